@@ -23,9 +23,47 @@ import (
 )
 
 var (
-	errBadBool = errors.New("abi: improperly encoded boolean value")
+	errBadBool    = errors.New("abi: improperly encoded boolean value")
+	errBadPadding = errors.New("abi: improperly padded value")
+
+	// ErrPackedDynamicType is returned when packing or unpacking a type which
+	// has no unambiguous packed encoding, e.g. nested arrays or tuples with
+	// variable-length fields.
+	ErrPackedDynamicType = errors.New("abi: type not supported by packed encoding")
+)
+
+// Errors wrapped by DecodeError when the topics of a log don't match an event.
+var (
+	ErrLogTopicCount = errors.New("abi: log topic count mismatch")
+	ErrLogSignature  = errors.New("abi: log signature mismatch")
 )
 
+// DecodeError is returned by Event.DecodeLog when a log doesn't match the event,
+// identifying the first mismatch encountered. Mismatches not specific to a field,
+// e.g. in the number of topics, leave Field and Type empty.
+type DecodeError struct {
+	Field   string // Name of the mismatching field
+	Type    string // Expected ABI type of the field
+	Indexed bool   // Whether the field was read from the topics
+	Data    []byte // Bytes received for the field (topic or head word)
+	Err     error  // Reason of the mismatch
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field == "" && e.Type == "" {
+		return fmt.Sprintf("abi: cannot decode log: %v", e.Err)
+	}
+	kind := "non-indexed"
+	if e.Indexed {
+		kind = "indexed"
+	}
+	return fmt.Sprintf("abi: cannot decode %s field %q of type %s from %x: %v", kind, e.Field, e.Type, e.Data, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 // formatSliceString formats the reflection kind with the given slice size
 // and returns a formatted string representation.
 func formatSliceString(kind reflect.Kind, sliceSize int) string {
@@ -17,10 +17,13 @@
 package abi
 
 import (
+	"bytes"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
@@ -75,3 +78,98 @@ func (e Event) Sig() string {
 func (e Event) ID() common.Hash {
 	return common.BytesToHash(crypto.Keccak256([]byte(e.Sig())))
 }
+
+// DecodeLog decodes the fields of the event from a log, returning their values
+// by name. Indexed fields of dynamic types (strings, bytes, arrays and tuples)
+// are stored as the Keccak256 hash of their value in the topics, so only the
+// hash is returned for them.
+//
+// If the log doesn't match the event, a *DecodeError describing the first
+// mismatch is returned.
+func (e Event) DecodeLog(log *types.Log) (map[string]interface{}, error) {
+	topics := log.Topics
+
+	// Check the signature and the number of topics
+	if !e.Anonymous {
+		if len(topics) == 0 {
+			return nil, &DecodeError{Err: fmt.Errorf("%w: missing signature", ErrLogTopicCount)}
+		}
+		if id := e.ID(); topics[0] != id {
+			return nil, &DecodeError{Indexed: true, Data: topics[0].Bytes(), Err: fmt.Errorf("%w: have %x, want %x", ErrLogSignature, topics[0], id)}
+		}
+		topics = topics[1:]
+	}
+	var indexed int
+	for _, arg := range e.Inputs {
+		if arg.Indexed {
+			indexed++
+		}
+	}
+	if len(topics) != indexed {
+		return nil, &DecodeError{Err: fmt.Errorf("%w: have %d indexed fields, want %d", ErrLogTopicCount, len(topics), indexed)}
+	}
+	// Decode the indexed fields from the topics and the rest from the data
+	out := make(map[string]interface{}, len(e.Inputs))
+	for _, arg := range e.Inputs {
+		if !arg.Indexed {
+			continue
+		}
+		value, err := decodeTopic(arg.Type, topics[0])
+		if err != nil {
+			return nil, &DecodeError{Field: arg.Name, Type: arg.Type.String(), Indexed: true, Data: topics[0].Bytes(), Err: err}
+		}
+		out[arg.Name] = value
+		topics = topics[1:]
+	}
+	offset := 0
+	for _, arg := range e.Inputs.NonIndexed() {
+		size := getTypeSize(arg.Type)
+		value, err := toGoType(offset, arg.Type, log.Data)
+		if err != nil {
+			// Report the head of the field, as far as it's present
+			start, end := offset, offset+size
+			if start > len(log.Data) {
+				start = len(log.Data)
+			}
+			if end > len(log.Data) {
+				end = len(log.Data)
+			}
+			return nil, &DecodeError{Field: arg.Name, Type: arg.Type.String(), Data: common.CopyBytes(log.Data[start:end]), Err: err}
+		}
+		out[arg.Name] = value
+		offset += size
+	}
+	return out, nil
+}
+
+// MustDecodeLog is like DecodeLog, but panics if the log doesn't match the event.
+// It's meant to be used in tests.
+func (e Event) MustDecodeLog(log *types.Log) map[string]interface{} {
+	out, err := e.DecodeLog(log)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// decodeTopic decodes an indexed field of type t from its topic.
+func decodeTopic(t Type, topic common.Hash) (interface{}, error) {
+	switch t.T {
+	case StringTy, BytesTy, SliceTy, ArrayTy, TupleTy:
+		// Only the hash of the value is stored in the topic
+		return topic, nil
+	}
+	value, err := toGoType(0, t, topic.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// Reject values which don't re-encode to the topic, e.g. an address with
+	// garbage in the padding.
+	switch t.T {
+	case IntTy, UintTy, AddressTy, FixedBytesTy:
+		if packed, err := t.pack(reflect.ValueOf(value)); err != nil || !bytes.Equal(packed, topic.Bytes()) {
+			return nil, errBadPadding
+		}
+	}
+	return value, nil
+}
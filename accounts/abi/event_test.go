@@ -20,12 +20,14 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -369,6 +371,16 @@ func TestEventUnpackIndexed(t *testing.T) {
 	require.NoError(t, abi.Unpack(&rst, "test", b.Bytes()))
 	require.Equal(t, uint8(0), rst.Value1)
 	require.Equal(t, uint8(8), rst.Value2)
+
+	// Decoding the full log also recovers the indexed field
+	event := abi.Events["test"]
+	log := &types.Log{
+		Topics: []common.Hash{event.ID(), common.BytesToHash(packNum(reflect.ValueOf(uint8(4))))},
+		Data:   b.Bytes(),
+	}
+	out, err := event.DecodeLog(log)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"value1": uint8(4), "value2": uint8(8)}, out)
 }
 
 // TestEventIndexedWithArrayUnpack verifies that decoder will not overlow when static array is indexed input.
@@ -391,4 +403,86 @@ func TestEventIndexedWithArrayUnpack(t *testing.T) {
 	require.NoError(t, abi.Unpack(&rst, "test", b.Bytes()))
 	require.Equal(t, [2]uint8{0, 0}, rst.Value1)
 	require.Equal(t, stringOut, rst.Value2)
+
+	// Indexed arrays are only available as the hash of their encoding
+	event := abi.Events["test"]
+	hash := crypto.Keccak256Hash(append(packNum(reflect.ValueOf(1)), packNum(reflect.ValueOf(2))...))
+	out := event.MustDecodeLog(&types.Log{Topics: []common.Hash{event.ID(), hash}, Data: b.Bytes()})
+	require.Equal(t, map[string]interface{}{"value1": hash, "value2": stringOut}, out)
+}
+
+// TestEventDecodeLogErrors verifies that log mismatches are reported with the
+// field they occur in.
+func TestEventDecodeLogErrors(t *testing.T) {
+	definition := `[{"name": "test", "type": "event", "inputs": [{"indexed": true, "name":"from", "type":"address"},{"indexed": false, "name":"amount", "type":"uint256"},{"indexed": false, "name":"memo", "type":"string"}]}]`
+	abi, err := JSON(strings.NewReader(definition))
+	require.NoError(t, err)
+	event := abi.Events["test"]
+
+	var (
+		from   = common.HexToAddress("0x00Ce0d46d924CC8437c806721496599FC3FFA268")
+		amount = packNum(reflect.ValueOf(big.NewInt(1000000)))
+		valid  = append(append(append([]byte{}, amount...), packNum(reflect.ValueOf(64))...), packBytesSlice([]byte("hi"), 2)...)
+		dirty  = common.BytesToHash(append([]byte{0xff}, from.Bytes()...))
+	)
+	out := event.MustDecodeLog(&types.Log{Topics: []common.Hash{event.ID(), from.Hash()}, Data: valid})
+	require.Equal(t, map[string]interface{}{"from": from, "amount": big.NewInt(1000000), "memo": "hi"}, out)
+
+	tests := []struct {
+		name   string
+		log    *types.Log
+		expect DecodeError
+		cause  error
+	}{
+		{
+			name:   "missing topics",
+			log:    &types.Log{Data: valid},
+			expect: DecodeError{},
+			cause:  ErrLogTopicCount,
+		},
+		{
+			name:   "wrong signature",
+			log:    &types.Log{Topics: []common.Hash{from.Hash(), from.Hash()}, Data: valid},
+			expect: DecodeError{Indexed: true, Data: from.Hash().Bytes()},
+			cause:  ErrLogSignature,
+		},
+		{
+			name:   "extra topic",
+			log:    &types.Log{Topics: []common.Hash{event.ID(), from.Hash(), from.Hash()}, Data: valid},
+			expect: DecodeError{},
+			cause:  ErrLogTopicCount,
+		},
+		{
+			name:   "dirty address padding",
+			log:    &types.Log{Topics: []common.Hash{event.ID(), dirty}, Data: valid},
+			expect: DecodeError{Field: "from", Type: "address", Indexed: true, Data: dirty.Bytes()},
+			cause:  errBadPadding,
+		},
+		{
+			name:   "truncated amount",
+			log:    &types.Log{Topics: []common.Hash{event.ID(), from.Hash()}, Data: amount[:16]},
+			expect: DecodeError{Field: "amount", Type: "uint256", Data: amount[:16]},
+		},
+		{
+			name:   "missing memo",
+			log:    &types.Log{Topics: []common.Hash{event.ID(), from.Hash()}, Data: amount},
+			expect: DecodeError{Field: "memo", Type: "string", Data: []byte{}},
+		},
+	}
+	for _, tt := range tests {
+		_, err := event.DecodeLog(tt.log)
+		decodeErr, ok := err.(*DecodeError)
+		if !ok {
+			t.Errorf("%s: error type mismatch: have %T (%v), want *DecodeError", tt.name, err, err)
+			continue
+		}
+		assert.Equal(t, tt.expect.Field, decodeErr.Field, tt.name)
+		assert.Equal(t, tt.expect.Type, decodeErr.Type, tt.name)
+		assert.Equal(t, tt.expect.Indexed, decodeErr.Indexed, tt.name)
+		assert.Equal(t, tt.expect.Data, decodeErr.Data, tt.name)
+		if tt.cause != nil {
+			assert.True(t, errors.Is(err, tt.cause), "%s: cause mismatch: have %v, want %v", tt.name, err, tt.cause)
+		}
+	}
+	assert.Panics(t, func() { event.MustDecodeLog(&types.Log{Data: valid}) })
 }
@@ -0,0 +1,262 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PackPacked packs the arguments like Solidity's abi.encodePacked: elementary
+// values take the minimal space of their type (e.g. 1 byte for uint8, 20 bytes
+// for address), strings and bytes are inlined without length, and elements of
+// arrays are padded to 32 bytes each. Static tuples are packed field by field.
+//
+// Types whose packed encoding would be ambiguous, i.e. nested arrays, arrays of
+// strings or bytes and tuples with variable-length fields, are rejected with
+// ErrPackedDynamicType.
+func (arguments Arguments) PackPacked(args ...interface{}) ([]byte, error) {
+	if len(args) != len(arguments) {
+		return nil, fmt.Errorf("argument count mismatch: %d for %d", len(args), len(arguments))
+	}
+	var ret []byte
+	for i, a := range args {
+		packed, err := arguments[i].Type.packPacked(reflect.ValueOf(a))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, packed...)
+	}
+	return ret, nil
+}
+
+// PackedUnpack is the inverse of PackPacked, returning the values of the
+// arguments decoded from the packed data. As packed values carry no length,
+// at most one of the arguments may be of variable length (string, bytes or a
+// slice), taking up the data not claimed by the others.
+func (arguments Arguments) PackedUnpack(data []byte) ([]interface{}, error) {
+	// Figure out the size of every argument, deriving the one of the variable
+	// length argument from the leftover data.
+	var (
+		sizes    = make([]int, len(arguments))
+		variable = -1
+		static   = 0
+	)
+	for i, arg := range arguments {
+		size, err := packedSize(arg.Type)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			if variable >= 0 {
+				return nil, fmt.Errorf("%w: multiple variable-length arguments (%s, %s)", ErrPackedDynamicType, arguments[variable].Type, arg.Type)
+			}
+			variable = i
+			continue
+		}
+		sizes[i] = size
+		static += size
+	}
+	if static > len(data) || (variable < 0 && static != len(data)) {
+		return nil, fmt.Errorf("abi: packed data length mismatch: have %d, want %d", len(data), static)
+	}
+	if variable >= 0 {
+		sizes[variable] = len(data) - static
+		if arguments[variable].Type.T == SliceTy && sizes[variable]%32 != 0 {
+			return nil, fmt.Errorf("abi: packed slice length %d not a multiple of 32", sizes[variable])
+		}
+	}
+	// Decode the arguments one by one
+	values := make([]interface{}, 0, len(arguments))
+	for i, arg := range arguments {
+		value, err := arg.Type.unpackPacked(data[:sizes[i]])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		data = data[sizes[i]:]
+	}
+	return values, nil
+}
+
+// packedSize returns the number of bytes taken by the packed encoding of t, or
+// -1 if it depends on the value.
+func packedSize(t Type) (int, error) {
+	switch t.T {
+	case IntTy, UintTy:
+		return t.Size / 8, nil
+	case BoolTy:
+		return 1, nil
+	case AddressTy:
+		return common.AddressLength, nil
+	case FixedBytesTy:
+		return t.Size, nil
+	case FunctionTy:
+		return 24, nil
+	case HashTy:
+		return common.HashLength, nil
+	case StringTy, BytesTy:
+		return -1, nil
+	case SliceTy, ArrayTy:
+		// Array elements are padded to 32 bytes, which is only well defined for
+		// elementary static elements.
+		if isDynamicType(*t.Elem) || t.Elem.T == ArrayTy || t.Elem.T == TupleTy {
+			return 0, fmt.Errorf("%w: %s", ErrPackedDynamicType, t)
+		}
+		if t.T == SliceTy {
+			return -1, nil
+		}
+		return 32 * t.Size, nil
+	case TupleTy:
+		total := 0
+		for _, elem := range t.TupleElems {
+			size, err := packedSize(*elem)
+			if err != nil {
+				return 0, err
+			}
+			if size < 0 {
+				return 0, fmt.Errorf("%w: %s", ErrPackedDynamicType, t)
+			}
+			total += size
+		}
+		return total, nil
+	default:
+		return 0, fmt.Errorf("abi: unsupported packed type %s", t)
+	}
+}
+
+// packPacked packs the given value in the packed encoding of t.
+func (t Type) packPacked(v reflect.Value) ([]byte, error) {
+	v = indirect(v)
+	if _, err := packedSize(t); err != nil {
+		return nil, err
+	}
+	if err := typeCheck(t, v); err != nil {
+		return nil, err
+	}
+	switch t.T {
+	case IntTy, UintTy:
+		// The standard encoding is the 256 bit two's complement, which only
+		// needs to be truncated to the size of the type.
+		return packNum(v)[32-t.Size/8:], nil
+	case BoolTy:
+		if v.Bool() {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case StringTy:
+		return []byte(v.String()), nil
+	case AddressTy, BytesTy, FixedBytesTy, FunctionTy:
+		if v.Kind() == reflect.Array {
+			v = mustArrayToByteSlice(v)
+		}
+		return common.CopyBytes(v.Bytes()), nil
+	case SliceTy, ArrayTy:
+		var ret []byte
+		for i := 0; i < v.Len(); i++ {
+			elem := indirect(v.Index(i))
+			if err := typeCheck(*t.Elem, elem); err != nil {
+				return nil, err
+			}
+			ret = append(ret, packElement(*t.Elem, elem)...)
+		}
+		return ret, nil
+	case TupleTy:
+		fieldmap, err := mapArgNamesToStructFields(t.TupleRawNames, v)
+		if err != nil {
+			return nil, err
+		}
+		var ret []byte
+		for i, elem := range t.TupleElems {
+			field := v.FieldByName(fieldmap[t.TupleRawNames[i]])
+			if !field.IsValid() {
+				return nil, fmt.Errorf("field %s for tuple not found in the given struct", t.TupleRawNames[i])
+			}
+			val, err := elem.packPacked(field)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, val...)
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("abi: unsupported packed type %s", t)
+	}
+}
+
+// unpackPacked decodes a value of type t from its packed encoding, which must
+// span the entire data.
+func (t Type) unpackPacked(data []byte) (interface{}, error) {
+	if _, err := packedSize(t); err != nil {
+		return nil, err
+	}
+	switch t.T {
+	case IntTy, UintTy:
+		// Extend the value to a full word, so it can be read like a standard
+		// encoded one.
+		word := make([]byte, 32)
+		if t.T == IntTy && len(data) > 0 && data[0]&0x80 != 0 {
+			for i := range word {
+				word[i] = 0xff
+			}
+		}
+		copy(word[32-len(data):], data)
+		return readInteger(t.T, t.Kind, word), nil
+	case BoolTy:
+		switch data[0] {
+		case 0:
+			return false, nil
+		case 1:
+			return true, nil
+		default:
+			return nil, errBadBool
+		}
+	case StringTy:
+		return string(data), nil
+	case BytesTy:
+		return common.CopyBytes(data), nil
+	case AddressTy:
+		return common.BytesToAddress(data), nil
+	case HashTy:
+		return common.BytesToHash(data), nil
+	case FixedBytesTy:
+		return readFixedBytes(t, data)
+	case FunctionTy:
+		var funcTy [24]byte
+		copy(funcTy[:], data)
+		return funcTy, nil
+	case SliceTy, ArrayTy:
+		// The elements are padded like in the standard encoding
+		return forEachUnpack(t, data, 0, len(data)/32)
+	case TupleTy:
+		retval := reflect.New(t.Type).Elem()
+		for i, elem := range t.TupleElems {
+			size, _ := packedSize(*elem)
+			value, err := elem.unpackPacked(data[:size])
+			if err != nil {
+				return nil, err
+			}
+			retval.Field(i).Set(reflect.ValueOf(value))
+			data = data[size:]
+		}
+		return retval.Interface(), nil
+	default:
+		return nil, fmt.Errorf("abi: unsupported packed type %s", t)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newPackedArguments creates unnamed arguments of the given types.
+func newPackedArguments(t *testing.T, types []string, components [][]ArgumentMarshaling) Arguments {
+	t.Helper()
+
+	var args Arguments
+	for i, typ := range types {
+		var comps []ArgumentMarshaling
+		if components != nil {
+			comps = components[i]
+		}
+		abiType, err := NewType(typ, "", comps)
+		if err != nil {
+			t.Fatalf("failed to create type %s: %v", typ, err)
+		}
+		args = append(args, Argument{Type: abiType})
+	}
+	return args
+}
+
+// Tests packed encoding against the output of abi.encodePacked in Solidity.
+func TestPackPacked(t *testing.T) {
+	addr := common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+
+	for i, test := range []struct {
+		types      []string
+		components [][]ArgumentMarshaling
+		inputs     []interface{}
+		output     string
+	}{
+		// abi.encodePacked(int16(-1), bytes1(0x42), uint16(0x03), string("Hello, world!"))
+		{
+			[]string{"int16", "bytes1", "uint16", "string"},
+			nil,
+			[]interface{}{int16(-1), [1]byte{0x42}, uint16(3), "Hello, world!"},
+			"ffff42000348656c6c6f2c20776f726c6421",
+		},
+		// abi.encodePacked(uint8(1), address(0x5aAe...eAed), bytes32(0x11...11), string("hello"))
+		{
+			[]string{"uint8", "address", "bytes32", "string"},
+			nil,
+			[]interface{}{uint8(1), addr, [32]byte{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11}, "hello"},
+			"015aaeb6053f3e94c9b9a09f33669435e7ef1beaed111111111111111111111111111111111111111111111111111111111111111168656c6c6f",
+		},
+		// abi.encodePacked(true, uint32(0xdeadbeef), hex"cafe")
+		{
+			[]string{"bool", "uint32", "bytes"},
+			nil,
+			[]interface{}{true, uint32(0xdeadbeef), []byte{0xca, 0xfe}},
+			"01deadbeefcafe",
+		},
+		// abi.encodePacked(int72(-1), uint128(0x0102))
+		{
+			[]string{"int72", "uint128"},
+			nil,
+			[]interface{}{big.NewInt(-1), big.NewInt(0x0102)},
+			"ffffffffffffffffff" + "00000000000000000000000000000102",
+		},
+		// abi.encodePacked(uint256[2]([1, 2]))
+		{
+			[]string{"uint256[2]"},
+			nil,
+			[]interface{}{[2]*big.Int{big.NewInt(1), big.NewInt(2)}},
+			"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002",
+		},
+		// abi.encodePacked(int8(-2), [address(0x5aAe...eAed)]) with a dynamic address[]
+		{
+			[]string{"int8", "address[]"},
+			nil,
+			[]interface{}{int8(-2), []common.Address{addr}},
+			"fe0000000000000000000000005aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		},
+		// Static tuples are packed field by field
+		{
+			[]string{"tuple"},
+			[][]ArgumentMarshaling{{{Name: "a", Type: "uint16"}, {Name: "b", Type: "address"}}},
+			[]interface{}{struct {
+				A uint16
+				B common.Address
+			}{0x0102, addr}},
+			"01025aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		},
+	} {
+		args := newPackedArguments(t, test.types, test.components)
+		packed, err := args.PackPacked(test.inputs...)
+		if err != nil {
+			t.Errorf("test %d: failed to pack: %v", i, err)
+			continue
+		}
+		if want := common.Hex2Bytes(test.output); !bytes.Equal(packed, want) {
+			t.Errorf("test %d: packed mismatch: have %x, want %x", i, packed, want)
+			continue
+		}
+		// Unpack the values and make sure they encode the same
+		values, err := args.PackedUnpack(packed)
+		if err != nil {
+			t.Errorf("test %d: failed to unpack: %v", i, err)
+			continue
+		}
+		repacked, err := args.PackPacked(values...)
+		if err != nil {
+			t.Errorf("test %d: failed to repack: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(repacked, packed) {
+			t.Errorf("test %d: repacked mismatch: have %x, want %x", i, repacked, packed)
+		}
+	}
+}
+
+func TestPackedUnpack(t *testing.T) {
+	args := newPackedArguments(t, []string{"int16", "bytes1", "uint16", "string"}, nil)
+	values, err := args.PackedUnpack(common.Hex2Bytes("ffff42000348656c6c6f2c20776f726c6421"))
+	if err != nil {
+		t.Fatalf("failed to unpack: %v", err)
+	}
+	want := []interface{}{int16(-1), [1]byte{0x42}, uint16(3), "Hello, world!"}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("unpacked values mismatch: have %v, want %v", values, want)
+	}
+	// Truncated and oversized data must be rejected
+	fixed := newPackedArguments(t, []string{"uint8", "address"}, nil)
+	if _, err := fixed.PackedUnpack(make([]byte, 20)); err == nil {
+		t.Error("unpacked truncated data")
+	}
+	if _, err := fixed.PackedUnpack(make([]byte, 22)); err == nil {
+		t.Error("unpacked oversized data")
+	}
+	// Slices must consist of whole elements
+	slice := newPackedArguments(t, []string{"uint256[]"}, nil)
+	if _, err := slice.PackedUnpack(make([]byte, 48)); err == nil {
+		t.Error("unpacked partial slice element")
+	}
+	// Booleans must be zero or one
+	boolean := newPackedArguments(t, []string{"bool"}, nil)
+	if _, err := boolean.PackedUnpack([]byte{2}); err != errBadBool {
+		t.Errorf("bad boolean error mismatch: have %v, want %v", err, errBadBool)
+	}
+}
+
+func TestPackedDynamicTypes(t *testing.T) {
+	dynamicTuple := [][]ArgumentMarshaling{{{Name: "a", Type: "uint256"}, {Name: "b", Type: "string"}}}
+
+	for i, test := range []struct {
+		types      []string
+		components [][]ArgumentMarshaling
+		inputs     []interface{}
+	}{
+		{[]string{"uint256[][]"}, nil, []interface{}{[][]*big.Int{{big.NewInt(1)}}}},
+		{[]string{"uint8[2][2]"}, nil, []interface{}{[2][2]uint8{{1, 2}, {3, 4}}}},
+		{[]string{"string[]"}, nil, []interface{}{[]string{"a", "b"}}},
+		{[]string{"bytes[2]"}, nil, []interface{}{[2][]byte{{1}, {2}}}},
+		{[]string{"tuple"}, dynamicTuple, []interface{}{struct {
+			A *big.Int
+			B string
+		}{big.NewInt(1), "a"}}},
+	} {
+		args := newPackedArguments(t, test.types, test.components)
+		if _, err := args.PackPacked(test.inputs...); !errors.Is(err, ErrPackedDynamicType) {
+			t.Errorf("test %d: pack error mismatch: have %v, want %v", i, err, ErrPackedDynamicType)
+		}
+		if _, err := args.PackedUnpack(make([]byte, 64)); !errors.Is(err, ErrPackedDynamicType) {
+			t.Errorf("test %d: unpack error mismatch: have %v, want %v", i, err, ErrPackedDynamicType)
+		}
+	}
+	// Multiple variable-length values can be packed, but not unpacked
+	args := newPackedArguments(t, []string{"string", "bytes"}, nil)
+	packed, err := args.PackPacked("a", []byte{0x62})
+	if err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+	if !bytes.Equal(packed, []byte("ab")) {
+		t.Fatalf("packed mismatch: have %x, want %x", packed, "ab")
+	}
+	if _, err := args.PackedUnpack(packed); !errors.Is(err, ErrPackedDynamicType) {
+		t.Errorf("unpack error mismatch: have %v, want %v", err, ErrPackedDynamicType)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// NewTuple constructs an anonymous struct holding the arguments, with the field
+// names and types a tuple of them is unpacked into. Every argument is set from
+// the value of the same name: tuples may be given as maps of their fields and
+// arrays or slices of tuples as slices of such maps, all other values must be
+// assignable to the Go type of their argument. Missing and unknown names are
+// rejected.
+func (arguments Arguments) NewTuple(values map[string]interface{}) (interface{}, error) {
+	elems, names := arguments.tupleElems()
+
+	fields := make([]reflect.StructField, len(arguments))
+	for i, arg := range arguments {
+		name := ToCamelCase(arg.Name)
+		if name == "" {
+			return nil, fmt.Errorf("abi: purely anonymous or underscored argument is not supported")
+		}
+		for j := 0; j < i; j++ {
+			if fields[j].Name == name {
+				return nil, fmt.Errorf("abi: arguments %q and %q map to the same field %s", arguments[j].Name, arg.Name, name)
+			}
+		}
+		fields[i] = reflect.StructField{
+			Name: name,
+			Type: arg.Type.Type,
+			Tag:  reflect.StructTag("json:\"" + arg.Name + "\""),
+		}
+	}
+	tuple, err := newTupleValue("", reflect.StructOf(fields), elems, names, values)
+	if err != nil {
+		return nil, err
+	}
+	return tuple.Interface(), nil
+}
+
+// ToMap is the inverse of NewTuple, returning the values of the arguments held
+// by the given struct (or pointer to one) keyed by argument name. Nested tuples
+// are converted to maps of their fields and arrays or slices of tuples to slices
+// of such maps. Struct fields not corresponding to any argument are rejected.
+func (arguments Arguments) ToMap(unpacked interface{}) (map[string]interface{}, error) {
+	elems, names := arguments.tupleElems()
+	return tupleToMap("", indirect(reflect.ValueOf(unpacked)), elems, names)
+}
+
+// tupleElems returns the types and names of the arguments, as if they were the
+// components of a tuple.
+func (arguments Arguments) tupleElems() ([]*Type, []string) {
+	var (
+		elems = make([]*Type, len(arguments))
+		names = make([]string, len(arguments))
+	)
+	for i := range arguments {
+		elems[i], names[i] = &arguments[i].Type, arguments[i].Name
+	}
+	return elems, names
+}
+
+// newTupleValue creates a struct of the given type, setting the fields of the
+// tuple components from the values of the same name. The path of the tuple is
+// used to report the offending field on failure.
+func newTupleValue(path string, typ reflect.Type, elems []*Type, names []string, values map[string]interface{}) (reflect.Value, error) {
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	for name := range values {
+		if !known[name] {
+			return reflect.Value{}, fmt.Errorf("abi: unknown tuple field %s", fieldPath(path, name))
+		}
+	}
+	tuple := reflect.New(typ).Elem()
+	for i, name := range names {
+		value, ok := values[name]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("abi: missing tuple field %s", fieldPath(path, name))
+		}
+		field, err := newFieldValue(fieldPath(path, name), *elems[i], value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		tuple.Field(i).Set(field)
+	}
+	return tuple, nil
+}
+
+// newFieldValue converts the given value into the Go type of t, constructing
+// nested tuples from maps of their fields.
+func newFieldValue(path string, t Type, value interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(value)
+	if v.IsValid() && v.Type().AssignableTo(t.Type) {
+		return v, nil
+	}
+	switch {
+	case t.T == TupleTy:
+		if fields, ok := value.(map[string]interface{}); ok {
+			return newTupleValue(path, t.Type, t.TupleElems, t.TupleRawNames, fields)
+		}
+	case (t.T == SliceTy || t.T == ArrayTy) && v.Kind() == reflect.Slice:
+		var list reflect.Value
+		if t.T == SliceTy {
+			list = reflect.MakeSlice(t.Type, v.Len(), v.Len())
+		} else {
+			if v.Len() != t.Size {
+				return reflect.Value{}, fmt.Errorf("abi: array length mismatch for %s: have %d, want %d", path, v.Len(), t.Size)
+			}
+			list = reflect.New(t.Type).Elem()
+		}
+		for i := 0; i < v.Len(); i++ {
+			elem, err := newFieldValue(fmt.Sprintf("%s[%d]", path, i), *t.Elem, v.Index(i).Interface())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			list.Index(i).Set(elem)
+		}
+		return list, nil
+	}
+	return reflect.Value{}, fmt.Errorf("abi: cannot use %T as type %v for %s", value, t.Type, path)
+}
+
+// tupleToMap collects the fields of the tuple components from the given struct
+// into a map keyed by component name.
+func tupleToMap(path string, v reflect.Value, elems []*Type, names []string) (map[string]interface{}, error) {
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("abi: cannot convert %v to tuple map, struct expected", v.Kind())
+	}
+	var (
+		values = make(map[string]interface{}, len(names))
+		known  = make(map[string]bool, len(names))
+	)
+	for i, name := range names {
+		field := v.FieldByName(ToCamelCase(name))
+		if !field.IsValid() {
+			return nil, fmt.Errorf("abi: missing struct field %s for %s", ToCamelCase(name), fieldPath(path, name))
+		}
+		value, err := fieldToValue(fieldPath(path, name), field, *elems[i])
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+		known[ToCamelCase(name)] = true
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Name; !known[name] {
+			return nil, fmt.Errorf("abi: unknown struct field %s", fieldPath(path, name))
+		}
+	}
+	return values, nil
+}
+
+// fieldToValue returns the value of a struct field of type t, converting nested
+// tuples into maps of their fields.
+func fieldToValue(path string, field reflect.Value, t Type) (interface{}, error) {
+	field = indirect(field)
+	switch {
+	case t.T == TupleTy:
+		return tupleToMap(path, field, t.TupleElems, t.TupleRawNames)
+	case (t.T == SliceTy || t.T == ArrayTy) && hasTuple(*t.Elem):
+		if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
+			return nil, fmt.Errorf("abi: cannot convert %v to list for %s", field.Kind(), path)
+		}
+		list := make([]interface{}, field.Len())
+		for i := range list {
+			elem, err := fieldToValue(fmt.Sprintf("%s[%d]", path, i), field.Index(i), *t.Elem)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = elem
+		}
+		return list, nil
+	default:
+		return field.Interface(), nil
+	}
+}
+
+// hasTuple reports whether t is a tuple or a (nested) array or slice of them.
+func hasTuple(t Type) bool {
+	for t.T == SliceTy || t.T == ArrayTy {
+		t = *t.Elem
+	}
+	return t.T == TupleTy
+}
+
+// fieldPath returns the path of the named field within the given tuple path.
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
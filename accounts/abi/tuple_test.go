@@ -0,0 +1,190 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const tupleArguments = `[
+	{"name": "id", "type": "uint256"},
+	{"name": "owner", "type": "tuple", "components": [
+		{"name": "addr", "type": "address"},
+		{"name": "tags", "type": "string[]"},
+		{"name": "inner", "type": "tuple", "components": [{"name": "flag", "type": "bool"}]}
+	]},
+	{"name": "items", "type": "tuple[]", "components": [
+		{"name": "amount", "type": "uint8"},
+		{"name": "memo", "type": "bytes"}
+	]},
+	{"name": "pair", "type": "tuple[2]", "components": [{"name": "x", "type": "int64"}]}
+]`
+
+// newTupleArguments parses the arguments used by the tuple tests.
+func newTupleArguments(t *testing.T) Arguments {
+	t.Helper()
+
+	var args Arguments
+	if err := json.Unmarshal([]byte(tupleArguments), &args); err != nil {
+		t.Fatalf("failed to parse arguments: %v", err)
+	}
+	return args
+}
+
+// newTupleValues returns values for all the tuple test arguments.
+func newTupleValues() map[string]interface{} {
+	return map[string]interface{}{
+		"id": big.NewInt(42),
+		"owner": map[string]interface{}{
+			"addr":  common.HexToAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"),
+			"tags":  []string{"a", "b"},
+			"inner": map[string]interface{}{"flag": true},
+		},
+		"items": []map[string]interface{}{
+			{"amount": uint8(1), "memo": []byte{0x01}},
+			{"amount": uint8(2), "memo": []byte{0x02, 0x03}},
+		},
+		"pair": []interface{}{
+			map[string]interface{}{"x": int64(-1)},
+			map[string]interface{}{"x": int64(1)},
+		},
+	}
+}
+
+// Tests that tuples constructed from maps have the types produced by unpacking,
+// and convert back into the same maps.
+func TestNewTuple(t *testing.T) {
+	args := newTupleArguments(t)
+
+	tuple, err := args.NewTuple(newTupleValues())
+	if err != nil {
+		t.Fatalf("failed to create tuple: %v", err)
+	}
+	// Pack the fields of the tuple and unpack them again, they must match exactly
+	v := reflect.ValueOf(tuple)
+	fields := make([]interface{}, v.NumField())
+	for i := range fields {
+		fields[i] = v.Field(i).Interface()
+	}
+	packed, err := args.Pack(fields...)
+	if err != nil {
+		t.Fatalf("failed to pack tuple: %v", err)
+	}
+	unpacked, err := args.UnpackValues(packed)
+	if err != nil {
+		t.Fatalf("failed to unpack tuple: %v", err)
+	}
+	if !reflect.DeepEqual(unpacked, fields) {
+		t.Fatalf("unpacked tuple mismatch: have %+v, want %+v", unpacked, fields)
+	}
+	if field, _ := v.Type().FieldByName("Owner"); field.Tag.Get("json") != "owner" {
+		t.Errorf("field tag mismatch: have %q, want %q", field.Tag.Get("json"), "owner")
+	}
+	// Convert the tuple back, also through a pointer
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	for _, input := range []interface{}{tuple, ptr.Interface()} {
+		values, err := args.ToMap(input)
+		if err != nil {
+			t.Fatalf("failed to convert tuple: %v", err)
+		}
+		want := newTupleValues()
+		want["items"] = []interface{}{
+			map[string]interface{}{"amount": uint8(1), "memo": []byte{0x01}},
+			map[string]interface{}{"amount": uint8(2), "memo": []byte{0x02, 0x03}},
+		}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("converted tuple mismatch: have %v, want %v", values, want)
+		}
+	}
+}
+
+// Tests that missing, unknown and mistyped values are reported with their path.
+func TestNewTupleErrors(t *testing.T) {
+	args := newTupleArguments(t)
+
+	for i, test := range []struct {
+		mutate func(values map[string]interface{})
+		err    string
+	}{
+		{func(values map[string]interface{}) { delete(values, "id") }, "missing tuple field id"},
+		{func(values map[string]interface{}) { values["extra"] = 1 }, "unknown tuple field extra"},
+		{func(values map[string]interface{}) {
+			delete(values["owner"].(map[string]interface{})["inner"].(map[string]interface{}), "flag")
+		}, "missing tuple field owner.inner.flag"},
+		{func(values map[string]interface{}) {
+			values["items"].([]map[string]interface{})[1]["extra"] = 1
+		}, "unknown tuple field items[1].extra"},
+		{func(values map[string]interface{}) { values["id"] = 42 }, "cannot use int as type *big.Int for id"},
+		{func(values map[string]interface{}) {
+			values["pair"] = values["pair"].([]interface{})[:1]
+		}, "array length mismatch for pair: have 1, want 2"},
+	} {
+		values := newTupleValues()
+		test.mutate(values)
+		if _, err := args.NewTuple(values); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, test.err)
+		}
+	}
+	// Unnamed arguments can't be turned into fields
+	unnamed := Arguments{{Type: args[0].Type}}
+	if _, err := unnamed.NewTuple(map[string]interface{}{"": big.NewInt(1)}); err == nil {
+		t.Error("created tuple of unnamed argument")
+	}
+}
+
+// Tests that structs not matching the arguments are rejected when converted.
+func TestToMapErrors(t *testing.T) {
+	args := newTupleArguments(t)[:2]
+
+	type inner struct{ Flag bool }
+	type owner struct {
+		Addr  common.Address
+		Tags  []string
+		Inner inner
+	}
+	if _, err := args.ToMap(struct{ Id *big.Int }{big.NewInt(1)}); err == nil || !strings.Contains(err.Error(), "missing struct field Owner for owner") {
+		t.Errorf("missing field error mismatch: %v", err)
+	}
+	extra := struct {
+		Id    *big.Int
+		Owner owner
+		Extra bool
+	}{Id: big.NewInt(1)}
+	if _, err := args.ToMap(extra); err == nil || !strings.Contains(err.Error(), "unknown struct field Extra") {
+		t.Errorf("unknown field error mismatch: %v", err)
+	}
+	nested := struct {
+		Id    *big.Int
+		Owner struct {
+			Addr common.Address
+			Tags []string
+		}
+	}{Id: big.NewInt(1)}
+	if _, err := args.ToMap(nested); err == nil || !strings.Contains(err.Error(), "missing struct field Inner for owner.inner") {
+		t.Errorf("nested missing field error mismatch: %v", err)
+	}
+	if _, err := args.ToMap(42); err == nil {
+		t.Error("converted non-struct value")
+	}
+}
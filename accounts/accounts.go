@@ -171,6 +171,20 @@ type Backend interface {
 	Subscribe(sink chan<- WalletEvent) event.Subscription
 }
 
+// Statuses of the wallet change detection reported by a WatchReporter.
+const (
+	WatchStatusWatching = "watching"         // Changes are picked up as they happen
+	WatchStatusPolling  = "degraded-polling" // Changes are only picked up by polling
+)
+
+// WatchReporter is an optional interface of backends which watch for the arrival
+// and departure of wallets, reporting whether the watching currently works.
+type WatchReporter interface {
+	// WatchStatus returns the URL scheme of the wallets of the backend, along
+	// with the status of its change detection.
+	WatchStatus() (scheme string, status string)
+}
+
 // TextHash is a helper function that calculates a hash for the given message that can be
 // safely used to calculate a signature from.
 //
@@ -229,16 +229,16 @@ func (ac *accountCache) close() {
 }
 
 // scanAccounts checks if any changes have occurred on the filesystem, and
-// updates the account cache accordingly
-func (ac *accountCache) scanAccounts() error {
+// updates the account cache accordingly, reporting whether anything changed.
+func (ac *accountCache) scanAccounts() (bool, error) {
 	// Scan the entire folder metadata for file changes
 	creates, deletes, updates, err := ac.fileC.scan(ac.keydir)
 	if err != nil {
 		log.Debug("Failed to reload keystore contents", "err", err)
-		return err
+		return false, err
 	}
 	if creates.Cardinality() == 0 && deletes.Cardinality() == 0 && updates.Cardinality() == 0 {
-		return nil
+		return false, nil
 	}
 	// Create a helper method to scan the contents of the key files
 	var (
@@ -297,5 +297,5 @@ func (ac *accountCache) scanAccounts() error {
 	default:
 	}
 	log.Trace("Handled keystore changes", "time", end.Sub(start))
-	return nil
+	return true, nil
 }
@@ -176,6 +176,15 @@ func (ks *KeyStore) refreshWallets() {
 	}
 }
 
+// WatchStatus implements accounts.WatchReporter, returning whether changes of the
+// keystore directory are picked up by a watcher, or only by polling.
+func (ks *KeyStore) WatchStatus() (string, string) {
+	ks.cache.mu.Lock()
+	defer ks.cache.mu.Unlock()
+
+	return KeyStoreScheme, ks.cache.watcher.status()
+}
+
 // Subscribe implements accounts.Backend, creating an async subscription to
 // receive notifications on the addition or removal of keystore wallets.
 func (ks *KeyStore) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
@@ -19,25 +19,69 @@
 package keystore
 
 import (
+	"errors"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/rjeczalik/notify"
 )
 
+const (
+	// watchCheckInterval is the time between the self-checks of a running watcher,
+	// comparing a scan of the keystore folder to the cached accounts.
+	watchCheckInterval = time.Minute
+
+	// watchRetryMin and watchRetryMax bound the backoff between the attempts to
+	// re-establish a failed watcher.
+	watchRetryMin = time.Second
+	watchRetryMax = time.Minute
+)
+
+// errWatcherStale is returned if the self-check of a watcher found changes in the
+// keystore folder it didn't report.
+var errWatcherStale = errors.New("watcher missed keystore changes")
+
+// notifier is the file system notification facility used by the watcher. It is
+// an interface so that watcher failures can be simulated.
+type notifier interface {
+	Watch(path string, c chan<- notify.EventInfo, events ...notify.Event) error
+	Stop(c chan<- notify.EventInfo)
+}
+
+// notifyLib is the notifier backed by the notify library.
+type notifyLib struct{}
+
+func (notifyLib) Watch(path string, c chan<- notify.EventInfo, events ...notify.Event) error {
+	return notify.Watch(path, c, events...)
+}
+
+func (notifyLib) Stop(c chan<- notify.EventInfo) {
+	notify.Stop(c)
+}
+
 type watcher struct {
 	ac       *accountCache
+	notifier notifier
 	starting bool
 	running  bool
 	ev       chan notify.EventInfo
 	quit     chan struct{}
+
+	checkInterval time.Duration // Time between the self-checks of the watcher
+	retryMin      time.Duration // Initial backoff of re-establishing the watcher
+	retryMax      time.Duration // Maximum backoff of re-establishing the watcher
 }
 
 func newWatcher(ac *accountCache) *watcher {
 	return &watcher{
-		ac:   ac,
-		ev:   make(chan notify.EventInfo, 10),
-		quit: make(chan struct{}),
+		ac:            ac,
+		notifier:      notifyLib{},
+		ev:            make(chan notify.EventInfo, 10),
+		quit:          make(chan struct{}),
+		checkInterval: watchCheckInterval,
+		retryMin:      watchRetryMin,
+		retryMax:      watchRetryMax,
 	}
 }
 
@@ -56,20 +100,62 @@ func (w *watcher) close() {
 	close(w.quit)
 }
 
+// status returns whether changes of the keystore folder are picked up by the
+// watcher, or only by polling. The caller must hold w.ac.mu.
+func (w *watcher) status() string {
+	if w.running {
+		return accounts.WatchStatusWatching
+	}
+	return accounts.WatchStatusPolling
+}
+
+// loop keeps the keystore folder watched until the watcher is closed. If watching
+// fails, the account cache falls back to polling while the watcher is being
+// re-established with backoff.
 func (w *watcher) loop() {
 	defer func() {
 		w.ac.mu.Lock()
-		w.running = false
 		w.starting = false
 		w.ac.mu.Unlock()
 	}()
 	logger := log.New("path", w.ac.keydir)
 
-	if err := notify.Watch(w.ac.keydir, w.ev, notify.All); err != nil {
-		logger.Trace("Failed to watch keystore folder", "err", err)
-		return
+	var (
+		retry  = w.retryMin
+		failed = false
+	)
+	for {
+		started, err := w.watch(logger, failed)
+		if err == nil {
+			return
+		}
+		if started {
+			logger.Warn("Keystore watcher failed, polling until restored", "err", err)
+			retry, failed = w.retryMin, true
+		} else {
+			logger.Trace("Failed to watch keystore folder", "err", err)
+		}
+		timer := time.NewTimer(retry)
+		select {
+		case <-w.quit:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if retry *= 2; retry > w.retryMax {
+			retry = w.retryMax
+		}
 	}
-	defer notify.Stop(w.ev)
+}
+
+// watch reloads the account cache on file system events until the watcher is
+// closed or fails. On failure, the error is returned along with whether watching
+// had started. The failed flag tells whether a previous watcher failed.
+func (w *watcher) watch(logger log.Logger, failed bool) (bool, error) {
+	if err := w.notifier.Watch(w.ac.keydir, w.ev, notify.All); err != nil {
+		return false, err
+	}
+	defer w.notifier.Stop(w.ev)
 	logger.Trace("Started watching keystore folder")
 	defer logger.Trace("Stopped watching keystore folder")
 
@@ -77,6 +163,17 @@ func (w *watcher) loop() {
 	w.running = true
 	w.ac.mu.Unlock()
 
+	defer func() {
+		w.ac.mu.Lock()
+		w.running = false
+		w.ac.mu.Unlock()
+	}()
+	if failed {
+		logger.Info("Keystore watcher restored")
+	}
+	// Pick up the changes made while the folder wasn't watched
+	w.ac.scanAccounts()
+
 	// Wait for file system events and reload.
 	// When an event occurs, the reload call is delayed a bit so that
 	// multiple events arriving quickly only cause a single reload.
@@ -84,16 +181,18 @@ func (w *watcher) loop() {
 		debounceDuration = 500 * time.Millisecond
 		rescanTriggered  = false
 		debounce         = time.NewTimer(0)
+		check            = time.NewTicker(w.checkInterval)
 	)
 	// Ignore initial trigger
 	if !debounce.Stop() {
 		<-debounce.C
 	}
 	defer debounce.Stop()
+	defer check.Stop()
 	for {
 		select {
 		case <-w.quit:
-			return
+			return true, nil
 		case <-w.ev:
 			// Trigger the scan (with delay), if not already triggered
 			if !rescanTriggered {
@@ -103,6 +202,20 @@ func (w *watcher) loop() {
 		case <-debounce.C:
 			w.ac.scanAccounts()
 			rescanTriggered = false
+		case <-check.C:
+			// Unless events are pending, the folder must match the cache. Changes
+			// found by the scan were missed by the watcher, which is deemed dead.
+			// The scan itself picks up the missed changes.
+			if rescanTriggered || len(w.ev) > 0 {
+				continue
+			}
+			changed, err := w.ac.scanAccounts()
+			if err != nil {
+				return true, err
+			}
+			if changed {
+				return true, errWatcherStale
+			}
 		}
 	}
 }
@@ -21,8 +21,11 @@
 
 package keystore
 
+import "github.com/ethereum/go-ethereum/accounts"
+
 type watcher struct{ running bool }
 
 func newWatcher(*accountCache) *watcher { return new(watcher) }
 func (*watcher) start()                 {}
 func (*watcher) close()                 {}
+func (*watcher) status() string         { return accounts.WatchStatusPolling }
@@ -0,0 +1,183 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin,!ios,cgo freebsd linux,!arm64 netbsd solaris
+
+package keystore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cespare/cp"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/rjeczalik/notify"
+)
+
+// testNotifier is a notifier whose watches can be made to fail, either by being
+// refused or by silently dropping all events.
+type testNotifier struct {
+	refuse  bool // Whether new watches are refused
+	dead    bool // Whether new watches never deliver events
+	watches int  // Number of watches requested
+
+	lock sync.Mutex
+}
+
+func (n *testNotifier) Watch(path string, c chan<- notify.EventInfo, events ...notify.Event) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.watches++
+	switch {
+	case n.refuse:
+		return errors.New("watch refused")
+	case n.dead:
+		return nil
+	default:
+		return notify.Watch(path, c, events...)
+	}
+}
+
+func (n *testNotifier) Stop(c chan<- notify.EventInfo) {
+	notify.Stop(c)
+}
+
+func (n *testNotifier) set(refuse, dead bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.refuse, n.dead = refuse, dead
+}
+
+func (n *testNotifier) count() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.watches
+}
+
+// newTestWatchedCache creates an account cache over an empty temporary folder,
+// watched through the given notifier with short check and retry intervals.
+func newTestWatchedCache(t *testing.T, n notifier) (string, *accountCache, chan struct{}) {
+	dir, err := ioutil.TempDir("", "eth-keystore-watch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, changes := newAccountCache(dir)
+	cache.watcher.notifier = n
+	cache.watcher.checkInterval = 100 * time.Millisecond
+	cache.watcher.retryMin = 50 * time.Millisecond
+	cache.watcher.retryMax = 100 * time.Millisecond
+
+	cache.accounts() // Start the watcher
+	return dir, cache, changes
+}
+
+// waitWatchStatus waits until the watcher of the cache reports the given status.
+func waitWatchStatus(cache *accountCache, status string) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		cache.mu.Lock()
+		have := cache.watcher.status()
+		cache.mu.Unlock()
+
+		if have == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Tests that a watcher silently dropping events is detected by its self-check,
+// the missed changes are picked up, and the watcher is re-established.
+func TestWatcherSelfCheck(t *testing.T) {
+	t.Parallel()
+
+	n := &testNotifier{dead: true}
+	dir, cache, changes := newTestWatchedCache(t, n)
+	defer os.RemoveAll(dir)
+	defer cache.close()
+
+	if !waitWatchStatus(cache, accounts.WatchStatusWatching) {
+		t.Fatal("watcher not started")
+	}
+	// Add a key unnoticed by the watcher, it must be picked up by the self-check
+	file := filepath.Join(dir, "aaa")
+	if err := cp.CopyFile(file, cachetestAccounts[0].URL.Path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("missed change not reconciled")
+	}
+	cache.mu.Lock()
+	found := len(cache.byAddr[cachetestAccounts[0].Address]) == 1
+	cache.mu.Unlock()
+	if !found {
+		t.Fatal("missed account not added")
+	}
+	// The watcher must be re-established after the failure
+	for deadline := time.Now().Add(5 * time.Second); n.count() < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher not re-established")
+		}
+	}
+	if !waitWatchStatus(cache, accounts.WatchStatusWatching) {
+		t.Fatal("watcher not restored")
+	}
+}
+
+// Tests that a watcher which can't be established is retried until it succeeds,
+// reporting degraded polling meanwhile.
+func TestWatcherRetry(t *testing.T) {
+	t.Parallel()
+
+	n := &testNotifier{refuse: true}
+	dir, cache, changes := newTestWatchedCache(t, n)
+	defer os.RemoveAll(dir)
+	defer cache.close()
+
+	for deadline := time.Now().Add(5 * time.Second); n.count() < 3; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher not retried")
+		}
+	}
+	if !waitWatchStatus(cache, accounts.WatchStatusPolling) {
+		t.Fatal("failing watcher not reported as degraded")
+	}
+	// Add a key while not watched, then allow the watcher to be established.
+	// The key must be picked up when it's restored.
+	file := filepath.Join(dir, "aaa")
+	if err := cp.CopyFile(file, cachetestAccounts[0].URL.Path); err != nil {
+		t.Fatal(err)
+	}
+	n.set(false, false)
+
+	if !waitWatchStatus(cache, accounts.WatchStatusWatching) {
+		t.Fatal("watcher not restored")
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("missed change not reconciled")
+	}
+}
@@ -136,6 +136,26 @@ func (am *Manager) Backends(kind reflect.Type) []Backend {
 	return am.backends[kind]
 }
 
+// WatchStatuses returns the change detection status of the backends reporting
+// one, keyed by the URL scheme of their wallets. If several backends serve the
+// same scheme, the scheme is reported as degraded if any of them is.
+func (am *Manager) WatchStatuses() map[string]string {
+	statuses := make(map[string]string)
+	for _, kind := range am.backends {
+		for _, backend := range kind {
+			reporter, ok := backend.(WatchReporter)
+			if !ok {
+				continue
+			}
+			scheme, status := reporter.WatchStatus()
+			if statuses[scheme] != WatchStatusPolling {
+				statuses[scheme] = status
+			}
+		}
+	}
+	return statuses
+}
+
 // Wallets returns all signer accounts registered under this account manager.
 func (am *Manager) Wallets() []Wallet {
 	am.lock.RLock()
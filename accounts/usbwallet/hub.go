@@ -43,6 +43,10 @@ const refreshCycle = time.Second
 // trashing.
 const refreshThrottling = 500 * time.Millisecond
 
+// enumRetryMax is the maximum time between USB enumeration attempts while the
+// enumeration is continually failing.
+const enumRetryMax = time.Minute
+
 // Hub is a accounts.Backend that can find and handle generic USB hardware wallets.
 type Hub struct {
 	scheme     string                  // Protocol scheme prefixing account and wallet URLs.
@@ -52,6 +56,8 @@ type Hub struct {
 	endpointID int                     // USB endpoint identifier used for non-macOS device discovery
 	makeDriver func(log.Logger) driver // Factory method to construct a vendor specific driver
 
+	enumerate func(vendorID uint16, productID uint16) ([]usb.DeviceInfo, error) // USB enumerator, replaceable to simulate failures
+
 	refreshed   time.Time               // Time instance when the list of wallets was last refreshed
 	wallets     []accounts.Wallet       // List of USB wallet devices currently tracking
 	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
@@ -66,6 +72,7 @@ type Hub struct {
 	commsPend int        // Number of operations blocking enumeration
 	commsLock sync.Mutex // Lock protecting the pending counter and enumeration
 	enumFails uint32     // Number of times enumeration has failed
+	enumRetry time.Time  // Time before which failing enumerations aren't retried
 }
 
 // NewLedgerHub creates a new hardware wallet manager for Ledger devices.
@@ -109,6 +116,7 @@ func newHub(scheme string, vendorID uint16, productIDs []uint16, usageID uint16,
 		usageID:    usageID,
 		endpointID: endpointID,
 		makeDriver: makeDriver,
+		enumerate:  usb.Enumerate,
 		quit:       make(chan chan error),
 	}
 	hub.refreshWallets()
@@ -140,8 +148,12 @@ func (hub *Hub) refreshWallets() {
 	if elapsed < refreshThrottling {
 		return
 	}
-	// If USB enumeration is continually failing, don't keep trying indefinitely
-	if atomic.LoadUint32(&hub.enumFails) > 2 {
+	// If USB enumeration is continually failing, back off before retrying
+	hub.stateLock.RLock()
+	retry := hub.enumRetry
+	hub.stateLock.RUnlock()
+
+	if time.Now().Before(retry) {
 		return
 	}
 	// Retrieve the current list of USB wallet devices
@@ -160,7 +172,7 @@ func (hub *Hub) refreshWallets() {
 			return
 		}
 	}
-	infos, err := usb.Enumerate(hub.vendorID, 0)
+	infos, err := hub.enumerate(hub.vendorID, 0)
 	if err != nil {
 		failcount := atomic.AddUint32(&hub.enumFails, 1)
 		if runtime.GOOS == "linux" {
@@ -169,9 +181,26 @@ func (hub *Hub) refreshWallets() {
 		}
 		log.Error("Failed to enumerate USB devices", "hub", hub.scheme,
 			"vendor", hub.vendorID, "failcount", failcount, "err", err)
+
+		// Past a few failures, retry with exponential backoff until the USB
+		// subsystem recovers (e.g. after a reset)
+		if failcount > 2 {
+			backoff := enumRetryMax
+			if shift := failcount - 3; shift < 6 {
+				backoff = refreshCycle << shift
+			}
+			if backoff > enumRetryMax {
+				backoff = enumRetryMax
+			}
+			hub.stateLock.Lock()
+			hub.enumRetry = time.Now().Add(backoff)
+			hub.stateLock.Unlock()
+		}
 		return
 	}
-	atomic.StoreUint32(&hub.enumFails, 0)
+	if failcount := atomic.SwapUint32(&hub.enumFails, 0); failcount > 2 {
+		log.Info("USB enumeration restored", "hub", hub.scheme, "vendor", hub.vendorID, "failcount", failcount)
+	}
 
 	for _, info := range infos {
 		for _, id := range hub.productIDs {
@@ -238,6 +267,16 @@ func (hub *Hub) refreshWallets() {
 	}
 }
 
+// WatchStatus implements accounts.WatchReporter, returning whether the USB devices
+// are enumerated successfully, or enumeration is failing and retried with backoff.
+// Wallet changes missed meanwhile are reported once enumeration succeeds again.
+func (hub *Hub) WatchStatus() (string, string) {
+	if atomic.LoadUint32(&hub.enumFails) > 2 {
+		return hub.scheme, accounts.WatchStatusPolling
+	}
+	return hub.scheme, accounts.WatchStatusWatching
+}
+
 // Subscribe implements accounts.Backend, creating an async subscription to
 // receive notifications on the addition or removal of USB wallets.
 func (hub *Hub) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
@@ -0,0 +1,82 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package usbwallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/karalabe/usb"
+)
+
+// Tests that a hub keeps retrying a failing USB enumeration with backoff, and
+// reports the wallets missed meanwhile once it recovers.
+func TestHubEnumerationRecovery(t *testing.T) {
+	var (
+		failing = true
+		calls   = 0
+		device  = usb.DeviceInfo{Path: "test", ProductID: 0x0001, UsagePage: 0xffa0}
+	)
+	hub := &Hub{
+		scheme:     LedgerScheme,
+		vendorID:   0x2c97,
+		productIDs: []uint16{0x0001},
+		usageID:    0xffa0,
+		makeDriver: newLedgerDriver,
+		enumerate: func(vendorID uint16, productID uint16) ([]usb.DeviceInfo, error) {
+			calls++
+			if failing {
+				return nil, errors.New("usb reset")
+			}
+			return []usb.DeviceInfo{device}, nil
+		},
+		quit: make(chan chan error),
+	}
+	events := make(chan accounts.WalletEvent, 1)
+	sub := hub.updateFeed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	// Fail the enumeration until the hub backs off
+	for i := 0; i < 3; i++ {
+		hub.refreshWallets()
+	}
+	if _, status := hub.WatchStatus(); status != accounts.WatchStatusPolling {
+		t.Fatalf("status mismatch: have %s, want %s", status, accounts.WatchStatusPolling)
+	}
+	hub.refreshWallets()
+	if calls != 3 {
+		t.Fatalf("enumeration not backed off: have %d calls, want 3", calls)
+	}
+	// Recover the USB subsystem and let the backoff expire
+	failing = false
+	hub.enumRetry = time.Time{}
+
+	hub.refreshWallets()
+	if _, status := hub.WatchStatus(); status != accounts.WatchStatusWatching {
+		t.Fatalf("status mismatch: have %s, want %s", status, accounts.WatchStatusWatching)
+	}
+	select {
+	case event := <-events:
+		if event.Kind != accounts.WalletArrived || event.Wallet.URL().Path != device.Path {
+			t.Fatalf("unexpected wallet event: %v %v", event.Kind, event.Wallet.URL())
+		}
+	default:
+		t.Fatal("missed wallet not reported")
+	}
+}
@@ -186,6 +186,28 @@ Note:
 As you can directly copy your encrypted accounts to another ethereum instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "tui",
+				Usage:  "Manage accounts interactively",
+				Action: utils.MigrateFlags(accountTUI),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.LightKDFFlag,
+					utils.IPCPathFlag,
+				},
+				Description: `
+    geth account tui
+
+Lists the accounts of the keystore along with their key files and, if a node
+is running on the same data directory, their balances. Accounts can be
+exported to new key files and deleted. Keystore files and unencrypted private
+keys in hexadecimal format can be imported. If a directory is given to import,
+a file can be picked from it.
+
+Passwords are always requested interactively.
 `,
 			},
 		},
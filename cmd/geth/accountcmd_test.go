@@ -18,12 +18,16 @@ package main
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/cespare/cp"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // These tests are 'smoke tests' for the account related
@@ -116,6 +120,75 @@ Repeat password: {{.InputLine "foobar2"}}
 `)
 }
 
+func TestAccountTUI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("key file paths differ on windows")
+	}
+	datadir := tmpDatadirWithKeystore(t)
+	defer os.RemoveAll(datadir)
+
+	// Create a directory with an unencrypted key to pick for import
+	key, _ := crypto.GenerateKey()
+	keydir := filepath.Join(datadir, "keys")
+	os.Mkdir(keydir, 0700)
+	if err := crypto.SaveECDSA(filepath.Join(keydir, "key.hex"), key); err != nil {
+		t.Fatal(err)
+	}
+	geth := runGeth(t, "account", "tui", "--datadir", datadir, "--lightkdf")
+	defer geth.ExpectExit()
+
+	geth.Expect(`
+#0 0x7EF5A6135f1FD6a02593eEdC869c6D41D934aef8 balance: n/a file: {{.Datadir}}/keystore/UTC--2016-03-22T12-57-55.920751759Z--7ef5a6135f1fd6a02593eedc869c6d41d934aef8
+#1 0xf466859eAD1932D743d622CB74FC058882E8648A balance: n/a file: {{.Datadir}}/keystore/aaa
+#2 0x289d485D9771714CCe91D3393D764E1311907ACc balance: n/a file: {{.Datadir}}/keystore/zzz
+Commands: import [file|dir], export <#> [file], delete <#>, refresh, quit
+> {{.InputLine "export 1"}}
+Export to file: {{.InputLine (printf "%s/exported.json" .Datadir)}}
+!! Unsupported terminal, password will be echoed.
+Password of the account: {{.InputLine "foobar"}}
+Password of the exported key: {{.InputLine "exported"}}
+Repeat password: {{.InputLine "exported"}}
+Exported account 0xf466859eAD1932D743d622CB74FC058882E8648A to {{.Datadir}}/exported.json
+#0 0x7EF5A6135f1FD6a02593eEdC869c6D41D934aef8 balance: n/a file: {{.Datadir}}/keystore/UTC--2016-03-22T12-57-55.920751759Z--7ef5a6135f1fd6a02593eedc869c6d41d934aef8
+#1 0xf466859eAD1932D743d622CB74FC058882E8648A balance: n/a file: {{.Datadir}}/keystore/aaa
+#2 0x289d485D9771714CCe91D3393D764E1311907ACc balance: n/a file: {{.Datadir}}/keystore/zzz
+Commands: import [file|dir], export <#> [file], delete <#>, refresh, quit
+> {{.InputLine "delete 2"}}
+Delete account 0x289d485D9771714CCe91D3393D764E1311907ACc? The key can't be recovered without a backup. [y/n] {{.InputLine "y"}}Password of the account: {{.InputLine "foobar"}}
+Deleted account 0x289d485D9771714CCe91D3393D764E1311907ACc
+#0 0x7EF5A6135f1FD6a02593eEdC869c6D41D934aef8 balance: n/a file: {{.Datadir}}/keystore/UTC--2016-03-22T12-57-55.920751759Z--7ef5a6135f1fd6a02593eedc869c6d41d934aef8
+#1 0xf466859eAD1932D743d622CB74FC058882E8648A balance: n/a file: {{.Datadir}}/keystore/aaa
+Commands: import [file|dir], export <#> [file], delete <#>, refresh, quit
+> {{.InputLine (printf "import %s/keys" .Datadir)}}
+  [0] key.hex
+Select file: {{.InputLine "0"}}
+Password of the imported account: {{.InputLine "imported"}}
+Repeat password: {{.InputLine "imported"}}
+`)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	geth.InputLine("quit")
+	geth.ExpectRegexp(`Imported account ` + address.Hex() + `
+(#\d 0x[0-9a-fA-F]{40} balance: n/a file: .+\n){3}Commands: .*\n> \n`)
+
+	// Check the exported, deleted and imported key files
+	keyjson, err := ioutil.ReadFile(filepath.Join(datadir, "exported.json"))
+	if err != nil {
+		t.Fatalf("failed to read exported key: %v", err)
+	}
+	if exported, err := keystore.DecryptKey(keyjson, "exported"); err != nil {
+		t.Errorf("failed to decrypt exported key: %v", err)
+	} else if exported.Address != common.HexToAddress("f466859ead1932d743d622cb74fc058882e8648a") {
+		t.Errorf("exported key address mismatch: %x", exported.Address)
+	}
+	if _, err := os.Stat(filepath.Join(datadir, "keystore", "zzz")); !os.IsNotExist(err) {
+		t.Errorf("deleted key file still exists: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(datadir, "keystore", "UTC--*--"+strings.ToLower(address.Hex()[2:])))
+	if len(files) != 1 {
+		t.Errorf("imported key file not found in keystore: %v", files)
+	}
+}
+
 func TestWalletImport(t *testing.T) {
 	geth := runGeth(t, "wallet", "import", "--lightkdf", "testdata/guswallet.json")
 	defer geth.ExpectExit()
@@ -0,0 +1,326 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/console"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/peterh/liner"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const accountTUIHelp = "Commands: import [file|dir], export <#> [file], delete <#>, refresh, quit"
+
+// accountTUI starts the interactive account manager on the keystore defined by
+// the CLI flags.
+func accountTUI(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	ui := &accountUI{ks: ks, prompter: console.Stdin, out: os.Stdout}
+
+	// Show balances if a node is running on the same data directory
+	if endpoint := stack.IPCEndpoint(); endpoint != "" {
+		if _, err := os.Stat(endpoint); err == nil {
+			if client, err := rpc.Dial(endpoint); err == nil {
+				defer client.Close()
+				ui.client = ethclient.NewClient(client)
+			}
+		}
+	}
+	if err := ui.run(); err != nil {
+		utils.Fatalf("%v", err)
+	}
+	return nil
+}
+
+// accountUI is a terminal user interface listing the accounts of a keystore and
+// allowing to import, export and delete keys.
+type accountUI struct {
+	ks       *keystore.KeyStore
+	prompter console.UserPrompter
+	out      io.Writer
+	client   *ethclient.Client // Client of a running node, nil if none is available
+}
+
+// run displays the accounts and processes commands until the user quits or the
+// input ends.
+func (ui *accountUI) run() error {
+	ui.render()
+	for {
+		input, err := ui.prompter.PromptInput("> ")
+		if err == io.EOF || err == liner.ErrPromptAborted {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "import", "i":
+			err = ui.importKey(fields[1:])
+		case "export", "e":
+			err = ui.exportKey(fields[1:])
+		case "delete", "d":
+			err = ui.deleteKey(fields[1:])
+		case "refresh", "r":
+		case "quit", "q":
+			return nil
+		default:
+			err = fmt.Errorf("unknown command %q", fields[0])
+		}
+		if err != nil {
+			fmt.Fprintf(ui.out, "Error: %v\n", err)
+		}
+		ui.render()
+	}
+}
+
+// render prints the account list along with the available commands.
+func (ui *accountUI) render() {
+	accs := ui.ks.Accounts()
+	if len(accs) == 0 {
+		fmt.Fprintln(ui.out, "No accounts in keystore.")
+	}
+	for i, acc := range accs {
+		fmt.Fprintf(ui.out, "#%d %s balance: %s file: %s\n", i, acc.Address.Hex(), ui.balance(acc), acc.URL.Path)
+	}
+	fmt.Fprintln(ui.out, accountTUIHelp)
+}
+
+// balance retrieves the balance of an account from the connected node.
+func (ui *accountUI) balance(acc accounts.Account) string {
+	if ui.client == nil {
+		return "n/a"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	wei, err := ui.client.BalanceAt(ctx, acc.Address, nil)
+	if err != nil {
+		return "n/a"
+	}
+	ether := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether))
+	return ether.Text('f', 6) + " ETH"
+}
+
+// importKey imports a keystore file or a file containing a hex encoded private
+// key. If a directory is given, the user picks a file from it.
+func (ui *accountUI) importKey(args []string) error {
+	path, err := ui.argOrPrompt(args, 0, "Key file or directory: ")
+	if err != nil {
+		return err
+	}
+	if path, err = ui.pickFile(path); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var acc accounts.Account
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		password, err := ui.password("Password of the key file: ", false)
+		if err != nil {
+			return err
+		}
+		newPassword, err := ui.password("Password of the imported account: ", true)
+		if err != nil {
+			return err
+		}
+		if acc, err = ui.ks.Import(data, password, newPassword); err != nil {
+			return err
+		}
+	} else {
+		key, err := crypto.LoadECDSA(path)
+		if err != nil {
+			return err
+		}
+		password, err := ui.password("Password of the imported account: ", true)
+		if err != nil {
+			return err
+		}
+		if acc, err = ui.ks.ImportECDSA(key, password); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(ui.out, "Imported account %s\n", acc.Address.Hex())
+	return nil
+}
+
+// exportKey writes the key of an account to a new keystore file, encrypted with
+// a new password.
+func (ui *accountUI) exportKey(args []string) error {
+	acc, err := ui.selectAccount(args)
+	if err != nil {
+		return err
+	}
+	path, err := ui.argOrPrompt(args, 1, "Export to file: ")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("file %s already exists", path)
+	}
+	password, err := ui.password("Password of the account: ", false)
+	if err != nil {
+		return err
+	}
+	newPassword, err := ui.password("Password of the exported key: ", true)
+	if err != nil {
+		return err
+	}
+	keyjson, err := ui.ks.Export(acc, password, newPassword)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, keyjson, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(ui.out, "Exported account %s to %s\n", acc.Address.Hex(), path)
+	return nil
+}
+
+// deleteKey removes the key file of an account after confirmation.
+func (ui *accountUI) deleteKey(args []string) error {
+	acc, err := ui.selectAccount(args)
+	if err != nil {
+		return err
+	}
+	ok, err := ui.prompter.PromptConfirm(fmt.Sprintf("Delete account %s? The key can't be recovered without a backup.", acc.Address.Hex()))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	password, err := ui.password("Password of the account: ", false)
+	if err != nil {
+		return err
+	}
+	if err := ui.ks.Delete(acc, password); err != nil {
+		return err
+	}
+	fmt.Fprintf(ui.out, "Deleted account %s\n", acc.Address.Hex())
+	return nil
+}
+
+// selectAccount returns the account with the index given as first argument.
+func (ui *accountUI) selectAccount(args []string) (accounts.Account, error) {
+	input, err := ui.argOrPrompt(args, 0, "Account #: ")
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	accs := ui.ks.Accounts()
+	index, err := strconv.Atoi(strings.TrimPrefix(input, "#"))
+	if err != nil || index < 0 || index >= len(accs) {
+		return accounts.Account{}, fmt.Errorf("invalid account %q", input)
+	}
+	return accs[index], nil
+}
+
+// pickFile lists the files of a directory and lets the user choose one. Paths
+// of regular files are returned as is.
+func (ui *accountUI) pickFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			files = append(files, entry.Name())
+		}
+	}
+	if len(files) == 0 {
+		return "", errors.New("no files in directory")
+	}
+	for i, file := range files {
+		fmt.Fprintf(ui.out, "  [%d] %s\n", i, file)
+	}
+	input, err := ui.prompter.PromptInput("Select file: ")
+	if err != nil {
+		return "", err
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || index < 0 || index >= len(files) {
+		return "", fmt.Errorf("invalid selection %q", input)
+	}
+	return filepath.Join(path, files[index]), nil
+}
+
+// argOrPrompt returns the command argument at the given index, prompting the
+// user for it if it wasn't given.
+func (ui *accountUI) argOrPrompt(args []string, index int, prompt string) (string, error) {
+	if index < len(args) {
+		return args[index], nil
+	}
+	input, err := ui.prompter.PromptInput(prompt)
+	if err != nil {
+		return "", err
+	}
+	if input = strings.TrimSpace(input); input == "" {
+		return "", errors.New("no input given")
+	}
+	return input, nil
+}
+
+// password requests a password from the user, optionally asking for it twice.
+func (ui *accountUI) password(prompt string, confirmation bool) (string, error) {
+	password, err := ui.prompter.PromptPassword(prompt)
+	if err != nil {
+		return "", err
+	}
+	if confirmation {
+		confirm, err := ui.prompter.PromptPassword("Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if password != confirm {
+			return "", errors.New("passwords do not match")
+		}
+	}
+	return password, nil
+}
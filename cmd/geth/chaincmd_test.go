@@ -0,0 +1,89 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that chain validation stops at a block with a corrupt parent hash and
+// that the block range can be limited.
+func TestChainValidate(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	// Initialize the data directory with a custom genesis
+	gspec := &core.Genesis{
+		Config:     params.TestChainConfig,
+		Difficulty: big.NewInt(0x20000),
+		GasLimit:   0x2fefd8,
+		Alloc:      core.GenesisAlloc{},
+	}
+	blob, err := json.Marshal(gspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisFile := filepath.Join(datadir, "genesis.json")
+	if err := ioutil.WriteFile(genesisFile, blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	runGeth(t, "--datadir", datadir, "init", genesisFile).WaitExit()
+
+	// Export a chain with a corrupt parent hash at block 500
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 600, nil)
+
+	header := blocks[499].Header()
+	header.ParentHash[0] ^= 0xff
+	blocks[499] = types.NewBlockWithHeader(header)
+
+	chainFile := filepath.Join(datadir, "chain.rlp")
+	out, err := os.Create(chainFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range append([]*types.Block{genesis}, blocks...) {
+		if err := rlp.Encode(out, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Close()
+
+	// Validate the entire file, the corrupt block must be reported
+	geth := runGeth(t, "--datadir", datadir, "--fakepow", "chain", "validate", chainFile)
+	geth.ExpectRegexp(fmt.Sprintf(`Fatal: Validation failed after 499 valid blocks: invalid block 500 \(hash %x\): parent hash mismatch: .*\n`, blocks[499].Hash()))
+	geth.ExpectExit()
+
+	// Validate the blocks in front of the corrupt one
+	geth = runGeth(t, "--datadir", datadir, "--fakepow", "chain", "validate", "--from", "100", "--to", "499", chainFile)
+	geth.ExpectRegexp(`Validated 400 blocks in .*\n`)
+	geth.ExpectExit()
+}
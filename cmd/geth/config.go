@@ -23,12 +23,17 @@ import (
 	"math/big"
 	"os"
 	"reflect"
+	"sync"
 	"unicode"
 
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
@@ -78,6 +83,7 @@ type gethConfig struct {
 	Shh      whisper.Config
 	Node     node.Config
 	Ethstats ethstatsConfig
+	Metrics  metrics.Config
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -92,7 +98,24 @@ func loadConfig(file string, cfg *gethConfig) error {
 	if _, ok := err.(*toml.LineError); ok {
 		err = errors.New(file + ", " + err.Error())
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("invalid config file %s:\n%v", file, err)
+	}
+	return nil
+}
+
+// validate checks the loaded configuration for values out of their valid range.
+func (cfg *gethConfig) validate() error {
+	if err := cfg.Node.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Metrics.Validate(); err != nil {
+		return err
+	}
+	return cfg.Eth.Validate()
 }
 
 func defaultNodeConfig() node.Config {
@@ -108,9 +131,10 @@ func defaultNodeConfig() node.Config {
 func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	// Load defaults.
 	cfg := gethConfig{
-		Eth:  eth.DefaultConfig,
-		Shh:  whisper.DefaultConfig,
-		Node: defaultNodeConfig(),
+		Eth:     eth.DefaultConfig,
+		Shh:     whisper.DefaultConfig,
+		Node:    defaultNodeConfig(),
+		Metrics: metrics.DefaultConfig,
 	}
 
 	// Load config file.
@@ -122,6 +146,13 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 
 	// Apply flags.
 	utils.SetNodeConfig(ctx, &cfg.Node)
+	if cfg.Node.LogLevel != "" {
+		lvl, err := log.LvlFromString(cfg.Node.LogLevel)
+		if err != nil {
+			utils.Fatalf("Invalid log level: %v", err)
+		}
+		debug.Handler.Verbosity(int(lvl))
+	}
 	stack, err := node.New(&cfg.Node)
 	if err != nil {
 		utils.Fatalf("Failed to create the protocol stack: %v", err)
@@ -131,6 +162,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		cfg.Ethstats.URL = ctx.GlobalString(utils.EthStatsURLFlag.Name)
 	}
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
+	utils.SetMetricsConfig(ctx, &cfg.Metrics)
 
 	return stack, cfg
 }
@@ -145,7 +177,9 @@ func enableWhisper(ctx *cli.Context) bool {
 	return false
 }
 
-func makeFullNode(ctx *cli.Context) *node.Node {
+// makeFullNode creates the node with all the services requested on the command
+// line. The returned config is the one the services were created with.
+func makeFullNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	stack, cfg := makeConfigNode(ctx)
 	if ctx.GlobalIsSet(utils.OverrideIstanbulFlag.Name) {
 		cfg.Eth.OverrideIstanbul = new(big.Int).SetUint64(ctx.GlobalUint64(utils.OverrideIstanbulFlag.Name))
@@ -154,6 +188,7 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		cfg.Eth.OverrideMuirGlacier = new(big.Int).SetUint64(ctx.GlobalUint64(utils.OverrideMuirGlacierFlag.Name))
 	}
 	utils.RegisterEthService(stack, &cfg.Eth)
+	stack.SetConfigLoader(configReloader(stack, cfg))
 
 	// Whisper must be explicitly enabled by specifying at least 1 whisper flag or in dev mode
 	shhEnabled := enableWhisper(ctx)
@@ -178,7 +213,67 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
-	return stack
+	// Add the services of any plugins.
+	utils.RegisterPlugins(ctx, stack)
+	return stack, cfg
+}
+
+// configReloader returns the loader used by admin_reloadConfig. Config files are
+// read on top of the startup configuration, so settings given as flags are kept
+// unless the file overrides them. Only the reloadable fields of the node and eth
+// sections may change, changes to any other setting are rejected.
+func configReloader(stack *node.Node, cfg gethConfig) node.ConfigLoader {
+	var lock sync.Mutex // Protects cfg from concurrent reloads
+
+	return func(file string) (*node.Config, func(), error) {
+		lock.Lock()
+		oldcfg := cfg
+		lock.Unlock()
+
+		newcfg := oldcfg
+		if oldcfg.Eth.Miner.GasPrice != nil {
+			// Don't let the decoder write through to the running config.
+			newcfg.Eth.Miner.GasPrice = new(big.Int).Set(oldcfg.Eth.Miner.GasPrice)
+		}
+		if err := loadConfig(file, &newcfg); err != nil {
+			return nil, nil, err
+		}
+		// The node section is checked by the node itself, diff all the others
+		var changed []string
+		for _, section := range []struct {
+			name     string
+			old, new interface{}
+		}{
+			{"Eth", &oldcfg.Eth, &newcfg.Eth},
+			{"Shh", &oldcfg.Shh, &newcfg.Shh},
+			{"Ethstats", &oldcfg.Ethstats, &newcfg.Ethstats},
+			{"Metrics", &oldcfg.Metrics, &newcfg.Metrics},
+		} {
+			fields, err := node.ConfigDiff(section.name, section.old, section.new)
+			if err != nil {
+				return nil, nil, err
+			}
+			changed = append(changed, fields...)
+		}
+		apply := func() {
+			lock.Lock()
+			defer lock.Unlock()
+
+			for _, field := range changed {
+				switch field {
+				case "Eth.Miner.GasPrice":
+					var ethereum *eth.Ethereum
+					if err := stack.Service(&ethereum); err != nil {
+						continue
+					}
+					eth.NewPrivateMinerAPI(ethereum).SetGasPrice(hexutil.Big(*newcfg.Eth.Miner.GasPrice))
+					log.Info("Reloaded configuration value", "field", field)
+				}
+			}
+			cfg.Eth = newcfg.Eth
+		}
+		return &newcfg.Node, apply, nil
+	}
 }
 
 // dumpConfig is the dumpconfig command.
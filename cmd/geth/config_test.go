@@ -0,0 +1,112 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/node"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
+)
+
+// Tests that reloading a config file with a changed miner gas price applies it
+// to the running miner, while changes to other sections are rejected.
+func TestConfigReloadGasPrice(t *testing.T) {
+	ws := tmpdir(t)
+	defer os.RemoveAll(ws)
+
+	cfg := gethConfig{
+		Eth:     eth.DefaultConfig,
+		Shh:     whisper.DefaultConfig,
+		Node:    node.Config{P2P: node.DefaultConfig.P2P},
+		Metrics: metrics.DefaultConfig,
+	}
+	cfg.Node.P2P.ListenAddr, cfg.Node.P2P.NoDiscovery, cfg.Node.P2P.MaxPeers = "", true, 0
+	cfg.Eth.Genesis = core.DeveloperGenesisBlock(0, [20]byte{})
+	cfg.Eth.Ethash.PowMode = ethash.ModeFake
+	cfg.Eth.Miner.GasPrice = big.NewInt(1)
+
+	stack, err := node.New(&cfg.Node)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return eth.New(ctx, &cfg.Eth)
+	}); err != nil {
+		t.Fatalf("failed to register eth service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Close()
+
+	var ethereum *eth.Ethereum
+	if err := stack.Service(&ethereum); err != nil {
+		t.Fatalf("failed to retrieve eth service: %v", err)
+	}
+	// Reload config files as written by dumpconfig, with the given modification
+	reload := func(modify func(*gethConfig)) error {
+		newcfg := cfg
+		newcfg.Eth.Miner.GasPrice = new(big.Int).Set(cfg.Eth.Miner.GasPrice)
+		modify(&newcfg)
+
+		// Like dumpconfig, leave out the genesis, which keeps the running one
+		newcfg.Eth.Genesis = nil
+		config, err := tomlSettings.Marshal(&newcfg)
+		if err != nil {
+			t.Fatalf("failed to encode config: %v", err)
+		}
+		file := filepath.Join(ws, "reload.toml")
+		if err := ioutil.WriteFile(file, config, 0600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+		nodecfg, apply, err := configReloader(stack, cfg)(file)
+		if err != nil {
+			return err
+		}
+		if err := stack.ReloadConfig(nodecfg); err != nil {
+			return err
+		}
+		apply()
+		return nil
+	}
+	if err := reload(func(c *gethConfig) { c.Eth.Miner.GasPrice = big.NewInt(2000000000) }); err != nil {
+		t.Fatalf("failed to reload gas price: %v", err)
+	}
+	if price := ethereum.TxPool().GasPrice(); price.Cmp(big.NewInt(2000000000)) != 0 {
+		t.Errorf("gas price mismatch: have %v, want %v", price, 2000000000)
+	}
+	// Settings of other sections can't be changed at runtime
+	for section, modify := range map[string]func(*gethConfig){
+		"Metrics":  func(c *gethConfig) { c.Metrics.Enabled = true },
+		"Ethstats": func(c *gethConfig) { c.Ethstats.URL = "node:secret@localhost:3000" },
+		"Shh":      func(c *gethConfig) { c.Shh.MaxMessageSize = 1 },
+	} {
+		err := reload(modify)
+		if rerr, ok := err.(*node.ConfigReloadError); !ok || rerr.Err != node.ErrNotHotReloadable {
+			t.Errorf("%s section: error mismatch: have %v, want not hot reloadable", section, err)
+		}
+	}
+}
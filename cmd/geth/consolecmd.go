@@ -17,10 +17,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -31,6 +34,9 @@ import (
 	"gopkg.in/urfave/cli.v1"
 )
 
+// defaultAuthRPCPort is the port of authrpc:// endpoints which don't specify one.
+const defaultAuthRPCPort = 8551
+
 var (
 	consoleFlags = []cli.Flag{utils.JSpathFlag, utils.ExecFlag, utils.PreloadJSFlag}
 
@@ -51,13 +57,17 @@ See https://github.com/ethereum/go-ethereum/wiki/JavaScript-Console.`,
 		Name:      "attach",
 		Usage:     "Start an interactive JavaScript environment (connect to node)",
 		ArgsUsage: "[endpoint]",
-		Flags:     append(consoleFlags, utils.DataDirFlag),
+		Flags:     append(consoleFlags, utils.DataDirFlag, utils.JWTSecretFlag),
 		Category:  "CONSOLE COMMANDS",
 		Description: `
 The Geth console is an interactive shell for the JavaScript runtime environment
 which exposes a node admin interface as well as the Ðapp JavaScript API.
 See https://github.com/ethereum/go-ethereum/wiki/JavaScript-Console.
-This command allows to open a console on a running geth node.`,
+This command allows to open a console on a running geth node.
+
+Endpoints requiring JWT authentication, like the Engine API, can be attached to
+by passing the secret using --authrpc.jwtsecret. The endpoint authrpc://host:port
+is a shorthand for the websocket endpoint ws://host:port, the port defaults to 8551.`,
 	}
 
 	javascriptCommand = cli.Command{
@@ -78,7 +88,8 @@ JavaScript API. See https://github.com/ethereum/go-ethereum/wiki/JavaScript-Cons
 func localConsole(ctx *cli.Context) error {
 	// Create and start the node based on the CLI flags
 	prepare(ctx)
-	node := makeFullNode(ctx)
+	node, cfg := makeFullNode(ctx)
+	utils.SetupMetrics(&cfg.Metrics, strconv.FormatUint(cfg.Eth.NetworkId, 10))
 	startNode(ctx, node)
 	defer node.Close()
 
@@ -116,22 +127,7 @@ func localConsole(ctx *cli.Context) error {
 // console to it.
 func remoteConsole(ctx *cli.Context) error {
 	// Attach to a remotely running geth instance and start the JavaScript console
-	endpoint := ctx.Args().First()
-	if endpoint == "" {
-		path := node.DefaultDataDir()
-		if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
-			path = ctx.GlobalString(utils.DataDirFlag.Name)
-		}
-		if path != "" {
-			if ctx.GlobalBool(utils.TestnetFlag.Name) {
-				path = filepath.Join(path, "testnet")
-			} else if ctx.GlobalBool(utils.RinkebyFlag.Name) {
-				path = filepath.Join(path, "rinkeby")
-			}
-		}
-		endpoint = fmt.Sprintf("%s/geth.ipc", path)
-	}
-	client, err := dialRPC(endpoint)
+	client, err := dialRPC(remoteEndpoint(ctx), ctx.String(utils.JWTSecretFlag.Name))
 	if err != nil {
 		utils.Fatalf("Unable to attach to remote geth: %v", err)
 	}
@@ -160,18 +156,69 @@ func remoteConsole(ctx *cli.Context) error {
 	return nil
 }
 
+// remoteEndpoint returns the endpoint given as the first command argument, or the
+// IPC endpoint inside the configured data directory if none was given.
+func remoteEndpoint(ctx *cli.Context) string {
+	endpoint := ctx.Args().First()
+	if endpoint == "" {
+		path := node.DefaultDataDir()
+		if ctx.GlobalIsSet(utils.DataDirFlag.Name) {
+			path = ctx.GlobalString(utils.DataDirFlag.Name)
+		}
+		if path != "" {
+			if ctx.GlobalBool(utils.TestnetFlag.Name) {
+				path = filepath.Join(path, "testnet")
+			} else if ctx.GlobalBool(utils.RinkebyFlag.Name) {
+				path = filepath.Join(path, "rinkeby")
+			}
+		}
+		endpoint = fmt.Sprintf("%s/geth.ipc", path)
+	}
+	return endpoint
+}
+
 // dialRPC returns a RPC client which connects to the given endpoint.
 // The check for empty endpoint implements the defaulting logic
 // for "geth attach" and "geth monitor" with no argument.
-func dialRPC(endpoint string) (*rpc.Client, error) {
+func dialRPC(endpoint string, jwtsecret string) (*rpc.Client, error) {
+	authrpc := strings.HasPrefix(endpoint, "authrpc://")
 	if endpoint == "" {
 		endpoint = node.DefaultIPCEndpoint(clientIdentifier)
+	} else if authrpc {
+		endpoint = authRPCEndpoint(endpoint)
 	} else if strings.HasPrefix(endpoint, "rpc:") || strings.HasPrefix(endpoint, "ipc:") {
 		// Backwards compatibility with geth < 1.5 which required
 		// these prefixes.
 		endpoint = endpoint[4:]
 	}
-	return rpc.Dial(endpoint)
+	if jwtsecret == "" {
+		if authrpc {
+			return nil, fmt.Errorf("%s requires --%s", endpoint, utils.JWTSecretFlag.Name)
+		}
+		return rpc.Dial(endpoint)
+	}
+	// JWT authentication was requested, which is only supported on websockets.
+	if !strings.HasPrefix(endpoint, "ws://") && !strings.HasPrefix(endpoint, "wss://") {
+		return nil, fmt.Errorf("JWT authentication requires a websocket endpoint, got %s", endpoint)
+	}
+	secret, err := node.ReadJWTSecret(jwtsecret)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.DialWebsocketWithAuth(context.Background(), endpoint, "", node.NewJWTAuth(secret))
+}
+
+// authRPCEndpoint converts an authrpc://host[:port] endpoint into the websocket
+// endpoint of the Engine API.
+func authRPCEndpoint(endpoint string) string {
+	host, path := strings.TrimPrefix(endpoint, "authrpc://"), ""
+	if i := strings.IndexByte(host, '/'); i >= 0 {
+		host, path = host[:i], host[i:]
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, strconv.Itoa(defaultAuthRPCPort))
+	}
+	return "ws://" + host + path
 }
 
 // ephemeralConsole starts a new geth node, attaches an ephemeral JavaScript
@@ -179,7 +226,7 @@ func dialRPC(endpoint string) (*rpc.Client, error) {
 // everything down.
 func ephemeralConsole(ctx *cli.Context) error {
 	// Create and start the node based on the CLI flags
-	node := makeFullNode(ctx)
+	node, _ := makeFullNode(ctx)
 	startNode(ctx, node)
 	defer node.Close()
 
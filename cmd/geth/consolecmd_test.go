@@ -17,8 +17,15 @@
 package main
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -27,7 +34,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 const (
@@ -125,6 +134,87 @@ func TestWSAttachWelcome(t *testing.T) {
 	geth.ExpectExit()
 }
 
+// jwtCheckHandler rejects requests without a valid JWT bearer token, like the
+// authenticated endpoints of the Engine API.
+func jwtCheckHandler(secret []byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			parts = strings.Split(token, ".")
+		)
+		if len(parts) != 3 {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(parts[0] + "." + parts[1]))
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		var claims struct {
+			IssuedAt int64 `json:"iat"`
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if err := json.Unmarshal(payload, &claims); err != nil || time.Since(time.Unix(claims.IssuedAt, 0)) > time.Minute {
+			http.Error(w, "stale token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Tests that attaching to a JWT authenticated endpoint only succeeds with the
+// right secret.
+func TestAttachJWT(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-jwt-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	secretFile := filepath.Join(dir, "jwtsecret")
+	if err := ioutil.WriteFile(secretFile, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wrongFile := filepath.Join(dir, "wrongsecret")
+	if err := ioutil.WriteFile(wrongFile, []byte(hexutil.Encode(make([]byte, 32))), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := rpc.NewServer()
+	defer srv.Stop()
+	httpsrv := httptest.NewServer(jwtCheckHandler(secret, srv.WebsocketHandler([]string{"*"})))
+	defer httpsrv.Close()
+
+	var (
+		host     = strings.TrimPrefix(httpsrv.URL, "http://")
+		endpoint = "authrpc://" + host
+	)
+	if _, err := dialRPC(endpoint, ""); err == nil {
+		t.Error("authrpc endpoint attached without secret")
+	}
+	if _, err := dialRPC("ws://"+host, ""); err == nil {
+		t.Error("unauthenticated attach not rejected")
+	}
+	if _, err := dialRPC(endpoint, wrongFile); err == nil {
+		t.Error("attach with wrong secret not rejected")
+	}
+	client, err := dialRPC(endpoint, secretFile)
+	if err != nil {
+		t.Fatalf("attach with secret failed: %v", err)
+	}
+	defer client.Close()
+
+	var modules map[string]string
+	if err := client.Call(&modules, "rpc_modules"); err != nil {
+		t.Fatalf("authenticated call failed: %v", err)
+	}
+}
+
 func testAttachWelcome(t *testing.T, geth *testgeth, endpoint, apis string) {
 	// Attach to a running geth note and terminate immediately
 	attach := runGeth(t, "attach", endpoint)
@@ -0,0 +1,370 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbPrefixFlag = cli.StringFlag{
+		Name:  "prefix",
+		Usage: "Hex encoded prefix of the keys to list",
+	}
+	dbKeyFlag = cli.StringFlag{
+		Name:  "key",
+		Usage: "Hex encoded key of the entry to print",
+	}
+	dbDecodeAsFlag = cli.StringFlag{
+		Name:  "decode-as",
+		Usage: "Type to decode the value of --key as (" + strings.Join(dbDecoderNames(), ", ") + ")",
+	}
+	dbLimitFlag = cli.IntFlag{
+		Name:  "limit",
+		Usage: "Maximum number of keys to list (0 = no limit)",
+		Value: 100,
+	}
+	dbWriteKeyFlag = cli.StringFlag{
+		Name:  "write-key",
+		Usage: "Hex encoded key of the entry to overwrite (dangerous)",
+	}
+	dbValueFlag = cli.StringFlag{
+		Name:  "value",
+		Usage: "Hex encoded value to write with --write-key",
+	}
+	dbForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Confirm writing to the database with --write-key",
+	}
+
+	dbCommand = cli.Command{
+		Name:      "db",
+		Usage:     "Low level database operations",
+		ArgsUsage: "",
+		Category:  "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(dbInspect),
+				Name:      "inspect",
+				Usage:     "Inspect or repair raw entries of the chain database",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.DBEngineFlag,
+					utils.CacheFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+					utils.SyncModeFlag,
+					dbPrefixFlag,
+					dbKeyFlag,
+					dbDecodeAsFlag,
+					dbLimitFlag,
+					dbWriteKeyFlag,
+					dbValueFlag,
+					dbForceFlag,
+				},
+				Description: `
+    geth db inspect --key <hex> [--decode-as <type>]
+
+Prints the raw value stored under the key, along with its RLP structure if the
+value is RLP encoded. With --decode-as, the value is decoded into the named type
+and printed as JSON.
+
+    geth db inspect [--prefix <hex>] [--limit <n>]
+
+Lists the keys with the given prefix and their value sizes, followed by a
+summary of all matching entries.
+
+    geth db inspect --write-key <hex> --value <hex> --force
+
+Overwrites a single entry for emergency repairs, printing the previous value.
+Make a backup of the database first, this can't be undone.`,
+			},
+		},
+	}
+)
+
+// dbDecoders maps the type names accepted by --decode-as to functions decoding
+// a database value into a JSON printable representation.
+var dbDecoders = map[string]func(blob []byte) (interface{}, error){
+	"types.Header": func(blob []byte) (interface{}, error) {
+		header := new(types.Header)
+		return header, rlp.DecodeBytes(blob, header)
+	},
+	"types.Body": func(blob []byte) (interface{}, error) {
+		body := new(types.Body)
+		return body, rlp.DecodeBytes(blob, body)
+	},
+	"types.Transaction": func(blob []byte) (interface{}, error) {
+		tx := new(types.Transaction)
+		return tx, rlp.DecodeBytes(blob, tx)
+	},
+	"types.Receipt": func(blob []byte) (interface{}, error) {
+		receipt := new(types.Receipt)
+		return receipt, rlp.DecodeBytes(blob, receipt)
+	},
+	"types.ReceiptForStorage": func(blob []byte) (interface{}, error) {
+		receipt := new(types.ReceiptForStorage)
+		return (*types.Receipt)(receipt), rlp.DecodeBytes(blob, receipt)
+	},
+	"types.Receipts": func(blob []byte) (interface{}, error) {
+		// Receipts are stored as a list of their storage encoding
+		var stored []*types.ReceiptForStorage
+		if err := rlp.DecodeBytes(blob, &stored); err != nil {
+			return nil, err
+		}
+		receipts := make(types.Receipts, len(stored))
+		for i, receipt := range stored {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		return receipts, nil
+	},
+	"state.Account": func(blob []byte) (interface{}, error) {
+		account := new(state.Account)
+		return account, rlp.DecodeBytes(blob, account)
+	},
+	"big.Int": func(blob []byte) (interface{}, error) {
+		number := new(big.Int)
+		return number, rlp.DecodeBytes(blob, number)
+	},
+}
+
+// dbDecoderNames returns the sorted names of the types supported by --decode-as.
+func dbDecoderNames() []string {
+	names := make([]string, 0, len(dbDecoders))
+	for name := range dbDecoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeDBValue decodes a database value into the named type.
+func decodeDBValue(typename string, blob []byte) (interface{}, error) {
+	decode, ok := dbDecoders[typename]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q, supported: %s", typename, strings.Join(dbDecoderNames(), ", "))
+	}
+	value, err := decode(blob)
+	if err != nil {
+		return nil, fmt.Errorf("value is not a %s: %v", typename, err)
+	}
+	return value, nil
+}
+
+// parseHexFlag decodes a hex encoded flag value, the 0x prefix is optional.
+func parseHexFlag(ctx *cli.Context, flag cli.StringFlag) ([]byte, error) {
+	blob, err := hex.DecodeString(strings.TrimPrefix(ctx.String(flag.Name), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %v", flag.Name, err)
+	}
+	return blob, nil
+}
+
+var errNotRLP = errors.New("trailing data after RLP value")
+
+// dumpRLP prints the structure of an RLP encoded value, one string per line and
+// lists indented by their depth.
+func dumpRLP(w io.Writer, blob []byte, depth int) error {
+	kind, content, rest, err := rlp.Split(blob)
+	if err != nil {
+		return err
+	}
+	if depth == 0 && len(rest) > 0 {
+		return errNotRLP
+	}
+	indent := strings.Repeat("  ", depth)
+	if kind != rlp.List {
+		fmt.Fprintf(w, "%s0x%x\n", indent, content)
+		return nil
+	}
+	fmt.Fprintf(w, "%s[\n", indent)
+	for len(content) > 0 {
+		_, _, rest, err := rlp.Split(content)
+		if err != nil {
+			return err
+		}
+		if err := dumpRLP(w, content[:len(content)-len(rest)], depth+1); err != nil {
+			return err
+		}
+		content = rest
+	}
+	fmt.Fprintf(w, "%s]\n", indent)
+	return nil
+}
+
+func dbInspect(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		utils.Fatalf("This command doesn't accept arguments.")
+	}
+	var modes int
+	for _, flag := range []string{dbKeyFlag.Name, dbPrefixFlag.Name, dbWriteKeyFlag.Name} {
+		if ctx.IsSet(flag) {
+			modes++
+		}
+	}
+	if modes > 1 {
+		utils.Fatalf("Only one of --%s, --%s and --%s may be given.", dbKeyFlag.Name, dbPrefixFlag.Name, dbWriteKeyFlag.Name)
+	}
+	if ctx.IsSet(dbDecodeAsFlag.Name) && !ctx.IsSet(dbKeyFlag.Name) {
+		utils.Fatalf("--%s requires --%s.", dbDecodeAsFlag.Name, dbKeyFlag.Name)
+	}
+	if ctx.IsSet(dbWriteKeyFlag.Name) && !ctx.Bool(dbForceFlag.Name) {
+		utils.Fatalf("Refusing to modify the database without --%s, make a backup first.", dbForceFlag.Name)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	var err error
+	switch {
+	case ctx.IsSet(dbKeyFlag.Name):
+		err = dbPrintKey(ctx, db)
+	case ctx.IsSet(dbWriteKeyFlag.Name):
+		err = dbWriteKey(ctx, db)
+	default:
+		err = dbListPrefix(ctx, db)
+	}
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	return nil
+}
+
+// dbPrintKey prints the raw value stored under --key, decoded as --decode-as if
+// requested, or as a generic RLP structure otherwise.
+func dbPrintKey(ctx *cli.Context, db ethdb.KeyValueReader) error {
+	key, err := parseHexFlag(ctx, dbKeyFlag)
+	if err != nil {
+		return err
+	}
+	blob, err := db.Get(key)
+	if err != nil {
+		return fmt.Errorf("key 0x%x not found: %v", key, err)
+	}
+	fmt.Printf("Key:   0x%x\n", key)
+	fmt.Printf("Size:  %d bytes\n", len(blob))
+	fmt.Printf("Value: 0x%x\n", blob)
+
+	if typename := ctx.String(dbDecodeAsFlag.Name); typename != "" {
+		value, err := decodeDBValue(typename, blob)
+		if err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Decoded as %s:\n%s\n", typename, out)
+		return nil
+	}
+	var dump bytes.Buffer
+	if err := dumpRLP(&dump, blob, 0); err != nil {
+		fmt.Println("Value is not RLP encoded")
+		return nil
+	}
+	fmt.Printf("RLP:\n%s", dump.String())
+	return nil
+}
+
+// dbListPrefix prints the keys starting with --prefix and the size of their
+// values, followed by a summary of all matching entries.
+func dbListPrefix(ctx *cli.Context, db ethdb.Iteratee) error {
+	prefix, err := parseHexFlag(ctx, dbPrefixFlag)
+	if err != nil {
+		return err
+	}
+	limit := ctx.Int(dbLimitFlag.Name)
+
+	it := db.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	var (
+		count int
+		size  common.StorageSize
+		w     = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	)
+	fmt.Fprintln(w, "KEY\tSIZE")
+	for it.Next() {
+		if limit == 0 || count < limit {
+			fmt.Fprintf(w, "0x%x\t%d\n", it.Key(), len(it.Value()))
+		}
+		count++
+		size += common.StorageSize(len(it.Key()) + len(it.Value()))
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if limit != 0 && count > limit {
+		fmt.Printf("... %d more keys omitted\n", count-limit)
+	}
+	fmt.Printf("Found %d entries with prefix 0x%x, %v in total\n", count, prefix, size)
+	return nil
+}
+
+// dbWriteKey overwrites the entry stored under --write-key with --value.
+func dbWriteKey(ctx *cli.Context, db ethdb.KeyValueStore) error {
+	key, err := parseHexFlag(ctx, dbWriteKeyFlag)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return errors.New("refusing to write an empty key")
+	}
+	if !ctx.IsSet(dbValueFlag.Name) {
+		return fmt.Errorf("--%s requires --%s", dbWriteKeyFlag.Name, dbValueFlag.Name)
+	}
+	value, err := parseHexFlag(ctx, dbValueFlag)
+	if err != nil {
+		return err
+	}
+	if prev, err := db.Get(key); err == nil {
+		fmt.Printf("Previous value: 0x%x\n", prev)
+	} else {
+		fmt.Println("Previous value: none")
+	}
+	if err := db.Put(key, value); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d bytes to key 0x%x\n", len(value), key)
+	return nil
+}
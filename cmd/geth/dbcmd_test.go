@@ -0,0 +1,175 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// blockKey builds a key of the rawdb schema: prefix + num (uint64 big endian) +
+// hash + suffix.
+func blockKey(prefix string, number uint64, hash common.Hash, suffix string) []byte {
+	key := append([]byte(prefix), make([]byte, 8)...)
+	binary.BigEndian.PutUint64(key[len(prefix):], number)
+	return append(append(key, hash.Bytes()...), suffix...)
+}
+
+// Tests that the entries written by rawdb can be decoded as their types.
+func TestDBDecodeRawdbEntries(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	tx := types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	header := &types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(131072), Extra: []byte("test")}
+	block := types.NewBlockWithHeader(header).WithBody([]*types.Transaction{tx}, nil)
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, Logs: []*types.Log{}}
+
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteReceipts(db, block.Hash(), 10, types.Receipts{receipt})
+	rawdb.WriteTd(db, block.Hash(), 10, big.NewInt(424242))
+
+	decode := func(typename string, key []byte) interface{} {
+		t.Helper()
+
+		blob, err := db.Get(key)
+		if err != nil {
+			t.Fatalf("%s: entry 0x%x missing: %v", typename, key, err)
+		}
+		value, err := decodeDBValue(typename, blob)
+		if err != nil {
+			t.Fatalf("%s: %v", typename, err)
+		}
+		if _, err := json.Marshal(value); err != nil {
+			t.Fatalf("%s: failed to print: %v", typename, err)
+		}
+		return value
+	}
+	if have := decode("types.Header", blockKey("h", 10, block.Hash(), "")).(*types.Header); have.Hash() != block.Hash() {
+		t.Errorf("header hash mismatch: have %x, want %x", have.Hash(), block.Hash())
+	}
+	if have := decode("types.Body", blockKey("b", 10, block.Hash(), "")).(*types.Body); len(have.Transactions) != 1 || have.Transactions[0].Hash() != tx.Hash() {
+		t.Errorf("body transactions mismatch: have %v", have.Transactions)
+	}
+	if have := decode("types.Receipts", blockKey("r", 10, block.Hash(), "")).(types.Receipts); len(have) != 1 || have[0].CumulativeGasUsed != 21000 || have[0].Status != types.ReceiptStatusSuccessful {
+		t.Errorf("receipts mismatch: have %v", have)
+	}
+	if have := decode("big.Int", blockKey("h", 10, block.Hash(), "t")).(*big.Int); have.Int64() != 424242 {
+		t.Errorf("total difficulty mismatch: have %v, want 424242", have)
+	}
+	// Values of the wrong type and unknown types must be rejected
+	blob, _ := db.Get(blockKey("h", 10, block.Hash(), ""))
+	if _, err := decodeDBValue("types.Receipts", blob); err == nil {
+		t.Error("header decoded as receipts")
+	}
+	if _, err := decodeDBValue("types.Unknown", blob); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
+
+func TestDumpRLP(t *testing.T) {
+	tests := []struct {
+		blob []byte
+		want string
+		fail bool
+	}{
+		{blob: common.FromHex("0x83646f67"), want: "0x646f67\n"},
+		{blob: common.FromHex("0xc0"), want: "[\n]\n"},
+		{blob: common.FromHex("0xc6827a77c10401"), want: "[\n  0x7a77\n  [\n    0x04\n  ]\n  0x01\n]\n"},
+		{blob: common.FromHex("0x0000000000000001"), fail: true},
+		{blob: common.FromHex("0xc3827a"), fail: true},
+	}
+	for i, tt := range tests {
+		var out bytes.Buffer
+		err := dumpRLP(&out, tt.blob, 0)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: no error for invalid RLP", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		} else if out.String() != tt.want {
+			t.Errorf("test %d: output mismatch:\nhave:\n%s\nwant:\n%s", i, out.String(), tt.want)
+		}
+	}
+}
+
+// Tests printing, listing and overwriting entries of a chain database.
+func TestDBInspectCommand(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	gspec := &core.Genesis{
+		Config:     params.TestChainConfig,
+		Difficulty: big.NewInt(0x20000),
+		GasLimit:   0x2fefd8,
+		Alloc:      core.GenesisAlloc{},
+	}
+	blob, err := json.Marshal(gspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesisFile := filepath.Join(datadir, "genesis.json")
+	if err := ioutil.WriteFile(genesisFile, blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	runGeth(t, "--datadir", datadir, "init", genesisFile).WaitExit()
+
+	genesis := gspec.ToBlock(nil)
+	headerKey := fmt.Sprintf("%x", blockKey("h", 0, genesis.Hash(), ""))
+
+	// Print the genesis header, decoded and as generic RLP
+	geth := runGeth(t, "--datadir", datadir, "db", "inspect", "--key", headerKey, "--decode-as", "types.Header")
+	geth.ExpectRegexp(fmt.Sprintf(`(?s)Key:   0x%s\n.*Decoded as types.Header:\n.*"hash": "%s"`, headerKey, genesis.Hash().Hex()))
+	geth.WaitExit()
+
+	geth = runGeth(t, "--datadir", datadir, "db", "inspect", "--key", headerKey)
+	geth.ExpectRegexp(`(?s)RLP:\n\[\n  0x0{64}\n`)
+	geth.WaitExit()
+
+	// List the genesis header and its canonical hash and total difficulty
+	geth = runGeth(t, "--datadir", datadir, "db", "inspect", "--prefix", "0x68", "--limit", "1")
+	geth.ExpectRegexp(`(?s)KEY +SIZE\n0x68\w+ +\d+\n\.\.\. 2 more keys omitted\nFound 3 entries with prefix 0x68, .* in total\n`)
+	geth.ExpectExit()
+
+	// Writing must be refused without --force
+	geth = runGeth(t, "--datadir", datadir, "db", "inspect", "--write-key", "0x1234", "--value", "0x5678")
+	geth.ExpectRegexp(`Fatal: Refusing to modify the database without --force, make a backup first.\n`)
+	geth.ExpectExit()
+
+	geth = runGeth(t, "--datadir", datadir, "db", "inspect", "--write-key", "0x1234", "--value", "0x5678", "--force")
+	geth.ExpectRegexp(`Previous value: none\nWrote 2 bytes to key 0x1234\n`)
+	geth.ExpectExit()
+
+	geth = runGeth(t, "--datadir", datadir, "db", "inspect", "--key", "0x1234")
+	geth.ExpectRegexp(`Value: 0x5678\nValue is not RLP encoded\n`)
+	geth.ExpectExit()
+}
@@ -40,7 +40,6 @@ import (
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -63,8 +62,12 @@ var (
 		utils.BootnodesFlag,
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.DataDirFlag,
 		utils.AncientFlag,
+		utils.DBEngineFlag,
+		utils.DBIOBudgetFlag,
+		utils.DBPruneBloomFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
 		utils.NoUSBFlag,
@@ -77,6 +80,9 @@ var (
 		utils.EthashDatasetDirFlag,
 		utils.EthashDatasetsInMemoryFlag,
 		utils.EthashDatasetsOnDiskFlag,
+		utils.EthashDatasetIOLimitFlag,
+		utils.EthashWorkFetchTimeoutFlag,
+		utils.EthashSlowVerificationsFlag,
 		utils.TxPoolLocalsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
@@ -91,6 +97,7 @@ var (
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
+		utils.TxHistoryFlag,
 		utils.LightServeFlag,
 		utils.LightLegacyServFlag,
 		utils.LightIngressFlag,
@@ -98,6 +105,7 @@ var (
 		utils.LightMaxPeersFlag,
 		utils.LightLegacyPeersFlag,
 		utils.LightKDFFlag,
+		utils.LightPriorityTokenFlag,
 		utils.UltraLightServersFlag,
 		utils.UltraLightFractionFlag,
 		utils.UltraLightOnlyAnnounceFlag,
@@ -106,6 +114,7 @@ var (
 		utils.CacheDatabaseFlag,
 		utils.CacheTrieFlag,
 		utils.CacheGCFlag,
+		utils.CacheGCCapFlag,
 		utils.CacheNoPrefetchFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
@@ -124,6 +133,7 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerLegacyExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
+		utils.MinerMaxTemplateAgeFlag,
 		utils.MinerNoVerfiyFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
@@ -145,6 +155,7 @@ var (
 		utils.GpoPercentileFlag,
 		utils.EWASMInterpreterFlag,
 		utils.EVMInterpreterFlag,
+		utils.PluginPathFlag,
 		configFileFlag,
 	}
 
@@ -167,6 +178,7 @@ var (
 		utils.WSAllowedOriginsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.IPCAbstractFlag,
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalGasCap,
 	}
@@ -187,6 +199,16 @@ var (
 		utils.MetricsInfluxDBUsernameFlag,
 		utils.MetricsInfluxDBPasswordFlag,
 		utils.MetricsInfluxDBTagsFlag,
+		utils.MetricsInfluxDBIntervalFlag,
+		utils.MetricsEnableInfluxDBV2Flag,
+		utils.MetricsInfluxDBTokenFlag,
+		utils.MetricsInfluxDBBucketFlag,
+		utils.MetricsInfluxDBOrganizationFlag,
+		utils.MetricsEnablePrometheusFlag,
+		utils.MetricsPrometheusAddrFlag,
+		utils.MetricsPrometheusPortFlag,
+		utils.MetricsPrometheusPathFlag,
+		utils.MetricsPrometheusQuantilesFlag,
 	}
 )
 
@@ -206,6 +228,9 @@ func init() {
 		removedbCommand,
 		dumpCommand,
 		inspectCommand,
+		chainCommand,
+		// See dbcmd.go:
+		dbCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -213,6 +238,12 @@ func init() {
 		consoleCommand,
 		attachCommand,
 		javascriptCommand,
+		// See metricscmd.go:
+		metricsCommand,
+		// See peercmd.go:
+		peerCommand,
+		// See simulatecmd.go:
+		simulateCommand,
 		// See misccmd.go:
 		makecacheCommand,
 		makedagCommand,
@@ -233,7 +264,11 @@ func init() {
 	app.Flags = append(app.Flags, metricsFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
-		return debug.Setup(ctx, "")
+		if err := debug.Setup(ctx, ""); err != nil {
+			return err
+		}
+		utils.EnableMetrics(ctx)
+		return nil
 	}
 	app.After = func(ctx *cli.Context) error {
 		debug.Exit()
@@ -249,7 +284,7 @@ func main() {
 	}
 }
 
-// prepare manipulates memory cache allowance and setups metric system.
+// prepare manipulates memory cache allowance.
 // This function should be called before launching devp2p stack.
 func prepare(ctx *cli.Context) {
 	// If we're a full node on mainnet without --cache specified, bump default cache allowance
@@ -286,11 +321,6 @@ func prepare(ctx *cli.Context) {
 	log.Debug("Sanitizing Go's GC trigger", "percent", int(gogc))
 	godebug.SetGCPercent(int(gogc))
 
-	// Start metrics export if enabled
-	utils.SetupMetrics(ctx)
-
-	// Start system runtime metrics collection
-	go metrics.CollectProcessMetrics(3 * time.Second)
 }
 
 // geth is the main entry point into the system if no special subcommand is ran.
@@ -301,8 +331,9 @@ func geth(ctx *cli.Context) error {
 		return fmt.Errorf("invalid command: %q", args[0])
 	}
 	prepare(ctx)
-	node := makeFullNode(ctx)
+	node, cfg := makeFullNode(ctx)
 	defer node.Close()
+	utils.SetupMetrics(&cfg.Metrics, strconv.FormatUint(cfg.Eth.NetworkId, 10))
 	startNode(ctx, node)
 	node.Wait()
 	return nil
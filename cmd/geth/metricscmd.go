@@ -0,0 +1,95 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/internal/debug"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	metricsOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the metrics snapshot to (default = stdout)",
+	}
+
+	metricsCommand = cli.Command{
+		Name:      "metrics",
+		Usage:     "Inspect the metrics of a running node",
+		ArgsUsage: "",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(metricsSnapshot),
+				Name:      "snapshot",
+				Usage:     "Export the current values of all metrics to a JSON file",
+				ArgsUsage: "[endpoint]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					utils.JWTSecretFlag,
+					metricsOutputFlag,
+				},
+				Description: `
+The snapshot command connects to a running geth instance, by default through the
+IPC endpoint in the data directory, and exports the current values of all its
+metrics as JSON. Each metric is reported with its name, type and values, the
+snapshot itself carries the time it was taken at.
+
+The node must be running with --metrics, otherwise most metrics are not collected.`,
+			},
+		},
+	}
+)
+
+// metricsSnapshot retrieves the current metrics of a running node and writes
+// them out as JSON.
+func metricsSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command accepts at most one argument.")
+	}
+	client, err := dialRPC(remoteEndpoint(ctx), ctx.String(utils.JWTSecretFlag.Name))
+	if err != nil {
+		utils.Fatalf("Unable to attach to remote geth: %v", err)
+	}
+	defer client.Close()
+
+	var snapshot debug.MetricsSnapshot
+	if err := client.Call(&snapshot, "debug_metricsSnapshot"); err != nil {
+		utils.Fatalf("Failed to retrieve metrics: %v", err)
+	}
+	out, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode metrics: %v", err)
+	}
+	output := ctx.String(metricsOutputFlag.Name)
+	if output == "" {
+		fmt.Println(string(out))
+		return nil
+	}
+	if err := ioutil.WriteFile(output, append(out, '\n'), 0644); err != nil {
+		utils.Fatalf("Failed to write metrics snapshot: %v", err)
+	}
+	fmt.Printf("Exported %d metrics to %s\n", len(snapshot.Metrics), output)
+	return nil
+}
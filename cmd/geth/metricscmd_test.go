@@ -0,0 +1,81 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/debug"
+)
+
+// Tests that the metrics of a running node can be exported to a JSON file.
+func TestMetricsSnapshot(t *testing.T) {
+	ws := tmpdir(t)
+	defer os.RemoveAll(ws)
+
+	ipc := filepath.Join(ws, "geth.ipc")
+	if runtime.GOOS == "windows" {
+		ipc = `\\.\pipe\geth` + strconv.Itoa(trulyRandInt(100000, 999999))
+	}
+	geth := runGeth(t,
+		"--port", "0", "--maxpeers", "0", "--nodiscover", "--nat", "none",
+		"--metrics", "--ipcpath", ipc)
+	defer func() {
+		geth.Interrupt()
+		geth.ExpectExit()
+	}()
+	waitForEndpoint(t, ipc, 3*time.Second)
+
+	output := filepath.Join(ws, "metrics.json")
+	snap := runGeth(t, "metrics", "snapshot", "--output", output, "ipc:"+ipc)
+	snap.ExpectRegexp(`Exported \d+ metrics to .*\n`)
+	snap.ExpectExit()
+
+	blob, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot debug.MetricsSnapshot
+	if err := json.Unmarshal(blob, &snapshot); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	if snapshot.Time.IsZero() {
+		t.Error("snapshot time missing")
+	}
+	types := make(map[string]string)
+	for _, metric := range snapshot.Metrics {
+		types[metric.Name] = metric.Type
+	}
+	for name, kind := range map[string]string{
+		"system/memory/allocs":   "meter",
+		"system/memory/held":     "gauge",
+		"p2p/ingress":            "meter",
+		"chain/inserts":          "timer",
+		"txpool/pending/replace": "meter",
+	} {
+		if types[name] != kind {
+			t.Errorf("metric %s: have type %q, want %q", name, types[name], kind)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	peerOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "Output format (text or json)",
+		Value: "text",
+	}
+	peerFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.IPCPathFlag,
+		utils.TestnetFlag,
+		utils.RinkebyFlag,
+		utils.GoerliFlag,
+		peerOutputFlag,
+	}
+
+	peerCommand = cli.Command{
+		Name:      "peer",
+		Usage:     "Manage the peers of a running node",
+		ArgsUsage: "",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Description: `
+The peer commands connect to a running geth instance through its IPC endpoint,
+which is looked up in the data directory unless --ipcpath is an explicit path.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(peerList),
+				Name:      "list",
+				Usage:     "List the connected peers",
+				ArgsUsage: " ",
+				Flags:     peerFlags,
+				Description: `
+Prints the ID, remote address and client version of all connected peers.`,
+			},
+			{
+				Action:    utils.MigrateFlags(peerAdd),
+				Name:      "add",
+				Usage:     "Connect to a peer",
+				ArgsUsage: "<enode>",
+				Flags:     peerFlags,
+				Description: `
+Adds the node as a static peer, which is kept connected until removed.`,
+			},
+			{
+				Action:    utils.MigrateFlags(peerRemove),
+				Name:      "remove",
+				Usage:     "Disconnect from a peer",
+				ArgsUsage: "<enode>",
+				Flags:     peerFlags,
+				Description: `
+Disconnects the node and removes it from the static peers.`,
+			},
+			{
+				Action:    utils.MigrateFlags(peerBan),
+				Name:      "ban",
+				Usage:     "Disconnect from a peer and refuse it temporarily",
+				ArgsUsage: "<enode> <duration>",
+				Flags:     peerFlags,
+				Description: `
+Disconnects the node and refuses connections to it for the given duration, e.g.
+30m or 24h. Bans require peer penalties to be enabled and don't affect trusted
+peers.`,
+			},
+		},
+	}
+)
+
+// peerActionResult is the outcome of a peer management action.
+type peerActionResult struct {
+	Action      string     `json:"action"`
+	Enode       string     `json:"enode"`
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"`
+}
+
+var errPeerOutputFormat = errors.New("invalid output format, want text or json")
+
+// parsePeerOutput validates the requested output format, reporting whether JSON
+// output was requested.
+func parsePeerOutput(format string) (bool, error) {
+	switch format {
+	case "", "text":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, errPeerOutputFormat
+}
+
+// parsePeerArgs parses the enode URL of a peer action.
+func parsePeerArgs(args []string) (*enode.Node, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected a single enode URL argument")
+	}
+	node, err := enode.Parse(enode.ValidSchemes, args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid enode: %v", err)
+	}
+	return node, nil
+}
+
+// parseBanArgs parses the enode URL and the duration of a peer ban.
+func parseBanArgs(args []string) (*enode.Node, time.Duration, error) {
+	if len(args) != 2 {
+		return nil, 0, errors.New("expected an enode URL and a duration argument")
+	}
+	node, err := parsePeerArgs(args[:1])
+	if err != nil {
+		return nil, 0, err
+	}
+	duration, err := time.ParseDuration(args[1])
+	if err != nil || duration <= 0 {
+		return nil, 0, fmt.Errorf("invalid ban duration: %q", args[1])
+	}
+	return node, duration, nil
+}
+
+// dialPeerEndpoint connects to the IPC endpoint of the node whose peers are
+// managed, and validates the requested output format.
+func dialPeerEndpoint(ctx *cli.Context) (*rpc.Client, bool) {
+	jsonOutput, err := parsePeerOutput(ctx.String(peerOutputFlag.Name))
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	cfg := node.Config{DataDir: utils.MakeDataDir(ctx), IPCPath: clientIdentifier + ".ipc"}
+	if ctx.GlobalIsSet(utils.IPCPathFlag.Name) {
+		cfg.IPCPath = ctx.GlobalString(utils.IPCPathFlag.Name)
+	}
+	client, err := rpc.Dial(cfg.IPCEndpoint())
+	if err != nil {
+		utils.Fatalf("Unable to attach to geth: %v", err)
+	}
+	return client, jsonOutput
+}
+
+// printPeerJSON prints a value as indented JSON.
+func printPeerJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode output: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+func peerList(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		utils.Fatalf("This command doesn't accept arguments.")
+	}
+	client, jsonOutput := dialPeerEndpoint(ctx)
+	defer client.Close()
+
+	var peers []*p2p.PeerInfo
+	if err := client.Call(&peers, "admin_peers"); err != nil {
+		utils.Fatalf("Failed to retrieve peers: %v", err)
+	}
+	if jsonOutput {
+		printPeerJSON(peers)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tVERSION")
+	for _, peer := range peers {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", peer.ID, peer.Network.RemoteAddress, peer.Name)
+	}
+	return w.Flush()
+}
+
+func peerAdd(ctx *cli.Context) error {
+	return peerAction(ctx, "add", "admin_addPeer", "Added")
+}
+
+func peerRemove(ctx *cli.Context) error {
+	return peerAction(ctx, "remove", "admin_removePeer", "Removed")
+}
+
+// peerAction calls an admin method taking the enode URL of a peer.
+func peerAction(ctx *cli.Context, action, method, done string) error {
+	node, err := parsePeerArgs(ctx.Args())
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	client, jsonOutput := dialPeerEndpoint(ctx)
+	defer client.Close()
+
+	var ok bool
+	if err := client.Call(&ok, method, node.URLv4()); err != nil {
+		utils.Fatalf("Failed to %s peer: %v", action, err)
+	}
+	if jsonOutput {
+		printPeerJSON(&peerActionResult{Action: action, Enode: node.URLv4()})
+	} else {
+		fmt.Printf("%s peer %s\n", done, node.URLv4())
+	}
+	return nil
+}
+
+func peerBan(ctx *cli.Context) error {
+	node, duration, err := parseBanArgs(ctx.Args())
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	client, jsonOutput := dialPeerEndpoint(ctx)
+	defer client.Close()
+
+	var rep p2p.Reputation
+	if err := client.Call(&rep, "admin_banPeer", node.URLv4(), duration.String()); err != nil {
+		utils.Fatalf("Failed to ban peer: %v", err)
+	}
+	if jsonOutput {
+		printPeerJSON(&peerActionResult{Action: "ban", Enode: node.URLv4(), BannedUntil: rep.BannedUntil})
+	} else if rep.BannedUntil != nil {
+		fmt.Printf("Banned peer %s until %v\n", node.URLv4(), rep.BannedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
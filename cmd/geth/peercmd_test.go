@@ -0,0 +1,187 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const testEnode = "enode://a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c@52.16.188.185:30303"
+
+func TestParsePeerArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{testEnode}, true},
+		{[]string{}, false},
+		{[]string{testEnode, testEnode}, false},
+		{[]string{"enode://invalid@127.0.0.1:30303"}, false},
+	}
+	for i, test := range tests {
+		node, err := parsePeerArgs(test.args)
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: error mismatch: %v", i, err)
+		}
+		if err == nil && node.URLv4() != testEnode {
+			t.Errorf("test %d: enode mismatch: have %s", i, node.URLv4())
+		}
+	}
+}
+
+func TestParseBanArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		duration time.Duration
+		ok       bool
+	}{
+		{[]string{testEnode, "90m"}, 90 * time.Minute, true},
+		{[]string{testEnode, "24h"}, 24 * time.Hour, true},
+		{[]string{testEnode}, 0, false},
+		{[]string{testEnode, "forever"}, 0, false},
+		{[]string{testEnode, "-1h"}, 0, false},
+		{[]string{testEnode, "0s"}, 0, false},
+		{[]string{"invalid", "1h"}, 0, false},
+	}
+	for i, test := range tests {
+		_, duration, err := parseBanArgs(test.args)
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: error mismatch: %v", i, err)
+		}
+		if duration != test.duration {
+			t.Errorf("test %d: duration mismatch: have %v, want %v", i, duration, test.duration)
+		}
+	}
+}
+
+func TestParsePeerOutput(t *testing.T) {
+	for format, want := range map[string]bool{"": false, "text": false, "json": true} {
+		if have, err := parsePeerOutput(format); err != nil || have != want {
+			t.Errorf("format %q: have %t, %v, want %t", format, have, err, want)
+		}
+	}
+	if _, err := parsePeerOutput("xml"); err != errPeerOutputFormat {
+		t.Errorf("invalid format error mismatch: have %v, want %v", err, errPeerOutputFormat)
+	}
+}
+
+// startPeerTestNode starts an in-process node without any protocols, listening
+// on a random local port.
+func startPeerTestNode(t *testing.T, ipc string) *node.Node {
+	stack, err := node.New(&node.Config{
+		IPCPath: ipc,
+		P2P: p2p.Config{
+			ListenAddr:      "127.0.0.1:0",
+			NoDiscovery:     true,
+			MaxPeers:        10,
+			PenaltyDuration: time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	return stack
+}
+
+// waitPeerCount waits until the node has the given number of peers.
+func waitPeerCount(t *testing.T, stack *node.Node, count int) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(50 * time.Millisecond) {
+		if stack.Server().PeerCount() == count {
+			return
+		}
+	}
+	t.Fatalf("peer count mismatch: have %d, want %d", stack.Server().PeerCount(), count)
+}
+
+// Tests the peer commands against a network of two in-process nodes.
+func TestPeerCommands(t *testing.T) {
+	ws := tmpdir(t)
+	defer os.RemoveAll(ws)
+
+	ipc := filepath.Join(ws, "geth.ipc")
+	local := startPeerTestNode(t, ipc)
+	defer local.Close()
+	remote := startPeerTestNode(t, "")
+	defer remote.Close()
+
+	remoteURL := remote.Server().Self().URLv4()
+	remoteID := remote.Server().Self().ID().String()
+
+	// Connect the nodes and check the peer list
+	geth := runGeth(t, "peer", "add", "--ipcpath", ipc, remoteURL)
+	geth.ExpectRegexp("Added peer " + regexp.QuoteMeta(remoteURL) + "\n")
+	geth.ExpectExit()
+	waitPeerCount(t, local, 1)
+
+	geth = runGeth(t, "peer", "list", "--ipcpath", ipc)
+	geth.ExpectRegexp(`ID +ADDRESS +VERSION\n` + remoteID + ` +127\.0\.0\.1:\d+ +.*\n`)
+	geth.ExpectExit()
+
+	geth = runGeth(t, "peer", "list", "--ipcpath", ipc, "--output", "json")
+	_, matches := geth.ExpectRegexp(`(?s)(\[.*\])\n`)
+	geth.ExpectExit()
+
+	var peers []*p2p.PeerInfo
+	if err := json.Unmarshal([]byte(matches[1]), &peers); err != nil {
+		t.Fatalf("invalid peer list: %v", err)
+	}
+	if len(peers) != 1 || peers[0].ID != remoteID {
+		t.Fatalf("peer list mismatch: %+v", peers)
+	}
+	// Disconnect the peer, then ban it
+	geth = runGeth(t, "peer", "remove", "--ipcpath", ipc, remoteURL)
+	geth.ExpectRegexp("Removed peer " + regexp.QuoteMeta(remoteURL) + "\n")
+	geth.ExpectExit()
+	waitPeerCount(t, local, 0)
+
+	geth = runGeth(t, "peer", "ban", "--ipcpath", ipc, "--output", "json", remoteURL, "1h")
+	_, matches = geth.ExpectRegexp(`(?s)(\{.*\})\n`)
+	geth.ExpectExit()
+
+	var result peerActionResult
+	if err := json.Unmarshal([]byte(matches[1]), &result); err != nil {
+		t.Fatalf("invalid ban result: %v", err)
+	}
+	if result.Action != "ban" || result.Enode != remoteURL || result.BannedUntil == nil {
+		t.Fatalf("ban result mismatch: %+v", result)
+	}
+	if rep := local.Server().PeerReputation(remote.Server().Self().ID()); !rep.Banned {
+		t.Fatalf("peer not banned: %+v", rep)
+	}
+	// Banned peers must not be connected
+	geth = runGeth(t, "peer", "add", "--ipcpath", ipc, remoteURL)
+	geth.ExpectRegexp("Added peer .*\n")
+	geth.ExpectExit()
+
+	time.Sleep(500 * time.Millisecond)
+	if count := local.Server().PeerCount(); count != 0 {
+		t.Fatalf("banned peer connected: %d peers", count)
+	}
+}
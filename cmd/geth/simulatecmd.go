@@ -0,0 +1,248 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	simulateTxFlag = cli.StringFlag{
+		Name:  "tx",
+		Usage: "Hex encoded signed transaction to simulate",
+	}
+	simulateBlockFlag = cli.StringFlag{
+		Name:  "block",
+		Usage: `Block to simulate on top of (number, "latest" or "pending")`,
+		Value: "pending",
+	}
+	simulateABIFlag = cli.StringFlag{
+		Name:  "abi",
+		Usage: "Path to the JSON ABI of the called contract, used to decode the results",
+	}
+	simulateTraceFlag = cli.BoolFlag{
+		Name:  "trace",
+		Usage: "Print the executed opcodes",
+	}
+	simulateOverrideFlag = cli.StringFlag{
+		Name:  "override",
+		Usage: "Path to a JSON file of state overrides, keyed by account address",
+	}
+
+	simulateCommand = cli.Command{
+		Action:    utils.MigrateFlags(simulate),
+		Name:      "simulate",
+		Usage:     "Execute a signed transaction against the state of a running node without broadcasting it",
+		ArgsUsage: "[endpoint]",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+			utils.JWTSecretFlag,
+			simulateTxFlag,
+			simulateBlockFlag,
+			simulateABIFlag,
+			simulateTraceFlag,
+			simulateOverrideFlag,
+		},
+		Description: `
+The simulate command connects to a running geth instance, by default through the
+IPC endpoint in the data directory, and executes the given signed transaction on
+top of the state of a block, without broadcasting it or including it anywhere.
+It prints the return data, the gas used, the revert reason if any and the logs
+emitted by the transaction. The transaction nonce is not checked.
+
+If the ABI of the called contract is given with --abi, the return data and the
+logs are decoded with it. The state can be modified before execution with
+--override, whose file maps account addresses to overrides of their balance,
+nonce, code, and storage (state or stateDiff), as accepted by eth_call.`,
+	}
+)
+
+// simulateResult mirrors ethapi.SimulationResult, keeping only the fields of the
+// opcode trace which are printed.
+type simulateResult struct {
+	Hash       common.Hash    `json:"hash"`
+	From       common.Address `json:"from"`
+	Failed     bool           `json:"failed"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Logs       []*types.Log   `json:"logs"`
+	StructLogs []struct {
+		Pc      uint64 `json:"pc"`
+		Op      string `json:"op"`
+		Gas     uint64 `json:"gas"`
+		GasCost uint64 `json:"gasCost"`
+		Depth   int    `json:"depth"`
+	} `json:"structLogs"`
+}
+
+// simulate executes a signed transaction on a running node and prints the
+// outcome.
+func simulate(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command accepts at most one argument.")
+	}
+	input := ctx.String(simulateTxFlag.Name)
+	if input == "" {
+		utils.Fatalf("A signed transaction must be given with --%s.", simulateTxFlag.Name)
+	}
+	encoded, err := hexutil.Decode(input)
+	if err != nil {
+		utils.Fatalf("Invalid transaction: %v", err)
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encoded, tx); err != nil {
+		utils.Fatalf("Invalid transaction: %v", err)
+	}
+	block := ctx.String(simulateBlockFlag.Name)
+	switch block {
+	case "latest", "pending":
+	default:
+		number, err := strconv.ParseUint(block, 0, 64)
+		if err != nil {
+			utils.Fatalf("Invalid block %q: %v", block, err)
+		}
+		block = hexutil.EncodeUint64(number)
+	}
+	config := struct {
+		Trace     bool            `json:"trace"`
+		Overrides json.RawMessage `json:"overrides,omitempty"`
+	}{
+		Trace: ctx.Bool(simulateTraceFlag.Name),
+	}
+	if path := ctx.String(simulateOverrideFlag.Name); path != "" {
+		blob, err := ioutil.ReadFile(path)
+		if err != nil {
+			utils.Fatalf("Failed to read state overrides: %v", err)
+		}
+		config.Overrides = blob
+	}
+	var contract *abi.ABI
+	if path := ctx.String(simulateABIFlag.Name); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			utils.Fatalf("Failed to read ABI: %v", err)
+		}
+		parsed, err := abi.JSON(file)
+		file.Close()
+		if err != nil {
+			utils.Fatalf("Invalid ABI: %v", err)
+		}
+		contract = &parsed
+	}
+	client, err := dialRPC(remoteEndpoint(ctx), ctx.String(utils.JWTSecretFlag.Name))
+	if err != nil {
+		utils.Fatalf("Unable to attach to remote geth: %v", err)
+	}
+	defer client.Close()
+
+	var result simulateResult
+	if err := client.Call(&result, "debug_simulateTransaction", hexutil.Bytes(encoded), block, config); err != nil {
+		utils.Fatalf("Failed to simulate transaction: %v", err)
+	}
+	printSimulation(tx, &result, contract)
+	return nil
+}
+
+// printSimulation writes the outcome of a simulated transaction to stdout,
+// decoding the return data and logs with the contract ABI if available.
+func printSimulation(tx *types.Transaction, result *simulateResult, contract *abi.ABI) {
+	status := "success"
+	if result.Failed {
+		status = "failed"
+	}
+	fmt.Println("Transaction:", result.Hash.Hex())
+	fmt.Println("From:       ", result.From.Hex())
+	fmt.Println("Status:     ", status)
+	fmt.Println("Gas used:   ", uint64(result.GasUsed))
+	fmt.Println("Return data:", result.ReturnData)
+
+	if result.Failed {
+		if reason, ok := revertReason(result.ReturnData); ok {
+			fmt.Printf("Revert reason: %q\n", reason)
+		}
+	} else if contract != nil && len(result.ReturnData) > 0 && len(tx.Data()) >= 4 {
+		if method, err := contract.MethodById(tx.Data()[:4]); err == nil {
+			if values, err := method.Outputs.UnpackValues(result.ReturnData); err == nil {
+				fmt.Printf("Returned:    %s%v\n", method.Name, values)
+			} else {
+				fmt.Printf("Returned:    failed to decode %s outputs: %v\n", method.Name, err)
+			}
+		}
+	}
+	fmt.Printf("Logs:        %d\n", len(result.Logs))
+	for i, log := range result.Logs {
+		fmt.Printf("  [%d] address %s\n", i, log.Address.Hex())
+		for j, topic := range log.Topics {
+			fmt.Printf("      topic %d %s\n", j, topic.Hex())
+		}
+		fmt.Printf("      data %s\n", hexutil.Bytes(log.Data))
+
+		if contract == nil || len(log.Topics) == 0 {
+			continue
+		}
+		event, err := contract.EventByID(log.Topics[0])
+		if err != nil {
+			continue
+		}
+		values, err := event.Inputs.NonIndexed().UnpackValues(log.Data)
+		if err != nil {
+			fmt.Printf("      event %s: failed to decode data: %v\n", event.Name, err)
+			continue
+		}
+		fmt.Printf("      event %s%v\n", event.Name, values)
+	}
+	if len(result.StructLogs) > 0 {
+		fmt.Println("Trace:")
+		for _, step := range result.StructLogs {
+			fmt.Printf("  %s%-5d %-14s gas %-8d cost %d\n", strings.Repeat("  ", step.Depth-1), step.Pc, step.Op, step.Gas, step.GasCost)
+		}
+	}
+}
+
+// revertSelector is the 4 byte selector of the Error(string) revert reason.
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// revertReason decodes the Error(string) revert reason from the return data of
+// a failed execution.
+func revertReason(data []byte) (string, bool) {
+	if len(data) < 4 || !bytes.Equal(data[:4], revertSelector) {
+		return "", false
+	}
+	typ, _ := abi.NewType("string", "", nil)
+	var reason string
+	if err := (abi.Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+		return "", false
+	}
+	return reason, true
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const simulateABI = `[
+	{"type":"function","name":"get","constant":true,"inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"event","name":"Ping","anonymous":false,"inputs":[{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+var (
+	// simulatePinger stores and returns 42, emitting Ping(42) along the way.
+	simulatePinger = "0x602a600055602a600052" +
+		"7f" + crypto.Keccak256Hash([]byte("Ping(uint256)")).Hex()[2:] + "60206000a1" +
+		"60206000f3"
+
+	// simulateReverter reverts with Error("nope").
+	simulateReverter = "0x" +
+		"7f08c379a000000000000000000000000000000000000000000000000000000000600052" +
+		"6020600452" +
+		"6004602452" +
+		"7f6e6f706500000000000000000000000000000000000000000000000000000000604452" +
+		"60646000fd"
+)
+
+// Tests that signed transactions can be simulated against a running dev chain,
+// with their results decoded using the contract ABI.
+func TestSimulateCommand(t *testing.T) {
+	ws := tmpdir(t)
+	defer os.RemoveAll(ws)
+
+	ipc := filepath.Join(ws, "geth.ipc")
+	if runtime.GOOS == "windows" {
+		ipc = `\\.\pipe\geth` + strconv.Itoa(trulyRandInt(100000, 999999))
+	}
+	geth := runGeth(t,
+		"--dev", "--datadir", filepath.Join(ws, "node"), "--port", "0", "--maxpeers", "0",
+		"--nodiscover", "--nat", "none", "--ipcpath", ipc)
+	defer func() {
+		geth.Interrupt()
+		geth.ExpectExit()
+	}()
+	waitForEndpoint(t, ipc, 5*time.Second)
+
+	var (
+		key, _   = crypto.GenerateKey()
+		pinger   = common.HexToAddress("0x1000")
+		reverter = common.HexToAddress("0x2000")
+	)
+	sign := func(to common.Address, data []byte) string {
+		tx := types.NewTransaction(0, to, big.NewInt(1), 100000, big.NewInt(1), data)
+		tx, err := types.SignTx(tx, types.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob, _ := rlp.EncodeToBytes(tx)
+		return hexutil.Encode(blob)
+	}
+	abiPath := filepath.Join(ws, "contract.abi")
+	if err := ioutil.WriteFile(abiPath, []byte(simulateABI), 0644); err != nil {
+		t.Fatal(err)
+	}
+	overridePath := filepath.Join(ws, "override.json")
+	overrides := fmt.Sprintf(`{"%s": {"code": "%s"}, "%s": {"code": "%s"}}`, pinger.Hex(), simulatePinger, reverter.Hex(), simulateReverter)
+	if err := ioutil.WriteFile(overridePath, []byte(overrides), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A plain value transfer should succeed without any logs
+	sim := runGeth(t, "simulate", "--tx", sign(common.HexToAddress("0xdead"), nil), "ipc:"+ipc)
+	sim.ExpectRegexp(`(?s)From: +` + crypto.PubkeyToAddress(key.PublicKey).Hex() + `\nStatus: +success\nGas used: +21000\n.*Logs: +0\n`)
+	sim.WaitExit()
+
+	// A contract call should have its return data and logs decoded
+	sim = runGeth(t, "simulate", "--tx", sign(pinger, hexutil.MustDecode("0x6d4ce63c")),
+		"--block", "latest", "--abi", abiPath, "--override", overridePath, "--trace", "ipc:"+ipc)
+	sim.ExpectRegexp(`(?s)Status: +success\n.*Returned: +get\[42\]\n.*event Ping\[42\]\n.*Trace:\n.*SSTORE.*RETURN`)
+	sim.WaitExit()
+
+	// A reverting contract call should have its revert reason decoded
+	sim = runGeth(t, "simulate", "--tx", sign(reverter, nil), "--override", overridePath, "ipc:"+ipc)
+	sim.ExpectRegexp(`(?s)Status: +failed\n.*Revert reason: "nope"\n`)
+	sim.WaitExit()
+}
@@ -18,7 +18,10 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -26,6 +29,7 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -36,13 +40,21 @@ import (
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/klauspost/compress/zstd"
 )
 
 const (
 	importBatchSize = 2500
 )
 
+// compressedChainMagic is the header of zstd compressed chain exports, used by
+// ImportChain to detect compressed files.
+const compressedChainMagic = "GETH_COMPRESSED_CHAIN\x00"
+
+var errNotCompressedChain = errors.New("not a compressed chain file")
+
 // Fatalf formats a message to standard error and exits the program.
 // The message is also printed to standard output if standard error
 // is redirected to a different file.
@@ -85,7 +97,10 @@ func StartNode(stack *node.Node) {
 	}()
 }
 
-func ImportChain(chain *core.BlockChain, fn string) error {
+// ImportChain imports the blocks in the specified file into the chain. Files
+// ending in .gz are gunzipped, files written by a compressed export are detected
+// by their header. If compressed is set, the file must be a compressed export.
+func ImportChain(chain *core.BlockChain, fn string, compressed bool) error {
 	// Watch for Ctrl-C while the import is running.
 	// If a signal is received, the import will stop at the next batch.
 	interrupt := make(chan os.Signal, 1)
@@ -110,19 +125,11 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 
 	log.Info("Importing blockchain", "file", fn)
 
-	// Open the file handle and potentially unwrap the gzip stream
-	fh, err := os.Open(fn)
+	reader, closer, err := openChainFile(fn, compressed)
 	if err != nil {
 		return err
 	}
-	defer fh.Close()
-
-	var reader io.Reader = fh
-	if strings.HasSuffix(fn, ".gz") {
-		if reader, err = gzip.NewReader(reader); err != nil {
-			return err
-		}
-	}
+	defer closer()
 	stream := rlp.NewStream(reader, 0)
 
 	// Run actual the import.
@@ -168,6 +175,180 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	return nil
 }
 
+// openChainFile opens a chain export for reading, unwrapping gzip (by the file
+// extension) and zstd streams. The returned function releases the file.
+func openChainFile(fn string, compressed bool) (io.Reader, func(), error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			fh.Close()
+			return nil, nil, err
+		}
+	}
+	if reader, err = openCompressedChain(reader, compressed); err != nil {
+		fh.Close()
+		return nil, nil, err
+	}
+	closer := func() { fh.Close() }
+	if zr, ok := reader.(*zstd.Decoder); ok {
+		closer = func() {
+			zr.Close()
+			fh.Close()
+		}
+	}
+	return reader, closer, nil
+}
+
+// ValidateChain verifies the headers of the blocks numbered from..to (both
+// inclusive) in a chain export against the consensus rules of the chain's
+// engine: the parent links, the difficulty and the seal (PoW or signature).
+// Transactions are not executed and nothing is written to the chain. Blocks
+// before the range are only loaded as ancestors.
+//
+// The number of verified blocks is returned along with the error describing the
+// first invalid block, if any.
+func ValidateChain(chain *core.BlockChain, fn string, from, to uint64) (int, error) {
+	log.Info("Validating blockchain", "file", fn, "from", from, "to", to)
+
+	reader, closer, err := openChainFile(fn, false)
+	if err != nil {
+		return 0, err
+	}
+	defer closer()
+	stream := rlp.NewStream(reader, 0)
+
+	var (
+		headers  = newValidationChain(chain)
+		engine   = chain.Engine()
+		verified = 0
+		logged   = time.Now()
+	)
+	for {
+		var block types.Block
+		if err := stream.Decode(&block); err == io.EOF {
+			break
+		} else if err != nil {
+			return verified, fmt.Errorf("after block %d: %v", headers.number(), err)
+		}
+		header := block.Header()
+		number := header.Number.Uint64()
+		if number > to {
+			break
+		}
+		if headers.head == nil {
+			headers.first = number
+		}
+		if number >= from && number > 0 {
+			if prev := headers.CurrentHeader(); prev != nil && prev.Number.Uint64()+1 == number && prev.Hash() != header.ParentHash {
+				err = fmt.Errorf("parent hash mismatch: have %x, want %x", header.ParentHash, prev.Hash())
+			} else {
+				err = engine.VerifyHeader(headers, header, true)
+			}
+			if err != nil {
+				return verified, fmt.Errorf("invalid block %d (hash %x): %v", number, header.Hash(), err)
+			}
+			verified++
+		}
+		headers.add(header)
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Validating blockchain", "number", number, "verified", verified)
+			logged = time.Now()
+		}
+	}
+	return verified, nil
+}
+
+// validationChain is a consensus.ChainReader over the most recent headers loaded
+// from a chain export. Headers older than the file are taken from the local
+// chain, so exports not starting at the genesis can be validated too.
+type validationChain struct {
+	chain   *core.BlockChain
+	first   uint64 // Number of the first header loaded from the file
+	head    *types.Header
+	hashes  map[common.Hash]*types.Header
+	numbers map[uint64]*types.Header
+}
+
+func newValidationChain(chain *core.BlockChain) *validationChain {
+	return &validationChain{
+		chain:   chain,
+		hashes:  make(map[common.Hash]*types.Header),
+		numbers: make(map[uint64]*types.Header),
+	}
+}
+
+// add tracks a validated header, dropping the ones beyond the immutability
+// threshold, which consensus engines never look back to.
+func (c *validationChain) add(header *types.Header) {
+	number := header.Number.Uint64()
+	if old := c.numbers[number]; old != nil {
+		delete(c.hashes, old.Hash())
+	}
+	c.hashes[header.Hash()] = header
+	c.numbers[number] = header
+	c.head = header
+
+	if number >= params.ImmutabilityThreshold {
+		if old := c.numbers[number-params.ImmutabilityThreshold]; old != nil {
+			delete(c.hashes, old.Hash())
+			delete(c.numbers, old.Number.Uint64())
+		}
+	}
+}
+
+// number returns the number of the last loaded header.
+func (c *validationChain) number() uint64 {
+	if c.head == nil {
+		return 0
+	}
+	return c.head.Number.Uint64()
+}
+
+func (c *validationChain) Config() *params.ChainConfig  { return c.chain.Config() }
+func (c *validationChain) CurrentHeader() *types.Header { return c.head }
+
+func (c *validationChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.hashes[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	if number < c.first {
+		return c.chain.GetHeader(hash, number)
+	}
+	return nil
+}
+
+func (c *validationChain) GetHeaderByNumber(number uint64) *types.Header {
+	if header := c.numbers[number]; header != nil {
+		return header
+	}
+	if number < c.first {
+		return c.chain.GetHeaderByNumber(number)
+	}
+	return nil
+}
+
+func (c *validationChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	if header := c.hashes[hash]; header != nil {
+		return header
+	}
+	if header := c.chain.GetHeaderByHash(hash); header != nil && header.Number.Uint64() < c.first {
+		return header
+	}
+	return nil
+}
+
+func (c *validationChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if header := c.GetHeader(hash, number); header != nil {
+		return types.NewBlockWithHeader(header)
+	}
+	return nil
+}
+
 func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block {
 	head := chain.CurrentBlock()
 	for i, block := range blocks {
@@ -187,8 +368,9 @@ func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block
 }
 
 // ExportChain exports a blockchain into the specified file, truncating any data
-// already present in the file.
-func ExportChain(blockchain *core.BlockChain, fn string) error {
+// already present in the file. If compress is set, the blocks are written as a
+// zstd stream following a header identifying the compressed format.
+func ExportChain(blockchain *core.BlockChain, fn string, compress bool) error {
 	log.Info("Exporting blockchain", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
@@ -203,18 +385,31 @@ func ExportChain(blockchain *core.BlockChain, fn string) error {
 		writer = gzip.NewWriter(writer)
 		defer writer.(*gzip.Writer).Close()
 	}
+	if compress {
+		zw, err := newCompressedChainWriter(writer, true)
+		if err != nil {
+			return err
+		}
+		writer = zw
+	}
 	// Iterate over the blocks and export them
 	if err := blockchain.Export(writer); err != nil {
 		return err
 	}
+	if zw, ok := writer.(*zstd.Encoder); ok {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
 	log.Info("Exported blockchain", "file", fn)
 
 	return nil
 }
 
 // ExportAppendChain exports a blockchain into the specified file, appending to
-// the file if data already exists in it.
-func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
+// the file if data already exists in it. If compress is set, the blocks are
+// appended as a new zstd frame, so the file must be empty or a compressed export.
+func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64, compress bool) error {
 	log.Info("Exporting blockchain", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
@@ -229,14 +424,87 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 		writer = gzip.NewWriter(writer)
 		defer writer.(*gzip.Writer).Close()
 	}
+	if compress {
+		// Only start the file with the header, compressed chunks are concatenated.
+		header, err := compressedChainHeader(fn)
+		if err != nil {
+			return err
+		}
+		zw, err := newCompressedChainWriter(writer, !header)
+		if err != nil {
+			return err
+		}
+		writer = zw
+	}
 	// Iterate over the blocks and export them
 	if err := blockchain.ExportN(writer, first, last); err != nil {
 		return err
 	}
+	if zw, ok := writer.(*zstd.Encoder); ok {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
 	log.Info("Exported blockchain to", "file", fn)
 	return nil
 }
 
+// newCompressedChainWriter wraps w with a zstd encoder, optionally writing the
+// compressed chain header first.
+func newCompressedChainWriter(w io.Writer, header bool) (*zstd.Encoder, error) {
+	if header {
+		if _, err := io.WriteString(w, compressedChainMagic); err != nil {
+			return nil, err
+		}
+	}
+	return zstd.NewWriter(w)
+}
+
+// compressedChainHeader reports whether the given file already starts with the
+// compressed chain header. Empty files have no header, any other content is an
+// error as compressed data can't be appended to it.
+func compressedChainHeader(fn string) (bool, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return false, err
+	}
+	defer fh.Close()
+
+	header := make([]byte, len(compressedChainMagic))
+	n, err := io.ReadFull(fh, header)
+	switch {
+	case n == 0:
+		return false, nil
+	case err == nil && string(header) == compressedChainMagic:
+		return true, nil
+	default:
+		return false, errNotCompressedChain
+	}
+}
+
+// openCompressedChain checks whether r starts with the compressed chain header.
+// If so, it returns a reader decompressing the remaining stream, otherwise the
+// input is returned as is, unless required is set.
+func openCompressedChain(r io.Reader, required bool) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(compressedChainMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(header, []byte(compressedChainMagic)) {
+		if required {
+			return nil, errNotCompressedChain
+		}
+		return br, nil
+	}
+	br.Discard(len(compressedChainMagic))
+	zr, err := zstd.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
 func ImportPreimages(db ethdb.Database, fn string) error {
 	log.Info("Importing preimages", "file", fn)
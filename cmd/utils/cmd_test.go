@@ -0,0 +1,132 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newTestChain(t *testing.T, blocks int) *core.BlockChain {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = new(core.Genesis).MustCommit(db)
+	)
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks > 0 {
+		generated, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, blocks, nil)
+		if _, err := chain.InsertChain(generated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chain
+}
+
+// Tests that compressed chain exports are smaller than plain ones and can be
+// imported again, with and without the compress flag.
+func TestExportImportCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-export-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTestChain(t, 256)
+	defer src.Stop()
+
+	var (
+		plain      = filepath.Join(dir, "chain.rlp")
+		compressed = filepath.Join(dir, "chain.rlp.zst")
+	)
+	if err := ExportChain(src, plain, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportChain(src, compressed, true); err != nil {
+		t.Fatal(err)
+	}
+	plainInfo, _ := os.Stat(plain)
+	compressedInfo, _ := os.Stat(compressed)
+	if compressedInfo.Size() >= plainInfo.Size() {
+		t.Errorf("compressed export not smaller: %d >= %d bytes", compressedInfo.Size(), plainInfo.Size())
+	}
+	t.Logf("plain export %d bytes, compressed %d bytes", plainInfo.Size(), compressedInfo.Size())
+
+	for _, flag := range []bool{false, true} {
+		dst := newTestChain(t, 0)
+		if err := ImportChain(dst, compressed, flag); err != nil {
+			t.Fatalf("import (compress=%v) failed: %v", flag, err)
+		}
+		if have, want := dst.CurrentBlock().Hash(), src.CurrentBlock().Hash(); have != want {
+			t.Errorf("head mismatch after import (compress=%v): have %x, want %x", flag, have, want)
+		}
+		dst.Stop()
+	}
+	// Requiring compression must reject plain exports.
+	dst := newTestChain(t, 0)
+	defer dst.Stop()
+	if err := ImportChain(dst, plain, true); err != errNotCompressedChain {
+		t.Fatalf("wrong error importing plain export as compressed: %v", err)
+	}
+}
+
+// Tests that compressed exports can be appended in several ranges.
+func TestExportAppendCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-export-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := newTestChain(t, 128)
+	defer src.Stop()
+
+	file := filepath.Join(dir, "chain.rlp.zst")
+	if err := ExportAppendChain(src, file, 0, 63, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportAppendChain(src, file, 64, 128, true); err != nil {
+		t.Fatal(err)
+	}
+	dst := newTestChain(t, 0)
+	defer dst.Stop()
+	if err := ImportChain(dst, file, false); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := dst.CurrentBlock().Hash(), src.CurrentBlock().Hash(); have != want {
+		t.Errorf("head mismatch after import: have %x, want %x", have, want)
+	}
+
+	// Compressed data can't be appended to plain exports.
+	plain := filepath.Join(dir, "chain.rlp")
+	if err := ExportAppendChain(src, plain, 0, 10, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportAppendChain(src, plain, 11, 20, true); err != errNotCompressedChain {
+		t.Fatalf("wrong error appending compressed data to plain export: %v", err)
+	}
+}
@@ -24,8 +24,10 @@ import (
 	"io"
 	"io/ioutil"
 	"math/big"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -36,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -52,10 +55,12 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -147,6 +152,21 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Key-value store backend of the databases (" + strings.Join(ethdb.Backends(), ", ") + ")",
+		Value: ethdb.DefaultBackend,
+	}
+	DBIOBudgetFlag = cli.IntFlag{
+		Name:  "db.iobudget",
+		Usage: "Percentage of time online database compaction and verification may spend on IO",
+		Value: eth.DefaultConfig.DatabaseIOBudget,
+	}
+	DBPruneBloomFlag = cli.Uint64Flag{
+		Name:  "db.prunebloom",
+		Usage: "Megabytes of memory allocated to the bloom filter marking the state retained by pruning",
+		Value: eth.DefaultConfig.StateBloomSize,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -225,6 +245,11 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	TxHistoryFlag = cli.Uint64Flag{
+		Name:  "history.transactions",
+		Usage: "Number of recent blocks to retain transaction bodies for (default = retain all)",
+		Value: eth.DefaultConfig.TxHistory,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -272,6 +297,10 @@ var (
 		Usage: "Maximum number of light clients to serve, or light servers to attach to",
 		Value: eth.DefaultConfig.LightPeers,
 	}
+	LightPriorityTokenFlag = cli.StringFlag{
+		Name:  "light.prioritytoken",
+		Usage: "Hex encoded token presented to light servers for prioritized service",
+	}
 	UltraLightServersFlag = cli.StringFlag{
 		Name:  "ulc.servers",
 		Usage: "List of trusted ultra-light servers",
@@ -316,6 +345,21 @@ var (
 		Usage: "Number of recent ethash mining DAGs to keep on disk (1+GB each)",
 		Value: eth.DefaultConfig.Ethash.DatasetsOnDisk,
 	}
+	EthashDatasetIOLimitFlag = cli.Uint64Flag{
+		Name:  "ethash.dagiolimit",
+		Usage: "Maximum write throughput of ethash mining DAG generation in bytes per second (0 = unlimited)",
+		Value: eth.DefaultConfig.Ethash.GenerationIOLimitBytesPerSec,
+	}
+	EthashWorkFetchTimeoutFlag = cli.DurationFlag{
+		Name:  "ethash.worktimeout",
+		Usage: "Maximum time remote work requests wait for the miner, e.g. while it restarts",
+		Value: eth.DefaultConfig.Ethash.WorkFetchTimeout,
+	}
+	EthashSlowVerificationsFlag = cli.IntFlag{
+		Name:  "ethash.slowverifications",
+		Usage: "Number of slowest seal verifications to track for ethash_getSlowVerifications (0 = disabled)",
+		Value: eth.DefaultConfig.Ethash.SlowVerifications,
+	}
 	// Transaction pool settings
 	TxPoolLocalsFlag = cli.StringFlag{
 		Name:  "txpool.locals",
@@ -391,6 +435,10 @@ var (
 		Usage: "Percentage of cache memory allowance to use for trie pruning (default = 25% full mode, 0% archive mode)",
 		Value: 25,
 	}
+	CacheGCCapFlag = cli.IntFlag{
+		Name:  "cache.gc.cap",
+		Usage: "Hard memory cap (MB) of the trie pruning cache, flushing synchronously when exceeded (0 = disabled)",
+	}
 	CacheNoPrefetchFlag = cli.BoolFlag{
 		Name:  "cache.noprefetch",
 		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
@@ -466,6 +514,11 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerMaxTemplateAgeFlag = cli.DurationFlag{
+		Name:  "miner.maxtemplateage",
+		Usage: "Maximum age of the block being mined before it's recreated with a fresh timestamp (0 = disabled)",
+		Value: eth.DefaultConfig.Miner.MaxTemplateAge,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -507,6 +560,10 @@ var (
 		Name:  "nocompaction",
 		Usage: "Disables db compaction after import",
 	}
+	CompressChainFlag = cli.BoolFlag{
+		Name:  "compress",
+		Usage: "Compress exported chain data using zstd (import requires compressed input)",
+	}
 	// RPC settings
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
@@ -516,6 +573,10 @@ var (
 		Name:  "ipcpath",
 		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 	}
+	IPCAbstractFlag = cli.BoolFlag{
+		Name:  "ipc.abstract",
+		Usage: "Place the IPC socket in the Linux abstract namespace (same as an --ipcpath starting with @)",
+	}
 	RPCEnabledFlag = cli.BoolFlag{
 		Name:  "rpc",
 		Usage: "Enable the HTTP-RPC server",
@@ -601,6 +662,10 @@ var (
 		Name:  "preload",
 		Usage: "Comma separated list of JavaScript files to preload into the console",
 	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "authrpc.jwtsecret",
+		Usage: "Path to the hex encoded JWT secret used to authenticate with the Engine API",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -633,6 +698,11 @@ var (
 		Usage: "Comma separated enode URLs for P2P v5 discovery bootstrap (light server, light nodes)",
 		Value: "",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to use for peer discovery",
+		Value: "",
+	}
 	NodeKeyFileFlag = cli.StringFlag{
 		Name:  "nodekey",
 		Usage: "P2P node key file",
@@ -643,7 +713,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|pcp|extip:<IP>)",
 		Value: "any",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
@@ -712,22 +782,22 @@ var (
 	MetricsInfluxDBEndpointFlag = cli.StringFlag{
 		Name:  "metrics.influxdb.endpoint",
 		Usage: "InfluxDB API endpoint to report metrics to",
-		Value: "http://localhost:8086",
+		Value: metrics.DefaultConfig.InfluxDBEndpoint,
 	}
 	MetricsInfluxDBDatabaseFlag = cli.StringFlag{
 		Name:  "metrics.influxdb.database",
 		Usage: "InfluxDB database name to push reported metrics to",
-		Value: "geth",
+		Value: metrics.DefaultConfig.InfluxDBDatabase,
 	}
 	MetricsInfluxDBUsernameFlag = cli.StringFlag{
 		Name:  "metrics.influxdb.username",
 		Usage: "Username to authorize access to the database",
-		Value: "test",
+		Value: metrics.DefaultConfig.InfluxDBUsername,
 	}
 	MetricsInfluxDBPasswordFlag = cli.StringFlag{
 		Name:  "metrics.influxdb.password",
 		Usage: "Password to authorize access to the database",
-		Value: "test",
+		Value: metrics.DefaultConfig.InfluxDBPassword,
 	}
 	// Tags are part of every measurement sent to InfluxDB. Queries on tags are faster in InfluxDB.
 	// For example `host` tag could be used so that we can group all nodes and average a measurement
@@ -735,8 +805,55 @@ var (
 	// https://docs.influxdata.com/influxdb/v1.4/concepts/key_concepts/#tag-key
 	MetricsInfluxDBTagsFlag = cli.StringFlag{
 		Name:  "metrics.influxdb.tags",
-		Usage: "Comma-separated InfluxDB tags (key/values) attached to all measurements",
-		Value: "host=localhost",
+		Usage: "Comma-separated InfluxDB tags (key/values) attached to all measurements (default = host and chain)",
+		Value: metrics.DefaultConfig.InfluxDBTags,
+	}
+	MetricsInfluxDBIntervalFlag = cli.DurationFlag{
+		Name:  "metrics.influxdb.interval",
+		Usage: "Interval between two reports to InfluxDB",
+		Value: metrics.DefaultConfig.InfluxDBInterval,
+	}
+	MetricsEnableInfluxDBV2Flag = cli.BoolFlag{
+		Name:  "metrics.influxdbv2",
+		Usage: "Enable metrics export/push to an external InfluxDB v2 database (exclusive with --metrics.influxdb)",
+	}
+	MetricsInfluxDBTokenFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.token",
+		Usage: "Token to authorize access to the database (v2 only)",
+	}
+	MetricsInfluxDBBucketFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.bucket",
+		Usage: "InfluxDB bucket name to push reported metrics to (v2 only)",
+		Value: metrics.DefaultConfig.InfluxDBBucket,
+	}
+	MetricsInfluxDBOrganizationFlag = cli.StringFlag{
+		Name:  "metrics.influxdb.organization",
+		Usage: "InfluxDB organization name (v2 only)",
+		Value: metrics.DefaultConfig.InfluxDBOrganization,
+	}
+	MetricsEnablePrometheusFlag = cli.BoolFlag{
+		Name:  "metrics.prometheus",
+		Usage: "Enable the Prometheus metrics exposition endpoint",
+	}
+	MetricsPrometheusAddrFlag = cli.StringFlag{
+		Name:  "metrics.prometheus.addr",
+		Usage: "Prometheus endpoint listening interface",
+		Value: metrics.DefaultConfig.PrometheusAddr,
+	}
+	MetricsPrometheusPortFlag = cli.IntFlag{
+		Name:  "metrics.prometheus.port",
+		Usage: "Prometheus endpoint listening port",
+		Value: metrics.DefaultConfig.PrometheusPort,
+	}
+	MetricsPrometheusPathFlag = cli.StringFlag{
+		Name:  "metrics.prometheus.path",
+		Usage: "HTTP path the Prometheus metrics are served on",
+		Value: metrics.DefaultConfig.PrometheusPath,
+	}
+	MetricsPrometheusQuantilesFlag = cli.StringFlag{
+		Name:  "metrics.prometheus.quantiles",
+		Usage: "Comma-separated quantiles reported for histograms and timers",
+		Value: metrics.DefaultConfig.PrometheusQuantiles,
 	}
 
 	EWASMInterpreterFlag = cli.StringFlag{
@@ -749,6 +866,10 @@ var (
 		Usage: "External EVM configuration (default = built-in interpreter)",
 		Value: "",
 	}
+	PluginPathFlag = DirectoryFlag{
+		Name:  "plugin.path",
+		Usage: "Directory to load Go plugins (*.so) adding services to the node from",
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -870,6 +991,24 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setDNSDiscoveryURLs sets the DNS node lists used for peer discovery from
+// the command line flags.
+func setDNSDiscoveryURLs(ctx *cli.Context, cfg *p2p.Config) {
+	if !ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
+		return
+	}
+	cfg.DiscoveryURLs = nil
+	for _, url := range strings.Split(ctx.GlobalString(DNSDiscoveryFlag.Name), ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		if _, _, err := dnsdisc.ParseURL(url); err != nil {
+			Fatalf("Invalid DNS discovery URL %q: %v", url, err)
+		}
+		cfg.DiscoveryURLs = append(cfg.DiscoveryURLs, url)
+	}
+}
+
 // setListenAddress creates a TCP listening address string from set command
 // line flags.
 func setListenAddress(ctx *cli.Context, cfg *p2p.Config) {
@@ -964,12 +1103,21 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
 	CheckExclusive(ctx, IPCDisabledFlag, IPCPathFlag)
+	CheckExclusive(ctx, IPCDisabledFlag, IPCAbstractFlag)
 	switch {
 	case ctx.GlobalBool(IPCDisabledFlag.Name):
 		cfg.IPCPath = ""
 	case ctx.GlobalIsSet(IPCPathFlag.Name):
 		cfg.IPCPath = ctx.GlobalString(IPCPathFlag.Name)
 	}
+	if ctx.GlobalBool(IPCAbstractFlag.Name) {
+		if runtime.GOOS != "linux" {
+			Fatalf("Option %q is only supported on Linux", IPCAbstractFlag.Name)
+		}
+		if cfg.IPCPath != "" && !strings.HasPrefix(cfg.IPCPath, "@") {
+			cfg.IPCPath = "@" + cfg.IPCPath
+		}
+	}
 }
 
 // setLes configures the les server and ultra light client settings from the command line flags.
@@ -992,6 +1140,13 @@ func setLes(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(LightMaxPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightMaxPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightPriorityTokenFlag.Name) {
+		token, err := hexutil.Decode(ctx.GlobalString(LightPriorityTokenFlag.Name))
+		if err != nil {
+			Fatalf("Invalid light priority token: %v", err)
+		}
+		cfg.LightPriorityToken = token
+	}
 	if ctx.GlobalIsSet(UltraLightServersFlag.Name) {
 		cfg.UltraLightServers = strings.Split(ctx.GlobalString(UltraLightServersFlag.Name), ",")
 	}
@@ -1095,6 +1250,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setListenAddress(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	setBootstrapNodesV5(ctx, cfg)
+	setDNSDiscoveryURLs(ctx, cfg)
 
 	lightClient := ctx.GlobalString(SyncModeFlag.Name) == "light"
 	lightServer := (ctx.GlobalInt(LightLegacyServFlag.Name) != 0 || ctx.GlobalInt(LightServeFlag.Name) != 0)
@@ -1175,6 +1331,10 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		cfg.DatabaseBackend = ctx.GlobalString(DBEngineFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1299,6 +1459,15 @@ func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EthashDatasetsOnDiskFlag.Name) {
 		cfg.Ethash.DatasetsOnDisk = ctx.GlobalInt(EthashDatasetsOnDiskFlag.Name)
 	}
+	if ctx.GlobalIsSet(EthashDatasetIOLimitFlag.Name) {
+		cfg.Ethash.GenerationIOLimitBytesPerSec = ctx.GlobalUint64(EthashDatasetIOLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(EthashWorkFetchTimeoutFlag.Name) {
+		cfg.Ethash.WorkFetchTimeout = ctx.GlobalDuration(EthashWorkFetchTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(EthashSlowVerificationsFlag.Name) {
+		cfg.Ethash.SlowVerifications = ctx.GlobalInt(EthashSlowVerificationsFlag.Name)
+	}
 }
 
 func setMiner(ctx *cli.Context, cfg *miner.Config) {
@@ -1332,6 +1501,9 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerMaxTemplateAgeFlag.Name) {
+		cfg.MaxTemplateAge = ctx.GlobalDuration(MinerMaxTemplateAgeFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *eth.Config) {
@@ -1443,6 +1615,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
 	}
+	if ctx.GlobalIsSet(DBIOBudgetFlag.Name) {
+		cfg.DatabaseIOBudget = ctx.GlobalInt(DBIOBudgetFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBPruneBloomFlag.Name) {
+		cfg.StateBloomSize = ctx.GlobalUint64(DBPruneBloomFlag.Name)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1450,6 +1628,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GCModeFlag.Name) {
 		cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 	}
+	if ctx.GlobalIsSet(TxHistoryFlag.Name) {
+		cfg.TxHistory = ctx.GlobalUint64(TxHistoryFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
 	}
@@ -1459,6 +1640,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieDirtyCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheGCCapFlag.Name) {
+		cfg.TrieDirtyCap = ctx.GlobalInt(CacheGCCapFlag.Name)
+	}
 	if ctx.GlobalIsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.GlobalString(DocRootFlag.Name)
 	}
@@ -1555,6 +1739,17 @@ func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// RegisterPlugins loads the plugins in the directory given by --plugin.path and
+// registers their services on the node.
+func RegisterPlugins(ctx *cli.Context, stack *node.Node) {
+	if !ctx.GlobalIsSet(PluginPathFlag.Name) {
+		return
+	}
+	if err := node.LoadPlugins(stack, ctx.GlobalString(PluginPathFlag.Name)); err != nil {
+		Fatalf("Failed to load plugins: %v", err)
+	}
+}
+
 // RegisterEthStatsService configures the Ethereum Stats daemon and adds it to
 // the given node.
 func RegisterEthStatsService(stack *node.Node, url string) {
@@ -1593,25 +1788,127 @@ func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []st
 	}
 }
 
-func SetupMetrics(ctx *cli.Context) {
-	if metrics.Enabled {
-		log.Info("Enabling metrics collection")
-		var (
-			enableExport = ctx.GlobalBool(MetricsEnableInfluxDBFlag.Name)
-			endpoint     = ctx.GlobalString(MetricsInfluxDBEndpointFlag.Name)
-			database     = ctx.GlobalString(MetricsInfluxDBDatabaseFlag.Name)
-			username     = ctx.GlobalString(MetricsInfluxDBUsernameFlag.Name)
-			password     = ctx.GlobalString(MetricsInfluxDBPasswordFlag.Name)
-		)
+// EnableMetrics turns on the metrics system if requested on the command line. It
+// should be called as early as possible, before any data is collected.
+func EnableMetrics(ctx *cli.Context) {
+	if ctx.GlobalBool(MetricsEnabledFlag.Name) {
+		metrics.Enable()
+	}
+	if ctx.GlobalBool(MetricsEnabledExpensiveFlag.Name) {
+		metrics.EnableExpensive()
+	}
+}
 
-		if enableExport {
-			tagsMap := SplitTagsFlag(ctx.GlobalString(MetricsInfluxDBTagsFlag.Name))
+// SetMetricsConfig applies metrics related command line flags to the config.
+func SetMetricsConfig(ctx *cli.Context, cfg *metrics.Config) {
+	if ctx.GlobalIsSet(MetricsEnabledFlag.Name) {
+		cfg.Enabled = ctx.GlobalBool(MetricsEnabledFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsEnabledExpensiveFlag.Name) {
+		cfg.EnabledExpensive = ctx.GlobalBool(MetricsEnabledExpensiveFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsEnablePrometheusFlag.Name) {
+		cfg.EnablePrometheus = ctx.GlobalBool(MetricsEnablePrometheusFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsPrometheusAddrFlag.Name) {
+		cfg.PrometheusAddr = ctx.GlobalString(MetricsPrometheusAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsPrometheusPortFlag.Name) {
+		cfg.PrometheusPort = ctx.GlobalInt(MetricsPrometheusPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsPrometheusPathFlag.Name) {
+		cfg.PrometheusPath = ctx.GlobalString(MetricsPrometheusPathFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsPrometheusQuantilesFlag.Name) {
+		cfg.PrometheusQuantiles = ctx.GlobalString(MetricsPrometheusQuantilesFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBEndpointFlag.Name) {
+		cfg.InfluxDBEndpoint = ctx.GlobalString(MetricsInfluxDBEndpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBTagsFlag.Name) {
+		cfg.InfluxDBTags = ctx.GlobalString(MetricsInfluxDBTagsFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBIntervalFlag.Name) {
+		cfg.InfluxDBInterval = ctx.GlobalDuration(MetricsInfluxDBIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsEnableInfluxDBFlag.Name) {
+		cfg.EnableInfluxDB = ctx.GlobalBool(MetricsEnableInfluxDBFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBDatabaseFlag.Name) {
+		cfg.InfluxDBDatabase = ctx.GlobalString(MetricsInfluxDBDatabaseFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBUsernameFlag.Name) {
+		cfg.InfluxDBUsername = ctx.GlobalString(MetricsInfluxDBUsernameFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBPasswordFlag.Name) {
+		cfg.InfluxDBPassword = ctx.GlobalString(MetricsInfluxDBPasswordFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsEnableInfluxDBV2Flag.Name) {
+		cfg.EnableInfluxDBV2 = ctx.GlobalBool(MetricsEnableInfluxDBV2Flag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBTokenFlag.Name) {
+		cfg.InfluxDBToken = ctx.GlobalString(MetricsInfluxDBTokenFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBBucketFlag.Name) {
+		cfg.InfluxDBBucket = ctx.GlobalString(MetricsInfluxDBBucketFlag.Name)
+	}
+	if ctx.GlobalIsSet(MetricsInfluxDBOrganizationFlag.Name) {
+		cfg.InfluxDBOrganization = ctx.GlobalString(MetricsInfluxDBOrganizationFlag.Name)
+	}
+	if err := cfg.Validate(); err != nil {
+		Fatalf("Invalid metrics configuration: %v", err)
+	}
+}
 
+// SetupMetrics enables the metrics collection and starts the exporters requested
+// in the config. The measurements sent to InfluxDB are tagged with the host name
+// and the given chain, unless the configured tags override them.
+func SetupMetrics(cfg *metrics.Config, chain string) {
+	if cfg.Enabled {
+		metrics.Enable()
+	}
+	if cfg.EnabledExpensive {
+		metrics.EnableExpensive()
+	}
+	if !metrics.Enabled() {
+		return
+	}
+	if cfg.EnableInfluxDB || cfg.EnableInfluxDBV2 {
+		tags := map[string]string{"chain": chain}
+		if host, err := os.Hostname(); err == nil {
+			tags["host"] = host
+		}
+		for key, value := range SplitTagsFlag(cfg.InfluxDBTags) {
+			tags[key] = value
+		}
+		if cfg.EnableInfluxDBV2 {
+			log.Info("Enabling metrics export to InfluxDB (v2)")
+			go influxdb.InfluxDBV2WithTags(metrics.DefaultRegistry, cfg.InfluxDBInterval, cfg.InfluxDBEndpoint, cfg.InfluxDBToken, cfg.InfluxDBBucket, cfg.InfluxDBOrganization, "geth.", tags)
+		} else {
 			log.Info("Enabling metrics export to InfluxDB")
-
-			go influxdb.InfluxDBWithTags(metrics.DefaultRegistry, 10*time.Second, endpoint, database, username, password, "geth.", tagsMap)
+			go influxdb.InfluxDBWithTags(metrics.DefaultRegistry, cfg.InfluxDBInterval, cfg.InfluxDBEndpoint, cfg.InfluxDBDatabase, cfg.InfluxDBUsername, cfg.InfluxDBPassword, "geth.", tags)
 		}
 	}
+	if cfg.EnablePrometheus {
+		quantiles, err := prometheus.ParseQuantiles(cfg.PrometheusQuantiles)
+		if err != nil {
+			Fatalf("Invalid Prometheus quantiles: %v", err)
+		}
+		var (
+			address = fmt.Sprintf("%s:%d", cfg.PrometheusAddr, cfg.PrometheusPort)
+			mux     = http.NewServeMux()
+		)
+		mux.Handle(cfg.PrometheusPath, prometheus.NewHandler(metrics.DefaultRegistry, "geth_", quantiles))
+
+		log.Info("Enabling Prometheus metrics endpoint", "url", fmt.Sprintf("http://%s%s", address, cfg.PrometheusPath))
+		go func() {
+			if err := http.ListenAndServe(address, mux); err != nil {
+				log.Error("Failure in running Prometheus metrics endpoint", "err", err)
+			}
+		}()
+	}
+	// Start system runtime metrics collection
+	go metrics.CollectProcessMetrics(3 * time.Second)
 }
 
 func SplitTagsFlag(tagsFlag string) map[string]string {
@@ -0,0 +1,74 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// Tests that services registered by plugins are started and their APIs can be
+// called. The plugin is built from testdata, and must live outside of package
+// node as its in-package tests change the identity of the package.
+func TestLoadPlugins(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the test plugin is slow")
+	}
+	dir, err := ioutil.TempDir("", "geth-plugin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gobin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	build := exec.Command(gobin, "build", "-buildmode=plugin", "-o", filepath.Join(dir, "hello.so"), "./testdata/plugin")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("plugins not supported: %v\n%s", err, out)
+	}
+	stack, err := node.New(&node.Config{P2P: p2p.Config{NoDiscovery: true, ListenAddr: "127.0.0.1:0"}})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	if err := node.LoadPlugins(stack, dir); err != nil {
+		t.Fatalf("failed to load plugins: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	defer client.Close()
+
+	var result string
+	if err := client.Call(&result, "foo_hello"); err != nil {
+		t.Fatalf("failed to call plugin API: %v", err)
+	}
+	if result != "hello from plugin" {
+		t.Errorf("result mismatch: have %q, want %q", result, "hello from plugin")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// This is a test plugin registering a service with the foo_hello RPC method.
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Plugin is the symbol looked up by the node.
+var Plugin node.Plugin = helloPlugin{}
+
+type helloPlugin struct{}
+
+func (helloPlugin) Manifest() node.PluginManifest {
+	return node.PluginManifest{Name: "hello", Version: "1.0.0", ABIVersion: node.PluginABIVersion}
+}
+
+func (helloPlugin) RegisterServices(stack *node.Node) error {
+	return stack.Register(func(*node.ServiceContext) (node.Service, error) {
+		return helloService{}, nil
+	})
+}
+
+type helloService struct{}
+
+func (helloService) Protocols() []p2p.Protocol { return nil }
+func (helloService) Start(*p2p.Server) error   { return nil }
+func (helloService) Stop() error               { return nil }
+
+func (helloService) APIs() []rpc.API {
+	return []rpc.API{{Namespace: "foo", Version: "1.0", Service: helloAPI{}, Public: true}}
+}
+
+type helloAPI struct{}
+
+func (helloAPI) Hello() string {
+	return "hello from plugin"
+}
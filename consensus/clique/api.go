@@ -17,9 +17,11 @@
 package clique
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -120,58 +122,111 @@ func (api *API) Discard(address common.Address) {
 	delete(api.clique.proposals, address)
 }
 
+const (
+	// defaultStatusBlocks is the number of recent blocks Status inspects if no
+	// count is requested.
+	defaultStatusBlocks = 64
+
+	// maxStatusBlocks is the maximum number of recent blocks Status inspects.
+	maxStatusBlocks = 8192
+)
+
+var errInvalidStatusBlocks = errors.New("invalid number of status blocks")
+
 type status struct {
-	InturnPercent float64                `json:"inturnPercent"`
-	SigningStatus map[common.Address]int `json:"sealerActivity"`
-	NumBlocks     uint64                 `json:"numBlocks"`
+	InturnPercent float64                 `json:"inturnPercent"`
+	SigningStatus map[common.Address]int  `json:"sealerActivity"`
+	NumBlocks     uint64                  `json:"numBlocks"`
+	Signers       []common.Address        `json:"signers"`
+	IdleSigners   []common.Address        `json:"idleSigners"`
+	Proposals     map[common.Address]bool `json:"proposals"`
 }
 
-// Status returns the status of the last N blocks,
-// - the number of active signers,
-// - the number of signers,
-// - the percentage of in-turn blocks
-func (api *API) Status() (*status, error) {
-	var (
-		numBlocks = uint64(64)
-		header    = api.chain.CurrentHeader()
-		diff      = uint64(0)
-		optimals  = 0
-	)
+// Status returns the sealing activity over the last N blocks (64 by default):
+// - the number of blocks sealed by each signer,
+// - the percentage of in-turn blocks,
+// - the currently authorized signers,
+// - the signers which haven't sealed any of the blocks they are expected to
+//   seal at least one of, i.e. the last two rounds of in-turn slots,
+// - the current proposals of the node.
+//
+// The sealers are recovered from the header signatures, which are cached.
+func (api *API) Status(numBlocks *hexutil.Uint64) (*status, error) {
+	blocks := uint64(defaultStatusBlocks)
+	if numBlocks != nil {
+		blocks = uint64(*numBlocks)
+	}
+	if blocks == 0 || blocks > maxStatusBlocks {
+		return nil, fmt.Errorf("%w: %d, must be in [1, %d]", errInvalidStatusBlocks, blocks, maxStatusBlocks)
+	}
+	header := api.chain.CurrentHeader()
 	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
 	if err != nil {
 		return nil, err
 	}
 	var (
 		signers = snap.signers()
-		end     = header.Number.Uint64()
-		start   = end - numBlocks
+		head    = header.Number.Uint64()
+		window  = 2 * uint64(len(signers)) // Blocks an active signer seals at least one of
+		span    = blocks                   // Blocks to inspect, covering the idleness window
 	)
-	if numBlocks > end {
-		start = 1
-		numBlocks = end - start
+	if span < window {
+		span = window
+	}
+	// The genesis block isn't sealed, so it can't be inspected
+	if blocks > head {
+		blocks = head
+	}
+	if span > head {
+		span = head
 	}
-	signStatus := make(map[common.Address]int)
+	var (
+		optimals   = 0
+		signStatus = make(map[common.Address]int)
+		lastSealed = make(map[common.Address]uint64) // Most recent block sealed by each signer
+	)
 	for _, s := range signers {
 		signStatus[s] = 0
 	}
-	for n := start; n < end; n++ {
-		h := api.chain.GetHeaderByNumber(n)
-		if h == nil {
-			return nil, fmt.Errorf("missing block %d", n)
-		}
-		if h.Difficulty.Cmp(diffInTurn) == 0 {
-			optimals++
-		}
-		diff += h.Difficulty.Uint64()
+	for h := header; h.Number.Uint64() > head-span; {
+		number := h.Number.Uint64()
 		sealer, err := api.clique.Author(h)
 		if err != nil {
 			return nil, err
 		}
-		signStatus[sealer]++
+		if number > head-blocks {
+			if h.Difficulty.Cmp(diffInTurn) == 0 {
+				optimals++
+			}
+			signStatus[sealer]++
+		}
+		if _, ok := lastSealed[sealer]; !ok {
+			lastSealed[sealer] = number
+		}
+		if h = api.chain.GetHeader(h.ParentHash, number-1); h == nil {
+			return nil, fmt.Errorf("missing block %d", number-1)
+		}
+	}
+	// Signers are idle if they missed the whole window. If the chain is shorter
+	// than the window, there's no telling yet.
+	idle := []common.Address{}
+	if span >= window {
+		for _, s := range signers {
+			if last, ok := lastSealed[s]; !ok || head-last >= window {
+				idle = append(idle, s)
+			}
+		}
+	}
+	var inturn float64
+	if blocks > 0 {
+		inturn = float64(100*optimals) / float64(blocks)
 	}
 	return &status{
-		InturnPercent: float64((100 * optimals)) / float64(numBlocks),
+		InturnPercent: inturn,
 		SigningStatus: signStatus,
-		NumBlocks:     numBlocks,
+		NumBlocks:     blocks,
+		Signers:       signers,
+		IdleSigners:   idle,
+		Proposals:     api.Proposals(),
 	}, nil
 }
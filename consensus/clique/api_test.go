@@ -0,0 +1,141 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the status reports the sealing activity of the signers, including
+// the ones not sealing at all.
+func TestStatus(t *testing.T) {
+	// Create three signers, the last of which never seals
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(crypto.PubkeyToAddress(keys[i].PublicKey).Bytes(), crypto.PubkeyToAddress(keys[j].PublicKey).Bytes()) < 0
+	})
+	signers := make([]common.Address, len(keys))
+	for i, key := range keys {
+		signers[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	idle := 2
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		engine = New(params.AllCliqueProtocolChanges.Clique, db)
+	)
+	genspec := &core.Genesis{ExtraData: make([]byte, extraVanity+len(signers)*common.AddressLength+extraSeal)}
+	for i, signer := range signers {
+		copy(genspec.ExtraData[extraVanity+i*common.AddressLength:], signer[:])
+	}
+	genesis := genspec.MustCommit(db)
+
+	// Generate a chain sealed alternately by the two active signers, as the
+	// recent signer limit forbids a signer to seal twice in a row
+	blocks, _ := core.GenerateChain(params.AllCliqueProtocolChanges, genesis, engine, db, 20, func(i int, block *core.BlockGen) {
+		block.SetDifficulty(diffInTurn)
+	})
+	var (
+		sealed = make(map[common.Address]int)
+		inturn = 0
+	)
+	for i, block := range blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		number := header.Number.Uint64()
+		sealer := int(number % 2)
+
+		header.Extra = make([]byte, extraVanity+extraSeal)
+		header.Difficulty = diffNoTurn
+		if number%uint64(len(signers)) == uint64(sealer) {
+			header.Difficulty = diffInTurn
+		}
+		// Count the last ten blocks for the expected status
+		if number > 10 {
+			sealed[signers[sealer]]++
+			if header.Difficulty.Cmp(diffInTurn) == 0 {
+				inturn++
+			}
+		}
+		sig, _ := crypto.Sign(SealHash(header).Bytes(), keys[sealer])
+		copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+		blocks[i] = block.WithSeal(header)
+	}
+	chain, _ := core.NewBlockChain(db, nil, params.AllCliqueProtocolChanges, engine, vm.Config{}, nil)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := &API{chain: chain, clique: engine}
+	api.Propose(common.Address{0x01}, true)
+
+	numBlocks := hexutil.Uint64(10)
+	status, err := api.Status(&numBlocks)
+	if err != nil {
+		t.Fatalf("failed to retrieve status: %v", err)
+	}
+	if status.NumBlocks != 10 {
+		t.Errorf("block count mismatch: have %d, want %d", status.NumBlocks, 10)
+	}
+	want := map[common.Address]int{signers[0]: sealed[signers[0]], signers[1]: sealed[signers[1]], signers[idle]: 0}
+	if !reflect.DeepEqual(status.SigningStatus, want) {
+		t.Errorf("sealer activity mismatch: have %v, want %v", status.SigningStatus, want)
+	}
+	if percent := float64(100*inturn) / 10; status.InturnPercent != percent {
+		t.Errorf("in-turn percentage mismatch: have %v, want %v", status.InturnPercent, percent)
+	}
+	if !reflect.DeepEqual(status.Signers, signers) {
+		t.Errorf("signers mismatch: have %v, want %v", status.Signers, signers)
+	}
+	if want := []common.Address{signers[idle]}; !reflect.DeepEqual(status.IdleSigners, want) {
+		t.Errorf("idle signers mismatch: have %v, want %v", status.IdleSigners, want)
+	}
+	if want := map[common.Address]bool{{0x01}: true}; !reflect.DeepEqual(status.Proposals, want) {
+		t.Errorf("proposals mismatch: have %v, want %v", status.Proposals, want)
+	}
+	// The default number of blocks is capped by the chain length, while oversized
+	// requests are rejected
+	if status, err = api.Status(nil); err != nil {
+		t.Fatalf("failed to retrieve default status: %v", err)
+	}
+	if status.NumBlocks != 20 {
+		t.Errorf("default block count mismatch: have %d, want %d", status.NumBlocks, 20)
+	}
+	numBlocks = maxStatusBlocks + 1
+	if _, err := api.Status(&numBlocks); !errors.Is(err, errInvalidStatusBlocks) {
+		t.Errorf("oversized status error mismatch: have %v, want %v", err, errInvalidStatusBlocks)
+	}
+}
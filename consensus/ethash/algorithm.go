@@ -17,6 +17,7 @@
 package ethash
 
 import (
+	"context"
 	"encoding/binary"
 	"hash"
 	"math/big"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"golang.org/x/crypto/sha3"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -137,19 +139,42 @@ func seedHash(block uint64) []byte {
 // set of 524288 64-byte values.
 // This method places the result into dest in machine byte order.
 func generateCache(dest []uint32, epoch uint64, seed []byte) {
+	generateCacheAbortable(dest, epoch, seed, nil)
+}
+
+// cacheAbortInterval is the number of cache rows between checks of the abort
+// channel during cache generation.
+const cacheAbortInterval = 4096
+
+// generateCacheAbortable is like generateCache, but stops generating as soon
+// as the abort channel is closed. The return value reports whether generation
+// completed; the content of dest is undefined if it did not.
+func generateCacheAbortable(dest []uint32, epoch uint64, seed []byte, abort <-chan struct{}) bool {
 	// Print some debug logs to allow analysis on low end devices
 	logger := log.New("epoch", epoch)
 
 	start := time.Now()
+	aborted := false
 	defer func() {
 		elapsed := time.Since(start)
-
+		if aborted {
+			logger.Debug("Aborted ethash verification cache generation", "elapsed", common.PrettyDuration(elapsed))
+			return
+		}
 		logFn := logger.Debug
 		if elapsed > 3*time.Second {
 			logFn = logger.Info
 		}
 		logFn("Generated ethash verification cache", "elapsed", common.PrettyDuration(elapsed))
 	}()
+	shouldAbort := func() bool {
+		select {
+		case <-abort:
+			aborted = true
+		default:
+		}
+		return aborted
+	}
 	// Convert our destination slice to a byte buffer
 	header := *(*reflect.SliceHeader)(unsafe.Pointer(&dest))
 	header.Len *= 4
@@ -182,6 +207,9 @@ func generateCache(dest []uint32, epoch uint64, seed []byte) {
 	// Sequentially produce the initial dataset
 	keccak512(cache, seed)
 	for offset := uint64(hashBytes); offset < size; offset += hashBytes {
+		if (offset/hashBytes)%cacheAbortInterval == 1 && shouldAbort() {
+			return false
+		}
 		keccak512(cache[offset:], cache[offset-hashBytes:offset])
 		atomic.AddUint32(&progress, 1)
 	}
@@ -190,6 +218,9 @@ func generateCache(dest []uint32, epoch uint64, seed []byte) {
 
 	for i := 0; i < cacheRounds; i++ {
 		for j := 0; j < rows; j++ {
+			if j%cacheAbortInterval == 0 && shouldAbort() {
+				return false
+			}
 			var (
 				srcOff = ((j - 1 + rows) % rows) * hashBytes
 				dstOff = j * hashBytes
@@ -205,6 +236,7 @@ func generateCache(dest []uint32, epoch uint64, seed []byte) {
 	if !isLittleEndian() {
 		swap(cache)
 	}
+	return true
 }
 
 // swap changes the byte order of the buffer assuming a uint32 representation.
@@ -262,9 +294,25 @@ func generateDatasetItem(cache []uint32, index uint32, keccak512 hasher) []byte
 	return mix
 }
 
+// datasetLimiterChunk is the number of dataset bytes generated by a thread between
+// two waits on the I/O limiter.
+const datasetLimiterChunk = 64 * 1024
+
+// newDatasetLimiter creates a rate limiter capping the dataset generation to the
+// given number of bytes per second.
+func newDatasetLimiter(bytesPerSec uint64) *rate.Limiter {
+	// The burst must fit a whole chunk, otherwise waiting for it would fail
+	burst := uint64(datasetLimiterChunk)
+	if bytesPerSec > burst {
+		burst = bytesPerSec
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), int(burst))
+}
+
 // generateDataset generates the entire ethash dataset for mining.
-// This method places the result into dest in machine byte order.
-func generateDataset(dest []uint32, epoch uint64, cache []uint32) {
+// This method places the result into dest in machine byte order. If limiter is
+// non-nil, the generation is throttled to the number of bytes it allows.
+func generateDataset(dest []uint32, epoch uint64, cache []uint32, limiter *rate.Limiter) {
 	// Print some debug logs to allow analysis on low end devices
 	logger := log.New("epoch", epoch)
 
@@ -312,6 +360,7 @@ func generateDataset(dest []uint32, epoch uint64, cache []uint32) {
 			}
 			// Calculate the dataset segment
 			percent := uint32(size / hashBytes / 100)
+			pending := 0 // Bytes generated since the last wait on the limiter
 			for index := first; index < limit; index++ {
 				item := generateDatasetItem(cache, index, keccak512)
 				if swapped {
@@ -319,6 +368,12 @@ func generateDataset(dest []uint32, epoch uint64, cache []uint32) {
 				}
 				copy(dataset[index*hashBytes:], item)
 
+				if limiter != nil {
+					if pending += hashBytes; pending == datasetLimiterChunk || index == limit-1 {
+						limiter.WaitN(context.Background(), pending)
+						pending = 0
+					}
+				}
 				if status := atomic.AddUint32(&progress, 1); status%percent == 0 {
 					logger.Info("Generating DAG in progress", "percentage", uint64(status*100)/(size/hashBytes), "elapsed", common.PrettyDuration(time.Since(start)))
 				}
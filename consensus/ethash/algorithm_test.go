@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -651,7 +652,7 @@ func TestDatasetGeneration(t *testing.T) {
 		generateCache(cache, tt.epoch, seedHash(tt.epoch*epochLength+1))
 
 		dataset := make([]uint32, tt.datasetSize/4)
-		generateDataset(dataset, tt.epoch, cache)
+		generateDataset(dataset, tt.epoch, cache, nil)
 
 		want := make([]uint32, tt.datasetSize/4)
 		prepare(want, tt.dataset)
@@ -662,6 +663,29 @@ func TestDatasetGeneration(t *testing.T) {
 	}
 }
 
+// Tests that throttling the dataset generation slows it down to the requested
+// rate, without changing the generated content.
+func TestDatasetGenerationLimit(t *testing.T) {
+	cache := make([]uint32, 1024/4)
+	generateCache(cache, 0, make([]byte, 32))
+
+	want := make([]uint32, 128*1024/4)
+	generateDataset(want, 0, cache, nil)
+
+	// The limiter allows the first 64KB right away, the rest takes a second
+	start := time.Now()
+
+	dataset := make([]uint32, 128*1024/4)
+	generateDataset(dataset, 0, cache, newDatasetLimiter(64*1024))
+
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("throttled generation too fast: %v", elapsed)
+	}
+	if !reflect.DeepEqual(dataset, want) {
+		t.Errorf("throttled dataset content mismatch")
+	}
+}
+
 // Tests whether the hashimoto lookup works for both light as well as the full
 // datasets.
 func TestHashimoto(t *testing.T) {
@@ -670,7 +694,7 @@ func TestHashimoto(t *testing.T) {
 	generateCache(cache, 0, make([]byte, 32))
 
 	dataset := make([]uint32, 32*1024/4)
-	generateDataset(dataset, 0, cache)
+	generateDataset(dataset, 0, cache, nil)
 
 	// Create a block to verify
 	hash := hexutil.MustDecode("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
@@ -729,7 +753,7 @@ func TestConcurrentDiskCacheGeneration(t *testing.T) {
 
 		go func(idx int) {
 			defer pend.Done()
-			ethash := New(Config{cachedir, 0, 1, "", 0, 0, ModeNormal, nil}, nil, false)
+			ethash := New(Config{cachedir, 0, 1, "", 0, 0, ModeNormal, 0, "", false, 0, 0, 0, 0, nil, nil, nil}, nil, false)
 			defer ethash.Close()
 			if err := ethash.VerifySeal(nil, block.Header()); err != nil {
 				t.Errorf("proc %d: block verification failed: %v", idx, err)
@@ -755,7 +779,7 @@ func BenchmarkSmallDatasetGeneration(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		dataset := make([]uint32, 32*65536/4)
-		generateDataset(dataset, 0, cache)
+		generateDataset(dataset, 0, cache, nil)
 	}
 }
 
@@ -778,7 +802,7 @@ func BenchmarkHashimotoFullSmall(b *testing.B) {
 	generateCache(cache, 0, make([]byte, 32))
 
 	dataset := make([]uint32, 32*65536/4)
-	generateDataset(dataset, 0, cache)
+	generateDataset(dataset, 0, cache, nil)
 
 	hash := hexutil.MustDecode("0xc9149cc0386e689d789a1c2f3d5d169a61a6218ed30e74414dc736e442ef3d1f")
 
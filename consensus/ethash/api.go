@@ -18,47 +18,111 @@ package ethash
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
-var errEthashStopped = errors.New("ethash stopped")
+var (
+	errEthashStopped     = errors.New("ethash stopped")
+	errInvalidPartitions = errors.New("invalid number of work partitions")
+	errInvalidHistory    = errors.New("invalid number of history blocks")
+	errInvalidWorkSig    = errors.New("invalid work signature")
+	errInvalidBlockTime  = errors.New("invalid average block time")
+	errEpochTimeOverflow = errors.New("epoch time estimate overflows")
+	errUnknownTip        = errors.New("chain head unknown")
+	errChainSyncing      = errors.New("chain syncing")
+	errMalformedPowHash  = errors.New("malformed input: pow-hash is not a 32 byte hex value")
+	errMalformedDigest   = errors.New("malformed input: mix digest is not a 32 byte hex value")
+	errNotTestMode       = errors.New("only supported in test mode")
+	errNotDevMode        = errors.New("only supported in fake and test modes")
+	errMinerInitializing = errors.New("miner initializing")
+	errInvalidSealRange  = errors.New("invalid seal verification range")
+	errSealRangeBusy     = errors.New("seal range verification already running")
+)
+
+// maxWorkPartitions is the maximum number of nonce ranges a work package can be
+// split into.
+const maxWorkPartitions = 1024
+
+// maxDifficultyHistory is the maximum number of blocks the difficulty history
+// can be requested for, bounding the number of headers retrieved per call.
+const maxDifficultyHistory = 1024
+
+// maxSealVerifyRange is the maximum number of blocks whose seals can be verified
+// in a single call, bounding the time taken by it.
+const maxSealVerifyRange = 1024
 
-// API exposes ethash related methods for the RPC interface.
+// API exposes ethash related methods for the RPC interface. It's limited to the
+// remote mining methods, which are mirrored into the eth and parity namespaces
+// for the sake of existing miners.
 type API struct {
 	ethash *Ethash
+	chain  consensus.ChainReader
+}
+
+// EthashAPI exposes ethash methods for inspecting the engine and the current
+// work, which are only available through the ethash RPC namespace.
+type EthashAPI struct {
+	ethash *Ethash
+	chain  consensus.ChainReader
+}
+
+// remote returns the mining API the work related methods are built upon.
+func (api *EthashAPI) remote() *API {
+	return &API{api.ethash, api.chain}
+}
+
+// PrivateAPI exposes ethash methods changing the behaviour of the engine or
+// consuming significant resources, which are only available through the private
+// ethash RPC namespace.
+type PrivateAPI struct {
+	ethash *Ethash
+	chain  consensus.ChainReader
 }
 
 // GetWork returns a work package for external miner.
 //
 // The work package consists of 3 strings:
-//   result[0] - 32 bytes hex encoded current block header pow-hash
-//   result[1] - 32 bytes hex encoded seed hash used for DAG
-//   result[2] - 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
-//   result[3] - hex encoded block number
-//   result[4], 32 bytes hex encoded parent block header pow-hash
-//   result[5], hex encoded gas limit
-//   result[6], hex encoded gas used
-//   result[7], hex encoded transaction count
-//   result[8], hex encoded uncle count
-//   result[9], RLP encoded header with additonal empty extra data bytes
+//
+//	result[0] - 32 bytes hex encoded current block header pow-hash
+//	result[1] - 32 bytes hex encoded seed hash used for DAG
+//	result[2] - 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
+//	result[3] - hex encoded block number
+//	result[4], 32 bytes hex encoded parent block header pow-hash
+//	result[5], hex encoded gas limit
+//	result[6], hex encoded gas used
+//	result[7], hex encoded transaction count
+//	result[8], hex encoded uncle count
+//	result[9], RLP encoded header with additonal empty extra data bytes
 func (api *API) GetWork() ([10]string, error) {
 	if api.ethash.remote == nil {
 		return [10]string{}, errors.New("not supported")
 	}
+	if api.ethash.chainSyncing() {
+		return [10]string{}, errChainSyncing
+	}
 
 	var (
 		workCh = make(chan [10]string, 1)
 		errc   = make(chan error, 1)
 	)
-	select {
-	case api.ethash.remote.fetchWorkCh <- &sealWork{errc: errc, res: workCh}:
-	case <-api.ethash.remote.exitCh:
-		return [10]string{}, errEthashStopped
+	if err := api.fetchWork(&sealWork{errc: errc, res: workCh}); err != nil {
+		return [10]string{}, err
 	}
 	select {
 	case work := <-workCh:
@@ -68,6 +132,144 @@ func (api *API) GetWork() ([10]string, error) {
 	}
 }
 
+// fetchWork hands a work request over to the remote sealer. If the sealer doesn't
+// pick it up within the configured timeout, e.g. because the miner is restarting,
+// errMinerInitializing is returned rather than blocking the RPC handler.
+func (api *API) fetchWork(req *sealWork) error {
+	timeout := api.ethash.config.WorkFetchTimeout
+	if timeout <= 0 {
+		timeout = DefaultWorkFetchTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case api.ethash.remote.fetchWorkCh <- req:
+		return nil
+	case <-api.ethash.remote.exitCh:
+		return errEthashStopped
+	case <-timer.C:
+		return errMinerInitializing
+	}
+}
+
+// GetWorkHashingInput returns the RLP encoded header of the current work, with
+// the nonce and mix digest omitted. Its Keccak256 hash is the pow-hash returned
+// as the first element of GetWork, which allows external miners to check that
+// they hash the right data.
+func (api *EthashAPI) GetWorkHashingInput() (hexutil.Bytes, error) {
+	if api.ethash.remote == nil {
+		return nil, errors.New("not supported")
+	}
+	if api.ethash.chainSyncing() {
+		return nil, errChainSyncing
+	}
+
+	var (
+		inputCh = make(chan []byte, 1)
+		errc    = make(chan error, 1)
+	)
+	if err := api.remote().fetchWork(&sealWork{errc: errc, input: inputCh}); err != nil {
+		return nil, err
+	}
+	select {
+	case input := <-inputCh:
+		return input, nil
+	case err := <-errc:
+		return nil, err
+	}
+}
+
+// WorkBinaryLength is the size of the binary work encoding returned by
+// GetWorkBinary.
+const WorkBinaryLength = 3*common.HashLength + 8
+
+// GetWorkBinary returns the essential fields of the current work package in a
+// fixed binary layout, which is less than half the size of the JSON encoding of
+// GetWork and can be parsed without a JSON decoder. The layout of the 104 bytes is:
+//
+//	[0:32]   - block header pow-hash
+//	[32:64]  - seed hash used for DAG
+//	[64:96]  - boundary condition ("target"), 2^256/difficulty, big endian
+//	[96:104] - block number, big endian uint64
+func (api *EthashAPI) GetWorkBinary() (hexutil.Bytes, error) {
+	work, err := api.remote().GetWork()
+	if err != nil {
+		return nil, err
+	}
+	return encodeWorkBinary(work)
+}
+
+// encodeWorkBinary converts a work package returned by GetWork into the binary
+// layout of GetWorkBinary.
+func encodeWorkBinary(work [10]string) ([]byte, error) {
+	number, err := hexutil.DecodeUint64(work[3])
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, WorkBinaryLength)
+	for i := 0; i < 3; i++ {
+		hash := common.HexToHash(work[i])
+		copy(blob[i*common.HashLength:], hash[:])
+	}
+	binary.BigEndian.PutUint64(blob[3*common.HashLength:], number)
+	return blob, nil
+}
+
+// GetBlockReward returns the static block reward in wei for sealing the current
+// work package, as defined by the forks active at its height. Rewards for
+// included uncles are not part of it.
+func (api *EthashAPI) GetBlockReward() (*hexutil.Big, error) {
+	if api.chain == nil {
+		return nil, errors.New("not supported")
+	}
+	work, err := api.remote().GetWork()
+	if err != nil {
+		return nil, err
+	}
+	number, err := hexutil.DecodeBig(work[3])
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(new(big.Int).Set(staticBlockReward(api.chain.Config(), number))), nil
+}
+
+// PartitionedWork is a work package paired with a suggested nonce range, which
+// allows splitting the nonce space of a single work across multiple devices.
+type PartitionedWork struct {
+	Work       [10]string     `json:"work"`
+	StartNonce hexutil.Uint64 `json:"startNonce"`
+	EndNonce   hexutil.Uint64 `json:"endNonce"`
+}
+
+// GetWorkPartitioned returns the current work package split into the requested
+// number of partitions. All partitions share the same pow-hash, but each carries
+// a distinct, non-overlapping nonce range hint. The ranges together cover the
+// entire nonce space.
+func (api *EthashAPI) GetWorkPartitioned(parts hexutil.Uint64) ([]PartitionedWork, error) {
+	if parts == 0 || parts > maxWorkPartitions {
+		return nil, errInvalidPartitions
+	}
+	work, err := api.remote().GetWork()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		size  = math.MaxUint64 / uint64(parts)
+		works = make([]PartitionedWork, parts)
+	)
+	for i := range works {
+		works[i] = PartitionedWork{
+			Work:       work,
+			StartNonce: hexutil.Uint64(uint64(i) * size),
+			EndNonce:   hexutil.Uint64(uint64(i+1)*size - 1),
+		}
+	}
+	// Extend the last range to the end of the nonce space
+	works[parts-1].EndNonce = math.MaxUint64
+	return works, nil
+}
+
 // NewWorks send a notification each time a new work is available for mining.
 func (api *API) NewWorks(ctx context.Context) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -102,13 +304,44 @@ func (api *API) NewWorks(ctx context.Context) (*rpc.Subscription, error) {
 // It returns an indication if the work was accepted.
 // Note either an invalid solution, a stale work a non-existent work will return false.
 func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash, extraNonceStr *string) bool {
+	return api.submitWork(nonce, hash, digest, extraNonceStr, common.Hash{})
+}
+
+// powHash is the pow-hash of a detailed work submission. It only decodes from
+// 0x prefixed hex encodings of exactly 32 bytes, rejecting anything else with a
+// malformed input error before the submission is processed.
+type powHash common.Hash
+
+// UnmarshalJSON parses a pow-hash in hex syntax.
+func (h *powHash) UnmarshalJSON(input []byte) error {
+	if err := (*common.Hash)(h).UnmarshalJSON(input); err != nil {
+		return errMalformedPowHash
+	}
+	return nil
+}
+
+// mixDigest is the mix digest of a detailed work submission, decoded as strictly
+// as the pow-hash.
+type mixDigest common.Hash
+
+// UnmarshalJSON parses a mix digest in hex syntax.
+func (d *mixDigest) UnmarshalJSON(input []byte) error {
+	if err := (*common.Hash)(d).UnmarshalJSON(input); err != nil {
+		return errMalformedDigest
+	}
+	return nil
+}
+
+// submitWork submits a POW solution on behalf of the given miner, zero if unknown.
+func (api *API) submitWork(nonce types.BlockNonce, hash, digest common.Hash, extraNonceStr *string, miner common.Hash) bool {
 	if api.ethash.remote == nil {
 		return false
 	}
-
-	var extraNonce []byte
+	var (
+		extraNonce []byte
+		err        error
+	)
 	if extraNonceStr != nil {
-		var err error
 		extraNonce, err = hexutil.Decode(*extraNonceStr)
 		if err != nil {
 			return false
@@ -119,11 +352,12 @@ func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash, ext
 	var blockHashCh = make(chan common.Hash, 1)
 	select {
 	case api.ethash.remote.submitWorkCh <- &mineResult{
-		nonce:     nonce,
-		mixDigest: digest,
-		hash:      hash,
+		nonce:       nonce,
+		mixDigest:   digest,
+		hash:        hash,
 		extraNonce:  extraNonce,
-		errc:      errc,
+		miner:       miner,
+		errc:        errc,
 		blockHashCh: blockHashCh,
 	}:
 	case <-api.ethash.remote.exitCh:
@@ -137,25 +371,255 @@ func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash, ext
 	}
 }
 
+// SolveAndSubmit brute-forces a valid nonce for the pending work with the given
+// pow-hash and submits it, returning the hash of the accepted block. It exercises
+// the complete getwork, solve and submit flow in one call for integration tests,
+// hence it's only supported in test mode where the dataset is tiny. A zero hash
+// selects the current work.
+func (api *EthashAPI) SolveAndSubmit(hash common.Hash) (common.Hash, error) {
+	if api.ethash.remote == nil {
+		return common.Hash{}, errors.New("not supported")
+	}
+	if api.ethash.config.PowMode != ModeTest {
+		return common.Hash{}, errNotTestMode
+	}
+	// Retrieve the block pending for the work
+	block, err := api.remote().pendingBlock(hash)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if hash == (common.Hash{}) {
+		hash = api.ethash.SealHash(block.Header())
+	}
+	// Solve the work and submit it like a remote miner would
+	nonce, digest := api.ethash.solve(block.Header())
+
+	var (
+		blockHashCh = make(chan common.Hash, 1)
+		errc        = make(chan error, 1)
+	)
+	select {
+	case api.ethash.remote.submitWorkCh <- &mineResult{
+		nonce:       nonce,
+		mixDigest:   digest,
+		hash:        hash,
+		errc:        errc,
+		blockHashCh: blockHashCh,
+	}:
+	case <-api.ethash.remote.exitCh:
+		return common.Hash{}, errEthashStopped
+	}
+	select {
+	case err := <-errc:
+		return common.Hash{}, err
+	case blockHash := <-blockHashCh:
+		return blockHash, nil
+	}
+}
+
+// pendingBlock retrieves the block pending for the work with the given pow-hash
+// from the remote sealer, or the block of the current work if the hash is zero.
+func (api *API) pendingBlock(hash common.Hash) (*types.Block, error) {
+	var (
+		blockCh = make(chan *types.Block, 1)
+		errc    = make(chan error, 1)
+	)
+	if err := api.fetchWork(&sealWork{errc: errc, hash: hash, block: blockCh}); err != nil {
+		return nil, err
+	}
+	select {
+	case block := <-blockCh:
+		return block, nil
+	case err := <-errc:
+		return nil, err
+	}
+}
+
+// Work statuses reported by ReplaySubmission.
+const (
+	WorkCurrent = "current" // the work is the current one handed out to miners
+	WorkStale   = "stale"   // the work is for an older, still remembered block
+	WorkUnknown = "unknown" // the work was never handed out or has been dropped
+)
+
+// ReplayResult is a trace of the validation of a work submission, explaining
+// why it would be accepted or rejected.
+type ReplayResult struct {
+	Status      string          `json:"status"`           // Status of the submitted work
+	Number      *hexutil.Uint64 `json:"number,omitempty"` // Number of the block pending for the work
+	MixDigest   common.Hash     `json:"mixDigest"`        // Mix digest computed for the nonce
+	MixMatch    bool            `json:"mixMatch"`         // Whether the submitted mix digest is the computed one
+	Hash        common.Hash     `json:"hash"`             // Final PoW hash computed for the nonce
+	Target      common.Hash     `json:"target"`           // Boundary condition the final hash must not exceed
+	MeetsTarget bool            `json:"meetsTarget"`      // Whether the final hash is within the boundary
+	Accepted    bool            `json:"accepted"`         // Whether a submission would have been accepted
+	Reason      string          `json:"reason,omitempty"` // Reason of the rejection, if any
+}
+
+// ReplaySubmission runs the validation of a work submission for the pending work
+// with the given pow-hash, like eth_submitWork does, and returns a detailed trace
+// of it. It is meant for debugging rejected shares: the solution is neither
+// accepted nor broadcast, even if it's valid.
+//
+// Replaying is only supported on development chains, in fake and test modes.
+func (api *PrivateAPI) ReplaySubmission(hash common.Hash, nonce types.BlockNonce, digest common.Hash) (ReplayResult, error) {
+	if mode := api.ethash.config.PowMode; mode != ModeFake && mode != ModeTest {
+		return ReplayResult{}, errNotDevMode
+	}
+	if api.ethash.remote == nil {
+		return ReplayResult{}, errors.New("not supported")
+	}
+	public := &API{api.ethash, api.chain}
+
+	current, err := public.pendingBlock(common.Hash{})
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	// Look up the submitted work, as the remote sealer would
+	result := ReplayResult{Status: WorkUnknown, Reason: "work submitted but none pending"}
+
+	block, err := public.pendingBlock(hash)
+	switch {
+	case err == errUnknownWork:
+		return result, nil
+	case err != nil:
+		return ReplayResult{}, err
+	case api.ethash.SealHash(current.Header()) == hash:
+		result.Status, result.Reason = WorkCurrent, ""
+	default:
+		result.Status, result.Reason = WorkStale, ""
+	}
+	number := hexutil.Uint64(block.NumberU64())
+	result.Number = &number
+
+	// Recompute the PoW of the solution on a copy of the pending header
+	header := block.Header()
+	header.Nonce = nonce
+	header.MixDigest = digest
+
+	pow := api.ethash
+	if pow.shared != nil {
+		pow = pow.shared
+	}
+	mix, final, err := pow.powValues(context.Background(), header, atomic.LoadUint32(&pow.fullVerify) == 1)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	target := api.ethash.workTarget(header.Difficulty)
+
+	result.MixDigest = common.BytesToHash(mix)
+	result.MixMatch = result.MixDigest == digest
+	result.Hash = common.BytesToHash(final)
+	result.Target = common.BigToHash(target)
+	result.MeetsTarget = new(big.Int).SetBytes(final).Cmp(target) <= 0
+
+	// Evaluate the outcome in the order the remote sealer checks it
+	switch {
+	case !result.MeetsTarget && !api.ethash.remote.noverify:
+		result.Reason = "invalid proof-of-work submitted"
+	case block.NumberU64()+staleThreshold <= current.NumberU64():
+		result.Reason = "work submitted is too old"
+	default:
+		result.Accepted = true
+	}
+	return result, nil
+}
+
+// GetPendingTimestamp returns the timestamp of the block in the current work
+// package. The difficulty of the work was computed from it, so it allows to
+// reproduce the boundary condition returned by GetWork.
+func (api *EthashAPI) GetPendingTimestamp() (hexutil.Uint64, error) {
+	if api.ethash.remote == nil {
+		return 0, errors.New("not supported")
+	}
+	block, err := api.remote().pendingBlock(common.Hash{})
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(block.Time()), nil
+}
+
+// GetSolvingDifficulty returns the difficulty of the block in the current work
+// package, which any submitted solution must meet for the block to be sealed.
+// Unlike the share difficulties assigned by pools to their workers, this is the
+// real bar. If a work target override is set, the difficulty of the easier
+// target is returned instead.
+func (api *EthashAPI) GetSolvingDifficulty() (*hexutil.Big, error) {
+	if api.ethash.remote == nil {
+		return nil, errors.New("not supported")
+	}
+	block, err := api.remote().pendingBlock(common.Hash{})
+	if err != nil {
+		return nil, err
+	}
+	difficulty := new(big.Int).Set(block.Difficulty())
+	if target := api.ethash.workTarget(difficulty); target.Cmp(new(big.Int).Div(two256, difficulty)) != 0 {
+		difficulty.Div(two256, target)
+	}
+	return (*hexutil.Big)(difficulty), nil
+}
+
+// SignedWorkResult is the outcome of a signed work submission, carrying the
+// address of the miner the work is attributed to.
+type SignedWorkResult struct {
+	Miner    common.Address `json:"miner"`
+	Accepted bool           `json:"accepted"`
+}
+
+// SignedWorkHash returns the hash a miner needs to sign in order to attribute a
+// work submission to itself, keccak256(hash || nonce || digest).
+func SignedWorkHash(nonce types.BlockNonce, hash, digest common.Hash) []byte {
+	return crypto.Keccak256(hash[:], nonce[:], digest[:])
+}
+
+// SubmitWorkSigned is similar to eth_submitWork, but additionally requires a
+// 65 byte [R || S || V] secp256k1 signature over SignedWorkHash, and returns the
+// address of the signer alongside the acceptance of the work. This allows pools
+// to attribute shares to key holders without trusting self-reported miner IDs.
+//
+// A malformed or unrecoverable signature rejects the work before it is verified,
+// returning an error instead of a result.
+func (api *EthashAPI) SubmitWorkSigned(nonce types.BlockNonce, hash, digest common.Hash, sig hexutil.Bytes) (*SignedWorkResult, error) {
+	if len(sig) != crypto.SignatureLength {
+		return nil, errInvalidWorkSig
+	}
+	// Accept both the raw and the legacy (27/28) recovery identifiers
+	sig = common.CopyBytes(sig)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pubkey, err := crypto.SigToPub(SignedWorkHash(nonce, hash, digest), sig)
+	if err != nil {
+		return nil, errInvalidWorkSig
+	}
+	miner := crypto.PubkeyToAddress(*pubkey)
+	return &SignedWorkResult{
+		Miner:    miner,
+		Accepted: api.remote().submitWork(nonce, hash, digest, nil, common.BytesToHash(miner[:])),
+	}, nil
+}
+
 // SubmitWorkDetail is similar to eth_submitWork but will return the block hash on success,
 // and return an explicit error message on failure.
 //
 // Params (same as `eth_submitWork`):
-//   [
-//       "<nonce>",
-//       "<pow_hash>",
-//       "<mix_hash>"
-//   ]
+//
+//	[
+//	    "<nonce>",
+//	    "<pow_hash>",
+//	    "<mix_hash>"
+//	]
 //
 // Result on success:
-//   "block_hash"
+//
+//	"block_hash"
 //
 // Error on failure:
-//   {code: -32005, message: "Cannot submit work.", data: "<reason for submission failure>"}
 //
-// See the original proposal here: <https://github.com/paritytech/parity-ethereum/pull/9404>
+//	{code: -32005, message: "Cannot submit work.", data: "<reason for submission failure>"}
 //
-func (api *API) SubmitWorkDetail(nonce types.BlockNonce, hash, digest common.Hash, extraNonceStr *string) (blockHash common.Hash, err rpc.ErrorWithInfo) {
+// See the original proposal here: <https://github.com/paritytech/parity-ethereum/pull/9404>
+func (api *API) SubmitWorkDetail(nonce types.BlockNonce, hash powHash, digest mixDigest, extraNonceStr *string) (blockHash common.Hash, err rpc.ErrorWithInfo) {
 	if api.ethash.remote == nil {
 		err = cannotSubmitWorkError{"not supported"}
 		return
@@ -177,8 +641,8 @@ func (api *API) SubmitWorkDetail(nonce types.BlockNonce, hash, digest common.Has
 	select {
 	case api.ethash.remote.submitWorkCh <- &mineResult{
 		nonce:       nonce,
-		mixDigest:   digest,
-		hash:        hash,
+		mixDigest:   common.Hash(digest),
+		hash:        common.Hash(hash),
 		errc:        errc,
 		extraNonce:  extraNonce,
 		blockHashCh: blockHashCh,
@@ -202,15 +666,16 @@ func (api *API) SubmitWorkDetail(nonce types.BlockNonce, hash, digest common.Has
 // which submit work through this node.
 //
 // It accepts the miner hash rate and an identifier which must be unique
-// between nodes.
-func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
+// between nodes. Rates submitted under the same identifier from different
+// hosts are combined according to the configured DuplicateIDPolicy.
+func (api *API) SubmitHashRate(ctx context.Context, rate hexutil.Uint64, id common.Hash) bool {
 	if api.ethash.remote == nil {
 		return false
 	}
 
 	var done = make(chan struct{}, 1)
 	select {
-	case api.ethash.remote.submitRateCh <- &hashrate{done: done, rate: uint64(rate), id: id}:
+	case api.ethash.remote.submitRateCh <- &hashrate{done: done, rate: uint64(rate), id: id, source: rateSource(ctx)}:
 	case <-api.ethash.remote.exitCh:
 		return false
 	}
@@ -220,7 +685,295 @@ func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
 	return true
 }
 
+// rateSource returns the host a hash rate was submitted from, as recorded in the
+// request context by the HTTP server. Other transports don't record it.
+func rateSource(ctx context.Context) string {
+	remote, ok := ctx.Value("remote").(string)
+	if !ok {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// GetVerificationMode returns whether seals are verified using the full dataset
+// ("full") or the ethash cache ("light").
+func (api *EthashAPI) GetVerificationMode() string {
+	return api.ethash.VerificationMode()
+}
+
+// SetVerificationMode switches seal verification between the full dataset
+// ("full"), which is fast but needs more than a gigabyte of memory, and the
+// ethash cache ("light").
+func (api *PrivateAPI) SetVerificationMode(mode string) (bool, error) {
+	if err := api.ethash.SetVerificationMode(mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FreezeDifficulty pins the difficulty of new blocks to the given value for
+// benchmarking, see Ethash.FreezeDifficulty.
+func (api *PrivateAPI) FreezeDifficulty(difficulty *hexutil.Big) (bool, error) {
+	if err := api.ethash.FreezeDifficulty((*big.Int)(difficulty)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnfreezeDifficulty returns to the normal difficulty adjustment.
+func (api *PrivateAPI) UnfreezeDifficulty() bool {
+	api.ethash.UnfreezeDifficulty()
+	return true
+}
+
+// BestShareResult is the best share submitted by remote miners, see GetBestShare.
+type BestShareResult struct {
+	Difficulty *hexutil.Big `json:"difficulty"` // Difficulty achieved by the solution
+	Miner      common.Hash  `json:"miner"`      // Signer of the solution if submitted signed, zero otherwise
+	Time       time.Time    `json:"time"`       // Time the solution was submitted
+}
+
+// GetBestShare returns the solution achieving the highest difficulty submitted
+// since start or the last reset, valid or not, or null if there was none. Signed
+// submissions are attributed to the address of their signer.
+func (api *EthashAPI) GetBestShare() *BestShareResult {
+	best := api.ethash.BestShare()
+	if best == nil {
+		return nil
+	}
+	return &BestShareResult{
+		Difficulty: (*hexutil.Big)(best.Difficulty),
+		Miner:      best.Miner,
+		Time:       best.Time,
+	}
+}
+
+// ResetBestShare forgets the best share submitted so far, starting a new round.
+func (api *PrivateAPI) ResetBestShare() bool {
+	api.ethash.ResetBestShare()
+	return true
+}
+
+// VerifyStats contains seal verification statistics, see GetVerifyStats.
+type VerifyStats struct {
+	Verified      hexutil.Uint64 `json:"verified"`
+	Failed        hexutil.Uint64 `json:"failed"`
+	AvgDurationMs float64        `json:"avgDurationMs"`
+}
+
+// GetVerifyStats returns the number of valid and invalid seals verified since
+// start or the last reset, and the average verification time in milliseconds.
+func (api *EthashAPI) GetVerifyStats() *VerifyStats {
+	verified, failed, avg := api.ethash.GetVerifyStats()
+	return &VerifyStats{Verified: hexutil.Uint64(verified), Failed: hexutil.Uint64(failed), AvgDurationMs: avg}
+}
+
+// ResetVerifyStats clears the seal verification statistics.
+func (api *PrivateAPI) ResetVerifyStats() bool {
+	api.ethash.ResetVerifyStats()
+	return true
+}
+
+// GetSlowVerifications returns the slowest seal verifications since start or
+// the last reset of the verification statistics, the slowest first. Tracking
+// must be enabled by configuring the number of verifications to keep.
+func (api *EthashAPI) GetSlowVerifications() []SlowVerifyRecord {
+	return api.ethash.GetSlowVerifications()
+}
+
+// GetEffectiveConfig returns the configuration the engine actually runs with,
+// after defaults were applied, see Ethash.EffectiveConfig.
+func (api *EthashAPI) GetEffectiveConfig() map[string]interface{} {
+	return api.ethash.EffectiveConfig()
+}
+
+// EngineUptime is the result of GetEngineUptime.
+type EngineUptime struct {
+	StartTime time.Time     `json:"startTime"` // Time the engine was constructed
+	Uptime    time.Duration `json:"uptime"`    // Time since the engine was constructed
+}
+
+// GetEngineUptime returns when the engine was constructed and how long it has
+// been running since.
+func (api *EthashAPI) GetEngineUptime() EngineUptime {
+	start := api.ethash.StartTime()
+	return EngineUptime{StartTime: start, Uptime: time.Since(start)}
+}
+
 // GetHashrate returns the current hashrate for local CPU miner and remote miner.
 func (api *API) GetHashrate() uint64 {
 	return uint64(api.ethash.Hashrate())
 }
+
+// EstimateNextEpochTime estimates how long it takes until the chain reaches the
+// next epoch boundary, given the average block time in milliseconds. Operators
+// can use it to decide when to start generating the next DAG.
+func (api *EthashAPI) EstimateNextEpochTime(avgBlockTimeMs hexutil.Uint64) (time.Duration, error) {
+	if avgBlockTimeMs == 0 {
+		return 0, errInvalidBlockTime
+	}
+	if api.chain == nil {
+		return 0, errUnknownTip
+	}
+	header := api.chain.CurrentHeader()
+	if header == nil {
+		return 0, errUnknownTip
+	}
+	var (
+		number    = header.Number.Uint64()
+		remaining = (number/epochLength+1)*epochLength - number
+	)
+	if remaining > uint64(math.MaxInt64/int64(time.Millisecond))/uint64(avgBlockTimeMs) {
+		return 0, errEpochTimeOverflow
+	}
+	return time.Duration(remaining*uint64(avgBlockTimeMs)) * time.Millisecond, nil
+}
+
+// SameEpoch reports whether two block numbers fall into the same DAG epoch, i.e.
+// whether they are mined with the same cache and dataset.
+func (api *EthashAPI) SameEpoch(a, b hexutil.Uint64) bool {
+	return uint64(a)/epochLength == uint64(b)/epochLength
+}
+
+// ActiveForkRules reports which consensus relevant forks are active at the
+// current head of the chain, as derived from the chain configuration.
+func (api *EthashAPI) ActiveForkRules() (map[string]bool, error) {
+	if api.chain == nil {
+		return nil, errUnknownTip
+	}
+	header := api.chain.CurrentHeader()
+	if header == nil {
+		return nil, errUnknownTip
+	}
+	var (
+		config = api.chain.Config()
+		number = header.Number
+	)
+	return map[string]bool{
+		"homestead":      config.IsHomestead(number),
+		"daoFork":        config.IsDAOFork(number),
+		"eip150":         config.IsEIP150(number),
+		"eip155":         config.IsEIP155(number),
+		"eip158":         config.IsEIP158(number),
+		"byzantium":      config.IsByzantium(number),
+		"constantinople": config.IsConstantinople(number),
+		"petersburg":     config.IsPetersburg(number),
+		"istanbul":       config.IsIstanbul(number),
+		"muirGlacier":    config.IsMuirGlacier(number),
+	}, nil
+}
+
+// GetDifficultyHistory returns the difficulties of the last count blocks of the
+// local chain, ordered from the oldest to the current head. If the chain is
+// shorter than requested, all blocks down to the genesis are returned.
+//
+// The count must be between 1 and 1024 (maxDifficultyHistory).
+func (api *EthashAPI) GetDifficultyHistory(count hexutil.Uint64) ([]*hexutil.Big, error) {
+	if count == 0 || count > maxDifficultyHistory {
+		return nil, errInvalidHistory
+	}
+	if api.chain == nil {
+		return nil, errors.New("not supported")
+	}
+	var (
+		header = api.chain.CurrentHeader()
+		diffs  = make([]*hexutil.Big, 0, count)
+	)
+	for header != nil && len(diffs) < int(count) {
+		diffs = append(diffs, (*hexutil.Big)(new(big.Int).Set(header.Difficulty)))
+
+		number := header.Number.Uint64()
+		if number == 0 {
+			break
+		}
+		header = api.chain.GetHeader(header.ParentHash, number-1)
+	}
+	// Headers were collected backwards, flip them to chronological order
+	for i, j := 0, len(diffs)-1; i < j; i, j = i+1, j-1 {
+		diffs[i], diffs[j] = diffs[j], diffs[i]
+	}
+	return diffs, nil
+}
+
+// SealVerifyResult is the outcome of the seal verification of a block, see
+// VerifySealRange.
+type SealVerifyResult struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+	Valid  bool           `json:"valid"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// VerifySealRange re-verifies the seals of the canonical blocks from and to the
+// given numbers inclusive, using up to the given number of concurrent verifiers
+// (all CPUs if not positive). The blocks are verified in order, so that blocks of
+// the same epoch share the verification cache. The results are ordered by block
+// number.
+//
+// The range must span at most 1024 blocks (maxSealVerifyRange). Only a single
+// verification may run at a time, concurrent calls are rejected.
+func (api *PrivateAPI) VerifySealRange(ctx context.Context, from, to hexutil.Uint64, parallelism int) ([]SealVerifyResult, error) {
+	if from > to || to-from >= maxSealVerifyRange {
+		return nil, errInvalidSealRange
+	}
+	if !api.ethash.rangeVerify.TryLock() {
+		return nil, errSealRangeBusy
+	}
+	defer api.ethash.rangeVerify.Unlock()
+
+	if api.chain == nil {
+		return nil, errors.New("not supported")
+	}
+	headers := make([]*types.Header, 0, to-from+1)
+	for number := uint64(from); number <= uint64(to); number++ {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		headers = append(headers, header)
+	}
+	if parallelism <= 0 || parallelism > runtime.NumCPU() {
+		parallelism = runtime.NumCPU()
+	}
+	if parallelism > len(headers) {
+		parallelism = len(headers)
+	}
+	var (
+		results = make([]SealVerifyResult, len(headers))
+		next    = int32(-1)
+		pend    sync.WaitGroup
+	)
+	for i := 0; i < parallelism; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			for {
+				index := int(atomic.AddInt32(&next, 1))
+				if index >= len(headers) || ctx.Err() != nil {
+					return
+				}
+				// Verify a copy, as the verification may fix up the mix digest
+				header := headers[index]
+				err := api.ethash.verifySeal(ctx, api.chain, types.CopyHeader(header), false)
+
+				results[index] = SealVerifyResult{
+					Number: hexutil.Uint64(header.Number.Uint64()),
+					Hash:   header.Hash(),
+					Valid:  err == nil,
+				}
+				if err != nil {
+					results[index].Error = err.Error()
+				}
+			}
+		}()
+	}
+	pend.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
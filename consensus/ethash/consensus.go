@@ -18,10 +18,12 @@ package ethash
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
@@ -41,7 +43,6 @@ var (
 	FrontierBlockReward       = big.NewInt(5e+18) // Block reward in wei for successfully mining a block
 	ByzantiumBlockReward      = big.NewInt(3e+18) // Block reward in wei for successfully mining a block upward from Byzantium
 	ConstantinopleBlockReward = big.NewInt(2e+18) // Block reward in wei for successfully mining a block upward from Constantinople
-	maxUncles                 = 2                 // Maximum number of uncles allowed in a single block
 	allowedFutureBlockTime    = 15 * time.Second  // Max time from current time allowed for blocks, before they're considered future blocks
 
 	// calcDifficultyEip2384 is the difficulty adjustment algorithm as specified by EIP 2384.
@@ -76,6 +77,16 @@ var (
 	errInvalidDifficulty = errors.New("non-positive difficulty")
 	errInvalidMixDigest  = errors.New("invalid mix digest")
 	errInvalidPoW        = errors.New("invalid proof-of-work")
+	errTargetOverride    = errors.New("work target override not allowed in normal mode")
+	errDifficultyFreeze  = errors.New("difficulty freeze not allowed in normal mode")
+
+	// ErrInvalidUncleSeal is returned by VerifyUncleSeal if the proof-of-work of
+	// the uncle is invalid.
+	ErrInvalidUncleSeal = errors.New("invalid uncle proof-of-work")
+
+	// ErrUncleDepth is returned by VerifyUncleSeal if the uncle isn't within the
+	// allowed depth of the ancestor.
+	ErrUncleDepth = errors.New("uncle not within allowed depth")
 )
 
 // Author implements consensus.Engine, returning the header's coinbase as the
@@ -193,7 +204,7 @@ func (ethash *Ethash) VerifyUncles(chain consensus.ChainReader, block *types.Blo
 		return nil
 	}
 	// Verify that there are at most 2 uncles included in this block
-	if len(block.Uncles()) > maxUncles {
+	if len(block.Uncles()) > params.MaxUncles {
 		return errTooManyUncles
 	}
 	if len(block.Uncles()) == 0 {
@@ -305,8 +316,12 @@ func (ethash *Ethash) verifyHeader(chain consensus.ChainReader, header, parent *
 
 // CalcDifficulty is the difficulty adjustment algorithm. It returns
 // the difficulty that a new block should have when created at time
-// given the parent block's time and difficulty.
+// given the parent block's time and difficulty, or the frozen difficulty if
+// set with FreezeDifficulty.
 func (ethash *Ethash) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	if difficulty := ethash.frozenDifficulty(); difficulty != nil {
+		return new(big.Int).Set(difficulty)
+	}
 	return CalcDifficulty(chain.Config(), time, parent)
 }
 
@@ -487,36 +502,103 @@ func calcDifficultyFrontier(time uint64, parent *types.Header) *big.Int {
 // VerifySeal implements consensus.Engine, checking whether the given block satisfies
 // the PoW difficulty requirements.
 func (ethash *Ethash) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
-	return ethash.verifySeal(chain, header, false)
+	return ethash.verifySeal(context.Background(), chain, header, false)
+}
+
+// VerifySealContext is like VerifySeal, but respects the cancellation of the
+// given context. If the verification cache for the header's epoch needs to be
+// generated first, the generation is aborted when the context is canceled and
+// the context's error is returned.
+func (ethash *Ethash) VerifySealContext(ctx context.Context, header *types.Header) error {
+	return ethash.verifySeal(ctx, nil, header, false)
+}
+
+// VerifyUncleSeal checks whether an uncle satisfies the PoW difficulty requirements,
+// using the verification cache of its epoch, and whether it's recent enough to be
+// included by the canonical block of the given number. ErrUncleDepth is returned
+// if the uncle is too old or not older than that block, ErrInvalidUncleSeal if its
+// seal is invalid. The uncle itself is never modified.
+func (ethash *Ethash) VerifyUncleSeal(uncle *types.Header, ancestorNumber uint64) error {
+	number := uncle.Number.Uint64()
+	if number >= ancestorNumber || ancestorNumber-number > uint64(params.MaxUncleDepth) {
+		return ErrUncleDepth
+	}
+	if ethash.verifySeal(context.Background(), nil, types.CopyHeader(uncle), false) != nil {
+		return ErrInvalidUncleSeal
+	}
+	return nil
 }
 
 // verifySeal checks whether a block satisfies the PoW difficulty requirements,
 // either using the usual ethash cache for it, or alternatively using a full DAG
 // to make remote mining fast.
-func (ethash *Ethash) verifySeal(chain consensus.ChainReader, header *types.Header, fulldag bool) error {
+func (ethash *Ethash) verifySeal(ctx context.Context, chain consensus.ChainReader, header *types.Header, fulldag bool) error {
+	return ethash.verifySealWith(ctx, header, fulldag, nil)
+}
+
+// verifySealWith is verifySeal, additionally passing the PoW values computed for
+// the header to the given callback, if set, before they are checked. It allows
+// inspecting the values without hashing the header twice.
+func (ethash *Ethash) verifySealWith(ctx context.Context, header *types.Header, fulldag bool, onValues func(digest, result []byte)) error {
+	// If we're running a shared PoW, delegate verification to it
+	if ethash.shared != nil {
+		return ethash.shared.verifySealWith(ctx, header, fulldag, onValues)
+	}
+	start := time.Now()
+	err := ethash.checkSeal(ctx, header, fulldag, onValues)
+	if err != context.Canceled && err != context.DeadlineExceeded {
+		elapsed := time.Since(start)
+		ethash.verifyStats.record(elapsed, err == nil)
+		ethash.slowVerifies.record(header, elapsed, err == nil)
+	}
+	return err
+}
+
+// checkSeal performs the seal verification of verifySealWith.
+func (ethash *Ethash) checkSeal(ctx context.Context, header *types.Header, fulldag bool, onValues func(digest, result []byte)) error {
 	// If we're running a fake PoW, accept any seal as valid
 	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
-		time.Sleep(ethash.fakeDelay)
+		select {
+		case <-time.After(ethash.fakeDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 		if ethash.fakeFail == header.Number.Uint64() {
 			return errInvalidPoW
 		}
 		return nil
 	}
-	// If we're running a shared PoW, delegate verification to it
-	if ethash.shared != nil {
-		return ethash.shared.verifySeal(chain, header, fulldag)
+	if atomic.LoadUint32(&ethash.fullVerify) == 1 {
+		fulldag = true
 	}
 	// Ensure that we have a valid difficulty for the block
 	if header.Difficulty.Sign() <= 0 {
 		return errInvalidDifficulty
 	}
 	// Recompute the digest and PoW values
+	digest, result, err := ethash.powValues(ctx, header, fulldag)
+	if err != nil {
+		return err
+	}
+	if onValues != nil {
+		onValues(digest, result)
+	}
+	target := ethash.workTarget(header.Difficulty)
+	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+		return errInvalidPoW
+	}
+	// Fix mix digest if PoW is valid
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		header.MixDigest = common.BytesToHash(digest)
+	}
+	return nil
+}
+
+// powValues computes the mix digest and the final PoW hash of a header, using
+// the full dataset if requested and available, or the verification cache.
+func (ethash *Ethash) powValues(ctx context.Context, header *types.Header, fulldag bool) (digest []byte, result []byte, err error) {
 	number := header.Number.Uint64()
 
-	var (
-		digest []byte
-		result []byte
-	)
 	// If fast-but-heavy PoW verification was requested, use an ethash dataset
 	if fulldag {
 		dataset := ethash.dataset(number, true)
@@ -533,7 +615,10 @@ func (ethash *Ethash) verifySeal(chain consensus.ChainReader, header *types.Head
 	}
 	// If slow-but-light PoW verification was requested (or DAG not yet ready), use an ethash cache
 	if !fulldag {
-		cache := ethash.cache(number)
+		cache, err := ethash.cacheContext(ctx, number)
+		if err != nil {
+			return nil, nil, err
+		}
 
 		size := datasetSize(number)
 		if ethash.config.PowMode == ModeTest {
@@ -545,15 +630,7 @@ func (ethash *Ethash) verifySeal(chain consensus.ChainReader, header *types.Head
 		// until after the call to hashimotoLight so it's not unmapped while being used.
 		runtime.KeepAlive(cache)
 	}
-	target := new(big.Int).Div(two256, header.Difficulty)
-	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
-		return errInvalidPoW
-	}
-	// Fix mix digest if PoW is valid
-	if !bytes.Equal(header.MixDigest[:], digest) {
-		header.MixDigest = common.BytesToHash(digest)
-	}
-	return nil
+	return digest, result, nil
 }
 
 // Prepare implements consensus.Engine, initializing the difficulty field of a
@@ -589,8 +666,15 @@ func (ethash *Ethash) FinalizeAndAssemble(chain consensus.ChainReader, header *t
 // SealHash returns the hash of a block prior to it being sealed.
 func (ethash *Ethash) SealHash(header *types.Header) (hash common.Hash) {
 	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(ethash.SealHashInput(header))
+	hasher.Sum(hash[:0])
+	return hash
+}
 
-	rlp.Encode(hasher, []interface{}{
+// SealHashInput returns the RLP encoded header without the nonce and mix digest,
+// which is the data hashed by SealHash.
+func (ethash *Ethash) SealHashInput(header *types.Header) []byte {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
 		header.ParentHash,
 		header.UncleHash,
 		header.Coinbase,
@@ -605,8 +689,7 @@ func (ethash *Ethash) SealHash(header *types.Header) (hash common.Hash) {
 		header.Time,
 		header.Extra,
 	})
-	hasher.Sum(hash[:0])
-	return hash
+	return enc
 }
 
 // Some weird constants to avoid constant memory allocs for them.
@@ -615,18 +698,26 @@ var (
 	big32 = big.NewInt(32)
 )
 
+// staticBlockReward returns the block reward in wei for mining a block at the
+// given height, excluding the rewards for included uncles.
+func staticBlockReward(config *params.ChainConfig, number *big.Int) *big.Int {
+	switch {
+	case config.IsConstantinople(number):
+		return ConstantinopleBlockReward
+	case config.IsByzantium(number):
+		return ByzantiumBlockReward
+	default:
+		return FrontierBlockReward
+	}
+}
+
 // AccumulateRewards credits the coinbase of the given block with the mining
 // reward. The total reward consists of the static block reward and rewards for
 // included uncles. The coinbase of each uncle block is also rewarded.
 func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
 	// Select the correct block reward based on chain progression
-	blockReward := FrontierBlockReward
-	if config.IsByzantium(header.Number) {
-		blockReward = ByzantiumBlockReward
-	}
-	if config.IsConstantinople(header.Number) {
-		blockReward = ConstantinopleBlockReward
-	}
+	blockReward := staticBlockReward(config, header.Number)
+
 	// Accumulate the rewards for the miner and any included uncles
 	reward := new(big.Int).Set(blockReward)
 	r := new(big.Int)
@@ -18,15 +18,19 @@
 package ethash
 
 import (
+	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
 	"math/rand"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -34,22 +38,33 @@ import (
 	"unsafe"
 
 	mmap "github.com/edsrzf/mmap-go"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/hashicorp/golang-lru/simplelru"
+	"golang.org/x/time/rate"
 )
 
 var ErrInvalidDumpMagic = errors.New("invalid dump magic")
 
+// Seal verification modes, see SetVerificationMode.
+const (
+	VerifyLight = "light" // Verify seals using the ethash cache, slow but small
+	VerifyFull  = "full"  // Verify seals using the full dataset, fast but large
+)
+
 var (
 	// two256 is a big integer representing 2^256
 	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
 	// sharedEthash is a full instance that can be shared between multiple users.
-	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal, nil}, nil, false)
+	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal, 0, "", false, 0, 0, 0, 0, nil, nil, nil}, nil, false)
 
 	// algorithmRevision is the data structure version used for file naming.
 	algorithmRevision = 23
@@ -108,7 +123,9 @@ func memoryMapFile(file *os.File, write bool) (mmap.MMap, []uint32, error) {
 // memoryMapAndGenerate tries to memory map a temporary file of uint32s for write
 // access, fill it with the data from a generator and then move it into the final
 // path requested.
-func memoryMapAndGenerate(path string, size uint64, generator func(buffer []uint32)) (*os.File, mmap.MMap, []uint32, error) {
+//
+// If the generator fails, the temporary file is removed and the error returned.
+func memoryMapAndGenerate(path string, size uint64, generator func(buffer []uint32) error) (*os.File, mmap.MMap, []uint32, error) {
 	// Ensure the data folder exists
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return nil, nil, nil, err
@@ -132,8 +149,12 @@ func memoryMapAndGenerate(path string, size uint64, generator func(buffer []uint
 	copy(buffer, dumpMagic)
 
 	data := buffer[len(dumpMagic):]
-	generator(data)
-
+	if err := generator(data); err != nil {
+		mem.Unmap()
+		dump.Close()
+		os.Remove(temp)
+		return nil, nil, nil, err
+	}
 	if err := mem.Unmap(); err != nil {
 		return nil, nil, nil, err
 	}
@@ -200,69 +221,104 @@ func (lru *lru) get(epoch uint64) (item, future interface{}) {
 
 // cache wraps an ethash cache with some metadata to allow easier concurrent use.
 type cache struct {
-	epoch uint64    // Epoch for which this cache is relevant
-	dump  *os.File  // File descriptor of the memory mapped cache
-	mmap  mmap.MMap // Memory map itself to unmap before releasing
-	cache []uint32  // The actual cache data content (may be memory mapped)
-	once  sync.Once // Ensures the cache is generated only once
+	epoch uint64        // Epoch for which this cache is relevant
+	dump  *os.File      // File descriptor of the memory mapped cache
+	mmap  mmap.MMap     // Memory map itself to unmap before releasing
+	cache []uint32      // The actual cache data content (may be memory mapped)
+	lock  chan struct{} // Semaphore ensuring the cache is generated only once
+	done  bool          // Whether the cache was fully generated (guarded by lock)
 }
 
 // newCache creates a new ethash verification cache and returns it as a plain Go
 // interface to be usable in an LRU cache.
 func newCache(epoch uint64) interface{} {
-	return &cache{epoch: epoch}
+	return &cache{epoch: epoch, lock: make(chan struct{}, 1)}
 }
 
 // generate ensures that the cache content is generated before use.
 func (c *cache) generate(dir string, limit int, test bool) {
-	c.once.Do(func() {
-		size := cacheSize(c.epoch*epochLength + 1)
-		seed := seedHash(c.epoch*epochLength + 1)
-		if test {
-			size = 1024
-		}
-		// If we don't store anything on disk, generate and return.
-		if dir == "" || limit <= 0 {
-			c.cache = make([]uint32, size/4)
-			generateCache(c.cache, c.epoch, seed)
-			return
-		}
-		// Disk storage is needed, this will get fancy
-		var endian string
-		if !isLittleEndian() {
-			endian = ".be"
-		}
-		path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
-		logger := log.New("epoch", c.epoch)
+	c.generateContext(context.Background(), dir, limit, test)
+}
 
-		// We're about to mmap the file, ensure that the mapping is cleaned up when the
-		// cache becomes unused.
-		runtime.SetFinalizer(c, (*cache).finalizer)
+// generateContext ensures that the cache content is generated before use. If the
+// context is canceled before the cache is ready, waiting is aborted, as is the
+// generation itself if it was started by this call. Aborted generations leave no
+// partial data behind and are restarted by the next caller.
+func (c *cache) generateContext(ctx context.Context, dir string, limit int, test bool) error {
+	select {
+	case c.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.lock }()
 
-		// Try to load the file from disk and memory map it
-		var err error
-		c.dump, c.mmap, c.cache, err = memoryMap(path)
-		if err == nil {
-			logger.Debug("Loaded old ethash cache from disk")
-			return
+	if c.done {
+		return nil
+	}
+	size := cacheSize(c.epoch*epochLength + 1)
+	seed := seedHash(c.epoch*epochLength + 1)
+	if test {
+		size = 1024
+	}
+	// If we don't store anything on disk, generate and return.
+	if dir == "" || limit <= 0 {
+		cache := make([]uint32, size/4)
+		if !generateCacheAbortable(cache, c.epoch, seed, ctx.Done()) {
+			return ctx.Err()
 		}
-		logger.Debug("Failed to load old ethash cache", "err", err)
+		c.cache, c.done = cache, true
+		return nil
+	}
+	// Disk storage is needed, this will get fancy
+	var endian string
+	if !isLittleEndian() {
+		endian = ".be"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
+	logger := log.New("epoch", c.epoch)
 
-		// No previous cache available, create a new cache file to fill
-		c.dump, c.mmap, c.cache, err = memoryMapAndGenerate(path, size, func(buffer []uint32) { generateCache(buffer, c.epoch, seed) })
-		if err != nil {
-			logger.Error("Failed to generate mapped ethash cache", "err", err)
+	// Try to load the file from disk and memory map it
+	var err error
+	c.dump, c.mmap, c.cache, err = memoryMap(path)
+	if err == nil {
+		logger.Debug("Loaded old ethash cache from disk")
 
-			c.cache = make([]uint32, size/4)
-			generateCache(c.cache, c.epoch, seed)
-		}
-		// Iterate over all previous instances and delete old ones
-		for ep := int(c.epoch) - limit; ep >= 0; ep-- {
-			seed := seedHash(uint64(ep)*epochLength + 1)
-			path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
-			os.Remove(path)
+		// Ensure that the mapping is cleaned up when the cache becomes unused.
+		runtime.SetFinalizer(c, (*cache).finalizer)
+		c.done = true
+		return nil
+	}
+	logger.Debug("Failed to load old ethash cache", "err", err)
+
+	// No previous cache available, create a new cache file to fill
+	c.dump, c.mmap, c.cache, err = memoryMapAndGenerate(path, size, func(buffer []uint32) error {
+		if !generateCacheAbortable(buffer, c.epoch, seed, ctx.Done()) {
+			return ctx.Err()
 		}
+		return nil
 	})
+	switch {
+	case err == nil:
+		runtime.SetFinalizer(c, (*cache).finalizer)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	default:
+		logger.Error("Failed to generate mapped ethash cache", "err", err)
+
+		cache := make([]uint32, size/4)
+		if !generateCacheAbortable(cache, c.epoch, seed, ctx.Done()) {
+			return ctx.Err()
+		}
+		c.cache = cache
+	}
+	// Iterate over all previous instances and delete old ones
+	for ep := int(c.epoch) - limit; ep >= 0; ep-- {
+		seed := seedHash(uint64(ep)*epochLength + 1)
+		path := filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
+		os.Remove(path)
+	}
+	c.done = true
+	return nil
 }
 
 // finalizer unmaps the memory and closes the file.
@@ -290,9 +346,15 @@ func newDataset(epoch uint64) interface{} {
 	return &dataset{epoch: epoch}
 }
 
-// generate ensures that the dataset content is generated before use.
-func (d *dataset) generate(dir string, limit int, test bool) {
+// generate ensures that the dataset content is generated before use. If ready
+// is non-nil, it is invoked in a new goroutine once the dataset is available.
+func (d *dataset) generate(dir string, limit int, test bool, ioLimit uint64, ready func(epoch uint64)) {
 	d.once.Do(func() {
+		// Notify the caller after the dataset is marked done (deferred calls run
+		// in reverse order)
+		if ready != nil {
+			defer func() { go ready(d.epoch) }()
+		}
 		// Mark the dataset generated after we're done. This is needed for remote
 		defer atomic.StoreUint32(&d.done, 1)
 
@@ -309,7 +371,7 @@ func (d *dataset) generate(dir string, limit int, test bool) {
 			generateCache(cache, d.epoch, seed)
 
 			d.dataset = make([]uint32, dsize/4)
-			generateDataset(d.dataset, d.epoch, cache)
+			generateDataset(d.dataset, d.epoch, cache, nil)
 
 			return
 		}
@@ -338,12 +400,19 @@ func (d *dataset) generate(dir string, limit int, test bool) {
 		cache := make([]uint32, csize/4)
 		generateCache(cache, d.epoch, seed)
 
-		d.dump, d.mmap, d.dataset, err = memoryMapAndGenerate(path, dsize, func(buffer []uint32) { generateDataset(buffer, d.epoch, cache) })
+		var limiter *rate.Limiter
+		if ioLimit > 0 {
+			limiter = newDatasetLimiter(ioLimit)
+		}
+		d.dump, d.mmap, d.dataset, err = memoryMapAndGenerate(path, dsize, func(buffer []uint32) error {
+			generateDataset(buffer, d.epoch, cache, limiter)
+			return nil
+		})
 		if err != nil {
 			logger.Error("Failed to generate mapped ethash dataset", "err", err)
 
 			d.dataset = make([]uint32, dsize/2)
-			generateDataset(d.dataset, d.epoch, cache)
+			generateDataset(d.dataset, d.epoch, cache, nil)
 		}
 		// Iterate over all previous instances and delete old ones
 		for ep := int(d.epoch) - limit; ep >= 0; ep-- {
@@ -372,14 +441,14 @@ func (d *dataset) finalizer() {
 
 // MakeCache generates a new ethash cache and optionally stores it to disk.
 func MakeCache(block uint64, dir string) {
-	c := cache{epoch: block / epochLength}
+	c := newCache(block / epochLength).(*cache)
 	c.generate(dir, math.MaxInt32, false)
 }
 
 // MakeDataset generates a new ethash dataset and optionally stores it to disk.
 func MakeDataset(block uint64, dir string) {
 	d := dataset{epoch: block / epochLength}
-	d.generate(dir, math.MaxInt32, false)
+	d.generate(dir, math.MaxInt32, false, 0, nil)
 }
 
 // Mode defines the type and amount of PoW verification an ethash engine makes.
@@ -393,6 +462,35 @@ const (
 	ModeFullFake
 )
 
+// String implements fmt.Stringer.
+func (m Mode) String() string {
+	switch m {
+	case ModeNormal:
+		return "normal"
+	case ModeShared:
+		return "shared"
+	case ModeTest:
+		return "test"
+	case ModeFake:
+		return "fake"
+	case ModeFullFake:
+		return "fullfake"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint(m))
+	}
+}
+
+// Policies for combining remote hash rates submitted under the same identifier
+// from different sources, see Config.DuplicateIDPolicy.
+const (
+	DuplicateIDSum     = "sum"
+	DuplicateIDReplace = "replace"
+)
+
+// DefaultWorkFetchTimeout is the time work requests wait for the sealer to pick
+// them up if no Config.WorkFetchTimeout is set.
+const DefaultWorkFetchTimeout = 3 * time.Second
+
 // Config are the configuration parameters of the ethash.
 type Config struct {
 	CacheDir       string
@@ -403,6 +501,57 @@ type Config struct {
 	DatasetsOnDisk int
 	PowMode        Mode
 
+	// HashrateFloor is the minimum hash rate a local or remote sealer must have
+	// to be included in the reported hash rate. Rates below the floor are
+	// reported as zero, without affecting the tracked rates themselves.
+	HashrateFloor uint64
+
+	// DuplicateIDPolicy defines how the hash rates submitted by remote miners
+	// under the same identifier from different sources (hosts) are combined:
+	//   - "sum" (default): the rates of all sources are added up. This is
+	//     accurate if the identifier is shared by distinct rigs, but counts a
+	//     rig reporting through several hosts multiple times.
+	//   - "replace": only the rate of the latest source is kept. This avoids
+	//     counting a rig twice, but under-reports distinct rigs sharing the
+	//     identifier.
+	// Submissions are attributed to hosts over HTTP only, others share a source.
+	DuplicateIDPolicy string
+
+	// RequireSyncedForWork makes work requests of remote miners fail while the
+	// chain is syncing, as reported by the function set with SetSyncStatus.
+	RequireSyncedForWork bool
+
+	// GenerationIOLimitBytesPerSec caps the rate at which the mining dataset is
+	// written while generating it into the dataset directory, so the DAG doesn't
+	// starve other users of the same disk (e.g. the chain database). Generation
+	// takes longer accordingly. Zero means unlimited.
+	GenerationIOLimitBytesPerSec uint64
+
+	// WorkFetchTimeout bounds how long work requests of remote miners wait for
+	// the sealer to pick them up, e.g. while the miner is restarting, before
+	// failing with a "miner initializing" error. Zero selects the default.
+	WorkFetchTimeout time.Duration
+
+	// SlowVerifications is the number of slowest seal verifications to keep track
+	// of, see GetSlowVerifications. Zero disables the tracking.
+	SlowVerifications int
+
+	// NearMissDifficulty is the difficulty a hash found by the local sealing
+	// threads must meet to be reported via OnNearMiss, even though it doesn't
+	// meet the difficulty of the block. Zero disables near-miss reporting.
+	NearMissDifficulty uint64
+
+	// OnDatasetReady, if set, is called whenever the mining dataset of an epoch
+	// finished generating (or was loaded from disk). It is invoked once per
+	// dataset on a separate goroutine, so it may block without stalling mining.
+	OnDatasetReady func(epoch uint64) `toml:"-"`
+
+	// OnNearMiss, if set, is called for every nonce found by the local sealing
+	// threads whose hash meets NearMissDifficulty but not the block difficulty,
+	// along with the difficulty the hash meets. It's called on the sealing
+	// threads, so it must not block.
+	OnNearMiss func(nonce uint64, difficulty *big.Int) `toml:"-"`
+
 	Log log.Logger `toml:"-"`
 }
 
@@ -421,13 +570,22 @@ type Ethash struct {
 	hashrate metrics.Meter // Meter tracking the average hashrate
 	remote   *remoteSealer
 
+	fullVerify   uint32            // Whether seals are verified using the full dataset (atomic)
+	verifyStats  verifyStats       // Seal verification counters since start or last reset
+	slowVerifies slowVerifyTracker // Slowest seal verifications since start or last reset
+	syncing      atomic.Value      // Function reporting whether the chain is syncing, see SetSyncStatus
+	target       atomic.Value      // Overridden mining target (*big.Int), see SetWorkTargetOverride
+	difficulty   atomic.Value      // Frozen block difficulty (*big.Int), see FreezeDifficulty
+	started      time.Time         // Time the engine was constructed
+
 	// The fields below are hooks for testing
 	shared    *Ethash       // Shared PoW verifier to avoid cache regeneration
 	fakeFail  uint64        // Block number which fails PoW check even in fake mode
 	fakeDelay time.Duration // Time delay to sleep for before returning from verify
 
-	lock      sync.Mutex // Ensures thread safety for the in-memory caches and mining fields
-	closeOnce sync.Once  // Ensures exit channel will not be closed twice.
+	lock        sync.Mutex // Ensures thread safety for the in-memory caches and mining fields
+	rangeVerify sync.Mutex // Ensures a single seal range verification runs at a time
+	closeOnce   sync.Once  // Ensures exit channel will not be closed twice.
 
 	workFeed event.Feed
 	scope    event.SubscriptionScope
@@ -450,13 +608,26 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 	if config.DatasetDir != "" && config.DatasetsOnDisk > 0 {
 		config.Log.Info("Disk storage enabled for ethash DAGs", "dir", config.DatasetDir, "count", config.DatasetsOnDisk)
 	}
+	if config.WorkFetchTimeout <= 0 {
+		config.WorkFetchTimeout = DefaultWorkFetchTimeout
+	}
+	switch config.DuplicateIDPolicy {
+	case "":
+		config.DuplicateIDPolicy = DuplicateIDSum
+	case DuplicateIDSum, DuplicateIDReplace:
+	default:
+		config.Log.Warn("Unknown duplicate hashrate ID policy, summing rates", "policy", config.DuplicateIDPolicy)
+		config.DuplicateIDPolicy = DuplicateIDSum
+	}
 	ethash := &Ethash{
 		config:   config,
 		caches:   newlru("cache", config.CachesInMem, newCache),
 		datasets: newlru("dataset", config.DatasetsInMem, newDataset),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
+		started:  time.Now(),
 	}
+	ethash.slowVerifies.limit = config.SlowVerifications
 	ethash.remote = startRemoteSealer(ethash, notify, noverify)
 	return ethash
 }
@@ -465,11 +636,12 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 // purposes.
 func NewTester(notify []string, noverify bool) *Ethash {
 	ethash := &Ethash{
-		config:   Config{PowMode: ModeTest, Log: log.Root()},
+		config:   Config{PowMode: ModeTest, WorkFetchTimeout: DefaultWorkFetchTimeout, Log: log.Root()},
 		caches:   newlru("cache", 1, newCache),
 		datasets: newlru("dataset", 1, newDataset),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeterForced(),
+		started:  time.Now(),
 	}
 	ethash.remote = startRemoteSealer(ethash, notify, noverify)
 	return ethash
@@ -484,6 +656,7 @@ func NewFaker() *Ethash {
 			PowMode: ModeFake,
 			Log:     log.Root(),
 		},
+		started: time.Now(),
 	}
 }
 
@@ -497,6 +670,7 @@ func NewFakeFailer(fail uint64) *Ethash {
 			Log:     log.Root(),
 		},
 		fakeFail: fail,
+		started:  time.Now(),
 	}
 }
 
@@ -510,6 +684,7 @@ func NewFakeDelayer(delay time.Duration) *Ethash {
 			Log:     log.Root(),
 		},
 		fakeDelay: delay,
+		started:   time.Now(),
 	}
 }
 
@@ -521,6 +696,7 @@ func NewFullFaker() *Ethash {
 			PowMode: ModeFullFake,
 			Log:     log.Root(),
 		},
+		started: time.Now(),
 	}
 }
 
@@ -548,19 +724,28 @@ func (ethash *Ethash) Close() error {
 // by first checking against a list of in-memory caches, then against caches
 // stored on disk, and finally generating one if none can be found.
 func (ethash *Ethash) cache(block uint64) *cache {
+	c, _ := ethash.cacheContext(context.Background(), block)
+	return c
+}
+
+// cacheContext is like cache, but gives up waiting for the cache generation when
+// the context is canceled.
+func (ethash *Ethash) cacheContext(ctx context.Context, block uint64) (*cache, error) {
 	epoch := block / epochLength
 	currentI, futureI := ethash.caches.get(epoch)
 	current := currentI.(*cache)
 
 	// Wait for generation finish.
-	current.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest)
+	if err := current.generateContext(ctx, ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest); err != nil {
+		return nil, err
+	}
 
 	// If we need a new future cache, now's a good time to regenerate it.
 	if futureI != nil {
 		future := futureI.(*cache)
 		go future.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest)
 	}
-	return current
+	return current, nil
 }
 
 // dataset tries to retrieve a mining dataset for the specified block number
@@ -578,20 +763,20 @@ func (ethash *Ethash) dataset(block uint64, async bool) *dataset {
 	// If async is specified, generate everything in a background thread
 	if async && !current.generated() {
 		go func() {
-			current.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest)
+			current.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest, ethash.config.GenerationIOLimitBytesPerSec, ethash.config.OnDatasetReady)
 
 			if futureI != nil {
 				future := futureI.(*dataset)
-				future.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest)
+				future.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest, ethash.config.GenerationIOLimitBytesPerSec, ethash.config.OnDatasetReady)
 			}
 		}()
 	} else {
 		// Either blocking generation was requested, or already done
-		current.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest)
+		current.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest, ethash.config.GenerationIOLimitBytesPerSec, ethash.config.OnDatasetReady)
 
 		if futureI != nil {
 			future := futureI.(*dataset)
-			go future.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest)
+			go future.generate(ethash.config.DatasetDir, ethash.config.DatasetsOnDisk, ethash.config.PowMode == ModeTest, ethash.config.GenerationIOLimitBytesPerSec, ethash.config.OnDatasetReady)
 		}
 	}
 	return current
@@ -628,14 +813,396 @@ func (ethash *Ethash) SetThreads(threads int) {
 	}
 }
 
+// SetSyncStatus sets the function queried before handing out work to remote
+// miners. If RequireSyncedForWork is enabled, no work is returned while it
+// reports the chain to be syncing. The function is called on every request, so
+// it must be cheap.
+func (ethash *Ethash) SetSyncStatus(syncing func() bool) {
+	ethash.syncing.Store(syncing)
+}
+
+// chainSyncing reports whether work requests must be refused because the chain
+// is not synced.
+func (ethash *Ethash) chainSyncing() bool {
+	if !ethash.config.RequireSyncedForWork {
+		return false
+	}
+	syncing, _ := ethash.syncing.Load().(func() bool)
+	return syncing != nil && syncing()
+}
+
+// BestShare returns the solution achieving the highest difficulty submitted by
+// remote miners since start or the last ResetBestShare, valid or not, or nil if
+// none was submitted. This tells how close the miners came to finding a block.
+func (ethash *Ethash) BestShare() *BestShare {
+	if ethash.remote == nil {
+		return nil
+	}
+	ethash.remote.bestLock.Lock()
+	defer ethash.remote.bestLock.Unlock()
+
+	if ethash.remote.best == nil {
+		return nil
+	}
+	best := *ethash.remote.best
+	best.Difficulty = new(big.Int).Set(best.Difficulty)
+	return &best
+}
+
+// ResetBestShare forgets the best share submitted so far.
+func (ethash *Ethash) ResetBestShare() {
+	if ethash.remote == nil {
+		return
+	}
+	ethash.remote.bestLock.Lock()
+	defer ethash.remote.bestLock.Unlock()
+
+	ethash.remote.best = nil
+}
+
+// VerificationMode returns whether seals are verified using the full dataset
+// (VerifyFull) or the ethash cache (VerifyLight).
+func (ethash *Ethash) VerificationMode() string {
+	// If we're running a shared PoW, report the mode of that instead
+	if ethash.shared != nil {
+		return ethash.shared.VerificationMode()
+	}
+	if atomic.LoadUint32(&ethash.fullVerify) == 1 {
+		return VerifyFull
+	}
+	return VerifyLight
+}
+
+// SetVerificationMode switches seal verification between the full dataset and
+// the ethash cache. Verifications in progress finish in the mode they were
+// started in. Datasets are generated in the background on demand, until they
+// are ready seals are still verified using the cache.
+func (ethash *Ethash) SetVerificationMode(mode string) error {
+	var full uint32
+	switch mode {
+	case VerifyFull:
+		full = 1
+	case VerifyLight:
+	default:
+		return fmt.Errorf("invalid verification mode %q", mode)
+	}
+	// If we're running a shared PoW, set the mode on that instead
+	if ethash.shared != nil {
+		return ethash.shared.SetVerificationMode(mode)
+	}
+	atomic.StoreUint32(&ethash.fullVerify, full)
+	return nil
+}
+
+// EffectiveConfig returns the configuration the engine runs with, after defaults
+// were applied and invalid values sanitized, along with its protocol thresholds.
+// The key names are stable. Credentials in the URLs of notified remote miners are
+// redacted.
+func (ethash *Ethash) EffectiveConfig() map[string]interface{} {
+	// If we're running a shared PoW, report the configuration of that instead
+	if ethash.shared != nil {
+		config := ethash.shared.EffectiveConfig()
+		config["powMode"] = ModeShared.String()
+		return config
+	}
+	var (
+		notify   = []string{}
+		noverify bool
+		target   interface{}
+		frozen   interface{}
+	)
+	if ethash.remote != nil {
+		for _, rawurl := range ethash.remote.notifyURLs {
+			notify = append(notify, redactURL(rawurl))
+		}
+		noverify = ethash.remote.noverify
+	}
+	if override, _ := ethash.target.Load().(*big.Int); override != nil && override.Sign() > 0 {
+		target = common.BigToHash(override)
+	}
+	if difficulty := ethash.frozenDifficulty(); difficulty != nil {
+		frozen = (*hexutil.Big)(difficulty)
+	}
+	return map[string]interface{}{
+		"powMode":                ethash.config.PowMode.String(),
+		"epochLength":            uint64(epochLength),
+		"cacheDir":               ethash.config.CacheDir,
+		"cachesInMem":            ethash.config.CachesInMem,
+		"cachesOnDisk":           ethash.config.CachesOnDisk,
+		"datasetDir":             ethash.config.DatasetDir,
+		"datasetsInMem":          ethash.config.DatasetsInMem,
+		"datasetsOnDisk":         ethash.config.DatasetsOnDisk,
+		"verificationMode":       ethash.VerificationMode(),
+		"hashrateFloor":          ethash.config.HashrateFloor,
+		"duplicateIDPolicy":      ethash.config.DuplicateIDPolicy,
+		"requireSyncedForWork":   ethash.config.RequireSyncedForWork,
+		"generationIOLimit":      ethash.config.GenerationIOLimitBytesPerSec,
+		"workFetchTimeout":       ethash.config.WorkFetchTimeout.String(),
+		"slowVerifications":      ethash.config.SlowVerifications,
+		"nearMissDifficulty":     ethash.config.NearMissDifficulty,
+		"maxUncles":              params.MaxUncles,
+		"staleThreshold":         staleThreshold,
+		"allowedFutureBlockTime": allowedFutureBlockTime.String(),
+		"workTargetOverride":     target,
+		"frozenDifficulty":       frozen,
+		"notify":                 notify,
+		"noverify":               noverify,
+	}
+}
+
+// redactURL strips the credentials and the query, which may contain access
+// tokens, from the given URL.
+func redactURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "<redacted>"
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	return u.String()
+}
+
+// SetWorkTargetOverride makes the engine serve remote work with the given, easier
+// boundary and accept seals meeting it, while blocks keep their real difficulty.
+// This allows tests on development chains to find blocks instantly. The override
+// applies only if it is easier than the real target of a block, the zero hash
+// removes it.
+//
+// Blocks sealed against the override are invalid for other nodes, so it is not
+// available in ModeNormal and ModeShared.
+func (ethash *Ethash) SetWorkTargetOverride(target common.Hash) error {
+	if ethash.config.PowMode == ModeNormal || ethash.config.PowMode == ModeShared {
+		return errTargetOverride
+	}
+	ethash.target.Store(target.Big())
+	return nil
+}
+
+// workTarget returns the target seals of blocks with the given difficulty have
+// to meet, taking the target override into account.
+func (ethash *Ethash) workTarget(difficulty *big.Int) *big.Int {
+	target := new(big.Int).Div(two256, difficulty)
+	if override, _ := ethash.target.Load().(*big.Int); override != nil && override.Cmp(target) > 0 {
+		return override
+	}
+	return target
+}
+
+// FreezeDifficulty pins the difficulty of the blocks sealed and accepted by the
+// engine to the given value, instead of adjusting it to the block times. This
+// gives benchmarks of block production a stable target across template rebuilds.
+//
+// Blocks with a frozen difficulty are invalid for other nodes, so it is not
+// available in ModeNormal and ModeShared.
+func (ethash *Ethash) FreezeDifficulty(difficulty *big.Int) error {
+	if ethash.config.PowMode == ModeNormal || ethash.config.PowMode == ModeShared {
+		return errDifficultyFreeze
+	}
+	if difficulty == nil || difficulty.Sign() <= 0 {
+		return errInvalidDifficulty
+	}
+	ethash.difficulty.Store(new(big.Int).Set(difficulty))
+	return nil
+}
+
+// UnfreezeDifficulty returns to adjusting the difficulty of blocks to their times.
+func (ethash *Ethash) UnfreezeDifficulty() {
+	ethash.difficulty.Store((*big.Int)(nil))
+}
+
+// frozenDifficulty returns the difficulty set by FreezeDifficulty, or nil if the
+// difficulty isn't frozen.
+func (ethash *Ethash) frozenDifficulty() *big.Int {
+	difficulty, _ := ethash.difficulty.Load().(*big.Int)
+	return difficulty
+}
+
+// verifyStats counts seal verifications and their total duration. The fields
+// are accessed atomically.
+type verifyStats struct {
+	verified uint64 // Number of valid seals
+	failed   uint64 // Number of invalid seals
+	duration uint64 // Total time spent verifying, in nanoseconds
+}
+
+func (s *verifyStats) record(elapsed time.Duration, valid bool) {
+	if valid {
+		atomic.AddUint64(&s.verified, 1)
+	} else {
+		atomic.AddUint64(&s.failed, 1)
+	}
+	atomic.AddUint64(&s.duration, uint64(elapsed))
+}
+
+// GetVerifyStats returns the number of valid and invalid seals verified since the
+// engine was started or the statistics were last reset, along with the average
+// duration of a verification in milliseconds. Verifications aborted through their
+// context are not counted.
+func (ethash *Ethash) GetVerifyStats() (verified uint64, failed uint64, avgDurationMs float64) {
+	// If we're running a shared PoW, report the statistics of that instead
+	if ethash.shared != nil {
+		return ethash.shared.GetVerifyStats()
+	}
+	verified = atomic.LoadUint64(&ethash.verifyStats.verified)
+	failed = atomic.LoadUint64(&ethash.verifyStats.failed)
+	duration := atomic.LoadUint64(&ethash.verifyStats.duration)
+	if total := verified + failed; total > 0 {
+		avgDurationMs = float64(duration) / float64(total) / float64(time.Millisecond)
+	}
+	return verified, failed, avgDurationMs
+}
+
+// ResetVerifyStats clears the seal verification statistics. Verifications running
+// concurrently with the reset may be counted partially.
+func (ethash *Ethash) ResetVerifyStats() {
+	// If we're running a shared PoW, reset the statistics of that instead
+	if ethash.shared != nil {
+		ethash.shared.ResetVerifyStats()
+		return
+	}
+	atomic.StoreUint64(&ethash.verifyStats.verified, 0)
+	atomic.StoreUint64(&ethash.verifyStats.failed, 0)
+	atomic.StoreUint64(&ethash.verifyStats.duration, 0)
+	ethash.slowVerifies.reset()
+}
+
+// SlowVerifyRecord is a seal verification tracked by GetSlowVerifications.
+type SlowVerifyRecord struct {
+	Number     hexutil.Uint64 `json:"number"`
+	Hash       common.Hash    `json:"hash"`
+	DurationMs float64        `json:"durationMs"`
+	Valid      bool           `json:"valid"`
+}
+
+// slowVerifyTracker keeps the slowest seal verifications in a min-heap ordered by
+// duration, so a verification only needs to be compared against the fastest one
+// tracked. Once the heap is full, the duration of that one is also published in
+// an atomic floor, sparing the faster verifications from taking the lock.
+type slowVerifyTracker struct {
+	floor int64 // Duration of the fastest tracked verification once full (atomic)
+	limit int   // Number of verifications to track, disabled if not positive
+
+	records slowVerifyHeap
+	lock    sync.Mutex
+}
+
+// slowVerifyHeap is a min-heap of verifications ordered by duration.
+type slowVerifyHeap []slowVerifyEntry
+
+type slowVerifyEntry struct {
+	header  *types.Header
+	elapsed time.Duration
+	valid   bool
+}
+
+func (h slowVerifyHeap) Len() int            { return len(h) }
+func (h slowVerifyHeap) Less(i, j int) bool  { return h[i].elapsed < h[j].elapsed }
+func (h slowVerifyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slowVerifyHeap) Push(x interface{}) { *h = append(*h, x.(slowVerifyEntry)) }
+func (h *slowVerifyHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// record tracks a verification if it's among the slowest ones. The header is
+// copied only if it is tracked.
+func (t *slowVerifyTracker) record(header *types.Header, elapsed time.Duration, valid bool) {
+	if t.limit <= 0 || int64(elapsed) <= atomic.LoadInt64(&t.floor) {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	entry := slowVerifyEntry{header: types.CopyHeader(header), elapsed: elapsed, valid: valid}
+	switch {
+	case len(t.records) < t.limit:
+		heap.Push(&t.records, entry)
+	case elapsed > t.records[0].elapsed:
+		t.records[0] = entry
+		heap.Fix(&t.records, 0)
+	default:
+		return
+	}
+	if len(t.records) == t.limit {
+		atomic.StoreInt64(&t.floor, int64(t.records[0].elapsed))
+	}
+}
+
+// slowest returns the tracked verifications, the slowest first.
+func (t *slowVerifyTracker) slowest() []SlowVerifyRecord {
+	t.lock.Lock()
+	entries := append([]slowVerifyEntry{}, t.records...)
+	t.lock.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].elapsed > entries[j].elapsed })
+	records := make([]SlowVerifyRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = SlowVerifyRecord{
+			Number:     hexutil.Uint64(entry.header.Number.Uint64()),
+			Hash:       entry.header.Hash(),
+			DurationMs: float64(entry.elapsed) / float64(time.Millisecond),
+			Valid:      entry.valid,
+		}
+	}
+	return records
+}
+
+// reset drops all tracked verifications.
+func (t *slowVerifyTracker) reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.records = nil
+	atomic.StoreInt64(&t.floor, 0)
+}
+
+// GetSlowVerifications returns the slowest seal verifications since the engine
+// was started or the verification statistics were last reset, the slowest first.
+// The number of verifications tracked is set by Config.SlowVerifications; none
+// are tracked by default. Slow verifications point at blocks which hit cold
+// caches or datasets, or other slow paths.
+func (ethash *Ethash) GetSlowVerifications() []SlowVerifyRecord {
+	// If we're running a shared PoW, report the verifications of that instead
+	if ethash.shared != nil {
+		return ethash.shared.GetSlowVerifications()
+	}
+	return ethash.slowVerifies.slowest()
+}
+
+// StartTime returns the time the engine was constructed.
+func (ethash *Ethash) StartTime() time.Time {
+	// If we're running a shared PoW, report the start of that instead
+	if ethash.shared != nil {
+		return ethash.shared.StartTime()
+	}
+	return ethash.started
+}
+
+// GetEngineUptime returns how long the engine has been running since it was
+// constructed, giving context to the dataset generations and memory usage.
+func (ethash *Ethash) GetEngineUptime() time.Duration {
+	return time.Since(ethash.StartTime())
+}
+
 // Hashrate implements PoW, returning the measured rate of the search invocations
 // per second over the last minute.
 // Note the returned hashrate includes local hashrate, but also includes the total
 // hashrate of all remote miner.
 func (ethash *Ethash) Hashrate() float64 {
+	// Discard the local hash rate if it's below the reporting floor
+	local := ethash.hashrate.Rate1()
+	if local < float64(ethash.config.HashrateFloor) {
+		local = 0
+	}
 	// Short circuit if we are run the ethash in normal/test mode.
 	if ethash.config.PowMode != ModeNormal && ethash.config.PowMode != ModeTest {
-		return ethash.hashrate.Rate1()
+		return local
 	}
 	var res = make(chan uint64, 1)
 
@@ -643,36 +1210,49 @@ func (ethash *Ethash) Hashrate() float64 {
 	case ethash.remote.fetchRateCh <- res:
 	case <-ethash.remote.exitCh:
 		// Return local hashrate only if ethash is stopped.
-		return ethash.hashrate.Rate1()
+		return local
 	}
 
 	// Gather total submitted hash rate of remote sealers.
-	return ethash.hashrate.Rate1() + float64(<-res)
+	return local + float64(<-res)
 }
 
 // APIs implements consensus.Engine, returning the user facing RPC APIs.
 func (ethash *Ethash) APIs(chain consensus.ChainReader) []rpc.API {
-	// In order to ensure backward compatibility, we exposes ethash RPC APIs
-	// to both eth and ethash namespaces.
+	// In order to ensure backward compatibility, we exposes the remote mining
+	// RPC APIs to both eth and ethash namespaces. Methods inspecting the engine
+	// are only exposed in the ethash namespace, and methods altering it only in
+	// the private ethash namespace.
 	return []rpc.API{
 		{
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   &API{ethash},
+			Service:   &API{ethash, chain},
 			Public:    true,
 		},
 		{
 			Namespace: "ethash",
 			Version:   "1.0",
-			Service:   &API{ethash},
+			Service:   &API{ethash, chain},
 			Public:    true,
 		},
 		{
 			Namespace: "parity",
 			Version:   "1.0",
-			Service:   &API{ethash},
+			Service:   &API{ethash, chain},
 			Public:    true,
 		},
+		{
+			Namespace: "ethash",
+			Version:   "1.0",
+			Service:   &EthashAPI{ethash, chain},
+			Public:    true,
+		},
+		{
+			Namespace: "ethash",
+			Version:   "1.0",
+			Service:   &PrivateAPI{ethash, chain},
+		},
 	}
 }
 
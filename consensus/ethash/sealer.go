@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -45,6 +46,7 @@ const (
 var (
 	errNoMiningWork      = errors.New("no mining work available yet")
 	errInvalidSealResult = errors.New("invalid or stale proof-of-work solution")
+	errUnknownWork       = errors.New("unknown or stale work")
 )
 
 // Seal implements consensus.Engine, attempting to find a nonce that satisfies
@@ -139,6 +141,11 @@ func (ethash *Ethash) mine(block *types.Block, id int, seed uint64, abort chan s
 		number  = header.Number.Uint64()
 		dataset = ethash.dataset(number, false)
 	)
+	// Track the hashes meeting the near-miss difficulty if requested
+	var nearTarget *big.Int
+	if ethash.config.OnNearMiss != nil && ethash.config.NearMissDifficulty > 0 {
+		nearTarget = new(big.Int).Div(two256, new(big.Int).SetUint64(ethash.config.NearMissDifficulty))
+	}
 	// Start generating random nonces until we abort or find a good one
 	var (
 		attempts = int64(0)
@@ -164,7 +171,8 @@ search:
 			}
 			// Compute the PoW value of this nonce
 			digest, result := hashimotoFull(dataset.dataset, hash, nonce)
-			if new(big.Int).SetBytes(result).Cmp(target) <= 0 {
+			value := new(big.Int).SetBytes(result)
+			if value.Cmp(target) <= 0 {
 				// Correct nonce found, create a new header with it
 				header = types.CopyHeader(header)
 				header.Nonce = types.EncodeNonce(nonce)
@@ -179,6 +187,9 @@ search:
 				}
 				break search
 			}
+			if nearTarget != nil && value.Cmp(nearTarget) <= 0 {
+				ethash.config.OnNearMiss(nonce, new(big.Int).Div(two256, value))
+			}
 			nonce++
 		}
 	}
@@ -187,14 +198,35 @@ search:
 	runtime.KeepAlive(dataset)
 }
 
+// solve searches the nonces from zero for one satisfying the difficulty of the
+// header, returning it along with the resulting mix digest.
+func (ethash *Ethash) solve(header *types.Header) (types.BlockNonce, common.Hash) {
+	var (
+		hash    = ethash.SealHash(header).Bytes()
+		target  = new(big.Int).Div(two256, header.Difficulty)
+		dataset = ethash.dataset(header.Number.Uint64(), false)
+	)
+	// Datasets are unmapped in a finalizer. Ensure that the dataset stays live
+	// during the search so it's not unmapped while being read.
+	defer runtime.KeepAlive(dataset)
+
+	for nonce := uint64(0); ; nonce++ {
+		digest, result := hashimotoFull(dataset.dataset, hash, nonce)
+		if new(big.Int).SetBytes(result).Cmp(target) <= 0 {
+			return types.EncodeNonce(nonce), common.BytesToHash(digest)
+		}
+	}
+}
+
 // This is the timeout for HTTP requests to notify external miners.
 const remoteSealerTimeout = 1 * time.Second
 
 type remoteSealer struct {
 	works        map[common.Hash]*types.Block
-	rates        map[common.Hash]hashrate
+	rates        map[rateKey]hashrate
 	currentBlock *types.Block
 	currentWork  [10]string
+	currentInput []byte // RLP encoded header hashed into the pow-hash of the current work
 	notifyCtx    context.Context
 	cancelNotify context.CancelFunc // cancels all notification requests
 	reqWG        sync.WaitGroup     // tracks notification request goroutines
@@ -210,6 +242,17 @@ type remoteSealer struct {
 	submitRateCh chan *hashrate   // Channel used for remote sealer to submit their mining hashrate
 	requestExit  chan struct{}
 	exitCh       chan struct{}
+
+	best     *BestShare // Highest difficulty share submitted since start or last reset
+	bestLock sync.Mutex // Protects the best share, which is read outside of the loop
+}
+
+// BestShare is the submitted solution achieving the highest difficulty, see
+// Ethash.BestShare.
+type BestShare struct {
+	Difficulty *big.Int    // Difficulty achieved by the solution
+	Miner      common.Hash // Miner the solution is attributed to, zero if unknown
+	Time       time.Time   // Time the solution was submitted
 }
 
 // sealTask wraps a seal block with relative result channel for remote sealer thread.
@@ -220,28 +263,40 @@ type sealTask struct {
 
 // mineResult wraps the pow solution parameters for the specified block.
 type mineResult struct {
-	nonce     types.BlockNonce
-	mixDigest common.Hash
-	hash      common.Hash
+	nonce      types.BlockNonce
+	mixDigest  common.Hash
+	hash       common.Hash
 	extraNonce []byte
+	miner      common.Hash // Miner the solution is attributed to, zero if unknown
 
-	errc chan error
+	errc        chan error
 	blockHashCh chan common.Hash
 }
 
+// rateKey identifies the hash rate of a remote miner from a specific source.
+type rateKey struct {
+	id     common.Hash
+	source string
+}
+
 // hashrate wraps the hash rate submitted by the remote sealer.
 type hashrate struct {
-	id   common.Hash
-	ping time.Time
-	rate uint64
+	id     common.Hash
+	source string // Host the rate was submitted from, empty if unknown
+	ping   time.Time
+	rate   uint64
 
 	done chan struct{}
 }
 
-// sealWork wraps a seal work package for remote sealer.
+// sealWork wraps a seal work package for remote sealer. If input is set, the
+// hashing input of the work is returned instead of the package.
 type sealWork struct {
-	errc chan error
-	res  chan [10]string
+	errc  chan error
+	res   chan [10]string
+	input chan []byte
+	hash  common.Hash       // pow-hash of the work to return the pending block of, current work if zero
+	block chan *types.Block // pending block of the work, if requested
 }
 
 func startRemoteSealer(ethash *Ethash, urls []string, noverify bool) *remoteSealer {
@@ -253,7 +308,7 @@ func startRemoteSealer(ethash *Ethash, urls []string, noverify bool) *remoteSeal
 		notifyCtx:    ctx,
 		cancelNotify: cancel,
 		works:        make(map[common.Hash]*types.Block),
-		rates:        make(map[common.Hash]hashrate),
+		rates:        make(map[rateKey]hashrate),
 		workCh:       make(chan *sealTask),
 		fetchWorkCh:  make(chan *sealWork),
 		submitWorkCh: make(chan *mineResult),
@@ -288,15 +343,26 @@ func (s *remoteSealer) loop() {
 
 		case work := <-s.fetchWorkCh:
 			// Return current mining work to remote miner.
-			if s.currentBlock == nil {
+			switch {
+			case s.currentBlock == nil:
 				work.errc <- errNoMiningWork
-			} else {
+			case work.input != nil:
+				work.input <- s.currentInput
+			case work.block != nil && work.hash == (common.Hash{}):
+				work.block <- s.currentBlock
+			case work.block != nil:
+				if block := s.works[work.hash]; block != nil {
+					work.block <- block
+				} else {
+					work.errc <- errUnknownWork
+				}
+			default:
 				work.res <- s.currentWork
 			}
 
 		case result := <-s.submitWorkCh:
 			// Verify submitted PoW solution based on maintained mining blocks.
-			blockHash, err := s.submitWork(result.nonce, result.mixDigest, result.hash, result.extraNonce)
+			blockHash, err := s.submitWork(result.nonce, result.mixDigest, result.hash, result.extraNonce, result.miner)
 			if err == nil {
 				result.blockHashCh <- blockHash
 			} else {
@@ -304,14 +370,27 @@ func (s *remoteSealer) loop() {
 			}
 
 		case result := <-s.submitRateCh:
-			// Trace remote sealer's hash rate by submitted value.
-			s.rates[result.id] = hashrate{rate: result.rate, ping: time.Now()}
+			// Trace remote sealer's hash rate by submitted value. Rates of
+			// other sources under the same id are dropped if they shouldn't
+			// be summed up.
+			if s.ethash.config.DuplicateIDPolicy == DuplicateIDReplace {
+				for key := range s.rates {
+					if key.id == result.id && key.source != result.source {
+						delete(s.rates, key)
+					}
+				}
+			}
+			s.rates[rateKey{result.id, result.source}] = hashrate{rate: result.rate, ping: time.Now()}
 			close(result.done)
 
 		case req := <-s.fetchRateCh:
-			// Gather all hash rate submitted by remote sealer.
+			// Gather all hash rate submitted by remote sealer, skipping
+			// the ones below the reporting floor.
 			var total uint64
 			for _, rate := range s.rates {
+				if rate.rate < s.ethash.config.HashrateFloor {
+					continue
+				}
 				// this could overflow
 				total += rate.rate
 			}
@@ -356,8 +435,9 @@ func (s *remoteSealer) makeWork(block *types.Block) {
 	hash := s.ethash.SealHash(header)
 
 	s.currentWork[0] = hash.Hex()
+	s.currentInput = s.ethash.SealHashInput(header)
 	s.currentWork[1] = common.BytesToHash(SeedHash(block.NumberU64())).Hex()
-	s.currentWork[2] = common.BytesToHash(new(big.Int).Div(two256, block.Difficulty()).Bytes()).Hex()
+	s.currentWork[2] = common.BytesToHash(s.ethash.workTarget(block.Difficulty()).Bytes()).Hex()
 	s.currentWork[3] = hexutil.EncodeBig(block.Number())
 	s.currentWork[4] = block.ParentHash().Hex()
 	s.currentWork[5] = hexutil.EncodeUint64(block.GasLimit())
@@ -424,9 +504,46 @@ func (s *remoteSealer) sendNotification(ctx context.Context, url string, json []
 	}
 }
 
+// trackShare computes the difficulty achieved by a submitted solution, whether it
+// meets the target or not, and records it if it's the best share so far.
+func (s *remoteSealer) trackShare(header *types.Header, miner common.Hash) {
+	if s.ethash.config.PowMode == ModeFake || s.ethash.config.PowMode == ModeFullFake {
+		return
+	}
+	pow := s.ethash
+	if pow.shared != nil {
+		pow = pow.shared
+	}
+	digest, result, err := pow.powValues(context.Background(), header, atomic.LoadUint32(&pow.fullVerify) == 1)
+	if err != nil {
+		return
+	}
+	s.recordShare(header, digest, result, miner)
+}
+
+// recordShare records the difficulty achieved by a submitted solution with the
+// given PoW values if it's the best share so far. Solutions with a wrong mix
+// digest achieve nothing.
+func (s *remoteSealer) recordShare(header *types.Header, digest, result []byte, miner common.Hash) {
+	if !bytes.Equal(digest, header.MixDigest[:]) {
+		return
+	}
+	// The difficulty achieved is the one whose target the final hash just meets
+	achieved := new(big.Int).Set(two256)
+	if value := new(big.Int).SetBytes(result); value.Sign() > 0 {
+		achieved.Div(achieved, value)
+	}
+	s.bestLock.Lock()
+	defer s.bestLock.Unlock()
+
+	if s.best == nil || achieved.Cmp(s.best.Difficulty) > 0 {
+		s.best = &BestShare{Difficulty: achieved, Miner: miner, Time: time.Now()}
+	}
+}
+
 // submitWork verifies the submitted pow solution, returning
 // its block hash when success or an error when failed.
-func (s *remoteSealer) submitWork(nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash, extraNonce []byte) (blockHash common.Hash, err error) {
+func (s *remoteSealer) submitWork(nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash, extraNonce []byte, miner common.Hash) (blockHash common.Hash, err error) {
 	if s.currentBlock == nil {
 		err = errors.New("Pending work without block")
 		s.ethash.config.Log.Error(err.Error(), "sealhash", sealhash)
@@ -445,9 +562,14 @@ func (s *remoteSealer) submitWork(nonce types.BlockNonce, mixDigest common.Hash,
 	header.MixDigest = mixDigest
 	header.Extra = append(header.Extra, extraNonce...)
 
+	// Track the share achieved by the solution, reusing the PoW values computed
+	// by the seal verification if there's one.
 	start := time.Now()
-	if !s.noverify {
-		if ethashErr := s.ethash.verifySeal(nil, header, false); ethashErr != nil {
+	if s.noverify {
+		s.trackShare(header, miner)
+	} else {
+		track := func(digest, result []byte) { s.recordShare(header, digest, result, miner) }
+		if ethashErr := s.ethash.verifySealWith(context.Background(), header, false, track); ethashErr != nil {
 			err = errors.New("Invalid proof-of-work submitted")
 			s.ethash.config.Log.Warn(err.Error(), "sealhash", sealhash, "elapsed", common.PrettyDuration(time.Since(start)), "err", ethashErr)
 			return
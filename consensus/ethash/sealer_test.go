@@ -117,7 +117,7 @@ func TestRemoteMultiNotify(t *testing.T) {
 func TestStaleSubmission(t *testing.T) {
 	ethash := NewTester(nil, true)
 	defer ethash.Close()
-	api := &API{ethash}
+	api := &API{ethash: ethash}
 
 	fakeNonce, fakeDigest := types.BlockNonce{0x01, 0x02, 0x03}, common.HexToHash("deadbeef")
 
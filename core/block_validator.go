@@ -19,6 +19,7 @@ package core
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -55,6 +56,9 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	}
 	// Header validity is known at this point, check the uncles and transactions
 	header := block.Header()
+	if err := v.validateUncles(block); err != nil {
+		return err
+	}
 	if err := v.engine.VerifyUncles(v.bc, block); err != nil {
 		return err
 	}
@@ -73,6 +77,55 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	return nil
 }
 
+// validateUncles checks the consensus engine independent inclusion rules of the
+// block's uncles: their count, their distance from the including block, their
+// uniqueness and that none of them is an ancestor of the including block.
+func (v *BlockValidator) validateUncles(block *types.Block) error {
+	uncles := block.Uncles()
+	if len(uncles) > params.MaxUncles {
+		return ErrTooManyUncles
+	}
+	if len(uncles) == 0 {
+		return nil
+	}
+	// Gather the ancestors the uncles may not coincide with
+	ancestors := make(map[common.Hash]struct{})
+
+	number, parent := block.NumberU64()-1, block.ParentHash()
+	for i := 0; i < params.MaxUncleDepth; i++ {
+		header := v.bc.GetHeader(parent, number)
+		if header == nil {
+			break
+		}
+		ancestors[header.Hash()] = struct{}{}
+		if number == 0 {
+			break
+		}
+		parent, number = header.ParentHash, number-1
+	}
+	included := make(map[common.Hash]struct{})
+	for _, uncle := range uncles {
+		// Make sure the uncle is within the permitted generations
+		if uncle.Number == nil || !uncle.Number.IsUint64() {
+			return ErrUncleOutOfRange
+		}
+		if num := uncle.Number.Uint64(); num >= block.NumberU64() || num+params.MaxUncleDepth < block.NumberU64() {
+			return ErrUncleOutOfRange
+		}
+		// Make sure the uncle is included only once and is not on our own chain
+		hash := uncle.Hash()
+		if _, ok := included[hash]; ok {
+			return ErrDuplicateUncle
+		}
+		included[hash] = struct{}{}
+
+		if _, ok := ancestors[hash]; ok {
+			return ErrUncleIsAncestor
+		}
+	}
+	return nil
+}
+
 // ValidateState validates the various changes that happen after a state
 // transition, such as amount of used gas, the receipt roots and the state root
 // itself. ValidateState returns a database batch if the validation was a success
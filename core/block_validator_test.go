@@ -17,10 +17,12 @@
 package core
 
 import (
+	"math/big"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -197,3 +199,50 @@ func testHeaderConcurrentAbortion(t *testing.T, threads int) {
 		t.Errorf("verification count too large: have %d, want below %d", verified, 2*threads)
 	}
 }
+
+// Tests that the uncle inclusion rules are enforced by the block validator, even
+// if the consensus engine accepts anything.
+func TestUncleValidation(t *testing.T) {
+	var (
+		testdb    = rawdb.NewMemoryDatabase()
+		gspec     = &Genesis{Config: params.TestChainConfig}
+		genesis   = gspec.MustCommit(testdb)
+		blocks, _ = GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), testdb, 8, nil)
+		forks, _  = GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), testdb, 8, func(i int, b *BlockGen) {
+			b.SetCoinbase(common.Address{0x01})
+		})
+	)
+	chain, _ := NewBlockChain(testdb, nil, params.TestChainConfig, ethash.NewFullFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:7]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	parent := blocks[6]
+	tests := []struct {
+		uncles []*types.Header
+		err    error
+	}{
+		{[]*types.Header{forks[5].Header(), forks[4].Header()}, nil},
+		{[]*types.Header{forks[5].Header(), forks[4].Header(), forks[3].Header()}, ErrTooManyUncles},
+		{[]*types.Header{forks[7].Header()}, ErrUncleOutOfRange},
+		{[]*types.Header{forks[1].Header()}, nil},
+		{[]*types.Header{forks[0].Header()}, ErrUncleOutOfRange},
+		{[]*types.Header{genesis.Header()}, ErrUncleOutOfRange},
+		{[]*types.Header{forks[5].Header(), forks[5].Header()}, ErrDuplicateUncle},
+		{[]*types.Header{blocks[3].Header()}, ErrUncleIsAncestor},
+	}
+	for i, tt := range tests {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			Difficulty: parent.Difficulty(),
+			GasLimit:   parent.GasLimit(),
+			Time:       parent.Time() + 10,
+		}
+		block := types.NewBlock(header, nil, tt.uncles, nil)
+		if err := chain.Validator().ValidateBody(block); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
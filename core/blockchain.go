@@ -38,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/shutdowncheck"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
@@ -82,6 +83,8 @@ const (
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
+	bodyPruneInterval   = time.Minute
+	bodyPruneBatch      = 10000
 	TriesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
@@ -114,7 +117,9 @@ type CacheConfig struct {
 	TrieCleanNoPrefetch bool          // Whether to disable heuristic state prefetching for followup blocks
 	TrieDirtyLimit      int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
+	TrieDirtyCap        int           // Hard memory cap (MB) forcing synchronous flushes of dirty trie nodes (0 = disabled)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
+	TxHistory           uint64        // Number of recent blocks to retain transaction bodies for (0 = retain all)
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -140,12 +145,12 @@ type BlockChain struct {
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
 	hc            *HeaderChain
-	rmLogsFeed    event.Feed
-	chainFeed     event.Feed
-	chainSideFeed event.Feed
-	chainHeadFeed event.Feed
-	logsFeed      event.Feed
-	blockProcFeed event.Feed
+	rmLogsFeed    event.FeedOf[RemovedLogsEvent]
+	chainFeed     event.FeedOf[ChainEvent]
+	chainSideFeed event.FeedOf[ChainSideEvent]
+	chainHeadFeed event.FeedOf[ChainHeadEvent]
+	logsFeed      event.FeedOf[[]*types.Log]
+	blockProcFeed event.FeedOf[bool]
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -216,6 +221,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		vmConfig:       vmConfig,
 		badBlocks:      badBlocks,
 	}
+	if cacheConfig.TrieDirtyCap > 0 {
+		bc.stateCache.TrieDB().SetDirtyCap(common.StorageSize(cacheConfig.TrieDirtyCap) * 1024 * 1024)
+	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
@@ -295,6 +303,12 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Only start body pruning if a transaction history limit was requested
+	if cacheConfig.TxHistory > 0 {
+		bc.wg.Add(1)
+		go bc.pruneLoop()
+	}
 	return bc, nil
 }
 
@@ -594,16 +608,25 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	log.Info("Exporting batch of blocks", "count", last-first+1)
 
 	start, reported := time.Now(), time.Now()
-	for nr := first; nr <= last; nr++ {
-		block := bc.GetBlockByNumber(nr)
-		if block == nil {
-			return fmt.Errorf("export failed on #%d: not found", nr)
+	for nr := first; nr <= last; {
+		// Read ancient blocks in batches, the recent ones individually
+		blocks := bc.ancientBlocks(nr, last-nr+1)
+		if len(blocks) == 0 {
+			block := bc.GetBlockByNumber(nr)
+			if block == nil {
+				return fmt.Errorf("export failed on #%d: not found", nr)
+			}
+			blocks = []*types.Block{block}
 		}
-		if err := block.EncodeRLP(w); err != nil {
-			return err
+		for _, block := range blocks {
+			if err := block.EncodeRLP(w); err != nil {
+				return err
+			}
 		}
+		nr += uint64(len(blocks))
+
 		if time.Since(reported) >= statsReportLimit {
-			log.Info("Exporting blocks", "exported", block.NumberU64()-first, "elapsed", common.PrettyDuration(time.Since(start)))
+			log.Info("Exporting blocks", "exported", nr-first, "elapsed", common.PrettyDuration(time.Since(start)))
 			reported = time.Now()
 		}
 	}
@@ -828,14 +851,27 @@ func (bc *BlockChain) Stop() {
 	if !bc.cacheConfig.TrieDirtyDisabled {
 		triedb := bc.stateCache.TrieDB()
 
+		// Report the flush progress by the shrinkage of the dirty cache. The task
+		// is registered upfront, as the first commit is by far the longest.
+		dirty, _ := triedb.Size()
+		progress := func(size common.StorageSize) {
+			if size < dirty {
+				shutdowncheck.Progress("flushing trie cache", uint64(dirty-size), uint64(dirty))
+			}
+		}
+		shutdowncheck.Progress("flushing trie cache", 0, uint64(dirty))
+		defer shutdowncheck.Done("flushing trie cache")
+
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
 
 				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
-				if err := triedb.Commit(recent.Root(), true); err != nil {
+				if err := triedb.CommitWithProgress(recent.Root(), true, progress); err != nil {
 					log.Error("Failed to commit recent state trie", "err", err)
 				}
+				size, _ := triedb.Size()
+				progress(size)
 			}
 		}
 		for !bc.triegc.Empty() {
@@ -2047,6 +2083,58 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// pruneLoop periodically discards the transactions and uncles of all the frozen
+// blocks beyond the configured transaction history limit.
+func (bc *BlockChain) pruneLoop() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(bodyPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			bc.pruneBodies()
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// pruneBodies discards the transactions and uncles of the frozen blocks beyond
+// the configured transaction history limit. Bodies are pruned in batches, each
+// persisting its progress, so an interrupted run never rescans pruned blocks and
+// shutdown is not held up by a long pruning backlog.
+func (bc *BlockChain) pruneBodies() {
+	head := bc.CurrentBlock().NumberU64()
+	if head <= bc.cacheConfig.TxHistory {
+		return
+	}
+	target := head - bc.cacheConfig.TxHistory
+	for {
+		tail := rawdb.ReadBodyPruneTail(bc.db)
+		if tail >= target {
+			return
+		}
+		upToBlock := target
+		if upToBlock > tail+bodyPruneBatch {
+			upToBlock = tail + bodyPruneBatch
+		}
+		if err := rawdb.PruneBlockBodies(bc.db, upToBlock); err != nil {
+			log.Warn("Failed to prune block bodies", "err", err)
+			return
+		}
+		// Stop if the remaining blocks are not frozen yet, or if we're shutting down
+		if rawdb.ReadBodyPruneTail(bc.db) == tail {
+			return
+		}
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+	}
+}
+
 // BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
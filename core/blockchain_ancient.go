@@ -0,0 +1,234 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ancientReadBatch is the maximum number of blocks to retrieve from the ancient
+// store at once when assembling full blocks.
+const ancientReadBatch = 256
+
+// ancientRun checks whether the first of the given blocks is in the ancient store
+// and if so, returns its number along with the count of the given blocks directly
+// succeeding each other in the ancient store, starting with it.
+func (bc *BlockChain) ancientRun(hashes []common.Hash) (uint64, int) {
+	frozen, err := bc.db.Ancients()
+	if err != nil {
+		return 0, 0
+	}
+	number := bc.hc.GetBlockNumber(hashes[0])
+	if number == nil || *number >= frozen {
+		return 0, 0
+	}
+	run := 1
+	for run < len(hashes) && *number+uint64(run) < frozen {
+		if next := bc.hc.GetBlockNumber(hashes[run]); next == nil || *next != *number+uint64(run) {
+			break
+		}
+		run++
+	}
+	return *number, run
+}
+
+// GetBodiesRLP retrieves the RLP encoded bodies of the given blocks in the order
+// requested, skipping unknown ones, until their total size reaches limit. Bodies
+// of consecutive blocks in the ancient store are retrieved in batches.
+func (bc *BlockChain) GetBodiesRLP(hashes []common.Hash, limit int) []rlp.RawValue {
+	var (
+		bodies []rlp.RawValue
+		size   int
+	)
+	for len(hashes) > 0 && size < limit {
+		// Retrieve consecutive ancient bodies at once, double checking that the
+		// requested blocks are the canonical ones stored there
+		if number, run := bc.ancientRun(hashes); run > 0 {
+			canon := rawdb.ReadAncientHashes(bc.db, number, uint64(run))
+			batch := rawdb.ReadAncientBodiesRLP(bc.db, number, uint64(len(canon)), uint64(limit-size))
+			for i, body := range batch {
+				if canon[i] != hashes[i] {
+					body = bc.GetBodyRLP(hashes[i])
+				}
+				if len(body) != 0 {
+					bodies = append(bodies, body)
+					size += len(body)
+				}
+			}
+			if len(batch) > 0 {
+				hashes = hashes[len(batch):]
+				continue
+			}
+		}
+		// Not in the ancient store (or unavailable there), retrieve individually
+		if body := bc.GetBodyRLP(hashes[0]); len(body) != 0 {
+			bodies = append(bodies, body)
+			size += len(body)
+		}
+		hashes = hashes[1:]
+	}
+	return bodies
+}
+
+// GetReceiptsRLP retrieves the receipts of the given blocks in their RLP consensus
+// encoding in the order requested, skipping unknown ones, until their total size
+// reaches limit. Receipts of consecutive blocks in the ancient store are retrieved
+// in batches.
+func (bc *BlockChain) GetReceiptsRLP(hashes []common.Hash, limit int) []rlp.RawValue {
+	var (
+		receipts []rlp.RawValue
+		size     int
+	)
+	for len(hashes) > 0 && size < limit {
+		// Retrieve consecutive ancient receipts at once, double checking that the
+		// requested blocks are the canonical ones stored there
+		if number, run := bc.ancientRun(hashes); run > 0 {
+			canon := rawdb.ReadAncientHashes(bc.db, number, uint64(run))
+			batch := rawdb.ReadAncientReceiptsRLP(bc.db, number, uint64(len(canon)), uint64(limit-size))
+			for i, blob := range batch {
+				var encoded rlp.RawValue
+				if canon[i] == hashes[i] {
+					encoded = storedToConsensusReceipts(blob)
+				}
+				if encoded == nil {
+					encoded = bc.getReceiptsRLP(hashes[i])
+				}
+				if encoded != nil {
+					receipts = append(receipts, encoded)
+					size += len(encoded)
+				}
+			}
+			if len(batch) > 0 {
+				hashes = hashes[len(batch):]
+				continue
+			}
+		}
+		// Not in the ancient store (or unavailable there), retrieve individually
+		if encoded := bc.getReceiptsRLP(hashes[0]); encoded != nil {
+			receipts = append(receipts, encoded)
+			size += len(encoded)
+		}
+		hashes = hashes[1:]
+	}
+	return receipts
+}
+
+// getReceiptsRLP retrieves the consensus encoded receipts of a single block, or
+// nil if they're unknown.
+func (bc *BlockChain) getReceiptsRLP(hash common.Hash) rlp.RawValue {
+	receipts := bc.GetReceiptsByHash(hash)
+	if receipts == nil {
+		// Blocks without transactions have no receipts stored
+		if header := bc.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+			return nil
+		}
+	}
+	encoded, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		log.Error("Failed to encode receipts", "hash", hash, "err", err)
+		return nil
+	}
+	return encoded
+}
+
+// storedToConsensusReceipts converts the receipts of a block from their storage
+// encoding into their consensus one, or returns nil if they can't be decoded.
+func storedToConsensusReceipts(blob []byte) rlp.RawValue {
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(blob, &stored); err != nil {
+		log.Error("Invalid receipt array RLP", "err", err)
+		return nil
+	}
+	receipts := make([]*types.Receipt, len(stored))
+	for i, receipt := range stored {
+		receipts[i] = (*types.Receipt)(receipt)
+	}
+	encoded, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		log.Error("Failed to encode receipts", "err", err)
+		return nil
+	}
+	return encoded
+}
+
+// GetHeadersByRange retrieves up to count consecutive canonical headers starting
+// at the given number, stopping at the head of the chain. Headers in the ancient
+// store are retrieved in batches.
+func (bc *BlockChain) GetHeadersByRange(number, count uint64) []*types.Header {
+	var headers []*types.Header
+	for count > 0 {
+		if frozen, err := bc.db.Ancients(); err == nil && number < frozen {
+			batch := rawdb.ReadAncientHeadersRLP(bc.db, number, count, 0)
+			for _, blob := range batch {
+				header := new(types.Header)
+				if err := rlp.DecodeBytes(blob, header); err != nil {
+					log.Error("Invalid block header RLP", "number", number, "err", err)
+					return headers
+				}
+				headers = append(headers, header)
+			}
+			if len(batch) > 0 {
+				number, count = number+uint64(len(batch)), count-uint64(len(batch))
+				continue
+			}
+		}
+		header := bc.GetHeaderByNumber(number)
+		if header == nil {
+			break
+		}
+		headers = append(headers, header)
+		number, count = number+1, count-1
+	}
+	return headers
+}
+
+// ancientBlocks retrieves up to count consecutive canonical blocks from the
+// ancient store, starting at the given number. Nil is returned if the first one
+// isn't available there.
+func (bc *BlockChain) ancientBlocks(number, count uint64) []*types.Block {
+	frozen, err := bc.db.Ancients()
+	if err != nil || number >= frozen {
+		return nil
+	}
+	if count > frozen-number {
+		count = frozen - number
+	}
+	if count > ancientReadBatch {
+		count = ancientReadBatch
+	}
+	headers := rawdb.ReadAncientHeadersRLP(bc.db, number, count, 0)
+	bodies := rawdb.ReadAncientBodiesRLP(bc.db, number, uint64(len(headers)), 0)
+
+	blocks := make([]*types.Block, 0, len(bodies))
+	for i, blob := range bodies {
+		header, body := new(types.Header), new(types.Body)
+		if err := rlp.DecodeBytes(headers[i], header); err != nil {
+			log.Error("Invalid block header RLP", "number", number+uint64(i), "err", err)
+			break
+		}
+		if err := rlp.DecodeBytes(blob, body); err != nil {
+			log.Error("Invalid block body RLP", "number", number+uint64(i), "err", err)
+			break
+		}
+		blocks = append(blocks, types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles))
+	}
+	return blocks
+}
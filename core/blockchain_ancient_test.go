@@ -0,0 +1,151 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that batched retrievals spanning both the ancient store and the active
+// database return the same data as individual ones.
+func TestAncientBatchRetrieval(t *testing.T) {
+	var (
+		gendb   = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 64, func(i int, block *BlockGen) {
+		if i%3 != 0 {
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
+		}
+	})
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer db: %v", err)
+	}
+	gspec.MustCommit(db)
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, 40); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 41 {
+		t.Fatalf("ancient count mismatch: have %d, want %d", frozen, 41)
+	}
+	// Request bodies and receipts across the ancient boundary, interleaved with
+	// unknown and out of order blocks
+	var (
+		hashes       []common.Hash
+		wantBodies   []rlp.RawValue
+		wantReceipts []rlp.RawValue
+	)
+	request := func(i int) {
+		hashes = append(hashes, blocks[i].Hash())
+
+		body, _ := rlp.EncodeToBytes(blocks[i].Body())
+		wantBodies = append(wantBodies, body)
+		receipt, _ := rlp.EncodeToBytes(receipts[i])
+		wantReceipts = append(wantReceipts, receipt)
+	}
+	for i := 5; i < 20; i++ {
+		request(i)
+	}
+	hashes = append(hashes, common.Hash{0x01})
+	request(30)
+	request(25)
+	for i := 26; i < 60; i++ {
+		request(i)
+	}
+	bodies := chain.GetBodiesRLP(hashes, 1024*1024)
+	if len(bodies) != len(wantBodies) {
+		t.Fatalf("body count mismatch: have %d, want %d", len(bodies), len(wantBodies))
+	}
+	for i := range bodies {
+		if !bytes.Equal(bodies[i], wantBodies[i]) {
+			t.Errorf("body %d mismatch: have %x, want %x", i, bodies[i], wantBodies[i])
+		}
+	}
+	results := chain.GetReceiptsRLP(hashes, 1024*1024)
+	if len(results) != len(wantReceipts) {
+		t.Fatalf("receipts count mismatch: have %d, want %d", len(results), len(wantReceipts))
+	}
+	for i := range results {
+		if !bytes.Equal(results[i], wantReceipts[i]) {
+			t.Errorf("receipts %d mismatch: have %x, want %x", i, results[i], wantReceipts[i])
+		}
+	}
+	// Ensure the size limit is honoured
+	if limited := chain.GetBodiesRLP(hashes, 1); len(limited) != 1 {
+		t.Errorf("limited body count mismatch: have %d, want 1", len(limited))
+	}
+	// Retrieve all headers at once, going past the head of the chain
+	ranged := chain.GetHeadersByRange(1, 100)
+	if len(ranged) != len(blocks) {
+		t.Fatalf("header count mismatch: have %d, want %d", len(ranged), len(blocks))
+	}
+	for i, header := range ranged {
+		if header.Hash() != blocks[i].Hash() {
+			t.Errorf("header %d mismatch: have %x, want %x", i, header.Hash(), blocks[i].Hash())
+		}
+	}
+	// Export the entire chain and ensure it's the same as the generated one
+	var have, want bytes.Buffer
+	if err := chain.ExportN(&have, 1, uint64(len(blocks))); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	for _, block := range blocks {
+		block.EncodeRLP(&want)
+	}
+	if !bytes.Equal(have.Bytes(), want.Bytes()) {
+		t.Errorf("exported chain mismatch")
+	}
+}
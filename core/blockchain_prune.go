@@ -0,0 +1,98 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var errArchivePruning = errors.New("state pruning not supported on archive nodes")
+
+// PruneState deletes all trie nodes and contract codes from the database which
+// are not reachable from the states of the given number of most recent canonical
+// blocks or of the genesis block, returning the number of deleted entries. Blocks
+// whose state is unavailable are skipped, but the head state is always retained.
+//
+// Block processing is suspended while pruning. The states cached in memory are
+// flushed to disk beforehand, so none of them can reference pruned data when
+// they're written out later.
+func (bc *BlockChain) PruneState(p *pruner.Pruner, blocks uint64) (uint64, error) {
+	if bc.cacheConfig.TrieDirtyDisabled {
+		return 0, errArchivePruning
+	}
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	// Flush all the recent states from memory, retaining their tracking
+	triedb := bc.stateCache.TrieDB()
+
+	var (
+		cached []common.Hash
+		prios  []int64
+	)
+	for !bc.triegc.Empty() {
+		root, prio := bc.triegc.Pop()
+		cached, prios = append(cached, root.(common.Hash)), append(prios, prio)
+	}
+	for i, root := range cached {
+		bc.triegc.Push(root, prios[i])
+	}
+	for _, root := range cached {
+		if err := triedb.Commit(root, false); err != nil {
+			return 0, err
+		}
+	}
+	// Gather the states to retain, skipping duplicates and unavailable ones
+	head := bc.CurrentBlock()
+	if !bc.HasState(head.Root()) {
+		return 0, fmt.Errorf("missing head state %x", head.Root())
+	}
+	if blocks == 0 {
+		blocks = 1
+	}
+	var (
+		roots  []common.Hash
+		seen   = make(map[common.Hash]bool)
+		number = head.NumberU64()
+	)
+	for i := uint64(0); i < blocks && i <= number; i++ {
+		header := bc.GetHeaderByNumber(number - i)
+		if header == nil || seen[header.Root] || !bc.HasState(header.Root) {
+			continue
+		}
+		roots = append(roots, header.Root)
+		seen[header.Root] = true
+	}
+	if genesis := bc.genesisBlock.Root(); !seen[genesis] && bc.HasState(genesis) {
+		roots = append(roots, genesis)
+	}
+	log.Info("Pruning historical state", "number", number, "blocks", blocks, "retained", len(roots))
+
+	deleted, err := p.Prune(bc.stateCache, roots, bc.quit)
+
+	// Drop the deleted trie nodes cached in memory, even if the sweep failed midway
+	triedb.ResetCleanCache()
+	return deleted, err
+}
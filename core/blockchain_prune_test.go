@@ -0,0 +1,138 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// checkStateOnDisk iterates over the entire state with the given root, bypassing
+// any caches, returning an error if any of its entries is missing.
+func checkStateOnDisk(db ethdb.Database, root common.Hash) error {
+	st, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(st)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+// Tests that pruning the state of a live chain retains the states of the recent
+// blocks and of the genesis, and that the chain keeps working afterwards.
+func TestPruneState(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	gendb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(gendb)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 40, func(i int, block *BlockGen) {
+		// Create a contract and send funds to a new account in every block
+		tx, _ := types.SignTx(types.NewContractCreation(block.TxNonce(address), big.NewInt(0), 100000, big.NewInt(1), []byte{0x60, byte(i), 0x60, 0x00, 0x55}), signer, key)
+		block.AddTx(tx)
+		tx, _ = types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i)}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+		block.AddTx(tx)
+	})
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:30]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	deleted, err := chain.PruneState(pruner.NewPruner(db, "", 1), 5)
+	if err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if deleted == 0 {
+		t.Fatalf("no state pruned")
+	}
+	// The recent states and the genesis must be fully available from disk
+	for i, block := range blocks[:30] {
+		err := checkStateOnDisk(db, block.Root())
+		if i >= 25 && err != nil {
+			t.Errorf("retained state #%d inaccessible: %v", block.NumberU64(), err)
+		}
+		if i < 25 && err == nil {
+			t.Errorf("pruned state #%d still accessible", block.NumberU64())
+		}
+		if i < 25 && chain.HasState(block.Root()) {
+			t.Errorf("pruned state #%d reported available", block.NumberU64())
+		}
+	}
+	if err := checkStateOnDisk(db, genesis.Root()); err != nil {
+		t.Errorf("genesis state inaccessible: %v", err)
+	}
+	// The chain must keep processing blocks on top of the retained state
+	if _, err := chain.InsertChain(blocks[30:]); err != nil {
+		t.Fatalf("failed to insert chain after pruning: %v", err)
+	}
+	chain.Stop()
+
+	chain, err = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen blockchain: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[39].Hash() {
+		t.Fatalf("head mismatch after reopen: have #%d, want #%d", head.NumberU64(), blocks[39].NumberU64())
+	}
+	if err := checkStateOnDisk(db, blocks[39].Root()); err != nil {
+		t.Errorf("head state inaccessible after reopen: %v", err)
+	}
+}
+
+// Tests that archive nodes refuse to prune their state.
+func TestPruneStateArchive(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, err := NewBlockChain(db, &CacheConfig{TrieDirtyDisabled: true}, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.PruneState(pruner.NewPruner(db, "", 1), 1); err != errArchivePruning {
+		t.Errorf("error mismatch: have %v, want %v", err, errArchivePruning)
+	}
+	if err := checkStateOnDisk(db, genesis.Root()); err != nil {
+		t.Errorf("genesis state damaged: %v", err)
+	}
+}
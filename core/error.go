@@ -35,4 +35,18 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrTooManyUncles is returned if a block includes more uncles than allowed.
+	ErrTooManyUncles = errors.New("too many uncles")
+
+	// ErrUncleOutOfRange is returned if an included uncle is not within the allowed
+	// number of generations below the including block.
+	ErrUncleOutOfRange = errors.New("uncle number out of range")
+
+	// ErrDuplicateUncle is returned if a block includes the same uncle twice.
+	ErrDuplicateUncle = errors.New("duplicate uncle")
+
+	// ErrUncleIsAncestor is returned if an included uncle is a canonical ancestor
+	// of the including block.
+	ErrUncleIsAncestor = errors.New("uncle is ancestor")
 )
@@ -0,0 +1,50 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Tests that events carrying blocks can't be delivered to copying subscribers,
+// as the blocks would lose their unexported header and body.
+func TestCopyingFeedChainEvent(t *testing.T) {
+	var feed event.CopyingFeed[ChainEvent]
+
+	sub := feed.Subscribe(make(chan ChainEvent, 1))
+	if err := <-sub.Err(); err == nil {
+		t.Fatal("subscription accepted for chain events")
+	}
+	// Headers carry their own JSON encoding, so events made of them are copyable
+	var headers event.CopyingFeed[*types.Header]
+
+	ch := make(chan *types.Header, 1)
+	sub = headers.Subscribe(ch)
+	defer sub.Unsubscribe()
+
+	header := &types.Header{Number: common.Big1, Difficulty: common.Big2, Extra: []byte{0x01}}
+	if nsent := headers.Send(header); nsent != 1 {
+		t.Fatalf("send delivered %d times, want 1", nsent)
+	}
+	if have := <-ch; have == header || have.Hash() != header.Hash() {
+		t.Errorf("header copy mismatch: have %v, want %v", have.Hash(), header.Hash())
+	}
+}
@@ -172,6 +172,24 @@ func WriteFastTrieProgress(db ethdb.KeyValueWriter, count uint64) {
 	}
 }
 
+// ReadBodyPruneTail retrieves the number of the first frozen block whose body
+// was not yet discarded by body pruning.
+func ReadBodyPruneTail(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(bodyPruneTailKey)
+	if len(data) == 0 {
+		return 0
+	}
+	return new(big.Int).SetBytes(data).Uint64()
+}
+
+// WriteBodyPruneTail stores the number of the first frozen block whose body is
+// retained after body pruning.
+func WriteBodyPruneTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(bodyPruneTailKey, new(big.Int).SetUint64(number).Bytes()); err != nil {
+		log.Crit("Failed to store body prune tail", "err", err)
+	}
+}
+
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawValue {
 	// First try to look up the data in ancient database. Extra hash
@@ -471,8 +489,8 @@ func ReadRawReceipts(db ethdb.Reader, hash common.Hash, number uint64) types.Rec
 // fields then nil is returned.
 //
 // The current implementation populates these metadata fields by reading the receipts'
-// corresponding block body, so if the block body is not found it will return nil even
-// if the receipt itself is stored.
+// corresponding block body, or the fields retained from it if the body was pruned.
+// If neither is found it will return nil even if the receipt itself is stored.
 func ReadReceipts(db ethdb.Reader, hash common.Hash, number uint64, config *params.ChainConfig) types.Receipts {
 	// We're deriving many fields from the block body, retrieve beside the receipt
 	receipts := ReadRawReceipts(db, hash, number)
@@ -481,8 +499,20 @@ func ReadReceipts(db ethdb.Reader, hash common.Hash, number uint64, config *para
 	}
 	body := ReadBody(db, hash, number)
 	if body == nil {
-		log.Error("Missing body but have receipt", "hash", hash, "number", number)
-		return nil
+		// Nothing is retained for pruned blocks without transactions
+		if len(receipts) == 0 && number < ReadBodyPruneTail(db) {
+			return receipts
+		}
+		txs := ReadPrunedTxs(db, hash, number)
+		if txs == nil {
+			log.Error("Missing body but have receipt", "hash", hash, "number", number)
+			return nil
+		}
+		if err := receipts.DeriveFieldsFromTxs(hash, number, txs); err != nil {
+			log.Error("Failed to derive pruned block receipts fields", "hash", hash, "number", number, "err", err)
+			return nil
+		}
+		return receipts
 	}
 	if err := receipts.DeriveFields(config, hash, number, body.Transactions); err != nil {
 		log.Error("Failed to derive block receipts fields", "hash", hash, "number", number, "err", err)
@@ -491,6 +521,33 @@ func ReadReceipts(db ethdb.Reader, hash common.Hash, number uint64, config *para
 	return receipts
 }
 
+// ReadPrunedTxs retrieves the receipt fields of the transactions of a block whose
+// body was pruned.
+func ReadPrunedTxs(db ethdb.KeyValueReader, hash common.Hash, number uint64) []types.ReceiptTxFields {
+	data, _ := db.Get(prunedTxsKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	txs := []types.ReceiptTxFields{}
+	if err := rlp.DecodeBytes(data, &txs); err != nil {
+		log.Error("Invalid pruned transactions RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return txs
+}
+
+// WritePrunedTxs stores the receipt fields of the transactions of a block, which
+// are needed to derive its receipts after its body is pruned.
+func WritePrunedTxs(db ethdb.KeyValueWriter, hash common.Hash, number uint64, txs []types.ReceiptTxFields) {
+	data, err := rlp.EncodeToBytes(txs)
+	if err != nil {
+		log.Crit("Failed to encode pruned transactions", "err", err)
+	}
+	if err := db.Put(prunedTxsKey(number, hash), data); err != nil {
+		log.Crit("Failed to store pruned transactions", "err", err)
+	}
+}
+
 // WriteReceipts stores all the transaction receipts belonging to a block.
 func WriteReceipts(db ethdb.KeyValueWriter, hash common.Hash, number uint64, receipts types.Receipts) {
 	// Convert the receipts into their storage form and serialize them
@@ -521,6 +578,9 @@ func DeleteReceipts(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 //
 // Note, due to concurrent download of header and block body the header and thus
 // canonical hash can be stored in the database but the body data not (yet).
+//
+// If the body of the block was discarded by body pruning, the block is returned
+// without transactions and uncles.
 func ReadBlock(db ethdb.Reader, hash common.Hash, number uint64) *types.Block {
 	header := ReadHeader(db, hash, number)
 	if header == nil {
@@ -528,7 +588,10 @@ func ReadBlock(db ethdb.Reader, hash common.Hash, number uint64) *types.Block {
 	}
 	body := ReadBody(db, hash, number)
 	if body == nil {
-		return nil
+		if number >= ReadBodyPruneTail(db) {
+			return nil
+		}
+		body = new(types.Body)
 	}
 	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
 }
@@ -570,6 +633,54 @@ func WriteAncientBlock(db ethdb.AncientWriter, block *types.Block, receipts type
 	return len(headerBlob) + len(bodyBlob) + len(receiptBlob) + len(tdBlob) + common.HashLength
 }
 
+// readAncientRange retrieves a batch of consecutive items of the given kind from
+// the ancient store, or nil if they can't be retrieved.
+func readAncientRange(db ethdb.AncientReader, kind string, number, count, maxBytes uint64) []rlp.RawValue {
+	blobs, err := db.AncientRange(kind, number, count, maxBytes)
+	if err != nil {
+		return nil
+	}
+	values := make([]rlp.RawValue, len(blobs))
+	for i, blob := range blobs {
+		values[i] = blob
+	}
+	return values
+}
+
+// ReadAncientHashes retrieves the hashes of up to count consecutive canonical
+// blocks from the ancient store, starting at the given number. Fewer hashes are
+// returned if the ancient store ends earlier.
+func ReadAncientHashes(db ethdb.AncientReader, number, count uint64) []common.Hash {
+	blobs := readAncientRange(db, freezerHashTable, number, count, 0)
+	hashes := make([]common.Hash, len(blobs))
+	for i, blob := range blobs {
+		hashes[i] = common.BytesToHash(blob)
+	}
+	return hashes
+}
+
+// ReadAncientHeadersRLP retrieves the RLP encoded headers of up to count
+// consecutive canonical blocks from the ancient store, starting at the given
+// number. Fewer headers are returned if the ancient store ends earlier or if
+// their stored size exceeds maxBytes (0 = unlimited), but at least one if any.
+func ReadAncientHeadersRLP(db ethdb.AncientReader, number, count, maxBytes uint64) []rlp.RawValue {
+	return readAncientRange(db, freezerHeaderTable, number, count, maxBytes)
+}
+
+// ReadAncientBodiesRLP retrieves the RLP encoded bodies of up to count consecutive
+// canonical blocks from the ancient store, with the same semantics as
+// ReadAncientHeadersRLP.
+func ReadAncientBodiesRLP(db ethdb.AncientReader, number, count, maxBytes uint64) []rlp.RawValue {
+	return readAncientRange(db, freezerBodiesTable, number, count, maxBytes)
+}
+
+// ReadAncientReceiptsRLP retrieves the receipts of up to count consecutive
+// canonical blocks from the ancient store in their RLP storage encoding, with the
+// same semantics as ReadAncientHeadersRLP.
+func ReadAncientReceiptsRLP(db ethdb.AncientReader, number, count, maxBytes uint64) []rlp.RawValue {
+	return readAncientRange(db, freezerReceiptTable, number, count, maxBytes)
+}
+
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -96,3 +97,85 @@ func WritePreimages(db ethdb.KeyValueWriter, preimages map[common.Hash][]byte) {
 	preimageCounter.Inc(int64(len(preimages)))
 	preimageHitCounter.Inc(int64(len(preimages)))
 }
+
+// ReadStatePruneJournal retrieves the serialized progress of an interrupted
+// state pruning, if any.
+func ReadStatePruneJournal(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(statePruneJournalKey)
+	return data
+}
+
+// WriteStatePruneJournal stores the serialized progress of a state pruning, so
+// that it can be resumed after an interruption.
+func WriteStatePruneJournal(db ethdb.KeyValueWriter, journal []byte) {
+	if err := db.Put(statePruneJournalKey, journal); err != nil {
+		log.Crit("Failed to store state prune journal", "err", err)
+	}
+}
+
+// DeleteStatePruneJournal deletes the progress of a state pruning.
+func DeleteStatePruneJournal(db ethdb.KeyValueWriter) {
+	if err := db.Delete(statePruneJournalKey); err != nil {
+		log.Crit("Failed to remove state prune journal", "err", err)
+	}
+}
+
+// crashesToKeep is the number of recent unclean shutdowns retained in the
+// database.
+const crashesToKeep = 10
+
+// crashList is the list of boot times of sessions that didn't shut down
+// cleanly, the last one being the running session.
+type crashList struct {
+	Discarded uint64   // How many crashes have been dropped from the list
+	Recent    []uint64 // Unix timestamps of the recent unclean shutdowns
+}
+
+// PushUncleanShutdownMarker appends a new unclean shutdown marker and returns
+// the previous markers along with the number of older ones already discarded.
+func PushUncleanShutdownMarker(db ethdb.KeyValueStore) ([]uint64, uint64, error) {
+	var uncleanShutdowns crashList
+	// Read the old list, if any
+	if data, err := db.Get(uncleanShutdownKey); err != nil {
+		log.Warn("Error reading unclean shutdown markers", "error", err)
+	} else if err := rlp.DecodeBytes(data, &uncleanShutdowns); err != nil {
+		return nil, 0, err
+	}
+	var discarded = uncleanShutdowns.Discarded
+	var previous = make([]uint64, len(uncleanShutdowns.Recent))
+	copy(previous, uncleanShutdowns.Recent)
+
+	// Add a new (but cap it)
+	uncleanShutdowns.Recent = append(uncleanShutdowns.Recent, uint64(time.Now().Unix()))
+	if count := len(uncleanShutdowns.Recent); count > crashesToKeep+1 {
+		numDel := count - (crashesToKeep + 1)
+		uncleanShutdowns.Recent = uncleanShutdowns.Recent[numDel:]
+		uncleanShutdowns.Discarded += uint64(numDel)
+	}
+	// And save it again
+	data, _ := rlp.EncodeToBytes(uncleanShutdowns)
+	if err := db.Put(uncleanShutdownKey, data); err != nil {
+		log.Warn("Failed to write unclean-shutdown marker", "err", err)
+		return nil, 0, err
+	}
+	return previous, discarded, nil
+}
+
+// PopUncleanShutdownMarker removes the last unclean shutdown marker, flagging
+// the running session as cleanly shut down.
+func PopUncleanShutdownMarker(db ethdb.KeyValueStore) {
+	var uncleanShutdowns crashList
+	// Read the old list, if any
+	if data, err := db.Get(uncleanShutdownKey); err != nil {
+		log.Warn("Error reading unclean shutdown markers", "error", err)
+	} else if err := rlp.DecodeBytes(data, &uncleanShutdowns); err != nil {
+		log.Error("Error decoding unclean shutdown markers", "error", err)
+	}
+	if l := len(uncleanShutdowns.Recent); l > 0 {
+		uncleanShutdowns.Recent = uncleanShutdowns.Recent[:l-1]
+	}
+	data, _ := rlp.EncodeToBytes(uncleanShutdowns)
+	if err := db.Put(uncleanShutdownKey, data); err != nil {
+		log.Warn("Failed to clear unclean-shutdown marker", "err", err)
+	}
+}
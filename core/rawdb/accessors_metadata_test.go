@@ -0,0 +1,52 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import "testing"
+
+// Tests that unclean shutdown markers are pushed, popped and capped correctly.
+func TestUncleanShutdownMarkers(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// A fresh database has no previous unclean shutdowns
+	previous, discarded, err := PushUncleanShutdownMarker(db)
+	if err != nil {
+		t.Fatalf("failed to push marker: %v", err)
+	}
+	if len(previous) != 0 || discarded != 0 {
+		t.Fatalf("fresh database markers mismatch: have %d/%d, want 0/0", len(previous), discarded)
+	}
+	// A clean shutdown removes the marker of the session
+	PopUncleanShutdownMarker(db)
+	if previous, _, _ = PushUncleanShutdownMarker(db); len(previous) != 0 {
+		t.Fatalf("markers after clean shutdown mismatch: have %d, want 0", len(previous))
+	}
+	// Unclean shutdowns leave their markers in place, capped to the recent ones
+	for i := 1; i <= crashesToKeep+5; i++ {
+		previous, discarded, err = PushUncleanShutdownMarker(db)
+		if err != nil {
+			t.Fatalf("failed to push marker %d: %v", i, err)
+		}
+		wantPrevious, wantDiscarded := i, 0
+		if i > crashesToKeep+1 {
+			wantPrevious, wantDiscarded = crashesToKeep+1, i-crashesToKeep-1
+		}
+		if len(previous) != wantPrevious || discarded != uint64(wantDiscarded) {
+			t.Fatalf("session %d: markers mismatch: have %d/%d, want %d/%d", i, len(previous), discarded, wantPrevious, wantDiscarded)
+		}
+	}
+}
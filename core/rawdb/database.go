@@ -68,6 +68,11 @@ func (db *nofreezedb) Ancient(kind string, number uint64) ([]byte, error) {
 	return nil, errNotSupported
 }
 
+// AncientRange returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return nil, errNotSupported
+}
+
 // Ancients returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Ancients() (uint64, error) {
 	return 0, errNotSupported
@@ -171,6 +176,12 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 			// feezer.
 		}
 	}
+	// Hide the pruned block bodies again, finishing any interrupted pruning
+	if tail := ReadBodyPruneTail(db); tail > 0 {
+		if err := frdb.truncateTail(freezerBodiesTable, tail); err != nil {
+			return nil, err
+		}
+	}
 	// Freezer is consistent with the key-value database, permit combining the two
 	go frdb.freeze(db)
 
@@ -218,137 +229,242 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer
 	return frdb, nil
 }
 
-// InspectDatabase traverses the entire database and checks the size
-// of all different categories of data.
-func InspectDatabase(db ethdb.Database) error {
-	it := db.NewIterator()
+// NewBackendDatabase creates a persistent key-value database using the named
+// key-value store backend, or LevelDB if none is specified.
+func NewBackendDatabase(backend string, file string, cache int, handles int, namespace string) (ethdb.Database, error) {
+	db, err := ethdb.OpenBackend(backend, file, cache, handles, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabase(db), nil
+}
+
+// NewBackendDatabaseWithFreezer creates a persistent key-value database using
+// the named key-value store backend, or LevelDB if none is specified, with a
+// freezer moving immutable chain segments into cold storage.
+func NewBackendDatabaseWithFreezer(backend string, file string, cache int, handles int, freezer string, namespace string) (ethdb.Database, error) {
+	kvdb, err := ethdb.OpenBackend(backend, file, cache, handles, namespace)
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, namespace)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
+// DatabaseCategory is the number of entries and their total size of a single
+// category of data in the database.
+type DatabaseCategory struct {
+	Database string             `json:"database"`
+	Category string             `json:"category"`
+	Count    uint64             `json:"count"`
+	Size     common.StorageSize `json:"size"`
+}
+
+// DatabaseStats is the breakdown of the database size by category of data.
+type DatabaseStats struct {
+	Categories  []*DatabaseCategory `json:"categories"`  // Known categories of data, in display order
+	Unaccounted []*DatabaseCategory `json:"unaccounted"` // Entries of unknown categories, grouped by their first key byte
+	Count       uint64              `json:"count"`       // Total number of key-value entries
+	Total       common.StorageSize  `json:"total"`       // Total size of the key-value and ancient stores
+}
+
+// errInspectionAborted is returned if a database inspection was aborted by its
+// progress callback.
+var errInspectionAborted = errors.New("database inspection aborted")
+
+// keyCategory is a category of data in the key-value store, identified by the
+// shape of its keys.
+type keyCategory struct {
+	database string
+	name     string
+	match    func(key []byte) bool
+}
+
+// hasPrefixLen returns a matcher for keys with the given prefix and total length.
+func hasPrefixLen(prefix []byte, length int) func([]byte) bool {
+	return func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix) && len(key) == length
+	}
+}
+
+// hasPrefix returns a matcher for keys with the given prefix.
+func hasPrefix(prefix []byte) func([]byte) bool {
+	return func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	}
+}
+
+// keyCategories are the known categories of the key-value store. The first
+// matching category is attributed an entry, so the order matters.
+var keyCategories = []keyCategory{
+	{"Key-Value store", "Headers", hasPrefixLen(headerPrefix, len(headerPrefix)+8+common.HashLength)},
+	{"Key-Value store", "Bodies", hasPrefixLen(blockBodyPrefix, len(blockBodyPrefix)+8+common.HashLength)},
+	{"Key-Value store", "Receipts", hasPrefixLen(blockReceiptsPrefix, len(blockReceiptsPrefix)+8+common.HashLength)},
+	{"Key-Value store", "Pruned transactions", hasPrefixLen(prunedTxsPrefix, len(prunedTxsPrefix)+8+common.HashLength)},
+	{"Key-Value store", "Difficulties", func(key []byte) bool {
+		return bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix) && len(key) == len(headerPrefix)+8+common.HashLength+len(headerTDSuffix)
+	}},
+	{"Key-Value store", "Block number->hash", func(key []byte) bool {
+		return bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix) && len(key) == len(headerPrefix)+8+len(headerHashSuffix)
+	}},
+	{"Key-Value store", "Block hash->number", hasPrefixLen(headerNumberPrefix, len(headerNumberPrefix)+common.HashLength)},
+	{"Key-Value store", "Transaction index", hasPrefixLen(txLookupPrefix, len(txLookupPrefix)+common.HashLength)},
+	{"Key-Value store", "Bloombit index", hasPrefixLen(bloomBitsPrefix, len(bloomBitsPrefix)+10+common.HashLength)},
+	{"Key-Value store", "Trie nodes", func(key []byte) bool { return len(key) == common.HashLength }},
+	{"Key-Value store", "Trie preimages", hasPrefixLen(preimagePrefix, len(preimagePrefix)+common.HashLength)},
+	{"Key-Value store", "Clique snapshots", hasPrefixLen([]byte("clique-"), 7+common.HashLength)},
+	{"Key-Value store", "Chain configs", hasPrefixLen(configPrefix, len(configPrefix)+common.HashLength)},
+	{"Key-Value store", "Chain indexers", func(key []byte) bool {
+		return bytes.HasPrefix(key, BloomBitsIndexPrefix) || bytes.HasPrefix(key, []byte("chtIndexV2-")) || bytes.HasPrefix(key, []byte("bltIndex-"))
+	}},
+	{"Key-Value store", "Singleton metadata", func(key []byte) bool {
+		for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey, bodyPruneTailKey, statePruneJournalKey} {
+			if bytes.Equal(key, meta) {
+				return true
+			}
+		}
+		return false
+	}},
+	{"Light client", "CHT trie nodes", hasPrefixLen([]byte("cht-"), 4+common.HashLength)},
+	{"Light client", "Bloom trie nodes", hasPrefixLen([]byte("blt-"), 4+common.HashLength)},
+	{"Light client", "Trie roots", func(key []byte) bool {
+		return bytes.HasPrefix(key, []byte("chtRootV2-")) || bytes.HasPrefix(key, []byte("bltRoot-"))
+	}},
+	{"Light client", "ODR cache", hasPrefix([]byte("odr-cache-"))},
+}
+
+// ancientCategories are the tables of the ancient store, with their display names.
+var ancientCategories = []struct {
+	table string
+	name  string
+}{
+	{freezerHeaderTable, "Headers"},
+	{freezerBodiesTable, "Bodies"},
+	{freezerReceiptTable, "Receipts"},
+	{freezerDifficultyTable, "Difficulties"},
+	{freezerHashTable, "Block number->hash"},
+}
+
+// CollectDatabaseStats traverses the entire database and attributes the size of
+// every entry to its category of data. The optional progress callback is called
+// periodically with the number of entries inspected and the current key, the
+// inspection is aborted if it returns false.
+//
+// The key-value store is traversed through a snapshot, so the statistics are
+// consistent even if the database is written concurrently.
+func CollectDatabaseStats(db ethdb.Database, progress func(count uint64, key []byte) bool) (*DatabaseStats, error) {
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Release()
+
+	it := snap.NewIterator()
 	defer it.Release()
 
 	var (
-		count  int64
-		start  = time.Now()
-		logged = time.Now()
-
-		// Key-value store statistics
-		total           common.StorageSize
-		headerSize      common.StorageSize
-		bodySize        common.StorageSize
-		receiptSize     common.StorageSize
-		tdSize          common.StorageSize
-		numHashPairing  common.StorageSize
-		hashNumPairing  common.StorageSize
-		trieSize        common.StorageSize
-		txlookupSize    common.StorageSize
-		preimageSize    common.StorageSize
-		bloomBitsSize   common.StorageSize
-		cliqueSnapsSize common.StorageSize
-
-		// Ancient store statistics
-		ancientHeaders  common.StorageSize
-		ancientBodies   common.StorageSize
-		ancientReceipts common.StorageSize
-		ancientHashes   common.StorageSize
-		ancientTds      common.StorageSize
-
-		// Les statistic
-		chtTrieNodes   common.StorageSize
-		bloomTrieNodes common.StorageSize
-
-		// Meta- and unaccounted data
-		metadata    common.StorageSize
-		unaccounted common.StorageSize
+		stats   = new(DatabaseStats)
+		known   = make([]*DatabaseCategory, len(keyCategories))
+		unknown = make(map[byte]*DatabaseCategory)
 	)
+	for i, category := range keyCategories {
+		known[i] = &DatabaseCategory{Database: category.database, Category: category.name}
+	}
 	// Inspect key-value database first.
 	for it.Next() {
 		var (
 			key  = it.Key()
 			size = common.StorageSize(len(key) + len(it.Value()))
 		)
-		total += size
-		switch {
-		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix):
-			tdSize += size
-		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix):
-			numHashPairing += size
-		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+common.HashLength):
-			headerSize += size
-		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == (len(headerNumberPrefix)+common.HashLength):
-			hashNumPairing += size
-		case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == (len(blockBodyPrefix)+8+common.HashLength):
-			bodySize += size
-		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == (len(blockReceiptsPrefix)+8+common.HashLength):
-			receiptSize += size
-		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
-			txlookupSize += size
-		case bytes.HasPrefix(key, preimagePrefix) && len(key) == (len(preimagePrefix)+common.HashLength):
-			preimageSize += size
-		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
-			bloomBitsSize += size
-		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
-			cliqueSnapsSize += size
-		case bytes.HasPrefix(key, []byte("cht-")) && len(key) == 4+common.HashLength:
-			chtTrieNodes += size
-		case bytes.HasPrefix(key, []byte("blt-")) && len(key) == 4+common.HashLength:
-			bloomTrieNodes += size
-		case len(key) == common.HashLength:
-			trieSize += size
-		default:
-			var accounted bool
-			for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey} {
-				if bytes.Equal(key, meta) {
-					metadata += size
-					accounted = true
-					break
-				}
+		stats.Count++
+		stats.Total += size
+
+		var accounted bool
+		for i, category := range keyCategories {
+			if category.match(key) {
+				known[i].Count++
+				known[i].Size += size
+				accounted = true
+				break
+			}
+		}
+		if !accounted {
+			var first byte
+			if len(key) > 0 {
+				first = key[0]
 			}
-			if !accounted {
-				unaccounted += size
+			if unknown[first] == nil {
+				unknown[first] = &DatabaseCategory{Database: "Unaccounted", Category: fmt.Sprintf("Prefix %#02x", first)}
 			}
+			unknown[first].Count++
+			unknown[first].Size += size
 		}
-		count += 1
-		if count%1000 == 0 && time.Since(logged) > 8*time.Second {
-			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
-			logged = time.Now()
+		if progress != nil && stats.Count%1000 == 0 && !progress(stats.Count, key) {
+			return nil, errInspectionAborted
 		}
 	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
 	// Inspect append-only file store then.
-	ancients := []*common.StorageSize{&ancientHeaders, &ancientBodies, &ancientReceipts, &ancientHashes, &ancientTds}
-	for i, category := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable, freezerDifficultyTable} {
-		if size, err := db.AncientSize(category); err == nil {
-			*ancients[i] += common.StorageSize(size)
-			total += common.StorageSize(size)
+	ancients, _ := db.Ancients()
+	for _, category := range ancientCategories {
+		entry := &DatabaseCategory{Database: "Ancient store", Category: category.name, Count: ancients}
+		if size, err := db.AncientSize(category.table); err == nil {
+			entry.Size = common.StorageSize(size)
+			stats.Total += entry.Size
 		}
+		known = append(known, entry)
 	}
-	// Display the database statistic.
-	stats := [][]string{
-		{"Key-Value store", "Headers", headerSize.String()},
-		{"Key-Value store", "Bodies", bodySize.String()},
-		{"Key-Value store", "Receipts", receiptSize.String()},
-		{"Key-Value store", "Difficulties", tdSize.String()},
-		{"Key-Value store", "Block number->hash", numHashPairing.String()},
-		{"Key-Value store", "Block hash->number", hashNumPairing.String()},
-		{"Key-Value store", "Transaction index", txlookupSize.String()},
-		{"Key-Value store", "Bloombit index", bloomBitsSize.String()},
-		{"Key-Value store", "Trie nodes", trieSize.String()},
-		{"Key-Value store", "Trie preimages", preimageSize.String()},
-		{"Key-Value store", "Clique snapshots", cliqueSnapsSize.String()},
-		{"Key-Value store", "Singleton metadata", metadata.String()},
-		{"Ancient store", "Headers", ancientHeaders.String()},
-		{"Ancient store", "Bodies", ancientBodies.String()},
-		{"Ancient store", "Receipts", ancientReceipts.String()},
-		{"Ancient store", "Difficulties", ancientTds.String()},
-		{"Ancient store", "Block number->hash", ancientHashes.String()},
-		{"Light client", "CHT trie nodes", chtTrieNodes.String()},
-		{"Light client", "Bloom trie nodes", bloomTrieNodes.String()},
+	// Order the categories for display, ancients after the key-value store
+	for _, database := range []string{"Key-Value store", "Ancient store", "Light client"} {
+		for _, category := range known {
+			if category.Database == database {
+				stats.Categories = append(stats.Categories, category)
+			}
+		}
+	}
+	stats.Unaccounted = make([]*DatabaseCategory, 0, len(unknown))
+	for first := 0; first < 256; first++ {
+		if category, ok := unknown[byte(first)]; ok {
+			stats.Unaccounted = append(stats.Unaccounted, category)
+		}
+	}
+	return stats, nil
+}
+
+// InspectDatabase traverses the entire database and checks the size
+// of all different categories of data.
+func InspectDatabase(db ethdb.Database) error {
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	stats, err := CollectDatabaseStats(db, func(count uint64, key []byte) bool {
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return true
+	})
+	if err != nil {
+		return err
 	}
+	// Display the database statistic.
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Database", "Category", "Size"})
-	table.SetFooter([]string{"", "Total", total.String()})
-	table.AppendBulk(stats)
+	table.SetFooter([]string{"", "Total", stats.Total.String()})
+	for _, category := range stats.Categories {
+		table.Append([]string{category.Database, category.Category, category.Size.String()})
+	}
 	table.Render()
 
-	if unaccounted > 0 {
-		log.Error("Database contains unaccounted data", "size", unaccounted)
+	for _, category := range stats.Unaccounted {
+		log.Error("Database contains unaccounted data", "category", category.Category, "entries", category.Count, "size", category.Size)
 	}
 	return nil
 }
@@ -0,0 +1,72 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that ancient items are counted per table and that the inspection can be
+// aborted.
+func TestCollectDatabaseStats(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), frdir, "")
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend")
+	}
+	defer db.Close()
+
+	for i := uint64(0); i < 3; i++ {
+		if err := db.AppendAncient(i, common.Hash{byte(i)}.Bytes(), []byte{0xc0}, []byte{0xc0}, []byte{0xc0}, []byte{0x80}); err != nil {
+			t.Fatalf("failed to append ancient %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 2500; i++ {
+		db.Put([]byte(fmt.Sprintf("junk-%d", i)), nil)
+	}
+	stats, err := CollectDatabaseStats(db, nil)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	for _, category := range stats.Categories {
+		if category.Database == "Ancient store" && (category.Count != 3 || category.Size == 0) {
+			t.Errorf("ancient %s mismatch: have %d items of %v, want 3", category.Category, category.Count, category.Size)
+		}
+	}
+	if len(stats.Unaccounted) != 1 || stats.Unaccounted[0].Count != 2500 {
+		t.Errorf("unaccounted data mismatch: have %+v, want 2500 entries", stats.Unaccounted)
+	}
+	// Abort after the first progress report
+	var reports int
+	_, err = CollectDatabaseStats(db, func(count uint64, key []byte) bool {
+		reports++
+		return false
+	})
+	if err != errInspectionAborted || reports != 1 {
+		t.Errorf("abort mismatch: have %v after %d reports, want %v after 1", err, reports, errInspectionAborted)
+	}
+}
@@ -158,6 +158,15 @@ func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
 	return nil, errUnknownTable
 }
 
+// AncientRange retrieves multiple consecutive ancient binary blobs from the
+// append-only immutable files in one go.
+func (f *freezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.RetrieveItems(start, count, maxBytes)
+	}
+	return nil, errUnknownTable
+}
+
 // Ancients returns the length of the frozen items.
 func (f *freezer) Ancients() (uint64, error) {
 	return atomic.LoadUint64(&f.frozen), nil
@@ -232,6 +241,15 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	return nil
 }
 
+// truncateTail discards the data files of the specified category which only
+// contain items beneath the given threshold.
+func (f *freezer) truncateTail(kind string, items uint64) error {
+	if table := f.tables[kind]; table != nil {
+		return table.truncateTail(items)
+	}
+	return errUnknownTable
+}
+
 // sync flushes all data tables to disk.
 func (f *freezer) Sync() error {
 	var errs []error
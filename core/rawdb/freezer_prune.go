@@ -0,0 +1,102 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// PruneBlockBodies discards the transactions and uncles of all frozen blocks
+// below upToBlock (exclusive), retaining only their headers and receipts. Blocks
+// that are not yet moved into the freezer are never pruned.
+//
+// The transaction lookup entries of the pruned blocks are deleted immediately,
+// and the bodies are hidden from all readers. Since the freezer is append-only,
+// disk space is reclaimed at the granularity of whole data files, so some of the
+// pruned bodies may linger on disk until the next data file is fully pruned.
+func PruneBlockBodies(db ethdb.Database, upToBlock uint64) error {
+	// If we can't access the freezer, there's nothing to prune
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if upToBlock > frozen {
+		upToBlock = frozen
+	}
+	tail := ReadBodyPruneTail(db)
+	if upToBlock <= tail {
+		return nil
+	}
+	// Iterate over all the soon-to-be pruned bodies and drop their tx indices
+	var (
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged time.Time
+	)
+	for n := tail; n < upToBlock; n++ {
+		hash := ReadCanonicalHash(db, n)
+		body := ReadBody(db, hash, n)
+		if body != nil && len(body.Transactions) > 0 {
+			// Retain what the receipts are derived from, so they remain readable
+			txs := make([]types.ReceiptTxFields, len(body.Transactions))
+			for i, tx := range body.Transactions {
+				DeleteTxLookupEntry(batch, tx.Hash())
+				txs[i] = types.NewReceiptTxFields(txSigner(tx), tx)
+			}
+			WritePrunedTxs(batch, hash, n, txs)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		// If we've spent too much time already, notify the user of what we're doing
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning ancient block bodies", "number", n, "target", upToBlock, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	WriteBodyPruneTail(batch, upToBlock)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// Bodies are now inaccessible, reclaim the disk space of the ancient store
+	if frdb, ok := db.(*freezerdb); ok {
+		if f, ok := frdb.AncientStore.(*freezer); ok {
+			if err := f.truncateTail(freezerBodiesTable, upToBlock); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("Pruned ancient block bodies", "from", tail, "to", upToBlock, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// txSigner returns a signer recovering the sender of tx without knowing the chain
+// configuration, which is enough for the transactions already in the chain.
+func txSigner(tx *types.Transaction) types.Signer {
+	if tx.Protected() {
+		return types.NewEIP155Signer(tx.ChainId())
+	}
+	return types.FrontierSigner{}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that pruning ancient block bodies drops the transactions and uncles, but
+// retains the headers and receipts.
+func TestPruneBlockBodies(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), frdir, "")
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend")
+	}
+	defer db.Close()
+
+	// Freeze a handful of blocks, each with a transfer and a contract creation
+	var (
+		key, _   = crypto.GenerateKey()
+		signer   = types.NewEIP155Signer(params.TestChainConfig.ChainID)
+		blocks   []*types.Block
+		receipts []types.Receipts
+	)
+	for i := 0; i < 8; i++ {
+		transfer, _ := types.SignTx(types.NewTransaction(uint64(2*i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil), signer, key)
+		create, _ := types.SignTx(types.NewContractCreation(uint64(2*i+1), big.NewInt(0), 1, big.NewInt(1), nil), signer, key)
+		txs := []*types.Transaction{transfer, create}
+		raw := types.Receipts{
+			{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: uint64(i + 1), Logs: []*types.Log{{Address: common.HexToAddress("0x1")}}},
+			{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: uint64(2*i + 3), Logs: []*types.Log{}},
+		}
+		block := types.NewBlock(&types.Header{Number: big.NewInt(int64(i))}, txs, nil, raw)
+		WriteAncientBlock(db, block, raw, big.NewInt(int64(i)))
+		WriteTxLookupEntries(db, block)
+
+		full := ReadReceipts(db, block.Hash(), block.NumberU64(), params.TestChainConfig)
+		if len(full) != 2 || full[1].ContractAddress == (common.Address{}) {
+			t.Fatalf("block %d: failed to derive receipts before pruning", i)
+		}
+		blocks, receipts = append(blocks, block), append(receipts, full)
+	}
+	// Prune beyond the ancient limit and ensure it's capped
+	if err := PruneBlockBodies(db, 10); err != nil {
+		t.Fatalf("failed to prune block bodies: %v", err)
+	}
+	if tail := ReadBodyPruneTail(db); tail != 8 {
+		t.Fatalf("prune tail mismatch: have %d, want %d", tail, 8)
+	}
+	for i, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+
+		if body := ReadBody(db, hash, number); body != nil {
+			t.Errorf("block %d: pruned body returned", i)
+		}
+		if pruned := ReadBlock(db, hash, number); pruned == nil {
+			t.Errorf("block %d: pruned block not found", i)
+		} else if pruned.Hash() != hash || len(pruned.Transactions()) != 0 || len(pruned.Uncles()) != 0 {
+			t.Errorf("block %d: pruned block mismatch: have %x/%d txs, want %x/0 txs", i, pruned.Hash(), len(pruned.Transactions()), hash)
+		}
+		tx := block.Transactions()[0]
+		if txn, _, _, _ := ReadTransaction(db, tx.Hash()); txn != nil {
+			t.Errorf("block %d: pruned transaction returned", i)
+		}
+		// Receipts must remain readable with all their derived fields
+		have := ReadReceipts(db, hash, number, params.TestChainConfig)
+		if !reflect.DeepEqual(have, receipts[i]) {
+			t.Errorf("block %d: receipts mismatch after pruning:\nhave %+v\nwant %+v", i, have, receipts[i])
+		}
+	}
+}
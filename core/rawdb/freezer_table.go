@@ -21,8 +21,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -43,6 +45,10 @@ var (
 
 	// errNotSupported is returned if the database doesn't support the required operation.
 	errNotSupported = errors.New("this operation is not supported")
+
+	// errTruncateBelowTail is returned if the user attempts to truncate the head
+	// of the freezer table beneath the items already deleted from its tail.
+	errTruncateBelowTail = errors.New("truncation below the table tail")
 )
 
 // indexEntry contains the number/id of the file that the data resides in, aswell as the
@@ -74,10 +80,11 @@ func (i *indexEntry) marshallBinary() []byte {
 // It consists of a data file (snappy encoded arbitrary data blobs) and an indexEntry
 // file (uncompressed 64 bit indices into the data file).
 type freezerTable struct {
-	// WARNING: The `items` field is accessed atomically. On 32 bit platforms, only
-	// 64-bit aligned fields can be atomic. The struct is guaranteed to be so aligned,
-	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
-	items uint64 // Number of items stored in the table (including items removed from tail)
+	// WARNING: The `items` and `hidden` fields are accessed atomically. On 32 bit
+	// platforms, only 64-bit aligned fields can be atomic. The struct is guaranteed
+	// to be so aligned, so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
+	items  uint64 // Number of items stored in the table (including items removed from tail)
+	hidden uint64 // Number of leading items hidden from readers (deleted or not yet)
 
 	noCompression bool   // if true, disables snappy compression. Note: does not work retroactively
 	maxFileSize   uint32 // Max file size for data-files
@@ -232,8 +239,8 @@ func (t *freezerTable) repair() error {
 	t.index.ReadAt(buffer, 0)
 	firstIndex.unmarshalBinary(buffer)
 
-	t.tailId = firstIndex.offset
-	t.itemOffset = firstIndex.filenum
+	t.tailId = firstIndex.filenum
+	t.itemOffset = firstIndex.offset
 
 	t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 	lastIndex.unmarshalBinary(buffer)
@@ -248,6 +255,9 @@ func (t *freezerTable) repair() error {
 
 	// Keep truncating both files until they come in sync
 	contentExp = int64(lastIndex.offset)
+	if offsetsSize == indexEntrySize {
+		contentExp = 0 // index zero only tracks the tail, the table is empty
+	}
 
 	for contentExp != contentSize {
 		// Truncate the head file to the last offset pointer
@@ -284,6 +294,9 @@ func (t *freezerTable) repair() error {
 			}
 			lastIndex = newLastIndex
 			contentExp = int64(lastIndex.offset)
+			if offsetsSize == indexEntrySize {
+				contentExp = 0
+			}
 		}
 	}
 	// Ensure all reparation changes have been written to disk
@@ -333,6 +346,15 @@ func (t *freezerTable) truncate(items uint64) error {
 	if atomic.LoadUint64(&t.items) <= items {
 		return nil
 	}
+	// Items already deleted from the tail cannot be resurrected
+	offset := uint64(atomic.LoadUint32(&t.itemOffset))
+	if items < offset {
+		return errTruncateBelowTail
+	}
+	// Items appended again after the truncation are not hidden
+	if atomic.LoadUint64(&t.hidden) > items {
+		atomic.StoreUint64(&t.hidden, items)
+	}
 	// We need to truncate, save the old size for metrics tracking
 	oldSize, err := t.sizeNolock()
 	if err != nil {
@@ -340,16 +362,20 @@ func (t *freezerTable) truncate(items uint64) error {
 	}
 	// Something's out of sync, truncate the table's offset index
 	t.logger.Warn("Truncating freezer table", "items", t.items, "limit", items)
-	if err := truncateFreezerFile(t.index, int64(items+1)*indexEntrySize); err != nil {
+	if err := truncateFreezerFile(t.index, int64(items-offset+1)*indexEntrySize); err != nil {
 		return err
 	}
 	// Calculate the new expected size of the data file and truncate it
 	buffer := make([]byte, indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+	if _, err := t.index.ReadAt(buffer, int64((items-offset)*indexEntrySize)); err != nil {
 		return err
 	}
 	var expected indexEntry
 	expected.unmarshalBinary(buffer)
+	if items == offset {
+		// Index zero tracks the tail, not a data offset
+		expected = indexEntry{filenum: t.tailId}
+	}
 
 	// We might need to truncate back to older files
 	if expected.filenum != t.headId {
@@ -383,6 +409,106 @@ func (t *freezerTable) truncate(items uint64) error {
 	return nil
 }
 
+// truncateTail discards all data files at the beginning of the table that only
+// contain items below the provided threshold. Since items are not deleted one by
+// one, the data file holding the threshold item (and thus possibly some items
+// before it) is retained, but all the items below the threshold are hidden from
+// readers until the table is reopened.
+func (t *freezerTable) truncateTail(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Ensure the table is still accessible
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	// Figure out which data file the threshold item resides in
+	var (
+		offset = uint64(atomic.LoadUint32(&t.itemOffset))
+		total  = atomic.LoadUint64(&t.items)
+		buffer = make([]byte, indexEntrySize)
+		entry  indexEntry
+	)
+	if hidden := items; hidden > atomic.LoadUint64(&t.hidden) {
+		if hidden > total {
+			hidden = total
+		}
+		atomic.StoreUint64(&t.hidden, hidden)
+	}
+	if items <= offset {
+		return nil
+	}
+	tailId := atomic.LoadUint32(&t.headId)
+	if items < total {
+		if _, err := t.index.ReadAt(buffer, int64((items-offset+1)*indexEntrySize)); err != nil {
+			return err
+		}
+		entry.unmarshalBinary(buffer)
+		tailId = entry.filenum
+	}
+	if tailId <= t.tailId {
+		return nil
+	}
+	// Find the first item contained within the new tail file. Items never span
+	// multiple data files, so it is the one following the last item of the
+	// previous file.
+	var (
+		entries = total - offset
+		first   = uint64(sort.Search(int(entries), func(i int) bool {
+			t.index.ReadAt(buffer, int64(i+1)*indexEntrySize)
+			entry.unmarshalBinary(buffer)
+			return entry.filenum >= tailId
+		}))
+	)
+	// We need to truncate, save the old size for metrics tracking
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.logger.Info("Truncating freezer table tail", "items", offset+first, "tail", tailId)
+
+	// Assemble the new index file with index zero pointing to the new tail and
+	// atomically replace the old one.
+	blob := make([]byte, (entries-first+1)*indexEntrySize)
+	if _, err := t.index.ReadAt(blob[indexEntrySize:], int64((first+1)*indexEntrySize)); err != nil {
+		return err
+	}
+	head := indexEntry{filenum: tailId, offset: uint32(offset + first)}
+	copy(blob, head.marshallBinary())
+
+	name := t.index.Name()
+	if err := ioutil.WriteFile(name+".tmp", blob, 0644); err != nil {
+		return err
+	}
+	if err := t.index.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	if t.index, err = openFreezerFileForAppend(name); err != nil {
+		return err
+	}
+	// Delete all the data files beneath the new tail
+	for i := t.tailId; i < tailId; i++ {
+		t.releaseFile(i)
+		if err := os.Remove(t.fileName(i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	t.tailId = tailId
+	atomic.StoreUint32(&t.itemOffset, uint32(offset+first))
+
+	// Retrieve the new size and update the total size counter
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+
+	return nil
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -411,13 +537,7 @@ func (t *freezerTable) Close() error {
 func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error)) (f *os.File, err error) {
 	var exist bool
 	if f, exist = t.files[num]; !exist {
-		var name string
-		if t.noCompression {
-			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
-		} else {
-			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
-		}
-		f, err = opener(filepath.Join(t.path, name))
+		f, err = opener(t.fileName(num))
 		if err != nil {
 			return nil, err
 		}
@@ -426,6 +546,14 @@ func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error
 	return f, err
 }
 
+// fileName returns the path of the data file with the given number.
+func (t *freezerTable) fileName(num uint32) string {
+	if t.noCompression {
+		return filepath.Join(t.path, fmt.Sprintf("%s.%04d.rdat", t.name, num))
+	}
+	return filepath.Join(t.path, fmt.Sprintf("%s.%04d.cdat", t.name, num))
+}
+
 // releaseFile closes a file, and removes it from the open file cache.
 // Assumes that the caller holds the write lock
 func (t *freezerTable) releaseFile(num uint32) {
@@ -529,6 +657,11 @@ func (t *freezerTable) getBounds(item uint64) (uint32, uint32, uint32, error) {
 		return 0, 0, 0, err
 	}
 	endIdx.unmarshalBinary(buffer)
+	if item == 0 {
+		// Index zero tracks the tail of the table, the first item always starts
+		// at the beginning of its data file.
+		return 0, endIdx.offset, endIdx.filenum, nil
+	}
 	if startIdx.filenum != endIdx.filenum {
 		// If a piece of data 'crosses' a data-file,
 		// it's actually in one piece on the second data-file.
@@ -548,9 +681,9 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 	if atomic.LoadUint64(&t.items) <= item {
 		return nil, errOutOfBounds
 	}
-	// Ensure the item was not deleted from the tail either
+	// Ensure the item was not deleted or hidden from the tail either
 	offset := atomic.LoadUint32(&t.itemOffset)
-	if uint64(offset) > item {
+	if uint64(offset) > item || atomic.LoadUint64(&t.hidden) > item {
 		return nil, errOutOfBounds
 	}
 	t.lock.RLock()
@@ -579,6 +712,108 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 	return snappy.Decode(nil, blob)
 }
 
+// RetrieveItems returns up to count consecutive items starting at the given one.
+// Contrary to calling Retrieve repeatedly, the index entries of all the items are
+// read at once and the data with a single read per data file. Fewer items are
+// returned if the table ends earlier, or if their stored (possibly compressed)
+// size would exceed maxBytes, but at least one item is always returned. A zero
+// maxBytes disables the size limit.
+func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, error) {
+	blobs, err := t.retrieveItems(start, count, maxBytes)
+	if err != nil || t.noCompression {
+		return blobs, err
+	}
+	for i, blob := range blobs {
+		decoded, err := snappy.Decode(nil, blob)
+		if err != nil {
+			return nil, err
+		}
+		blobs[i] = decoded
+	}
+	return blobs, nil
+}
+
+// retrieveItems reads the raw data of a range of items, see RetrieveItems.
+func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([][]byte, error) {
+	// Hold the read lock throughout, so truncations can't interfere
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	// Ensure the table and the first item are accessible
+	if t.index == nil || t.head == nil {
+		return nil, errClosed
+	}
+	var (
+		items  = atomic.LoadUint64(&t.items)
+		offset = uint64(atomic.LoadUint32(&t.itemOffset))
+	)
+	if items <= start || offset > start || atomic.LoadUint64(&t.hidden) > start {
+		return nil, errOutOfBounds
+	}
+	if count > items-start {
+		count = items - start
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	// Read all the index entries bounding the requested items at once
+	var (
+		first   = start - offset
+		buffer  = make([]byte, (count+1)*indexEntrySize)
+		entries = make([]indexEntry, count+1)
+	)
+	if _, err := t.index.ReadAt(buffer, int64(first*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].unmarshalBinary(buffer[i*indexEntrySize:])
+	}
+	// Figure out the location of the items that fit into the size limit. Items
+	// never span multiple data files, one crossing into a new file starts at its
+	// very beginning (as does the first item after the tail).
+	type location struct {
+		filenum    uint32
+		start, end uint32
+	}
+	var (
+		locations []location
+		size      uint64
+	)
+	for i := uint64(0); i < count; i++ {
+		loc := location{filenum: entries[i+1].filenum, end: entries[i+1].offset}
+		if first+i > 0 && entries[i].filenum == loc.filenum {
+			loc.start = entries[i].offset
+		}
+		if maxBytes > 0 && size+uint64(loc.end-loc.start) > maxBytes && len(locations) > 0 {
+			break
+		}
+		size += uint64(loc.end - loc.start)
+		locations = append(locations, loc)
+	}
+	// Read the data of all items residing in the same data file at once
+	blobs := make([][]byte, len(locations))
+	for i := 0; i < len(locations); {
+		j := i + 1
+		for j < len(locations) && locations[j].filenum == locations[i].filenum {
+			j++
+		}
+		dataFile, exist := t.files[locations[i].filenum]
+		if !exist {
+			return nil, fmt.Errorf("missing data file %d", locations[i].filenum)
+		}
+		data := make([]byte, locations[j-1].end-locations[i].start)
+		if _, err := dataFile.ReadAt(data, int64(locations[i].start)); err != nil {
+			return nil, err
+		}
+		for k := i; k < j; k++ {
+			blobs[k] = data[locations[k].start-locations[i].start : locations[k].end-locations[i].start]
+		}
+		i = j
+	}
+	t.readMeter.Mark(int64(len(buffer)) + int64(size))
+	return blobs, nil
+}
+
 // has returns an indicator whether the specified number data
 // exists in the freezer table.
 func (t *freezerTable) has(number uint64) bool {
@@ -554,8 +554,8 @@ func TestOffset(t *testing.T) {
 		tailId := uint32(2)     // First file is 2
 		itemOffset := uint32(4) // We have removed four items
 		zeroIndex := indexEntry{
-			offset:  tailId,
-			filenum: itemOffset,
+			filenum: tailId,
+			offset:  itemOffset,
 		}
 		buf := zeroIndex.marshallBinary()
 		// Overwrite index zero
@@ -604,6 +604,173 @@ func TestOffset(t *testing.T) {
 	}
 }
 
+// TestTruncateTail tests that deleting data files from the tail of the table
+// retains all the items above the threshold, and survives a reopen.
+func TestTruncateTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncate-tail-%d", rand.Uint64())
+	{
+		f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Write 10 items of 20 bytes each, resulting in 5 data files
+		for x := 0; x < 10; x++ {
+			f.Append(uint64(x), getChunk(20, x))
+		}
+		// Item 5 lives in file 2, so only files 0 and 1 can be dropped
+		if err := f.truncateTail(5); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(f.fileName(1)); !os.IsNotExist(err) {
+			t.Fatalf("data file 1 not deleted: %v", err)
+		}
+		if _, err := os.Stat(f.fileName(2)); err != nil {
+			t.Fatalf("data file 2 deleted: %v", err)
+		}
+		// Item 4 shares the retained data file, but must be hidden nonetheless
+		if _, err := f.Retrieve(4); err != errOutOfBounds {
+			t.Fatalf("item 4: have %v, want %v", err, errOutOfBounds)
+		}
+		f.Close()
+	}
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 40, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.items != 10 {
+		t.Fatalf("item count mismatch: have %d, want %d", f.items, 10)
+	}
+	for x := 0; x < 4; x++ {
+		if _, err := f.Retrieve(uint64(x)); err == nil {
+			t.Fatalf("item %d: expected error", x)
+		}
+	}
+	for x := 4; x < 10; x++ {
+		if got, err := f.Retrieve(uint64(x)); err != nil {
+			t.Fatalf("item %d: %v", x, err)
+		} else if exp := getChunk(20, x); !bytes.Equal(got, exp) {
+			t.Fatalf("item %d: expected %x got %x", x, exp, got)
+		}
+	}
+	// Ensure appending continues after the reopen
+	if err := f.Append(10, getChunk(20, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Retrieve(10); err != nil {
+		t.Fatal(err)
+	} else if exp := getChunk(20, 10); !bytes.Equal(got, exp) {
+		t.Fatalf("expected %x got %x", exp, got)
+	}
+}
+
+// TestRetrieveItems tests that batched retrievals return the same items as
+// individual ones, across data files and after deleting from the tail.
+func TestRetrieveItems(t *testing.T) {
+	t.Parallel()
+	for _, noCompression := range []bool{true, false} {
+		f, err := newCustomTable(os.TempDir(), fmt.Sprintf("retrieve-items-%d", rand.Uint64()),
+			metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 50, noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Write 30 items of varying sizes, spread over multiple data files
+		for x := 0; x < 30; x++ {
+			f.Append(uint64(x), getChunk(5+x%4*5, x))
+		}
+		check := func(start, count, maxBytes uint64, want int) {
+			items, err := f.RetrieveItems(start, count, maxBytes)
+			if err != nil {
+				t.Fatalf("range %d+%d: %v", start, count, err)
+			}
+			if len(items) != want {
+				t.Fatalf("range %d+%d (max %d): item count mismatch: have %d, want %d", start, count, maxBytes, len(items), want)
+			}
+			for i, item := range items {
+				if exp := getChunk(5+(int(start)+i)%4*5, int(start)+i); !bytes.Equal(item, exp) {
+					t.Fatalf("range %d+%d: item %d: expected %x got %x", start, count, i, exp, item)
+				}
+			}
+		}
+		check(0, 30, 0, 30)
+		check(7, 10, 0, 10)
+		check(25, 10, 0, 5)
+		check(29, 1, 0, 1)
+		if noCompression {
+			check(0, 30, 30, 3)  // 5 + 10 + 15 bytes
+			check(3, 30, 1, 1)   // at least one item is returned
+			check(4, 30, 100, 8) // 5 + 10 + 15 + 20 + 5 + 10 + 15 + 20 bytes
+		}
+		if _, err := f.RetrieveItems(30, 1, 0); err != errOutOfBounds {
+			t.Fatalf("out of bounds range: have %v, want %v", err, errOutOfBounds)
+		}
+		// Drop some data files from the tail and ensure the rest is retrievable
+		if err := f.truncateTail(12); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.RetrieveItems(0, 5, 0); err != errOutOfBounds {
+			t.Fatalf("deleted range: have %v, want %v", err, errOutOfBounds)
+		}
+		if _, err := f.RetrieveItems(11, 5, 0); err != errOutOfBounds {
+			t.Fatalf("hidden range: have %v, want %v", err, errOutOfBounds)
+		}
+		check(12, 30, 0, 18)
+		check(15, 5, 0, 5)
+		f.Close()
+	}
+}
+
+// benchmarkRetrieval writes 10K items into a freezer table and reads them back
+// with the given retrieval function.
+func benchmarkRetrieval(b *testing.B, retrieve func(f *freezerTable, start, count uint64) error) {
+	f, err := newTable(os.TempDir(), fmt.Sprintf("bench-retrieve-%d", rand.Uint64()),
+		metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(f.index.Name())
+	defer os.RemoveAll(f.fileName(0))
+	defer f.Close()
+
+	const items = 10000
+	for x := 0; x < items; x++ {
+		f.Append(uint64(x), getChunk(500, x))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := retrieve(f, 0, items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRetrieve(b *testing.B) {
+	benchmarkRetrieval(b, func(f *freezerTable, start, count uint64) error {
+		for x := start; x < start+count; x++ {
+			if _, err := f.Retrieve(x); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkRetrieveItems(b *testing.B) {
+	benchmarkRetrieval(b, func(f *freezerTable, start, count uint64) error {
+		for start < count {
+			items, err := f.RetrieveItems(start, 256, 0)
+			if err != nil {
+				return err
+			}
+			start += uint64(len(items))
+		}
+		return nil
+	})
+}
+
 // TODO (?)
 // - test that if we remove several head-files, aswell as data last data-file,
 //   the index is truncated accordingly
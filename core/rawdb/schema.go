@@ -41,6 +41,15 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// bodyPruneTailKey tracks the first block whose body is retained after pruning.
+	bodyPruneTailKey = []byte("BodyPruneTail")
+
+	// statePruneJournalKey tracks the progress of an interrupted state pruning.
+	statePruneJournalKey = []byte("StatePruneJournal")
+
+	// uncleanShutdownKey tracks the list of local crashes.
+	uncleanShutdownKey = []byte("unclean-shutdown")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -49,6 +58,7 @@ var (
 
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
+	prunedTxsPrefix     = []byte("P") // prunedTxsPrefix + num (uint64 big endian) + hash -> receipt fields of pruned transactions
 
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
@@ -140,6 +150,11 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// prunedTxsKey = prunedTxsPrefix + num (uint64 big endian) + hash
+func prunedTxsKey(number uint64, hash common.Hash) []byte {
+	return append(append(prunedTxsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
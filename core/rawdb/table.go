@@ -62,6 +62,12 @@ func (t *table) Ancient(kind string, number uint64) ([]byte, error) {
 	return t.db.Ancient(kind, number)
 }
 
+// AncientRange is a noop passthrough that just forwards the request to the
+// underlying database.
+func (t *table) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return t.db.AncientRange(kind, start, count, maxBytes)
+}
+
 // Ancients is a noop passthrough that just forwards the request to the underlying
 // database.
 func (t *table) Ancients() (uint64, error) {
@@ -122,6 +128,16 @@ func (t *table) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
 	return t.db.NewIteratorWithPrefix(append([]byte(t.prefix), prefix...))
 }
 
+// NewSnapshot creates a snapshot of the current state of the database, which
+// prefixes each key access with the pre-configured string.
+func (t *table) NewSnapshot() (ethdb.Snapshot, error) {
+	snap, err := t.db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &tableSnapshot{snap: snap, prefix: t.prefix}, nil
+}
+
 // Stat returns a particular internal stat of the database.
 func (t *table) Stat(property string) (string, error) {
 	return t.db.Stat(property)
@@ -202,3 +218,44 @@ func (b *tableBatch) Reset() {
 func (b *tableBatch) Replay(w ethdb.KeyValueWriter) error {
 	return b.batch.Replay(w)
 }
+
+// tableSnapshot is a wrapper around a database snapshot that prefixes each key
+// access with a pre-configured string.
+type tableSnapshot struct {
+	snap   ethdb.Snapshot
+	prefix string
+}
+
+// Has retrieves if a prefixed version of a key is present in the snapshot.
+func (s *tableSnapshot) Has(key []byte) (bool, error) {
+	return s.snap.Has(append([]byte(s.prefix), key...))
+}
+
+// Get retrieves the given prefixed key if it's present in the snapshot.
+func (s *tableSnapshot) Get(key []byte) ([]byte, error) {
+	return s.snap.Get(append([]byte(s.prefix), key...))
+}
+
+// NewIterator creates a binary-alphabetical iterator over the entire keyspace
+// contained within the snapshot.
+func (s *tableSnapshot) NewIterator() ethdb.Iterator {
+	return s.NewIteratorWithPrefix(nil)
+}
+
+// NewIteratorWithStart creates a binary-alphabetical iterator over a subset of
+// the snapshot starting at a particular initial key (or after, if it does not
+// exist).
+func (s *tableSnapshot) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return s.snap.NewIteratorWithStart(start)
+}
+
+// NewIteratorWithPrefix creates a binary-alphabetical iterator over a subset
+// of the snapshot with a particular key prefix.
+func (s *tableSnapshot) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return s.snap.NewIteratorWithPrefix(append([]byte(s.prefix), prefix...))
+}
+
+// Release releases the underlying snapshot.
+func (s *tableSnapshot) Release() {
+	s.snap.Release()
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"encoding/binary"
+	"os"
+
+	"github.com/steakknife/bloomfilter"
+)
+
+// stateBloomHasher is a wrapper around a byte blob to satisfy the interface API
+// requirements of the bloom library used. It's used to convert a trie hash or a
+// contract code hash into a 64 bit mini hash.
+type stateBloomHasher []byte
+
+func (f stateBloomHasher) Write(p []byte) (n int, err error) { panic("not implemented") }
+func (f stateBloomHasher) Sum(b []byte) []byte               { panic("not implemented") }
+func (f stateBloomHasher) Reset()                            { panic("not implemented") }
+func (f stateBloomHasher) BlockSize() int                    { panic("not implemented") }
+func (f stateBloomHasher) Size() int                         { return 8 }
+func (f stateBloomHasher) Sum64() uint64                     { return binary.BigEndian.Uint64(f) }
+
+// stateBloom is a bloom filter marking the trie nodes and contract codes which
+// are reachable from the retained states. False positives merely leave a few
+// unreachable entries on disk, but reachable entries are never reported missing.
+type stateBloom struct {
+	bloom *bloomfilter.Filter
+}
+
+// newStateBloom creates an empty bloom filter of the given size in megabytes.
+// The bloom is hard coded to use 4 filters.
+func newStateBloom(size uint64) (*stateBloom, error) {
+	bloom, err := bloomfilter.New(size*1024*1024*8, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &stateBloom{bloom: bloom}, nil
+}
+
+// loadStateBloom loads a bloom filter persisted by commit. The file carries a
+// checksum, so a truncated or corrupted bloom is refused.
+func loadStateBloom(path string) (*stateBloom, error) {
+	bloom, _, err := bloomfilter.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &stateBloom{bloom: bloom}, nil
+}
+
+// commit persists the bloom filter into the given file. It's written into a
+// temporary file first, so the file is either complete or missing.
+func (b *stateBloom) commit(path string) error {
+	tmp := path + ".tmp"
+	if _, err := b.bloom.WriteFile(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// add marks the given hash as reachable.
+func (b *stateBloom) add(hash []byte) {
+	b.bloom.Add(stateBloomHasher(hash))
+}
+
+// contains reports whether the given hash may have been marked reachable.
+func (b *stateBloom) contains(hash []byte) bool {
+	return b.bloom.Contains(stateBloomHasher(hash))
+}
@@ -0,0 +1,294 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements the deletion of historical state which isn't
+// reachable from the retained recent states anymore.
+package pruner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// stateBloomFileName is the name of the file the marked state is persisted into,
+// allowing an interrupted sweep to resume.
+const stateBloomFileName = "statebloom.bf.gz"
+
+// sweepBatchSize is the amount of deletions accumulated before they are flushed
+// to disk along with the progress of the sweep. It's a variable for testing.
+var sweepBatchSize = ethdb.IdealBatchSize
+
+var (
+	errNoRetainedState   = errors.New("no state to retain")
+	errIncompleteMarking = errors.New("marking of retained state incomplete")
+	errPruningAborted    = errors.New("state pruning aborted")
+)
+
+// pruneJournal is the progress of a state pruning, persisted in the database so
+// that an interrupted pruning can be resumed.
+type pruneJournal struct {
+	Roots   []common.Hash // State roots marked reachable in the bloom filter
+	Marked  bool          // Whether marking of the roots completed and the bloom was persisted
+	Cursor  []byte        // Key the sweep continues from, all keys before it were swept
+	Deleted uint64        // Number of entries deleted by the sweep so far
+}
+
+// marked checks whether the given state root was already marked reachable.
+func (j *pruneJournal) marked(root common.Hash) bool {
+	for _, marked := range j.Roots {
+		if marked == root {
+			return true
+		}
+	}
+	return false
+}
+
+// Pruner deletes the trie nodes and contract codes of historical states from the
+// database in two phases. First, all trie nodes and contract codes reachable from
+// the retained state roots are marked in a bloom filter, which is persisted once
+// complete. Then, all trie nodes and contract codes of the database which aren't
+// marked are swept.
+//
+// Nothing is deleted unless marking completed for every retained root. The
+// progress is journaled, so an interrupted sweep resumes where it left off.
+type Pruner struct {
+	db        ethdb.Database
+	bloomPath string // File the bloom filter is persisted into, empty to keep it in memory only
+	bloomSize uint64 // Size of the bloom filter in megabytes
+}
+
+// NewPruner creates a pruner of the state stored in the given database, using a
+// bloom filter of the given size in megabytes. The bloom filter is persisted in
+// the given directory to resume interrupted sweeps. If it's empty, the bloom is
+// kept in memory only, and interrupted prunings restart with marking.
+func NewPruner(db ethdb.Database, datadir string, bloomSize uint64) *Pruner {
+	var path string
+	if datadir != "" {
+		path = filepath.Join(datadir, stateBloomFileName)
+	}
+	return &Pruner{
+		db:        db,
+		bloomPath: path,
+		bloomSize: bloomSize,
+	}
+}
+
+// Prune deletes all trie nodes and contract codes from the database which are not
+// reachable from the given state roots, returning the number of deleted entries.
+// The states are accessed through the given state database, so recent states that
+// are only cached in memory can be retained too, however no new state must be
+// written to disk while pruning.
+//
+// If an interrupted pruning is pending, it's resumed: only the roots that weren't
+// marked yet are marked, and the sweep continues where it left off.
+func (p *Pruner) Prune(statedb state.Database, roots []common.Hash, quit <-chan struct{}) (uint64, error) {
+	if len(roots) == 0 {
+		return 0, errNoRetainedState
+	}
+	journal, bloom := p.loadJournal()
+	if bloom == nil {
+		// Nothing usable was marked before, start marking from scratch. Entries
+		// swept already were unreachable, so the sweep may safely restart too.
+		var err error
+		if bloom, err = newStateBloom(p.bloomSize); err != nil {
+			return 0, err
+		}
+		journal = &pruneJournal{Deleted: journal.Deleted}
+		p.writeJournal(p.db, journal)
+	}
+	// Mark the retained states which weren't marked yet
+	var (
+		start  = time.Now()
+		marked []common.Hash
+	)
+	for _, root := range roots {
+		if journal.marked(root) {
+			continue
+		}
+		if err := p.mark(statedb, root, bloom, quit); err != nil {
+			if err == errPruningAborted {
+				return journal.Deleted, err
+			}
+			log.Error("Failed to mark retained state", "root", root, "err", err)
+			return journal.Deleted, errIncompleteMarking
+		}
+		marked = append(marked, root)
+	}
+	if len(marked) > 0 || !journal.Marked {
+		if p.bloomPath != "" {
+			if err := bloom.commit(p.bloomPath); err != nil {
+				return journal.Deleted, err
+			}
+		}
+		journal.Roots = append(journal.Roots, marked...)
+		journal.Marked = true
+		p.writeJournal(p.db, journal)
+
+		log.Info("Marked retained state", "roots", len(marked), "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return p.sweep(journal, bloom, quit)
+}
+
+// loadJournal retrieves the progress of an interrupted pruning and its bloom
+// filter. If no pruning is pending, an empty journal is returned. If marking was
+// not completed or its bloom filter is unusable, the journal is returned without
+// a bloom filter.
+func (p *Pruner) loadJournal() (*pruneJournal, *stateBloom) {
+	blob := rawdb.ReadStatePruneJournal(p.db)
+	if len(blob) == 0 {
+		return new(pruneJournal), nil
+	}
+	journal := new(pruneJournal)
+	if err := rlp.DecodeBytes(blob, journal); err != nil {
+		log.Warn("Discarding corrupted state prune journal", "err", err)
+		return new(pruneJournal), nil
+	}
+	if !journal.Marked {
+		log.Warn("Discarding incomplete state marking")
+		return journal, nil
+	}
+	if p.bloomPath == "" {
+		log.Warn("Discarding state marking kept in memory")
+		return journal, nil
+	}
+	bloom, err := loadStateBloom(p.bloomPath)
+	if err != nil {
+		log.Warn("Discarding unusable state marking", "path", p.bloomPath, "err", err)
+		return journal, nil
+	}
+	log.Info("Resuming interrupted state pruning", "roots", len(journal.Roots), "deleted", journal.Deleted)
+	return journal, bloom
+}
+
+// writeJournal persists the progress of the pruning.
+func (p *Pruner) writeJournal(db ethdb.KeyValueWriter, journal *pruneJournal) {
+	blob, err := rlp.EncodeToBytes(journal)
+	if err != nil {
+		log.Crit("Failed to encode state prune journal", "err", err)
+	}
+	rawdb.WriteStatePruneJournal(db, blob)
+}
+
+// mark iterates over the entire state with the given root, including storage
+// tries and contract codes, and adds every entry to the bloom filter. An error is
+// returned if any of them is missing.
+func (p *Pruner) mark(statedb state.Database, root common.Hash, bloom *stateBloom, quit <-chan struct{}) error {
+	st, err := state.New(root, statedb)
+	if err != nil {
+		return err
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		count  uint64
+	)
+	it := state.NewNodeIterator(st)
+	for it.Next() {
+		// Nodes embedded into their parents have no hash and aren't stored
+		if it.Hash != (common.Hash{}) {
+			bloom.add(it.Hash[:])
+			count++
+		}
+		if count%10000 == 0 {
+			select {
+			case <-quit:
+				return errPruningAborted
+			default:
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Marking retained state", "root", root, "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+	}
+	if it.Error != nil {
+		return it.Error
+	}
+	log.Debug("Marked retained state", "root", root, "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// sweep deletes all trie nodes and contract codes of the database which aren't
+// marked in the bloom filter, starting from the cursor of the journal. Progress
+// is persisted along with the deletions, and the journal is removed once done.
+func (p *Pruner) sweep(journal *pruneJournal, bloom *stateBloom, quit <-chan struct{}) (uint64, error) {
+	// Never delete anything unless every retained state was marked reachable
+	if !journal.Marked || bloom == nil {
+		return journal.Deleted, errIncompleteMarking
+	}
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		deleted = journal.Deleted
+		batch   = p.db.NewBatch()
+	)
+	it := p.db.NewIteratorWithStart(journal.Cursor)
+	defer it.Release()
+
+	for it.Next() {
+		// Trie nodes and contract codes are the only entries keyed by their hash
+		key := it.Key()
+		if len(key) == common.HashLength && !bloom.contains(key) {
+			batch.Delete(key)
+			deleted++
+		}
+		if batch.ValueSize() < sweepBatchSize {
+			continue
+		}
+		// Flush the deletions atomically with the progress made
+		progress := *journal
+		progress.Cursor, progress.Deleted = common.CopyBytes(key), deleted
+		p.writeJournal(batch, &progress)
+		if err := batch.Write(); err != nil {
+			return journal.Deleted, err
+		}
+		batch.Reset()
+		*journal = progress
+
+		select {
+		case <-quit:
+			return journal.Deleted, errPruningAborted
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state", "at", common.ToHex(key), "deleted", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return journal.Deleted, err
+	}
+	rawdb.DeleteStatePruneJournal(batch)
+	if err := batch.Write(); err != nil {
+		return journal.Deleted, err
+	}
+	if p.bloomPath != "" {
+		if err := os.Remove(p.bloomPath); err != nil && !os.IsNotExist(err) {
+			log.Warn("Failed to remove state bloom", "path", p.bloomPath, "err", err)
+		}
+	}
+	log.Info("Pruned state", "deleted", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+	return deleted, nil
+}
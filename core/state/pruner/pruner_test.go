@@ -0,0 +1,275 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// makeStates creates a sequence of states in the database, each one modifying
+// the balances, storage and code of accounts in the previous one, and returns
+// their roots.
+func makeStates(t *testing.T, db ethdb.Database, n int) []common.Hash {
+	var (
+		sdb   = state.NewDatabase(db)
+		roots []common.Hash
+		root  common.Hash
+	)
+	for i := 0; i < n; i++ {
+		st, err := state.New(root, sdb)
+		if err != nil {
+			t.Fatalf("state %d: failed to open parent: %v", i, err)
+		}
+		for j := 0; j < 20; j++ {
+			addr := common.BigToAddress(big.NewInt(int64(i*5 + j)))
+			st.AddBalance(addr, big.NewInt(int64(i+1)))
+			st.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i+1))))
+			if j%5 == 0 {
+				st.SetCode(addr, []byte{0x60, byte(i), 0x60, byte(j)})
+			}
+		}
+		if root, err = st.Commit(false); err != nil {
+			t.Fatalf("state %d: failed to commit: %v", i, err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("state %d: failed to flush: %v", i, err)
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// checkState iterates over the entire state with the given root from disk,
+// returning an error if any of its entries is missing.
+func checkState(db ethdb.Database, root common.Hash) error {
+	st, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(st)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+// countEntries returns the number of hash keyed entries in the database.
+func countEntries(db ethdb.Database) int {
+	it := db.NewIterator()
+	defer it.Release()
+
+	var count int
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			count++
+		}
+	}
+	return count
+}
+
+// Tests that pruning retains the requested states and deletes all the others.
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := rawdb.NewMemoryDatabase()
+	roots := makeStates(t, db, 10)
+	before := countEntries(db)
+
+	deleted, err := NewPruner(db, dir, 1).Prune(state.NewDatabase(db), roots[7:], nil)
+	if err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if after := countEntries(db); deleted == 0 || after != before-int(deleted) {
+		t.Errorf("deleted entry count mismatch: deleted %d, entries %d -> %d", deleted, before, after)
+	}
+	for i, root := range roots {
+		err := checkState(db, root)
+		if i >= 7 && err != nil {
+			t.Errorf("retained state %d inaccessible: %v", i, err)
+		}
+		if i < 7 && err == nil {
+			t.Errorf("pruned state %d still accessible", i)
+		}
+	}
+	// The pruning is finished, its journal and bloom must be gone
+	if blob := rawdb.ReadStatePruneJournal(db); len(blob) != 0 {
+		t.Errorf("prune journal left behind")
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateBloomFileName)); !os.IsNotExist(err) {
+		t.Errorf("state bloom left behind: %v", err)
+	}
+	// Pruning again must not delete anything retained
+	if deleted, err = NewPruner(db, dir, 1).Prune(state.NewDatabase(db), roots[7:], nil); err != nil {
+		t.Fatalf("failed to prune state again: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("repeated pruning deleted %d entries", deleted)
+	}
+	if _, err := NewPruner(db, dir, 1).Prune(state.NewDatabase(db), nil, nil); err != errNoRetainedState {
+		t.Errorf("error mismatch without roots: have %v, want %v", err, errNoRetainedState)
+	}
+}
+
+// Tests that nothing is deleted if any of the retained states is incomplete.
+func TestPruneIncompleteMarking(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	roots := makeStates(t, db, 10)
+
+	// Delete a node deep inside the most recent state, which isn't shared with
+	// the previous state
+	shared := make(map[common.Hash]bool)
+	st, _ := state.New(roots[8], state.NewDatabase(db))
+	for it := state.NewNodeIterator(st); it.Next(); {
+		shared[it.Hash] = true
+	}
+	var victim common.Hash
+	st, _ = state.New(roots[9], state.NewDatabase(db))
+	for it := state.NewNodeIterator(st); it.Next(); {
+		if it.Hash != (common.Hash{}) && it.Parent != (common.Hash{}) && !shared[it.Hash] {
+			victim = it.Hash
+		}
+	}
+	db.Delete(victim[:])
+	before := countEntries(db)
+
+	if _, err := NewPruner(db, "", 1).Prune(state.NewDatabase(db), roots[7:], nil); err != errIncompleteMarking {
+		t.Fatalf("error mismatch: have %v, want %v", err, errIncompleteMarking)
+	}
+	if after := countEntries(db); after != before {
+		t.Errorf("entries deleted despite incomplete marking: %d -> %d", before, after)
+	}
+	for i, root := range roots[:9] {
+		if err := checkState(db, root); err != nil {
+			t.Errorf("state %d damaged: %v", i, err)
+		}
+	}
+	// The sweep must refuse to run on its own without complete marking
+	journal, bloom := NewPruner(db, "", 1).loadJournal()
+	if _, err := NewPruner(db, "", 1).sweep(journal, bloom, nil); err != errIncompleteMarking {
+		t.Errorf("sweep error mismatch: have %v, want %v", err, errIncompleteMarking)
+	}
+}
+
+// failingDB is a database whose batches fail to be written after a number of
+// successful writes, simulating a crash in the middle of a sweep.
+type failingDB struct {
+	ethdb.Database
+	writes int // Number of batch writes that succeed before failing
+}
+
+func (db *failingDB) NewBatch() ethdb.Batch {
+	return &failingBatch{Batch: db.Database.NewBatch(), db: db}
+}
+
+type failingBatch struct {
+	ethdb.Batch
+	db *failingDB
+}
+
+func (b *failingBatch) Write() error {
+	if b.db.writes == 0 {
+		return errors.New("write failed")
+	}
+	b.db.writes--
+	return b.Batch.Write()
+}
+
+// Tests that a sweep failing midway is resumed, with or without the bloom filter
+// persisted, and with new states created in the meantime.
+func TestPruneResume(t *testing.T)             { testPruneResume(t, true) }
+func TestPruneResumeWithoutBloom(t *testing.T) { testPruneResume(t, false) }
+
+func testPruneResume(t *testing.T, persist bool) {
+	defer func(size int) { sweepBatchSize = size }(sweepBatchSize)
+	sweepBatchSize = 1
+
+	var dir string
+	if persist {
+		var err error
+		if dir, err = ioutil.TempDir("", ""); err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+	}
+	// Prune a reference database in one go to compare against
+	refdb := rawdb.NewMemoryDatabase()
+	roots := makeStates(t, refdb, 10)
+	if _, err := NewPruner(refdb, "", 1).Prune(state.NewDatabase(refdb), roots[7:], nil); err != nil {
+		t.Fatalf("failed to prune reference state: %v", err)
+	}
+	// Prune the same states, failing after a few deletions
+	db := rawdb.NewMemoryDatabase()
+	makeStates(t, db, 10)
+	before := countEntries(db)
+
+	fdb := &failingDB{Database: db, writes: 5}
+	deleted, err := NewPruner(fdb, dir, 1).Prune(state.NewDatabase(fdb), roots[7:], nil)
+	if err == nil {
+		t.Fatalf("sweep didn't fail")
+	}
+	if deleted != 5 || countEntries(db) != before-5 {
+		t.Fatalf("interrupted sweep progress mismatch: deleted %d, entries %d -> %d", deleted, before, countEntries(db))
+	}
+	for i, root := range roots[7:] {
+		if err := checkState(db, root); err != nil {
+			t.Errorf("retained state %d inaccessible after failure: %v", i+7, err)
+		}
+	}
+	journal, bloom := NewPruner(db, dir, 1).loadJournal()
+	if journal.Deleted != 5 || len(journal.Cursor) == 0 || (bloom != nil) != persist {
+		t.Fatalf("journal mismatch: deleted %d, cursor %x, bloom %v", journal.Deleted, journal.Cursor, bloom != nil)
+	}
+	// Resume the pruning, retaining a new state too
+	st, _ := state.New(roots[9], state.NewDatabase(db))
+	st.SetState(common.Address{0xff}, common.Hash{0x01}, common.Hash{0x02})
+	fresh, _ := st.Commit(false)
+	if err := st.Database().TrieDB().Commit(fresh, false); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err = NewPruner(db, dir, 1).Prune(state.NewDatabase(db), append(roots[7:], fresh), nil); err != nil {
+		t.Fatalf("failed to resume pruning: %v", err)
+	}
+	if want := uint64(before - countEntries(refdb)); deleted != want {
+		t.Errorf("total deleted mismatch: have %d, want %d", deleted, want)
+	}
+	for i, root := range append(roots[7:], fresh) {
+		if err := checkState(db, root); err != nil {
+			t.Errorf("retained state %d inaccessible: %v", i+7, err)
+		}
+	}
+	for i, root := range roots[:7] {
+		if err := checkState(db, root); err == nil {
+			t.Errorf("pruned state %d still accessible", i)
+		}
+	}
+	if blob := rawdb.ReadStatePruneJournal(db); len(blob) != 0 {
+		t.Errorf("prune journal left behind")
+	}
+}
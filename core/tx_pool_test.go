@@ -1752,6 +1752,63 @@ func TestTransactionStatusCheck(t *testing.T) {
 	}
 }
 
+// Tests that filtered subscriptions to the transaction feed only receive the
+// events accepted by the filter.
+func TestTransactionFilteredSubscription(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Subscribe to the promotions of transactions sent to a single recipient
+	target := common.Address{0x01}
+
+	events := make(chan NewTxsEvent, 32)
+	sub := pool.txFeed.SubscribeFiltered(events, func(ev interface{}) bool {
+		for _, tx := range ev.(NewTxsEvent).Txs {
+			if to := tx.To(); to != nil && *to == target {
+				return true
+			}
+		}
+		return false
+	})
+	defer sub.Unsubscribe()
+
+	// Add transactions alternating between the target and another recipient
+	var want []common.Hash
+	for i := uint64(0); i < 6; i++ {
+		to := common.Address{0x02}
+		if i%2 == 0 {
+			to = target
+		}
+		tx, _ := types.SignTx(types.NewTransaction(i, to, big.NewInt(100), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		if to == target {
+			want = append(want, tx.Hash())
+		}
+		if err := pool.addRemoteSync(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// Ensure only the transactions to the target were delivered
+	for i, hash := range want {
+		select {
+		case ev := <-events:
+			if len(ev.Txs) != 1 || ev.Txs[0].Hash() != hash {
+				t.Fatalf("event %d: transaction mismatch: have %v, want %x", i, ev.Txs, hash)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not fired", i)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event fired: %v", ev.Txs)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
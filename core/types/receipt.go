@@ -292,30 +292,57 @@ func (r Receipts) GetRlp(i int) []byte {
 	return bytes
 }
 
+// ReceiptTxFields are the fields of a transaction its receipt is derived from.
+// They are retained when the body of the containing block is pruned.
+type ReceiptTxFields struct {
+	TxHash          common.Hash
+	ContractAddress common.Address // Zero unless the transaction created a contract
+}
+
+// NewReceiptTxFields collects the fields of tx its receipt is derived from.
+func NewReceiptTxFields(signer Signer, tx *Transaction) ReceiptTxFields {
+	fields := ReceiptTxFields{TxHash: tx.Hash()}
+	if tx.To() == nil {
+		// Deriving the signer is expensive, only do if it's actually needed
+		from, _ := Sender(signer, tx)
+		fields.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+	}
+	return fields
+}
+
 // DeriveFields fills the receipts with their computed fields based on consensus
 // data and contextual infos like containing block and transactions.
 func (r Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, number uint64, txs Transactions) error {
+	if len(txs) != len(r) {
+		return errors.New("transaction and receipt count mismatch")
+	}
 	signer := MakeSigner(config, new(big.Int).SetUint64(number))
 
+	fields := make([]ReceiptTxFields, len(txs))
+	for i, tx := range txs {
+		fields[i] = NewReceiptTxFields(signer, tx)
+	}
+	return r.DeriveFieldsFromTxs(hash, number, fields)
+}
+
+// DeriveFieldsFromTxs fills the receipts with their computed fields like
+// DeriveFields, but based on the retained fields of the transactions instead of
+// the transactions themselves.
+func (r Receipts) DeriveFieldsFromTxs(hash common.Hash, number uint64, txs []ReceiptTxFields) error {
 	logIndex := uint(0)
 	if len(txs) != len(r) {
 		return errors.New("transaction and receipt count mismatch")
 	}
 	for i := 0; i < len(r); i++ {
-		// The transaction hash can be retrieved from the transaction itself
-		r[i].TxHash = txs[i].Hash()
+		// The transaction hash and contract address are retained from the transaction
+		r[i].TxHash = txs[i].TxHash
+		r[i].ContractAddress = txs[i].ContractAddress
 
 		// block location fields
 		r[i].BlockHash = hash
 		r[i].BlockNumber = new(big.Int).SetUint64(number)
 		r[i].TransactionIndex = uint(i)
 
-		// The contract address can be derived from the transaction itself
-		if txs[i].To() == nil {
-			// Deriving the signer is expensive, only do if it's actually needed
-			from, _ := Sender(signer, txs[i])
-			r[i].ContractAddress = crypto.CreateAddress(from, txs[i].Nonce())
-		}
 		// The used gas can be calculated based on previous r
 		if i == 0 {
 			r[i].GasUsed = r[i].CumulativeGasUsed
@@ -75,6 +75,26 @@ func (api *PublicEthereumAPI) ChainId() hexutil.Uint64 {
 	return (hexutil.Uint64)(chainID.Uint64())
 }
 
+// maxHeadersByRange is the maximum number of headers retrievable at once with
+// GetHeadersByRange.
+const maxHeadersByRange = 1024
+
+// GetHeadersByRange returns up to count consecutive canonical headers starting at
+// the given block number, fewer if the chain ends earlier. Contrary to the single
+// header retrievals, the total difficulty isn't included.
+func (api *PublicEthereumAPI) GetHeadersByRange(from hexutil.Uint64, count hexutil.Uint64) ([]map[string]interface{}, error) {
+	if count > maxHeadersByRange {
+		return nil, fmt.Errorf("too many headers requested: %d > %d", count, maxHeadersByRange)
+	}
+	headers := api.e.blockchain.GetHeadersByRange(uint64(from), uint64(count))
+
+	result := make([]map[string]interface{}, len(headers))
+	for i, header := range headers {
+		result[i] = ethapi.RPCMarshalHeader(header)
+	}
+	return result, nil
+}
+
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -301,6 +321,12 @@ func NewPrivateDebugAPI(eth *Ethereum) *PrivateDebugAPI {
 	return &PrivateDebugAPI{eth: eth}
 }
 
+// TrieDatabaseStats returns the memory usage and flush statistics of the trie
+// database, along with the state roots currently retained in memory.
+func (api *PrivateDebugAPI) TrieDatabaseStats() *trie.DatabaseStats {
+	return api.eth.blockchain.StateCache().TrieDB().Stats()
+}
+
 // Preimage is a debug API function that returns the preimage for a sha3 hash, if known.
 func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	if preimage := rawdb.ReadPreimage(api.eth.ChainDb(), hash); preimage != nil {
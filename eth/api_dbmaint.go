@@ -0,0 +1,461 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errCompactionRunning = errors.New("database compaction already running")
+	errMaintainerDown    = errors.New("database maintainer stopped")
+	errInvalidKeyRange   = errors.New("end prefix precedes start prefix")
+	errPruningRunning    = errors.New("state pruning already running")
+	errPruneWhileSyncing = errors.New("cannot prune state while syncing")
+	errPruneWhileMining  = errors.New("cannot prune state while mining")
+)
+
+// throttleSlice is the amount of work a throttled database maintenance task may
+// do before pausing to honour its IO budget.
+const throttleSlice = 10 * time.Millisecond
+
+// DatabaseCompaction is the state of a compaction of the chain database running
+// in the background.
+type DatabaseCompaction struct {
+	Start     hexutil.Bytes `json:"start"`
+	Limit     hexutil.Bytes `json:"limit"` // Exclusive, empty if running to the end of the keyspace
+	Started   time.Time     `json:"started"`
+	Finished  *time.Time    `json:"finished,omitempty"`
+	Ranges    int           `json:"ranges"`    // Number of key ranges to compact one after the other
+	Compacted int           `json:"compacted"` // Number of key ranges compacted so far
+	Progress  float64       `json:"progress"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// DatabaseVerification is the outcome of an integrity check of the chain
+// database.
+type DatabaseVerification struct {
+	Head    uint64   `json:"head"`    // Number of the head header the check started from
+	Checked uint64   `json:"checked"` // Number of recent blocks checked
+	Pruned  uint64   `json:"pruned"`  // Number of checked blocks whose body was pruned
+	Issues  []string `json:"issues"`  // Inconsistencies found, empty if none
+}
+
+// StatePruning is the outcome of a pruning of the historical state.
+type StatePruning struct {
+	Head    uint64        `json:"head"`    // Number of the head block when pruning started
+	Blocks  uint64        `json:"blocks"`  // Number of recent blocks whose state was retained
+	Deleted uint64        `json:"deleted"` // Number of trie nodes and contract codes deleted
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// dbMaintainer compacts and verifies the chain database while the node is
+// running, throttling itself to leave enough IO to block processing.
+type dbMaintainer struct {
+	db     ethdb.Database
+	budget int // Percentage of time maintenance may spend on IO
+
+	compaction *DatabaseCompaction // Latest compaction, running or finished
+	lock       sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDBMaintainer creates a maintainer of the given database, spending at most
+// the given percentage of time on IO.
+func newDBMaintainer(db ethdb.Database, budget int) *dbMaintainer {
+	if budget <= 0 || budget > 100 {
+		log.Warn("Sanitizing invalid database IO budget", "provided", budget, "updated", DefaultConfig.DatabaseIOBudget)
+		budget = DefaultConfig.DatabaseIOBudget
+	}
+	return &dbMaintainer{
+		db:     db,
+		budget: budget,
+		quit:   make(chan struct{}),
+	}
+}
+
+// stop aborts any running maintenance and waits for it to terminate. It must be
+// called before the database is closed.
+func (m *dbMaintainer) stop() {
+	m.lock.Lock()
+	select {
+	case <-m.quit:
+	default:
+		close(m.quit)
+	}
+	m.lock.Unlock()
+
+	m.wg.Wait()
+}
+
+// compact launches the compaction of the keys between the given prefixes, both
+// included.
+func (m *dbMaintainer) compact(startPrefix, endPrefix []byte) (*DatabaseCompaction, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	select {
+	case <-m.quit:
+		return nil, errMaintainerDown
+	default:
+	}
+	if m.compaction != nil && m.compaction.Finished == nil {
+		return nil, errCompactionRunning
+	}
+	var limit []byte
+	if len(endPrefix) > 0 {
+		if limit = prefixEnd(endPrefix); limit != nil && bytes.Compare(startPrefix, limit) >= 0 {
+			return nil, errInvalidKeyRange
+		}
+	}
+	ranges := compactionRanges(startPrefix, limit)
+
+	m.compaction = &DatabaseCompaction{
+		Start:   common.CopyBytes(startPrefix),
+		Limit:   limit,
+		Started: time.Now(),
+		Ranges:  len(ranges),
+	}
+	job := *m.compaction
+
+	m.wg.Add(1)
+	go m.runCompaction(m.compaction, ranges)
+	return &job, nil
+}
+
+// runCompaction compacts the given key ranges one after the other, tracking the
+// progress in the given compaction.
+func (m *dbMaintainer) runCompaction(job *DatabaseCompaction, ranges []keyRange) {
+	defer m.wg.Done()
+
+	log.Info("Compacting database", "start", job.Start, "limit", job.Limit, "ranges", len(ranges))
+	throttle := newIOThrottle(m.budget)
+
+	var err error
+	for i, r := range ranges {
+		if err = m.db.Compact(r.start, r.limit); err != nil {
+			break
+		}
+		m.lock.Lock()
+		job.Compacted, job.Progress = i+1, float64(i+1)/float64(len(ranges))
+		m.lock.Unlock()
+
+		if !throttle.pause(nil, m.quit) {
+			err = errMaintainerDown
+			break
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		log.Warn("Database compaction failed", "err", err)
+		job.Error = err.Error()
+		return
+	}
+	log.Info("Compacted database", "start", job.Start, "limit", job.Limit, "elapsed", common.PrettyDuration(finished.Sub(job.Started)))
+}
+
+// status returns a copy of the state of the latest compaction, or nil if none
+// was started.
+func (m *dbMaintainer) status() *DatabaseCompaction {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.compaction == nil {
+		return nil
+	}
+	job := *m.compaction
+	return &job
+}
+
+// verify checks the consistency of the head pointers and of the given number of
+// most recent blocks of the canonical chain.
+func (m *dbMaintainer) verify(ctx context.Context, depth uint64) (*DatabaseVerification, error) {
+	m.lock.Lock()
+	select {
+	case <-m.quit:
+		m.lock.Unlock()
+		return nil, errMaintainerDown
+	default:
+	}
+	m.wg.Add(1)
+	m.lock.Unlock()
+	defer m.wg.Done()
+
+	result := &DatabaseVerification{Issues: []string{}}
+	report := func(format string, args ...interface{}) {
+		result.Issues = append(result.Issues, fmt.Sprintf(format, args...))
+	}
+	// Ensure the head pointers reference known canonical blocks, in sane order
+	headHeader := m.verifyHead("header", rawdb.ReadHeadHeaderHash(m.db), report)
+	headFast := m.verifyHead("fast block", rawdb.ReadHeadFastBlockHash(m.db), report)
+	headBlock := m.verifyHead("block", rawdb.ReadHeadBlockHash(m.db), report)
+
+	if headHeader == nil {
+		return result, nil
+	}
+	if headFast != nil && *headFast > *headHeader {
+		report("head fast block #%d ahead of head header #%d", *headFast, *headHeader)
+	}
+	if headBlock != nil && *headBlock > *headHeader {
+		report("head block #%d ahead of head header #%d", *headBlock, *headHeader)
+	}
+	// Walk the recent canonical chain backwards, ensuring it's continuous and that
+	// blocks have their bodies and receipts available up to the fast block head
+	result.Head = *headHeader
+	if depth > *headHeader+1 {
+		depth = *headHeader + 1
+	}
+	var (
+		throttle = newIOThrottle(m.budget)
+		tail     = rawdb.ReadBodyPruneTail(m.db)
+	)
+	for number := *headHeader; result.Checked < depth; number-- {
+		result.Checked++
+
+		full := headFast != nil && number <= *headFast
+		if full && number < tail {
+			result.Pruned++
+		}
+		m.verifyBlock(number, full, number < tail, report)
+
+		if !throttle.pause(ctx.Done(), m.quit) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errMaintainerDown
+		}
+	}
+	return result, nil
+}
+
+// verifyHead checks that a head pointer references a known canonical block,
+// returning its number if so.
+func (m *dbMaintainer) verifyHead(kind string, hash common.Hash, report func(string, ...interface{})) *uint64 {
+	if hash == (common.Hash{}) {
+		report("missing head %s pointer", kind)
+		return nil
+	}
+	number := rawdb.ReadHeaderNumber(m.db, hash)
+	if number == nil {
+		report("unknown head %s %x", kind, hash)
+		return nil
+	}
+	if !rawdb.HasHeader(m.db, hash, *number) {
+		report("missing header of head %s #%d [%x]", kind, *number, hash)
+	}
+	if canon := rawdb.ReadCanonicalHash(m.db, *number); canon != hash {
+		report("head %s #%d [%x] not canonical, have %x", kind, *number, hash, canon)
+	}
+	return number
+}
+
+// verifyBlock checks the data stored for a canonical block: its mappings, its
+// link to the parent, and, if expected, its body and receipts. The body is not
+// checked if it was discarded by body pruning.
+func (m *dbMaintainer) verifyBlock(number uint64, full bool, pruned bool, report func(string, ...interface{})) {
+	hash := rawdb.ReadCanonicalHash(m.db, number)
+	if hash == (common.Hash{}) {
+		report("missing canonical hash #%d", number)
+		return
+	}
+	if mapped := rawdb.ReadHeaderNumber(m.db, hash); mapped == nil || *mapped != number {
+		report("missing hash->number mapping of #%d [%x]", number, hash)
+	}
+	header := rawdb.ReadHeader(m.db, hash, number)
+	if header == nil {
+		report("missing header #%d [%x]", number, hash)
+		return
+	}
+	if number > 0 {
+		if parent := rawdb.ReadCanonicalHash(m.db, number-1); header.ParentHash != parent {
+			report("canonical chain broken at #%d [%x]: parent %x, canonical %x", number, hash, header.ParentHash, parent)
+		}
+	}
+	if !full {
+		return
+	}
+	if !pruned && !rawdb.HasBody(m.db, hash, number) {
+		report("missing body #%d [%x]", number, hash)
+	}
+	// Blocks without transactions may have no receipts stored
+	if header.ReceiptHash != types.EmptyRootHash && !rawdb.HasReceipts(m.db, hash, number) {
+		report("missing receipts #%d [%x]", number, hash)
+	}
+}
+
+// keyRange is a range of keys of the database, start included and limit excluded.
+type keyRange struct {
+	start []byte
+	limit []byte // Nil for the end of the keyspace
+}
+
+// compactionRanges splits the keys between start and limit into ranges along the
+// first byte of the keys, or along the byte following start if all of them share
+// it as a prefix, so that they can be compacted one after the other.
+func compactionRanges(start, limit []byte) []keyRange {
+	var prefix []byte
+	if bytes.Equal(limit, prefixEnd(start)) {
+		prefix = start
+	}
+	bounds := [][]byte{start}
+	for i := 0; i < 256; i++ {
+		key := append(common.CopyBytes(prefix), byte(i))
+		if bytes.Compare(key, start) > 0 && (limit == nil || bytes.Compare(key, limit) < 0) {
+			bounds = append(bounds, key)
+		}
+	}
+	bounds = append(bounds, limit)
+
+	ranges := make([]keyRange, len(bounds)-1)
+	for i := range ranges {
+		ranges[i] = keyRange{start: bounds[i], limit: bounds[i+1]}
+	}
+	return ranges
+}
+
+// prefixEnd returns the smallest key greater than all keys with the given prefix,
+// or nil if there's none.
+func prefixEnd(prefix []byte) []byte {
+	end := common.CopyBytes(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// ioThrottle paces a database maintenance task, pausing it regularly so that it
+// spends at most a percentage of time doing IO.
+type ioThrottle struct {
+	budget int           // Percentage of time the task may spend working
+	busy   time.Duration // Time spent working since the last pause
+	last   time.Time     // Time the task resumed working
+}
+
+// newIOThrottle creates a throttle of a task starting now.
+func newIOThrottle(budget int) *ioThrottle {
+	return &ioThrottle{budget: budget, last: time.Now()}
+}
+
+// pause accounts for the time spent working since the last call and sleeps if
+// the task exhausted its budget. False is returned if either of the given
+// channels is closed in the meantime.
+func (t *ioThrottle) pause(cancel, quit <-chan struct{}) bool {
+	now := time.Now()
+	t.busy, t.last = t.busy+now.Sub(t.last), now
+
+	if t.budget < 100 && t.busy >= throttleSlice {
+		timer := time.NewTimer(t.busy * time.Duration(100-t.budget) / time.Duration(t.budget))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-cancel:
+			return false
+		case <-quit:
+			return false
+		}
+		t.busy, t.last = 0, time.Now()
+		return true
+	}
+	select {
+	case <-cancel:
+		return false
+	case <-quit:
+		return false
+	default:
+		return true
+	}
+}
+
+// CompactDatabase starts compacting the keys of the chain database between the
+// given prefixes, both included, in the background. Empty prefixes extend the
+// range to the start or the end of the keyspace. The progress can be retrieved
+// with CompactionStatus, and only one compaction may run at a time.
+func (api *PrivateDebugAPI) CompactDatabase(startPrefix, endPrefix hexutil.Bytes) (*DatabaseCompaction, error) {
+	return api.eth.dbMaint.compact(startPrefix, endPrefix)
+}
+
+// CompactionStatus returns the progress of the latest database compaction, or
+// nil if none was started.
+func (api *PrivateDebugAPI) CompactionStatus() *DatabaseCompaction {
+	return api.eth.dbMaint.status()
+}
+
+// VerifyDatabase checks the integrity of the chain database: the head pointers
+// must reference canonical blocks, and the given number of most recent blocks
+// must form a continuous canonical chain with their headers, bodies and receipts
+// available. The inconsistencies found are reported in the result.
+func (api *PrivateDebugAPI) VerifyDatabase(ctx context.Context, depth uint64) (*DatabaseVerification, error) {
+	return api.eth.dbMaint.verify(ctx, depth)
+}
+
+// PruneState deletes all trie nodes and contract codes which aren't reachable from
+// the states of the given number of most recent blocks or of the genesis block.
+// Block processing is suspended until the pruning finishes. If the pruning is
+// interrupted, the next call resumes it.
+//
+// Pruning is refused while the node is syncing or mining, as both would need the
+// historical state to be available.
+func (api *PrivateDebugAPI) PruneState(blocks uint64) (*StatePruning, error) {
+	if !atomic.CompareAndSwapInt32(&api.eth.pruning, 0, 1) {
+		return nil, errPruningRunning
+	}
+	defer atomic.StoreInt32(&api.eth.pruning, 0)
+
+	if api.eth.Downloader().Synchronising() {
+		return nil, errPruneWhileSyncing
+	}
+	if api.eth.IsMining() {
+		return nil, errPruneWhileMining
+	}
+	var (
+		start = time.Now()
+		head  = api.eth.blockchain.CurrentBlock().NumberU64()
+	)
+	deleted, err := api.eth.blockchain.PruneState(api.eth.pruner, blocks)
+	if err != nil {
+		return nil, err
+	}
+	return &StatePruning{
+		Head:    head,
+		Blocks:  blocks,
+		Deleted: deleted,
+		Elapsed: time.Since(start),
+	}, nil
+}
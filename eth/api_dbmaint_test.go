@@ -0,0 +1,230 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newMaintainedChain creates a chain of the given length in the database,
+// along with an API to maintain it. The maintainer must be stopped by the caller.
+func newMaintainedChain(t *testing.T, db ethdb.Database, blocks int) (*PrivateDebugAPI, []*types.Block) {
+	var (
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, blocks, func(i int, block *core.BlockGen) {
+		if i%2 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testBankKey)
+			block.AddTx(tx)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	return NewPrivateDebugAPI(&Ethereum{dbMaint: newDBMaintainer(db, 50)}), chain
+}
+
+// Tests that database compactions run in the background to completion, one at
+// a time.
+func TestCompactDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := rawdb.NewLevelDBDatabase(dir, 16, 16, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	api, _ := newMaintainedChain(t, db, 16)
+	defer api.eth.dbMaint.stop()
+
+	if status := api.CompactionStatus(); status != nil {
+		t.Fatalf("compaction status before start: have %+v, want nil", status)
+	}
+	if _, err := api.CompactDatabase(hexutil.Bytes{0x62}, hexutil.Bytes{0x61}); err != errInvalidKeyRange {
+		t.Fatalf("reversed range error mismatch: have %v, want %v", err, errInvalidKeyRange)
+	}
+	job, err := api.CompactDatabase(nil, nil)
+	if err != nil {
+		t.Fatalf("failed to start compaction: %v", err)
+	}
+	if job.Ranges != 257 {
+		t.Errorf("compaction range count mismatch: have %d, want %d", job.Ranges, 257)
+	}
+	if _, err := api.CompactDatabase(nil, nil); err != errCompactionRunning {
+		t.Errorf("concurrent compaction error mismatch: have %v, want %v", err, errCompactionRunning)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		if job = api.CompactionStatus(); job.Finished != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("compaction didn't finish: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Error != "" || job.Compacted != job.Ranges || job.Progress != 1 {
+		t.Fatalf("compaction result mismatch: %+v", job)
+	}
+	// Once finished, a new compaction may be started
+	if job, err = api.CompactDatabase(hexutil.Bytes{0x62}, hexutil.Bytes{0x62}); err != nil {
+		t.Fatalf("failed to restart compaction: %v", err)
+	}
+	if job.Ranges != 257 || !bytes.Equal(job.Limit, []byte{0x63}) {
+		t.Errorf("prefix compaction mismatch: have %d ranges up to %x, want 257 up to 63", job.Ranges, job.Limit)
+	}
+}
+
+// Tests the splitting of key ranges to compact.
+func TestCompactionRanges(t *testing.T) {
+	tests := []struct {
+		start, limit []byte
+		ranges       int
+		first, last  keyRange
+	}{
+		{nil, nil, 257, keyRange{nil, []byte{0x00}}, keyRange{[]byte{0xff}, nil}},
+		{[]byte{0x62}, []byte{0x63}, 257, keyRange{[]byte{0x62}, []byte{0x62, 0x00}}, keyRange{[]byte{0x62, 0xff}, []byte{0x63}}},
+		{[]byte{0x61, 0x10}, []byte{0x64}, 3, keyRange{[]byte{0x61, 0x10}, []byte{0x62}}, keyRange{[]byte{0x63}, []byte{0x64}}},
+		{[]byte{0xff}, nil, 257, keyRange{[]byte{0xff}, []byte{0xff, 0x00}}, keyRange{[]byte{0xff, 0xff}, nil}},
+	}
+	for i, tt := range tests {
+		ranges := compactionRanges(tt.start, tt.limit)
+		if len(ranges) != tt.ranges {
+			t.Errorf("test %d: range count mismatch: have %d, want %d", i, len(ranges), tt.ranges)
+			continue
+		}
+		first, last := ranges[0], ranges[len(ranges)-1]
+		if !bytes.Equal(first.start, tt.first.start) || !bytes.Equal(first.limit, tt.first.limit) {
+			t.Errorf("test %d: first range mismatch: have %x-%x, want %x-%x", i, first.start, first.limit, tt.first.start, tt.first.limit)
+		}
+		if !bytes.Equal(last.start, tt.last.start) || !bytes.Equal(last.limit, tt.last.limit) {
+			t.Errorf("test %d: last range mismatch: have %x-%x, want %x-%x", i, last.start, last.limit, tt.last.start, tt.last.limit)
+		}
+	}
+}
+
+// Tests that database verification passes on a consistent chain, and reports
+// missing data.
+func TestVerifyDatabase(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	api, chain := newMaintainedChain(t, db, 32)
+	defer api.eth.dbMaint.stop()
+
+	result, err := api.VerifyDatabase(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	if result.Head != 32 || result.Checked != 33 || len(result.Issues) != 0 {
+		t.Fatalf("verification result mismatch: have %+v, want 33 blocks from #32 without issues", result)
+	}
+	// Delete the body and receipts of a recent block, they should be found missing
+	block := chain[28]
+	rawdb.DeleteBody(db, block.Hash(), block.NumberU64())
+	rawdb.DeleteReceipts(db, block.Hash(), block.NumberU64())
+
+	if result, err = api.VerifyDatabase(context.Background(), 8); err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	want := []string{
+		fmt.Sprintf("missing body #29 [%x]", block.Hash()),
+		fmt.Sprintf("missing receipts #29 [%x]", block.Hash()),
+	}
+	if result.Checked != 8 || strings.Join(result.Issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("verification issues mismatch: have %q, want %q", result.Issues, want)
+	}
+	// Blocks deeper than requested shouldn't be checked
+	if result, err = api.VerifyDatabase(context.Background(), 2); err != nil || len(result.Issues) != 0 {
+		t.Errorf("shallow verification mismatch: have %v, %v; want no issues", result, err)
+	}
+	// Break the canonical chain and the head pointers
+	rawdb.DeleteCanonicalHash(db, 20)
+	rawdb.WriteHeadBlockHash(db, common.Hash{0x01})
+
+	if result, err = api.VerifyDatabase(context.Background(), 20); err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	want = []string{
+		fmt.Sprintf("unknown head block %x", common.Hash{0x01}),
+		fmt.Sprintf("missing body #29 [%x]", block.Hash()),
+		fmt.Sprintf("missing receipts #29 [%x]", block.Hash()),
+		fmt.Sprintf("canonical chain broken at #21 [%x]: parent %x, canonical %x", chain[20].Hash(), chain[19].Hash(), common.Hash{}),
+		"missing canonical hash #20",
+	}
+	if strings.Join(result.Issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("verification issues mismatch: have %q, want %q", result.Issues, want)
+	}
+}
+
+// Tests that database verification doesn't report the bodies discarded by body
+// pruning as missing, but counts them separately.
+func TestVerifyPrunedDatabase(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	api, chain := newMaintainedChain(t, db, 32)
+	defer api.eth.dbMaint.stop()
+
+	// Prune the bodies of the first few blocks, but leave their receipts intact
+	for _, block := range chain[:10] {
+		rawdb.DeleteBody(db, block.Hash(), block.NumberU64())
+	}
+	rawdb.WriteBodyPruneTail(db, 11)
+
+	result, err := api.VerifyDatabase(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	if result.Checked != 33 || result.Pruned != 11 || len(result.Issues) != 0 {
+		t.Fatalf("verification result mismatch: have %+v, want 33 blocks with 11 pruned and no issues", result)
+	}
+	// Bodies missing above the prune tail must still be reported
+	block := chain[10]
+	rawdb.DeleteBody(db, block.Hash(), block.NumberU64())
+
+	if result, err = api.VerifyDatabase(context.Background(), 1000); err != nil {
+		t.Fatalf("failed to verify database: %v", err)
+	}
+	want := []string{fmt.Sprintf("missing body #11 [%x]", block.Hash())}
+	if strings.Join(result.Issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("verification issues mismatch: have %q, want %q", result.Issues, want)
+	}
+}
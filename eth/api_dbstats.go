@@ -0,0 +1,217 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errUnknownStatsJob    = errors.New("unknown database inspection job")
+	errStatsInspectorDown = errors.New("database inspector stopped")
+)
+
+// DatabaseStatsJob is the state of a database inspection running in the
+// background.
+type DatabaseStatsJob struct {
+	ID       uint64               `json:"id"`
+	Started  time.Time            `json:"started"`
+	Finished *time.Time           `json:"finished,omitempty"`
+	Entries  uint64               `json:"entries"`  // Number of key-value entries inspected so far
+	Progress float64              `json:"progress"` // Estimated fraction of the keyspace inspected
+	Error    string               `json:"error,omitempty"`
+	Stats    *rawdb.DatabaseStats `json:"stats,omitempty"`
+
+	done chan struct{} // Closed when the inspection finishes
+}
+
+// dbStatsInspector runs database inspections in the background, one at a time,
+// and caches the result of the last successful one.
+type dbStatsInspector struct {
+	db ethdb.Database
+
+	job    *DatabaseStatsJob    // Latest inspection, running or finished
+	nextID uint64               // Identifier of the next inspection
+	cached *rawdb.DatabaseStats // Result of the last successful inspection
+	lock   sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDBStatsInspector creates an inspector of the given database.
+func newDBStatsInspector(db ethdb.Database) *dbStatsInspector {
+	return &dbStatsInspector{
+		db:     db,
+		nextID: 1,
+		quit:   make(chan struct{}),
+	}
+}
+
+// stop aborts any running inspection and waits for it to terminate. It must be
+// called before the database is closed.
+func (ins *dbStatsInspector) stop() {
+	ins.lock.Lock()
+	select {
+	case <-ins.quit:
+	default:
+		close(ins.quit)
+	}
+	ins.lock.Unlock()
+
+	ins.wg.Wait()
+}
+
+// start launches a new inspection, or returns the running one if any.
+func (ins *dbStatsInspector) start() (*DatabaseStatsJob, error) {
+	ins.lock.Lock()
+	defer ins.lock.Unlock()
+
+	select {
+	case <-ins.quit:
+		return nil, errStatsInspectorDown
+	default:
+	}
+	if ins.job != nil && ins.job.Finished == nil {
+		return ins.job, nil
+	}
+	job := &DatabaseStatsJob{
+		ID:      ins.nextID,
+		Started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	ins.nextID++
+	ins.job = job
+
+	ins.wg.Add(1)
+	go ins.run(job)
+	return job, nil
+}
+
+// run inspects the database, tracking the progress in the given job.
+func (ins *dbStatsInspector) run(job *DatabaseStatsJob) {
+	defer ins.wg.Done()
+	defer close(job.done)
+
+	log.Info("Inspecting database", "job", job.ID)
+	stats, err := rawdb.CollectDatabaseStats(ins.db, func(count uint64, key []byte) bool {
+		ins.lock.Lock()
+		job.Entries, job.Progress = count, keyspaceProgress(key)
+		ins.lock.Unlock()
+
+		select {
+		case <-ins.quit:
+			return false
+		default:
+			return true
+		}
+	})
+	ins.lock.Lock()
+	defer ins.lock.Unlock()
+
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		log.Warn("Database inspection failed", "job", job.ID, "err", err)
+		job.Error = err.Error()
+		return
+	}
+	log.Info("Inspected database", "job", job.ID, "entries", stats.Count, "size", stats.Total, "elapsed", finished.Sub(job.Started))
+	job.Entries, job.Progress, job.Stats = stats.Count, 1, stats
+	ins.cached = stats
+}
+
+// status returns a copy of the state of the requested inspection. Only the
+// latest inspection is retained.
+func (ins *dbStatsInspector) status(id uint64) (*DatabaseStatsJob, error) {
+	ins.lock.Lock()
+	defer ins.lock.Unlock()
+
+	if ins.job == nil || ins.job.ID != id {
+		return nil, errUnknownStatsJob
+	}
+	job := *ins.job
+	return &job, nil
+}
+
+// keyspaceProgress estimates the fraction of the keyspace preceding the key
+// from its first two bytes. Most of the data are trie nodes keyed by uniformly
+// distributed hashes, so it's a reasonable approximation.
+func keyspaceProgress(key []byte) float64 {
+	var prefix [2]byte
+	copy(prefix[:], key)
+	return float64(binary.BigEndian.Uint16(prefix[:])) / 65536
+}
+
+// DbStats returns the size breakdown of the chain database by category of data.
+// The result of the last inspection is returned if available, unless a refresh
+// is requested. Inspecting the entire database takes a while, see DbStatsAsync
+// for running it in the background.
+func (api *PrivateDebugAPI) DbStats(ctx context.Context, refresh *bool) (*rawdb.DatabaseStats, error) {
+	ins := api.eth.dbStats
+	if refresh == nil || !*refresh {
+		ins.lock.Lock()
+		cached := ins.cached
+		ins.lock.Unlock()
+
+		if cached != nil {
+			return cached, nil
+		}
+	}
+	job, err := ins.start()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	ins.lock.Lock()
+	defer ins.lock.Unlock()
+
+	if job.Error != "" {
+		return nil, errors.New(job.Error)
+	}
+	return job.Stats, nil
+}
+
+// DbStatsAsync starts inspecting the chain database in the background and
+// returns the identifier of the inspection, whose progress and result can be
+// retrieved with DbStatsProgress. If an inspection is already running, its
+// identifier is returned instead of starting a new one.
+func (api *PrivateDebugAPI) DbStatsAsync() (uint64, error) {
+	job, err := api.eth.dbStats.start()
+	if err != nil {
+		return 0, err
+	}
+	return job.ID, nil
+}
+
+// DbStatsProgress returns the progress of a background database inspection, and
+// its result once finished. Only the latest inspection can be queried.
+func (api *PrivateDebugAPI) DbStatsProgress(id uint64) (*DatabaseStatsJob, error) {
+	return api.eth.dbStats.status(id)
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the entries of a generated chain are attributed to the correct
+// categories, and that unknown entries are reported separately.
+func TestDbStats(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 8, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	db.Put([]byte("zzz"), []byte("junk"))
+
+	api := NewPrivateDebugAPI(&Ethereum{dbStats: newDBStatsInspector(db)})
+	stats, err := api.DbStats(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to inspect database: %v", err)
+	}
+	counts := make(map[string]uint64)
+	for _, category := range stats.Categories {
+		counts[category.Database+"/"+category.Category] = category.Count
+	}
+	want := map[string]uint64{
+		"Key-Value store/Headers":            9,
+		"Key-Value store/Bodies":             9,
+		"Key-Value store/Receipts":           9,
+		"Key-Value store/Difficulties":       9,
+		"Key-Value store/Block number->hash": 9,
+		"Key-Value store/Block hash->number": 9,
+		"Key-Value store/Transaction index":  8,
+		"Key-Value store/Chain configs":      1,
+		"Key-Value store/Bloombit index":     0,
+		"Ancient store/Headers":              0,
+	}
+	for category, count := range want {
+		if counts[category] != count {
+			t.Errorf("%s: count mismatch: have %d, want %d", category, counts[category], count)
+		}
+	}
+	if counts["Key-Value store/Trie nodes"] == 0 {
+		t.Error("no trie nodes found")
+	}
+	if counts["Key-Value store/Singleton metadata"] == 0 {
+		t.Error("no metadata found")
+	}
+	if len(stats.Unaccounted) != 1 || stats.Unaccounted[0].Category != "Prefix 0x7a" || stats.Unaccounted[0].Count != 1 || stats.Unaccounted[0].Size != 7 {
+		t.Errorf("unaccounted data mismatch: have %+v, want 7 bytes with prefix 0x7a", stats.Unaccounted)
+	}
+	var total uint64
+	for _, category := range append(stats.Categories, stats.Unaccounted...) {
+		total += category.Count
+	}
+	if total != stats.Count {
+		t.Errorf("categorized entries mismatch: have %d, want %d", total, stats.Count)
+	}
+}
+
+// Tests that inspections can run in the background, and that their results are
+// cached until a refresh is requested.
+func TestDbStatsAsync(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	db.Put([]byte("zzz"), []byte("junk"))
+
+	ins := newDBStatsInspector(db)
+	api := NewPrivateDebugAPI(&Ethereum{dbStats: ins})
+
+	id, err := api.DbStatsAsync()
+	if err != nil {
+		t.Fatalf("failed to start inspection: %v", err)
+	}
+	var job *DatabaseStatsJob
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if job, err = api.DbStatsProgress(id); err != nil {
+			t.Fatalf("failed to retrieve progress: %v", err)
+		}
+		if job.Finished != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("inspection didn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Error != "" || job.Progress != 1 || job.Stats == nil || job.Stats.Count != 1 {
+		t.Fatalf("inspection result mismatch: %+v", job)
+	}
+	if _, err := api.DbStatsProgress(id + 1); err != errUnknownStatsJob {
+		t.Errorf("unknown job error mismatch: have %v, want %v", err, errUnknownStatsJob)
+	}
+	// The result is cached until a refresh is requested
+	db.Put([]byte("yyy"), []byte("junk"))
+
+	if stats, err := api.DbStats(context.Background(), nil); err != nil || stats.Count != 1 {
+		t.Errorf("cached result mismatch: have %v, %v; want 1 entry", stats, err)
+	}
+	refresh := true
+	if stats, err := api.DbStats(context.Background(), &refresh); err != nil || stats.Count != 2 {
+		t.Errorf("refreshed result mismatch: have %v, %v; want 2 entries", stats, err)
+	}
+	// No new inspections are started once stopped
+	ins.stop()
+	if _, err := api.DbStatsAsync(); err != errStatsInspectorDown {
+		t.Errorf("inspection after stop error mismatch: have %v, want %v", err, errStatsInspectorDown)
+	}
+}
+
+func TestKeyspaceProgress(t *testing.T) {
+	tests := []struct {
+		key  []byte
+		want float64
+	}{
+		{nil, 0},
+		{[]byte{0x00, 0x00, 0xff}, 0},
+		{[]byte{0x80}, 0.5},
+		{[]byte{0xc0, 0x00}, 0.75},
+	}
+	for _, tt := range tests {
+		if have := keyspaceProgress(tt.key); have != tt.want {
+			t.Errorf("progress of %x mismatch: have %v, want %v", tt.key, have, tt.want)
+		}
+	}
+}
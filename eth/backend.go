@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/downloader"
@@ -43,6 +44,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/internal/shutdowncheck"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
@@ -76,7 +78,13 @@ type Ethereum struct {
 	lesServer       LesServer
 
 	// DB interfaces
-	chainDb ethdb.Database // Block chain database
+	chainDb ethdb.Database    // Block chain database
+	dbStats *dbStatsInspector // Background inspector of the chain database
+	dbMaint *dbMaintainer     // Background compactor and verifier of the chain database
+	pruner  *pruner.Pruner    // Offline pruner of the historical state
+	pruning int32             // Flag whether the state is being pruned (atomic)
+
+	shutdownTracker *shutdowncheck.ShutdownTracker // Tracks if and when the node has shutdown ungracefully
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -144,6 +152,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	eth := &Ethereum{
 		config:         config,
 		chainDb:        chainDb,
+		dbStats:        newDBStatsInspector(chainDb),
+		dbMaint:        newDBMaintainer(chainDb, config.DatabaseIOBudget),
+		pruner:         pruner.NewPruner(chainDb, ctx.ResolvePath(""), config.StateBloomSize),
 		eventMux:       ctx.EventMux,
 		accountManager: ctx.AccountManager,
 		engine:         CreateConsensusEngine(ctx, chainConfig, &config.Ethash, config.Miner.Notify, config.Miner.Noverify, chainDb),
@@ -170,6 +181,10 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 			rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 		}
 	}
+	// Report any previous unclean shutdowns and flag the session as running
+	eth.shutdownTracker = shutdowncheck.NewShutdownTracker(chainDb)
+	eth.shutdownTracker.MarkStartup()
+
 	var (
 		vmConfig = vm.Config{
 			EnablePreimageRecording: config.EnablePreimageRecording,
@@ -181,7 +196,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 			TrieCleanNoPrefetch: config.NoPrefetch,
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
+			TrieDirtyCap:        config.TrieDirtyCap,
 			TrieTimeLimit:       config.TrieTimeout,
+			TxHistory:           config.TxHistory,
 		}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve)
@@ -210,6 +227,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(chainConfig, checkpoint, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb, cacheLimit, config.Whitelist, eth.IsMining); err != nil {
 		return nil, err
 	}
+	// Don't hand out work to remote miners while catching up with the network
+	if engine, ok := eth.engine.(*ethash.Ethash); ok {
+		downloader := eth.protocolManager.downloader
+		engine.SetSyncStatus(func() bool {
+			if !downloader.Synchronising() {
+				return false
+			}
+			progress := downloader.Progress()
+			return progress.CurrentBlock < progress.HighestBlock
+		})
+	}
 	eth.miner = miner.New(eth, &config.Miner, chainConfig, eth.EventMux(), eth.engine, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
@@ -265,6 +293,13 @@ func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainCo
 			DatasetDir:     config.DatasetDir,
 			DatasetsInMem:  config.DatasetsInMem,
 			DatasetsOnDisk: config.DatasetsOnDisk,
+
+			RequireSyncedForWork:         config.RequireSyncedForWork,
+			GenerationIOLimitBytesPerSec: config.GenerationIOLimitBytesPerSec,
+			WorkFetchTimeout:             config.WorkFetchTimeout,
+			SlowVerifications:            config.SlowVerifications,
+			NearMissDifficulty:           config.NearMissDifficulty,
+			OnNearMiss:                   config.OnNearMiss,
 		}, notify, noverify)
 		engine.SetThreads(-1) // Disable CPU mining
 		return engine
@@ -558,7 +593,15 @@ func (s *Ethereum) Stop() error {
 	s.miner.Stop()
 	s.eventMux.Stop()
 
+	s.dbStats.stop()
+	s.dbMaint.stop()
+
+	// Clean shutdown marker as the last thing before closing db
+	s.shutdownTracker.Stop()
+
+	shutdowncheck.Progress("closing chain database", 0, 1)
 	s.chainDb.Close()
+	shutdowncheck.Done("closing chain database")
 	close(s.shutdownChan)
 	return nil
 }
@@ -25,10 +25,12 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/internal/validate"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -42,11 +44,16 @@ var DefaultConfig = Config{
 		CachesOnDisk:   3,
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
+
+		RequireSyncedForWork: true,
+		WorkFetchTimeout:     ethash.DefaultWorkFetchTimeout,
 	},
 	NetworkId:          1,
 	LightPeers:         100,
 	UltraLightFraction: 75,
 	DatabaseCache:      512,
+	DatabaseIOBudget:   25,
+	StateBloomSize:     256,
 	TrieCleanCache:     256,
 	TrieDirtyCache:     256,
 	TrieTimeout:        60 * time.Minute,
@@ -92,35 +99,43 @@ type Config struct {
 	Genesis *core.Genesis `toml:",omitempty"`
 
 	// Protocol options
-	NetworkId uint64 // Network ID to use for selecting peers to connect to
-	SyncMode  downloader.SyncMode
+	NetworkId uint64              `validate:"min=1"` // Network ID to use for selecting peers to connect to
+	SyncMode  downloader.SyncMode `validate:"oneof=fast full light"`
 
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
+	TxHistory uint64 `toml:",omitempty"` // Number of recent blocks to retain transaction bodies for (0 = retain all)
+
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
 	// Light client options
-	LightServ    int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
-	LightIngress int `toml:",omitempty"` // Incoming bandwidth limit for light servers
-	LightEgress  int `toml:",omitempty"` // Outgoing bandwidth limit for light servers
-	LightPeers   int `toml:",omitempty"` // Maximum number of LES client peers
+	LightServ    int `toml:",omitempty" validate:"min=0"` // Maximum percentage of time allowed for serving LES requests
+	LightIngress int `toml:",omitempty" validate:"min=0"` // Incoming bandwidth limit for light servers
+	LightEgress  int `toml:",omitempty" validate:"min=0"` // Outgoing bandwidth limit for light servers
+	LightPeers   int `toml:",omitempty" validate:"min=0"` // Maximum number of LES client peers
+
+	// Token presented to light servers for prioritized service
+	LightPriorityToken hexutil.Bytes `toml:",omitempty"`
 
 	// Ultra Light client options
-	UltraLightServers      []string `toml:",omitempty"` // List of trusted ultra light servers
-	UltraLightFraction     int      `toml:",omitempty"` // Percentage of trusted servers to accept an announcement
-	UltraLightOnlyAnnounce bool     `toml:",omitempty"` // Whether to only announce headers, or also serve them
+	UltraLightServers      []string `toml:",omitempty"`                          // List of trusted ultra light servers
+	UltraLightFraction     int      `toml:",omitempty" validate:"min=0,max=100"` // Percentage of trusted servers to accept an announcement
+	UltraLightOnlyAnnounce bool     `toml:",omitempty"`                          // Whether to only announce headers, or also serve them
 
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
-	DatabaseCache      int
+	DatabaseCache      int  `validate:"min=0"`
 	DatabaseFreezer    string
+	DatabaseIOBudget   int    `validate:"min=1,max=100"` // Percentage of time background database maintenance may spend on IO
+	StateBloomSize     uint64 `validate:"min=1"`         // Size in megabytes of the bloom filter marking the state retained by pruning
 
-	TrieCleanCache int
-	TrieDirtyCache int
-	TrieTimeout    time.Duration
+	TrieCleanCache int           `validate:"min=0"`
+	TrieDirtyCache int           `validate:"min=0"`
+	TrieDirtyCap   int           `validate:"min=0"`
+	TrieTimeout    time.Duration `validate:"min=0"`
 
 	// Mining options
 	Miner miner.Config
@@ -161,3 +176,9 @@ type Config struct {
 	// MuirGlacier block override (TODO: remove after the fork)
 	OverrideMuirGlacier *big.Int
 }
+
+// Validate checks the configuration against the constraints declared in the
+// validate tags of its fields.
+func (c *Config) Validate() error {
+	return validate.Struct("Config", c)
+}
@@ -342,6 +342,16 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 	return err
 }
 
+// IsPeerFault reports whether an error returned by Synchronise was caused by the
+// peer delivering invalid data, as opposed to e.g. being slow or unavailable.
+func IsPeerFault(err error) bool {
+	switch err {
+	case errBadPeer, errEmptyHeaderSet, errInvalidAncestor, errInvalidChain:
+		return true
+	}
+	return false
+}
+
 // synchronise will select the peer and use it for synchronising. If an empty string is given
 // it will use the best peer possible and synchronize if its TD is higher than our own. If any of the
 // checks fail an error will be returned. This method is synchronous
@@ -61,7 +61,17 @@ var (
 )
 
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protocolError{code: code, msg: fmt.Sprintf(format, v...)}
+}
+
+// protocolError is a violation of the eth protocol by the remote peer.
+type protocolError struct {
+	code errCode
+	msg  string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
 }
 
 type isMiningFn func() bool
@@ -197,7 +207,10 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 	broadcastBlock := func (block *types.Block, propagate bool) {
 		manager.BroadcastBlock(block, propagate, false)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, broadcastBlock, heighter, inserter, manager.removePeer)
+	punisher := func(id string) {
+		manager.punishPeer(id, p2p.IncidentInvalidBlock)
+	}
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, broadcastBlock, heighter, inserter, punisher)
 
 	rand.Seed(time.Now().UnixNano())
 
@@ -256,6 +269,14 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// punishPeer records an incident for a misbehaving peer and drops it.
+func (pm *ProtocolManager) punishPeer(id string, incident p2p.Incident) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.ReportIncident(incident)
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -307,7 +328,7 @@ func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *p
 func (pm *ProtocolManager) handle(p *peer) error {
 	// Ignore maxPeers if this is a trusted peer
 	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
-		return p2p.DiscTooManyPeers
+		return p2p.NewHandshakeError(p2p.HandshakeTooManyPeers, p2p.DiscTooManyPeers)
 	}
 	p.Log().Debug("Ethereum peer connected", "name", p.Name())
 
@@ -370,6 +391,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Ethereum message handling failed", "err", err)
+			if _, ok := err.(*protocolError); ok {
+				p.ReportIncident(p2p.IncidentProtocolViolation)
+			}
 			return err
 		}
 	}
@@ -550,26 +574,22 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			maxBlockFetch = 1
 		}
 
-		// Gather blocks until the fetch or network limits is reached
+		// Gather the requested hashes up to the fetch limit
 		var (
 			hash   common.Hash
-			bytes  int
-			bodies []rlp.RawValue
+			hashes []common.Hash
 		)
-		for bytes < softResponseLimit && len(bodies) < maxBlockFetch {
+		for len(hashes) < maxBlockFetch {
 			// Retrieve the hash of the next block
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
 			} else if err != nil {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
-			// Retrieve the requested block body, stopping if enough was found
-			if data := pm.blockchain.GetBodyRLP(hash); len(data) != 0 {
-				bodies = append(bodies, data)
-				bytes += len(data)
-			}
+			hashes = append(hashes, hash)
 		}
-		return p.SendBlockBodiesRLP(bodies)
+		// Retrieve the requested block bodies, stopping if enough was found
+		return p.SendBlockBodiesRLP(pm.blockchain.GetBodiesRLP(hashes, softResponseLimit))
 
 	case msg.Code == BlockBodiesMsg:
 		// A batch of block bodies arrived to one of our previous requests
@@ -651,35 +671,22 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			maxReceiptFetch = 1
 		}
 
-		// Gather state data until the fetch or network limits is reached
+		// Gather the requested hashes up to the fetch limit
 		var (
-			hash     common.Hash
-			bytes    int
-			receipts []rlp.RawValue
+			hash   common.Hash
+			hashes []common.Hash
 		)
-		for bytes < softResponseLimit && len(receipts) < maxReceiptFetch {
+		for len(hashes) < maxReceiptFetch {
 			// Retrieve the hash of the next block
 			if err := msgStream.Decode(&hash); err == rlp.EOL {
 				break
 			} else if err != nil {
 				return errResp(ErrDecode, "msg %v: %v", msg, err)
 			}
-			// Retrieve the requested block's receipts, skipping if unknown to us
-			results := pm.blockchain.GetReceiptsByHash(hash)
-			if results == nil {
-				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
-					continue
-				}
-			}
-			// If known, encode and queue for response packet
-			if encoded, err := rlp.EncodeToBytes(results); err != nil {
-				log.Error("Failed to encode receipt", "err", err)
-			} else {
-				receipts = append(receipts, encoded)
-				bytes += len(encoded)
-			}
+			hashes = append(hashes, hash)
 		}
-		return p.SendReceiptsRLP(receipts)
+		// Retrieve the requested blocks' receipts, skipping if unknown to us
+		return p.SendReceiptsRLP(pm.blockchain.GetReceiptsRLP(hashes, softResponseLimit))
 
 	case p.version >= eth63 && msg.Code == ReceiptsMsg:
 		// A batch of receipts arrived to one of our previous requests
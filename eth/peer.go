@@ -434,13 +434,13 @@ func (p *peer) readStatusLegacy(network uint64, status *statusData63, genesis co
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
-		return errResp(ErrGenesisMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
+		return p2p.NewHandshakeError(p2p.HandshakeGenesisMismatch, errResp(ErrGenesisMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8]))
 	}
 	if status.NetworkId != network {
-		return errResp(ErrNetworkIDMismatch, "%d (!= %d)", status.NetworkId, network)
+		return p2p.NewHandshakeError(p2p.HandshakeIncompatible, errResp(ErrNetworkIDMismatch, "%d (!= %d)", status.NetworkId, network))
 	}
 	if int(status.ProtocolVersion) != p.version {
-		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+		return p2p.NewHandshakeError(p2p.HandshakeIncompatible, errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version))
 	}
 	return nil
 }
@@ -461,16 +461,16 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.NetworkID != network {
-		return errResp(ErrNetworkIDMismatch, "%d (!= %d)", status.NetworkID, network)
+		return p2p.NewHandshakeError(p2p.HandshakeIncompatible, errResp(ErrNetworkIDMismatch, "%d (!= %d)", status.NetworkID, network))
 	}
 	if int(status.ProtocolVersion) != p.version {
-		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+		return p2p.NewHandshakeError(p2p.HandshakeIncompatible, errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version))
 	}
 	if status.Genesis != genesis {
-		return errResp(ErrGenesisMismatch, "%x (!= %x)", status.Genesis, genesis)
+		return p2p.NewHandshakeError(p2p.HandshakeGenesisMismatch, errResp(ErrGenesisMismatch, "%x (!= %x)", status.Genesis, genesis))
 	}
 	if err := forkFilter(status.ForkID); err != nil {
-		return errResp(ErrForkIDRejected, "%v", err)
+		return p2p.NewHandshakeError(p2p.HandshakeForkIDRejected, errResp(ErrForkIDRejected, "%v", err))
 	}
 	return nil
 }
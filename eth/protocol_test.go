@@ -56,9 +56,10 @@ func TestStatusMsgErrors63(t *testing.T) {
 	defer pm.Stop()
 
 	tests := []struct {
-		code      uint64
-		data      interface{}
-		wantError error
+		code        uint64
+		data        interface{}
+		wantError   error
+		wantFailure p2p.HandshakeFailure
 	}{
 		{
 			code: TxMsg, data: []interface{}{},
@@ -66,15 +67,18 @@ func TestStatusMsgErrors63(t *testing.T) {
 		},
 		{
 			code: StatusMsg, data: statusData63{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash()},
-			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", 63),
+			wantError:   errResp(ErrProtocolVersionMismatch, "10 (!= %d)", 63),
+			wantFailure: p2p.HandshakeIncompatible,
 		},
 		{
 			code: StatusMsg, data: statusData63{63, 999, td, head.Hash(), genesis.Hash()},
-			wantError: errResp(ErrNetworkIDMismatch, "999 (!= %d)", DefaultConfig.NetworkId),
+			wantError:   errResp(ErrNetworkIDMismatch, "999 (!= %d)", DefaultConfig.NetworkId),
+			wantFailure: p2p.HandshakeIncompatible,
 		},
 		{
 			code: StatusMsg, data: statusData63{63, DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}},
-			wantError: errResp(ErrGenesisMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+			wantError:   errResp(ErrGenesisMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+			wantFailure: p2p.HandshakeGenesisMismatch,
 		},
 	}
 	for i, test := range tests {
@@ -89,6 +93,14 @@ func TestStatusMsgErrors63(t *testing.T) {
 				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
 			} else if err.Error() != test.wantError.Error() {
 				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			} else {
+				var failure p2p.HandshakeFailure
+				if herr, ok := err.(*p2p.HandshakeError); ok {
+					failure = herr.Failure
+				}
+				if failure != test.wantFailure {
+					t.Errorf("test %d: wrong handshake failure: got %q, want %q", i, failure, test.wantFailure)
+				}
 			}
 		case <-time.After(2 * time.Second):
 			t.Errorf("protocol did not shut down within 2 seconds")
@@ -108,9 +120,10 @@ func TestStatusMsgErrors64(t *testing.T) {
 	defer pm.Stop()
 
 	tests := []struct {
-		code      uint64
-		data      interface{}
-		wantError error
+		code        uint64
+		data        interface{}
+		wantError   error
+		wantFailure p2p.HandshakeFailure
 	}{
 		{
 			code: TxMsg, data: []interface{}{},
@@ -118,19 +131,23 @@ func TestStatusMsgErrors64(t *testing.T) {
 		},
 		{
 			code: StatusMsg, data: statusData{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkID},
-			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", 64),
+			wantError:   errResp(ErrProtocolVersionMismatch, "10 (!= %d)", 64),
+			wantFailure: p2p.HandshakeIncompatible,
 		},
 		{
 			code: StatusMsg, data: statusData{64, 999, td, head.Hash(), genesis.Hash(), forkID},
-			wantError: errResp(ErrNetworkIDMismatch, "999 (!= %d)", DefaultConfig.NetworkId),
+			wantError:   errResp(ErrNetworkIDMismatch, "999 (!= %d)", DefaultConfig.NetworkId),
+			wantFailure: p2p.HandshakeIncompatible,
 		},
 		{
 			code: StatusMsg, data: statusData{64, DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}, forkID},
-			wantError: errResp(ErrGenesisMismatch, "0300000000000000000000000000000000000000000000000000000000000000 (!= %x)", genesis.Hash()),
+			wantError:   errResp(ErrGenesisMismatch, "0300000000000000000000000000000000000000000000000000000000000000 (!= %x)", genesis.Hash()),
+			wantFailure: p2p.HandshakeGenesisMismatch,
 		},
 		{
 			code: StatusMsg, data: statusData{64, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
-			wantError: errResp(ErrForkIDRejected, forkid.ErrLocalIncompatibleOrStale.Error()),
+			wantError:   errResp(ErrForkIDRejected, forkid.ErrLocalIncompatibleOrStale.Error()),
+			wantFailure: p2p.HandshakeForkIDRejected,
 		},
 	}
 	for i, test := range tests {
@@ -145,6 +162,14 @@ func TestStatusMsgErrors64(t *testing.T) {
 				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
 			} else if err.Error() != test.wantError.Error() {
 				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			} else {
+				var failure p2p.HandshakeFailure
+				if herr, ok := err.(*p2p.HandshakeError); ok {
+					failure = herr.Failure
+				}
+				if failure != test.wantFailure {
+					t.Errorf("test %d: wrong handshake failure: got %q, want %q", i, failure, test.wantFailure)
+				}
 			}
 		case <-time.After(2 * time.Second):
 			t.Errorf("protocol did not shut down within 2 seconds")
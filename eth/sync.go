@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
@@ -188,6 +189,9 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	}
 	// Run the sync cycle, and disable fast sync if we've went past the pivot block
 	if err := pm.downloader.Synchronise(peer.id, pHead, pTd, mode); err != nil {
+		if downloader.IsPeerFault(err) {
+			peer.ReportIncident(p2p.IncidentUselessData)
+		}
 		return
 	}
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
@@ -0,0 +1,133 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBackend is the name of the key-value store backend used if none is
+// configured.
+const DefaultBackend = "leveldb"
+
+// backendMarker is the name of the file recording the backend which created the
+// key-value store in a directory.
+const backendMarker = "BACKEND"
+
+// Backend is a persistent key-value store implementation which can be selected
+// by name.
+type Backend struct {
+	// Open opens the key-value store in the given directory, creating it if it
+	// doesn't exist yet. The namespace is the prefix that the metrics reporting
+	// should use for surfacing internal stats.
+	Open func(file string, cache int, handles int, namespace string) (KeyValueStore, error)
+
+	// Detect reports whether the given directory contains a key-value store
+	// created by this backend.
+	Detect func(file string) bool
+}
+
+var (
+	backendsLock sync.RWMutex
+	backends     = make(map[string]Backend)
+)
+
+// RegisterBackend makes a key-value store backend available under the given
+// name. It's meant to be called from the init function of the implementing
+// package and panics if the name is already taken.
+func RegisterBackend(name string, backend Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("database backend %q registered twice", name))
+	}
+	backends[name] = backend
+}
+
+// Backends returns the names of all registered key-value store backends.
+func Backends() []string {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenBackend opens the key-value store in the given directory using the named
+// backend, or the default one if the name is empty. Directories containing a
+// store of another backend are refused, so data is never silently opened with
+// the wrong backend.
+//
+// The backend is recorded in a marker file in the directory when the store is
+// first opened. Stores without a marker are checked against the registered
+// backends instead.
+func OpenBackend(name string, file string, cache int, handles int, namespace string) (KeyValueStore, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+	backendsLock.RLock()
+	backend, ok := backends[name]
+	others := make(map[string]Backend)
+	for other, b := range backends {
+		if other != name {
+			others[other] = b
+		}
+	}
+	backendsLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database backend %q (available: %v)", name, Backends())
+	}
+	marker := filepath.Join(file, backendMarker)
+	blob, err := ioutil.ReadFile(marker)
+	switch {
+	case err == nil:
+		if owner := strings.TrimSpace(string(blob)); owner != name {
+			return nil, fmt.Errorf("database %s was created by the %s backend, not %s", file, owner, name)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	case !backend.Detect(file):
+		for other, b := range others {
+			if b.Detect(file) {
+				return nil, fmt.Errorf("database %s was created by the %s backend, not %s", file, other, name)
+			}
+		}
+	}
+	db, err := backend.Open(file, cache, handles, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if blob == nil {
+		if err := ioutil.WriteFile(marker, []byte(name+"\n"), 0644); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to record database backend: %v", err)
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errTestOpened = errors.New("opened")
+
+// testBackend registers a fake backend detecting directories named after it.
+func testBackend(name string) {
+	RegisterBackend(name, Backend{
+		Open: func(file string, cache int, handles int, namespace string) (KeyValueStore, error) {
+			return nil, errTestOpened
+		},
+		Detect: func(file string) bool {
+			return strings.HasPrefix(file, name)
+		},
+	})
+}
+
+// Tests that backends are selected by name and that directories belonging to
+// another backend are refused.
+func TestOpenBackend(t *testing.T) {
+	testBackend("test-a")
+	testBackend("test-b")
+
+	tests := []struct {
+		backend string
+		file    string
+		err     string
+	}{
+		{"test-a", "test-a-dir", errTestOpened.Error()},
+		{"test-a", "fresh-dir", errTestOpened.Error()},
+		{"test-b", "test-a-dir", "database test-a-dir was created by the test-a backend, not test-b"},
+		{"test-c", "fresh-dir", `unknown database backend "test-c"`},
+	}
+	for i, tt := range tests {
+		_, err := OpenBackend(tt.backend, tt.file, 0, 0, "")
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("duplicate registration didn't panic")
+		}
+	}()
+	testBackend("test-a")
+}
+
+// Tests that the backend of a store is recorded on creation, and directories
+// marked by another backend are refused even if it doesn't detect them.
+func TestOpenBackendMarker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb-backend-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	RegisterBackend("test-marker", Backend{
+		Open: func(file string, cache int, handles int, namespace string) (KeyValueStore, error) {
+			return nil, nil
+		},
+		Detect: func(file string) bool { return false },
+	})
+	testBackend("test-other")
+
+	if _, err := OpenBackend("test-marker", dir, 0, 0, ""); err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if blob, err := ioutil.ReadFile(filepath.Join(dir, backendMarker)); err != nil || string(blob) != "test-marker\n" {
+		t.Fatalf("backend marker mismatch: have %q, %v; want %q", blob, err, "test-marker\n")
+	}
+	if _, err := OpenBackend("test-marker", dir, 0, 0, ""); err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	want := "database " + dir + " was created by the test-marker backend, not test-other"
+	if _, err := OpenBackend("test-other", dir, 0, 0, ""); err == nil || err.Error() != want {
+		t.Fatalf("error mismatch: have %v, want %q", err, want)
+	}
+}
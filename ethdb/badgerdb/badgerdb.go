@@ -0,0 +1,593 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build badgerdb
+
+// Package badgerdb implements the key-value database layer based on BadgerDB.
+//
+// The package is only built with the badgerdb build tag and registers itself as
+// the "badgerdb" ethdb backend when imported.
+package badgerdb
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// minCache is the minimum amount of memory in megabytes to allocate to the
+	// badger memtables.
+	minCache = 16
+
+	// metricsGatheringInterval specifies the interval to retrieve badger database
+	// size stats to report to the user.
+	metricsGatheringInterval = 3 * time.Second
+
+	// gcInterval specifies how often the value log is garbage collected. Badger
+	// never reclaims value log space on its own.
+	gcInterval = 5 * time.Minute
+
+	// gcDiscardRatio is the fraction of stale data in a value log file at which
+	// it's rewritten during garbage collection.
+	gcDiscardRatio = 0.5
+)
+
+// errSnapshotReleased is returned if a snapshot is accessed after being released.
+var errSnapshotReleased = errors.New("snapshot released")
+
+func init() {
+	ethdb.RegisterBackend("badgerdb", ethdb.Backend{
+		Open: func(file string, cache int, handles int, namespace string) (ethdb.KeyValueStore, error) {
+			db, err := New(file, cache, handles, namespace)
+			if err != nil {
+				return nil, err
+			}
+			return db, nil
+		},
+		Detect: func(file string) bool {
+			// LevelDB manifests are always numbered, badger's is not
+			return common.FileExist(filepath.Join(file, badger.ManifestFilename))
+		},
+	})
+}
+
+// Database is a persistent key-value store based on BadgerDB. Apart from basic
+// data storage functionality it also supports batch writes and iterating over
+// the keyspace in binary-alphabetical order.
+type Database struct {
+	fn string     // filename for reporting
+	db *badger.DB // BadgerDB instance
+
+	compTimeMeter metrics.Meter // Meter for measuring the total time spent in explicit compaction and value log GC
+	diskSizeGauge metrics.Gauge // Gauge for tracking the size of all the data in the database
+	lsmSizeGauge  metrics.Gauge // Gauge for tracking the size of the LSM tree
+	vlogSizeGauge metrics.Gauge // Gauge for tracking the size of the value logs
+
+	quitLock sync.Mutex      // Mutex protecting the quit channel access
+	quitChan chan chan error // Quit channel to stop the background maintenance before closing the database
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// New returns a wrapped BadgerDB object. The namespace is the prefix that the
+// metrics reporting should use for surfacing internal stats. Badger keeps its
+// tables memory mapped, so the file handle allowance is ignored.
+func New(file string, cache int, handles int, namespace string) (*Database, error) {
+	if cache < minCache {
+		cache = minCache
+	}
+	logger := log.New("database", file)
+	logger.Info("Allocated memtable cache", "cache", common.StorageSize(cache*1024*1024))
+
+	// Size the memtables so all of them together fit into the cache allowance
+	opts := badger.DefaultOptions(file).WithLogger(&badgerLogger{logger}).WithTruncate(true)
+	opts = opts.WithMaxTableSize(int64(cache) * 1024 * 1024 / int64(opts.NumMemtables))
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	bdb := &Database{
+		fn:       file,
+		db:       db,
+		log:      logger,
+		quitChan: make(chan chan error),
+	}
+	bdb.compTimeMeter = metrics.NewRegisteredMeter(namespace+"compact/time", nil)
+	bdb.diskSizeGauge = metrics.NewRegisteredGauge(namespace+"disk/size", nil)
+	bdb.lsmSizeGauge = metrics.NewRegisteredGauge(namespace+"disk/lsm", nil)
+	bdb.vlogSizeGauge = metrics.NewRegisteredGauge(namespace+"disk/vlog", nil)
+
+	// Start up the metrics gathering and value log collection and return
+	go bdb.maintain(metricsGatheringInterval, gcInterval)
+	return bdb, nil
+}
+
+// Close stops the background maintenance, flushes any pending data to disk and
+// closes all io accesses to the underlying key-value store.
+func (db *Database) Close() error {
+	db.quitLock.Lock()
+	defer db.quitLock.Unlock()
+
+	if db.quitChan != nil {
+		errc := make(chan error)
+		db.quitChan <- errc
+		if err := <-errc; err != nil {
+			db.log.Error("Database maintenance failed", "err", err)
+		}
+		db.quitChan = nil
+	}
+	return db.db.Close()
+}
+
+// Has retrieves if a key is present in the key-value store.
+func (db *Database) Has(key []byte) (bool, error) {
+	err := db.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		return err
+	})
+	switch err {
+	case nil:
+		return true, nil
+	case badger.ErrKeyNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Get retrieves the given key if it's present in the key-value store.
+func (db *Database) Get(key []byte) ([]byte, error) {
+	var dat []byte
+	err := db.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		dat, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dat, nil
+}
+
+// Put inserts the given value into the key-value store.
+func (db *Database) Put(key []byte, value []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// Delete removes the key from the key-value store.
+func (db *Database) Delete(key []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// NewBatch creates a write-only key-value store that buffers changes to its host
+// database until a final write is called.
+func (db *Database) NewBatch() ethdb.Batch {
+	return &batch{db: db.db}
+}
+
+// NewIterator creates a binary-alphabetical iterator over the entire keyspace
+// contained within the badger database.
+func (db *Database) NewIterator() ethdb.Iterator {
+	return newIterator(db.db, nil, nil)
+}
+
+// NewIteratorWithStart creates a binary-alphabetical iterator over a subset of
+// database content starting at a particular initial key (or after, if it does
+// not exist).
+func (db *Database) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return newIterator(db.db, nil, start)
+}
+
+// NewIteratorWithPrefix creates a binary-alphabetical iterator over a subset
+// of database content with a particular key prefix.
+func (db *Database) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return newIterator(db.db, prefix, nil)
+}
+
+// NewSnapshot creates a snapshot of the current state of the database, backed by
+// a read-only transaction, so it's not affected by subsequent writes.
+func (db *Database) NewSnapshot() (ethdb.Snapshot, error) {
+	return &snapshot{txn: db.db.NewTransaction(false)}, nil
+}
+
+// Stat returns a particular internal stat of the database. Supported properties
+// are "badger.size" for the on-disk footprint and "badger.tables" for the list
+// of tables in the LSM tree.
+func (db *Database) Stat(property string) (string, error) {
+	switch property {
+	case "badger.size":
+		lsm, vlog := db.db.Size()
+		return fmt.Sprintf("LSM(MB):%.5f VLog(MB):%.5f", float64(lsm)/1024/1024, float64(vlog)/1024/1024), nil
+
+	case "badger.tables":
+		var out strings.Builder
+		out.WriteString(" Level |   ID   |  Keys\n")
+		out.WriteString("-------+--------+---------\n")
+		for _, table := range db.db.Tables(true) {
+			fmt.Fprintf(&out, " %5d | %6d | %7d\n", table.Level, table.ID, table.KeyCount)
+		}
+		return out.String(), nil
+
+	default:
+		return "", fmt.Errorf("unknown property %q", property)
+	}
+}
+
+// Compact flattens the underlying data store and garbage collects the value
+// logs. Badger can't compact individual key ranges, so the start and limit keys
+// are ignored and the entire data store is always compacted.
+func (db *Database) Compact(start []byte, limit []byte) error {
+	defer func(start time.Time) {
+		db.compTimeMeter.Mark(int64(time.Since(start)))
+	}(time.Now())
+
+	if err := db.db.Flatten(1); err != nil {
+		return err
+	}
+	return db.collect()
+}
+
+// Path returns the path to the database directory.
+func (db *Database) Path() string {
+	return db.fn
+}
+
+// collect rewrites value log files until none of them are worth rewriting.
+func (db *Database) collect() error {
+	for {
+		switch err := db.db.RunValueLogGC(gcDiscardRatio); err {
+		case nil:
+			// A file was rewritten, there might be more
+		case badger.ErrNoRewrite, badger.ErrRejected:
+			// Nothing left to collect or a collection is already running
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// maintain periodically reports the database size to the metrics subsystem and
+// garbage collects the value logs, which badger doesn't do on its own.
+func (db *Database) maintain(refresh time.Duration, gc time.Duration) {
+	var (
+		errc chan error
+		merr error
+
+		meter   = time.NewTicker(refresh)
+		collect = time.NewTicker(gc)
+	)
+	defer meter.Stop()
+	defer collect.Stop()
+
+	for errc == nil {
+		select {
+		case errc = <-db.quitChan:
+			// Quit requesting, stop hammering the database
+		case <-meter.C:
+			lsm, vlog := db.db.Size()
+			db.diskSizeGauge.Update(lsm + vlog)
+			db.lsmSizeGauge.Update(lsm)
+			db.vlogSizeGauge.Update(vlog)
+
+		case <-collect.C:
+			// Keep collecting even after a failure, the value logs would grow
+			// unbounded otherwise
+			start := time.Now()
+			if err := db.collect(); err != nil {
+				db.log.Error("Value log collection failed", "err", err)
+				merr = err
+			}
+			db.compTimeMeter.Mark(int64(time.Since(start)))
+		}
+	}
+	errc <- merr
+}
+
+// keyvalue is a queued up batch operation, value is ignored for deletions.
+type keyvalue struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// batch is a write-only badger batch that commits changes to its host database
+// when Write is called. A batch cannot be used concurrently.
+type batch struct {
+	db     *badger.DB
+	writes []keyvalue
+	size   int
+}
+
+// Put inserts the given value into the batch for later committing.
+func (b *batch) Put(key, value []byte) error {
+	b.writes = append(b.writes, keyvalue{common.CopyBytes(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+// Delete inserts the a key removal into the batch for later committing.
+func (b *batch) Delete(key []byte) error {
+	b.writes = append(b.writes, keyvalue{common.CopyBytes(key), nil, true})
+	b.size++
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+// Write flushes any accumulated data to disk. Writes too large for a single
+// badger transaction are split up, so a batch is not guaranteed to be atomic.
+func (b *batch) Write() error {
+	wb := b.db.NewWriteBatch()
+	for _, kv := range b.writes {
+		var err error
+		if kv.delete {
+			err = wb.Delete(kv.key)
+		} else {
+			err = wb.Set(kv.key, kv.value)
+		}
+		if err != nil {
+			wb.Cancel()
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// Reset resets the batch for reuse.
+func (b *batch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// Replay replays the batch contents.
+func (b *batch) Replay(w ethdb.KeyValueWriter) error {
+	for _, kv := range b.writes {
+		if kv.delete {
+			if err := w.Delete(kv.key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.Put(kv.key, kv.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iterator is a binary-alphabetical iterator over a consistent snapshot of the
+// badger database, optionally limited to a key prefix.
+type iterator struct {
+	txn    *badger.Txn
+	owned  bool // Whether the transaction is discarded on release
+	it     *badger.Iterator
+	prefix []byte
+
+	moved    bool // Whether the iterator needs to be advanced before the next item
+	released bool // Whether the iterator and its transaction were released
+	key      []byte
+	value    []byte
+	err      error
+}
+
+// newIterator creates an iterator over the keys with the given prefix, starting
+// at the given key (or after, if it does not exist).
+func newIterator(db *badger.DB, prefix []byte, start []byte) *iterator {
+	return newTxnIterator(db.NewTransaction(false), true, prefix, start)
+}
+
+// newTxnIterator creates an iterator over the given transaction, discarding it
+// on release if the iterator owns it.
+func newTxnIterator(txn *badger.Txn, owned bool, prefix []byte, start []byte) *iterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+
+	seek := append(common.CopyBytes(prefix), start...)
+	it.Seek(seek)
+
+	return &iterator{
+		txn:    txn,
+		owned:  owned,
+		it:     it,
+		prefix: prefix,
+	}
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (it *iterator) Next() bool {
+	if it.released || it.err != nil {
+		return false
+	}
+	if it.moved {
+		it.it.Next()
+	}
+	it.moved = true
+
+	if !it.it.ValidForPrefix(it.prefix) {
+		it.key, it.value = nil, nil
+		return false
+	}
+	item := it.it.Item()
+	it.key = item.KeyCopy(it.key[:0])
+	if it.value, it.err = item.ValueCopy(it.value[:0]); it.err != nil {
+		it.key, it.value = nil, nil
+		return false
+	}
+	return true
+}
+
+// Error returns any accumulated error. Exhausting all the key/value pairs
+// is not considered to be an error.
+func (it *iterator) Error() error {
+	return it.err
+}
+
+// Key returns the key of the current key/value pair, or nil if done. The caller
+// should not modify the contents of the returned slice, and its contents may
+// change on the next call to Next.
+func (it *iterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the current key/value pair, or nil if done. The
+// caller should not modify the contents of the returned slice, and its contents
+// may change on the next call to Next.
+func (it *iterator) Value() []byte {
+	return it.value
+}
+
+// Release releases associated resources. Release should always succeed and can
+// be called multiple times without causing error.
+func (it *iterator) Release() {
+	if it.released {
+		return
+	}
+	it.it.Close()
+	if it.owned {
+		it.txn.Discard()
+	}
+	it.released = true
+}
+
+// snapshot wraps a read-only badger transaction, implementing the ethdb.Snapshot
+// interface. Transactions aren't safe for concurrent use, so accesses to it are
+// serialized.
+type snapshot struct {
+	txn      *badger.Txn
+	released bool
+	lock     sync.Mutex
+}
+
+// Has retrieves if a key is present in the snapshot.
+func (snap *snapshot) Has(key []byte) (bool, error) {
+	snap.lock.Lock()
+	defer snap.lock.Unlock()
+
+	if snap.released {
+		return false, errSnapshotReleased
+	}
+	switch _, err := snap.txn.Get(key); err {
+	case nil:
+		return true, nil
+	case badger.ErrKeyNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Get retrieves the given key if it's present in the snapshot.
+func (snap *snapshot) Get(key []byte) ([]byte, error) {
+	snap.lock.Lock()
+	defer snap.lock.Unlock()
+
+	if snap.released {
+		return nil, errSnapshotReleased
+	}
+	item, err := snap.txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+// NewIterator creates a binary-alphabetical iterator over the entire keyspace
+// contained within the snapshot.
+func (snap *snapshot) NewIterator() ethdb.Iterator {
+	return snap.newIterator(nil, nil)
+}
+
+// NewIteratorWithStart creates a binary-alphabetical iterator over a subset of
+// the snapshot starting at a particular initial key (or after, if it does not
+// exist).
+func (snap *snapshot) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return snap.newIterator(nil, start)
+}
+
+// NewIteratorWithPrefix creates a binary-alphabetical iterator over a subset
+// of the snapshot with a particular key prefix.
+func (snap *snapshot) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return snap.newIterator(prefix, nil)
+}
+
+// newIterator creates an iterator over the snapshot's transaction, which is left
+// open when the iterator is released.
+func (snap *snapshot) newIterator(prefix []byte, start []byte) ethdb.Iterator {
+	snap.lock.Lock()
+	defer snap.lock.Unlock()
+
+	if snap.released {
+		return &iterator{released: true, err: errSnapshotReleased}
+	}
+	return newTxnIterator(snap.txn, false, prefix, start)
+}
+
+// Release discards the transaction backing the snapshot.
+func (snap *snapshot) Release() {
+	snap.lock.Lock()
+	defer snap.lock.Unlock()
+
+	if !snap.released {
+		snap.txn.Discard()
+		snap.released = true
+	}
+}
+
+// badgerLogger forwards the internal badger logs to the contextual logger of
+// the database, demoting the chatty info messages to debug level.
+type badgerLogger struct {
+	log log.Logger
+}
+
+func (l *badgerLogger) Errorf(format string, args ...interface{}) {
+	l.log.Error(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func (l *badgerLogger) Warningf(format string, args ...interface{}) {
+	l.log.Warn(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func (l *badgerLogger) Infof(format string, args ...interface{}) {
+	l.log.Debug(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+func (l *badgerLogger) Debugf(format string, args ...interface{}) {
+	l.log.Trace(strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
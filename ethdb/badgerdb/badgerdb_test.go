@@ -0,0 +1,107 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build badgerdb
+
+package badgerdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
+	_ "github.com/ethereum/go-ethereum/ethdb/leveldb"
+)
+
+// newTestDatabase returns a constructor for badger databases in fresh temporary
+// directories, and a cleanup function removing all of them.
+func newTestDatabase(tb testing.TB) (func() ethdb.KeyValueStore, func()) {
+	root, err := ioutil.TempDir("", "badgerdb-test-")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var count int
+	open := func() ethdb.KeyValueStore {
+		count++
+		db, err := New(filepath.Join(root, fmt.Sprintf("db-%d", count)), 0, 0, "")
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return db
+	}
+	return open, func() { os.RemoveAll(root) }
+}
+
+func TestBadgerDB(t *testing.T) {
+	t.Run("DatabaseSuite", func(t *testing.T) {
+		open, cleanup := newTestDatabase(t)
+		defer cleanup()
+
+		dbtest.TestDatabaseSuite(t, open)
+	})
+}
+
+func BenchmarkBadgerDB(b *testing.B) {
+	open, cleanup := newTestDatabase(b)
+	defer cleanup()
+
+	dbtest.BenchDatabaseSuite(b, open)
+}
+
+// Tests that data directories of one backend are refused by the other.
+func TestBackendDetection(t *testing.T) {
+	root, err := ioutil.TempDir("", "badgerdb-detect-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, backend := range []string{"badgerdb", "leveldb"} {
+		dir := filepath.Join(root, backend)
+		db, err := ethdb.OpenBackend(backend, dir, 0, 0, "")
+		if err != nil {
+			t.Fatalf("%s: failed to create database: %v", backend, err)
+		}
+		if err := db.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatalf("%s: failed to write: %v", backend, err)
+		}
+		db.Close()
+
+		// Reopening with the same backend must work
+		if db, err = ethdb.OpenBackend(backend, dir, 0, 0, ""); err != nil {
+			t.Fatalf("%s: failed to reopen database: %v", backend, err)
+		}
+		if val, err := db.Get([]byte("key")); err != nil || string(val) != "value" {
+			t.Fatalf("%s: value mismatch: have %q, %v; want %q", backend, val, err, "value")
+		}
+		db.Close()
+
+		// Opening with any other backend must be refused
+		for _, other := range ethdb.Backends() {
+			if other == backend {
+				continue
+			}
+			if db, err := ethdb.OpenBackend(other, dir, 0, 0, ""); err == nil {
+				db.Close()
+				t.Fatalf("%s database opened by %s", backend, other)
+			}
+		}
+	}
+}
@@ -62,6 +62,7 @@ type KeyValueStore interface {
 	KeyValueWriter
 	Batcher
 	Iteratee
+	Snapshotter
 	Stater
 	Compacter
 	io.Closer
@@ -76,6 +77,11 @@ type AncientReader interface {
 	// Ancient retrieves an ancient binary blob from the append-only immutable files.
 	Ancient(kind string, number uint64) ([]byte, error)
 
+	// AncientRange retrieves up to count consecutive ancient binary blobs starting
+	// at the given number. Fewer items are returned if the store ends earlier or
+	// their stored size exceeds maxBytes (0 = unlimited), but never less than one.
+	AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error)
+
 	// Ancients returns the ancient item numbers in the ancient store.
 	Ancients() (uint64, error)
 
@@ -125,6 +131,7 @@ type Database interface {
 	Writer
 	Batcher
 	Iteratee
+	Snapshotter
 	Stater
 	Compacter
 	io.Closer
@@ -18,6 +18,7 @@ package dbtest
 
 import (
 	"bytes"
+	"crypto/rand"
 	"reflect"
 	"sort"
 	"testing"
@@ -303,6 +304,161 @@ func TestDatabaseSuite(t *testing.T, New func() ethdb.KeyValueStore) {
 		it.Release()
 	})
 
+	t.Run("Snapshot", func(t *testing.T) {
+		db := New()
+		defer db.Close()
+
+		for _, k := range []string{"1", "2", "3"} {
+			if err := db.Put([]byte(k), []byte("v"+k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		snap, err := db.NewSnapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Modify the database, the snapshot shouldn't observe any of it
+		if err := db.Put([]byte("4"), []byte("v4")); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Put([]byte("1"), []byte("changed")); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Delete([]byte("2")); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := snap.Get([]byte("1")); err != nil || !bytes.Equal(got, []byte("v1")) {
+			t.Errorf("snapshot get mismatch: got %q, %v; want %q", got, err, "v1")
+		}
+		if has, err := snap.Has([]byte("2")); err != nil || !has {
+			t.Errorf("snapshot lost deleted key: has %v, %v", has, err)
+		}
+		if has, err := snap.Has([]byte("4")); err != nil || has {
+			t.Errorf("snapshot observed new key: has %v, %v", has, err)
+		}
+		it := snap.NewIterator()
+		if got, want := iterateKeys(it), []string{"1", "2", "3"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %s; want: %s", got, want)
+		}
+		it.Release()
+
+		it = snap.NewIteratorWithStart([]byte("2"))
+		if got, want := iterateKeys(it), []string{"2", "3"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %s; want: %s", got, want)
+		}
+		it.Release()
+
+		// The database itself should still be up to date
+		it = db.NewIterator()
+		if got, want := iterateKeys(it), []string{"1", "3", "4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %s; want: %s", got, want)
+		}
+		it.Release()
+
+		snap.Release()
+	})
+}
+
+// BenchDatabaseSuite runs a suite of benchmarks against a KeyValueStore database
+// implementation, modelled after the access patterns of chain sync: random
+// 32 byte keys (hashes) with small values, written in batches and individually.
+func BenchDatabaseSuite(b *testing.B, New func() ethdb.KeyValueStore) {
+	b.Run("Put", func(b *testing.B) {
+		db := New()
+		defer db.Close()
+
+		keys, vals := makeDataset(b.N, 32, 100)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := db.Put(keys[i], vals[i]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("BatchWrite", func(b *testing.B) {
+		db := New()
+		defer db.Close()
+
+		keys, vals := makeDataset(b.N, 32, 100)
+		b.ResetTimer()
+
+		batch := db.NewBatch()
+		for i := 0; i < b.N; i++ {
+			batch.Put(keys[i], vals[i])
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					b.Fatal(err)
+				}
+				batch.Reset()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+	})
+
+	b.Run("Get", func(b *testing.B) {
+		db := New()
+		defer db.Close()
+
+		keys, vals := makeDataset(b.N, 32, 100)
+		batch := db.NewBatch()
+		for i := 0; i < b.N; i++ {
+			batch.Put(keys[i], vals[i])
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := db.Get(keys[i]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Iterate", func(b *testing.B) {
+		db := New()
+		defer db.Close()
+
+		keys, vals := makeDataset(b.N, 32, 100)
+		batch := db.NewBatch()
+		for i := 0; i < b.N; i++ {
+			batch.Put(keys[i], vals[i])
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+
+		it := db.NewIterator()
+		defer it.Release()
+		for it.Next() {
+		}
+		if err := it.Error(); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+// makeDataset generates a set of random keys and values of the given sizes.
+func makeDataset(size, ksize, vsize int) ([][]byte, [][]byte) {
+	var keys, vals [][]byte
+	for i := 0; i < size; i++ {
+		keys = append(keys, randBytes(ksize))
+		vals = append(vals, randBytes(vsize))
+	}
+	return keys, vals
+}
+
+// randBytes generates a random blob of data.
+func randBytes(len int) []byte {
+	buf := make([]byte, len)
+	if n, err := rand.Read(buf); n != len || err != nil {
+		panic(err)
+	}
+	return buf
 }
 
 func iterateKeys(it ethdb.Iterator) []string {
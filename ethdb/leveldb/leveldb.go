@@ -21,6 +21,7 @@ package leveldb
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -55,6 +56,21 @@ const (
 	metricsGatheringInterval = 3 * time.Second
 )
 
+func init() {
+	ethdb.RegisterBackend(ethdb.DefaultBackend, ethdb.Backend{
+		Open: func(file string, cache int, handles int, namespace string) (ethdb.KeyValueStore, error) {
+			db, err := New(file, cache, handles, namespace)
+			if err != nil {
+				return nil, err
+			}
+			return db, nil
+		},
+		Detect: func(file string) bool {
+			return common.FileExist(filepath.Join(file, "CURRENT"))
+		},
+	})
+}
+
 // Database is a persistent key-value store. Apart from basic data storage
 // functionality it also supports batch writes and iterating over the keyspace in
 // binary-alphabetical order.
@@ -202,6 +218,16 @@ func (db *Database) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
 	return db.db.NewIterator(util.BytesPrefix(prefix), nil)
 }
 
+// NewSnapshot creates a snapshot of the current state of the database, which is
+// not affected by subsequent writes.
+func (db *Database) NewSnapshot() (ethdb.Snapshot, error) {
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{db: snap}, nil
+}
+
 // Stat returns a particular internal stat of the database.
 func (db *Database) Stat(property string) (string, error) {
 	return db.db.GetProperty(property)
@@ -488,3 +514,47 @@ func (r *replayer) Delete(key []byte) {
 	}
 	r.failure = r.writer.Delete(key)
 }
+
+// snapshot wraps a leveldb snapshot, implementing the ethdb.Snapshot interface.
+type snapshot struct {
+	db *leveldb.Snapshot
+}
+
+// Has retrieves if a key is present in the snapshot.
+func (snap *snapshot) Has(key []byte) (bool, error) {
+	return snap.db.Has(key, nil)
+}
+
+// Get retrieves the given key if it's present in the snapshot.
+func (snap *snapshot) Get(key []byte) ([]byte, error) {
+	dat, err := snap.db.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return dat, nil
+}
+
+// NewIterator creates a binary-alphabetical iterator over the entire keyspace
+// contained within the snapshot.
+func (snap *snapshot) NewIterator() ethdb.Iterator {
+	return snap.db.NewIterator(new(util.Range), nil)
+}
+
+// NewIteratorWithStart creates a binary-alphabetical iterator over a subset of
+// the snapshot starting at a particular initial key (or after, if it does not
+// exist).
+func (snap *snapshot) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return snap.db.NewIterator(&util.Range{Start: start}, nil)
+}
+
+// NewIteratorWithPrefix creates a binary-alphabetical iterator over a subset
+// of the snapshot with a particular key prefix.
+func (snap *snapshot) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return snap.db.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+// Release releases the snapshot, allowing the database to discard the data it
+// retained.
+func (snap *snapshot) Release() {
+	snap.db.Release()
+}
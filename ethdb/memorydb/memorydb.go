@@ -192,6 +192,23 @@ func (db *Database) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
 	}
 }
 
+// NewSnapshot creates a snapshot of the current state of the database by copying
+// its entries, so it's not affected by subsequent writes.
+func (db *Database) NewSnapshot() (ethdb.Snapshot, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.db == nil {
+		return nil, errMemorydbClosed
+	}
+	// Values are never modified in place, it's enough to copy the map
+	entries := make(map[string][]byte, len(db.db))
+	for key, value := range db.db {
+		entries[key] = value
+	}
+	return &snapshot{db: &Database{db: entries}}, nil
+}
+
 // Stat returns a particular internal stat of the database.
 func (db *Database) Stat(property string) (string, error) {
 	return "", errors.New("unknown property")
@@ -342,3 +359,44 @@ func (it *iterator) Value() []byte {
 func (it *iterator) Release() {
 	it.keys, it.values = nil, nil
 }
+
+// snapshot is a frozen copy of a memory database, implementing the ethdb.Snapshot
+// interface.
+type snapshot struct {
+	db *Database
+}
+
+// Has retrieves if a key is present in the snapshot.
+func (snap *snapshot) Has(key []byte) (bool, error) {
+	return snap.db.Has(key)
+}
+
+// Get retrieves the given key if it's present in the snapshot.
+func (snap *snapshot) Get(key []byte) ([]byte, error) {
+	return snap.db.Get(key)
+}
+
+// NewIterator creates a binary-alphabetical iterator over the entire keyspace
+// contained within the snapshot.
+func (snap *snapshot) NewIterator() ethdb.Iterator {
+	return snap.db.NewIterator()
+}
+
+// NewIteratorWithStart creates a binary-alphabetical iterator over a subset of
+// the snapshot starting at a particular initial key (or after, if it does not
+// exist).
+func (snap *snapshot) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return snap.db.NewIteratorWithStart(start)
+}
+
+// NewIteratorWithPrefix creates a binary-alphabetical iterator over a subset
+// of the snapshot with a particular key prefix.
+func (snap *snapshot) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return snap.db.NewIteratorWithPrefix(prefix)
+}
+
+// Release deallocates the copied entries, any consecutive data access fails with
+// an error.
+func (snap *snapshot) Release() {
+	snap.db.Close()
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+// Snapshot is a frozen, read-only view of a key-value data store at the time it
+// was created. Writes to the data store happening afterwards are not visible in
+// it.
+//
+// A snapshot must be released after use, and before the data store is closed.
+// Until then, the data store can't discard the data overwritten or deleted since
+// the snapshot was created.
+type Snapshot interface {
+	KeyValueReader
+	Iteratee
+
+	// Release releases associated resources. Release should always succeed and can
+	// be called multiple times without causing error. Iterators created from the
+	// snapshot must be released first.
+	Release()
+}
+
+// Snapshotter wraps the NewSnapshot method of a backing data store.
+type Snapshotter interface {
+	// NewSnapshot creates a snapshot of the current state of the data store, which
+	// is not affected by subsequent writes.
+	NewSnapshot() (Snapshot, error)
+}
@@ -20,9 +20,29 @@ import (
 	"errors"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// filtersApplied counts the events discarded by the filters of subscriptions.
+var filtersApplied = metrics.NewRegisteredCounter("event/feed/filtered", nil)
+
+var (
+	errBadChannel = errors.New("event: Subscribe argument does not have sendable channel type")
+
+	// ErrSubscriberDropped is reported on the Err channel of subscriptions whose
+	// subscriber closed its error channel instead of sending an error.
+	ErrSubscriberDropped = errors.New("event: subscriber dropped")
 )
 
-var errBadChannel = errors.New("event: Subscribe argument does not have sendable channel type")
+// DroppedEvent is a value which a Feed failed to deliver to one of its subscribers,
+// as handed over to the dead letter queue of the feed.
+type DroppedEvent struct {
+	Event interface{}  // Value passed to Send
+	Sub   Subscription // Subscription whose channel was full
+}
 
 // Feed implements one-to-many subscriptions where the carrier of events is a channel.
 // Values sent to a Feed are delivered to all subscribed channels simultaneously.
@@ -33,15 +53,28 @@ var errBadChannel = errors.New("event: Subscribe argument does not have sendable
 //
 // The zero value is ready to use.
 type Feed struct {
+	dropped uint64 // Number of values not delivered to subscribers (atomic, keep first for alignment)
+
 	once      sync.Once        // ensures that init only runs once
 	sendLock  chan struct{}    // sendLock has a one-element buffer and is empty when held.It protects sendCases.
 	removeSub chan interface{} // interrupts Send
 	sendCases caseList         // the active set of select cases used by Send
 
 	// The inbox holds newly subscribed channels until they are added to sendCases.
-	mu    sync.Mutex
-	inbox caseList
-	etype reflect.Type
+	mu        sync.Mutex
+	inbox     caseList
+	etype     reflect.Type
+	reporters []*feedSub // subscriptions able to report errors back to the feed
+	subs      map[interface{}]*feedSub
+	dlq       chan<- DroppedEvent // dead letter queue, Send doesn't block if set
+
+	// filters maps the channels of filtered subscriptions to their filter. The map
+	// is replaced rather than modified, so Send can use it without holding mu.
+	filters map[interface{}]func(interface{}) bool
+
+	// meters maps the channels of metered subscriptions to their statistics. It
+	// is replaced rather than modified, like filters.
+	meters map[interface{}]*SubscriptionMetrics
 }
 
 // This is the index of the first actual subscription channel in sendCases.
@@ -68,8 +101,63 @@ func (f *Feed) init() {
 // until the subscription is canceled. All channels added must have the same element type.
 //
 // The channel should have ample buffer space to avoid blocking other subscribers.
-// Slow subscribers are not dropped.
+// Slow subscribers are not dropped, but they miss values if the feed has a dead
+// letter queue.
 func (f *Feed) Subscribe(channel interface{}) Subscription {
+	return f.SubscribeWithError(channel, nil)
+}
+
+// SubscribeWithError adds a channel to the feed like Subscribe, along with an error
+// channel through which the subscriber can signal that it's unable to keep up. Once
+// an error is pending on errCh, the feed stops delivering events to the subscriber,
+// even if a send is already blocked on it, and closes the channel. The error is
+// then reported on the subscription's Err channel.
+//
+// A nil errCh is equivalent to calling Subscribe.
+func (f *Feed) SubscribeWithError(channel interface{}, errCh chan error) Subscription {
+	return f.subscribe(channel, errCh, nil, nil)
+}
+
+// SubscribeFiltered adds a channel to the feed like Subscribe, but only the events
+// accepted by the filter are delivered to it. The filter is called by Send before
+// delivering the event, so discarded events don't take up channel space.
+//
+// The filter must be fast and must not call into the feed. A nil filter accepts
+// all events.
+func (f *Feed) SubscribeFiltered(channel interface{}, filter func(interface{}) bool) Subscription {
+	return f.subscribe(channel, nil, filter, nil)
+}
+
+// SubscribeWithMetrics adds a channel to the feed like Subscribe, and tracks the
+// delivery of values to it. This allows finding out which subscriber is slow to
+// receive values, holding up Send for all others.
+func (f *Feed) SubscribeWithMetrics(channel interface{}) (Subscription, *SubscriptionMetrics) {
+	meter := new(SubscriptionMetrics)
+	return f.subscribe(channel, nil, nil, meter), meter
+}
+
+// WithDeadLetter configures the feed to stop waiting for slow subscribers. A value
+// which can't be delivered right away because a subscriber's channel is full is
+// handed over to dlq instead, along with the subscription. It is discarded if dlq
+// is full too. A nil dlq restores the default blocking behavior.
+//
+// The feed is returned to allow configuring it on creation.
+func (f *Feed) WithDeadLetter(dlq chan<- DroppedEvent) *Feed {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dlq = dlq
+	return f
+}
+
+// DroppedCount returns the number of values which subscribers missed since the
+// creation of the feed, whether they could be handed over to the dead letter
+// queue or not.
+func (f *Feed) DroppedCount() uint64 {
+	return atomic.LoadUint64(&f.dropped)
+}
+
+func (f *Feed) subscribe(channel interface{}, errCh chan error, filter func(interface{}) bool, meter *SubscriptionMetrics) Subscription {
 	f.once.Do(f.init)
 
 	chanval := reflect.ValueOf(channel)
@@ -77,7 +165,7 @@ func (f *Feed) Subscribe(channel interface{}) Subscription {
 	if chantyp.Kind() != reflect.Chan || chantyp.ChanDir()&reflect.SendDir == 0 {
 		panic(errBadChannel)
 	}
-	sub := &feedSub{feed: f, channel: chanval, err: make(chan error, 1)}
+	sub := &feedSub{feed: f, channel: chanval, report: errCh, err: make(chan error, 1)}
 
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -88,6 +176,29 @@ func (f *Feed) Subscribe(channel interface{}) Subscription {
 	// The next Send will add it to f.sendCases.
 	cas := reflect.SelectCase{Dir: reflect.SelectSend, Chan: chanval}
 	f.inbox = append(f.inbox, cas)
+	if errCh != nil {
+		f.reporters = append(f.reporters, sub)
+	}
+	if f.subs == nil {
+		f.subs = make(map[interface{}]*feedSub)
+	}
+	f.subs[channel] = sub
+	if filter != nil {
+		filters := make(map[interface{}]func(interface{}) bool, len(f.filters)+1)
+		for ch, filter := range f.filters {
+			filters[ch] = filter
+		}
+		filters[channel] = filter
+		f.filters = filters
+	}
+	if meter != nil {
+		meters := make(map[interface{}]*SubscriptionMetrics, len(f.meters)+1)
+		for ch, meter := range f.meters {
+			meters[ch] = meter
+		}
+		meters[channel] = meter
+		f.meters = meters
+	}
 	return sub
 }
 
@@ -105,6 +216,7 @@ func (f *Feed) remove(sub *feedSub) {
 	// that have not been added to f.sendCases yet.
 	ch := sub.channel.Interface()
 	f.mu.Lock()
+	f.forget(sub)
 	index := f.inbox.find(ch)
 	if index != -1 {
 		f.inbox = f.inbox.delete(index)
@@ -118,11 +230,93 @@ func (f *Feed) remove(sub *feedSub) {
 		// Send will remove the channel from f.sendCases.
 	case <-f.sendLock:
 		// No Send is in progress, delete the channel now that we have the send lock.
-		f.sendCases = f.sendCases.delete(f.sendCases.find(ch))
+		// It might have been dropped already if the subscriber reported an error.
+		if index := f.sendCases.find(ch); index != -1 {
+			f.sendCases = f.sendCases.delete(index)
+		}
 		f.sendLock <- struct{}{}
 	}
 }
 
+// forget removes the subscription from the sets of error reporting, filtered and
+// metered ones, and from the index of subscriptions.
+//
+// note: callers must hold f.mu
+func (f *Feed) forget(sub *feedSub) {
+	delete(f.subs, sub.channel.Interface())
+	for i, reporter := range f.reporters {
+		if reporter == sub {
+			f.reporters = append(f.reporters[:i], f.reporters[i+1:]...)
+			break
+		}
+	}
+	if ch := sub.channel.Interface(); f.filters[ch] != nil {
+		filters := make(map[interface{}]func(interface{}) bool, len(f.filters)-1)
+		for other, filter := range f.filters {
+			if other != ch {
+				filters[other] = filter
+			}
+		}
+		f.filters = filters
+	}
+	if ch := sub.channel.Interface(); f.meters[ch] != nil {
+		meters := make(map[interface{}]*SubscriptionMetrics, len(f.meters)-1)
+		for other, meter := range f.meters {
+			if other != ch {
+				meters[other] = meter
+			}
+		}
+		f.meters = meters
+	}
+}
+
+// drop stops delivering events to a subscription which reported an error, closing
+// its channel and forwarding the error to its Err channel. The active prefix of the
+// send cases is returned with the dropped one removed if it was contained within.
+//
+// note: callers must hold the send lock
+func (f *Feed) drop(sub *feedSub, err error, cases caseList) caseList {
+	f.mu.Lock()
+	f.forget(sub)
+	f.mu.Unlock()
+
+	if index := f.sendCases.find(sub.channel.Interface()); index != -1 {
+		f.sendCases = f.sendCases.delete(index)
+		if index < len(cases) {
+			cases = f.sendCases[:len(cases)-1]
+		}
+	}
+	sub.channel.Close()
+	if err == nil {
+		err = ErrSubscriberDropped
+	}
+	sub.err <- err
+	return cases
+}
+
+// deadLetter hands the value over to the dead letter queue for each subscriber of
+// the given send cases, discarding it if the queue is full.
+func (f *Feed) deadLetter(dlq chan<- DroppedEvent, value interface{}, cases caseList) {
+	atomic.AddUint64(&f.dropped, uint64(len(cases)))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, cas := range cases {
+		ev := DroppedEvent{Event: value}
+		if sub := f.subs[cas.Chan.Interface()]; sub != nil {
+			ev.Sub = sub
+		}
+		if meter := f.meters[cas.Chan.Interface()]; meter != nil {
+			meter.dropped()
+		}
+		select {
+		case dlq <- ev:
+		default:
+		}
+	}
+}
+
 // Send delivers to all subscribed channels simultaneously.
 // It returns the number of subscribers that the value was sent to.
 func (f *Feed) Send(value interface{}) (nsent int) {
@@ -140,8 +334,34 @@ func (f *Feed) Send(value interface{}) (nsent int) {
 		f.sendLock <- struct{}{}
 		panic(feedTypeError{op: "Send", got: rvalue.Type(), want: f.etype})
 	}
+	reporters := append([]*feedSub(nil), f.reporters...)
+	filters := f.filters
+	meters := f.meters
+	dlq := f.dlq
 	f.mu.Unlock()
 
+	// Track the delivery times for the metered subscriptions
+	var start time.Time
+	if len(meters) > 0 {
+		start = time.Now()
+	}
+	delivered := func(cas reflect.SelectCase) {
+		if len(meters) > 0 {
+			if meter := meters[cas.Chan.Interface()]; meter != nil {
+				meter.delivered(time.Since(start))
+			}
+		}
+	}
+
+	// Drop the subscribers which reported an error since the last send.
+	for _, sub := range reporters {
+		select {
+		case err := <-sub.report:
+			f.drop(sub, err, nil)
+		default:
+		}
+	}
+
 	// Set the sent value on all channels.
 	for i := firstSubSendCase; i < len(f.sendCases); i++ {
 		f.sendCases[i].Send = rvalue
@@ -151,12 +371,24 @@ func (f *Feed) Send(value interface{}) (nsent int) {
 	// of sendCases. When a send succeeds, the corresponding case moves to the end of
 	// 'cases' and it shrinks by one element.
 	cases := f.sendCases
+
+	// Skip the subscribers whose filter rejects the value.
+	if len(filters) > 0 {
+		for i := firstSubSendCase; i < len(cases); i++ {
+			if filter := filters[cases[i].Chan.Interface()]; filter != nil && !filter(value) {
+				filtersApplied.Inc(1)
+				cases = cases.deactivate(i)
+				i--
+			}
+		}
+	}
 	for {
 		// Fast path: try sending without blocking before adding to the select set.
 		// This should usually succeed if subscribers are fast enough and have free
 		// buffer space.
 		for i := firstSubSendCase; i < len(cases); i++ {
 			if cases[i].Chan.TrySend(rvalue) {
+				delivered(cases[i])
 				nsent++
 				cases = cases.deactivate(i)
 				i--
@@ -165,16 +397,45 @@ func (f *Feed) Send(value interface{}) (nsent int) {
 		if len(cases) == firstSubSendCase {
 			break
 		}
-		// Select on all the receivers, waiting for them to unblock.
-		chosen, recv, _ := reflect.Select(cases)
-		if chosen == 0 /* <-f.removeSub */ {
+		// Don't wait for the remaining subscribers if the feed has a dead letter
+		// queue, their channels are full.
+		if dlq != nil {
+			f.deadLetter(dlq, value, cases[firstSubSendCase:])
+			break
+		}
+		// Select on all the receivers, waiting for them to unblock. The error channels
+		// of the blocked subscribers are watched too, in case they give up.
+		selected, blocked := cases, reporters[:0:0]
+		for _, sub := range reporters {
+			if index := cases.find(sub.channel.Interface()); index >= firstSubSendCase {
+				if len(blocked) == 0 {
+					selected = append(caseList(nil), cases...)
+				}
+				selected = append(selected, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.report)})
+				blocked = append(blocked, sub)
+			}
+		}
+		chosen, recv, recvOK := reflect.Select(selected)
+		switch {
+		case chosen == 0 /* <-f.removeSub */ :
 			index := f.sendCases.find(recv.Interface())
+			if index == -1 {
+				// Already dropped due to an error.
+				break
+			}
 			f.sendCases = f.sendCases.delete(index)
-			if index >= 0 && index < len(cases) {
+			if index < len(cases) {
 				// Shrink 'cases' too because the removed case was still active.
 				cases = f.sendCases[:len(cases)-1]
 			}
-		} else {
+		case chosen >= len(cases) /* <-sub.report */ :
+			var err error
+			if recvOK && !recv.IsNil() {
+				err = recv.Interface().(error)
+			}
+			cases = f.drop(blocked[chosen-len(cases)], err, cases)
+		default:
+			delivered(cases[chosen])
 			cases = cases.deactivate(chosen)
 			nsent++
 		}
@@ -191,6 +452,7 @@ func (f *Feed) Send(value interface{}) (nsent int) {
 type feedSub struct {
 	feed    *Feed
 	channel reflect.Value
+	report  chan error // error channel of the subscriber, if any
 	errOnce sync.Once
 	err     chan error
 }
@@ -17,11 +17,15 @@
 package event
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 func TestFeedPanics(t *testing.T) {
@@ -301,6 +305,142 @@ func TestFeedUnsubscribeFromInbox(t *testing.T) {
 	}
 }
 
+// Checks that a subscriber blocking a Send can bail out by reporting an error.
+func TestFeedSubscribeWithErrorBlocked(t *testing.T) {
+	var (
+		feed    Feed
+		healthy = make(chan int, 10)
+		stuck   = make(chan int)
+		errc    = make(chan error, 1)
+		failure = errors.New("can't keep up")
+	)
+	sub1 := feed.Subscribe(healthy)
+	defer sub1.Unsubscribe()
+	sub2 := feed.SubscribeWithError(stuck, errc)
+	defer sub2.Unsubscribe()
+
+	done := make(chan int)
+	go func() { done <- feed.Send(1) }()
+
+	// The send is blocked on the stuck subscriber until it reports an error
+	select {
+	case <-done:
+		t.Fatal("send didn't block on the stuck subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+	errc <- failure
+	select {
+	case nsent := <-done:
+		if nsent != 1 {
+			t.Errorf("wrong number of sends: have %d, want 1", nsent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("send still blocked after error reported")
+	}
+	if _, ok := <-stuck; ok {
+		t.Error("dropped subscriber's channel not closed")
+	}
+	if err := <-sub2.Err(); err != failure {
+		t.Errorf("wrong subscription error: have %v, want %v", err, failure)
+	}
+	// Further sends should skip the dropped subscriber
+	if nsent := feed.Send(2); nsent != 1 {
+		t.Errorf("wrong number of sends after drop: have %d, want 1", nsent)
+	}
+}
+
+// Checks that a subscriber with a pending error isn't sent any more events, and
+// that closing its error channel counts as an error too.
+func TestFeedSubscribeWithErrorPending(t *testing.T) {
+	var (
+		feed  Feed
+		ch1   = make(chan int, 10)
+		ch2   = make(chan int, 10)
+		errc1 = make(chan error, 1)
+		errc2 = make(chan error)
+	)
+	sub1 := feed.SubscribeWithError(ch1, errc1)
+	sub2 := feed.SubscribeWithError(ch2, errc2)
+
+	if nsent := feed.Send(1); nsent != 2 {
+		t.Fatalf("wrong number of sends: have %d, want 2", nsent)
+	}
+	errc1 <- errors.New("unhealthy")
+	close(errc2)
+
+	if nsent := feed.Send(2); nsent != 0 {
+		t.Fatalf("wrong number of sends after errors: have %d, want 0", nsent)
+	}
+	for i, ch := range []chan int{ch1, ch2} {
+		var got []int
+		for v := range ch {
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, []int{1}) {
+			t.Errorf("subscriber %d: wrong events: have %v, want [1]", i, got)
+		}
+	}
+	if err := <-sub2.Err(); err != ErrSubscriberDropped {
+		t.Errorf("wrong subscription error: have %v, want %v", err, ErrSubscriberDropped)
+	}
+	// Unsubscribing dropped subscriptions must not fail
+	sub1.Unsubscribe()
+	sub2.Unsubscribe()
+	if len(feed.sendCases) != 1 || len(feed.reporters) != 0 {
+		t.Errorf("subscriptions not cleaned up: %d send cases, %d reporters", len(feed.sendCases), len(feed.reporters))
+	}
+}
+
+func TestFeedSubscribeFiltered(t *testing.T) {
+	metrics.Enable()
+
+	var (
+		feed     Feed
+		all      = make(chan int, 10)
+		even     = make(chan int) // Unbuffered and never read for odd values
+		filtered = filtersApplied.Count()
+	)
+	feed.Subscribe(all)
+	sub := feed.SubscribeFiltered(even, func(v interface{}) bool { return v.(int)%2 == 0 })
+
+	var (
+		wg   sync.WaitGroup
+		got  []int
+		want = []int{0, 2, 4}
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range want {
+			got = append(got, <-even)
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		want := 1
+		if i%2 == 0 {
+			want = 2
+		}
+		if nsent := feed.Send(i); nsent != want {
+			t.Errorf("send %d: wrong number of sends: have %d, want %d", i, nsent, want)
+		}
+	}
+	wg.Wait()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong filtered events: have %v, want %v", got, want)
+	}
+	if len(all) != 5 {
+		t.Errorf("wrong number of unfiltered events: have %d, want 5", len(all))
+	}
+	if n := filtersApplied.Count() - filtered; n != 2 {
+		t.Errorf("wrong number of filtered events: have %d, want 2", n)
+	}
+	// Unsubscribing must remove the filter
+	sub.Unsubscribe()
+	if len(feed.filters) != 0 {
+		t.Errorf("filter not removed: %d left", len(feed.filters))
+	}
+}
+
 func BenchmarkFeedSend1000(b *testing.B) {
 	var (
 		done  sync.WaitGroup
@@ -331,3 +471,126 @@ func BenchmarkFeedSend1000(b *testing.B) {
 	b.StopTimer()
 	done.Wait()
 }
+
+func TestFeedDeadLetter(t *testing.T) {
+	var (
+		dlq  = make(chan DroppedEvent, 100)
+		feed = new(Feed).WithDeadLetter(dlq)
+		fast = make(chan int, 100)
+		slow = make(chan int, 10) // Never read, fills up after 10 values
+	)
+	defer feed.Subscribe(fast).Unsubscribe()
+	sub := feed.Subscribe(slow)
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 100; i++ {
+		want := 2
+		if i >= cap(slow) {
+			want = 1
+		}
+		if nsent := feed.Send(i); nsent != want {
+			t.Fatalf("send %d: wrong number of sends: have %d, want %d", i, nsent, want)
+		}
+	}
+	if len(fast) != 100 {
+		t.Errorf("wrong number of events delivered to fast subscriber: have %d, want 100", len(fast))
+	}
+	for len(fast) > 0 {
+		<-fast
+	}
+	if n := feed.DroppedCount(); n != 90 {
+		t.Errorf("wrong dropped count: have %d, want 90", n)
+	}
+	if len(dlq) != 90 {
+		t.Fatalf("wrong number of dead letters: have %d, want 90", len(dlq))
+	}
+	for i := cap(slow); i < 100; i++ {
+		ev := <-dlq
+		if ev.Event != i {
+			t.Errorf("dead letter %d: wrong event: have %v, want %d", i, ev.Event, i)
+		}
+		if ev.Sub != sub {
+			t.Errorf("dead letter %d: wrong subscription", i)
+		}
+	}
+	// Dead letters must be discarded if the queue is full, but still counted
+	for i := 0; i < cap(dlq)+1; i++ {
+		feed.Send(i)
+		<-fast
+	}
+	if len(dlq) != cap(dlq) {
+		t.Errorf("wrong number of dead letters: have %d, want %d", len(dlq), cap(dlq))
+	}
+	if n := feed.DroppedCount(); n != 90+uint64(cap(dlq))+1 {
+		t.Errorf("wrong dropped count: have %d, want %d", n, 90+cap(dlq)+1)
+	}
+}
+
+func TestFeedSubscribeWithMetrics(t *testing.T) {
+	var (
+		feed Feed
+		slow = make(chan int)       // Unbuffered, receives take 1ms of processing
+		fast = make(chan int, 1000) // Never blocks
+	)
+	slowSub, slowStats := feed.SubscribeWithMetrics(slow)
+	fastSub, fastStats := feed.SubscribeWithMetrics(fast)
+	defer fastSub.Unsubscribe()
+
+	const delay = time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			<-slow
+			time.Sleep(delay)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		feed.Send(i)
+	}
+	<-done
+
+	if n := atomic.LoadUint64(&slowStats.Delivered); n != 1000 {
+		t.Errorf("wrong number of deliveries to slow subscriber: have %d, want 1000", n)
+	}
+	if n := atomic.LoadUint64(&fastStats.Delivered); n != 1000 {
+		t.Errorf("wrong number of deliveries to fast subscriber: have %d, want 1000", n)
+	}
+	if p99 := atomic.LoadInt64(&slowStats.P99LatencyNs); p99 < int64(delay) {
+		t.Errorf("slow subscriber P99 latency too low: have %v, want >= %v", time.Duration(p99), delay)
+	}
+	if p50 := atomic.LoadInt64(&slowStats.P50LatencyNs); p50 < int64(delay)/2 {
+		t.Errorf("slow subscriber P50 latency too low: have %v, want >= %v", time.Duration(p50), delay/2)
+	}
+	// The fast subscriber must not be held up by the slow one. Compare against the
+	// slow subscriber instead of wall-clock time, which is unreliable under load.
+	fastP99, slowP50 := atomic.LoadInt64(&fastStats.P99LatencyNs), atomic.LoadInt64(&slowStats.P50LatencyNs)
+	if fastP99 > slowP50 {
+		t.Errorf("fast subscriber P99 latency above slow P50: have %v, want <= %v", time.Duration(fastP99), time.Duration(slowP50))
+	}
+	// Unsubscribing must stop tracking the subscription
+	slowSub.Unsubscribe()
+	if _, ok := feed.meters[slow]; ok {
+		t.Errorf("metrics of unsubscribed channel still tracked")
+	}
+}
+
+func TestFeedDeadLetterMetrics(t *testing.T) {
+	var (
+		dlq  = make(chan DroppedEvent, 10)
+		feed = new(Feed).WithDeadLetter(dlq)
+		ch   = make(chan int, 5)
+	)
+	sub, stats := feed.SubscribeWithMetrics(ch)
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 10; i++ {
+		feed.Send(i)
+	}
+	if n := atomic.LoadUint64(&stats.Delivered); n != 5 {
+		t.Errorf("wrong number of deliveries: have %d, want 5", n)
+	}
+	if n := atomic.LoadUint64(&stats.Dropped); n != 5 {
+		t.Errorf("wrong number of drops: have %d, want 5", n)
+	}
+}
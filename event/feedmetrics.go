@@ -0,0 +1,83 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencyWindow is the number of recent deliveries the latency percentiles
+	// of a subscription are computed over.
+	latencyWindow = 512
+
+	// latencyRefresh is the number of deliveries after which the latency
+	// percentiles are recomputed.
+	latencyRefresh = 16
+)
+
+// SubscriptionMetrics contains the delivery statistics of a subscription created
+// by Feed.SubscribeWithMetrics. The fields are updated by Send and must be read
+// atomically.
+type SubscriptionMetrics struct {
+	Delivered    uint64 // Number of values delivered to the channel
+	Dropped      uint64 // Number of values missed, see Feed.WithDeadLetter
+	P50LatencyNs int64  // Median time taken to deliver a value, in nanoseconds
+	P99LatencyNs int64  // 99th percentile of the time taken to deliver a value
+
+	// The latencies of the recent deliveries are only accessed by Send, which
+	// holds the send lock of the feed.
+	latencies [latencyWindow]int64 // Ring buffer of recent delivery latencies
+	recorded  uint64               // Number of latencies recorded
+	sorted    []int64              // Scratch space for computing the percentiles
+}
+
+// delivered records the delivery of a value which took the given time, updating
+// the latency percentiles periodically.
+//
+// note: callers must hold the send lock
+func (m *SubscriptionMetrics) delivered(latency time.Duration) {
+	atomic.AddUint64(&m.Delivered, 1)
+
+	m.latencies[m.recorded%latencyWindow] = int64(latency)
+	m.recorded++
+	if m.recorded <= latencyRefresh || m.recorded%latencyRefresh == 0 {
+		m.updatePercentiles()
+	}
+}
+
+// dropped records a value missed by the subscriber.
+func (m *SubscriptionMetrics) dropped() {
+	atomic.AddUint64(&m.Dropped, 1)
+}
+
+// updatePercentiles recomputes the latency percentiles over the recent deliveries.
+//
+// note: callers must hold the send lock
+func (m *SubscriptionMetrics) updatePercentiles() {
+	n := int(m.recorded)
+	if n > latencyWindow {
+		n = latencyWindow
+	}
+	m.sorted = append(m.sorted[:0], m.latencies[:n]...)
+	sort.Slice(m.sorted, func(i, j int) bool { return m.sorted[i] < m.sorted[j] })
+
+	atomic.StoreInt64(&m.P50LatencyNs, m.sorted[(n-1)*50/100])
+	atomic.StoreInt64(&m.P99LatencyNs, m.sorted[(n-1)*99/100])
+}
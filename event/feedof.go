@@ -0,0 +1,311 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// FeedOf implements one-to-many subscriptions where the carrier of events is a channel.
+// Values sent to a Feed are delivered to all subscribed channels simultaneously.
+//
+// It is the statically typed version of Feed: the element type of the subscribed
+// channels and the type of the sent values are checked at compile time instead of
+// causing panics. FeedOf[interface{}] accepts values of any type, but unlike Feed,
+// only channels of interface{} can subscribe to it.
+//
+// The zero value is ready to use.
+type FeedOf[T any] struct {
+	once      sync.Once     // ensures that init only runs once
+	sendLock  chan struct{} // sendLock has a one-element buffer and is empty when held.It protects sendCases.
+	removeSub chan chan<- T // interrupts Send
+	sendCases caseList      // the active set of select cases used by Send
+
+	// The inbox holds newly subscribed channels until they are added to sendCases.
+	mu      sync.Mutex
+	inbox   caseList
+	copying map[chan<- T]struct{} // channels receiving their own copy of every value
+}
+
+func (f *FeedOf[T]) init() {
+	f.removeSub = make(chan chan<- T)
+	f.sendLock = make(chan struct{}, 1)
+	f.sendLock <- struct{}{}
+	f.sendCases = caseList{{Chan: reflect.ValueOf(f.removeSub), Dir: reflect.SelectRecv}}
+}
+
+// Subscribe adds a channel to the feed. Future sends will be delivered on the channel
+// until the subscription is canceled.
+//
+// The channel should have ample buffer space to avoid blocking other subscribers.
+// Slow subscribers are not dropped.
+func (f *FeedOf[T]) Subscribe(channel chan<- T) Subscription {
+	return f.subscribe(channel, false)
+}
+
+// SubscribeCopying adds a channel to the feed like Subscribe, but every value is
+// copied before it's delivered on the channel, so that the subscriber may modify
+// it without affecting other subscribers. Values implementing Cloner[T] are copied
+// by their Clone method, others are copied by a JSON round-trip.
+//
+// If T doesn't implement Cloner[T] and has fields which wouldn't survive the JSON
+// round-trip, e.g. unexported ones, the subscription is refused: nothing is ever
+// delivered on the channel and the returned subscription fails with the error.
+func (f *FeedOf[T]) SubscribeCopying(channel chan<- T) Subscription {
+	return f.subscribe(channel, true)
+}
+
+func (f *FeedOf[T]) subscribe(channel chan<- T, copying bool) Subscription {
+	if copying {
+		if err := checkCopyable[T](); err != nil {
+			return NewSubscription(func(<-chan struct{}) error { return err })
+		}
+	}
+	f.once.Do(f.init)
+
+	sub := &feedOfSub[T]{feed: f, channel: channel, err: make(chan error, 1)}
+
+	// Add the select case to the inbox.
+	// The next Send will add it to f.sendCases.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cas := reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(channel)}
+	f.inbox = append(f.inbox, cas)
+	if copying {
+		if f.copying == nil {
+			f.copying = make(map[chan<- T]struct{})
+		}
+		f.copying[channel] = struct{}{}
+	}
+	return sub
+}
+
+func (f *FeedOf[T]) remove(sub *feedOfSub[T]) {
+	// Delete from inbox first, which covers channels
+	// that have not been added to f.sendCases yet.
+	f.mu.Lock()
+	delete(f.copying, sub.channel)
+	index := f.inbox.find(sub.channel)
+	if index != -1 {
+		f.inbox = f.inbox.delete(index)
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+
+	select {
+	case f.removeSub <- sub.channel:
+		// Send will remove the channel from f.sendCases.
+	case <-f.sendLock:
+		// No Send is in progress, delete the channel now that we have the send lock.
+		f.sendCases = f.sendCases.delete(f.sendCases.find(sub.channel))
+		f.sendLock <- struct{}{}
+	}
+}
+
+// Send delivers to all subscribed channels simultaneously.
+// It returns the number of subscribers that the value was sent to.
+func (f *FeedOf[T]) Send(value T) (nsent int) {
+	// Take the value through a pointer, so that its reflected type is T even if it's
+	// an interface type, and nil interface values can be sent too.
+	rvalue := reflect.ValueOf(&value).Elem()
+
+	f.once.Do(f.init)
+	<-f.sendLock
+
+	// Add new cases from the inbox after taking the send lock.
+	f.mu.Lock()
+	f.sendCases = append(f.sendCases, f.inbox...)
+	f.inbox = nil
+
+	// Set the sent value on all channels, copying it for those which asked for it.
+	for i := firstSubSendCase; i < len(f.sendCases); i++ {
+		f.sendCases[i].Send = rvalue
+		if len(f.copying) == 0 {
+			continue
+		}
+		if _, ok := f.copying[f.sendCases[i].Chan.Interface().(chan<- T)]; ok {
+			clone, err := cloneValue(value)
+			if err != nil {
+				f.mu.Unlock()
+				f.sendLock <- struct{}{}
+				panic(err)
+			}
+			f.sendCases[i].Send = reflect.ValueOf(&clone).Elem()
+		}
+	}
+	f.mu.Unlock()
+
+	// Send until all channels except removeSub have been chosen. 'cases' tracks a prefix
+	// of sendCases. When a send succeeds, the corresponding case moves to the end of
+	// 'cases' and it shrinks by one element.
+	cases := f.sendCases
+	for {
+		// Fast path: try sending without blocking before adding to the select set.
+		// This should usually succeed if subscribers are fast enough and have free
+		// buffer space.
+		for i := firstSubSendCase; i < len(cases); i++ {
+			if cases[i].Chan.TrySend(cases[i].Send) {
+				nsent++
+				cases = cases.deactivate(i)
+				i--
+			}
+		}
+		if len(cases) == firstSubSendCase {
+			break
+		}
+		// Select on all the receivers, waiting for them to unblock.
+		chosen, recv, _ := reflect.Select(cases)
+		if chosen == 0 /* <-f.removeSub */ {
+			index := f.sendCases.find(recv.Interface())
+			f.sendCases = f.sendCases.delete(index)
+			if index >= 0 && index < len(cases) {
+				// Shrink 'cases' too because the removed case was still active.
+				cases = f.sendCases[:len(cases)-1]
+			}
+		} else {
+			cases = cases.deactivate(chosen)
+			nsent++
+		}
+	}
+
+	// Forget about the sent value and hand off the send lock.
+	for i := firstSubSendCase; i < len(f.sendCases); i++ {
+		f.sendCases[i].Send = reflect.Value{}
+	}
+	f.sendLock <- struct{}{}
+	return nsent
+}
+
+// Cloner is implemented by values which can copy themselves for delivery to
+// copying subscribers, see FeedOf.SubscribeCopying.
+type Cloner[T any] interface {
+	Clone() T
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// checkCopyable returns an error if values of type T can't be copied by cloneValue
+// without losing data.
+func checkCopyable[T any]() error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Implements(reflect.TypeOf((*Cloner[T])(nil)).Elem()) {
+		return nil
+	}
+	if err := checkJSONCopyable(typ, make(map[reflect.Type]bool)); err != nil {
+		return fmt.Errorf("event: cannot copy %v: %v", typ, err)
+	}
+	return nil
+}
+
+// checkJSONCopyable returns an error if values of type typ don't survive a JSON
+// round-trip. Types with their own JSON or text encoding are trusted to encode
+// all of their data.
+func checkJSONCopyable(typ reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+
+	ptr := reflect.PtrTo(typ)
+	if ptr.Implements(jsonMarshalerType) && ptr.Implements(jsonUnmarshalerType) {
+		return nil
+	}
+	if ptr.Implements(textMarshalerType) && ptr.Implements(textUnmarshalerType) {
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkJSONCopyable(typ.Elem(), seen)
+
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+				return fmt.Errorf("unexported field %v.%s", typ, field.Name)
+			}
+			if field.Tag.Get("json") == "-" {
+				return fmt.Errorf("field %v.%s is not encoded", typ, field.Name)
+			}
+			if err := checkJSONCopyable(field.Type, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Interface, reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return fmt.Errorf("%v values can't be encoded", typ)
+	}
+	return nil
+}
+
+// cloneValue returns a copy of the given value sharing no memory with it.
+func cloneValue[T any](value T) (T, error) {
+	if cloner, ok := any(value).(Cloner[T]); ok {
+		return cloner.Clone(), nil
+	}
+	var clone T
+	blob, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(blob, &clone)
+	}
+	if err != nil {
+		return clone, fmt.Errorf("event: cannot copy %v: %v", reflect.TypeOf(&value).Elem(), err)
+	}
+	return clone, nil
+}
+
+// CopyingFeed is a FeedOf which copies every value for each subscriber, so that
+// subscribers can modify the received values without affecting each other. The
+// values are copied like for FeedOf.SubscribeCopying.
+//
+// The zero value is ready to use.
+type CopyingFeed[T any] struct {
+	FeedOf[T]
+}
+
+// Subscribe adds a channel to the feed, delivering a copy of every value on it.
+func (f *CopyingFeed[T]) Subscribe(channel chan<- T) Subscription {
+	return f.FeedOf.SubscribeCopying(channel)
+}
+
+type feedOfSub[T any] struct {
+	feed    *FeedOf[T]
+	channel chan<- T
+	errOnce sync.Once
+	err     chan error
+}
+
+func (sub *feedOfSub[T]) Unsubscribe() {
+	sub.errOnce.Do(func() {
+		sub.feed.remove(sub)
+		close(sub.err)
+	})
+}
+
+func (sub *feedOfSub[T]) Err() <-chan error {
+	return sub.err
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeedOf(t *testing.T) {
+	var feed FeedOf[int]
+	var done, subscribed sync.WaitGroup
+	subscriber := func(i int) {
+		defer done.Done()
+
+		subchan := make(chan int)
+		sub := feed.Subscribe(subchan)
+		timeout := time.NewTimer(2 * time.Second)
+		subscribed.Done()
+
+		select {
+		case v := <-subchan:
+			if v != 1 {
+				t.Errorf("%d: received value %d, want 1", i, v)
+			}
+		case <-timeout.C:
+			t.Errorf("%d: receive timeout", i)
+		}
+
+		sub.Unsubscribe()
+		select {
+		case _, ok := <-sub.Err():
+			if ok {
+				t.Errorf("%d: error channel not closed after unsubscribe", i)
+			}
+		case <-timeout.C:
+			t.Errorf("%d: unsubscribe timeout", i)
+		}
+	}
+
+	const n = 1000
+	done.Add(n)
+	subscribed.Add(n)
+	for i := 0; i < n; i++ {
+		go subscriber(i)
+	}
+	subscribed.Wait()
+	if nsent := feed.Send(1); nsent != n {
+		t.Errorf("first send delivered %d times, want %d", nsent, n)
+	}
+	if nsent := feed.Send(2); nsent != 0 {
+		t.Errorf("second send delivered %d times, want 0", nsent)
+	}
+	done.Wait()
+}
+
+func TestFeedOfUnsubscribeBlockedPost(t *testing.T) {
+	var (
+		feed   FeedOf[int]
+		nsends = 200
+		chans  = make([]chan int, 2000)
+		subs   = make([]Subscription, len(chans))
+		bchan  = make(chan int)
+		bsub   = feed.Subscribe(bchan)
+		wg     sync.WaitGroup
+	)
+	for i := range chans {
+		chans[i] = make(chan int, nsends)
+	}
+
+	// Queue up some Sends. None of these can make progress while bchan isn't read.
+	wg.Add(nsends)
+	for i := 0; i < nsends; i++ {
+		go func() {
+			feed.Send(99)
+			wg.Done()
+		}()
+	}
+	// Subscribe the other channels.
+	for i, ch := range chans {
+		subs[i] = feed.Subscribe(ch)
+	}
+	// Unsubscribe them again.
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	// Unblock the Sends.
+	bsub.Unsubscribe()
+	wg.Wait()
+}
+
+// Checks that a feed of an interface type delivers values of any dynamic type,
+// including nil, like the untyped Feed does.
+func TestFeedOfInterface(t *testing.T) {
+	var (
+		feed FeedOf[interface{}]
+		ch   = make(chan interface{}, 3)
+		sub  = feed.Subscribe(ch)
+	)
+	defer sub.Unsubscribe()
+
+	for _, value := range []interface{}{1, "two", nil} {
+		if nsent := feed.Send(value); nsent != 1 {
+			t.Fatalf("send of %v delivered %d times, want 1", value, nsent)
+		}
+		if have := <-ch; have != value {
+			t.Errorf("received value mismatch: have %v, want %v", have, value)
+		}
+	}
+}
+
+type copyTestEvent struct {
+	Values []int
+	Names  map[string]int
+}
+
+type clonedTestEvent struct {
+	values []int
+	clones *int
+}
+
+func (ev *clonedTestEvent) Clone() *clonedTestEvent {
+	*ev.clones++
+	return &clonedTestEvent{values: append([]int(nil), ev.values...), clones: ev.clones}
+}
+
+func TestFeedOfSubscribeCopying(t *testing.T) {
+	var (
+		feed FeedOf[*copyTestEvent]
+		ch1  = make(chan *copyTestEvent, 1)
+		ch2  = make(chan *copyTestEvent, 1)
+		ch3  = make(chan *copyTestEvent, 1)
+	)
+	feed.SubscribeCopying(ch1)
+	feed.SubscribeCopying(ch2)
+	sub := feed.Subscribe(ch3)
+	defer sub.Unsubscribe()
+
+	ev := &copyTestEvent{Values: []int{1, 2}, Names: map[string]int{"a": 1}}
+	if nsent := feed.Send(ev); nsent != 3 {
+		t.Fatalf("send delivered %d times, want 3", nsent)
+	}
+	ev1, ev2 := <-ch1, <-ch2
+	ev1.Values[0], ev1.Names["a"] = 10, 10
+	ev2.Values[0], ev2.Names["a"] = 20, 20
+
+	if ev1.Values[0] != 10 || ev1.Names["a"] != 10 {
+		t.Errorf("first subscriber sees foreign mutation: %v", ev1)
+	}
+	if ev2.Values[0] != 20 || ev2.Names["a"] != 20 {
+		t.Errorf("second subscriber sees foreign mutation: %v", ev2)
+	}
+	if ev.Values[0] != 1 || ev.Names["a"] != 1 {
+		t.Errorf("sent event mutated by subscribers: %v", ev)
+	}
+	if <-ch3 != ev {
+		t.Errorf("non-copying subscriber received a copy")
+	}
+}
+
+func TestCopyingFeedClone(t *testing.T) {
+	var (
+		feed   CopyingFeed[*clonedTestEvent]
+		ch1    = make(chan *clonedTestEvent, 1)
+		ch2    = make(chan *clonedTestEvent, 1)
+		clones int
+	)
+	sub1 := feed.Subscribe(ch1)
+	feed.Subscribe(ch2)
+
+	ev := &clonedTestEvent{values: []int{1}, clones: &clones}
+	if nsent := feed.Send(ev); nsent != 2 {
+		t.Fatalf("send delivered %d times, want 2", nsent)
+	}
+	ev1, ev2 := <-ch1, <-ch2
+	ev1.values[0] = 10
+	if ev2.values[0] != 1 || ev.values[0] != 1 {
+		t.Errorf("mutation visible to others: sent %v, received %v", ev.values, ev2.values)
+	}
+	if clones != 2 {
+		t.Errorf("clone count mismatch: have %d, want 2", clones)
+	}
+	// Unsubscribed channels must not be copied for anymore
+	sub1.Unsubscribe()
+	if nsent := feed.Send(ev); nsent != 1 {
+		t.Fatalf("send delivered %d times, want 1", nsent)
+	}
+	<-ch2
+	if clones != 3 {
+		t.Errorf("clone count mismatch: have %d, want 3", clones)
+	}
+}
+
+type lossyTestEvent struct {
+	Value  int
+	hidden []int
+}
+
+func TestFeedOfSubscribeCopyingRefused(t *testing.T) {
+	var (
+		feed FeedOf[*lossyTestEvent]
+		ch   = make(chan *lossyTestEvent, 1)
+	)
+	sub := feed.SubscribeCopying(ch)
+	if err := <-sub.Err(); err == nil {
+		t.Fatal("subscription accepted for value with unexported fields")
+	}
+	if nsent := feed.Send(&lossyTestEvent{Value: 1, hidden: []int{1}}); nsent != 0 {
+		t.Fatalf("send delivered %d times, want 0", nsent)
+	}
+}
+
+func BenchmarkFeedOfSend1000(b *testing.B) {
+	var (
+		done  sync.WaitGroup
+		feed  FeedOf[int]
+		nsubs = 1000
+	)
+	subscriber := func(ch <-chan int) {
+		for i := 0; i < b.N; i++ {
+			<-ch
+		}
+		done.Done()
+	}
+	done.Add(nsubs)
+	for i := 0; i < nsubs; i++ {
+		ch := make(chan int, 200)
+		feed.Subscribe(ch)
+		go subscriber(ch)
+	}
+
+	// The actual benchmark.
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if feed.Send(i) != nsubs {
+			panic("wrong number of sends")
+		}
+	}
+
+	b.StopTimer()
+	done.Wait()
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// persistentFeedPrefix is the database key prefix of the persistent feeds. The
+// key of an event is persistentFeedPrefix + name + "-" + seq (uint64 big endian).
+var persistentFeedPrefix = []byte("event-")
+
+// ErrEventsPruned is returned when replaying events which are no longer retained
+// by a persistent feed.
+var ErrEventsPruned = errors.New("events pruned")
+
+// PersistentFeed implements one-to-many subscriptions like FeedOf, but every
+// value is stored in a database before it is delivered, so that subscribers can
+// catch up with the values sent while they were down. The stored values are
+// numbered by a sequence starting at zero, and only the most recent ones are
+// retained.
+//
+// Values are encoded with RLP, so T must be RLP-serializable.
+type PersistentFeed[T any] struct {
+	db     ethdb.KeyValueStore
+	prefix []byte // Key prefix of the events of the feed
+	retain uint64 // Number of recent events retained
+
+	feed FeedOf[T]
+
+	// The lock is held by Send and by subscriptions replaying the stored events,
+	// so that no value is missed or delivered twice on the transition between
+	// the replay and live delivery.
+	mu   sync.Mutex
+	tail uint64 // Sequence number of the oldest retained event
+	head uint64 // Sequence number of the next event
+}
+
+// NewPersistentFeed creates a feed storing its values in the given database under
+// the given name, retaining the last retain values. Previously stored values of
+// the feed are loaded, so that the sequence numbers continue where they left off.
+func NewPersistentFeed[T any](db ethdb.KeyValueStore, name string, retain uint64) (*PersistentFeed[T], error) {
+	if retain == 0 {
+		return nil, errors.New("event: persistent feed must retain events")
+	}
+	f := &PersistentFeed[T]{
+		db:     db,
+		prefix: append(append(append([]byte{}, persistentFeedPrefix...), name...), '-'),
+		retain: retain,
+	}
+	// Find the range of the stored events, skipping the ones of other feeds
+	// whose name starts with the name of this one.
+	it := db.NewIteratorWithPrefix(f.prefix)
+	defer it.Release()
+
+	first := true
+	for it.Next() {
+		if len(it.Key()) != len(f.prefix)+8 {
+			continue
+		}
+		seq := binary.BigEndian.Uint64(it.Key()[len(f.prefix):])
+		if first {
+			f.tail, first = seq, false
+		}
+		f.head = seq + 1
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// eventKey returns the database key of the event with the given sequence number.
+func (f *PersistentFeed[T]) eventKey(seq uint64) []byte {
+	key := make([]byte, len(f.prefix)+8)
+	copy(key, f.prefix)
+	binary.BigEndian.PutUint64(key[len(f.prefix):], seq)
+	return key
+}
+
+// Head returns the sequence number the next value sent will be stored under.
+func (f *PersistentFeed[T]) Head() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return int64(f.head)
+}
+
+// Send stores a value and delivers it to all subscribed channels, returning its
+// sequence number. The value isn't delivered if it can't be stored.
+func (f *PersistentFeed[T]) Send(value T) (int64, error) {
+	blob, err := rlp.EncodeToBytes(value)
+	if err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Store the value, pruning the events beyond the retention limit
+	batch := f.db.NewBatch()
+	if err := batch.Put(f.eventKey(f.head), blob); err != nil {
+		return 0, err
+	}
+	tail := f.tail
+	for ; f.head+1-tail > f.retain; tail++ {
+		if err := batch.Delete(f.eventKey(tail)); err != nil {
+			return 0, err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return 0, err
+	}
+	seq := f.head
+	f.head, f.tail = f.head+1, tail
+
+	f.feed.Send(value)
+	return int64(seq), nil
+}
+
+// Subscribe adds a channel to the feed. The stored values with a sequence number
+// of at least since are delivered first, followed by all future values until
+// the subscription is canceled. A negative since subscribes to future values
+// only. ErrEventsPruned is returned if values requested were already pruned.
+//
+// Sends are blocked while the stored values are delivered, so the channel should
+// have ample buffer space, or be drained by another goroutine.
+func (f *PersistentFeed[T]) Subscribe(channel chan<- T, since int64) (Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if since >= 0 {
+		if err := f.replay(uint64(since), channel); err != nil {
+			return nil, err
+		}
+	}
+	return f.feed.Subscribe(channel), nil
+}
+
+// Replay synchronously delivers the stored values with a sequence number of at
+// least since on the channel, and then subscribes it to the future values. It
+// is a shorthand for Subscribe for subscribers which never unsubscribe, e.g.
+// watchers living as long as the feed.
+func (f *PersistentFeed[T]) Replay(since int64, ch chan<- T) error {
+	if since < 0 {
+		return fmt.Errorf("event: invalid replay start %d", since)
+	}
+	_, err := f.Subscribe(ch, since)
+	return err
+}
+
+// replay delivers the stored values starting at the given sequence number. It
+// must be called with the lock held.
+func (f *PersistentFeed[T]) replay(since uint64, channel chan<- T) error {
+	if since < f.tail {
+		return fmt.Errorf("%w: requested %d, oldest retained %d", ErrEventsPruned, since, f.tail)
+	}
+	for seq := since; seq < f.head; seq++ {
+		blob, err := f.db.Get(f.eventKey(seq))
+		if err != nil {
+			return err
+		}
+		var value T
+		if err := rlp.DecodeBytes(blob, &value); err != nil {
+			return err
+		}
+		channel <- value
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+type persistentTestEvent struct {
+	Number uint64
+	Note   string
+}
+
+// Tests that a subscriber restarting from its checkpoint receives all the events
+// sent while it was down, across restarts of the feed itself.
+func TestPersistentFeedRestart(t *testing.T) {
+	db := memorydb.New()
+	feed, err := NewPersistentFeed[persistentTestEvent](db, "deposits", 100)
+	if err != nil {
+		t.Fatalf("failed to create feed: %v", err)
+	}
+	// Run the subscriber for a few events, keeping track of its checkpoint
+	ch := make(chan persistentTestEvent, 100)
+	sub, err := feed.Subscribe(ch, -1)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	checkpoint := int64(-1)
+	for i := uint64(0); i < 5; i++ {
+		seq, err := feed.Send(persistentTestEvent{Number: i, Note: "live"})
+		if err != nil {
+			t.Fatalf("failed to send event %d: %v", i, err)
+		}
+		if ev := <-ch; ev.Number != i {
+			t.Fatalf("event mismatch: have %d, want %d", ev.Number, i)
+		}
+		checkpoint = seq
+	}
+	sub.Unsubscribe()
+
+	// Send events while the subscriber is down, and restart the feed
+	for i := uint64(5); i < 10; i++ {
+		if _, err := feed.Send(persistentTestEvent{Number: i, Note: "missed"}); err != nil {
+			t.Fatalf("failed to send event %d: %v", i, err)
+		}
+	}
+	feed, err = NewPersistentFeed[persistentTestEvent](db, "deposits", 100)
+	if err != nil {
+		t.Fatalf("failed to reopen feed: %v", err)
+	}
+	if head := feed.Head(); head != 10 {
+		t.Fatalf("reopened feed head mismatch: have %d, want 10", head)
+	}
+	// Restart the subscriber from its checkpoint, and check that it gets the
+	// missed events followed by the live ones
+	ch = make(chan persistentTestEvent, 100)
+	sub, err = feed.Subscribe(ch, checkpoint+1)
+	if err != nil {
+		t.Fatalf("failed to resubscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := uint64(10); i < 15; i++ {
+		if _, err := feed.Send(persistentTestEvent{Number: i, Note: "live"}); err != nil {
+			t.Fatalf("failed to send event %d: %v", i, err)
+		}
+	}
+	for i := uint64(5); i < 15; i++ {
+		if ev := <-ch; ev.Number != i {
+			t.Fatalf("event mismatch: have %d, want %d", ev.Number, i)
+		}
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event %d delivered", ev.Number)
+	default:
+	}
+}
+
+// Tests that only the most recent events are retained.
+func TestPersistentFeedRetention(t *testing.T) {
+	db := memorydb.New()
+	feed, err := NewPersistentFeed[uint64](db, "blocks", 5)
+	if err != nil {
+		t.Fatalf("failed to create feed: %v", err)
+	}
+	// Feeds sharing the prefix of the name must not interfere
+	other, err := NewPersistentFeed[uint64](db, "blocks-other", 5)
+	if err != nil {
+		t.Fatalf("failed to create feed: %v", err)
+	}
+	for i := uint64(0); i < 12; i++ {
+		if _, err := feed.Send(i); err != nil {
+			t.Fatalf("failed to send event %d: %v", i, err)
+		}
+		if _, err := other.Send(100 + i); err != nil {
+			t.Fatalf("failed to send event %d: %v", i, err)
+		}
+	}
+	if n := db.Len(); n != 10 {
+		t.Errorf("stored event count mismatch: have %d, want 10", n)
+	}
+	feed, err = NewPersistentFeed[uint64](db, "blocks", 5)
+	if err != nil {
+		t.Fatalf("failed to reopen feed: %v", err)
+	}
+	// Replaying pruned events fails, replaying retained ones succeeds
+	ch := make(chan uint64, 100)
+	if err := feed.Replay(6, ch); !errors.Is(err, ErrEventsPruned) {
+		t.Fatalf("pruned replay error mismatch: have %v, want %v", err, ErrEventsPruned)
+	}
+	if err := feed.Replay(7, ch); err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	if _, err := feed.Send(12); err != nil {
+		t.Fatalf("failed to send event: %v", err)
+	}
+	for i := uint64(7); i <= 12; i++ {
+		if have := <-ch; have != i {
+			t.Fatalf("event mismatch: have %d, want %d", have, i)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// PriorityFeed implements one-to-many subscriptions like FeedOf, but values are
+// queued and delivered asynchronously, with high priority values bypassing the
+// queued normal ones. A high priority value is delivered as soon as the value
+// being delivered at the time it was sent has been received by all subscribers.
+// Values of the same priority are delivered in the order they were sent.
+//
+// The queues are unbounded, so slow subscribers delay deliveries but never block
+// senders. The dispatching goroutine only runs while values are queued.
+//
+// The zero value is ready to use.
+type PriorityFeed[T any] struct {
+	feed FeedOf[T]
+
+	mu      sync.Mutex
+	high    []*priorityItem[T]
+	normal  []T
+	running bool // Whether the dispatcher goroutine is running
+}
+
+// priorityItem is a queued high priority value.
+type priorityItem[T any] struct {
+	value   T
+	started chan struct{} // Closed when the delivery of the value begins
+}
+
+// Subscribe adds a channel to the feed. Future values will be delivered on the
+// channel until the subscription is canceled.
+//
+// The channel should have ample buffer space to avoid delaying other subscribers.
+func (f *PriorityFeed[T]) Subscribe(channel chan<- T) Subscription {
+	return f.feed.Subscribe(channel)
+}
+
+// SendNormal queues a value for delivery after all the values queued before.
+func (f *PriorityFeed[T]) SendNormal(value T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.normal = append(f.normal, value)
+	f.dispatch()
+}
+
+// SendHigh queues a value for delivery before all the normal priority values.
+func (f *PriorityFeed[T]) SendHigh(value T) {
+	f.sendHigh(value)
+}
+
+// SendHighTimeout queues a value like SendHigh, but withdraws it if its delivery
+// doesn't begin within the given timeout, returning false in that case.
+func (f *PriorityFeed[T]) SendHighTimeout(value T, timeout time.Duration) bool {
+	item := f.sendHigh(value)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-item.started:
+		return true
+	case <-timer.C:
+	}
+	// Withdraw the value, unless the dispatcher picked it up in the meantime
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, queued := range f.high {
+		if queued == item {
+			f.high = append(f.high[:i], f.high[i+1:]...)
+			return false
+		}
+	}
+	return true
+}
+
+func (f *PriorityFeed[T]) sendHigh(value T) *priorityItem[T] {
+	item := &priorityItem[T]{value: value, started: make(chan struct{})}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.high = append(f.high, item)
+	f.dispatch()
+	return item
+}
+
+// dispatch starts the dispatcher goroutine if it's not running yet. It must be
+// called with the lock held.
+func (f *PriorityFeed[T]) dispatch() {
+	if !f.running {
+		f.running = true
+		go f.loop()
+	}
+}
+
+// loop delivers the queued values, high priority ones first, until both queues
+// are empty.
+func (f *PriorityFeed[T]) loop() {
+	for {
+		f.mu.Lock()
+		var value T
+		switch {
+		case len(f.high) > 0:
+			item := f.high[0]
+			f.high[0] = nil
+			f.high = f.high[1:]
+			close(item.started)
+			value = item.value
+
+		case len(f.normal) > 0:
+			var zero T
+			value = f.normal[0]
+			f.normal[0] = zero
+			f.normal = f.normal[1:]
+
+		default:
+			f.running = false
+			f.mu.Unlock()
+			return
+		}
+		f.mu.Unlock()
+
+		f.feed.Send(value)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPriorityFeed(t *testing.T) {
+	var (
+		feed PriorityFeed[int]
+		ch   = make(chan int)
+		sub  = feed.Subscribe(ch)
+	)
+	defer sub.Unsubscribe()
+
+	// Queue up normal events while the subscriber isn't reading, then a high
+	// priority one. Only the event already in delivery may precede it.
+	for i := 0; i < 100; i++ {
+		feed.SendNormal(i)
+	}
+	feed.SendHigh(-1)
+
+	var received []int
+	for len(received) < 101 {
+		select {
+		case ev := <-ch:
+			received = append(received, ev)
+			time.Sleep(time.Millisecond) // Simulate a slow subscriber
+		case <-time.After(time.Second):
+			t.Fatalf("receive timeout after %d events", len(received))
+		}
+	}
+	high := -1
+	for i, ev := range received {
+		if ev == -1 {
+			high = i
+		}
+	}
+	if high == -1 || high > 1 {
+		t.Fatalf("high priority event delivered at position %d: %v", high, received[:3])
+	}
+	// The normal events must be delivered in order
+	normal := append(append([]int(nil), received[:high]...), received[high+1:]...)
+	for i, ev := range normal {
+		if ev != i {
+			t.Fatalf("normal event %d mismatch: have %d", i, ev)
+		}
+	}
+}
+
+func TestPriorityFeedSendHighTimeout(t *testing.T) {
+	var (
+		feed PriorityFeed[int]
+		ch   = make(chan int)
+		sub  = feed.Subscribe(ch)
+	)
+	defer sub.Unsubscribe()
+
+	// Block the dispatcher on a delivery nobody receives
+	feed.SendNormal(1)
+	for {
+		feed.mu.Lock()
+		queued := len(feed.normal)
+		feed.mu.Unlock()
+		if queued == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if feed.SendHighTimeout(2, 20*time.Millisecond) {
+		t.Fatalf("high priority delivery began while the dispatcher was blocked")
+	}
+	// Once the subscriber reads, the withdrawn event must not be delivered
+	// and a new one should begin delivery in time
+	received := make(chan []int)
+	go func() {
+		var values []int
+		for value := range ch {
+			values = append(values, value)
+			if len(values) == 1 {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		received <- values
+	}()
+	if !feed.SendHighTimeout(3, time.Second) {
+		t.Fatalf("high priority delivery didn't begin after the subscriber read")
+	}
+	// Wait for the dispatcher to drain the queues before closing the channel
+	for {
+		feed.mu.Lock()
+		running := feed.running
+		feed.mu.Unlock()
+		if !running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sub.Unsubscribe()
+	close(ch)
+
+	if values := <-received; !reflect.DeepEqual(values, []int{1, 3}) {
+		t.Errorf("delivered values mismatch: have %v, want %v", values, []int{1, 3})
+	}
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	if len(feed.high) != 0 {
+		t.Errorf("withdrawn event still queued: %d", len(feed.high))
+	}
+}
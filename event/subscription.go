@@ -273,3 +273,48 @@ func (s *scopeSub) Unsubscribe() {
 func (s *scopeSub) Err() <-chan error {
 	return s.s.Err()
 }
+
+// drainTimeout is how long UnsubscribeAndDrain waits for further buffered events
+// before considering the channel empty.
+const drainTimeout = 10 * time.Millisecond
+
+// DrainableSubscription wraps a subscription delivering events of type T into a
+// channel, so that the events still buffered in the channel can be retrieved when
+// unsubscribing instead of being discarded.
+type DrainableSubscription[T any] struct {
+	Subscription
+	channel <-chan T
+}
+
+// NewDrainableSubscription wraps a subscription delivering its events into the
+// given channel. The channel must not be read by anyone else while draining.
+func NewDrainableSubscription[T any](sub Subscription, channel <-chan T) *DrainableSubscription[T] {
+	return &DrainableSubscription[T]{Subscription: sub, channel: channel}
+}
+
+// UnsubscribeAndDrain cancels the sending of events and returns all the events
+// buffered in the channel, in the order they were delivered. The channel is read
+// until no event arrives for a short while, or until it's closed.
+func (s *DrainableSubscription[T]) UnsubscribeAndDrain() []T {
+	s.Unsubscribe()
+
+	var events []T
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case ev, ok := <-s.channel:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(drainTimeout)
+		case <-timer.C:
+			return events
+		}
+	}
+}
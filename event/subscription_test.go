@@ -118,3 +118,31 @@ func TestResubscribeAbort(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUnsubscribeAndDrain(t *testing.T) {
+	var (
+		feed FeedOf[int]
+		ch   = make(chan int, 20)
+		sub  = NewDrainableSubscription[int](feed.Subscribe(ch), ch)
+	)
+	for i := 0; i < 10; i++ {
+		if nsent := feed.Send(i); nsent != 1 {
+			t.Fatalf("send %d delivered %d times, want 1", i, nsent)
+		}
+	}
+	events := sub.UnsubscribeAndDrain()
+	if len(events) != 10 {
+		t.Fatalf("drained %d events, want 10", len(events))
+	}
+	for i, ev := range events {
+		if ev != i {
+			t.Errorf("event %d mismatch: have %d, want %d", i, ev, i)
+		}
+	}
+	if _, ok := <-sub.Err(); ok {
+		t.Errorf("error channel not closed after drain")
+	}
+	if nsent := feed.Send(10); nsent != 0 {
+		t.Errorf("send after drain delivered %d times, want 0", nsent)
+	}
+}
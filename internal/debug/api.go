@@ -24,17 +24,21 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"math"
 	"os"
 	"os/user"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 // Handler is the global debugging handler.
@@ -49,10 +53,11 @@ type HandlerT struct {
 	cpuFile   string
 	traceW    io.WriteCloser
 	traceFile string
+	profiler  *continuousProfiler
 }
 
-// Verbosity sets the log verbosity ceiling. The verbosity of individual packages
-// and source files can be raised using Vmodule.
+// Verbosity sets the log verbosity ceiling. The verbosity of individual packages,
+// source files and modules can be raised using Vmodule.
 func (*HandlerT) Verbosity(level int) {
 	glogger.Verbosity(log.Lvl(level))
 }
@@ -207,6 +212,203 @@ func (*HandlerT) SetGCPercent(v int) int {
 	return debug.SetGCPercent(v)
 }
 
+// MetricSnapshot is the point-in-time state of a single metric.
+type MetricSnapshot struct {
+	Name   string                 `json:"name"`
+	Type   string                 `json:"type"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// MetricsSnapshot is the point-in-time state of all registered metrics.
+type MetricsSnapshot struct {
+	Time    time.Time        `json:"time"`
+	Metrics []MetricSnapshot `json:"metrics"`
+}
+
+// MetricsSnapshot returns the current values of all metrics in the default
+// registry, sorted by name.
+func (*HandlerT) MetricsSnapshot() *MetricsSnapshot {
+	snapshot := &MetricsSnapshot{Time: time.Now(), Metrics: []MetricSnapshot{}}
+	metrics.DefaultRegistry.Each(func(name string, i interface{}) {
+		if kind, values := metricValues(i); values != nil {
+			snapshot.Metrics = append(snapshot.Metrics, MetricSnapshot{Name: name, Type: kind, Values: values})
+		}
+	})
+	sort.Slice(snapshot.Metrics, func(i, j int) bool { return snapshot.Metrics[i].Name < snapshot.Metrics[j].Name })
+	return snapshot
+}
+
+// Metrics returns the current values of all metrics in the default registry as a
+// nested object, grouped by the slash separated components of the metric names.
+// Unless raw values are requested, the values are formatted for human reading.
+func (*HandlerT) Metrics(raw bool) map[string]interface{} {
+	var names []string
+	all := make(map[string]interface{})
+	metrics.DefaultRegistry.Each(func(name string, i interface{}) {
+		names = append(names, name)
+		all[name] = i
+	})
+	// Insert the metrics in order, so a metric named like the group of another one
+	// is always met first and the resulting object is deterministic.
+	sort.Strings(names)
+
+	root := make(map[string]interface{})
+	for _, name := range names {
+		kind, values := metricValues(all[name])
+		if values == nil {
+			continue
+		}
+		if !raw {
+			values = formatMetricValues(kind, values)
+		}
+		group, parts := root, strings.Split(name, "/")
+		for len(parts) > 1 {
+			sub, exists := group[parts[0]]
+			if !exists {
+				sub = make(map[string]interface{})
+				group[parts[0]] = sub
+			}
+			subgroup, ok := sub.(map[string]interface{})
+			if !ok {
+				// Name taken by a metric, store the rest of the path flat
+				break
+			}
+			group, parts = subgroup, parts[1:]
+		}
+		group[strings.Join(parts, "/")] = values
+	}
+	return root
+}
+
+// ListMetrics returns the sorted names of all metrics in the default registry
+// starting with the given prefix.
+func (*HandlerT) ListMetrics(prefix string) []string {
+	names := []string{}
+	metrics.DefaultRegistry.Each(func(name string, _ interface{}) {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// formatMetricValues converts the values of a metric into human readable strings,
+// scaling large numbers, suffixing rates and formatting the statistics of timers
+// as durations.
+func formatMetricValues(kind string, values map[string]interface{}) map[string]interface{} {
+	formatted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		var (
+			number float64
+			prec   = 2
+		)
+		switch value := value.(type) {
+		case int:
+			number, prec = float64(value), 0
+		case int64:
+			number, prec = float64(value), 0
+		case float64:
+			number = value
+		default:
+			formatted[key] = value
+			continue
+		}
+		switch {
+		case strings.HasSuffix(key, ".rate"):
+			formatted[key] = formatUnits(number, 2) + "/s"
+		case key == "count":
+			formatted[key] = formatUnits(number, 0)
+		case kind == "timer" || kind == "resettingtimer":
+			formatted[key] = time.Duration(number).String()
+		default:
+			formatted[key] = formatUnits(number, prec)
+		}
+	}
+	return formatted
+}
+
+// formatUnits formats a number with the given precision, scaling large values to
+// a metric unit prefix.
+func formatUnits(value float64, prec int) string {
+	units := []string{"", "K", "M", "G", "T", "P", "E"}
+
+	unit := 0
+	for math.Abs(value) >= 1000 && unit < len(units)-1 {
+		unit, value, prec = unit+1, value/1000, 2
+	}
+	return strconv.FormatFloat(value, 'f', prec, 64) + units[unit]
+}
+
+// metricValues returns the type name and the current values of a metric, or nil
+// values for unknown metric types.
+func metricValues(i interface{}) (string, map[string]interface{}) {
+	quantiles := []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+	switch metric := i.(type) {
+	case metrics.Counter:
+		return "counter", map[string]interface{}{"count": metric.Count()}
+	case metrics.Gauge:
+		return "gauge", map[string]interface{}{"value": metric.Value()}
+	case metrics.GaugeFloat64:
+		return "gauge", map[string]interface{}{"value": metric.Value()}
+	case metrics.Meter:
+		m := metric.Snapshot()
+		return "meter", map[string]interface{}{
+			"count":     m.Count(),
+			"1m.rate":   m.Rate1(),
+			"5m.rate":   m.Rate5(),
+			"15m.rate":  m.Rate15(),
+			"mean.rate": m.RateMean(),
+		}
+	case metrics.Histogram:
+		h := metric.Snapshot()
+		ps := h.Percentiles(quantiles)
+		return "histogram", map[string]interface{}{
+			"count":  h.Count(),
+			"min":    h.Min(),
+			"max":    h.Max(),
+			"mean":   h.Mean(),
+			"stddev": h.StdDev(),
+			"median": ps[0],
+			"75%":    ps[1],
+			"95%":    ps[2],
+			"99%":    ps[3],
+			"99.9%":  ps[4],
+		}
+	case metrics.Timer:
+		t := metric.Snapshot()
+		ps := t.Percentiles(quantiles)
+		return "timer", map[string]interface{}{
+			"count":     t.Count(),
+			"min":       t.Min(),
+			"max":       t.Max(),
+			"mean":      t.Mean(),
+			"stddev":    t.StdDev(),
+			"median":    ps[0],
+			"75%":       ps[1],
+			"95%":       ps[2],
+			"99%":       ps[3],
+			"99.9%":     ps[4],
+			"1m.rate":   t.Rate1(),
+			"5m.rate":   t.Rate5(),
+			"15m.rate":  t.Rate15(),
+			"mean.rate": t.RateMean(),
+		}
+	case metrics.ResettingTimer:
+		t := metric.Snapshot()
+		ps := t.Percentiles([]float64{50, 95, 99})
+		return "resettingtimer", map[string]interface{}{
+			"count":  len(t.Values()),
+			"mean":   t.Mean(),
+			"median": ps[0],
+			"95%":    ps[1],
+			"99%":    ps[2],
+		}
+	}
+	return "", nil
+}
+
 func writeProfile(name, file string) error {
 	p := pprof.Lookup(name)
 	log.Info("Writing profile records", "count", p.Count(), "type", name, "dump", file)
@@ -0,0 +1,157 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// registerTestMetrics registers one metric of each type under the "apitest" group
+// of the default registry, returning a function to unregister them.
+func registerTestMetrics() func() {
+	metrics.Enable()
+
+	names := []string{
+		"apitest/counter", "apitest/gauge", "apitest/gaugefloat",
+		"apitest/meter", "apitest/histogram", "apitest/timer", "apitest/resetting",
+		"apitest/nested/counter",
+	}
+	metrics.NewRegisteredCounter(names[0], nil).Inc(1500)
+	metrics.NewRegisteredGauge(names[1], nil).Update(42)
+	metrics.NewRegisteredGaugeFloat64(names[2], nil).Update(0.5)
+	metrics.NewRegisteredMeter(names[3], nil).Mark(3)
+	metrics.NewRegisteredHistogram(names[4], nil, metrics.NewUniformSample(100)).Update(10)
+	metrics.NewRegisteredTimer(names[5], nil).Update(2 * time.Millisecond)
+	metrics.NewRegisteredResettingTimer(names[6], nil).Update(3 * time.Second)
+	metrics.NewRegisteredCounter(names[7], nil).Inc(7)
+
+	return func() {
+		for _, name := range names {
+			metrics.Unregister(name)
+		}
+	}
+}
+
+func TestMetricsRaw(t *testing.T) {
+	defer registerTestMetrics()()
+
+	// Round trip through JSON to check the result as an RPC client would see it
+	blob, err := json.Marshal(new(HandlerT).Metrics(true))
+	if err != nil {
+		t.Fatalf("failed to marshal metrics: %v", err)
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(blob, &all); err != nil {
+		t.Fatalf("failed to unmarshal metrics: %v", err)
+	}
+	group, ok := all["apitest"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("metric group missing: %v", all["apitest"])
+	}
+	field := func(metric, key string) interface{} {
+		values, ok := group[metric].(map[string]interface{})
+		if !ok {
+			t.Fatalf("metric %s missing: %v", metric, group[metric])
+		}
+		return values[key]
+	}
+	tests := []struct {
+		metric, key string
+		want        interface{}
+	}{
+		{"counter", "count", 1500.0},
+		{"gauge", "value", 42.0},
+		{"gaugefloat", "value", 0.5},
+		{"meter", "count", 3.0},
+		{"histogram", "count", 1.0},
+		{"histogram", "max", 10.0},
+		{"histogram", "median", 10.0},
+		{"timer", "count", 1.0},
+		{"timer", "99%", float64(2 * time.Millisecond)},
+		{"resetting", "count", 1.0},
+		{"resetting", "median", float64(3 * time.Second)},
+	}
+	for _, tt := range tests {
+		if have := field(tt.metric, tt.key); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("%s %s mismatch: have %v, want %v", tt.metric, tt.key, have, tt.want)
+		}
+	}
+	for _, key := range []string{"1m.rate", "5m.rate", "15m.rate", "mean.rate"} {
+		if _, ok := field("meter", key).(float64); !ok {
+			t.Errorf("meter rate %s missing", key)
+		}
+	}
+	nested, ok := group["nested"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("nested metric group missing: %v", group["nested"])
+	}
+	if have := nested["counter"]; !reflect.DeepEqual(have, map[string]interface{}{"count": 7.0}) {
+		t.Errorf("nested counter mismatch: have %v", have)
+	}
+}
+
+func TestMetricsFormatted(t *testing.T) {
+	defer registerTestMetrics()()
+
+	group := new(HandlerT).Metrics(false)["apitest"].(map[string]interface{})
+	field := func(metric, key string) interface{} {
+		return group[metric].(map[string]interface{})[key]
+	}
+	tests := []struct {
+		metric, key string
+		want        interface{}
+	}{
+		{"counter", "count", "1.50K"},
+		{"gauge", "value", "42"},
+		{"gaugefloat", "value", "0.50"},
+		{"meter", "count", "3"},
+		{"histogram", "max", "10"},
+		{"histogram", "mean", "10.00"},
+		{"timer", "count", "1"},
+		{"timer", "max", "2ms"},
+		{"resetting", "median", "3s"},
+	}
+	for _, tt := range tests {
+		if have := field(tt.metric, tt.key); have != tt.want {
+			t.Errorf("%s %s mismatch: have %v, want %v", tt.metric, tt.key, have, tt.want)
+		}
+	}
+	if rate, _ := field("meter", "mean.rate").(string); len(rate) < 2 || rate[len(rate)-2:] != "/s" {
+		t.Errorf("meter rate not formatted: %v", field("meter", "mean.rate"))
+	}
+}
+
+func TestListMetrics(t *testing.T) {
+	defer registerTestMetrics()()
+
+	have := new(HandlerT).ListMetrics("apitest/")
+	want := []string{
+		"apitest/counter", "apitest/gauge", "apitest/gaugefloat", "apitest/histogram",
+		"apitest/meter", "apitest/nested/counter", "apitest/resetting", "apitest/timer",
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("metric list mismatch: have %v, want %v", have, want)
+	}
+	if have := new(HandlerT).ListMetrics("apitest/nothing"); len(have) != 0 {
+		t.Errorf("unexpected metrics listed: %v", have)
+	}
+}
@@ -17,12 +17,14 @@
 package debug
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -43,7 +45,7 @@ var (
 	}
 	vmoduleFlag = cli.StringFlag{
 		Name:  "vmodule",
-		Usage: "Per-module verbosity: comma-separated list of <pattern>=<level> (e.g. eth/*=5,p2p=4)",
+		Usage: "Per-module verbosity: comma-separated list of <pattern>=<level> (e.g. eth/*=5,p2p=4,module:downloader=5)",
 		Value: "",
 	}
 	backtraceAtFlag = cli.StringFlag{
@@ -51,6 +53,24 @@ var (
 		Usage: "Request a stack trace at a specific logging statement (e.g. \"block.go:271\")",
 		Value: "",
 	}
+	logjsonFlag = cli.BoolFlag{
+		Name:  "log.json",
+		Usage: "Format logs with JSON, one object per record",
+	}
+	logFileFlag = cli.StringFlag{
+		Name:  "log.file",
+		Usage: "Write logs to the given file in addition to the console",
+	}
+	logMaxSizeFlag = cli.IntFlag{
+		Name:  "log.maxsize",
+		Usage: "Maximum size in megabytes of the log file before it gets rotated (0 = no rotation)",
+		Value: 100,
+	}
+	logMaxBackupsFlag = cli.IntFlag{
+		Name:  "log.maxbackups",
+		Usage: "Maximum number of rotated log files to keep",
+		Value: 10,
+	}
 	debugFlag = cli.BoolFlag{
 		Name:  "debug",
 		Usage: "Prepends log messages with call-site location (file and line number)",
@@ -69,6 +89,20 @@ var (
 		Usage: "pprof HTTP server listening interface",
 		Value: "127.0.0.1",
 	}
+	pprofContinuousFlag = cli.DurationFlag{
+		Name:  "pprof.continuous",
+		Usage: "Capture a CPU profile into memory at the given interval, see debug_recentProfiles (0 = disabled)",
+	}
+	pprofContinuousDurationFlag = cli.DurationFlag{
+		Name:  "pprof.continuous.duration",
+		Usage: "Length of the continuously captured CPU profiles",
+		Value: 10 * time.Second,
+	}
+	pprofContinuousBufferFlag = cli.IntFlag{
+		Name:  "pprof.continuous.buffer",
+		Usage: "Maximum memory in megabytes used by the continuously captured CPU profiles",
+		Value: 16,
+	}
 	memprofilerateFlag = cli.IntFlag{
 		Name:  "memprofilerate",
 		Usage: "Turn on memory profiling with the given rate",
@@ -91,7 +125,9 @@ var (
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
+	logjsonFlag, logFileFlag, logMaxSizeFlag, logMaxBackupsFlag,
 	pprofFlag, pprofAddrFlag, pprofPortFlag,
+	pprofContinuousFlag, pprofContinuousDurationFlag, pprofContinuousBufferFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
 
@@ -115,6 +151,10 @@ func init() {
 func Setup(ctx *cli.Context, logdir string) error {
 	// logging
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
+	if ctx.GlobalBool(logjsonFlag.Name) {
+		ostream = log.StreamHandler(os.Stderr, log.JSONFormat())
+	}
+	handler := ostream
 	if logdir != "" {
 		rfh, err := log.RotatingFileHandler(
 			logdir,
@@ -124,8 +164,24 @@ func Setup(ctx *cli.Context, logdir string) error {
 		if err != nil {
 			return err
 		}
-		glogger.SetHandler(log.MultiHandler(ostream, rfh))
+		handler = log.MultiHandler(handler, rfh)
 	}
+	if file := ctx.GlobalString(logFileFlag.Name); file != "" {
+		maxsize, backups := ctx.GlobalInt(logMaxSizeFlag.Name), ctx.GlobalInt(logMaxBackupsFlag.Name)
+		if maxsize < 0 || backups < 0 {
+			return errors.New("log file size and backup limits must not be negative")
+		}
+		format := log.TerminalFormat(false)
+		if ctx.GlobalBool(logjsonFlag.Name) {
+			format = log.JSONFormat()
+		}
+		fh, err := log.SizeRotatingFileHandler(file, uint(maxsize)*1024*1024, backups, format)
+		if err != nil {
+			return err
+		}
+		handler = log.MultiHandler(handler, fh)
+	}
+	glogger.SetHandler(handler)
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
 	glogger.Vmodule(ctx.GlobalString(vmoduleFlag.Name))
 	glogger.BacktraceAt(ctx.GlobalString(backtraceAtFlag.Name))
@@ -144,6 +200,13 @@ func Setup(ctx *cli.Context, logdir string) error {
 			return err
 		}
 	}
+	if interval := ctx.GlobalDuration(pprofContinuousFlag.Name); interval > 0 {
+		duration := ctx.GlobalDuration(pprofContinuousDurationFlag.Name)
+		limit := ctx.GlobalInt(pprofContinuousBufferFlag.Name) * 1024 * 1024
+		if err := Handler.startProfiler(interval, duration, limit); err != nil {
+			return err
+		}
+	}
 
 	// pprof server
 	if ctx.GlobalBool(pprofFlag.Name) {
@@ -169,6 +232,7 @@ func StartPProf(address string) {
 // Exit stops all running profiles, flushing their output to the
 // respective file.
 func Exit() {
+	Handler.StopContinuousProfiling()
 	Handler.StopCPUProfile()
 	Handler.StopGoTrace()
 }
@@ -0,0 +1,273 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// maxProfileDataSize is the maximum size of a profile returned over RPC.
+	maxProfileDataSize = 32 * 1024 * 1024
+
+	// maxProfileDuration is the maximum duration of a CPU profile requested over
+	// RPC, or captured by the continuous profiler.
+	maxProfileDuration = 5 * time.Minute
+)
+
+var (
+	errProfileTooLarge    = errors.New("profile too large")
+	errProfileDuration    = errors.New("invalid profile duration")
+	errProfilerRunning    = errors.New("continuous profiling already running")
+	errProfilerNotRunning = errors.New("continuous profiling not running")
+	errProfileBuffer      = errors.New("invalid continuous profile buffer size")
+)
+
+// CpuProfileData turns on CPU profiling for nsec seconds and returns the profile
+// in the gzipped protobuf format of pprof. Profiling fails if another CPU profile
+// is being written at the same time, including the ones of the continuous
+// profiler.
+func (*HandlerT) CpuProfileData(ctx context.Context, nsec uint) ([]byte, error) {
+	duration := time.Duration(nsec) * time.Second
+	if duration <= 0 || duration > maxProfileDuration {
+		return nil, errProfileDuration
+	}
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	pprof.StopCPUProfile()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return capProfile(buf.Bytes())
+}
+
+// HeapProfileData returns a heap profile in the gzipped protobuf format of pprof.
+func (*HandlerT) HeapProfileData() ([]byte, error) {
+	return profileData("heap")
+}
+
+// BlockProfileData returns a goroutine blocking profile in the gzipped protobuf
+// format of pprof. Blocking events are only recorded while a block profile rate
+// is set, see SetBlockProfileRate.
+func (*HandlerT) BlockProfileData() ([]byte, error) {
+	return profileData("block")
+}
+
+// MutexProfileData returns a mutex contention profile in the gzipped protobuf
+// format of pprof. Contention is only recorded while a mutex profile fraction is
+// set, see SetMutexProfileFraction.
+func (*HandlerT) MutexProfileData() ([]byte, error) {
+	return profileData("mutex")
+}
+
+// profileData writes the named runtime profile into memory.
+func profileData(name string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup(name).WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	return capProfile(buf.Bytes())
+}
+
+// capProfile rejects profiles too large to be returned over RPC.
+func capProfile(data []byte) ([]byte, error) {
+	if len(data) > maxProfileDataSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", errProfileTooLarge, len(data), maxProfileDataSize)
+	}
+	return data, nil
+}
+
+// ProfileSnapshot is a CPU profile captured by the continuous profiler.
+type ProfileSnapshot struct {
+	Time     time.Time     `json:"time"`     // Time the capture started
+	Duration time.Duration `json:"duration"` // Length of the capture
+	Data     []byte        `json:"data"`     // Profile in the gzipped protobuf format of pprof
+}
+
+// StartContinuousProfiling starts capturing a short CPU profile of the given
+// length in seconds every interval seconds, keeping the most recent profiles in
+// memory up to the given total size in megabytes. The profiles are retrieved
+// with RecentProfiles. Captures are skipped while other CPU profiles are written.
+func (h *HandlerT) StartContinuousProfiling(interval, nsec uint, limitMB int) error {
+	return h.startProfiler(time.Duration(interval)*time.Second, time.Duration(nsec)*time.Second, limitMB*1024*1024)
+}
+
+func (h *HandlerT) startProfiler(interval, duration time.Duration, limit int) error {
+	if duration <= 0 || duration > maxProfileDuration || interval < duration {
+		return errProfileDuration
+	}
+	if limit <= 0 {
+		return errProfileBuffer
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.profiler != nil {
+		return errProfilerRunning
+	}
+	h.profiler = newContinuousProfiler(interval, duration, limit)
+	log.Info("Continuous profiling started", "interval", interval, "duration", duration, "buffer", limit)
+	return nil
+}
+
+// StopContinuousProfiling stops the continuous profiler, dropping the profiles
+// captured by it.
+func (h *HandlerT) StopContinuousProfiling() error {
+	h.mu.Lock()
+	profiler := h.profiler
+	h.profiler = nil
+	h.mu.Unlock()
+
+	if profiler == nil {
+		return errProfilerNotRunning
+	}
+	profiler.stop()
+	log.Info("Continuous profiling stopped")
+	return nil
+}
+
+// RecentProfiles returns the CPU profiles captured by the continuous profiler,
+// oldest first.
+func (h *HandlerT) RecentProfiles() ([]ProfileSnapshot, error) {
+	h.mu.Lock()
+	profiler := h.profiler
+	h.mu.Unlock()
+
+	if profiler == nil {
+		return nil, errProfilerNotRunning
+	}
+	return profiler.snapshots(), nil
+}
+
+// continuousProfiler periodically captures CPU profiles into a ring of in-memory
+// snapshots, whose total size is bounded.
+type continuousProfiler struct {
+	interval time.Duration // Time between the starts of two captures
+	duration time.Duration // Length of a capture
+	limit    int           // Maximum total size of the retained snapshots
+
+	ring []ProfileSnapshot // Retained snapshots, oldest first
+	size int               // Total size of the retained snapshots
+	lock sync.Mutex
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+func newContinuousProfiler(interval, duration time.Duration, limit int) *continuousProfiler {
+	p := &continuousProfiler{
+		interval: interval,
+		duration: duration,
+		limit:    limit,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// loop captures a profile every interval until stopped.
+func (p *continuousProfiler) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !p.capture() {
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// capture records a CPU profile and adds it to the ring, returning false if the
+// profiler was stopped meanwhile.
+func (p *continuousProfiler) capture() bool {
+	var (
+		buf   bytes.Buffer
+		start = time.Now()
+	)
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		log.Debug("Skipping continuous CPU profile", "err", err)
+		return true
+	}
+	timer := time.NewTimer(p.duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-p.quit:
+		pprof.StopCPUProfile()
+		return false
+	}
+	pprof.StopCPUProfile()
+
+	p.add(ProfileSnapshot{Time: start, Duration: time.Since(start), Data: buf.Bytes()})
+	return true
+}
+
+// add appends a snapshot to the ring, evicting the oldest ones beyond the size
+// limit.
+func (p *continuousProfiler) add(snapshot ProfileSnapshot) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.ring = append(p.ring, snapshot)
+	p.size += len(snapshot.Data)
+	for p.size > p.limit && len(p.ring) > 0 {
+		p.size -= len(p.ring[0].Data)
+		p.ring[0] = ProfileSnapshot{}
+		p.ring = p.ring[1:]
+	}
+}
+
+// snapshots returns the retained snapshots, oldest first.
+func (p *continuousProfiler) snapshots() []ProfileSnapshot {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]ProfileSnapshot{}, p.ring...)
+}
+
+// stop terminates the profiler, waiting for a running capture to be aborted.
+func (p *continuousProfiler) stop() {
+	close(p.quit)
+	<-p.done
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// parseProfile checks that the data is a valid pprof profile of the given type.
+func parseProfile(t *testing.T, data []byte, sampleType string) {
+	t.Helper()
+
+	prof, err := profile.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse profile: %v", err)
+	}
+	for _, st := range prof.SampleType {
+		if st.Type == sampleType {
+			return
+		}
+	}
+	t.Fatalf("sample type %q missing from profile: %v", sampleType, prof.SampleType)
+}
+
+func TestProfileData(t *testing.T) {
+	h := new(HandlerT)
+
+	if _, err := h.CpuProfileData(context.Background(), 0); err != errProfileDuration {
+		t.Fatalf("zero duration error mismatch: have %v, want %v", err, errProfileDuration)
+	}
+	cpu, err := h.CpuProfileData(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to profile CPU: %v", err)
+	}
+	parseProfile(t, cpu, "cpu")
+
+	heap, err := h.HeapProfileData()
+	if err != nil {
+		t.Fatalf("failed to profile heap: %v", err)
+	}
+	parseProfile(t, heap, "inuse_space")
+
+	block, err := h.BlockProfileData()
+	if err != nil {
+		t.Fatalf("failed to profile blocking: %v", err)
+	}
+	parseProfile(t, block, "contentions")
+
+	mutex, err := h.MutexProfileData()
+	if err != nil {
+		t.Fatalf("failed to profile mutexes: %v", err)
+	}
+	parseProfile(t, mutex, "contentions")
+}
+
+func TestContinuousProfiling(t *testing.T) {
+	h := new(HandlerT)
+
+	if _, err := h.RecentProfiles(); err != errProfilerNotRunning {
+		t.Fatalf("error mismatch before start: have %v, want %v", err, errProfilerNotRunning)
+	}
+	if err := h.startProfiler(50*time.Millisecond, 20*time.Millisecond, 1024*1024); err != nil {
+		t.Fatalf("failed to start profiler: %v", err)
+	}
+	if err := h.startProfiler(50*time.Millisecond, 20*time.Millisecond, 1024*1024); err != errProfilerRunning {
+		t.Fatalf("error mismatch on restart: have %v, want %v", err, errProfilerRunning)
+	}
+	// Wait for a few profiles to be captured and check them
+	var snapshots []ProfileSnapshot
+	for deadline := time.Now().Add(5 * time.Second); len(snapshots) < 3; {
+		if time.Now().After(deadline) {
+			t.Fatalf("profiles not captured: have %d, want 3", len(snapshots))
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var err error
+		if snapshots, err = h.RecentProfiles(); err != nil {
+			t.Fatalf("failed to retrieve profiles: %v", err)
+		}
+	}
+	for i, snapshot := range snapshots {
+		if i > 0 && !snapshot.Time.After(snapshots[i-1].Time) {
+			t.Errorf("snapshot %d: not ordered by time", i)
+		}
+		parseProfile(t, snapshot.Data, "cpu")
+	}
+	if err := h.StopContinuousProfiling(); err != nil {
+		t.Fatalf("failed to stop profiler: %v", err)
+	}
+	if _, err := h.RecentProfiles(); err != errProfilerNotRunning {
+		t.Fatalf("error mismatch after stop: have %v, want %v", err, errProfilerNotRunning)
+	}
+}
+
+// Tests that the profile ring evicts the oldest snapshots beyond its size limit.
+func TestContinuousProfilerLimit(t *testing.T) {
+	p := &continuousProfiler{limit: 100}
+	for i := 0; i < 10; i++ {
+		p.add(ProfileSnapshot{Duration: time.Duration(i), Data: make([]byte, 30)})
+	}
+	snapshots := p.snapshots()
+	if len(snapshots) != 3 {
+		t.Fatalf("retained snapshot count mismatch: have %d, want 3", len(snapshots))
+	}
+	for i, snapshot := range snapshots {
+		if want := time.Duration(7 + i); snapshot.Duration != want {
+			t.Errorf("snapshot %d: have %d, want %d", i, snapshot.Duration, want)
+		}
+	}
+	// Snapshots larger than the limit aren't retained at all
+	p.add(ProfileSnapshot{Data: make([]byte, 101)})
+	if snapshots := p.snapshots(); len(snapshots) != 0 || p.size != 0 {
+		t.Fatalf("oversized snapshot retained: %d snapshots, %d bytes", len(snapshots), p.size)
+	}
+}
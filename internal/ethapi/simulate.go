@@ -0,0 +1,104 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SimulateConfig are the options of a transaction simulation.
+type SimulateConfig struct {
+	Trace     bool                       `json:"trace"`     // Whether to record the executed opcodes
+	Overrides map[common.Address]account `json:"overrides"` // State overrides applied before execution
+}
+
+// SimulationResult is the outcome of executing a signed transaction on top of
+// the state of a block, without including it anywhere.
+type SimulationResult struct {
+	Hash       common.Hash    `json:"hash"`
+	From       common.Address `json:"from"`
+	Failed     bool           `json:"failed"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Logs       []*types.Log   `json:"logs"`
+	StructLogs []StructLogRes `json:"structLogs,omitempty"`
+}
+
+// SimulateTransaction executes a signed, RLP encoded transaction on top of the
+// state of the given block, pending by default, and returns its outcome. The
+// transaction is neither broadcast nor included in any block, and its nonce
+// isn't checked. Only the logs of successful executions are retained.
+func (api *PrivateDebugAPI) SimulateTransaction(ctx context.Context, encodedTx hexutil.Bytes, blockNrOrHash *rpc.BlockNumberOrHash, config *SimulateConfig) (*SimulationResult, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return nil, err
+	}
+	from, err := types.Sender(types.NewEIP155Signer(api.b.ChainConfig().ChainID), tx)
+	if err != nil {
+		return nil, err
+	}
+	if blockNrOrHash == nil {
+		pending := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+		blockNrOrHash = &pending
+	}
+	if config == nil {
+		config = new(SimulateConfig)
+	}
+	var (
+		gas      = hexutil.Uint64(tx.Gas())
+		gasPrice = (*hexutil.Big)(tx.GasPrice())
+		value    = (*hexutil.Big)(tx.Value())
+		data     = hexutil.Bytes(tx.Data())
+		args     = CallArgs{From: &from, To: tx.To(), Gas: &gas, GasPrice: gasPrice, Value: value, Data: &data}
+
+		logger *vm.StructLogger
+		vmCfg  *vm.Config
+	)
+	if config.Trace {
+		logger = vm.NewStructLogger(&vm.LogConfig{DisableMemory: true, DisableStorage: true})
+		vmCfg = &vm.Config{Debug: true, Tracer: logger}
+	}
+	res, used, failed, state, err := doCall(ctx, api.b, args, *blockNrOrHash, config.Overrides, vmCfg, 5*time.Second, api.b.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
+	result := &SimulationResult{
+		Hash:       tx.Hash(),
+		From:       from,
+		Failed:     failed,
+		GasUsed:    hexutil.Uint64(used),
+		ReturnData: res,
+		Logs:       []*types.Log{},
+	}
+	// The logs weren't recorded against any transaction, fill in the details
+	for _, log := range state.Logs() {
+		log.TxHash = tx.Hash()
+		result.Logs = append(result.Logs, log)
+	}
+	if logger != nil {
+		result.StructLogs = FormatLogs(logger.StructLogs())
+	}
+	return result, nil
+}
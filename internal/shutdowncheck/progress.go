@@ -0,0 +1,87 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package shutdowncheck
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Task is the progress of a long running task executed while shutting down,
+// such as flushing caches to disk.
+type Task struct {
+	Name    string    // Description of the task (e.g. "flushing trie cache")
+	Percent float64   // Completion percentage of the task, 0-100
+	Started time.Time // Time the task was first reported
+
+	seq uint64 // Registration order of the task
+}
+
+// String implements fmt.Stringer.
+func (t Task) String() string {
+	return fmt.Sprintf("%s: %.0f%% complete", t.Name, t.Percent)
+}
+
+var (
+	tasks     = make(map[string]*Task)
+	tasksSeq  uint64
+	tasksLock sync.Mutex
+)
+
+// Progress reports the completion of a shutdown task, registering the task if
+// it isn't already tracked. The reports are logged periodically by the node
+// while it waits for its services to stop.
+func Progress(name string, done, total uint64) {
+	percent := float64(100)
+	if total > 0 && done < total {
+		percent = float64(done) * 100 / float64(total)
+	}
+	tasksLock.Lock()
+	defer tasksLock.Unlock()
+
+	task, ok := tasks[name]
+	if !ok {
+		task = &Task{Name: name, Started: time.Now(), seq: tasksSeq}
+		tasks[name] = task
+		tasksSeq++
+	}
+	task.Percent = percent
+}
+
+// Done marks a shutdown task as finished, removing it from the tracked ones.
+func Done(name string) {
+	tasksLock.Lock()
+	defer tasksLock.Unlock()
+
+	delete(tasks, name)
+}
+
+// InFlight returns the shutdown tasks currently in progress, in the order they
+// were started.
+func InFlight() []Task {
+	tasksLock.Lock()
+	defer tasksLock.Unlock()
+
+	inflight := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		inflight = append(inflight, *task)
+	}
+	sort.Slice(inflight, func(i, j int) bool { return inflight[i].seq < inflight[j].seq })
+	return inflight
+}
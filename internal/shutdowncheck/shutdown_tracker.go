@@ -0,0 +1,65 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package shutdowncheck tracks the shutdown of the node: whether previous
+// sessions ended cleanly, and the progress of the tasks run while stopping.
+package shutdowncheck
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ShutdownTracker is a service that reports previous unclean shutdowns upon
+// start, and marks the running session as cleanly shut down upon stop.
+type ShutdownTracker struct {
+	db ethdb.Database
+}
+
+// NewShutdownTracker creates a new ShutdownTracker instance.
+func NewShutdownTracker(db ethdb.Database) *ShutdownTracker {
+	return &ShutdownTracker{db: db}
+}
+
+// MarkStartup records the start of the running session in the database, which
+// stays in place until Stop is called, and logs any previous unclean shutdowns.
+// It returns the number of unclean shutdowns still tracked in the database.
+func (t *ShutdownTracker) MarkStartup() int {
+	uncleanShutdowns, discards, err := rawdb.PushUncleanShutdownMarker(t.db)
+	if err != nil {
+		log.Error("Could not update unclean-shutdown-marker list", "error", err)
+		return 0
+	}
+	if len(uncleanShutdowns) == 0 {
+		return 0
+	}
+	for _, uncleanShutdown := range uncleanShutdowns {
+		log.Warn("Unclean shutdown detected", "booted", time.Unix(int64(uncleanShutdown), 0),
+			"age", common.PrettyAge(time.Unix(int64(uncleanShutdown), 0)))
+	}
+	log.Warn("Recent unclean shutdowns", "count", len(uncleanShutdowns), "discarded", discards)
+	return len(uncleanShutdowns)
+}
+
+// Stop removes the marker of the running session, flagging it as cleanly shut
+// down.
+func (t *ShutdownTracker) Stop() {
+	rawdb.PopUncleanShutdownMarker(t.db)
+}
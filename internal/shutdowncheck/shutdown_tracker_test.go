@@ -0,0 +1,74 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package shutdowncheck
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// Tests that the tracker counts the sessions which weren't stopped cleanly.
+func TestShutdownTracker(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	// Simulate a clean session followed by two crashed ones
+	tracker := NewShutdownTracker(db)
+	if n := tracker.MarkStartup(); n != 0 {
+		t.Fatalf("fresh database unclean shutdowns mismatch: have %d, want 0", n)
+	}
+	tracker.Stop()
+
+	if n := NewShutdownTracker(db).MarkStartup(); n != 0 {
+		t.Fatalf("unclean shutdowns after clean stop mismatch: have %d, want 0", n)
+	}
+	if n := NewShutdownTracker(db).MarkStartup(); n != 1 {
+		t.Fatalf("unclean shutdowns after crash mismatch: have %d, want 1", n)
+	}
+	tracker = NewShutdownTracker(db)
+	if n := tracker.MarkStartup(); n != 2 {
+		t.Fatalf("unclean shutdowns after two crashes mismatch: have %d, want 2", n)
+	}
+	// A clean stop only clears the marker of the running session
+	tracker.Stop()
+	if n := NewShutdownTracker(db).MarkStartup(); n != 2 {
+		t.Fatalf("unclean shutdowns after clean stop mismatch: have %d, want 2", n)
+	}
+}
+
+// Tests that the progress of shutdown tasks is tracked until they're done.
+func TestShutdownProgress(t *testing.T) {
+	Progress("flushing trie cache", 0, 100)
+	Progress("closing database", 0, 1)
+	Progress("flushing trie cache", 42, 100)
+
+	tasks := InFlight()
+	if len(tasks) != 2 {
+		t.Fatalf("in-flight task count mismatch: have %d, want 2", len(tasks))
+	}
+	if have, want := tasks[0].String(), "flushing trie cache: 42% complete"; have != want {
+		t.Errorf("first task mismatch: have %q, want %q", have, want)
+	}
+	if have, want := tasks[1].String(), "closing database: 0% complete"; have != want {
+		t.Errorf("second task mismatch: have %q, want %q", have, want)
+	}
+	Done("flushing trie cache")
+	Done("closing database")
+	if tasks := InFlight(); len(tasks) != 0 {
+		t.Fatalf("in-flight tasks after completion: %v", tasks)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package validate checks configuration structs against rules declared in their
+// struct tags.
+//
+// Rules are given as a comma separated list in the "validate" tag of a field:
+//
+//	omitempty        skips all other rules if the field has its zero value
+//	min=N, max=N     bounds numbers, or the length of strings, slices and maps
+//	oneof=A B C      requires the textual form of the value to be one of the options
+//	addr             requires a host:port network address
+//	dir              requires the path to be a directory, if it exists
+//
+// Fields holding structs are checked recursively, unless tagged with "-".
+package validate
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes a field which failed validation.
+type FieldError struct {
+	Field string // Path of the field, e.g. Config.P2P.MaxPeers
+	Msg   string // Human readable description of the violation
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// Errors is the list of violations found in a struct.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Struct validates all fields of the struct v, which may also be a pointer to
+// a struct. The name is used as the root of the field paths in errors. The
+// returned error is of type Errors if any field is invalid.
+func Struct(name string, v interface{}) error {
+	c := &checker{seen: make(map[uintptr]bool)}
+	c.walk(name, reflect.ValueOf(v))
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+type checker struct {
+	errs Errors
+	seen map[uintptr]bool // visited pointers, to break reference cycles
+}
+
+func (c *checker) fail(path, format string, args ...interface{}) {
+	c.errs = append(c.errs, &FieldError{Field: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// walk checks the tagged fields of a struct and descends into nested structs.
+func (c *checker) walk(path string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() || c.seen[v.Pointer()] {
+			return
+		}
+		c.seen[v.Pointer()] = true
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		fpath := path + "." + field.Name
+		if tag != "" {
+			c.check(fpath, v.Field(i), tag)
+		}
+		c.walk(fpath, v.Field(i))
+	}
+}
+
+// check applies the rules of a tag to a single field.
+func (c *checker) check(path string, v reflect.Value, tag string) {
+	var (
+		rules    = strings.Split(tag, ",")
+		min, max string
+	)
+	for _, rule := range rules {
+		if rule == "omitempty" && isZero(v) {
+			return
+		}
+	}
+	for _, rule := range rules {
+		var (
+			parts = strings.SplitN(rule, "=", 2)
+			arg   string
+		)
+		if len(parts) > 1 {
+			arg = parts[1]
+		}
+		switch parts[0] {
+		case "omitempty":
+		case "min":
+			min = arg
+		case "max":
+			max = arg
+		case "oneof":
+			options := strings.Fields(arg)
+			if text := textOf(v); !contains(options, text) {
+				c.fail(path, "must be one of %s, got %q", strings.Join(options, ", "), text)
+			}
+		case "addr":
+			if _, _, err := net.SplitHostPort(v.String()); err != nil {
+				c.fail(path, "must be a host:port address, got %q", v.String())
+			}
+		case "dir":
+			if info, err := os.Stat(v.String()); err == nil && !info.IsDir() {
+				c.fail(path, "must be a directory, got file %q", v.String())
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q on %s", parts[0], path))
+		}
+	}
+	if min != "" || max != "" {
+		c.checkRange(path, v, min, max)
+	}
+}
+
+// checkRange verifies that a number, or the length of a value, lies within the
+// given bounds.
+func (c *checker) checkRange(path string, v reflect.Value, min, max string) {
+	var (
+		n    float64
+		desc string
+	)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, desc = float64(v.Int()), fmt.Sprint(v.Interface())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, desc = float64(v.Uint()), fmt.Sprint(v.Interface())
+	case reflect.Float32, reflect.Float64:
+		n, desc = v.Float(), fmt.Sprint(v.Interface())
+	case reflect.String, reflect.Slice, reflect.Map:
+		n, desc = float64(v.Len()), fmt.Sprintf("length %d", v.Len())
+	default:
+		panic(fmt.Sprintf("validate: range rule on %s of kind %v", path, v.Kind()))
+	}
+	var (
+		hasMin, hasMax = min != "", max != ""
+		tooLow         = hasMin && n < parseBound(path, min)
+		tooHigh        = hasMax && n > parseBound(path, max)
+	)
+	if !tooLow && !tooHigh {
+		return
+	}
+	switch {
+	case hasMin && hasMax:
+		c.fail(path, "must be between %s and %s, got %s", min, max, desc)
+	case hasMin:
+		c.fail(path, "must be at least %s, got %s", min, desc)
+	default:
+		c.fail(path, "must be at most %s, got %s", max, desc)
+	}
+}
+
+func parseBound(path, s string) float64 {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: invalid bound %q on %s", s, path))
+	}
+	return n
+}
+
+// textOf returns the textual form of a value, preferring its text encoding.
+func textOf(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
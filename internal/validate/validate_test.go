@@ -0,0 +1,181 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package validate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testMode int
+
+func (m testMode) MarshalText() ([]byte, error) {
+	switch m {
+	case 0:
+		return []byte("fast"), nil
+	case 1:
+		return []byte("full"), nil
+	}
+	return nil, errors.New("unknown mode")
+}
+
+type testInner struct {
+	Peers int `validate:"min=1,max=1024"`
+}
+
+type testConfig struct {
+	Port     int           `validate:"min=0,max=65535"`
+	Fraction uint          `validate:"max=100"`
+	Timeout  time.Duration `validate:"min=0"`
+	Name     string        `validate:"min=2"`
+	Mode     testMode      `validate:"oneof=fast full"`
+	Listen   string        `validate:"omitempty,addr"`
+	Dir      string        `validate:"omitempty,dir"`
+	Skipped  testInner     `validate:"-"`
+	Inner    testInner
+	InnerPtr *testInner
+}
+
+func validConfig() testConfig {
+	return testConfig{
+		Port:     30303,
+		Fraction: 75,
+		Timeout:  time.Second,
+		Name:     "geth",
+		Listen:   ":30303",
+		Inner:    testInner{Peers: 25},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*testConfig)
+		err    string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *testConfig) {},
+		},
+		{
+			name:   "min and max, too low",
+			modify: func(c *testConfig) { c.Port = -1 },
+			err:    "Config.Port: must be between 0 and 65535, got -1",
+		},
+		{
+			name:   "min and max, too high",
+			modify: func(c *testConfig) { c.Port = 65536 },
+			err:    "Config.Port: must be between 0 and 65535, got 65536",
+		},
+		{
+			name:   "max only",
+			modify: func(c *testConfig) { c.Fraction = 101 },
+			err:    "Config.Fraction: must be at most 100, got 101",
+		},
+		{
+			name:   "min on duration",
+			modify: func(c *testConfig) { c.Timeout = -time.Second },
+			err:    "Config.Timeout: must be at least 0, got -1s",
+		},
+		{
+			name:   "min on string length",
+			modify: func(c *testConfig) { c.Name = "g" },
+			err:    "Config.Name: must be at least 2, got length 1",
+		},
+		{
+			name:   "oneof",
+			modify: func(c *testConfig) { c.Mode = 5 },
+			err:    `Config.Mode: must be one of fast, full, got ""`,
+		},
+		{
+			name:   "oneof, valid text",
+			modify: func(c *testConfig) { c.Mode = 1 },
+		},
+		{
+			name:   "addr",
+			modify: func(c *testConfig) { c.Listen = "30303" },
+			err:    `Config.Listen: must be a host:port address, got "30303"`,
+		},
+		{
+			name:   "omitempty",
+			modify: func(c *testConfig) { c.Listen = "" },
+		},
+		{
+			name:   "dir, existing directory",
+			modify: func(c *testConfig) { c.Dir = dir },
+		},
+		{
+			name:   "dir, missing path",
+			modify: func(c *testConfig) { c.Dir = filepath.Join(dir, "missing") },
+		},
+		{
+			name:   "dir, regular file",
+			modify: func(c *testConfig) { c.Dir = file },
+			err:    `Config.Dir: must be a directory, got file "` + file + `"`,
+		},
+		{
+			name:   "skipped struct",
+			modify: func(c *testConfig) { c.Skipped.Peers = 0 },
+		},
+		{
+			name:   "nested struct",
+			modify: func(c *testConfig) { c.Inner.Peers = 0 },
+			err:    "Config.Inner.Peers: must be between 1 and 1024, got 0",
+		},
+		{
+			name:   "nested pointer",
+			modify: func(c *testConfig) { c.InnerPtr = &testInner{Peers: 2000} },
+			err:    "Config.InnerPtr.Peers: must be between 1 and 1024, got 2000",
+		},
+		{
+			name: "multiple errors",
+			modify: func(c *testConfig) {
+				c.Port = -1
+				c.Inner.Peers = 0
+			},
+			err: "Config.Port: must be between 0 and 65535, got -1\nConfig.Inner.Peers: must be between 1 and 1024, got 0",
+		},
+	}
+	for _, test := range tests {
+		cfg := validConfig()
+		test.modify(&cfg)
+
+		err := Struct("Config", &cfg)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q", test.name, test.err)
+		case test.err != "" && err.Error() != test.err:
+			t.Errorf("%s: error mismatch:\nhave %q\nwant %q", test.name, err, test.err)
+		}
+	}
+}
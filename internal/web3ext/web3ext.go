@@ -105,7 +105,8 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'status',
 			call: 'clique_status',
-			params: 0
+			params: 1,
+			inputFormatter: [null]
 		}),
 	],
 	properties: [
@@ -126,6 +127,22 @@ web3._extend({
 			call: 'ethash_getWork',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'getWorkHashingInput',
+			call: 'ethash_getWorkHashingInput',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getWorkBinary',
+			call: 'ethash_getWorkBinary',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getBlockReward',
+			call: 'ethash_getBlockReward',
+			params: 0,
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'ethash_getHashrate',
@@ -141,6 +158,118 @@ web3._extend({
 			call: 'ethash_submitHashRate',
 			params: 2,
 		}),
+		new web3._extend.Method({
+			name: 'solveAndSubmit',
+			call: 'ethash_solveAndSubmit',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'replaySubmission',
+			call: 'ethash_replaySubmission',
+			params: 3,
+		}),
+		new web3._extend.Method({
+			name: 'getPendingTimestamp',
+			call: 'ethash_getPendingTimestamp',
+			params: 0,
+			outputFormatter: web3._extend.utils.toDecimal,
+		}),
+		new web3._extend.Method({
+			name: 'getSolvingDifficulty',
+			call: 'ethash_getSolvingDifficulty',
+			params: 0,
+			outputFormatter: web3._extend.utils.toBigNumber,
+		}),
+		new web3._extend.Method({
+			name: 'submitWorkSigned',
+			call: 'ethash_submitWorkSigned',
+			params: 4,
+		}),
+		new web3._extend.Method({
+			name: 'getDifficultyHistory',
+			call: 'ethash_getDifficultyHistory',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'estimateNextEpochTime',
+			call: 'ethash_estimateNextEpochTime',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'verifySealRange',
+			call: 'ethash_verifySealRange',
+			params: 3,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal, null]
+		}),
+		new web3._extend.Method({
+			name: 'sameEpoch',
+			call: 'ethash_sameEpoch',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'activeForkRules',
+			call: 'ethash_activeForkRules',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getVerificationMode',
+			call: 'ethash_getVerificationMode',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setVerificationMode',
+			call: 'ethash_setVerificationMode',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getVerifyStats',
+			call: 'ethash_getVerifyStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getSlowVerifications',
+			call: 'ethash_getSlowVerifications',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getEngineUptime',
+			call: 'ethash_getEngineUptime',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getEffectiveConfig',
+			call: 'ethash_getEffectiveConfig',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'resetVerifyStats',
+			call: 'ethash_resetVerifyStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'freezeDifficulty',
+			call: 'ethash_freezeDifficulty',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'unfreezeDifficulty',
+			call: 'ethash_unfreezeDifficulty',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getBestShare',
+			call: 'ethash_getBestShare',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'resetBestShare',
+			call: 'ethash_resetBestShare',
+			params: 0
+		}),
 	]
 });
 `
@@ -167,8 +296,49 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'removeTrustedPeer',
 			call: 'admin_removeTrustedPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'setNetRestrict',
+			call: 'admin_setNetRestrict',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getNetRestrict',
+			call: 'admin_getNetRestrict'
+		}),
+		new web3._extend.Method({
+			name: 'peerReputation',
+			call: 'admin_peerReputation',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'clearReputation',
+			call: 'admin_clearReputation',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'listStaticPeers',
+			call: 'admin_listStaticPeers',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'listTrustedPeers',
+			call: 'admin_listTrustedPeers',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'setENRValue',
+			call: 'admin_setENRValue',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -205,6 +375,11 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'reloadConfig',
+			call: 'admin_reloadConfig',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -215,6 +390,18 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerStats',
+			getter: 'admin_peerStats'
+		}),
+		new web3._extend.Property({
+			name: 'health',
+			getter: 'admin_health'
+		}),
+		new web3._extend.Property({
+			name: 'discoveryTable',
+			getter: 'admin_discoveryTable'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -273,6 +460,12 @@ web3._extend({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
 		}),
+		new web3._extend.Method({
+			name: 'simulateTransaction',
+			call: 'debug_simulateTransaction',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null],
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
@@ -309,6 +502,63 @@ web3._extend({
 			call: 'debug_memStats',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'trieDatabaseStats',
+			call: 'debug_trieDatabaseStats',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'dbStats',
+			call: 'debug_dbStats',
+			params: 1,
+			inputFormatter: [null],
+		}),
+		new web3._extend.Method({
+			name: 'dbStatsAsync',
+			call: 'debug_dbStatsAsync',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'dbStatsProgress',
+			call: 'debug_dbStatsProgress',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'compactDatabase',
+			call: 'debug_compactDatabase',
+			params: 2,
+		}),
+		new web3._extend.Method({
+			name: 'compactionStatus',
+			call: 'debug_compactionStatus',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'verifyDatabase',
+			call: 'debug_verifyDatabase',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'pruneState',
+			call: 'debug_pruneState',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'metricsSnapshot',
+			call: 'debug_metricsSnapshot',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'metrics',
+			call: 'debug_metrics',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'listMetrics',
+			call: 'debug_listMetrics',
+			params: 1,
+			inputFormatter: [null],
+		}),
 		new web3._extend.Method({
 			name: 'gcStats',
 			call: 'debug_gcStats',
@@ -319,6 +569,41 @@ web3._extend({
 			call: 'debug_cpuProfile',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'cpuProfileData',
+			call: 'debug_cpuProfileData',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'heapProfileData',
+			call: 'debug_heapProfileData',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'blockProfileData',
+			call: 'debug_blockProfileData',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'mutexProfileData',
+			call: 'debug_mutexProfileData',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'startContinuousProfiling',
+			call: 'debug_startContinuousProfiling',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'stopContinuousProfiling',
+			call: 'debug_stopContinuousProfiling',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'recentProfiles',
+			call: 'debug_recentProfiles',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'startCPUProfile',
 			call: 'debug_startCPUProfile',
@@ -504,6 +789,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getHeadersByRange',
+			call: 'eth_getHeadersByRange',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
+		}),
 		new web3._extend.Method({
 			name: 'getHeaderByNumber',
 			call: 'eth_getHeaderByNumber',
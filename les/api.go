@@ -102,6 +102,7 @@ func (api *PrivateLightServerAPI) clientInfo(c *clientInfo, id enode.ID) map[str
 		info["isConnected"] = true
 		info["connectionTime"] = float64(now-c.connectedAt) / float64(time.Second)
 		info["capacity"] = c.capacity
+		info["weight"] = c.weight
 		pb, nb := c.balanceTracker.getBalance(now)
 		info["pricing/balance"], info["pricing/negBalance"] = pb, nb
 		info["pricing/balanceMeta"] = c.balanceMetaInfo
@@ -86,6 +86,7 @@ type clientPool struct {
 	connectedQueue *prque.LazyQueue
 
 	defaultPosFactors, defaultNegFactors priceFactors
+	priorityHook                         ClientPriority // Assigns capacity weights to clients, nil if unset
 
 	connLimit         int            // The maximum number of connections that clientpool can support
 	capLimit          uint64         // The maximum cumulative capacity that clientpool can support
@@ -107,6 +108,7 @@ type clientPeer interface {
 	freeClientId() string
 	updateCapacity(uint64)
 	freezeClient()
+	priorityToken() []byte
 }
 
 // clientInfo represents a connected client
@@ -116,6 +118,7 @@ type clientInfo struct {
 	connectedAt            mclock.AbsTime
 	capacity               uint64
 	priority               bool
+	weight                 uint64 // capacity weight assigned by the priority hook
 	pool                   *clientPool
 	peer                   clientPeer
 	queueIndex             int // position in connectedQueue
@@ -132,12 +135,18 @@ func connSetIndex(a interface{}, index int) {
 // connPriority callback returns actual priority of clientInfo item in connectedQueue
 func connPriority(a interface{}, now mclock.AbsTime) int64 {
 	c := a.(*clientInfo)
+	if c.weight > 0 {
+		return weightPriority(c.weight)
+	}
 	return c.balanceTracker.getPriority(now)
 }
 
 // connMaxPriority callback returns estimated maximum priority of clientInfo item in connectedQueue
 func connMaxPriority(a interface{}, until mclock.AbsTime) int64 {
 	c := a.(*clientInfo)
+	if c.weight > 0 {
+		return weightPriority(c.weight)
+	}
 	pri := c.balanceTracker.estimatedPriority(until, true)
 	c.balanceTracker.addCallback(balanceCallbackQueue, pri+1, func() {
 		c.pool.lock.Lock()
@@ -252,6 +261,13 @@ func (f *clientPool) connect(peer clientPeer, capacity uint64) bool {
 	if !e.priority || capacity == 0 {
 		capacity = f.freeClientCap
 	}
+	// Clients weighted by the priority hook get a multiple of the free capacity.
+	if f.priorityHook != nil {
+		e.weight = f.priorityHook.ClientWeight(&ClientHandshake{ID: id, Address: freeID, Token: peer.priorityToken()})
+		if weighted := f.weightedCapacity(e.weight); weighted > capacity {
+			capacity = weighted
+		}
+	}
 	e.capacity = capacity
 
 	// Starts a balance tracker
@@ -283,7 +299,11 @@ func (f *clientPool) connect(peer clientPeer, capacity uint64) bool {
 		if f.disableBias {
 			bias = 0
 		}
-		if newCapacity > f.capLimit || newCount > f.connLimit || (e.balanceTracker.estimatedPriority(now+mclock.AbsTime(bias), false)-kickPriority) > 0 {
+		priority := e.balanceTracker.estimatedPriority(now+mclock.AbsTime(bias), false)
+		if e.weight > 0 {
+			priority = weightPriority(e.weight)
+		}
+		if newCapacity > f.capLimit || newCount > f.connLimit || (priority-kickPriority) > 0 {
 			for _, c := range kickList {
 				f.connectedQueue.Push(c)
 			}
@@ -315,7 +335,7 @@ func (f *clientPool) connect(peer clientPeer, capacity uint64) bool {
 	}
 	totalConnectedGauge.Update(int64(f.connectedCap))
 	clientConnectedMeter.Mark(1)
-	log.Debug("Client accepted", "address", freeID)
+	log.Debug("Client accepted", "address", freeID, "weight", e.weight)
 	return true
 }
 
@@ -362,6 +382,27 @@ func (f *clientPool) forClients(ids []enode.ID, callback func(*clientInfo, enode
 	return nil
 }
 
+// setPriorityHook sets the hook assigning capacity weights to subsequently
+// connected clients.
+func (f *clientPool) setPriorityHook(hook ClientPriority) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.priorityHook = hook
+}
+
+// weightedCapacity returns the capacity of clients with the given weight, which
+// is a multiple of the free client capacity limited by the total capacity.
+func (f *clientPool) weightedCapacity(weight uint64) uint64 {
+	if weight == 0 {
+		return 0
+	}
+	if f.freeClientCap != 0 && weight > f.capLimit/f.freeClientCap {
+		return f.capLimit
+	}
+	return weight * f.freeClientCap
+}
+
 // setDefaultFactors sets the default price factors applied to subsequently connected clients
 func (f *clientPool) setDefaultFactors(posFactors, negFactors priceFactors) {
 	f.lock.Lock()
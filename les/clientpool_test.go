@@ -25,6 +25,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -78,6 +79,16 @@ func (i *poolTestPeerWithCap) updateCapacity(cap uint64) { i.cap = cap }
 
 func (i poolTestPeer) freezeClient() {}
 
+func (i poolTestPeer) priorityToken() []byte { return nil }
+
+type poolTestPeerWithToken struct {
+	poolTestPeer
+
+	token []byte
+}
+
+func (i poolTestPeerWithToken) priorityToken() []byte { return i.token }
+
 func testClientPool(t *testing.T, connLimit, clientCount, paidCount int, randomDisconnect bool) {
 	rand.Seed(time.Now().UnixNano())
 	var (
@@ -339,6 +350,121 @@ func TestFreeClientKickedOut(t *testing.T) {
 	}
 }
 
+// Tests that clients weighted by the priority hook get a larger capacity and that
+// free clients are evicted before them.
+func TestWeightedClientEviction(t *testing.T) {
+	var (
+		clock  mclock.Simulated
+		db     = rawdb.NewMemoryDatabase()
+		kicked = make(chan int, 10)
+		key    = []byte("service key")
+		expiry = time.Now().Add(time.Hour)
+	)
+	removeFn := func(id enode.ID) { kicked <- int(id[0]) }
+	pool := newClientPool(db, 1, &clock, removeFn)
+	defer pool.stop()
+	pool.disableBias = true
+	pool.setLimits(4, uint64(10))
+	pool.setDefaultFactors(priceFactors{1, 0, 1}, priceFactors{1, 0, 1})
+	pool.setPriorityHook(NewHMACTokenVerifier(key))
+
+	weighted := func(i int, weight uint64) clientPeer {
+		return poolTestPeerWithToken{poolTestPeer(i), MakeHMACToken(key, poolTestPeer(i).ID(), weight, expiry)}
+	}
+	expectKicked := func(ids ...int) {
+		t.Helper()
+		select {
+		case id := <-kicked:
+			for _, want := range ids {
+				if id == want {
+					return
+				}
+			}
+			t.Fatalf("wrong client kicked: have %d, want one of %v", id, ids)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for one of %v to be kicked", ids)
+		}
+	}
+
+	// Fill the pool with two free and two weighted clients.
+	for _, peer := range []clientPeer{poolTestPeer(0), poolTestPeer(1), weighted(2, 2), weighted(3, 3)} {
+		if !pool.connect(peer, 0) {
+			t.Fatalf("client %d rejected", peer.ID()[0])
+		}
+	}
+	if c := pool.connectedMap[poolTestPeer(2).ID()]; c.weight != 2 || c.capacity != 2 {
+		t.Fatalf("wrong weighted client state: weight %d, capacity %d", c.weight, c.capacity)
+	}
+	clock.Run(time.Minute)
+
+	// New clients replace the free ones, as long as there are any.
+	if !pool.connect(poolTestPeer(4), 0) {
+		t.Fatal("new free client rejected")
+	}
+	expectKicked(0, 1)
+	if !pool.connect(weighted(5, 1), 0) {
+		t.Fatal("weighted client rejected")
+	}
+	expectKicked(0, 1, 4)
+	if !pool.connect(weighted(6, 1), 0) {
+		t.Fatal("weighted client rejected")
+	}
+	expectKicked(0, 1, 4)
+
+	// Free clients, and clients with invalid tokens, can't evict weighted ones.
+	if pool.connect(poolTestPeer(7), 0) {
+		t.Fatal("free client evicted weighted client")
+	}
+	stolen := poolTestPeerWithToken{poolTestPeer(8), MakeHMACToken(key, poolTestPeer(2).ID(), 10, expiry)}
+	if pool.connect(stolen, 0) {
+		t.Fatal("client with token of another node evicted weighted client")
+	}
+	// Heavier clients evict the lightest ones until their capacity fits.
+	if !pool.connect(weighted(9, 5), 0) {
+		t.Fatal("heavy weighted client rejected")
+	}
+	expectKicked(5, 6)
+	expectKicked(5, 6)
+	select {
+	case id := <-kicked:
+		t.Fatalf("unexpected client %d kicked", id)
+	default:
+	}
+}
+
+func TestHMACTokenVerifier(t *testing.T) {
+	var (
+		key      = []byte("service key")
+		id       = enode.ID{1}
+		now      = time.Unix(1000000, 0)
+		verifier = NewHMACTokenVerifier(key)
+	)
+	verifier.now = func() time.Time { return now }
+
+	token := MakeHMACToken(key, id, 7, now.Add(time.Minute))
+	if w := verifier.ClientWeight(&ClientHandshake{ID: id, Token: token}); w != 7 {
+		t.Errorf("wrong weight for valid token: have %d, want 7", w)
+	}
+	tampered := common.CopyBytes(token)
+	tampered[7] = 8
+	tests := []struct {
+		name string
+		hs   *ClientHandshake
+	}{
+		{"no token", &ClientHandshake{ID: id}},
+		{"other node", &ClientHandshake{ID: enode.ID{2}, Token: token}},
+		{"tampered", &ClientHandshake{ID: id, Token: tampered}},
+		{"wrong key", &ClientHandshake{ID: id, Token: MakeHMACToken([]byte("other"), id, 7, now.Add(time.Minute))}},
+		{"expired", &ClientHandshake{ID: id, Token: MakeHMACToken(key, id, 7, now)}},
+		{"truncated", &ClientHandshake{ID: id, Token: token[:len(token)-1]}},
+	}
+	for _, test := range tests {
+		if w := verifier.ClientWeight(test.hs); w != 0 {
+			t.Errorf("%s: got weight %d for invalid token", test.name, w)
+		}
+	}
+}
+
 func TestPositiveBalanceCalculation(t *testing.T) {
 	var (
 		clock  mclock.Simulated
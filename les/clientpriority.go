@@ -0,0 +1,108 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// ClientHandshake contains the details of a connecting light client which are
+// relevant for prioritizing it.
+type ClientHandshake struct {
+	ID      enode.ID // Node identifier of the client
+	Address string   // Network address of the client, without port
+	Token   []byte   // Opaque priority token sent in the handshake, nil if none
+}
+
+// ClientPriority assigns capacity weights to connecting light clients.
+//
+// A weight of zero serves the client as a free client. Clients with a positive
+// weight are assigned weight times the free client capacity (limited by the total
+// capacity) and are only evicted in favor of clients with a higher weight.
+type ClientPriority interface {
+	ClientWeight(hs *ClientHandshake) uint64
+}
+
+// maxClientWeight limits client weights so they can be converted to priorities.
+const maxClientWeight = math.MaxInt64 / 4
+
+// weightPriority returns the connection queue priority of clients with a positive
+// weight. It is lower than the priority of any balance based client, so weighted
+// clients are evicted last.
+func weightPriority(weight uint64) int64 {
+	if weight > maxClientWeight {
+		weight = maxClientWeight
+	}
+	return math.MinInt64/2 - int64(weight)
+}
+
+// hmacTokenLength is the length of priority tokens created by MakeHMACToken:
+// weight (8 bytes), expiry as unix time (8 bytes) and HMAC-SHA256 (32 bytes).
+const hmacTokenLength = 8 + 8 + sha256.Size
+
+// HMACTokenVerifier is a ClientPriority granting the weight contained in priority
+// tokens created by MakeHMACToken with the same key.
+type HMACTokenVerifier struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewHMACTokenVerifier creates a token verifier using the given service key.
+func NewHMACTokenVerifier(key []byte) *HMACTokenVerifier {
+	return &HMACTokenVerifier{key: key, now: time.Now}
+}
+
+// ClientWeight implements ClientPriority. Clients without a valid token, and
+// tokens which are expired or were issued for another node, get weight zero.
+func (v *HMACTokenVerifier) ClientWeight(hs *ClientHandshake) uint64 {
+	if len(hs.Token) != hmacTokenLength {
+		return 0
+	}
+	var (
+		weight = binary.BigEndian.Uint64(hs.Token[:8])
+		expiry = int64(binary.BigEndian.Uint64(hs.Token[8:16]))
+	)
+	if !hmac.Equal(hs.Token[16:], hmacTokenMAC(v.key, hs.ID, hs.Token[:16])) {
+		return 0
+	}
+	if v.now().Unix() >= expiry {
+		return 0
+	}
+	return weight
+}
+
+// MakeHMACToken creates a priority token for the given client, granting it the
+// weight until the expiry time.
+func MakeHMACToken(key []byte, id enode.ID, weight uint64, expiry time.Time) []byte {
+	token := make([]byte, 16, hmacTokenLength)
+	binary.BigEndian.PutUint64(token[:8], weight)
+	binary.BigEndian.PutUint64(token[8:16], uint64(expiry.Unix()))
+	return append(token, hmacTokenMAC(key, id, token)...)
+}
+
+func hmacTokenMAC(key []byte, id enode.ID, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(id[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -119,7 +119,7 @@ type meteredMsgReadWriter struct {
 // newMeteredMsgWriter wraps a p2p MsgReadWriter with metering support. If the
 // metrics system is disabled, this function returns the original object.
 func newMeteredMsgWriter(rw p2p.MsgReadWriter, version int) p2p.MsgReadWriter {
-	if !metrics.Enabled {
+	if !metrics.Enabled() {
 		return rw
 	}
 	return &meteredMsgReadWriter{MsgReadWriter: rw, version: version}
@@ -33,6 +33,7 @@ type LesOdr struct {
 	indexerConfig                              *light.IndexerConfig
 	chtIndexer, bloomTrieIndexer, bloomIndexer *core.ChainIndexer
 	retriever                                  *retrieveManager
+	cache                                      *light.OdrCache
 	stop                                       chan struct{}
 }
 
@@ -41,6 +42,7 @@ func NewLesOdr(db ethdb.Database, config *light.IndexerConfig, retriever *retrie
 		db:            db,
 		indexerConfig: config,
 		retriever:     retriever,
+		cache:         light.NewOdrCache(db, light.DefaultOdrCacheLimit, light.DefaultOdrCacheRetention),
 		stop:          make(chan struct{}),
 	}
 }
@@ -55,6 +57,11 @@ func (odr *LesOdr) Database() ethdb.Database {
 	return odr.db
 }
 
+// Cache returns the cache tracking the retrieved data stored in the database
+func (odr *LesOdr) Cache() *light.OdrCache {
+	return odr.cache
+}
+
 // SetIndexers adds the necessary chain indexers to the ODR backend
 func (odr *LesOdr) SetIndexers(chtIndexer, bloomTrieIndexer, bloomIndexer *core.ChainIndexer) {
 	odr.chtIndexer = chtIndexer
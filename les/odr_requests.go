@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -518,6 +519,15 @@ func (r *TxStatusRequest) Validate(db ethdb.Database, msg *Msg) error {
 	if len(status) != len(r.Hashes) {
 		return errInvalidEntryCount
 	}
+	// Check the claimed positions of included transactions where possible
+	for i, stat := range status {
+		if stat.Status != core.TxStatusIncluded {
+			continue
+		}
+		if err := light.VerifyTxLookup(db, r.Hashes[i], stat.Lookup); err != nil {
+			return err
+		}
+	}
 	r.Status = status
 	return nil
 }
@@ -180,6 +180,86 @@ func odrTxStatus(ctx context.Context, db ethdb.Database, config *params.ChainCon
 	return rlp
 }
 
+func TestOdrTxLookupLes2(t *testing.T) { testOdrTxLookup(t, 2) }
+func TestOdrTxLookupLes3(t *testing.T) { testOdrTxLookup(t, 3) }
+
+// testOdrTxLookup tests that light clients can retrieve historical transactions
+// indexed by the server, while unindexed ones are reported as unknown.
+func testOdrTxLookup(t *testing.T, protocol int) {
+	server, client, tearDown := newClientServerEnv(t, 4, protocol, nil, nil, 0, false, true)
+	defer tearDown()
+
+	client.handler.synchronise(client.peer.peer)
+	waitForPeers = 0
+
+	var txs []*types.Transaction
+	for i := uint64(1); i <= server.handler.blockchain.CurrentHeader().Number.Uint64(); i++ {
+		txs = append(txs, server.handler.blockchain.GetBlockByNumber(i).Transactions()...)
+	}
+	if len(txs) < 2 {
+		t.Fatalf("not enough test transactions: %d", len(txs))
+	}
+	// Drop the lookup entry of the last transaction, as if it was beyond the index.
+	unindexed := txs[len(txs)-1]
+	rawdb.DeleteTxLookupEntry(server.db, unindexed.Hash())
+
+	odr := client.handler.backend.blockchain.Odr()
+	for _, tx := range txs {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		have, blockHash, number, index, err := light.GetTransaction(ctx, odr, tx.Hash())
+		cancel()
+		if err != nil {
+			t.Fatalf("transaction %x: retrieval failed: %v", tx.Hash(), err)
+		}
+		if tx == unindexed {
+			if have != nil {
+				t.Fatalf("unindexed transaction %x retrieved", tx.Hash())
+			}
+			continue
+		}
+		if have == nil || have.Hash() != tx.Hash() {
+			t.Fatalf("transaction %x: have %v", tx.Hash(), have)
+		}
+		lookup := server.handler.blockchain.GetTransactionLookup(tx.Hash())
+		if blockHash != lookup.BlockHash || number != lookup.BlockIndex || index != lookup.Index {
+			t.Fatalf("transaction %x: position mismatch: have %x/%d/%d, want %x/%d/%d", tx.Hash(), blockHash, number, index, lookup.BlockHash, lookup.BlockIndex, lookup.Index)
+		}
+	}
+}
+
+// Tests that transaction status responses claiming a position contradicting the
+// locally known block body are rejected.
+func TestTxStatusValidation(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		tx1   = types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		tx2   = types.NewTransaction(1, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		bhash = common.Hash{0xaa}
+		body  = &types.Body{Transactions: types.Transactions{tx1, tx2}}
+	)
+	rawdb.WriteBody(db, bhash, 1, body)
+
+	tests := []struct {
+		status light.TxStatus
+		err    error
+	}{
+		{light.TxStatus{Status: core.TxStatusPending}, nil},
+		{light.TxStatus{Status: core.TxStatusIncluded, Lookup: &rawdb.LegacyTxLookupEntry{BlockHash: bhash, BlockIndex: 1, Index: 0}}, nil},
+		{light.TxStatus{Status: core.TxStatusIncluded, Lookup: &rawdb.LegacyTxLookupEntry{BlockHash: bhash, BlockIndex: 1, Index: 1}}, light.ErrInvalidTxLookup},
+		{light.TxStatus{Status: core.TxStatusIncluded, Lookup: &rawdb.LegacyTxLookupEntry{BlockHash: bhash, BlockIndex: 1, Index: 2}}, light.ErrInvalidTxLookup},
+		{light.TxStatus{Status: core.TxStatusIncluded}, light.ErrInvalidTxLookup},
+		// Blocks without a local body can't be checked
+		{light.TxStatus{Status: core.TxStatusIncluded, Lookup: &rawdb.LegacyTxLookupEntry{BlockHash: common.Hash{0xbb}, BlockIndex: 2, Index: 5}}, nil},
+	}
+	for i, test := range tests {
+		req := &TxStatusRequest{Hashes: []common.Hash{tx1.Hash()}}
+		msg := &Msg{MsgType: MsgTxStatus, Obj: []light.TxStatus{test.status}}
+		if err := req.Validate(db, msg); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
+
 // testOdr tests odr requests whose validation guaranteed by block headers.
 func testOdr(t *testing.T, protocol int, expFail uint64, checkCached bool, fn odrTestFn) {
 	// Assemble the test environment
@@ -117,6 +117,8 @@ type peer struct {
 	onlyAnnounce            bool
 	chainSince, chainRecent uint64
 	stateSince, stateRecent uint64
+
+	token []byte // Priority token, sent by clients in the handshake
 }
 
 func newPeer(version int, network uint64, trusted bool, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -151,6 +153,11 @@ func (p *peer) freeClientId() string {
 	return p.id
 }
 
+// priorityToken returns the priority token the client sent in the handshake.
+func (p *peer) priorityToken() []byte {
+	return p.token
+}
+
 // rejectUpdate returns true if a parameter update has to be rejected because
 // the size and/or rate of updates exceed the capacity limitation
 func (p *peer) rejectUpdate(size uint64) bool {
@@ -630,6 +637,9 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 			p.announceType = announceTypeSigned
 		}
 		send = send.add("announceType", p.announceType)
+		if len(p.token) > 0 {
+			send = send.add("priorityToken", p.token)
+		}
 	}
 
 	recvList, err := p.sendReceiveHandshake(send)
@@ -679,6 +689,9 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 			// set default announceType on server side
 			p.announceType = announceTypeSimple
 		}
+		if recv.get("priorityToken", &p.token) != nil {
+			p.token = nil
+		}
 		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
 	} else {
 		if recv.get("serveChainSince", &p.chainSince) != nil {
@@ -17,6 +17,7 @@
 package les
 
 import (
+	"bytes"
 	"math/big"
 	"net"
 	"testing"
@@ -92,6 +93,44 @@ func TestPeerHandshakeSetAnnounceTypeToAnnounceTypeSignedForTrustedPeer(t *testi
 	}
 }
 
+func TestPeerHandshakeClientSendsPriorityToken(t *testing.T) {
+	var (
+		id    = newNodeID(t).ID()
+		token = []byte{1, 2, 3, 4}
+		sent  []byte
+	)
+	p := peer{
+		Peer:    p2p.NewPeer(id, "test peer", []p2p.Cap{}),
+		version: protocolVersion,
+		token:   token,
+		rw: &rwStub{
+			WriteHook: func(recvList keyValueList) {
+				recv, _ := recvList.decode()
+				if err := recv.get("priorityToken", &sent); err != nil {
+					t.Fatal(err)
+				}
+			},
+			ReadHook: func(l keyValueList) keyValueList {
+				l = l.add("serveHeaders", nil)
+				l = l.add("serveChainSince", uint64(0))
+				l = l.add("serveStateSince", uint64(0))
+				l = l.add("txRelay", nil)
+				l = l.add("flowControl/BL", uint64(0))
+				l = l.add("flowControl/MRR", uint64(0))
+				l = l.add("flowControl/MRC", testCostList(0))
+				return l
+			},
+		},
+		network: NetworkId,
+	}
+	if err := p.Handshake(td, hash, headNum, genesis, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sent, token) {
+		t.Fatalf("wrong priority token sent: got %x, want %x", sent, token)
+	}
+}
+
 func TestPeerHandshakeAnnounceTypeSignedForTrustedPeersPeerNotInTrusted(t *testing.T) {
 	id := newNodeID(t).ID()
 	p := peer{
@@ -211,6 +211,12 @@ func (s *LesServer) SetBloomBitsIndexer(bloomIndexer *core.ChainIndexer) {
 	bloomIndexer.AddChildIndexer(s.bloomTrieIndexer)
 }
 
+// SetClientPriority sets the hook assigning capacity weights to connecting light
+// clients, see ClientPriority. Clients connected before are not affected.
+func (s *LesServer) SetClientPriority(hook ClientPriority) {
+	s.clientPool.setPriorityHook(hook)
+}
+
 // SetClient sets the rpc client and starts running checkpoint contract if it is not yet watched.
 func (s *LesServer) SetContractBackend(backend bind.ContractBackend) {
 	if s.oracle == nil {
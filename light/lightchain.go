@@ -392,6 +392,9 @@ func (lc *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (i
 	}
 	i, err := lc.hc.InsertHeaderChain(chain, whFunc, start)
 	lc.postChainEvents(events)
+	if len(events) > 0 {
+		odrCacheOf(lc.odr).Prune(lc.CurrentHeader().Number.Uint64())
+	}
 	return i, err
 }
 
@@ -35,6 +35,10 @@ var NoOdr = context.Background()
 // ErrNoPeers is returned if no peers capable of serving a queued request are available
 var ErrNoPeers = errors.New("no suitable peers available")
 
+// ErrInvalidTxLookup is returned if a transaction is not found at the block
+// position given in a transaction status response.
+var ErrInvalidTxLookup = errors.New("transaction not found at claimed position")
+
 // OdrBackend is an interface to a backend service that handles ODR retrievals type
 type OdrBackend interface {
 	Database() ethdb.Database
@@ -0,0 +1,228 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+const (
+	// DefaultOdrCacheLimit is the default number of retrievals kept in the cache.
+	DefaultOdrCacheLimit = 16384
+
+	// DefaultOdrCacheRetention is the default number of blocks after which cached
+	// data of non-canonical blocks is dropped.
+	DefaultOdrCacheRetention = 128
+)
+
+var (
+	odrCacheHitMeter  = metrics.NewRegisteredMeter("light/odr/cache/hit", nil)
+	odrCacheMissMeter = metrics.NewRegisteredMeter("light/odr/cache/miss", nil)
+
+	odrCachePrefix = []byte("odr-cache-") // odrCachePrefix + id -> RLP(odrCacheEntry)
+)
+
+// odrCacheEntry records the database entries written for a retrieval, so they
+// can be deleted when the retrieval is evicted from the cache.
+type odrCacheEntry struct {
+	Number   uint64      // Number of the block the data was retrieved for
+	Hash     common.Hash // Hash of the block the data was retrieved for
+	Keys     [][]byte    // Database keys of the stored trie nodes and code
+	Receipts bool        // Whether the receipts of the block were stored
+}
+
+// OdrCache tracks the validated results of on-demand retrievals, which are stored
+// in the client database and served from there for repeated requests.
+//
+// The number of tracked retrievals is limited, the least recently used ones are
+// deleted from the database when the limit is exceeded. Once the chain head is
+// more than the retention window ahead, the results retrieved for blocks which
+// are no longer canonical are deleted as well.
+//
+// Trie nodes are shared between retrievals, so deleting a retrieval can remove
+// nodes needed by others. These are simply retrieved again when needed.
+type OdrCache struct {
+	db        ethdb.Database
+	retention uint64
+
+	lock    sync.Mutex
+	entries *simplelru.LRU // id -> *odrCacheEntry
+}
+
+// NewOdrCache creates a retrieval cache, loading the entries persisted in the
+// database.
+func NewOdrCache(db ethdb.Database, limit int, retention uint64) *OdrCache {
+	c := &OdrCache{db: db, retention: retention}
+	c.entries, _ = simplelru.NewLRU(limit, c.evicted)
+
+	type loaded struct {
+		id    common.Hash
+		entry *odrCacheEntry
+	}
+	var entries []loaded
+
+	it := db.NewIteratorWithPrefix(odrCachePrefix)
+	for it.Next() {
+		entry := new(odrCacheEntry)
+		if len(it.Key()) != len(odrCachePrefix)+common.HashLength || rlp.DecodeBytes(it.Value(), entry) != nil {
+			log.Warn("Dropping invalid ODR cache entry", "key", it.Key())
+			db.Delete(it.Key())
+			continue
+		}
+		entries = append(entries, loaded{common.BytesToHash(it.Key()[len(odrCachePrefix):]), entry})
+	}
+	it.Release()
+
+	// Treat retrievals for recent blocks as the most recently used ones
+	sort.Slice(entries, func(i, j int) bool { return entries[i].entry.Number < entries[j].entry.Number })
+	for _, e := range entries {
+		c.entries.Add(e.id, e.entry)
+	}
+	return c
+}
+
+// Len returns the number of cached retrievals.
+func (c *OdrCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.entries.Len()
+}
+
+// Prune deletes the retrievals for non-canonical blocks older than the retention
+// window ending at the given head.
+func (c *OdrCache) Prune(head uint64) {
+	if c == nil || head <= c.retention {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	limit := head - c.retention
+	for _, id := range c.entries.Keys() {
+		value, _ := c.entries.Peek(id)
+		entry := value.(*odrCacheEntry)
+		if entry.Number < limit && rawdb.ReadCanonicalHash(c.db, entry.Number) != entry.Hash {
+			c.entries.Remove(id)
+		}
+	}
+}
+
+// hit records that a request was served from the database.
+func (c *OdrCache) hit(id common.Hash) {
+	odrCacheHitMeter.Mark(1)
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries.Get(id)
+}
+
+// add starts tracking the database entries written by a completed retrieval.
+func (c *OdrCache) add(id common.Hash, req OdrRequest) {
+	odrCacheMissMeter.Mark(1)
+	if c == nil {
+		return
+	}
+	var entry *odrCacheEntry
+	switch req := req.(type) {
+	case *TrieRequest:
+		entry = &odrCacheEntry{Number: req.Id.BlockNumber, Hash: req.Id.BlockHash}
+		for _, node := range req.Proof.NodeList() {
+			entry.Keys = append(entry.Keys, crypto.Keccak256(node))
+		}
+	case *CodeRequest:
+		entry = &odrCacheEntry{Number: req.Id.BlockNumber, Hash: req.Id.BlockHash, Keys: [][]byte{req.Hash[:]}}
+	case *ReceiptsRequest:
+		if req.Untrusted {
+			return // not stored
+		}
+		entry = &odrCacheEntry{Number: req.Number, Hash: req.Hash, Receipts: true}
+	default:
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Keep tracking the entries of an earlier retrieval of the same data
+	if old, ok := c.entries.Peek(id); ok {
+		entry.Keys = append(old.(*odrCacheEntry).Keys, entry.Keys...)
+		entry.Receipts = entry.Receipts || old.(*odrCacheEntry).Receipts
+	}
+	blob, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		log.Crit("Failed to encode ODR cache entry", "err", err)
+	}
+	if err := c.db.Put(append(odrCachePrefix, id[:]...), blob); err != nil {
+		log.Crit("Failed to store ODR cache entry", "err", err)
+	}
+	c.entries.Add(id, entry)
+}
+
+// evicted deletes the data of a retrieval removed from the cache.
+func (c *OdrCache) evicted(key, value interface{}) {
+	var (
+		id    = key.(common.Hash)
+		entry = value.(*odrCacheEntry)
+		batch = c.db.NewBatch()
+	)
+	for _, key := range entry.Keys {
+		batch.Delete(key)
+	}
+	if entry.Receipts {
+		rawdb.DeleteReceipts(batch, entry.Hash, entry.Number)
+	}
+	batch.Delete(append(odrCachePrefix, id[:]...))
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete ODR cache entry", "err", err)
+	}
+}
+
+// trieCacheID returns the cache identifier of a trie entry retrieval.
+func trieCacheID(id *TrieID, key []byte) common.Hash {
+	return crypto.Keccak256Hash([]byte("trie"), id.BlockHash[:], id.Root[:], key)
+}
+
+// codeCacheID returns the cache identifier of a contract code retrieval.
+func codeCacheID(hash common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte("code"), hash[:])
+}
+
+// receiptsCacheID returns the cache identifier of a block receipts retrieval.
+func receiptsCacheID(hash common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte("receipts"), hash[:])
+}
+
+// odrCacheOf returns the retrieval cache of an ODR backend, or nil if it has none.
+func odrCacheOf(odr OdrBackend) *OdrCache {
+	if b, ok := odr.(interface{ Cache() *OdrCache }); ok {
+		return b.Cache()
+	}
+	return nil
+}
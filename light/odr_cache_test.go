@@ -0,0 +1,221 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// cachingTestOdr is a test ODR backend with a retrieval cache, counting the
+// network retrievals.
+type cachingTestOdr struct {
+	*testOdr
+	cache      *OdrCache
+	retrievals int
+}
+
+func (odr *cachingTestOdr) Retrieve(ctx context.Context, req OdrRequest) error {
+	odr.retrievals++
+	return odr.testOdr.Retrieve(ctx, req)
+}
+
+func (odr *cachingTestOdr) Cache() *OdrCache {
+	return odr.cache
+}
+
+// Tests that repeated requests for the same state, code and receipts are served
+// from the cache without any network retrievals.
+func TestOdrCacheRepeatedRequests(t *testing.T) {
+	var (
+		sdb     = rawdb.NewMemoryDatabase()
+		ldb     = rawdb.NewMemoryDatabase()
+		gspec   = core.Genesis{Alloc: core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}}}
+		genesis = gspec.MustCommit(sdb)
+	)
+	gspec.MustCommit(ldb)
+	blockchain, _ := core.NewBlockChain(sdb, nil, params.TestChainConfig, ethash.NewFullFaker(), vm.Config{}, nil)
+	gchain, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), sdb, 4, testChainGen)
+	if _, err := blockchain.InsertChain(gchain); err != nil {
+		t.Fatal(err)
+	}
+	odr := &cachingTestOdr{
+		testOdr: &testOdr{sdb: sdb, ldb: ldb, indexerConfig: TestClientIndexerConfig},
+		cache:   NewOdrCache(ldb, DefaultOdrCacheLimit, DefaultOdrCacheRetention),
+	}
+	lightchain, err := NewLightChain(odr, params.TestChainConfig, ethash.NewFullFaker(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := make([]*types.Header, len(gchain))
+	for i, block := range gchain {
+		headers[i] = block.Header()
+	}
+	if _, err := lightchain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatal(err)
+	}
+	request := func() {
+		t.Helper()
+
+		head := lightchain.CurrentHeader()
+		st := NewState(context.Background(), head, odr)
+		for _, addr := range []common.Address{testBankAddress, acc1Addr, acc2Addr} {
+			st.GetBalance(addr)
+		}
+		st.GetCode(testContractAddr)
+		st.GetState(testContractAddr, common.BigToHash(big.NewInt(1)))
+		if err := st.Error(); err != nil {
+			t.Fatalf("state access failed: %v", err)
+		}
+		if _, err := GetBlockReceipts(context.Background(), odr, gchain[1].Hash(), gchain[1].NumberU64()); err != nil {
+			t.Fatalf("receipt retrieval failed: %v", err)
+		}
+	}
+	request()
+	if odr.retrievals == 0 {
+		t.Fatal("no retrievals for the first requests")
+	}
+	if odr.cache.Len() == 0 {
+		t.Fatal("no retrievals cached")
+	}
+	odr.retrievals = 0
+	request()
+	if odr.retrievals != 0 {
+		t.Fatalf("repeated requests caused %d retrievals", odr.retrievals)
+	}
+	// The cache must survive restarts
+	if cache := NewOdrCache(ldb, DefaultOdrCacheLimit, DefaultOdrCacheRetention); cache.Len() != odr.cache.Len() {
+		t.Fatalf("reloaded cache size mismatch: have %d, want %d", cache.Len(), odr.cache.Len())
+	}
+}
+
+// Tests that a reorg switches the state of the latest block to the new branch,
+// and that cached data of the abandoned branch is dropped once it's older than
+// the retention window.
+func TestOdrCacheReorg(t *testing.T) {
+	var (
+		sdb     = rawdb.NewMemoryDatabase()
+		ldb     = rawdb.NewMemoryDatabase()
+		gspec   = core.Genesis{Alloc: core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}}}
+		genesis = gspec.MustCommit(sdb)
+		signer  = types.HomesteadSigner{}
+	)
+	gspec.MustCommit(ldb)
+	blockchain, _ := core.NewBlockChain(sdb, nil, params.TestChainConfig, ethash.NewFullFaker(), vm.Config{}, nil)
+
+	// Create two branches transferring different amounts to the same account
+	transfer := func(amount int64) func(int, *core.BlockGen) {
+		return func(i int, block *core.BlockGen) {
+			if i == 0 {
+				tx, _ := types.SignTx(types.NewTransaction(0, acc1Addr, big.NewInt(amount), params.TxGas, nil, nil), signer, testBankKey)
+				block.AddTx(tx)
+			}
+		}
+	}
+	chainA, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), sdb, 3, transfer(1000))
+	chainB, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), sdb, 8, transfer(2000))
+	if _, err := blockchain.InsertChain(chainA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blockchain.InsertChain(chainB); err != nil {
+		t.Fatal(err)
+	}
+	odr := &cachingTestOdr{
+		testOdr: &testOdr{sdb: sdb, ldb: ldb, indexerConfig: TestClientIndexerConfig},
+		cache:   NewOdrCache(ldb, DefaultOdrCacheLimit, 2),
+	}
+	lightchain, err := NewLightChain(odr, params.TestChainConfig, ethash.NewFullFaker(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := func(chain []*types.Block) {
+		t.Helper()
+
+		headers := make([]*types.Header, len(chain))
+		for i, block := range chain {
+			headers[i] = block.Header()
+		}
+		if _, err := lightchain.InsertHeaderChain(headers, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latestBalance := func() *big.Int {
+		t.Helper()
+
+		st := NewState(context.Background(), lightchain.CurrentHeader(), odr)
+		balance := st.GetBalance(acc1Addr)
+		if err := st.Error(); err != nil {
+			t.Fatalf("state access failed: %v", err)
+		}
+		return balance
+	}
+	insert(chainA)
+	if balance := latestBalance(); balance.Int64() != 1000 {
+		t.Fatalf("balance mismatch on first branch: have %v, want 1000", balance)
+	}
+	cachedA := odr.cache.Len()
+
+	// Reorg to the longer branch, the head is now past the retention window
+	// of the abandoned blocks, so their cached data is dropped.
+	insert(chainB)
+	if odr.cache.Len() >= cachedA {
+		t.Fatalf("abandoned branch not pruned: %d entries, %d before reorg", odr.cache.Len(), cachedA)
+	}
+	if balance := latestBalance(); balance.Int64() != 2000 {
+		t.Fatalf("balance mismatch after reorg: have %v, want 2000", balance)
+	}
+}
+
+// Tests that the least recently used retrievals are evicted along with their
+// data once the cache is full.
+func TestOdrCacheEviction(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		cache = NewOdrCache(db, 2, DefaultOdrCacheRetention)
+		codes = [][]byte{{1}, {2}, {3}}
+	)
+	add := func(code []byte) {
+		hash := crypto.Keccak256Hash(code)
+		db.Put(hash[:], code)
+		cache.add(codeCacheID(hash), &CodeRequest{Id: &TrieID{BlockNumber: 1}, Hash: hash, Data: code})
+	}
+	stored := func(code []byte) bool {
+		ok, _ := db.Has(crypto.Keccak256(code))
+		return ok
+	}
+	add(codes[0])
+	add(codes[1])
+	cache.hit(codeCacheID(crypto.Keccak256Hash(codes[0])))
+	add(codes[2])
+
+	if cache.Len() != 2 {
+		t.Fatalf("cache size mismatch: have %d, want 2", cache.Len())
+	}
+	if !stored(codes[0]) || stored(codes[1]) || !stored(codes[2]) {
+		t.Fatalf("wrong code evicted: stored %v %v %v", stored(codes[0]), stored(codes[1]), stored(codes[2]))
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -138,13 +139,17 @@ func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint
 // in a block given by its hash.
 func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, error) {
 	// Assume receipts are already stored locally and attempt to retrieve.
+	cache := odrCacheOf(odr)
 	receipts := rawdb.ReadRawReceipts(odr.Database(), hash, number)
 	if receipts == nil {
 		r := &ReceiptsRequest{Hash: hash, Number: number}
 		if err := odr.Retrieve(ctx, r); err != nil {
 			return nil, err
 		}
+		cache.add(receiptsCacheID(hash), r)
 		receipts = r.Receipts
+	} else {
+		cache.hit(receiptsCacheID(hash))
 	}
 	// If the receipts are incomplete, fill the derived fields
 	if len(receipts) > 0 && receipts[0].TxHash == (common.Hash{}) {
@@ -264,21 +269,50 @@ func GetBloomBits(ctx context.Context, odr OdrBackend, bitIdx uint, sectionIdxLi
 }
 
 // GetTransaction retrieves a canonical transaction by hash and also returns its position in the chain
+// GetTransaction retrieves a canonical transaction by hash, along with the hash
+// and number of the including block and its index in the block.
+//
+// The block position is reported by the server and checked against the retrieved
+// block body. If the transaction is not at the claimed position, the status is
+// requested once more. As the body is now available locally, the response of the
+// misbehaving server fails validation and another server is asked.
 func GetTransaction(ctx context.Context, odr OdrBackend, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
-	r := &TxStatusRequest{Hashes: []common.Hash{txHash}}
-	if err := odr.Retrieve(ctx, r); err != nil || r.Status[0].Status != core.TxStatusIncluded {
-		return nil, common.Hash{}, 0, 0, err
-	} else {
+	for i := 0; i < 2; i++ {
+		r := &TxStatusRequest{Hashes: []common.Hash{txHash}}
+		if err := odr.Retrieve(ctx, r); err != nil || r.Status[0].Status != core.TxStatusIncluded {
+			return nil, common.Hash{}, 0, 0, err
+		}
 		pos := r.Status[0].Lookup
 		// first ensure that we have the header, otherwise block body retrieval will fail
 		// also verify if this is a canonical block by getting the header by number and checking its hash
 		if header, err := GetHeaderByNumber(ctx, odr, pos.BlockIndex); err != nil || header.Hash() != pos.BlockHash {
 			return nil, common.Hash{}, 0, 0, err
 		}
-		if body, err := GetBody(ctx, odr, pos.BlockHash, pos.BlockIndex); err != nil || uint64(len(body.Transactions)) <= pos.Index || body.Transactions[pos.Index].Hash() != txHash {
+		body, err := GetBody(ctx, odr, pos.BlockHash, pos.BlockIndex)
+		if err != nil {
 			return nil, common.Hash{}, 0, 0, err
-		} else {
+		}
+		if uint64(len(body.Transactions)) > pos.Index && body.Transactions[pos.Index].Hash() == txHash {
 			return body.Transactions[pos.Index], pos.BlockHash, pos.BlockIndex, pos.Index, nil
 		}
 	}
+	return nil, common.Hash{}, 0, 0, ErrInvalidTxLookup
+}
+
+// VerifyTxLookup checks the position of an included transaction reported by a
+// server against the locally stored block body. Bodies are only stored after they
+// were verified against the transaction root of their header, so a mismatch proves
+// the server wrong. Positions in blocks without a local body can't be checked yet.
+func VerifyTxLookup(db ethdb.Database, txHash common.Hash, lookup *rawdb.LegacyTxLookupEntry) error {
+	if lookup == nil {
+		return ErrInvalidTxLookup
+	}
+	body := rawdb.ReadBody(db, lookup.BlockHash, lookup.BlockIndex)
+	if body == nil {
+		return nil
+	}
+	if uint64(len(body.Transactions)) <= lookup.Index || body.Transactions[lookup.Index].Hash() != txHash {
+		return ErrInvalidTxLookup
+	}
+	return nil
 }
@@ -70,14 +70,19 @@ func (db *odrDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, err
 	if codeHash == sha3Nil {
 		return nil, nil
 	}
+	cache := odrCacheOf(db.backend)
 	if code, err := db.backend.Database().Get(codeHash[:]); err == nil {
+		cache.hit(codeCacheID(codeHash))
 		return code, nil
 	}
 	id := *db.id
 	id.AccKey = addrHash[:]
 	req := &CodeRequest{Id: &id, Hash: codeHash}
-	err := db.backend.Retrieve(db.ctx, req)
-	return req.Data, err
+	if err := db.backend.Retrieve(db.ctx, req); err != nil {
+		return nil, err
+	}
+	cache.add(codeCacheID(codeHash), req)
+	return req.Data, nil
 }
 
 func (db *odrDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
@@ -148,6 +153,10 @@ func (t *odrTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter
 // do tries and retries to execute a function until it returns with no error or
 // an error type other than MissingNodeError
 func (t *odrTrie) do(key []byte, fn func() error) error {
+	var (
+		cache     = odrCacheOf(t.db.backend)
+		retrieved bool
+	)
 	for {
 		var err error
 		if t.trie == nil {
@@ -157,12 +166,17 @@ func (t *odrTrie) do(key []byte, fn func() error) error {
 			err = fn()
 		}
 		if _, ok := err.(*trie.MissingNodeError); !ok {
+			if err == nil && !retrieved {
+				cache.hit(trieCacheID(t.id, key))
+			}
 			return err
 		}
 		r := &TrieRequest{Id: t.id, Key: key}
 		if err := t.db.backend.Retrieve(t.db.ctx, r); err != nil {
 			return err
 		}
+		cache.add(trieCacheID(t.id, key), r)
+		retrieved = true
 	}
 }
 
@@ -180,6 +180,59 @@ func RotatingFileHandler(path string, limit uint, formatter Format) (Handler, er
 	}), nil
 }
 
+// SizeRotatingFileHandler returns a handler which writes log records to the file
+// at the given path. Before the file exceeds the size limit in bytes, it's renamed
+// to path.1, shifting the older files to path.2 and so on, and a new file is
+// started. At most backups old files are kept. This is the layout expected by log
+// shippers and rotation tools. A zero limit disables the rotation.
+func SizeRotatingFileHandler(path string, limit uint, backups int, fmtr Format) (Handler, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	counter := &countingWriter{w: f, count: uint(info.Size())}
+
+	h := FuncHandler(func(r *Record) error {
+		msg := fmtr.Format(r)
+		if limit > 0 && counter.count > 0 && counter.count+uint(len(msg)) > limit {
+			if err := rotateFile(counter, path, backups); err != nil {
+				return err
+			}
+		}
+		_, err := counter.Write(msg)
+		return err
+	})
+	return closingHandler{counter, LazyHandler(SyncHandler(h))}, nil
+}
+
+// rotateFile closes the log file written by the counter, shifts it into the
+// numbered backups, dropping the oldest one, and opens a new file at path.
+func rotateFile(counter *countingWriter, path string, backups int) error {
+	counter.Close()
+	if backups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", path, backups))
+		for i := backups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		}
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	counter.w, counter.count = f, 0
+	return nil
+}
+
 // NetHandler opens a socket to the given address and writes records
 // over the connection.
 func NetHandler(network, addr string, fmtr Format) (Handler, error) {
@@ -19,6 +19,7 @@ package log
 import (
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -28,7 +29,11 @@ import (
 )
 
 // errVmoduleSyntax is returned when a user vmodule pattern is invalid.
-var errVmoduleSyntax = errors.New("expect comma-separated list of filename=N")
+var errVmoduleSyntax = errors.New("expect comma-separated list of filename=N or module:name=N")
+
+// modulePrefix marks the vmodule rules matching the "module" context attribute
+// of records instead of their callsite.
+const modulePrefix = "module:"
 
 // errTraceSyntax is returned when a user backtrace pattern is invalid.
 var errTraceSyntax = errors.New("expect file.go:234")
@@ -44,6 +49,7 @@ type GlogHandler struct {
 	backtrace uint32 // Flag whether backtrace location is set
 
 	patterns  []pattern       // Current list of patterns to override with
+	modules   []modulePattern // Current list of module patterns to override with
 	siteCache map[uintptr]Lvl // Cache of callsite pattern evaluations
 	location  string          // file:line location where to do a stackdump at
 	lock      sync.RWMutex    // Lock protecting the override pattern list
@@ -69,6 +75,13 @@ type pattern struct {
 	level   Lvl
 }
 
+// modulePattern contains a filter for the Vmodule option, holding a verbosity
+// level and a glob pattern matching the "module" context attribute of records.
+type modulePattern struct {
+	pattern string
+	level   Lvl
+}
+
 // Verbosity sets the glog verbosity ceiling. The verbosity of individual packages
 // and source files can be raised using Vmodule.
 func (h *GlogHandler) Verbosity(level Lvl) {
@@ -90,8 +103,20 @@ func (h *GlogHandler) Verbosity(level Lvl) {
 //
 //  pattern="foo/*=3"
 //   sets V to 3 in all files of any packages whose import path contains "foo"
+//
+//  pattern="module:downloader=5"
+//   sets V to 5 for all records whose "module" context attribute is "downloader"
+//
+//  pattern="module:eth/*=4"
+//   sets V to 4 for all records whose "module" attribute matches the glob "eth/*"
+//
+// Module rules are checked before the file ones, and are evaluated for every
+// record below the verbosity ceiling, as the attribute depends on the logger.
 func (h *GlogHandler) Vmodule(ruleset string) error {
-	var filter []pattern
+	var (
+		filter  []pattern
+		modules []modulePattern
+	)
 	for _, rule := range strings.Split(ruleset, ",") {
 		// Empty strings such as from a trailing comma can be ignored
 		if len(rule) == 0 {
@@ -115,6 +140,15 @@ func (h *GlogHandler) Vmodule(ruleset string) error {
 		if level <= 0 {
 			continue // Ignore. It's harmless but no point in paying the overhead.
 		}
+		// Module rules are matched against the context attribute as globs
+		if strings.HasPrefix(parts[0], modulePrefix) {
+			glob := strings.TrimPrefix(parts[0], modulePrefix)
+			if _, err := path.Match(glob, ""); err != nil || glob == "" {
+				return errVmoduleSyntax
+			}
+			modules = append(modules, modulePattern{glob, Lvl(level)})
+			continue
+		}
 		// Compile the rule pattern into a regular expression
 		matcher := ".*"
 		for _, comp := range strings.Split(parts[0], "/") {
@@ -137,8 +171,9 @@ func (h *GlogHandler) Vmodule(ruleset string) error {
 	defer h.lock.Unlock()
 
 	h.patterns = filter
+	h.modules = modules
 	h.siteCache = make(map[uintptr]Lvl)
-	atomic.StoreUint32(&h.override, uint32(len(filter)))
+	atomic.StoreUint32(&h.override, uint32(len(filter)+len(modules)))
 
 	return nil
 }
@@ -205,8 +240,22 @@ func (h *GlogHandler) Log(r *Record) error {
 	if atomic.LoadUint32(&h.override) == 0 {
 		return nil
 	}
-	// Check callsite cache for previously calculated log levels
+	// Check the module rules, which can't be cached by callsite
 	h.lock.RLock()
+	if len(h.modules) > 0 {
+		if module, ok := recordModule(r); ok {
+			for _, rule := range h.modules {
+				if matched, _ := path.Match(rule.pattern, module); matched {
+					h.lock.RUnlock()
+					if rule.level >= r.Lvl {
+						return h.origin.Log(r)
+					}
+					return nil
+				}
+			}
+		}
+	}
+	// Check callsite cache for previously calculated log levels
 	lvl, ok := h.siteCache[r.Call.Frame().PC]
 	h.lock.RUnlock()
 
@@ -230,3 +279,14 @@ func (h *GlogHandler) Log(r *Record) error {
 	}
 	return nil
 }
+
+// recordModule returns the "module" context attribute of a record, if any.
+func recordModule(r *Record) (string, bool) {
+	for i := 0; i+1 < len(r.Ctx); i += 2 {
+		if key, ok := r.Ctx[i].(string); ok && key == "module" {
+			module, ok := r.Ctx[i+1].(string)
+			return module, ok
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"testing"
+)
+
+// recordingHandler collects the messages of the records passed to it.
+type recordingHandler struct {
+	msgs []string
+}
+
+func (h *recordingHandler) Log(r *Record) error {
+	h.msgs = append(h.msgs, r.Msg)
+	return nil
+}
+
+// Tests that vmodule rules matching the "module" context attribute raise the
+// verbosity of the matching loggers only, taking effect on subsequent records.
+func TestGlogModuleRules(t *testing.T) {
+	var (
+		out    = new(recordingHandler)
+		glog   = NewGlogHandler(out)
+		logger = New()
+	)
+	logger.SetHandler(glog)
+	glog.Verbosity(LvlInfo)
+
+	var (
+		downloader = logger.New("module", "downloader")
+		fetcher    = logger.New("module", "eth/fetcher")
+		handler    = logger.New("module", "eth/handler")
+	)
+	log := func() {
+		out.msgs = nil
+		downloader.Trace("downloader")
+		fetcher.Debug("fetcher")
+		handler.Trace("handler")
+		logger.Debug("root")
+	}
+	log()
+	if len(out.msgs) != 0 {
+		t.Fatalf("records above verbosity emitted: %v", out.msgs)
+	}
+	if err := glog.Vmodule("module:downloader=5,module:eth/*=4"); err != nil {
+		t.Fatalf("failed to set vmodule: %v", err)
+	}
+	log()
+	want := []string{"downloader", "fetcher"}
+	if len(out.msgs) != len(want) || out.msgs[0] != want[0] || out.msgs[1] != want[1] {
+		t.Fatalf("emitted records mismatch: have %v, want %v", out.msgs, want)
+	}
+	// Resetting the rules must silence the modules again
+	if err := glog.Vmodule(""); err != nil {
+		t.Fatalf("failed to reset vmodule: %v", err)
+	}
+	log()
+	if len(out.msgs) != 0 {
+		t.Fatalf("records above verbosity emitted after reset: %v", out.msgs)
+	}
+}
+
+func TestGlogVmoduleSyntax(t *testing.T) {
+	glog := NewGlogHandler(DiscardHandler())
+	for _, rule := range []string{"module:=5", "module:[=5", "module:eth"} {
+		if err := glog.Vmodule(rule); err != errVmoduleSyntax {
+			t.Errorf("rule %q: error mismatch: have %v, want %v", rule, err, errVmoduleSyntax)
+		}
+	}
+	if err := glog.Vmodule("module:eth/*=5,p2p=4"); err != nil {
+		t.Errorf("valid rules rejected: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the JSON format emits one flat object per record.
+func TestJSONFormat(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		logger = New("module", "downloader")
+	)
+	logger.SetHandler(StreamHandler(&buf, JSONFormat()))
+	logger.Info("Imported blocks", "count", 3, "err", errors.New("boom"))
+	logger.Warn("Stalled")
+
+	scanner := bufio.NewScanner(&buf)
+	var records []map[string]interface{}
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid JSON record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("record count mismatch: have %d, want 2", len(records))
+	}
+	want := map[string]interface{}{
+		"lvl":    "info",
+		"msg":    "Imported blocks",
+		"module": "downloader",
+		"count":  float64(3),
+		"err":    "boom",
+	}
+	for key, value := range want {
+		if records[0][key] != value {
+			t.Errorf("field %s mismatch: have %v, want %v", key, records[0][key], value)
+		}
+	}
+	if ts, ok := records[0]["t"].(string); !ok {
+		t.Errorf("timestamp missing: %v", records[0])
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Errorf("invalid timestamp %q: %v", ts, err)
+	}
+	if len(records[0]) != len(want)+1 {
+		t.Errorf("unexpected fields: %v", records[0])
+	}
+	if records[1]["lvl"] != "warn" || records[1]["module"] != "downloader" {
+		t.Errorf("second record mismatch: %v", records[1])
+	}
+}
+
+// Tests that the size rotating file handler shifts full files into numbered
+// backups, keeping only the configured number of them.
+func TestSizeRotatingFileHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "geth.log")
+	format := FormatFunc(func(r *Record) []byte { return []byte(r.Msg + "\n") })
+	h, err := SizeRotatingFileHandler(path, 20, 2, format)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	closer := h.(closingHandler)
+	defer closer.Close()
+
+	logger := New()
+	logger.SetHandler(h)
+	for _, msg := range []string{"record 1", "record 2", "record 3", "record 4", "record 5", "record 6", "record 7"} {
+		logger.Info(msg)
+	}
+	// Two records fit into a file, the oldest ones must have been dropped
+	want := map[string]string{
+		"geth.log":   "record 7\n",
+		"geth.log.1": "record 5\nrecord 6\n",
+		"geth.log.2": "record 3\nrecord 4\n",
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != len(want) {
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		t.Fatalf("log files mismatch: have %s", strings.Join(names, ", "))
+	}
+	for name, content := range want {
+		blob, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if string(blob) != content {
+			t.Errorf("%s content mismatch: have %q, want %q", name, blob, content)
+		}
+	}
+}
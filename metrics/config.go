@@ -0,0 +1,85 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"errors"
+	"time"
+)
+
+// Config contains the configuration of the metrics collection and of the
+// exporters reporting them.
+type Config struct {
+	Enabled          bool
+	EnabledExpensive bool
+
+	// Prometheus exposition endpoint
+	EnablePrometheus    bool
+	PrometheusAddr      string
+	PrometheusPort      int
+	PrometheusPath      string
+	PrometheusQuantiles string // Comma separated quantiles of histograms and timers
+
+	// InfluxDB exporter settings shared by the v1 and v2 APIs
+	InfluxDBEndpoint string
+	InfluxDBTags     string        // Comma separated key=value tags of all measurements
+	InfluxDBInterval time.Duration // Interval between two reports
+
+	// InfluxDB v1 exporter, authenticated by username and password
+	EnableInfluxDB   bool
+	InfluxDBDatabase string
+	InfluxDBUsername string
+	InfluxDBPassword string
+
+	// InfluxDB v2 exporter, authenticated by token
+	EnableInfluxDBV2     bool
+	InfluxDBToken        string
+	InfluxDBBucket       string
+	InfluxDBOrganization string
+}
+
+// DefaultConfig is the default config for metrics used in go-ethereum.
+var DefaultConfig = Config{
+	PrometheusAddr:      "127.0.0.1",
+	PrometheusPort:      6061,
+	PrometheusPath:      "/metrics",
+	PrometheusQuantiles: "0.5,0.75,0.95,0.99,0.999,0.9999",
+
+	InfluxDBEndpoint: "http://localhost:8086",
+	InfluxDBInterval: 10 * time.Second,
+
+	InfluxDBDatabase: "geth",
+	InfluxDBUsername: "test",
+	InfluxDBPassword: "test",
+
+	InfluxDBBucket:       "geth",
+	InfluxDBOrganization: "geth",
+}
+
+// Validate checks the config for conflicting or missing settings.
+func (c *Config) Validate() error {
+	if c.EnableInfluxDB && c.EnableInfluxDBV2 {
+		return errors.New("the InfluxDB v1 and v2 exporters are mutually exclusive")
+	}
+	if (c.EnableInfluxDB || c.EnableInfluxDBV2) && c.InfluxDBInterval <= 0 {
+		return errors.New("the InfluxDB report interval must be positive")
+	}
+	if c.EnableInfluxDBV2 && c.InfluxDBToken == "" {
+		return errors.New("the InfluxDB v2 exporter requires a token")
+	}
+	return nil
+}
@@ -35,7 +35,7 @@ func GetOrRegisterCounterForced(name string, r Registry) Counter {
 
 // NewCounter constructs a new StandardCounter.
 func NewCounter() Counter {
-	if !Enabled {
+	if !Enabled() {
 		return NilCounter{}
 	}
 	return &StandardCounter{0}
@@ -49,7 +49,7 @@ func NewCounterForced() Counter {
 
 // NewRegisteredCounter constructs and registers a new StandardCounter.
 func NewRegisteredCounter(name string, r Registry) Counter {
-	c := NewCounter()
+	c := newLazyCounter(NewCounter)
 	if nil == r {
 		r = DefaultRegistry
 	}
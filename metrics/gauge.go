@@ -22,7 +22,7 @@ func GetOrRegisterGauge(name string, r Registry) Gauge {
 
 // NewGauge constructs a new StandardGauge.
 func NewGauge() Gauge {
-	if !Enabled {
+	if !Enabled() {
 		return NilGauge{}
 	}
 	return &StandardGauge{0}
@@ -30,7 +30,7 @@ func NewGauge() Gauge {
 
 // NewRegisteredGauge constructs and registers a new StandardGauge.
 func NewRegisteredGauge(name string, r Registry) Gauge {
-	c := NewGauge()
+	c := newLazyGauge(NewGauge)
 	if nil == r {
 		r = DefaultRegistry
 	}
@@ -40,7 +40,7 @@ func NewRegisteredGauge(name string, r Registry) Gauge {
 
 // NewFunctionalGauge constructs a new FunctionalGauge.
 func NewFunctionalGauge(f func() int64) Gauge {
-	if !Enabled {
+	if !Enabled() {
 		return NilGauge{}
 	}
 	return &FunctionalGauge{value: f}
@@ -48,7 +48,7 @@ func NewFunctionalGauge(f func() int64) Gauge {
 
 // NewRegisteredFunctionalGauge constructs and registers a new StandardGauge.
 func NewRegisteredFunctionalGauge(name string, r Registry, f func() int64) Gauge {
-	c := NewFunctionalGauge(f)
+	c := newLazyGauge(func() Gauge { return NewFunctionalGauge(f) })
 	if nil == r {
 		r = DefaultRegistry
 	}
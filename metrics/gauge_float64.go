@@ -20,7 +20,7 @@ func GetOrRegisterGaugeFloat64(name string, r Registry) GaugeFloat64 {
 
 // NewGaugeFloat64 constructs a new StandardGaugeFloat64.
 func NewGaugeFloat64() GaugeFloat64 {
-	if !Enabled {
+	if !Enabled() {
 		return NilGaugeFloat64{}
 	}
 	return &StandardGaugeFloat64{
@@ -30,7 +30,7 @@ func NewGaugeFloat64() GaugeFloat64 {
 
 // NewRegisteredGaugeFloat64 constructs and registers a new StandardGaugeFloat64.
 func NewRegisteredGaugeFloat64(name string, r Registry) GaugeFloat64 {
-	c := NewGaugeFloat64()
+	c := newLazyGaugeFloat64(NewGaugeFloat64)
 	if nil == r {
 		r = DefaultRegistry
 	}
@@ -40,7 +40,7 @@ func NewRegisteredGaugeFloat64(name string, r Registry) GaugeFloat64 {
 
 // NewFunctionalGauge constructs a new FunctionalGauge.
 func NewFunctionalGaugeFloat64(f func() float64) GaugeFloat64 {
-	if !Enabled {
+	if !Enabled() {
 		return NilGaugeFloat64{}
 	}
 	return &FunctionalGaugeFloat64{value: f}
@@ -48,7 +48,7 @@ func NewFunctionalGaugeFloat64(f func() float64) GaugeFloat64 {
 
 // NewRegisteredFunctionalGauge constructs and registers a new StandardGauge.
 func NewRegisteredFunctionalGaugeFloat64(name string, r Registry, f func() float64) GaugeFloat64 {
-	c := NewFunctionalGaugeFloat64(f)
+	c := newLazyGaugeFloat64(func() GaugeFloat64 { return NewFunctionalGaugeFloat64(f) })
 	if nil == r {
 		r = DefaultRegistry
 	}
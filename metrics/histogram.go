@@ -28,7 +28,7 @@ func GetOrRegisterHistogram(name string, r Registry, s Sample) Histogram {
 
 // NewHistogram constructs a new StandardHistogram from a Sample.
 func NewHistogram(s Sample) Histogram {
-	if !Enabled {
+	if !Enabled() {
 		return NilHistogram{}
 	}
 	return &StandardHistogram{sample: s}
@@ -37,7 +37,7 @@ func NewHistogram(s Sample) Histogram {
 // NewRegisteredHistogram constructs and registers a new StandardHistogram from
 // a Sample.
 func NewRegisteredHistogram(name string, r Registry, s Sample) Histogram {
-	c := NewHistogram(s)
+	c := newLazyHistogram(func() Histogram { return NewHistogram(s) })
 	if nil == r {
 		r = DefaultRegistry
 	}
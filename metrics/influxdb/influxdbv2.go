@@ -0,0 +1,245 @@
+package influxdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	uurl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// v2Timeout is the time allowed for a write request to complete.
+	v2Timeout = 10 * time.Second
+
+	// v2MaxBackoff is the upper limit of the delay between two reports after
+	// consecutive failures.
+	v2MaxBackoff = 5 * time.Minute
+)
+
+// v2Reporter reports metrics to an InfluxDB v2 server (or Influx Cloud) through
+// the line protocol write endpoint, authenticated by a token.
+type v2Reporter struct {
+	reg      metrics.Registry
+	interval time.Duration
+
+	write     string // URL of the write endpoint, including the bucket and organization
+	token     string
+	namespace string
+	tags      map[string]string
+
+	client   *http.Client
+	cache    map[string]int64
+	dropped  metrics.Counter // Number of points dropped due to failed writes
+	failures int             // Number of consecutive failed writes
+}
+
+// InfluxDBV2WithTags starts an InfluxDB v2 reporter which will post the metrics
+// from the given metrics.Registry at each d interval with the specified tags.
+// Failed reports are dropped, and reporting backs off exponentially until the
+// server accepts writes again.
+func InfluxDBV2WithTags(r metrics.Registry, d time.Duration, endpoint, token, bucket, organization, namespace string, tags map[string]string) {
+	rep, err := newV2Reporter(r, d, endpoint, token, bucket, organization, namespace, tags)
+	if err != nil {
+		log.Warn("Unable to create InfluxDB v2 reporter", "err", err)
+		return
+	}
+	rep.run()
+}
+
+// InfluxDBV2WithTagsOnce runs once an InfluxDB v2 reporter and posts the given
+// metrics.Registry with the specified tags.
+func InfluxDBV2WithTagsOnce(r metrics.Registry, endpoint, token, bucket, organization, namespace string, tags map[string]string) error {
+	rep, err := newV2Reporter(r, 0, endpoint, token, bucket, organization, namespace, tags)
+	if err != nil {
+		return err
+	}
+	if err := rep.send(); err != nil {
+		return fmt.Errorf("unable to send to InfluxDB. err: %v", err)
+	}
+	return nil
+}
+
+func newV2Reporter(r metrics.Registry, d time.Duration, endpoint, token, bucket, organization, namespace string, tags map[string]string) (*v2Reporter, error) {
+	u, err := uurl.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse InfluxDB. url: %s, err: %v", endpoint, err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+	u.RawQuery = uurl.Values{
+		"org":       {organization},
+		"bucket":    {bucket},
+		"precision": {"ns"},
+	}.Encode()
+
+	return &v2Reporter{
+		reg:       r,
+		interval:  d,
+		write:     u.String(),
+		token:     token,
+		namespace: namespace,
+		tags:      tags,
+		client:    &http.Client{Timeout: v2Timeout},
+		cache:     make(map[string]int64),
+		dropped:   metrics.GetOrRegisterCounterForced("metrics/influxdb/dropped", r),
+	}, nil
+}
+
+func (r *v2Reporter) run() {
+	timer := time.NewTimer(r.interval)
+	defer timer.Stop()
+
+	for range timer.C {
+		if err := r.send(); err != nil {
+			log.Warn("Unable to send to InfluxDB", "err", err, "retry", r.delay())
+		}
+		timer.Reset(r.delay())
+	}
+}
+
+// delay returns the time to wait before the next report, backing off after
+// consecutive failed writes.
+func (r *v2Reporter) delay() time.Duration {
+	delay := r.interval
+	for i := 0; i < r.failures && delay < v2MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > v2MaxBackoff && r.interval < v2MaxBackoff {
+		delay = v2MaxBackoff
+	}
+	return delay
+}
+
+// send writes the current values of all metrics to the server. The points are
+// dropped if the write fails.
+func (r *v2Reporter) send() error {
+	var (
+		buf    bytes.Buffer
+		points int64
+		tags   = encodeTags(r.tags)
+	)
+	r.reg.Each(func(name string, i interface{}) {
+		now := time.Now()
+		measurement, fields := readMeter(r.namespace, name, i, r.cache)
+		if fields == nil {
+			return
+		}
+		if encodePoint(&buf, measurement, tags, fields, now) {
+			points++
+		}
+	})
+	if points == 0 {
+		return nil
+	}
+	if err := r.post(&buf); err != nil {
+		r.failures++
+		r.dropped.Inc(points)
+		return err
+	}
+	r.failures = 0
+	return nil
+}
+
+// post sends a batch of points in line protocol to the write endpoint.
+func (r *v2Reporter) post(body io.Reader) error {
+	req, err := http.NewRequest(http.MethodPost, r.write, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+r.token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("write rejected: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Characters to escape in the different elements of the line protocol.
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// encodeTags renders the tags in the line protocol, sorted by key as recommended
+// by InfluxDB. Tags with an empty key or value are not allowed and are skipped.
+func encodeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if key != "" && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(key))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(tags[key]))
+	}
+	return b.String()
+}
+
+// encodePoint writes a point in line protocol to the buffer, sorting the fields
+// by key. Non-finite float fields can't be represented and are skipped; the point
+// is omitted if no field remains, in which case false is returned.
+func encodePoint(buf *bytes.Buffer, measurement, tags string, fields map[string]interface{}, ts time.Time) bool {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var line []byte
+	for _, key := range keys {
+		var value []byte
+		switch v := fields[key].(type) {
+		case int:
+			value = append(strconv.AppendInt(nil, int64(v), 10), 'i')
+		case int64:
+			value = append(strconv.AppendInt(nil, v, 10), 'i')
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			value = strconv.AppendFloat(nil, v, 'f', -1, 64)
+		default:
+			continue
+		}
+		if len(line) > 0 {
+			line = append(line, ',')
+		}
+		line = append(line, keyEscaper.Replace(key)...)
+		line = append(line, '=')
+		line = append(line, value...)
+	}
+	if len(line) == 0 {
+		return false
+	}
+	buf.WriteString(measurementEscaper.Replace(measurement))
+	buf.WriteString(tags)
+	buf.WriteByte(' ')
+	buf.Write(line)
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(ts.UnixNano(), 10))
+	buf.WriteByte('\n')
+	return true
+}
@@ -0,0 +1,198 @@
+package influxdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func init() {
+	metrics.Enable()
+}
+
+// request is a write request received by the test server.
+type request struct {
+	path, query string
+	header      http.Header
+	body        string
+}
+
+// newTestServer creates an InfluxDB v2 mock, recording the write requests and
+// responding with the given status code.
+func newTestServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	reqs := make(chan request, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		reqs <- request{path: r.URL.Path, query: r.URL.RawQuery, header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	return srv, reqs
+}
+
+// Tests that every metric type is encoded in line protocol, and that the writes
+// are authenticated and address the configured bucket.
+func TestInfluxDBV2Write(t *testing.T) {
+	srv, reqs := newTestServer(t, http.StatusNoContent)
+	defer srv.Close()
+
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("chain/inserts", r).Inc(3)
+	metrics.NewRegisteredGauge("txpool/pending", r).Update(7)
+	metrics.NewRegisteredGaugeFloat64("system/load", r).Update(0.25)
+	metrics.NewRegisteredMeter("p2p/ingress", r).Mark(10)
+	metrics.NewRegisteredHistogram("trie/depth", r, metrics.NewUniformSample(10)).Update(4)
+	metrics.NewRegisteredTimer("chain/validation", r).Update(time.Millisecond)
+	metrics.NewRegisteredResettingTimer("chain/account reads", r).Update(2 * time.Millisecond)
+	metrics.NewRegisteredResettingTimer("chain/idle", r) // Empty, must be skipped
+
+	tags := map[string]string{"host": "node 1", "chain": "1", "empty": ""}
+	if err := InfluxDBV2WithTagsOnce(r, srv.URL+"/", "secret", "geth", "ethereum", "geth.", tags); err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+	req := <-reqs
+	if req.path != "/api/v2/write" {
+		t.Errorf("path mismatch: have %s, want %s", req.path, "/api/v2/write")
+	}
+	if want := "bucket=geth&org=ethereum&precision=ns"; req.query != want {
+		t.Errorf("query mismatch: have %s, want %s", req.query, want)
+	}
+	if auth := req.header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("authorization mismatch: have %q, want %q", auth, "Token secret")
+	}
+	// Index the lines by measurement, stripping the timestamps
+	lines := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(req.body, "\n"), "\n") {
+		cut := strings.LastIndexByte(line, ' ')
+		if cut < 0 {
+			t.Fatalf("malformed line: %q", line)
+		}
+		if _, err := strconv.ParseInt(line[cut+1:], 10, 64); err != nil {
+			t.Errorf("invalid timestamp in line %q: %v", line, err)
+		}
+		measurement := line[:strings.IndexByte(line, ',')]
+		lines[measurement] = line[:cut]
+	}
+	const tagset = ",chain=1,host=node\\ 1 "
+	tests := map[string]string{
+		"geth.chain/inserts.count":            "value=3i",
+		"geth.txpool/pending.gauge":           "value=7i",
+		"geth.system/load.gauge":              "value=0.25",
+		"geth.p2p/ingress.meter":              "count=10i,",
+		"geth.trie/depth.histogram":           "count=1i,max=4i,mean=4,min=4i,p50=4,",
+		"geth.chain/validation.timer":         "count=1i,m1=",
+		`geth.chain/account\ reads.span`:      "count=1i,max=2000000i,mean=2000000,min=2000000i,p50=2000000i,",
+		"geth.metrics/influxdb/dropped.count": "value=0i",
+	}
+
+	for measurement, fields := range tests {
+		line, ok := lines[measurement]
+		if !ok {
+			t.Errorf("measurement %s missing", measurement)
+			continue
+		}
+		if want := measurement + tagset + fields; !strings.HasPrefix(line, want) {
+			t.Errorf("line mismatch:\nhave %s\nwant %s...", line, want)
+		}
+	}
+	if len(lines) != len(tests) {
+		t.Errorf("line count mismatch: have %d, want %d", len(lines), len(tests))
+	}
+}
+
+// Tests that counters are reported as the change since the previous report.
+func TestInfluxDBV2CounterDelta(t *testing.T) {
+	srv, reqs := newTestServer(t, http.StatusNoContent)
+	defer srv.Close()
+
+	r := metrics.NewRegistry()
+	counter := metrics.NewRegisteredCounter("counter", r)
+
+	rep, err := newV2Reporter(r, time.Second, srv.URL, "secret", "geth", "ethereum", "", nil)
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	for i, want := range []string{"value=5i", "value=2i"} {
+		counter.Inc(int64(5 - 3*i))
+		if err := rep.send(); err != nil {
+			t.Fatalf("report %d: failed to send: %v", i, err)
+		}
+		// The dropped counter of the reporter is reported too, in random order
+		var (
+			body  = (<-reqs).body
+			found bool
+		)
+		for _, line := range strings.Split(body, "\n") {
+			if strings.HasPrefix(line, "counter.count ") {
+				found = strings.HasPrefix(line, "counter.count "+want+" ")
+				break
+			}
+		}
+		if !found {
+			t.Errorf("report %d: counter mismatch: have %q, want %q", i, body, want)
+		}
+	}
+}
+
+// Tests that failed writes drop the points, counting them, and back off the
+// reporting until a write succeeds again.
+func TestInfluxDBV2Failure(t *testing.T) {
+	srv, reqs := newTestServer(t, http.StatusUnauthorized)
+	defer srv.Close()
+
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredGauge("gauge", r).Update(1)
+
+	rep, err := newV2Reporter(r, time.Minute, srv.URL, "bad", "geth", "ethereum", "", nil)
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+	for i, delay := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if err := rep.send(); err == nil {
+			t.Fatalf("report %d: rejected write succeeded", i)
+		}
+		<-reqs
+		if have := rep.delay(); have != delay {
+			t.Errorf("report %d: delay mismatch: have %v, want %v", i, have, delay)
+		}
+	}
+	// The gauge and the dropped counter itself are reported in each attempt
+	if dropped := rep.dropped.Count(); dropped != 8 {
+		t.Errorf("dropped points mismatch: have %d, want %d", dropped, 8)
+	}
+	// Recovering should reset the backoff
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if err := rep.send(); err != nil {
+		t.Fatalf("failed to send after recovery: %v", err)
+	}
+	if have := rep.delay(); have != time.Minute {
+		t.Errorf("delay mismatch after recovery: have %v, want %v", have, time.Minute)
+	}
+}
+
+// Tests that values not representable in line protocol are skipped.
+func TestEncodePoint(t *testing.T) {
+	var buf bytes.Buffer
+	if encodePoint(&buf, "m", "", map[string]interface{}{"nan": math.NaN(), "inf": math.Inf(1)}, time.Unix(0, 1)) {
+		t.Errorf("point without representable fields encoded: %q", buf.String())
+	}
+	fields := map[string]interface{}{"a b": 1, "c,d": 1.5, "nan": math.NaN()}
+	if !encodePoint(&buf, "m,x y", encodeTags(map[string]string{"k=1": "v,2"}), fields, time.Unix(0, 42)) {
+		t.Fatalf("point not encoded")
+	}
+	if want := "m\\,x\\ y,k\\=1=v\\,2 a\\ b=1i,c\\,d=1.5 42\n"; buf.String() != want {
+		t.Errorf("encoding mismatch:\nhave %q\nwant %q", buf.String(), want)
+	}
+}
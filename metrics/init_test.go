@@ -1,5 +1,5 @@
 package metrics
 
 func init() {
-	Enabled = true
+	Enable()
 }
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// lazy is a placeholder for a metric created while the metrics system was disabled.
+// It behaves like the no-op metric until the system is enabled, and instantiates
+// the real metric on first use afterwards.
+type lazy[T any] struct {
+	nop  T
+	ctor func() T
+
+	once sync.Once
+	real T
+}
+
+// get returns the real metric if the metrics system was enabled, or the no-op
+// metric otherwise.
+func (l *lazy[T]) get() T {
+	if !Enabled() {
+		return l.nop
+	}
+	l.once.Do(func() { l.real = l.ctor() })
+	return l.real
+}
+
+// lazyCounter is a placeholder for a registered Counter.
+type lazyCounter struct{ lazy[Counter] }
+
+func newLazyCounter(ctor func() Counter) Counter {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyCounter{lazy[Counter]{nop: NilCounter{}, ctor: ctor}}
+}
+
+func (c *lazyCounter) Clear()            { c.get().Clear() }
+func (c *lazyCounter) Count() int64      { return c.get().Count() }
+func (c *lazyCounter) Dec(i int64)       { c.get().Dec(i) }
+func (c *lazyCounter) Inc(i int64)       { c.get().Inc(i) }
+func (c *lazyCounter) Snapshot() Counter { return c.get().Snapshot() }
+
+// lazyGauge is a placeholder for a registered Gauge.
+type lazyGauge struct{ lazy[Gauge] }
+
+func newLazyGauge(ctor func() Gauge) Gauge {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyGauge{lazy[Gauge]{nop: NilGauge{}, ctor: ctor}}
+}
+
+func (g *lazyGauge) Snapshot() Gauge { return g.get().Snapshot() }
+func (g *lazyGauge) Update(v int64)  { g.get().Update(v) }
+func (g *lazyGauge) Dec(i int64)     { g.get().Dec(i) }
+func (g *lazyGauge) Inc(i int64)     { g.get().Inc(i) }
+func (g *lazyGauge) Value() int64    { return g.get().Value() }
+
+// lazyGaugeFloat64 is a placeholder for a registered GaugeFloat64.
+type lazyGaugeFloat64 struct{ lazy[GaugeFloat64] }
+
+func newLazyGaugeFloat64(ctor func() GaugeFloat64) GaugeFloat64 {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyGaugeFloat64{lazy[GaugeFloat64]{nop: NilGaugeFloat64{}, ctor: ctor}}
+}
+
+func (g *lazyGaugeFloat64) Snapshot() GaugeFloat64 { return g.get().Snapshot() }
+func (g *lazyGaugeFloat64) Update(v float64)       { g.get().Update(v) }
+func (g *lazyGaugeFloat64) Value() float64         { return g.get().Value() }
+
+// lazyMeter is a placeholder for a registered Meter.
+type lazyMeter struct{ lazy[Meter] }
+
+func newLazyMeter(ctor func() Meter) Meter {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyMeter{lazy[Meter]{nop: NilMeter{}, ctor: ctor}}
+}
+
+func (m *lazyMeter) Count() int64      { return m.get().Count() }
+func (m *lazyMeter) Mark(n int64)      { m.get().Mark(n) }
+func (m *lazyMeter) Rate1() float64    { return m.get().Rate1() }
+func (m *lazyMeter) Rate5() float64    { return m.get().Rate5() }
+func (m *lazyMeter) Rate15() float64   { return m.get().Rate15() }
+func (m *lazyMeter) RateMean() float64 { return m.get().RateMean() }
+func (m *lazyMeter) Snapshot() Meter   { return m.get().Snapshot() }
+func (m *lazyMeter) Stop()             { m.get().Stop() }
+
+// lazyHistogram is a placeholder for a registered Histogram.
+type lazyHistogram struct{ lazy[Histogram] }
+
+func newLazyHistogram(ctor func() Histogram) Histogram {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyHistogram{lazy[Histogram]{nop: NilHistogram{}, ctor: ctor}}
+}
+
+func (h *lazyHistogram) Clear()                             { h.get().Clear() }
+func (h *lazyHistogram) Count() int64                       { return h.get().Count() }
+func (h *lazyHistogram) Max() int64                         { return h.get().Max() }
+func (h *lazyHistogram) Mean() float64                      { return h.get().Mean() }
+func (h *lazyHistogram) Min() int64                         { return h.get().Min() }
+func (h *lazyHistogram) Percentile(p float64) float64       { return h.get().Percentile(p) }
+func (h *lazyHistogram) Percentiles(ps []float64) []float64 { return h.get().Percentiles(ps) }
+func (h *lazyHistogram) Sample() Sample                     { return h.get().Sample() }
+func (h *lazyHistogram) Snapshot() Histogram                { return h.get().Snapshot() }
+func (h *lazyHistogram) StdDev() float64                    { return h.get().StdDev() }
+func (h *lazyHistogram) Sum() int64                         { return h.get().Sum() }
+func (h *lazyHistogram) Update(v int64)                     { h.get().Update(v) }
+func (h *lazyHistogram) Variance() float64                  { return h.get().Variance() }
+
+// lazyTimer is a placeholder for a registered Timer.
+type lazyTimer struct{ lazy[Timer] }
+
+func newLazyTimer(ctor func() Timer) Timer {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyTimer{lazy[Timer]{nop: NilTimer{}, ctor: ctor}}
+}
+
+func (t *lazyTimer) Count() int64                       { return t.get().Count() }
+func (t *lazyTimer) Max() int64                         { return t.get().Max() }
+func (t *lazyTimer) Mean() float64                      { return t.get().Mean() }
+func (t *lazyTimer) Min() int64                         { return t.get().Min() }
+func (t *lazyTimer) Percentile(p float64) float64       { return t.get().Percentile(p) }
+func (t *lazyTimer) Percentiles(ps []float64) []float64 { return t.get().Percentiles(ps) }
+func (t *lazyTimer) Rate1() float64                     { return t.get().Rate1() }
+func (t *lazyTimer) Rate5() float64                     { return t.get().Rate5() }
+func (t *lazyTimer) Rate15() float64                    { return t.get().Rate15() }
+func (t *lazyTimer) RateMean() float64                  { return t.get().RateMean() }
+func (t *lazyTimer) Snapshot() Timer                    { return t.get().Snapshot() }
+func (t *lazyTimer) StdDev() float64                    { return t.get().StdDev() }
+func (t *lazyTimer) Stop()                              { t.get().Stop() }
+func (t *lazyTimer) Sum() int64                         { return t.get().Sum() }
+func (t *lazyTimer) Time(f func())                      { t.get().Time(f) }
+func (t *lazyTimer) Update(d time.Duration)             { t.get().Update(d) }
+func (t *lazyTimer) UpdateSince(ts time.Time)           { t.get().UpdateSince(ts) }
+func (t *lazyTimer) Variance() float64                  { return t.get().Variance() }
+
+// lazyResettingTimer is a placeholder for a registered ResettingTimer.
+type lazyResettingTimer struct{ lazy[ResettingTimer] }
+
+func newLazyResettingTimer(ctor func() ResettingTimer) ResettingTimer {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazyResettingTimer{lazy[ResettingTimer]{nop: NilResettingTimer{}, ctor: ctor}}
+}
+
+func (t *lazyResettingTimer) Values() []int64                  { return t.get().Values() }
+func (t *lazyResettingTimer) Snapshot() ResettingTimer         { return t.get().Snapshot() }
+func (t *lazyResettingTimer) Percentiles(ps []float64) []int64 { return t.get().Percentiles(ps) }
+func (t *lazyResettingTimer) Mean() float64                    { return t.get().Mean() }
+func (t *lazyResettingTimer) Time(f func())                    { t.get().Time(f) }
+func (t *lazyResettingTimer) Update(d time.Duration)           { t.get().Update(d) }
+func (t *lazyResettingTimer) UpdateSince(ts time.Time)         { t.get().UpdateSince(ts) }
+
+// lazySample is a placeholder for a Sample created while the metrics system was
+// disabled, allowing histograms and timers to be instantiated from it later.
+type lazySample struct{ lazy[Sample] }
+
+func newLazySample(ctor func() Sample) Sample {
+	if Enabled() {
+		return ctor()
+	}
+	return &lazySample{lazy[Sample]{nop: NilSample{}, ctor: ctor}}
+}
+
+func (s *lazySample) Clear()                             { s.get().Clear() }
+func (s *lazySample) Count() int64                       { return s.get().Count() }
+func (s *lazySample) Max() int64                         { return s.get().Max() }
+func (s *lazySample) Mean() float64                      { return s.get().Mean() }
+func (s *lazySample) Min() int64                         { return s.get().Min() }
+func (s *lazySample) Percentile(p float64) float64       { return s.get().Percentile(p) }
+func (s *lazySample) Percentiles(ps []float64) []float64 { return s.get().Percentiles(ps) }
+func (s *lazySample) Size() int                          { return s.get().Size() }
+func (s *lazySample) Snapshot() Sample                   { return s.get().Snapshot() }
+func (s *lazySample) StdDev() float64                    { return s.get().StdDev() }
+func (s *lazySample) Sum() int64                         { return s.get().Sum() }
+func (s *lazySample) Update(v int64)                     { s.get().Update(v) }
+func (s *lazySample) Values() []int64                    { return s.get().Values() }
+func (s *lazySample) Variance() float64                  { return s.get().Variance() }
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// Tests that registered metrics created while the metrics system is disabled are
+// no-ops, and that they start collecting data once the system is enabled.
+func TestEnable(t *testing.T) {
+	// Temporarily disable the metrics system enabled for the tests
+	enableLock.Lock()
+	atomic.StoreInt32(&enabled, 0)
+	enableLock.Unlock()
+	defer Enable()
+
+	r := NewRegistry()
+	var (
+		counter   = NewRegisteredCounter("counter", r)
+		gauge     = NewRegisteredGauge("gauge", r)
+		gaugeF    = NewRegisteredGaugeFloat64("gaugefloat64", r)
+		meter     = NewRegisteredMeter("meter", r)
+		histogram = NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+		timer     = NewRegisteredTimer("timer", r)
+		resetting = NewRegisteredResettingTimer("resetting", r)
+	)
+	defer meter.Stop()
+	defer timer.Stop()
+
+	// Unregistered metrics should be stubs, registered ones should act like them
+	if _, ok := NewMeter().(NilMeter); !ok {
+		t.Fatalf("unregistered meter is not a stub while disabled")
+	}
+	update := func() {
+		counter.Inc(1)
+		gauge.Update(2)
+		gaugeF.Update(3)
+		meter.Mark(4)
+		histogram.Update(5)
+		timer.Update(6)
+		resetting.Update(7)
+	}
+	update()
+	if n := counter.Count(); n != 0 {
+		t.Errorf("counter collected while disabled: %d", n)
+	}
+	if n := meter.Count(); n != 0 {
+		t.Errorf("meter collected while disabled: %d", n)
+	}
+	if n := timer.Count(); n != 0 {
+		t.Errorf("timer collected while disabled: %d", n)
+	}
+	// Enable the metrics system and ensure the earlier metrics start collecting
+	Enable()
+	if !Enabled() {
+		t.Fatalf("metrics system not enabled")
+	}
+	update()
+
+	if n := counter.Count(); n != 1 {
+		t.Errorf("counter mismatch: have %d, want %d", n, 1)
+	}
+	if n := gauge.Value(); n != 2 {
+		t.Errorf("gauge mismatch: have %d, want %d", n, 2)
+	}
+	if n := gaugeF.Value(); n != 3 {
+		t.Errorf("float gauge mismatch: have %v, want %v", n, 3.0)
+	}
+	if n := meter.Count(); n != 4 {
+		t.Errorf("meter mismatch: have %d, want %d", n, 4)
+	}
+	if n := histogram.Sum(); n != 5 {
+		t.Errorf("histogram mismatch: have %d, want %d", n, 5)
+	}
+	if n := timer.Sum(); n != 6 {
+		t.Errorf("timer mismatch: have %d, want %d", n, 6)
+	}
+	if n := resetting.Snapshot().Values(); len(n) != 1 || n[0] != 7 {
+		t.Errorf("resetting timer mismatch: have %v, want %v", n, []int64{7})
+	}
+	// The registry should expose the live metrics too
+	if n := r.Get("meter").(Meter).Count(); n != 4 {
+		t.Errorf("registered meter mismatch: have %d, want %d", n, 4)
+	}
+	// Metrics created after enabling should be real right away
+	if _, ok := NewRegisteredCounter("late", r).(*StandardCounter); !ok {
+		t.Errorf("counter created after enabling is not a standard one")
+	}
+}
@@ -43,7 +43,7 @@ func GetOrRegisterMeterForced(name string, r Registry) Meter {
 // NewMeter constructs a new StandardMeter and launches a goroutine.
 // Be sure to call Stop() once the meter is of no use to allow for garbage collection.
 func NewMeter() Meter {
-	if !Enabled {
+	if !Enabled() {
 		return NilMeter{}
 	}
 	m := newStandardMeter()
@@ -77,7 +77,7 @@ func NewMeterForced() Meter {
 // Be sure to unregister the meter from the registry once it is of no use to
 // allow for garbage collection.
 func NewRegisteredMeter(name string, r Registry) Meter {
-	c := NewMeter()
+	c := newLazyMeter(NewMeter)
 	if nil == r {
 		r = DefaultRegistry
 	}
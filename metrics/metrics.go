@@ -6,51 +6,60 @@
 package metrics
 
 import (
-	"os"
 	"runtime"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// enabled is set atomically when the metrics system is turned on by Enable.
+var enabled int32
+
 // Enabled is checked by the constructor functions for all of the
 // standard metrics. If it is true, the metric returned is a stub.
 //
 // This global kill-switch helps quantify the observer effect and makes
 // for less cluttered pprof profiles.
-var Enabled = false
+//
+// Use Enable to turn the metrics system on.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
 
 // EnabledExpensive is a soft-flag meant for external packages to check if costly
 // metrics gathering is allowed or not. The goal is to separate standard metrics
 // for health monitoring and debug metrics that might impact runtime performance.
 var EnabledExpensive = false
 
-// enablerFlags is the CLI flag names to use to enable metrics collections.
-var enablerFlags = []string{"metrics"}
+// enableLock serializes the enabling of the metrics system.
+var enableLock sync.Mutex
 
-// expensiveEnablerFlags is the CLI flag names to use to enable metrics collections.
-var expensiveEnablerFlags = []string{"metrics.expensive"}
-
-// Init enables or disables the metrics system. Since we need this to run before
-// any other code gets to create meters and timers, we'll actually do an ugly hack
-// and peek into the command line args for the metrics flag.
-func init() {
-	for _, arg := range os.Args {
-		flag := strings.TrimLeft(arg, "-")
+// Enable turns on the metrics system. The registered metrics created while the
+// system was disabled (e.g. package level meters created during init) start to
+// collect data too, the unregistered ones remain no-ops.
+//
+// Enable should be called before starting the node, as data collection only
+// starts from the moment it is called. The metrics system cannot be turned off
+// again once enabled.
+func Enable() {
+	enableLock.Lock()
+	defer enableLock.Unlock()
+
+	if Enabled() {
+		return
+	}
+	log.Info("Enabling metrics collection")
+	atomic.StoreInt32(&enabled, 1)
+}
 
-		for _, enabler := range enablerFlags {
-			if !Enabled && flag == enabler {
-				log.Info("Enabling metrics collection")
-				Enabled = true
-			}
-		}
-		for _, enabler := range expensiveEnablerFlags {
-			if !EnabledExpensive && flag == enabler {
-				log.Info("Enabling expensive metrics collection")
-				EnabledExpensive = true
-			}
-		}
+// EnableExpensive allows the collection of the expensive metrics. It doesn't turn
+// on the metrics system itself.
+func EnableExpensive() {
+	if !EnabledExpensive {
+		log.Info("Enabling expensive metrics collection")
+		EnabledExpensive = true
 	}
 }
 
@@ -58,7 +67,7 @@ func init() {
 // process.
 func CollectProcessMetrics(refresh time.Duration) {
 	// Short circuit if the metrics system is disabled
-	if !Enabled {
+	if !Enabled() {
 		return
 	}
 	refreshFreq := int64(refresh / time.Second)
@@ -18,98 +18,214 @@ package prometheus
 
 import (
 	"bytes"
-	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
-var (
-	typeGaugeTpl           = "# TYPE %s gauge\n"
-	typeCounterTpl         = "# TYPE %s counter\n"
-	typeSummaryTpl         = "# TYPE %s summary\n"
-	keyValueTpl            = "%s %v\n\n"
-	keyQuantileTagValueTpl = "%s {quantile=\"%s\"} %v\n\n"
-)
-
-// collector is a collection of byte buffers that aggregate Prometheus reports
-// for different metric types.
+// collector is a byte buffer that aggregates the Prometheus text exposition of
+// metrics of different types.
 type collector struct {
-	buff *bytes.Buffer
+	buff      *bytes.Buffer
+	prefix    string
+	quantiles []float64
+	labels    []string // Rendered quantile labels, one for each of the quantiles
+
+	seen    map[string]bool // Metric family names already written
+	scratch []byte          // Reusable buffer for formatting numbers
 }
 
-// newCollector createa a new Prometheus metric aggregator.
-func newCollector() *collector {
+// newCollector creates a new Prometheus metric aggregator, prefixing all metric
+// names with the given prefix and reporting the given quantiles of summaries.
+func newCollector(prefix string, quantiles []float64) *collector {
+	labels := make([]string, len(quantiles))
+	for i, q := range quantiles {
+		labels[i] = `{quantile="` + strconv.FormatFloat(q, 'g', -1, 64) + `"}`
+	}
 	return &collector{
-		buff: &bytes.Buffer{},
+		buff:      new(bytes.Buffer),
+		prefix:    prefix,
+		quantiles: quantiles,
+		labels:    labels,
+		seen:      make(map[string]bool),
 	}
 }
 
-func (c *collector) addCounter(name string, m metrics.Counter) {
-	c.writeGaugeCounter(name, m.Count())
+// reserve checks whether none of the metric families with the given suffixes of
+// the given name were written already, marking them as written if so. Distinct
+// metrics may map to the same name after sanitization, but Prometheus requires
+// every family to be unique.
+func (c *collector) reserve(name string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if c.seen[name+suffix] {
+			return false
+		}
+	}
+	for _, suffix := range suffixes {
+		c.seen[name+suffix] = true
+	}
+	return true
 }
 
-func (c *collector) addGauge(name string, m metrics.Gauge) {
-	c.writeGaugeCounter(name, m.Value())
+func (c *collector) addCounter(name string, m metrics.Counter) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "") {
+		return false
+	}
+	c.writeType(name, "counter")
+	c.writeInt(name, "", m.Count())
+	return true
 }
 
-func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
-	c.writeGaugeCounter(name, m.Value())
+func (c *collector) addGauge(name string, m metrics.Gauge) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "") {
+		return false
+	}
+	c.writeType(name, "gauge")
+	c.writeInt(name, "", m.Value())
+	return true
 }
 
-func (c *collector) addHistogram(name string, m metrics.Histogram) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
+func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "") {
+		return false
 	}
+	c.writeType(name, "gauge")
+	c.writeFloat(name, "", m.Value())
+	return true
 }
 
-func (c *collector) addMeter(name string, m metrics.Meter) {
-	c.writeGaugeCounter(name, m.Count())
+// addMeter writes the number of events of a meter as a counter, and its moving
+// average rates per second as gauges derived from it.
+func (c *collector) addMeter(name string, m metrics.Meter) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "", "_rate1", "_rate5", "_rate15", "_rate_mean") {
+		return false
+	}
+	c.writeType(name, "counter")
+	c.writeInt(name, "", m.Count())
+	c.writeGaugeFloat(name+"_rate1", m.Rate1())
+	c.writeGaugeFloat(name+"_rate5", m.Rate5())
+	c.writeGaugeFloat(name+"_rate15", m.Rate15())
+	c.writeGaugeFloat(name+"_rate_mean", m.RateMean())
+	return true
 }
 
-func (c *collector) addTimer(name string, m metrics.Timer) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
+func (c *collector) addHistogram(name string, m metrics.Histogram) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "", "_sum", "_count") {
+		return false
 	}
+	c.writeSummary(name, m.Percentiles(c.quantiles), float64(m.Sum()), m.Count())
+	return true
 }
 
-func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
-	if len(m.Values()) <= 0 {
-		return
+// addTimer writes the durations measured by a timer, in nanoseconds, as a summary.
+func (c *collector) addTimer(name string, m metrics.Timer) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "", "_sum", "_count") {
+		return false
 	}
-	ps := m.Percentiles([]float64{50, 95, 99})
-	val := m.Values()
-	c.writeSummaryCounter(name, len(val))
-	c.writeSummaryPercentile(name, "0.50", ps[0])
-	c.writeSummaryPercentile(name, "0.95", ps[1])
-	c.writeSummaryPercentile(name, "0.99", ps[2])
+	c.writeSummary(name, m.Percentiles(c.quantiles), float64(m.Sum()), m.Count())
+	return true
 }
 
-func (c *collector) writeGaugeCounter(name string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+// addResettingTimer writes the durations measured by a resetting timer since the
+// previous snapshot, in nanoseconds, as a summary.
+func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) bool {
+	name = c.sanitize(name)
+	if !c.reserve(name, "", "_sum", "_count") {
+		return false
+	}
+	var (
+		values = m.Values()
+		ps     = make([]float64, len(c.quantiles))
+		sum    float64
+	)
+	if len(values) > 0 {
+		percents := make([]float64, len(c.quantiles))
+		for i, q := range c.quantiles {
+			percents[i] = q * 100
+		}
+		for i, p := range m.Percentiles(percents) {
+			ps[i] = float64(p)
+		}
+	}
+	for _, v := range values {
+		sum += float64(v)
+	}
+	c.writeSummary(name, ps, sum, int64(len(values)))
+	return true
+}
+
+// writeSummary writes a summary with the given quantile values, sum and count.
+func (c *collector) writeSummary(name string, ps []float64, sum float64, count int64) {
+	c.writeType(name, "summary")
+	for i := range ps {
+		c.writeFloat(name, c.labels[i], ps[i])
+	}
+	c.writeFloat(name+"_sum", "", sum)
+	c.writeInt(name+"_count", "", count)
+}
+
+func (c *collector) writeGaugeFloat(name string, value float64) {
+	c.writeType(name, "gauge")
+	c.writeFloat(name, "", value)
+}
+
+func (c *collector) writeType(name, kind string) {
+	c.buff.WriteString("# TYPE ")
+	c.buff.WriteString(name)
+	c.buff.WriteByte(' ')
+	c.buff.WriteString(kind)
+	c.buff.WriteByte('\n')
 }
 
-func (c *collector) writeSummaryCounter(name string, value interface{}) {
-	name = mutateKey(name + "_count")
-	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+func (c *collector) writeInt(name, labels string, value int64) {
+	c.buff.WriteString(name)
+	c.buff.WriteString(labels)
+	c.buff.WriteByte(' ')
+	c.scratch = strconv.AppendInt(c.scratch[:0], value, 10)
+	c.buff.Write(c.scratch)
+	c.buff.WriteByte('\n')
 }
 
-func (c *collector) writeSummaryPercentile(name, p string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, p, value))
+func (c *collector) writeFloat(name, labels string, value float64) {
+	c.buff.WriteString(name)
+	c.buff.WriteString(labels)
+	c.buff.WriteByte(' ')
+	c.scratch = strconv.AppendFloat(c.scratch[:0], value, 'g', -1, 64)
+	c.buff.Write(c.scratch)
+	c.buff.WriteByte('\n')
 }
 
-func mutateKey(key string) string {
-	return strings.Replace(key, "/", "_", -1)
+// sanitize converts a metric name of the registry into a valid Prometheus metric
+// name with the configured prefix. Characters not allowed by Prometheus, like the
+// slashes and dots separating the name components, are replaced by underscores.
+func (c *collector) sanitize(name string) string {
+	buf := make([]byte, 0, len(c.prefix)+len(name)+1)
+	for i := 0; i < len(c.prefix)+len(name); i++ {
+		var ch byte
+		if i < len(c.prefix) {
+			ch = c.prefix[i]
+		} else {
+			ch = name[i-len(c.prefix)]
+		}
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '_', ch == ':':
+		case ch >= '0' && ch <= '9':
+			if i == 0 {
+				buf = append(buf, '_')
+			}
+		default:
+			ch = '_'
+		}
+		buf = append(buf, ch)
+	}
+	if len(buf) == 0 {
+		return "_"
+	}
+	return string(buf)
 }
@@ -18,51 +18,109 @@
 package prometheus
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
-// Handler returns an HTTP handler which dump metrics in Prometheus format.
+// DefaultQuantiles are the quantiles reported for histograms and timers unless
+// configured otherwise.
+var DefaultQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+// Handler returns an HTTP handler which dump metrics in Prometheus format, using
+// the registry names without a prefix and the default quantiles.
 func Handler(reg metrics.Registry) http.Handler {
+	return NewHandler(reg, "", DefaultQuantiles)
+}
+
+// NewHandler returns an HTTP handler which dumps all the metrics of the registry
+// in the Prometheus text exposition format. Metric names are sanitized to the
+// Prometheus rules and prefixed with the given prefix. Histograms and timers are
+// reported as summaries with the given quantiles.
+func NewHandler(reg metrics.Registry, prefix string, quantiles []float64) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Gather and pre-sort the metrics to avoid random listings
-		var names []string
-		reg.Each(func(name string, i interface{}) {
-			names = append(names, name)
-		})
-		sort.Strings(names)
+		c := collect(reg, prefix, quantiles)
 
-		// Aggregate all the metris into a Prometheus collector
-		c := newCollector()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Content-Length", fmt.Sprint(c.buff.Len()))
+		w.Write(c.buff.Bytes())
+	})
+}
+
+// collect renders all the metrics of the registry into a collector.
+func collect(reg metrics.Registry, prefix string, quantiles []float64) *collector {
+	// Gather and pre-sort the metrics to avoid random listings
+	var (
+		names []string
+		all   = make(map[string]interface{})
+	)
+	reg.Each(func(name string, i interface{}) {
+		names = append(names, name)
+		all[name] = i
+	})
+	sort.Strings(names)
 
-		for _, name := range names {
-			i := reg.Get(name)
+	// Aggregate all the metrics into a Prometheus collector
+	c := newCollector(prefix, quantiles)
 
-			switch m := i.(type) {
-			case metrics.Counter:
-				c.addCounter(name, m.Snapshot())
-			case metrics.Gauge:
-				c.addGauge(name, m.Snapshot())
-			case metrics.GaugeFloat64:
-				c.addGaugeFloat64(name, m.Snapshot())
-			case metrics.Histogram:
-				c.addHistogram(name, m.Snapshot())
-			case metrics.Meter:
-				c.addMeter(name, m.Snapshot())
-			case metrics.Timer:
-				c.addTimer(name, m.Snapshot())
-			case metrics.ResettingTimer:
-				c.addResettingTimer(name, m.Snapshot())
-			default:
-				log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", i))
+	for _, name := range names {
+		var added bool
+		switch m := all[name].(type) {
+		case metrics.Counter:
+			added = c.addCounter(name, m.Snapshot())
+		case metrics.Gauge:
+			added = c.addGauge(name, m.Snapshot())
+		case metrics.GaugeFloat64:
+			added = c.addGaugeFloat64(name, m.Snapshot())
+		case metrics.Histogram:
+			added = c.addHistogram(name, m.Snapshot())
+		case metrics.Meter:
+			added = c.addMeter(name, m.Snapshot())
+		case metrics.Timer:
+			added = c.addTimer(name, m.Snapshot())
+		case metrics.ResettingTimer:
+			added = c.addResettingTimer(name, m.Snapshot())
+		default:
+			log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", m))
+			continue
+		}
+		if !added {
+			log.Debug("Skipping Prometheus metric with conflicting name", "name", name)
+		}
+	}
+	return c
+}
+
+// ParseQuantiles parses a comma separated list of quantiles, each of which must
+// be between 0 and 1.
+func ParseQuantiles(list string) ([]float64, error) {
+	var quantiles []float64
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		q, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile %q: %v", field, err)
+		}
+		if q < 0 || q > 1 {
+			return nil, fmt.Errorf("quantile %v out of range [0, 1]", q)
+		}
+		for _, have := range quantiles {
+			if have == q {
+				return nil, fmt.Errorf("duplicate quantile %v", q)
 			}
 		}
-		w.Header().Add("Content-Type", "text/plain")
-		w.Header().Add("Content-Length", fmt.Sprint(c.buff.Len()))
-		w.Write(c.buff.Bytes())
-	})
+		quantiles = append(quantiles, q)
+	}
+	if len(quantiles) == 0 {
+		return nil, errors.New("no quantiles")
+	}
+	return quantiles, nil
 }
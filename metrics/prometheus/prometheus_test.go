@@ -0,0 +1,385 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	sampleRe     = regexp.MustCompile(`^([^{ ]+)(\{[^}]*\})? (\S+)( -?[0-9]+)?$`)
+	labelRe      = regexp.MustCompile(`^([^=]+)="((?:[^"\\]|\\.)*)"$`)
+)
+
+func init() {
+	metrics.Enable()
+}
+
+// sample is a single line of a parsed exposition.
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// exposition is a parsed Prometheus text exposition, mapping the metric family
+// names to their types and the sample names to their samples.
+type exposition struct {
+	types   map[string]string
+	samples map[string][]sample
+}
+
+// parseExposition strictly validates and parses an exposition in the Prometheus
+// text format: every sample must belong to a metric family declared beforehand,
+// families must be contiguous and unique, names and labels must be valid, and
+// the samples of summaries must fit their family.
+func parseExposition(text string) (*exposition, error) {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return nil, fmt.Errorf("missing final line feed")
+	}
+	exp := &exposition{types: make(map[string]string), samples: make(map[string][]sample)}
+	var (
+		family string
+		series = make(map[string]bool)
+	)
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || fields[1] != "TYPE" && fields[1] != "HELP" {
+				return nil, fmt.Errorf("line %d: unexpected comment %q", i, line)
+			}
+			if fields[1] == "HELP" {
+				continue
+			}
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("line %d: malformed type %q", i, line)
+			}
+			name, kind := fields[2], fields[3]
+			if !metricNameRe.MatchString(name) {
+				return nil, fmt.Errorf("line %d: invalid metric name %q", i, name)
+			}
+			switch kind {
+			case "counter", "gauge", "summary", "histogram", "untyped":
+			default:
+				return nil, fmt.Errorf("line %d: invalid metric type %q", i, kind)
+			}
+			if _, ok := exp.types[name]; ok {
+				return nil, fmt.Errorf("line %d: duplicate metric family %q", i, name)
+			}
+			exp.types[name], family = kind, name
+			continue
+		}
+		match := sampleRe.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: malformed sample %q", i, line)
+		}
+		name := match[1]
+		if !metricNameRe.MatchString(name) {
+			return nil, fmt.Errorf("line %d: invalid metric name %q", i, name)
+		}
+		labels := make(map[string]string)
+		if match[2] != "" {
+			for _, pair := range strings.Split(strings.Trim(match[2], "{}"), ",") {
+				lm := labelRe.FindStringSubmatch(pair)
+				if lm == nil || !labelNameRe.MatchString(lm[1]) || strings.HasPrefix(lm[1], "__") {
+					return nil, fmt.Errorf("line %d: invalid label %q", i, pair)
+				}
+				if _, ok := labels[lm[1]]; ok {
+					return nil, fmt.Errorf("line %d: duplicate label %q", i, lm[1])
+				}
+				labels[lm[1]] = lm[2]
+			}
+		}
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value %q", i, match[3])
+		}
+		// The sample must belong to the family declared last
+		if family == "" {
+			return nil, fmt.Errorf("line %d: sample %q without type", i, name)
+		}
+		switch exp.types[family] {
+		case "summary":
+			switch name {
+			case family:
+				q, ok := labels["quantile"]
+				if !ok || len(labels) != 1 {
+					return nil, fmt.Errorf("line %d: summary quantile without quantile label", i)
+				}
+				if qv, err := strconv.ParseFloat(q, 64); err != nil || qv < 0 || qv > 1 {
+					return nil, fmt.Errorf("line %d: invalid quantile %q", i, q)
+				}
+			case family + "_sum", family + "_count":
+				if len(labels) != 0 {
+					return nil, fmt.Errorf("line %d: labeled summary %s", i, name)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: sample %q outside of summary %q", i, name, family)
+			}
+		default:
+			if name != family {
+				return nil, fmt.Errorf("line %d: sample %q outside of family %q", i, name, family)
+			}
+			if _, ok := labels["quantile"]; ok {
+				return nil, fmt.Errorf("line %d: quantile label on %s", i, exp.types[family])
+			}
+		}
+		if exp.types[family] == "counter" && (value < 0 || math.IsNaN(value)) {
+			return nil, fmt.Errorf("line %d: invalid counter value %v", i, value)
+		}
+		id := name + match[2]
+		if series[id] {
+			return nil, fmt.Errorf("line %d: duplicate series %q", i, id)
+		}
+		series[id] = true
+		exp.samples[name] = append(exp.samples[name], sample{labels: labels, value: value})
+	}
+	return exp, nil
+}
+
+// value returns the value of the only sample with the given name.
+func (exp *exposition) value(t *testing.T, name string) float64 {
+	t.Helper()
+	if len(exp.samples[name]) != 1 {
+		t.Fatalf("sample %s count mismatch: have %d, want 1", name, len(exp.samples[name]))
+	}
+	return exp.samples[name][0].value
+}
+
+// scrape renders the registry through the handler and parses the result.
+func scrape(t testing.TB, reg metrics.Registry, prefix string, quantiles []float64) *exposition {
+	rec := httptest.NewRecorder()
+	NewHandler(reg, prefix, quantiles).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type mismatch: have %q", ct)
+	}
+	exp, err := parseExposition(rec.Body.String())
+	if err != nil {
+		t.Fatalf("invalid exposition: %v\n%s", err, rec.Body.String())
+	}
+	return exp
+}
+
+func TestHandler(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	counter := metrics.NewCounter()
+	counter.Inc(12)
+	reg.Register("chain/inserts", counter)
+
+	gauge := metrics.NewGauge()
+	gauge.Update(-5)
+	reg.Register("p2p.peers-count", gauge)
+
+	gaugeFloat := metrics.NewGaugeFloat64()
+	gaugeFloat.Update(0.25)
+	reg.Register("1st/ratio", gaugeFloat)
+
+	meter := metrics.NewMeterForced()
+	meter.Mark(7)
+	reg.Register("eth/downloader/bodies/in", meter)
+
+	histogram := metrics.NewHistogram(metrics.NewUniformSample(100))
+	for i := int64(1); i <= 100; i++ {
+		histogram.Update(i)
+	}
+	reg.Register("txpool/sizes", histogram)
+
+	timer := metrics.NewTimer()
+	timer.Update(time.Second)
+	timer.Update(3 * time.Second)
+	reg.Register("chain/execution", timer)
+
+	resetting := metrics.NewResettingTimer()
+	resetting.Update(10)
+	resetting.Update(30)
+	reg.Register("rpc/duration", resetting)
+
+	// Distinct names mapping to the same Prometheus name must not be duplicated
+	reg.Register("chain_inserts", metrics.NewGauge())
+
+	exp := scrape(t, reg, "geth_", []float64{0.5, 0.99})
+
+	wantTypes := map[string]string{
+		"geth_chain_inserts":                      "counter",
+		"geth_p2p_peers_count":                    "gauge",
+		"geth_1st_ratio":                          "gauge",
+		"geth_eth_downloader_bodies_in":           "counter",
+		"geth_eth_downloader_bodies_in_rate1":     "gauge",
+		"geth_eth_downloader_bodies_in_rate5":     "gauge",
+		"geth_eth_downloader_bodies_in_rate15":    "gauge",
+		"geth_eth_downloader_bodies_in_rate_mean": "gauge",
+		"geth_txpool_sizes":                       "summary",
+		"geth_chain_execution":                    "summary",
+		"geth_rpc_duration":                       "summary",
+	}
+	if len(exp.types) != len(wantTypes) {
+		t.Errorf("metric family count mismatch: have %d, want %d: %v", len(exp.types), len(wantTypes), exp.types)
+	}
+	for name, kind := range wantTypes {
+		if exp.types[name] != kind {
+			t.Errorf("type of %s mismatch: have %q, want %q", name, exp.types[name], kind)
+		}
+	}
+	if v := exp.value(t, "geth_chain_inserts"); v != 12 {
+		t.Errorf("counter mismatch: have %v, want 12", v)
+	}
+	if v := exp.value(t, "geth_p2p_peers_count"); v != -5 {
+		t.Errorf("gauge mismatch: have %v, want -5", v)
+	}
+	if v := exp.value(t, "geth_1st_ratio"); v != 0.25 {
+		t.Errorf("float gauge mismatch: have %v, want 0.25", v)
+	}
+	if v := exp.value(t, "geth_eth_downloader_bodies_in"); v != 7 {
+		t.Errorf("meter count mismatch: have %v, want 7", v)
+	}
+	if v := exp.value(t, "geth_txpool_sizes_count"); v != 100 {
+		t.Errorf("histogram count mismatch: have %v, want 100", v)
+	}
+	if v := exp.value(t, "geth_txpool_sizes_sum"); v != 5050 {
+		t.Errorf("histogram sum mismatch: have %v, want 5050", v)
+	}
+	if v := exp.value(t, "geth_chain_execution_sum"); v != float64(4*time.Second) {
+		t.Errorf("timer sum mismatch: have %v, want %v", v, float64(4*time.Second))
+	}
+	if v := exp.value(t, "geth_rpc_duration_count"); v != 2 {
+		t.Errorf("resetting timer count mismatch: have %v, want 2", v)
+	}
+	if v := exp.value(t, "geth_rpc_duration_sum"); v != 40 {
+		t.Errorf("resetting timer sum mismatch: have %v, want 40", v)
+	}
+	quantiles := make(map[string]float64)
+	for _, s := range exp.samples["geth_txpool_sizes"] {
+		quantiles[s.labels["quantile"]] = s.value
+	}
+	if len(quantiles) != 2 || quantiles["0.5"] != 50.5 || quantiles["0.99"] != 99.99 {
+		t.Errorf("histogram quantiles mismatch: %v", quantiles)
+	}
+}
+
+// Tests that an empty registry and metrics without data produce valid output.
+func TestHandlerEmpty(t *testing.T) {
+	exp := scrape(t, metrics.NewRegistry(), "", DefaultQuantiles)
+	if len(exp.types) != 0 {
+		t.Errorf("metrics reported for empty registry: %v", exp.types)
+	}
+	reg := metrics.NewRegistry()
+	reg.Register("empty/histogram", metrics.NewHistogram(metrics.NewUniformSample(10)))
+	reg.Register("empty/timer", metrics.NewTimer())
+	reg.Register("empty/resetting", metrics.NewResettingTimer())
+
+	exp = scrape(t, reg, "", DefaultQuantiles)
+	for _, name := range []string{"empty_histogram", "empty_timer", "empty_resetting"} {
+		if exp.types[name] != "summary" || len(exp.samples[name]) != len(DefaultQuantiles) {
+			t.Errorf("%s mismatch: type %q, %d quantiles", name, exp.types[name], len(exp.samples[name]))
+		}
+		if v := exp.value(t, name+"_count"); v != 0 {
+			t.Errorf("%s count mismatch: have %v, want 0", name, v)
+		}
+	}
+}
+
+func TestParseQuantiles(t *testing.T) {
+	tests := []struct {
+		list string
+		want []float64
+		fail bool
+	}{
+		{list: "0.5,0.99", want: []float64{0.5, 0.99}},
+		{list: " 0 , 1 ,", want: []float64{0, 1}},
+		{list: "", fail: true},
+		{list: "0.5,1.5", fail: true},
+		{list: "-0.1", fail: true},
+		{list: "half", fail: true},
+		{list: "0.5,0.5", fail: true},
+	}
+	for _, tt := range tests {
+		have, err := ParseQuantiles(tt.list)
+		if (err != nil) != tt.fail {
+			t.Errorf("%q: error mismatch: have %v, want failure %v", tt.list, err, tt.fail)
+			continue
+		}
+		if fmt.Sprint(have) != fmt.Sprint(tt.want) && !tt.fail {
+			t.Errorf("%q: quantiles mismatch: have %v, want %v", tt.list, have, tt.want)
+		}
+	}
+}
+
+// newBenchRegistry creates a registry with the given number of metrics of every
+// type, all with data.
+func newBenchRegistry(n int) metrics.Registry {
+	reg := metrics.NewRegistry()
+	for i := 0; i < n; i++ {
+		counter := metrics.NewCounter()
+		counter.Inc(int64(i))
+		reg.Register(fmt.Sprintf("bench/counter/%d", i), counter)
+
+		gauge := metrics.NewGauge()
+		gauge.Update(int64(i))
+		reg.Register(fmt.Sprintf("bench/gauge/%d", i), gauge)
+
+		meter := metrics.NewMeterForced()
+		meter.Mark(int64(i))
+		reg.Register(fmt.Sprintf("bench/meter/%d", i), meter)
+
+		histogram := metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015))
+		timer := metrics.NewTimer()
+		for j := 0; j < 100; j++ {
+			histogram.Update(int64(j))
+			timer.Update(time.Duration(j))
+		}
+		reg.Register(fmt.Sprintf("bench/histogram/%d", i), histogram)
+		reg.Register(fmt.Sprintf("bench/timer/%d", i), timer)
+	}
+	return reg
+}
+
+// Tests that a large registry renders into a valid exposition.
+func TestHandlerLarge(t *testing.T) {
+	exp := scrape(t, newBenchRegistry(200), "geth_", DefaultQuantiles)
+	if want := 200 * (2 + 5 + 2); len(exp.types) != want {
+		t.Errorf("metric family count mismatch: have %d, want %d", len(exp.types), want)
+	}
+}
+
+// BenchmarkHandler measures a scrape of a registry with 5000 metrics.
+func BenchmarkHandler(b *testing.B) {
+	var (
+		reg     = newBenchRegistry(1000)
+		handler = NewHandler(reg, "geth_", DefaultQuantiles)
+		req     = httptest.NewRequest("GET", "/metrics", nil)
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -32,7 +32,7 @@ func GetOrRegisterResettingTimer(name string, r Registry) ResettingTimer {
 
 // NewRegisteredResettingTimer constructs and registers a new StandardResettingTimer.
 func NewRegisteredResettingTimer(name string, r Registry) ResettingTimer {
-	c := NewResettingTimer()
+	c := newLazyResettingTimer(NewResettingTimer)
 	if nil == r {
 		r = DefaultRegistry
 	}
@@ -42,7 +42,7 @@ func NewRegisteredResettingTimer(name string, r Registry) ResettingTimer {
 
 // NewResettingTimer constructs a new StandardResettingTimer
 func NewResettingTimer() ResettingTimer {
-	if !Enabled {
+	if !Enabled() {
 		return NilResettingTimer{}
 	}
 	return &StandardResettingTimer{
@@ -46,8 +46,8 @@ type ExpDecaySample struct {
 // NewExpDecaySample constructs a new exponentially-decaying sample with the
 // given reservoir size and alpha.
 func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
-	if !Enabled {
-		return NilSample{}
+	if !Enabled() {
+		return newLazySample(func() Sample { return NewExpDecaySample(reservoirSize, alpha) })
 	}
 	s := &ExpDecaySample{
 		alpha:         alpha,
@@ -407,8 +407,8 @@ type UniformSample struct {
 // NewUniformSample constructs a new uniform sample with the given reservoir
 // size.
 func NewUniformSample(reservoirSize int) Sample {
-	if !Enabled {
-		return NilSample{}
+	if !Enabled() {
+		return newLazySample(func() Sample { return NewUniformSample(reservoirSize) })
 	}
 	return &UniformSample{
 		reservoirSize: reservoirSize,
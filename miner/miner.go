@@ -48,9 +48,11 @@ type Config struct {
 	ExtraData hexutil.Bytes  `toml:",omitempty"` // Block extra data set by the miner
 	GasFloor  uint64         // Target gas floor for mined blocks.
 	GasCeil   uint64         // Target gas ceiling for mined blocks.
-	GasPrice  *big.Int       // Minimum gas price for mining a transaction
+	GasPrice  *big.Int       `reload:"true"` // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in ethash).
+
+	MaxTemplateAge time.Duration // Maximum age of the mining work before it's rebuilt with a fresh timestamp (0 = disabled).
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -291,11 +291,25 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		interrupt   *int32
 		minRecommit = recommit // minimal resubmit interval specified by user.
 		timestamp   int64      // timestamp for each round of mining.
+		stamped     time.Time  // local time the current timestamp was taken at.
+		maxAge      = w.config.MaxTemplateAge
 	)
 
 	timer := time.NewTimer(0)
 	<-timer.C // discard the initial tick
 
+	staleTimer := time.NewTimer(0)
+	<-staleTimer.C // discard the initial tick
+
+	// restamp starts a new round of mining with the current time as timestamp.
+	restamp := func() {
+		stamped = time.Now()
+		timestamp = stamped.Unix()
+		if maxAge > 0 {
+			staleTimer.Reset(maxAge)
+		}
+	}
+
 	// commit aborts in-flight transaction execution with given signal and resubmits a new one.
 	commit := func(noempty bool, s int32) {
 		if interrupt != nil {
@@ -342,14 +356,30 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		select {
 		case <-w.startCh:
 			clearPending(w.chain.CurrentBlock().NumberU64())
-			timestamp = time.Now().Unix()
+			restamp()
 			commit(false, commitInterruptNewHead)
 
 		case head := <-w.chainHeadCh:
 			clearPending(head.Block.NumberU64())
-			timestamp = time.Now().Unix()
+			restamp()
 			commit(false, commitInterruptNewHead)
 
+		case <-staleTimer.C:
+			// The timer may have fired before being rearmed by a new round, in
+			// which case the current work isn't stale yet.
+			if age := time.Since(stamped); age < maxAge {
+				staleTimer.Reset(maxAge - age)
+				continue
+			}
+			// If mining is running and no new head arrived for too long, rebuild
+			// the work with a fresh timestamp (and difficulty), so miners aren't
+			// left working on an outdated target during quiet periods.
+			if w.isRunning() && (w.chainConfig.Clique == nil || w.chainConfig.Clique.Period > 0) {
+				log.Debug("Refreshing stale mining work", "age", common.PrettyDuration(time.Since(stamped)))
+				restamp()
+				commit(false, commitInterruptResubmit)
+			}
+
 		case <-timer.C:
 			// If mining is running resubmit a new work cycle periodically to pull in
 			// higher priced transactions. Disable this overhead for pending blocks.
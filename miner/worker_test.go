@@ -417,6 +417,50 @@ func testRegenerateMiningBlock(t *testing.T, chainConfig *params.ChainConfig, en
 	}
 }
 
+func TestRefreshStaleWork(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	config := *testConfig
+	config.MaxTemplateAge = time.Second
+
+	backend := newTestWorkerBackend(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	backend.txPool.AddLocals(pendingTxs)
+	w := newWorker(&config, ethashChainConfig, engine, backend, new(event.TypeMux), nil, false)
+	w.setEtherbase(testBankAddress)
+	defer w.close()
+
+	var taskCh = make(chan uint64, 10)
+	w.newTaskHook = func(task *task) {
+		if task.block.NumberU64() == 1 {
+			taskCh <- task.block.Time()
+		}
+	}
+	w.skipSealHook = func(task *task) bool {
+		return true
+	}
+	w.start()
+
+	// The first work is an empty task and the second one has the pending tx
+	var first uint64
+	for i := 0; i < 2; i++ {
+		select {
+		case first = <-taskCh:
+		case <-time.NewTimer(time.Second).C:
+			t.Fatal("new task timeout")
+		}
+	}
+	// Without any new transaction or head, the work should be refreshed
+	select {
+	case stamp := <-taskCh:
+		if stamp <= first {
+			t.Errorf("refreshed work timestamp mismatch: have %d, want > %d", stamp, first)
+		}
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Error("stale work not refreshed")
+	}
+}
+
 func TestAdjustIntervalEthash(t *testing.T) {
 	testAdjustInterval(t, ethashChainConfig, ethash.NewFaker())
 }
@@ -18,13 +18,19 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -41,8 +47,12 @@ func NewPrivateAdminAPI(node *Node) *PrivateAdminAPI {
 }
 
 // AddPeer requests connecting to a remote node, and also maintaining the new
-// connection at all times, even reconnecting if it is lost.
+// connection at all times, even reconnecting if it is lost. The peer is stored
+// in the node database and reconnected after a restart too.
 func (api *PrivateAdminAPI) AddPeer(url string) (bool, error) {
+	if err := api.node.checkRateLimit("admin_addPeer"); err != nil {
+		return false, err
+	}
 	// Make sure the server is running, fail otherwise
 	server := api.node.Server()
 	if server == nil {
@@ -57,8 +67,12 @@ func (api *PrivateAdminAPI) AddPeer(url string) (bool, error) {
 	return true, nil
 }
 
-// RemovePeer disconnects from a remote node if the connection exists
+// RemovePeer disconnects from a remote node if the connection exists, and
+// removes it from the persisted static peers.
 func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
+	if err := api.node.checkRateLimit("admin_removePeer"); err != nil {
+		return false, err
+	}
 	// Make sure the server is running, fail otherwise
 	server := api.node.Server()
 	if server == nil {
@@ -73,8 +87,12 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
-// AddTrustedPeer allows a remote node to always connect, even if slots are full
+// AddTrustedPeer allows a remote node to always connect, even if slots are full.
+// The peer is stored in the node database and remains trusted after a restart.
 func (api *PrivateAdminAPI) AddTrustedPeer(url string) (bool, error) {
+	if err := api.node.checkRateLimit("admin_addTrustedPeer"); err != nil {
+		return false, err
+	}
 	// Make sure the server is running, fail otherwise
 	server := api.node.Server()
 	if server == nil {
@@ -88,9 +106,12 @@ func (api *PrivateAdminAPI) AddTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
-// RemoveTrustedPeer removes a remote node from the trusted peer set, but it
-// does not disconnect it automatically.
-func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
+// RemoveTrustedPeer removes a remote node from the trusted peer set, including
+// the persisted one. The node is only disconnected if requested explicitly.
+func (api *PrivateAdminAPI) RemoveTrustedPeer(url string, disconnect *bool) (bool, error) {
+	if err := api.node.checkRateLimit("admin_removeTrustedPeer"); err != nil {
+		return false, err
+	}
 	// Make sure the server is running, fail otherwise
 	server := api.node.Server()
 	if server == nil {
@@ -101,9 +122,194 @@ func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 		return false, fmt.Errorf("invalid enode: %v", err)
 	}
 	server.RemoveTrustedPeer(node)
+	if disconnect != nil && *disconnect {
+		for _, peer := range server.Peers() {
+			if peer.ID() == node.ID() {
+				peer.Disconnect(p2p.DiscRequested)
+			}
+		}
+	}
+	return true, nil
+}
+
+// ListStaticPeers returns the nodes the node maintains connections to, along
+// with their connection state.
+func (api *PrivateAdminAPI) ListStaticPeers() ([]*p2p.PeerSetInfo, error) {
+	if err := api.node.checkRateLimit("admin_listStaticPeers"); err != nil {
+		return nil, err
+	}
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.StaticPeers(), nil
+}
+
+// ListTrustedPeers returns the nodes allowed to connect even if slots are full,
+// along with their connection state.
+func (api *PrivateAdminAPI) ListTrustedPeers() ([]*p2p.PeerSetInfo, error) {
+	if err := api.node.checkRateLimit("admin_listTrustedPeers"); err != nil {
+		return nil, err
+	}
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.TrustedPeers(), nil
+}
+
+// SetENRValue sets an arbitrary key/value pair in the local node record. The
+// value is stored as a byte string. Keys describing the identity and endpoints
+// of the node are maintained by the p2p server and cannot be overridden.
+func (api *PrivateAdminAPI) SetENRValue(key string, value hexutil.Bytes) (bool, error) {
+	if err := api.node.checkRateLimit("admin_setENRValue"); err != nil {
+		return false, err
+	}
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if key == "" {
+		return false, errors.New("empty ENR key")
+	}
+	if enr.IsReservedKey(key) {
+		return false, fmt.Errorf("reserved ENR key %q", key)
+	}
+	server.LocalNode().Set(enr.WithEntry(key, []byte(value)))
+	return true, nil
+}
+
+// NetRestrictInfo lists the IP networks connections are restricted to, in CIDR
+// notation. A null list means connections aren't restricted.
+type NetRestrictInfo struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+// GetNetRestrict returns the IP networks connections are restricted to.
+func (api *PrivateAdminAPI) GetNetRestrict() (*NetRestrictInfo, error) {
+	if err := api.node.checkRateLimit("admin_getNetRestrict"); err != nil {
+		return nil, err
+	}
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	restrict := server.NetRestriction()
+	return &NetRestrictInfo{
+		Inbound:  netlistStrings(restrict.Inbound),
+		Outbound: netlistStrings(restrict.Outbound),
+	}, nil
+}
+
+// SetNetRestrict restricts new connections in the given direction ("inbound",
+// "outbound" or "both") to the given IP networks in CIDR notation. An empty list
+// lifts the restriction. Outbound restrictions also apply to the nodes kept by
+// discovery. Connected peers outside of the networks are only disconnected if
+// disconnect is set.
+func (api *PrivateAdminAPI) SetNetRestrict(cidrs []string, direction string, disconnect *bool) (bool, error) {
+	if err := api.node.checkRateLimit("admin_setNetRestrict"); err != nil {
+		return false, err
+	}
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	var list *netutil.Netlist
+	if len(cidrs) > 0 {
+		list = new(netutil.Netlist)
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return false, fmt.Errorf("invalid network %q: %v", cidr, err)
+			}
+			*list = append(*list, *network)
+		}
+	}
+	restrict := server.NetRestriction()
+	switch direction {
+	case "inbound":
+		restrict.Inbound = list
+	case "outbound":
+		restrict.Outbound = list
+	case "both":
+		restrict.Inbound, restrict.Outbound = list, list
+	default:
+		return false, fmt.Errorf("invalid direction %q, want inbound, outbound or both", direction)
+	}
+	server.SetNetRestriction(restrict, disconnect != nil && *disconnect)
+	return true, nil
+}
+
+func netlistStrings(list *netutil.Netlist) []string {
+	if list == nil {
+		return nil
+	}
+	masks := make([]string, 0, len(*list))
+	for _, network := range *list {
+		masks = append(masks, network.String())
+	}
+	return masks
+}
+
+// PeerReputation returns the reputation of a node, derived from the incidents
+// reported for it, e.g. sending invalid blocks.
+func (api *PrivateAdminAPI) PeerReputation(id enode.ID) (*p2p.Reputation, error) {
+	if err := api.node.checkRateLimit("admin_peerReputation"); err != nil {
+		return nil, err
+	}
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	rep := server.PeerReputation(id)
+	return &rep, nil
+}
+
+// ClearReputation forgets all incidents reported for a node, lifting any ban.
+func (api *PrivateAdminAPI) ClearReputation(id enode.ID) (bool, error) {
+	if err := api.node.checkRateLimit("admin_clearReputation"); err != nil {
+		return false, err
+	}
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.ClearReputation(id); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
+// BanPeer disconnects a remote node and refuses connections to it for the given
+// duration, e.g. "30m" or "24h".
+func (api *PrivateAdminAPI) BanPeer(url string, duration string) (*p2p.Reputation, error) {
+	if err := api.node.checkRateLimit("admin_banPeer"); err != nil {
+		return nil, err
+	}
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	node, err := enode.Parse(enode.ValidSchemes, url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enode: %v", err)
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid ban duration: %q", duration)
+	}
+	rep, err := server.BanPeer(node, d)
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -144,6 +350,9 @@ func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription,
 
 // StartRPC starts the HTTP RPC API server.
 func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis *string, vhosts *string) (bool, error) {
+	if err := api.node.checkRateLimit("admin_startRPC"); err != nil {
+		return false, err
+	}
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
@@ -194,6 +403,9 @@ func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis
 
 // StopRPC terminates an already running HTTP RPC API endpoint.
 func (api *PrivateAdminAPI) StopRPC() (bool, error) {
+	if err := api.node.checkRateLimit("admin_stopRPC"); err != nil {
+		return false, err
+	}
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
@@ -206,6 +418,9 @@ func (api *PrivateAdminAPI) StopRPC() (bool, error) {
 
 // StartWS starts the websocket RPC API server.
 func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *string, apis *string) (bool, error) {
+	if err := api.node.checkRateLimit("admin_startWS"); err != nil {
+		return false, err
+	}
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
@@ -246,8 +461,24 @@ func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 	return true, nil
 }
 
+// ReloadConfig reads the given configuration file and applies the settings which
+// can be changed at runtime. It fails without changing anything if the file
+// modifies any other setting.
+func (api *PrivateAdminAPI) ReloadConfig(file string) (bool, error) {
+	if err := api.node.checkRateLimit("admin_reloadConfig"); err != nil {
+		return false, err
+	}
+	if err := api.node.reloadConfigFile(file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // StopWS terminates an already running websocket RPC API endpoint.
 func (api *PrivateAdminAPI) StopWS() (bool, error) {
+	if err := api.node.checkRateLimit("admin_stopWS"); err != nil {
+		return false, err
+	}
 	api.node.lock.Lock()
 	defer api.node.lock.Unlock()
 
@@ -273,6 +504,9 @@ func NewPublicAdminAPI(node *Node) *PublicAdminAPI {
 // Peers retrieves all the information we know about each individual peer at the
 // protocol granularity.
 func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
+	if err := api.node.checkRateLimit("admin_peers"); err != nil {
+		return nil, err
+	}
 	server := api.node.Server()
 	if server == nil {
 		return nil, ErrNodeStopped
@@ -280,9 +514,33 @@ func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerStats retrieves the connection quality statistics of all connected peers.
+func (api *PublicAdminAPI) PeerStats() ([]*p2p.PeerStats, error) {
+	if err := api.node.checkRateLimit("admin_peerStats"); err != nil {
+		return nil, err
+	}
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerStats(), nil
+}
+
+// Health runs the readiness checks of the node, the same ones backing the HTTP
+// readiness probe.
+func (api *PublicAdminAPI) Health(ctx context.Context) (*HealthStatus, error) {
+	if err := api.node.checkRateLimit("admin_health"); err != nil {
+		return nil, err
+	}
+	return api.node.Health(ctx), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
+	if err := api.node.checkRateLimit("admin_nodeInfo"); err != nil {
+		return nil, err
+	}
 	server := api.node.Server()
 	if server == nil {
 		return nil, ErrNodeStopped
@@ -290,6 +548,23 @@ func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// DiscoveryTable retrieves the content of the discovery node table, listing the
+// entry counts and liveness of each bucket.
+func (api *PublicAdminAPI) DiscoveryTable() (*discover.TableInfo, error) {
+	if err := api.node.checkRateLimit("admin_discoveryTable"); err != nil {
+		return nil, err
+	}
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	info := server.DiscoveryTable()
+	if info == nil {
+		return nil, errors.New("discovery is disabled")
+	}
+	return info, nil
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *PublicAdminAPI) Datadir() string {
 	return api.node.DataDir()
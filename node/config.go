@@ -25,6 +25,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/external"
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/validate"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -68,7 +70,12 @@ type Config struct {
 	// registered services, instead those can use utility methods to create/access
 	// databases or flat files. This enables ephemeral nodes which can fully reside
 	// in memory.
-	DataDir string
+	DataDir string `validate:"omitempty,dir"`
+
+	// DatabaseBackend is the name of the key-value store backend used for the
+	// databases in the data directory, leveldb if empty. Alternative backends
+	// must be registered with ethdb.RegisterBackend.
+	DatabaseBackend string `toml:",omitempty"`
 
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
@@ -101,7 +108,9 @@ type Config struct {
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
-	// relative), then that specific path is enforced. An empty path disables IPC.
+	// relative), then that specific path is enforced. On Linux, a path starting
+	// with @ names a socket in the abstract namespace instead of a file. An empty
+	// path disables IPC.
 	IPCPath string `toml:",omitempty"`
 
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
@@ -111,7 +120,7 @@ type Config struct {
 	// HTTPPort is the TCP port number on which to start the HTTP RPC server. The
 	// default zero value is/ valid and will pick a port number randomly (useful
 	// for ephemeral nodes).
-	HTTPPort int `toml:",omitempty"`
+	HTTPPort int `toml:",omitempty" validate:"min=0,max=65535"`
 
 	// HTTPCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
@@ -143,7 +152,7 @@ type Config struct {
 	// WSPort is the TCP port number on which to start the websocket RPC server. The
 	// default zero value is/ valid and will pick a port number randomly (useful for
 	// ephemeral nodes).
-	WSPort int `toml:",omitempty"`
+	WSPort int `toml:",omitempty" validate:"min=0,max=65535"`
 
 	// WSOrigins is the list of domain to accept websocket requests from. Please be
 	// aware that the server can only act upon the HTTP request the client sends and
@@ -162,6 +171,35 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// DrainTimeout is the maximum time to wait for in-flight RPC requests to finish
+	// when the node is stopped. The RPC endpoints stop accepting new connections
+	// while draining. Zero disables draining.
+	DrainTimeout time.Duration `toml:",omitempty" validate:"min=0"`
+
+	// StopTimeout is the maximum time each service may take to stop. If a service
+	// exceeds it, the stacks of all goroutines are dumped to a file in the instance
+	// directory and the process is forcibly terminated. Zero disables the deadline.
+	StopTimeout time.Duration `toml:",omitempty" validate:"min=0"`
+
+	// MethodRateLimits limits how often admin RPC methods may be called, in requests
+	// per second, keyed by method name (e.g. admin_peers). Short bursts of up to one
+	// second worth of requests are allowed. Methods mapped to zero are unlimited.
+	// If nil, DefaultMethodRateLimits is used. Changes reset the rate limiters.
+	MethodRateLimits map[string]float64 `toml:",omitempty" reload:"true"`
+
+	// HealthEndpoints enables the liveness and readiness probes at /health/live and
+	// /health/ready on the HTTP RPC endpoint. The node is ready when all of its
+	// health checks pass, see Node.RegisterHealthCheck.
+	HealthEndpoints bool `toml:",omitempty"`
+
+	// HealthMinPeers is the minimum number of connected peers for the node to be
+	// considered ready.
+	HealthMinPeers int `toml:",omitempty" validate:"min=0"`
+
+	// HealthMaxSyncLag is the maximum number of blocks the chain of the node may
+	// be behind the network head for the node to be considered ready.
+	HealthMaxSyncLag uint64 `toml:",omitempty"`
+
 	// GraphQLHost is the host interface on which to start the GraphQL server. If this
 	// field is empty, no GraphQL API endpoint will be started.
 	GraphQLHost string `toml:",omitempty"`
@@ -169,7 +207,7 @@ type Config struct {
 	// GraphQLPort is the TCP port number on which to start the GraphQL server. The
 	// default zero value is/ valid and will pick a port number randomly (useful
 	// for ephemeral nodes).
-	GraphQLPort int `toml:",omitempty"`
+	GraphQLPort int `toml:",omitempty" validate:"min=0,max=65535"`
 
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
@@ -188,11 +226,23 @@ type Config struct {
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
+	// LogLevel sets the verbosity of the root logger (crit, error, warn, info, debug
+	// or trace). The verbosity is left untouched if it is empty. The node doesn't
+	// apply it on creation, it is up to the program using the node, but changes
+	// are applied when reloading the configuration at runtime.
+	LogLevel string `toml:",omitempty" reload:"true" validate:"omitempty,oneof=crit error warn info debug trace"`
+
 	staticNodesWarning     bool
 	trustedNodesWarning    bool
 	oldGethResourceWarning bool
 }
 
+// Validate checks the configuration against the constraints declared in the
+// validate tags of its fields, including those of the embedded p2p settings.
+func (c *Config) Validate() error {
+	return validate.Struct("Config", c)
+}
+
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -208,6 +258,10 @@ func (c *Config) IPCEndpoint() string {
 		}
 		return `\\.\pipe\` + c.IPCPath
 	}
+	// Abstract socket names on Linux don't live in the file system
+	if runtime.GOOS == "linux" && strings.HasPrefix(c.IPCPath, "@") {
+		return c.IPCPath
+	}
 	// Resolve names into the data directory full paths otherwise
 	if filepath.Base(c.IPCPath) == c.IPCPath {
 		if c.DataDir == "" {
@@ -19,12 +19,15 @@ package node
 import (
 	"bytes"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 )
 
@@ -160,3 +163,189 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that configuration errors are reported with the path of the field.
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		modify func(*Config)
+		err    string
+	}{
+		{modify: func(c *Config) {}},
+		{
+			modify: func(c *Config) { c.HTTPPort = 70000 },
+			err:    "Config.HTTPPort: must be between 0 and 65535, got 70000",
+		},
+		{
+			modify: func(c *Config) { c.P2P.MaxPeers = -1 },
+			err:    "Config.P2P.MaxPeers: must be at least 0, got -1",
+		},
+		{
+			modify: func(c *Config) { c.P2P.ListenAddr = "localhost" },
+			err:    `Config.P2P.ListenAddr: must be a host:port address, got "localhost"`,
+		},
+	}
+	for i, test := range tests {
+		cfg := DefaultConfig
+		test.modify(&cfg)
+
+		err := cfg.Validate()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("test %d: unexpected error: %v", i, err)
+			}
+		} else if err == nil || err.Error() != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, test.err)
+		}
+	}
+}
+
+// Tests that creating a node leaves the process wide log level alone, as other
+// nodes in the process may log too.
+func TestNewKeepsVerbosity(t *testing.T) {
+	defer func(orig func(log.Lvl)) { setVerbosity = orig }(setVerbosity)
+	setVerbosity = func(lvl log.Lvl) { t.Errorf("verbosity changed to %v", lvl) }
+
+	cfg := testNodeConfig()
+	cfg.LogLevel = "trace"
+	stack, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.Close()
+}
+
+// Tests that reloadable settings are applied to a running node, while changes to
+// any other setting are rejected without applying anything.
+func TestConfigReload(t *testing.T) {
+	var verbosity log.Lvl
+	defer func(orig func(log.Lvl)) { setVerbosity = orig }(setVerbosity)
+	setVerbosity = func(lvl log.Lvl) { verbosity = lvl }
+
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	// Change both reloadable settings.
+	cfg := *testNodeConfig()
+	cfg.LogLevel = "debug"
+	cfg.P2P.MaxPeers = 7
+	if err := stack.ReloadConfig(&cfg); err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if verbosity != log.LvlDebug {
+		t.Errorf("verbosity mismatch: have %v, want %v", verbosity, log.LvlDebug)
+	}
+	if max := stack.Server().PeerLimit(); max != 7 {
+		t.Errorf("server peer limit mismatch: have %d, want 7", max)
+	}
+	// A change to a fixed setting must not apply the reloadable ones.
+	cfg.P2P.MaxPeers = 9
+	cfg.HTTPPort = 9999
+	err = stack.ReloadConfig(&cfg)
+	if rerr, ok := err.(*ConfigReloadError); !ok || rerr.Field != "Config.HTTPPort" || rerr.Err != ErrNotHotReloadable {
+		t.Fatalf("error mismatch: have %v, want HTTPPort not reloadable", err)
+	}
+	if max := stack.Server().PeerLimit(); max != 7 {
+		t.Errorf("server peer limit changed by failed reload: have %d, want 7", max)
+	}
+}
+
+// Tests that the admin RPC rate limits can be changed at runtime, and invalid
+// limits are rejected without replacing the current ones.
+func TestConfigReloadRateLimits(t *testing.T) {
+	cfg := *testNodeConfig()
+	cfg.MethodRateLimits = map[string]float64{"admin_peers": 1}
+	stack, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.checkRateLimit("admin_peers"); err != nil {
+		t.Fatalf("first request limited: %v", err)
+	}
+	if err := stack.checkRateLimit("admin_peers"); err == nil {
+		t.Fatalf("second request not limited")
+	}
+	// Lift the limit of admin_peers and limit admin_nodeInfo instead.
+	cfg.MethodRateLimits = map[string]float64{"admin_peers": 0, "admin_nodeInfo": 1}
+	if err := stack.ReloadConfig(&cfg); err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := stack.checkRateLimit("admin_peers"); err != nil {
+			t.Fatalf("request %d: unlimited method failed: %v", i, err)
+		}
+	}
+	stack.checkRateLimit("admin_nodeInfo")
+	if err := stack.checkRateLimit("admin_nodeInfo"); err == nil {
+		t.Fatalf("newly limited method not limited")
+	}
+	// Invalid limits must be rejected as a whole.
+	bad := cfg
+	bad.MethodRateLimits = map[string]float64{"admin_peers": 1, "eth_call": 1}
+	if err := stack.ReloadConfig(&bad); err == nil {
+		t.Fatalf("rate limit of non-admin method accepted")
+	}
+	if err := stack.checkRateLimit("admin_peers"); err != nil {
+		t.Fatalf("limits changed by failed reload: %v", err)
+	}
+}
+
+// Tests that ConfigDiff compares nested structs and big integers by value.
+func TestConfigDiff(t *testing.T) {
+	type inner struct {
+		Price *big.Int `reload:"true"`
+		Limit uint64
+	}
+	type config struct {
+		Inner  inner
+		Ptr    *inner
+		Hidden int `toml:"-"`
+	}
+	old := config{Inner: inner{Price: big.NewInt(1)}, Ptr: &inner{Limit: 1}}
+	tests := []struct {
+		modify  func(*config)
+		changed []string
+		err     string
+	}{
+		{
+			modify: func(c *config) { c.Inner.Price = big.NewInt(1) },
+		},
+		{
+			modify:  func(c *config) { c.Inner.Price = big.NewInt(2) },
+			changed: []string{"C.Inner.Price"},
+		},
+		{
+			modify: func(c *config) { c.Hidden = 1 },
+		},
+		{
+			modify: func(c *config) { c.Ptr = &inner{Limit: 2} },
+			err:    "config field C.Ptr.Limit cannot be changed at runtime",
+		},
+		{
+			modify: func(c *config) { c.Ptr = nil },
+			err:    "config field C.Ptr cannot be changed at runtime",
+		},
+	}
+	for i, test := range tests {
+		new := old
+		test.modify(&new)
+
+		changed, err := ConfigDiff("C", &old, &new)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("test %d: unexpected error: %v", i, err)
+			} else if !reflect.DeepEqual(changed, test.changed) {
+				t.Errorf("test %d: changed fields mismatch: have %v, want %v", i, changed, test.changed)
+			}
+		} else if err == nil || err.Error() != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, test.err)
+		}
+	}
+}
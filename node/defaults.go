@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -34,8 +35,20 @@ const (
 	DefaultWSPort      = 8546        // Default TCP port for the websocket RPC server
 	DefaultGraphQLHost = "localhost" // Default host interface for the GraphQL server
 	DefaultGraphQLPort = 8547        // Default TCP port for the GraphQL server
+
+	DefaultDrainTimeout = 30 * time.Second // Default time to wait for in-flight RPC requests on shutdown
+	DefaultStopTimeout  = 5 * time.Minute  // Default time a service may take to stop before the process is killed
+
+	DefaultHealthMaxSyncLag = 64 // Default number of blocks a ready node may be behind the network head
 )
 
+// DefaultMethodRateLimits are the rate limits of admin RPC methods used if none
+// are configured.
+var DefaultMethodRateLimits = map[string]float64{
+	"admin_peers":    5,
+	"admin_startRPC": 0.1,
+}
+
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
 	DataDir:             DefaultDataDir(),
@@ -47,10 +60,14 @@ var DefaultConfig = Config{
 	WSModules:           []string{"net", "web3"},
 	GraphQLPort:         DefaultGraphQLPort,
 	GraphQLVirtualHosts: []string{"localhost"},
+	DrainTimeout:        DefaultDrainTimeout,
+	StopTimeout:         DefaultStopTimeout,
+	HealthMaxSyncLag:    DefaultHealthMaxSyncLag,
 	P2P: p2p.Config{
-		ListenAddr: ":30303",
-		MaxPeers:   50,
-		NAT:        nat.Any(),
+		ListenAddr:      ":30303",
+		MaxPeers:        50,
+		NAT:             nat.Any(),
+		PenaltyDuration: time.Hour,
 	},
 }
 
@@ -29,6 +29,11 @@ var (
 	ErrNodeRunning    = errors.New("node already running")
 	ErrServiceUnknown = errors.New("unknown service")
 
+	ErrCyclicDependency     = errors.New("cyclic service dependency")
+	ErrDuplicateServiceName = errors.New("duplicate service name")
+	ErrNotHotReloadable     = errors.New("cannot be changed at runtime")
+	ErrStopTimeout          = errors.New("service failed to stop in time")
+
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
 
@@ -39,6 +44,17 @@ func convertFileLockError(err error) error {
 	return err
 }
 
+// RateLimitError is returned by admin RPC methods called more often than their
+// configured rate limit permits.
+type RateLimitError struct {
+	Method string
+}
+
+func (e *RateLimitError) Error() string { return "rate limited" }
+
+// ErrorCode returns the JSON-RPC error code of rate limited requests.
+func (e *RateLimitError) ErrorCode() int { return -32005 }
+
 // DuplicateServiceError is returned during Node startup if a registered service
 // constructor returns a service of the same type that was already started.
 type DuplicateServiceError struct {
@@ -50,6 +66,41 @@ func (e *DuplicateServiceError) Error() string {
 	return fmt.Sprintf("duplicate service: %v", e.Kind)
 }
 
+// UnknownDependencyError is returned during Node startup if a registered service
+// depends on a service name that was never registered.
+type UnknownDependencyError struct {
+	Service    string
+	Dependency string
+}
+
+// Error generates a textual representation of the unknown dependency error.
+func (e *UnknownDependencyError) Error() string {
+	return fmt.Sprintf("service %q depends on unknown service %q", e.Service, e.Dependency)
+}
+
+// PluginABIError is returned when loading a plugin built against a different
+// version of the plugin ABI.
+type PluginABIError struct {
+	Have, Want int
+}
+
+// Error generates a textual representation of the plugin ABI error.
+func (e *PluginABIError) Error() string {
+	return fmt.Sprintf("incompatible plugin ABI version %d, want %d", e.Have, e.Want)
+}
+
+// ConfigReloadError is returned when reloading the configuration fails because
+// of the named field.
+type ConfigReloadError struct {
+	Field string
+	Err   error
+}
+
+// Error generates a textual representation of the config reload error.
+func (e *ConfigReloadError) Error() string {
+	return fmt.Sprintf("config field %s %v", e.Field, e.Err)
+}
+
 // StopError is returned if a Node fails to stop either any of its registered
 // services or itself.
 type StopError struct {
@@ -0,0 +1,199 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// healthCheckTimeout is the time allowed for all readiness checks to complete.
+	healthCheckTimeout = 5 * time.Second
+
+	// Paths of the health endpoints served on the HTTP RPC endpoint.
+	healthLivePath  = "/health/live"
+	healthReadyPath = "/health/ready"
+)
+
+// HealthCheck is a condition of the node's readiness to serve requests. It
+// returns nil if the condition holds, or an error describing why it doesn't.
+type HealthCheck func(ctx context.Context) error
+
+// HealthStatus is the outcome of the health checks of a node.
+type HealthStatus struct {
+	Live   bool              `json:"live"`   // Whether the node is running
+	Ready  bool              `json:"ready"`  // Whether the node is running and all checks pass
+	Checks map[string]string `json:"checks"` // Outcome of each check, "ok" or the failure reason
+}
+
+// RegisterHealthCheck adds a readiness check to the node, replacing any check
+// registered under the same name. The default checks are named "rpc", "peers"
+// and "sync", and may be replaced or disabled by registering a nil check.
+func (n *Node) RegisterHealthCheck(name string, check HealthCheck) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if check == nil {
+		delete(n.healthChecks, name)
+		return
+	}
+	n.healthChecks[name] = check
+}
+
+// defaultHealthChecks returns the readiness checks every node starts out with.
+func (n *Node) defaultHealthChecks() map[string]HealthCheck {
+	return map[string]HealthCheck{
+		"rpc":   n.checkRPC,
+		"peers": n.checkPeers,
+		"sync":  n.checkSync,
+	}
+}
+
+// Health runs the readiness checks of the node. The checks are skipped if the
+// node isn't running, as it can't be ready anyway.
+func (n *Node) Health(ctx context.Context) *HealthStatus {
+	n.lock.RLock()
+	live := n.server != nil
+	checks := make(map[string]HealthCheck, len(n.healthChecks))
+	for name, check := range n.healthChecks {
+		checks[name] = check
+	}
+	n.lock.RUnlock()
+
+	status := &HealthStatus{Live: live, Ready: live, Checks: make(map[string]string)}
+	if !live {
+		return status
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checks[name](ctx); err != nil {
+			status.Ready = false
+			status.Checks[name] = err.Error()
+			continue
+		}
+		status.Checks[name] = "ok"
+	}
+	return status
+}
+
+// checkRPC verifies that the node answers RPC requests.
+func (n *Node) checkRPC(ctx context.Context) error {
+	client, err := n.Attach()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var version string
+	return client.CallContext(ctx, &version, "web3_clientVersion")
+}
+
+// checkPeers verifies that the node is connected to the configured minimum
+// number of peers.
+func (n *Node) checkPeers(ctx context.Context) error {
+	server := n.Server()
+	if server == nil {
+		return ErrNodeStopped
+	}
+	if have, want := server.PeerCount(), n.config.HealthMinPeers; have < want {
+		return fmt.Errorf("too few peers: have %d, want %d", have, want)
+	}
+	return nil
+}
+
+// checkSync verifies that the chain of the node is within the configured number
+// of blocks of the network head, as reported by eth_syncing. Nodes not running a
+// chain pass the check.
+func (n *Node) checkSync(ctx context.Context) error {
+	client, err := n.Attach()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var result json.RawMessage
+	if err := client.CallContext(ctx, &result, "eth_syncing"); err != nil {
+		if rerr, ok := err.(rpc.Error); ok && rerr.ErrorCode() == -32601 {
+			return nil // method not found, no chain to sync
+		}
+		return err
+	}
+	var syncing bool
+	if json.Unmarshal(result, &syncing) == nil {
+		return nil // not syncing
+	}
+	var progress struct {
+		CurrentBlock hexutil.Uint64 `json:"currentBlock"`
+		HighestBlock hexutil.Uint64 `json:"highestBlock"`
+	}
+	if err := json.Unmarshal(result, &progress); err != nil {
+		return err
+	}
+	if progress.HighestBlock <= progress.CurrentBlock {
+		return nil
+	}
+	if lag := uint64(progress.HighestBlock - progress.CurrentBlock); lag > n.config.HealthMaxSyncLag {
+		return fmt.Errorf("syncing: %d blocks behind the network head", lag)
+	}
+	return nil
+}
+
+// healthHandler is an HTTP middleware serving the liveness and readiness probes
+// of the node, forwarding all other requests to the next handler. The probes
+// answer with 200 OK if they pass and 503 Service Unavailable otherwise. The
+// readiness probe also reports the outcome of the checks in the response body.
+func (n *Node) healthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pass   bool
+			result interface{}
+		)
+		switch r.URL.Path {
+		case healthLivePath:
+			n.lock.RLock()
+			pass = n.server != nil
+			n.lock.RUnlock()
+			result = map[string]bool{"live": pass}
+		case healthReadyPath:
+			status := n.Health(r.Context())
+			pass, result = status.Ready, status
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !pass {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// syncAPI mimics the eth_syncing method of a chain service. The progress may be
+// updated while the node is running, so it's accessed atomically.
+type syncAPI struct {
+	current, highest uint64
+}
+
+func (api *syncAPI) Syncing() (interface{}, error) {
+	current, highest := atomic.LoadUint64(&api.current), atomic.LoadUint64(&api.highest)
+	if current >= highest {
+		return false, nil
+	}
+	return map[string]interface{}{
+		"currentBlock": hexutil.Uint64(current),
+		"highestBlock": hexutil.Uint64(highest),
+	}, nil
+}
+
+// startHealthNode starts a node serving the given sync progress over eth_syncing,
+// if any.
+func startHealthNode(t *testing.T, config *Config, sync *syncAPI) *Node {
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if sync != nil {
+		service := func(*ServiceContext) (Service, error) {
+			return &InstrumentedService{apis: []rpc.API{{Namespace: "eth", Version: "1.0", Service: sync}}}, nil
+		}
+		if err := stack.Register(service); err != nil {
+			t.Fatalf("failed to register service: %v", err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	return stack
+}
+
+// Tests that the readiness checks reflect the state of the node.
+func TestHealthChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		minPeers int
+		maxLag   uint64
+		sync     *syncAPI
+		failed   []string
+	}{
+		{name: "no chain"},
+		{name: "synced", sync: &syncAPI{current: 100, highest: 100}},
+		{name: "lag within limit", maxLag: 10, sync: &syncAPI{current: 90, highest: 100}},
+		{name: "lag beyond limit", maxLag: 10, sync: &syncAPI{current: 89, highest: 100}, failed: []string{"sync"}},
+		{name: "too few peers", minPeers: 1, failed: []string{"peers"}},
+	}
+	for _, tt := range tests {
+		config := testNodeConfig()
+		config.HealthMinPeers = tt.minPeers
+		config.HealthMaxSyncLag = tt.maxLag
+
+		stack := startHealthNode(t, config, tt.sync)
+		status := stack.Health(context.Background())
+		stack.Close()
+
+		if !status.Live {
+			t.Errorf("%s: running node not live", tt.name)
+		}
+		if ready := len(tt.failed) == 0; status.Ready != ready {
+			t.Errorf("%s: readiness mismatch: have %v, want %v", tt.name, status.Ready, ready)
+		}
+		var failed []string
+		for _, name := range []string{"peers", "rpc", "sync"} {
+			if result, ok := status.Checks[name]; !ok {
+				t.Errorf("%s: check %s not run", tt.name, name)
+			} else if result != "ok" {
+				failed = append(failed, name)
+			}
+		}
+		if !reflect.DeepEqual(failed, tt.failed) {
+			t.Errorf("%s: failed checks mismatch: have %v, want %v", tt.name, failed, tt.failed)
+		}
+	}
+}
+
+// Tests that custom checks can be registered and the default ones replaced.
+func TestHealthCheckRegistration(t *testing.T) {
+	stack := startHealthNode(t, testNodeConfig(), nil)
+	defer stack.Close()
+
+	stack.RegisterHealthCheck("custom", func(context.Context) error { return errors.New("not warmed up") })
+	stack.RegisterHealthCheck("rpc", nil)
+
+	status := stack.Health(context.Background())
+	if status.Ready {
+		t.Errorf("node ready despite failing check")
+	}
+	want := map[string]string{"custom": "not warmed up", "peers": "ok", "sync": "ok"}
+	if !reflect.DeepEqual(status.Checks, want) {
+		t.Errorf("check results mismatch: have %v, want %v", status.Checks, want)
+	}
+	// A stopped node is neither live nor ready
+	stack.Stop()
+	if status := stack.Health(context.Background()); status.Live || status.Ready {
+		t.Errorf("stopped node healthy: live %v, ready %v", status.Live, status.Ready)
+	}
+}
+
+// Tests that the probes are served on the HTTP RPC endpoint if enabled.
+func TestHealthEndpoints(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost = "127.0.0.1"
+	config.HealthEndpoints = true
+	config.HealthMaxSyncLag = 10
+
+	sync := &syncAPI{current: 100, highest: 100}
+	stack := startHealthNode(t, config, sync)
+	defer stack.Close()
+
+	url := "http://" + stack.httpListener.Addr().String()
+	probe := func(path string) (int, map[string]interface{}) {
+		res, err := http.Get(url + path)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		defer res.Body.Close()
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: invalid response: %v", path, err)
+		}
+		return res.StatusCode, body
+	}
+	if code, body := probe(healthLivePath); code != http.StatusOK || body["live"] != true {
+		t.Errorf("liveness probe failed: %d %v", code, body)
+	}
+	if code, body := probe(healthReadyPath); code != http.StatusOK || body["ready"] != true {
+		t.Errorf("readiness probe failed: %d %v", code, body)
+	}
+	// Falling behind the network head must fail the readiness probe only
+	atomic.StoreUint64(&sync.highest, 200)
+	if code, _ := probe(healthLivePath); code != http.StatusOK {
+		t.Errorf("liveness probe failed while syncing: %d", code)
+	}
+	if code, body := probe(healthReadyPath); code != http.StatusServiceUnavailable || body["ready"] != false {
+		t.Errorf("readiness probe passed while syncing: %d %v", code, body)
+	}
+	// RPC requests must still be served
+	client, err := rpc.Dial(url)
+	if err != nil {
+		t.Fatalf("failed to dial HTTP endpoint: %v", err)
+	}
+	defer client.Close()
+
+	var version string
+	if err := client.Call(&version, "web3_clientVersion"); err != nil {
+		t.Errorf("RPC request failed: %v", err)
+	}
+}
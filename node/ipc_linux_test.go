@@ -0,0 +1,66 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that the IPC endpoint can be placed in the Linux abstract socket
+// namespace and serves JSON-RPC requests there.
+func TestAbstractIPCEndpoint(t *testing.T) {
+	name := fmt.Sprintf("@geth-test-%x.ipc", crypto.Keccak256([]byte(t.Name()), []byte(fmt.Sprint(os.Getpid())))[:8])
+
+	conf := testNodeConfig()
+	conf.IPCPath = name
+	stack, err := New(conf)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if endpoint := stack.IPCEndpoint(); endpoint != name {
+		t.Fatalf("IPC endpoint mismatch: have %s, want %s", endpoint, name)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	client, err := rpc.Dial(name)
+	if err != nil {
+		t.Fatalf("failed to dial abstract IPC endpoint: %v", err)
+	}
+	defer client.Close()
+
+	var modules map[string]string
+	if err := client.Call(&modules, "rpc_modules"); err != nil {
+		t.Fatalf("RPC call failed: %v", err)
+	}
+	if _, ok := modules["admin"]; !ok {
+		t.Errorf("admin module missing from %v", modules)
+	}
+	// Nothing must have been created in the file system.
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("IPC endpoint shows up as a file: %v", err)
+	}
+}
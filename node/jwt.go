@@ -0,0 +1,84 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// jwtSecretLength is the length of JWT secrets used by the Engine API.
+const jwtSecretLength = 32
+
+var errInvalidJWTSecret = errors.New("invalid JWT secret, expected 32 hex encoded bytes")
+
+// jwtHeader is the encoded JOSE header of all tokens, they are signed using HMAC-SHA256.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// ReadJWTSecret reads a hex encoded JWT secret from a file, as used to
+// authenticate with the Engine API of execution clients.
+func ReadJWTSecret(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	secret := common.FromHex(strings.TrimSpace(string(data)))
+	if len(secret) != jwtSecretLength {
+		return nil, errInvalidJWTSecret
+	}
+	return secret, nil
+}
+
+// NewJWTAuth creates an authenticator adding a JWT bearer token signed with the
+// given secret to requests. The token only carries the issued-at claim, which
+// servers accept for a short time only, so a fresh token is created for every
+// request.
+func NewJWTAuth(secret []byte) rpc.HTTPAuth {
+	return func(header http.Header) error {
+		token, err := newJWT(secret, time.Now())
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// newJWT creates an HS256 token issued at the given time.
+func newJWT(secret []byte, issued time.Time) (string, error) {
+	claims, err := json.Marshal(struct {
+		IssuedAt int64 `json:"iat"`
+	}{issued.Unix()})
+	if err != nil {
+		return "", err
+	}
+	payload := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -17,14 +17,18 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -35,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/prometheus/tsdb/fileutil"
+	"golang.org/x/time/rate"
 )
 
 // Node is a container on which services can be registered.
@@ -49,8 +54,12 @@ type Node struct {
 	serverConfig p2p.Config
 	server       *p2p.Server // Currently running P2P networking layer
 
-	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
+	serviceFuncs []*ServiceDescriptor     // Service descriptors (in registration order)
 	services     map[reflect.Type]Service // Currently running services
+	serviceOrder []reflect.Type           // Start order of the running services
+	hooks        []LifecycleHook          // Lifecycle hooks (in registration order)
+	healthChecks map[string]HealthCheck   // Readiness checks by name
+	protocols    []p2p.Protocol           // Protocols registered outside of services
 
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
@@ -64,11 +73,17 @@ type Node struct {
 	httpListener  net.Listener // HTTP RPC listener socket to server API requests
 	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
 
+	httpMiddlewares []func(http.Handler) http.Handler // HTTP handler wrappers (first registered = outermost)
+	configLoader    ConfigLoader                      // Config file reader for admin_reloadConfig
+	rateLimiters    map[string]*rate.Limiter          // Admin RPC method rate limiters, replaced on config reload
+	rateLimitLock   sync.RWMutex                      // Protects rateLimiters
+
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
-	stop chan struct{} // Channel to wait for termination notifications
+	stop chan struct{}  // Channel to wait for termination notifications
+	exit func(code int) // Terminates the process if a service fails to stop in time
 	lock sync.RWMutex
 
 	log log.Logger
@@ -98,6 +113,10 @@ func New(conf *Config) (*Node, error) {
 	if strings.HasSuffix(conf.Name, ".ipc") {
 		return nil, errors.New(`Config.Name cannot end in ".ipc"`)
 	}
+	limiters, err := makeRateLimiters(conf.MethodRateLimits)
+	if err != nil {
+		return nil, err
+	}
 	// Ensure that the AccountManager method works before the node has started.
 	// We rely on this in cmd/geth.
 	am, ephemeralKeystore, err := makeAccountManager(conf)
@@ -109,17 +128,56 @@ func New(conf *Config) (*Node, error) {
 	}
 	// Note: any interaction with Config that would create/touch files
 	// in the data directory or instance directory is delayed until Start.
-	return &Node{
+	node := &Node{
 		accman:            am,
 		ephemeralKeystore: ephemeralKeystore,
 		config:            conf,
-		serviceFuncs:      []ServiceConstructor{},
+		serviceFuncs:      []*ServiceDescriptor{},
 		ipcEndpoint:       conf.IPCEndpoint(),
 		httpEndpoint:      conf.HTTPEndpoint(),
 		wsEndpoint:        conf.WSEndpoint(),
 		eventmux:          new(event.TypeMux),
+		rateLimiters:      limiters,
 		log:               conf.Logger,
-	}, nil
+		exit:              os.Exit,
+	}
+	node.healthChecks = node.defaultHealthChecks()
+	return node, nil
+}
+
+// makeRateLimiters creates the rate limiters of admin RPC methods.
+func makeRateLimiters(limits map[string]float64) (map[string]*rate.Limiter, error) {
+	if limits == nil {
+		limits = DefaultMethodRateLimits
+	}
+	limiters := make(map[string]*rate.Limiter)
+	for method, rps := range limits {
+		if !strings.HasPrefix(method, "admin_") {
+			return nil, fmt.Errorf("invalid rate limit for %s: only admin methods can be limited", method)
+		}
+		if rps < 0 {
+			return nil, fmt.Errorf("invalid rate limit for %s: %v requests per second", method, rps)
+		}
+		if rps == 0 {
+			continue
+		}
+		burst := int(math.Ceil(rps))
+		limiters[method] = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+	return limiters, nil
+}
+
+// checkRateLimit returns a *RateLimitError if the given admin RPC method was
+// called more often than its rate limit permits.
+func (n *Node) checkRateLimit(method string) error {
+	n.rateLimitLock.RLock()
+	limiter := n.rateLimiters[method]
+	n.rateLimitLock.RUnlock()
+
+	if limiter != nil && !limiter.Allow() {
+		return &RateLimitError{Method: method}
+	}
+	return nil
 }
 
 // Close stops the Node and releases resources acquired in
@@ -154,12 +212,139 @@ func (n *Node) Register(constructor ServiceConstructor) error {
 	if n.server != nil {
 		return ErrNodeRunning
 	}
-	n.serviceFuncs = append(n.serviceFuncs, constructor)
+	n.serviceFuncs = append(n.serviceFuncs, &ServiceDescriptor{Constructor: constructor})
 	return nil
 }
 
+// RegisterService injects a new service into the node's stack, declaring the
+// services it depends on. Dependencies are resolved at startup, so they may be
+// registered in any order; a dependency cycle fails the startup of the node with
+// ErrCyclicDependency.
+func (n *Node) RegisterService(desc ServiceDescriptor) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server != nil {
+		return ErrNodeRunning
+	}
+	if desc.Name != "" {
+		for _, registered := range n.serviceFuncs {
+			if registered.Name == desc.Name {
+				return ErrDuplicateServiceName
+			}
+		}
+	}
+	desc.DependsOn = append([]string(nil), desc.DependsOn...)
+	n.serviceFuncs = append(n.serviceFuncs, &desc)
+	return nil
+}
+
+// RegisterLifecycleHook adds a hook to be notified around the startup and
+// shutdown of the node. Hooks can only be registered while the node is stopped.
+func (n *Node) RegisterLifecycleHook(hook LifecycleHook) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server != nil {
+		return ErrNodeRunning
+	}
+	n.hooks = append(n.hooks, hook)
+	return nil
+}
+
+// RegisterProtocols adds devp2p protocols to the node which aren't provided by
+// a service. Protocols registered before the node is started are offered to all
+// peers. If the node is running, they are added to the live P2P server and only
+// negotiated with peers connecting afterwards, see p2p.Server.AddProtocol. The
+// registrations persist across restarts of the node.
+func (n *Node) RegisterProtocols(protocols []p2p.Protocol) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for _, p := range protocols {
+		for _, have := range n.protocols {
+			if have.Name == p.Name && have.Version == p.Version {
+				return fmt.Errorf("protocol %s/%d already registered", p.Name, p.Version)
+			}
+		}
+		if n.server != nil {
+			if err := n.server.AddProtocol(p); err != nil {
+				return err
+			}
+		}
+		n.protocols = append(n.protocols, p)
+	}
+	return nil
+}
+
+// UnregisterProtocol removes a protocol added through RegisterProtocols. If the
+// node is running, new peers no longer negotiate the protocol, while peers already
+// running it are left alone until they disconnect.
+func (n *Node) UnregisterProtocol(name string, version uint) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for i, p := range n.protocols {
+		if p.Name != name || p.Version != version {
+			continue
+		}
+		if n.server != nil {
+			if err := n.server.RemoveProtocol(name, version); err != nil {
+				return err
+			}
+		}
+		n.protocols = append(n.protocols[:i:i], n.protocols[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("protocol %s/%d not registered", name, version)
+}
+
+// UseMiddleware adds a wrapper around the handler chain of the HTTP RPC endpoint,
+// e.g. for logging, authentication or tracing. Middlewares are applied in
+// registration order, the first registered one being the outermost. They take
+// effect the next time the HTTP endpoint is started.
+func (n *Node) UseMiddleware(middleware func(http.Handler) http.Handler) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.httpMiddlewares = append(n.httpMiddlewares, middleware)
+}
+
+// lifecycleHooks returns a copy of the registered lifecycle hooks along with
+// whether the node is currently running.
+func (n *Node) lifecycleHooks() ([]LifecycleHook, bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return append([]LifecycleHook(nil), n.hooks...), n.server != nil
+}
+
 // Start create a live P2P node and starts running it.
 func (n *Node) Start() error {
+	// Run the pre-start hooks outside of the lock, so they may use the node
+	hooks, running := n.lifecycleHooks()
+	if running {
+		return ErrNodeRunning
+	}
+	ctx := context.Background()
+	for _, hook := range hooks {
+		if err := hook.BeforeStart(ctx); err != nil {
+			return err
+		}
+	}
+	if err := n.start(); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err := hook.AfterStart(ctx); err != nil {
+			n.log.Error("Lifecycle hook failed after node startup", "err", err)
+		}
+	}
+	return nil
+}
+
+// start assembles and starts the P2P server, services and RPC endpoints.
+func (n *Node) start() error {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
@@ -167,6 +352,11 @@ func (n *Node) Start() error {
 	if n.server != nil {
 		return ErrNodeRunning
 	}
+	// Order the services by their dependencies, failing on any cycles
+	descs, err := sortServices(n.serviceFuncs)
+	if err != nil {
+		return err
+	}
 	if err := n.openDataDir(); err != nil {
 		return err
 	}
@@ -190,8 +380,11 @@ func (n *Node) Start() error {
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
 	// Otherwise copy and specialize the P2P configuration
-	services := make(map[reflect.Type]Service)
-	for _, constructor := range n.serviceFuncs {
+	var (
+		services = make(map[reflect.Type]Service)
+		order    = make([]reflect.Type, 0, len(descs))
+	)
+	for _, desc := range descs {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
 			config:         n.config,
@@ -203,7 +396,7 @@ func (n *Node) Start() error {
 			ctx.services[kind] = s
 		}
 		// Construct and save the service
-		service, err := constructor(ctx)
+		service, err := desc.Constructor(ctx)
 		if err != nil {
 			return err
 		}
@@ -212,21 +405,23 @@ func (n *Node) Start() error {
 			return &DuplicateServiceError{Kind: kind}
 		}
 		services[kind] = service
+		order = append(order, kind)
 	}
 	// Gather the protocols and start the freshly assembled P2P server
-	for _, service := range services {
-		running.Protocols = append(running.Protocols, service.Protocols()...)
+	for _, kind := range order {
+		running.Protocols = append(running.Protocols, services[kind].Protocols()...)
 	}
+	running.Protocols = append(running.Protocols, n.protocols...)
 	if err := running.Start(); err != nil {
 		return convertFileLockError(err)
 	}
-	// Start each of the services
+	// Start each of the services in dependency order
 	var started []reflect.Type
-	for kind, service := range services {
+	for _, kind := range order {
 		// Start the next service, stopping all previous upon failure
-		if err := service.Start(running); err != nil {
-			for _, kind := range started {
-				services[kind].Stop()
+		if err := services[kind].Start(running); err != nil {
+			for i := len(started) - 1; i >= 0; i-- {
+				services[started[i]].Stop()
 			}
 			running.Stop()
 
@@ -245,6 +440,7 @@ func (n *Node) Start() error {
 	}
 	// Finish initializing the startup
 	n.services = services
+	n.serviceOrder = order
 	n.server = running
 	n.stop = make(chan struct{})
 	return nil
@@ -364,7 +560,12 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
+	middlewares := n.httpMiddlewares
+	if n.config.HealthEndpoints {
+		// Serve the probes ahead of the other middlewares, they may require auth
+		middlewares = append([]func(http.Handler) http.Handler{n.healthHandler}, middlewares...)
+	}
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts, middlewares...)
 	if err != nil {
 		return err
 	}
@@ -424,9 +625,86 @@ func (n *Node) stopWS() {
 	}
 }
 
+// drainRPC stops accepting new connections on the external RPC endpoints and
+// waits up to the configured drain timeout for their in-flight requests to finish.
+//
+// The node lock is only held to look up the endpoints, not during the drain, as
+// in-flight requests may need it to finish.
+func (n *Node) drainRPC() {
+	n.lock.RLock()
+	if n.server == nil || n.config.DrainTimeout <= 0 {
+		n.lock.RUnlock()
+		return
+	}
+	var (
+		listeners = []net.Listener{n.wsListener, n.httpListener, n.ipcListener}
+		handlers  = []*rpc.Server{n.wsHandler, n.httpHandler, n.ipcHandler}
+	)
+	n.lock.RUnlock()
+
+	for _, listener := range listeners {
+		if listener != nil {
+			listener.Close()
+		}
+	}
+	pending := func() (total int64) {
+		for _, handler := range handlers {
+			if handler != nil {
+				total += handler.ActiveRequests()
+			}
+		}
+		return total
+	}
+	if pending() == 0 {
+		return
+	}
+	n.log.Info("Waiting for RPC requests to finish", "pending", pending(), "timeout", n.config.DrainTimeout)
+
+	timeout := time.NewTimer(n.config.DrainTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for pending() > 0 {
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			n.log.Warn("Timed out waiting for RPC requests", "pending", pending())
+			return
+		}
+	}
+}
+
 // Stop terminates a running node along with all it's services. In the node was
 // not started, an error is returned.
 func (n *Node) Stop() error {
+	// Run the pre-stop hooks outside of the lock, so they may use the node
+	hooks, running := n.lifecycleHooks()
+	if !running {
+		return ErrNodeStopped
+	}
+	ctx := context.Background()
+	for _, hook := range hooks {
+		if err := hook.BeforeStop(ctx); err != nil {
+			n.log.Error("Lifecycle hook failed before node shutdown", "err", err)
+		}
+	}
+	if err := n.shutdown(); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err := hook.AfterStop(ctx); err != nil {
+			n.log.Error("Lifecycle hook failed after node shutdown", "err", err)
+		}
+	}
+	return nil
+}
+
+// shutdown terminates the RPC endpoints, services and the P2P server.
+func (n *Node) shutdown() error {
+	// Let the in-flight RPC requests finish before locking the node
+	n.drainRPC()
+
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
@@ -443,13 +721,16 @@ func (n *Node) Stop() error {
 	failure := &StopError{
 		Services: make(map[reflect.Type]error),
 	}
-	for kind, service := range n.services {
-		if err := service.Stop(); err != nil {
+	// Stop the services in reverse start order, so none outlives its dependencies
+	for i := len(n.serviceOrder) - 1; i >= 0; i-- {
+		kind := n.serviceOrder[i]
+		if err := n.stopService(kind, n.services[kind]); err != nil {
 			failure.Services[kind] = err
 		}
 	}
 	n.server.Stop()
 	n.services = nil
+	n.serviceOrder = nil
 	n.server = nil
 
 	// Release instance directory lock.
@@ -610,7 +891,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string) (
 	if n.config.DataDir == "" {
 		return rawdb.NewMemoryDatabase(), nil
 	}
-	return rawdb.NewLevelDBDatabase(n.config.ResolvePath(name), cache, handles, namespace)
+	return rawdb.NewBackendDatabase(n.config.DatabaseBackend, n.config.ResolvePath(name), cache, handles, namespace)
 }
 
 // OpenDatabaseWithFreezer opens an existing database with the given name (or
@@ -630,7 +911,7 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 	case !filepath.IsAbs(freezer):
 		freezer = n.config.ResolvePath(freezer)
 	}
-	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+	return rawdb.NewBackendDatabaseWithFreezer(n.config.DatabaseBackend, root, cache, handles, freezer, namespace)
 }
 
 // ResolvePath returns the absolute path of a resource in the instance directory.
@@ -74,3 +74,69 @@ func ExampleService() {
 	// Service starting...
 	// Service stopping...
 }
+
+// Message codes of the echo protocol.
+const (
+	echoRequestMsg = 0x00
+	echoReplyMsg   = 0x01
+)
+
+// echoProtocol is a minimal devp2p subprotocol answering every request message
+// with a reply carrying the same payload.
+var echoProtocol = p2p.Protocol{
+	Name:    "echo",
+	Version: 1,
+	Length:  2,
+	Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var payload string
+			if err := msg.Decode(&payload); err != nil {
+				return err
+			}
+			if msg.Code == echoRequestMsg {
+				if err := p2p.Send(rw, echoReplyMsg, payload); err != nil {
+					return err
+				}
+			}
+		}
+	},
+	NodeInfo: func() interface{} { return "echoing" },
+}
+
+func ExampleNode_RegisterProtocols() {
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		log.Fatalf("Failed to create network node: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.Start(); err != nil {
+		log.Fatalf("Failed to start the protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	// Protocols can be registered on the running node. They are negotiated with
+	// all peers connecting from now on, existing connections are unaffected.
+	if err := stack.RegisterProtocols([]p2p.Protocol{echoProtocol}); err != nil {
+		log.Fatalf("Failed to register protocol: %v", err)
+	}
+	server := stack.Server()
+	fmt.Println("echo info:", server.NodeInfo().Protocols["echo"])
+	fmt.Println("echo peers:", len(server.ProtocolPeers("echo", 1)))
+
+	// Unregistering stops offering the protocol to new peers.
+	if err := stack.UnregisterProtocol("echo", 1); err != nil {
+		log.Fatalf("Failed to unregister protocol: %v", err)
+	}
+	_, offered := server.NodeInfo().Protocols["echo"]
+	fmt.Println("echo offered:", offered)
+
+	// Output:
+	// echo info: echoing
+	// echo peers: 0
+	// echo offered: false
+}
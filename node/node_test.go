@@ -17,10 +17,15 @@
 package node
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -348,6 +353,356 @@ func TestServiceStartupAbortion(t *testing.T) {
 	}
 }
 
+// Tests that services are started in dependency order and stopped in reverse,
+// regardless of the order they were registered in.
+func TestServiceDependencyOrdering(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	// Register C -> B -> A in reverse dependency order
+	var events []string
+	services := []struct {
+		id    string
+		maker InstrumentingWrapper
+		deps  []string
+	}{
+		{"C", InstrumentedServiceMakerC, []string{"B"}},
+		{"B", InstrumentedServiceMakerB, []string{"A"}},
+		{"A", InstrumentedServiceMakerA, nil},
+	}
+	for _, service := range services {
+		id := service.id // Closure for the constructor
+		constructor := func(*ServiceContext) (Service, error) {
+			return &InstrumentedService{
+				startHook: func(*p2p.Server) { events = append(events, "start "+id) },
+				stopHook:  func() { events = append(events, "stop "+id) },
+			}, nil
+		}
+		desc := ServiceDescriptor{Name: id, Constructor: service.maker(constructor), DependsOn: service.deps}
+		if err := stack.RegisterService(desc); err != nil {
+			t.Fatalf("service %s: registration failed: %v", id, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	want := []string{"start A", "start B", "start C", "stop C", "stop B", "stop A"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("service event order mismatch: have %v, want %v", events, want)
+	}
+}
+
+// Tests that invalid service dependencies are rejected at startup.
+func TestServiceDependencyErrors(t *testing.T) {
+	// Create a dependency cycle and ensure the node refuses to start
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.RegisterService(ServiceDescriptor{Name: "A", Constructor: NewNoopServiceA, DependsOn: []string{"B"}}); err != nil {
+		t.Fatalf("failed to register service A: %v", err)
+	}
+	if err := stack.RegisterService(ServiceDescriptor{Name: "B", Constructor: NewNoopServiceB, DependsOn: []string{"A"}}); err != nil {
+		t.Fatalf("failed to register service B: %v", err)
+	}
+	if err := stack.RegisterService(ServiceDescriptor{Name: "A", Constructor: NewNoopServiceC}); err != ErrDuplicateServiceName {
+		t.Fatalf("duplicate name error mismatch: have %v, want %v", err, ErrDuplicateServiceName)
+	}
+	if err := stack.Start(); err != ErrCyclicDependency {
+		t.Fatalf("cyclic dependency error mismatch: have %v, want %v", err, ErrCyclicDependency)
+	}
+	// Depend on a non-existent service and ensure the node refuses to start
+	stack, err = New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.RegisterService(ServiceDescriptor{Name: "A", Constructor: NewNoopServiceA, DependsOn: []string{"X"}}); err != nil {
+		t.Fatalf("failed to register service A: %v", err)
+	}
+	want := &UnknownDependencyError{Service: "A", Dependency: "X"}
+	if err := stack.Start(); !reflect.DeepEqual(err, want) {
+		t.Fatalf("unknown dependency error mismatch: have %v, want %v", err, want)
+	}
+}
+
+// recordingHook is a LifecycleHook which appends its invocations to a shared
+// event log, optionally failing BeforeStart.
+type recordingHook struct {
+	id     string
+	events *[]string
+	fail   error
+}
+
+func (h *recordingHook) BeforeStart(context.Context) error {
+	*h.events = append(*h.events, h.id+":BeforeStart")
+	return h.fail
+}
+
+func (h *recordingHook) AfterStart(context.Context) error {
+	*h.events = append(*h.events, h.id+":AfterStart")
+	return nil
+}
+
+func (h *recordingHook) BeforeStop(context.Context) error {
+	*h.events = append(*h.events, h.id+":BeforeStop")
+	return nil
+}
+
+func (h *recordingHook) AfterStop(context.Context) error {
+	*h.events = append(*h.events, h.id+":AfterStop")
+	return nil
+}
+
+// Tests that lifecycle hooks are invoked in registration order around the
+// startup and shutdown of the services.
+func TestLifecycleHookOrdering(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	var events []string
+	service := func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{
+			startHook: func(*p2p.Server) { events = append(events, "service:Start") },
+			stopHook:  func() { events = append(events, "service:Stop") },
+		}, nil
+	}
+	if err := stack.Register(service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := stack.RegisterLifecycleHook(&recordingHook{id: id, events: &events}); err != nil {
+			t.Fatalf("failed to register hook %s: %v", id, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if err := stack.RegisterLifecycleHook(&recordingHook{id: "3", events: &events}); err != ErrNodeRunning {
+		t.Fatalf("hook registration on running node: have %v, want %v", err, ErrNodeRunning)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	want := []string{
+		"1:BeforeStart", "2:BeforeStart", "service:Start", "1:AfterStart", "2:AfterStart",
+		"1:BeforeStop", "2:BeforeStop", "service:Stop", "1:AfterStop", "2:AfterStop",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("hook invocation mismatch:\nhave %v\nwant %v", events, want)
+	}
+}
+
+// Tests that hooks created for a single lifecycle phase only run at that phase.
+func TestLifecycleHookPhases(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	var events []string
+	for phase, name := range []string{"PreStart", "PostStart", "PreStop", "PostStop"} {
+		name := name
+		hook := NewLifecycleHook(LifecyclePhase(phase), func(context.Context) error {
+			events = append(events, name)
+			return nil
+		})
+		if err := stack.RegisterLifecycleHook(hook); err != nil {
+			t.Fatalf("failed to register %s hook: %v", name, err)
+		}
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if want := []string{"PreStart", "PostStart"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("hook invocation mismatch after startup: have %v, want %v", events, want)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if want := []string{"PreStart", "PostStart", "PreStop", "PostStop"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("hook invocation mismatch after shutdown: have %v, want %v", events, want)
+	}
+}
+
+// Tests that a failing BeforeStart hook aborts the startup of the node before
+// any service is started.
+func TestLifecycleHookStartAbortion(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	var (
+		events  []string
+		failure = errors.New("fail")
+	)
+	service := func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{
+			startHook: func(*p2p.Server) { events = append(events, "service:Start") },
+		}, nil
+	}
+	if err := stack.Register(service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	stack.RegisterLifecycleHook(&recordingHook{id: "1", events: &events, fail: failure})
+	stack.RegisterLifecycleHook(&recordingHook{id: "2", events: &events})
+
+	if err := stack.Start(); err != failure {
+		t.Fatalf("stack startup failure mismatch: have %v, want %v", err, failure)
+	}
+	if want := []string{"1:BeforeStart"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("hook invocation mismatch: have %v, want %v", events, want)
+	}
+	if server := stack.Server(); server != nil {
+		t.Fatalf("p2p server running after aborted startup")
+	}
+}
+
+// slowAPI is an RPC service whose only method blocks for a while.
+type slowAPI struct {
+	node     *Node
+	started  chan struct{}
+	finished int32
+}
+
+func (api *slowAPI) Slow() {
+	close(api.started)
+	time.Sleep(300 * time.Millisecond)
+
+	// Access the node like many handlers do, which must not block the drain
+	api.node.Server()
+	atomic.StoreInt32(&api.finished, 1)
+}
+
+// Tests that stopping the node waits for in-flight RPC requests to finish, even
+// if they access the node.
+func TestNodeStopDrainsRPC(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost = "127.0.0.1"
+	config.HTTPModules = []string{"test"}
+	config.HTTPVirtualHosts = []string{"*"}
+	config.DrainTimeout = 5 * time.Second
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	api := &slowAPI{node: stack, started: make(chan struct{})}
+	service := func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{
+			apis: []rpc.API{{Namespace: "test", Version: "1.0", Service: api, Public: true}},
+		}, nil
+	}
+	if err := stack.Register(service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	client, err := rpc.DialHTTP("http://" + stack.httpListener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial HTTP endpoint: %v", err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- client.Call(nil, "test_slow") }()
+
+	select {
+	case <-api.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("RPC call never reached the service")
+	}
+	start := time.Now()
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= config.DrainTimeout {
+		t.Fatalf("drain timed out: took %v", elapsed)
+	}
+	if atomic.LoadInt32(&api.finished) == 0 {
+		t.Fatalf("node stopped before the in-flight request finished")
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+}
+
+// requestIDInjector is an HTTP middleware tagging every request with a unique
+// X-Request-ID header, which is also echoed in the response.
+func requestIDInjector(next http.Handler) http.Handler {
+	var counter int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-ID") == "" {
+			r.Header.Set("X-Request-ID", fmt.Sprintf("req-%d", atomic.AddInt64(&counter, 1)))
+		}
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Tests that HTTP middlewares are applied in registration order and that the
+// downstream handlers observe the modifications of the outer ones.
+func TestNodeHTTPMiddleware(t *testing.T) {
+	config := testNodeConfig()
+	config.HTTPHost = "127.0.0.1"
+	config.HTTPVirtualHosts = []string{"*"}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	var seen []string
+	stack.UseMiddleware(requestIDInjector)
+	stack.UseMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.Header.Get("X-Request-ID"))
+			next.ServeHTTP(w, r)
+		})
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	url := "http://" + stack.httpListener.Addr().String()
+	for i := 1; i <= 2; i++ {
+		body := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`)
+		resp, err := http.Post(url, "application/json", body)
+		if err != nil {
+			t.Fatalf("request %d: failed to call HTTP endpoint: %v", i, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("request %d: status mismatch: have %d, want %d", i, resp.StatusCode, http.StatusOK)
+		}
+		if have, want := resp.Header.Get("X-Request-ID"), fmt.Sprintf("req-%d", i); have != want {
+			t.Errorf("request %d: response ID mismatch: have %q, want %q", i, have, want)
+		}
+	}
+	if want := []string{"req-1", "req-2"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("downstream request IDs mismatch: have %v, want %v", seen, want)
+	}
+}
+
 // Tests that even if a registered service fails to shut down cleanly, it does
 // not influece the rest of the shutdown invocations.
 func TestServiceTerminationGuarantee(t *testing.T) {
@@ -597,3 +952,64 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that admin RPC methods are rate limited.
+func TestAdminRateLimit(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	client, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to connect to the inproc API server: %v", err)
+	}
+	defer client.Close()
+
+	// Fire rapid requests, only the default burst of admin_peers must pass
+	var (
+		start  = time.Now()
+		passed int
+	)
+	for i := 0; i < 10; i++ {
+		err := client.Call(nil, "admin_peers")
+		if err == nil {
+			passed++
+			continue
+		}
+		if rerr, ok := err.(rpc.Error); !ok || rerr.ErrorCode() != -32005 {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	if time.Since(start) > time.Second {
+		t.Skip("requests too slow to exhaust the rate limit")
+	}
+	if want := int(DefaultMethodRateLimits["admin_peers"]); passed != want {
+		t.Errorf("passed requests mismatch: have %d, want %d", passed, want)
+	}
+	// Methods without a limit must not be affected
+	for i := 0; i < 10; i++ {
+		if err := client.Call(nil, "admin_nodeInfo"); err != nil {
+			t.Fatalf("request %d: unlimited method failed: %v", i, err)
+		}
+	}
+}
+
+// Tests that rate limits can only be configured for admin methods.
+func TestAdminRateLimitConfig(t *testing.T) {
+	conf := testNodeConfig()
+	conf.MethodRateLimits = map[string]float64{"eth_call": 1}
+	if _, err := New(conf); err == nil {
+		t.Error("rate limit of non-admin method accepted")
+	}
+	conf.MethodRateLimits = map[string]float64{"admin_peers": -1}
+	if _, err := New(conf); err == nil {
+		t.Error("negative rate limit accepted")
+	}
+}
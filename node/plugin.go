@@ -0,0 +1,105 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"plugin"
+	"sort"
+)
+
+// PluginABIVersion is the version of the plugin contract implemented by this
+// package, i.e. the Plugin and PluginManifest types and the parts of Node
+// available to plugins during registration.
+//
+// The version is increased whenever the contract changes in a way that breaks
+// existing plugins. Only plugins built against exactly this version are loaded.
+// Note that the Go runtime imposes stricter rules on top: a plugin must be
+// built with the same Go toolchain and the same versions of all packages it
+// shares with the host binary, so plugins generally need to be rebuilt for
+// every release of geth even if the ABI version didn't change.
+const PluginABIVersion = 1
+
+// PluginSymbol is the name of the symbol plugins must export. It must be a
+// variable holding a Plugin or a value implementing Plugin.
+const PluginSymbol = "Plugin"
+
+// PluginManifest describes a plugin.
+type PluginManifest struct {
+	Name       string // Human readable name of the plugin
+	Version    string // Version of the plugin itself
+	ABIVersion int    // Value of PluginABIVersion the plugin was built against
+}
+
+// Plugin is implemented by dynamically loaded modules adding services, such as
+// protocol handlers and RPC APIs, to a node.
+type Plugin interface {
+	// Manifest returns the description of the plugin, which is checked before
+	// any of its services are registered.
+	Manifest() PluginManifest
+
+	// RegisterServices registers the services of the plugin on the not yet
+	// started node.
+	RegisterServices(stack *Node) error
+}
+
+// LoadPlugins opens all Go plugins (files ending in .so) in the given directory
+// in lexical order and registers their services on the node.
+func LoadPlugins(stack *Node, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := loadPlugin(stack, file); err != nil {
+			return fmt.Errorf("plugin %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+func loadPlugin(stack *Node, file string) error {
+	p, err := plugin.Open(file)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return err
+	}
+	var plug Plugin
+	switch sym := sym.(type) {
+	case *Plugin:
+		plug = *sym
+	case Plugin:
+		plug = sym
+	}
+	if plug == nil {
+		return fmt.Errorf("symbol %s of type %T doesn't implement node.Plugin", PluginSymbol, sym)
+	}
+	manifest := plug.Manifest()
+	if manifest.ABIVersion != PluginABIVersion {
+		return &PluginABIError{Have: manifest.ABIVersion, Want: PluginABIVersion}
+	}
+	if err := plug.RegisterServices(stack); err != nil {
+		return err
+	}
+	stack.log.Info("Loaded plugin", "name", manifest.Name, "version", manifest.Version, "file", file)
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"math/big"
+	"path/filepath"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ConfigLoader reads the configuration file given to admin_reloadConfig. Besides
+// the node configuration, it may return a function applying the reloadable
+// settings of other components, which is invoked after the node configuration
+// was applied successfully.
+type ConfigLoader func(file string) (cfg *Config, apply func(), err error)
+
+// setVerbosity changes the verbosity of the root logger. It is a variable so
+// tests can intercept it.
+var setVerbosity = func(lvl log.Lvl) {
+	debug.Handler.Verbosity(int(lvl))
+}
+
+// SetConfigLoader sets the function used by admin_reloadConfig to read config
+// files. Configuration reloading through RPC is disabled until it is set.
+func (n *Node) SetConfigLoader(loader ConfigLoader) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.configLoader = loader
+}
+
+// ReloadConfig applies the settings of cfg which can be changed at runtime, i.e.
+// fields tagged reload:"true". If any other field differs from the configuration
+// the node runs with, nothing is applied and a *ConfigReloadError wrapping
+// ErrNotHotReloadable is returned.
+func (n *Node) ReloadConfig(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	// Normalize the new config the same way New does.
+	conf := *cfg
+	if conf.DataDir != "" {
+		absdatadir, err := filepath.Abs(conf.DataDir)
+		if err != nil {
+			return err
+		}
+		conf.DataDir = absdatadir
+	}
+	// The rate limits are only checked when the limiters are created.
+	if _, err := makeRateLimiters(conf.MethodRateLimits); err != nil {
+		return err
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	changed, err := ConfigDiff("Config", n.config, &conf)
+	if err != nil {
+		return err
+	}
+	for _, field := range changed {
+		if configReloaders[field] == nil {
+			return &ConfigReloadError{Field: field, Err: ErrNotHotReloadable}
+		}
+	}
+	for _, field := range changed {
+		configReloaders[field](n, &conf)
+		n.log.Info("Reloaded configuration value", "field", field)
+	}
+	return nil
+}
+
+// configReloaders apply the runtime-changeable fields of the node configuration.
+// They are called with the node lock held.
+var configReloaders = map[string]func(n *Node, cfg *Config){
+	"Config.LogLevel": func(n *Node, cfg *Config) {
+		n.config.LogLevel = cfg.LogLevel
+		if lvl, err := log.LvlFromString(cfg.LogLevel); err == nil {
+			setVerbosity(lvl)
+		}
+	},
+	"Config.P2P.MaxPeers": func(n *Node, cfg *Config) {
+		n.config.P2P.MaxPeers = cfg.P2P.MaxPeers
+		n.serverConfig.MaxPeers = cfg.P2P.MaxPeers
+		if n.server != nil {
+			n.server.SetMaxPeers(cfg.P2P.MaxPeers)
+		}
+	},
+	"Config.MethodRateLimits": func(n *Node, cfg *Config) {
+		// The limits were checked by ReloadConfig already.
+		limiters, _ := makeRateLimiters(cfg.MethodRateLimits)
+		n.config.MethodRateLimits = cfg.MethodRateLimits
+
+		n.rateLimitLock.Lock()
+		n.rateLimiters = limiters
+		n.rateLimitLock.Unlock()
+	},
+}
+
+// reloadConfigFile reads the given config file using the configured loader and
+// applies its reloadable settings.
+func (n *Node) reloadConfigFile(file string) error {
+	n.lock.RLock()
+	loader := n.configLoader
+	n.lock.RUnlock()
+
+	if loader == nil {
+		return errors.New("config reloading not supported")
+	}
+	cfg, apply, err := loader(file)
+	if err != nil {
+		return err
+	}
+	if err := n.ReloadConfig(cfg); err != nil {
+		return err
+	}
+	if apply != nil {
+		apply()
+	}
+	return nil
+}
+
+// ConfigDiff compares two configuration structs of the same type, returning the
+// paths of the differing fields which are tagged reload:"true". If any other field
+// differs, a *ConfigReloadError naming it is returned. Fields that can't be set
+// in config files, i.e. interfaces, functions and fields tagged toml:"-", are
+// ignored. Structs are compared field by field, name is the root of the paths.
+func ConfigDiff(name string, old, new interface{}) ([]string, error) {
+	var changed []string
+	if err := configDiff(name, reflect.ValueOf(old), reflect.ValueOf(new), &changed); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+var bigIntType = reflect.TypeOf(new(big.Int))
+
+func configDiff(path string, old, new reflect.Value, changed *[]string) error {
+	if isStructPtr(old.Type()) {
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				return &ConfigReloadError{Field: path, Err: ErrNotHotReloadable}
+			}
+			return nil
+		}
+		old, new = old.Elem(), new.Elem()
+	}
+	typ := old.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		var (
+			fpath  = path + "." + field.Name
+			of, nf = old.Field(i), new.Field(i)
+		)
+		switch {
+		case of.Kind() == reflect.Interface || of.Kind() == reflect.Func:
+			continue
+		case (of.Kind() == reflect.Struct || isStructPtr(of.Type())) && field.Tag.Get("reload") != "true":
+			if err := configDiff(fpath, of, nf, changed); err != nil {
+				return err
+			}
+		case !configValueEqual(of, nf):
+			if field.Tag.Get("reload") != "true" {
+				return &ConfigReloadError{Field: fpath, Err: ErrNotHotReloadable}
+			}
+			*changed = append(*changed, fpath)
+		}
+	}
+	return nil
+}
+
+// isStructPtr reports whether typ points to a struct which is compared field by
+// field. Big integers are compared as values instead.
+func isStructPtr(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct && typ != bigIntType
+}
+
+// configValueEqual compares two config values, treating big integers as equal
+// if they have the same value, and nil slices and maps as equal to empty ones, as
+// config files don't tell them apart.
+func configValueEqual(a, b reflect.Value) bool {
+	if kind := a.Kind(); (kind == reflect.Slice || kind == reflect.Map) && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	if a.Type() == bigIntType {
+		x, y := a.Interface().(*big.Int), b.Interface().(*big.Int)
+		if x == nil || y == nil {
+			return x == y
+		}
+		return x.Cmp(y) == 0
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -17,6 +17,7 @@
 package node
 
 import (
+	"context"
 	"path/filepath"
 	"reflect"
 
@@ -45,7 +46,7 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int, nam
 	if ctx.config.DataDir == "" {
 		return rawdb.NewMemoryDatabase(), nil
 	}
-	return rawdb.NewLevelDBDatabase(ctx.config.ResolvePath(name), cache, handles, namespace)
+	return rawdb.NewBackendDatabase(ctx.config.DatabaseBackend, ctx.config.ResolvePath(name), cache, handles, namespace)
 }
 
 // OpenDatabaseWithFreezer opens an existing database with the given name (or
@@ -65,7 +66,7 @@ func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handl
 	case !filepath.IsAbs(freezer):
 		freezer = ctx.config.ResolvePath(freezer)
 	}
-	return rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+	return rawdb.NewBackendDatabaseWithFreezer(ctx.config.DatabaseBackend, root, cache, handles, freezer, namespace)
 }
 
 // ResolvePath resolves a user path into the data directory if that was relative
@@ -95,6 +96,69 @@ func (ctx *ServiceContext) ExtRPCEnabled() bool {
 // registered for service instantiation.
 type ServiceConstructor func(ctx *ServiceContext) (Service, error)
 
+// ServiceDescriptor describes a service to be registered into a node along with
+// the names of the services it depends on. The node constructs and starts the
+// dependencies of a service before the service itself, and stops them after it.
+//
+// Services without a name cannot be depended upon. Services unrelated through
+// dependencies are started in registration order.
+type ServiceDescriptor struct {
+	Name        string             // Unique name of the service to reference it as a dependency
+	Constructor ServiceConstructor // Constructor to instantiate the service with
+	DependsOn   []string           // Names of the services that need to start first
+}
+
+// sortServices orders the service descriptors such that every service comes
+// after all of its dependencies, using Kahn's algorithm. Among the services
+// ready to be placed, the earliest registered one is always picked first, so
+// the registration order is retained wherever dependencies allow.
+func sortServices(descs []*ServiceDescriptor) ([]*ServiceDescriptor, error) {
+	// Index the named services and count the unmet dependencies of each
+	index := make(map[string]int)
+	for i, desc := range descs {
+		if desc.Name != "" {
+			index[desc.Name] = i
+		}
+	}
+	var (
+		pending    = make([]int, len(descs))   // Number of unstarted dependencies per service
+		dependents = make([][]int, len(descs)) // Services depending on each service
+	)
+	for i, desc := range descs {
+		for _, name := range desc.DependsOn {
+			dep, ok := index[name]
+			if !ok {
+				return nil, &UnknownDependencyError{Service: desc.Name, Dependency: name}
+			}
+			pending[i]++
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+	// Repeatedly place the earliest registered service with no pending dependencies
+	var (
+		sorted = make([]*ServiceDescriptor, 0, len(descs))
+		placed = make([]bool, len(descs))
+	)
+	for len(sorted) < len(descs) {
+		next := -1
+		for i := range descs {
+			if !placed[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, ErrCyclicDependency
+		}
+		placed[next] = true
+		sorted = append(sorted, descs[next])
+		for _, dependent := range dependents[next] {
+			pending[dependent]--
+		}
+	}
+	return sorted, nil
+}
+
 // Service is an individual protocol that can be registered into a node.
 //
 // Notes:
@@ -120,3 +184,58 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// LifecycleHook is notified around the startup and shutdown of a node, and can
+// be used to implement cross-cutting concerns that don't belong to any single
+// service (e.g. pre-start migrations, readiness probes, flushing logs).
+//
+// Hooks are invoked in registration order. An error returned by BeforeStart
+// aborts the startup of the node, errors from the other methods are reported
+// but don't interrupt the startup or shutdown sequence.
+type LifecycleHook interface {
+	// BeforeStart is called before the p2p server and any services are started.
+	BeforeStart(ctx context.Context) error
+
+	// AfterStart is called once all services and RPC endpoints are running.
+	AfterStart(ctx context.Context) error
+
+	// BeforeStop is called before the RPC endpoints and services are stopped.
+	BeforeStop(ctx context.Context) error
+
+	// AfterStop is called once all services and the p2p server have stopped.
+	AfterStop(ctx context.Context) error
+}
+
+// LifecyclePhase is a point in the startup or shutdown sequence of a node.
+type LifecyclePhase int
+
+const (
+	PreStart  LifecyclePhase = iota // Before the p2p server and services start
+	PostStart                       // Once all services and RPC endpoints are running
+	PreStop                         // Before the RPC endpoints and services stop
+	PostStop                        // Once all services and the p2p server stopped
+)
+
+// NewLifecycleHook creates a hook running fn at a single phase of the lifecycle
+// of the node, to be registered through Node.RegisterLifecycleHook.
+func NewLifecycleHook(phase LifecyclePhase, fn func(ctx context.Context) error) LifecycleHook {
+	return &phaseHook{phase: phase, fn: fn}
+}
+
+// phaseHook is a LifecycleHook running a function at a single lifecycle phase.
+type phaseHook struct {
+	phase LifecyclePhase
+	fn    func(ctx context.Context) error
+}
+
+func (h *phaseHook) run(phase LifecyclePhase, ctx context.Context) error {
+	if h.phase != phase {
+		return nil
+	}
+	return h.fn(ctx)
+}
+
+func (h *phaseHook) BeforeStart(ctx context.Context) error { return h.run(PreStart, ctx) }
+func (h *phaseHook) AfterStart(ctx context.Context) error  { return h.run(PostStart, ctx) }
+func (h *phaseHook) BeforeStop(ctx context.Context) error  { return h.run(PreStop, ctx) }
+func (h *phaseHook) AfterStop(ctx context.Context) error   { return h.run(PostStop, ctx) }
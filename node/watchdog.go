@@ -0,0 +1,100 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/pprof"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/shutdowncheck"
+)
+
+// stopProgressInterval is the time between progress reports while waiting for
+// a service to stop.
+var stopProgressInterval = 8 * time.Second
+
+// stopService stops a service, waiting at most the configured stop timeout for
+// it to finish. The progress of the shutdown tasks is logged periodically while
+// waiting. If the timeout is exceeded, the goroutine stacks are dumped to a file
+// and the process is terminated.
+func (n *Node) stopService(kind reflect.Type, service Service) error {
+	if n.config.StopTimeout <= 0 {
+		return service.Stop()
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- service.Stop() }()
+
+	var (
+		start    = time.Now()
+		deadline = time.NewTimer(n.config.StopTimeout)
+		progress = time.NewTicker(stopProgressInterval)
+	)
+	defer deadline.Stop()
+	defer progress.Stop()
+
+	for {
+		select {
+		case err := <-errc:
+			return err
+
+		case <-progress.C:
+			n.log.Info("Waiting for service to stop", "service", kind, "elapsed", common.PrettyDuration(time.Since(start)))
+			for _, task := range shutdowncheck.InFlight() {
+				n.log.Info("Shutdown in progress", "task", task, "elapsed", common.PrettyDuration(time.Since(task.Started)))
+			}
+
+		case <-deadline.C:
+			n.log.Error("Service failed to stop in time", "service", kind, "timeout", n.config.StopTimeout)
+			if path, err := n.dumpStacks(); err != nil {
+				n.log.Error("Failed to dump goroutine stacks", "err", err)
+			} else {
+				n.log.Error("Dumped goroutine stacks", "path", path)
+			}
+			n.exit(1)
+
+			// Only reachable if the exit function was replaced
+			return ErrStopTimeout
+		}
+	}
+}
+
+// dumpStacks writes the stacks of all goroutines to a file in the instance
+// directory, or the temporary directory for nodes without a data directory,
+// returning the path of the file.
+func (n *Node) dumpStacks() (string, error) {
+	dir := n.config.instanceDir()
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("shutdown-stacks-%d.txt", time.Now().Unix()))
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		return "", err
+	}
+	return path, nil
+}
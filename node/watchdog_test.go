@@ -0,0 +1,161 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/shutdowncheck"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Tests that a service exceeding the stop timeout gets its stacks dumped and
+// the process terminated, while the shutdown progress is reported.
+func TestStopWatchdog(t *testing.T) {
+	defer func(interval time.Duration) { stopProgressInterval = interval }(stopProgressInterval)
+	stopProgressInterval = 10 * time.Millisecond
+
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	// Collect the log messages of the node
+	var (
+		logs   []string
+		logsMu sync.Mutex
+	)
+	logger := log.New()
+	logger.SetHandler(log.FuncHandler(func(r *log.Record) error {
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		logs = append(logs, fmt.Sprintf("%s %v", r.Msg, r.Ctx))
+		return nil
+	}))
+	config := testNodeConfig()
+	config.DataDir = datadir
+	config.StopTimeout = 200 * time.Millisecond
+	config.Logger = logger
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	exited := make(chan int, 1)
+	stack.exit = func(code int) { exited <- code }
+
+	// Register a service hanging while flushing its caches
+	release := make(chan struct{})
+	defer close(release)
+
+	constructor := func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{
+			stopHook: func() {
+				shutdowncheck.Progress("flushing trie cache", 42, 100)
+				defer shutdowncheck.Done("flushing trie cache")
+				<-release
+			},
+		}, nil
+	}
+	if err := stack.Register(constructor); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	err = stack.Stop()
+
+	// Check that the process would have been terminated with the stacks dumped
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("exit code mismatch: have %d, want 1", code)
+		}
+	default:
+		t.Fatalf("process not terminated on stop timeout")
+	}
+	stopErr, ok := err.(*StopError)
+	if !ok {
+		t.Fatalf("stop error mismatch: have %v, want *StopError", err)
+	}
+	if err := stopErr.Services[reflect.TypeOf(&InstrumentedService{})]; err != ErrStopTimeout {
+		t.Errorf("service stop error mismatch: have %v, want %v", err, ErrStopTimeout)
+	}
+	dumps, _ := filepath.Glob(filepath.Join(datadir, config.name(), "shutdown-stacks-*.txt"))
+	if len(dumps) != 1 {
+		t.Fatalf("stack dump count mismatch: have %d, want 1", len(dumps))
+	}
+	dump, err := ioutil.ReadFile(dumps[0])
+	if err != nil {
+		t.Fatalf("failed to read stack dump: %v", err)
+	}
+	if !strings.Contains(string(dump), "goroutine") {
+		t.Errorf("stack dump doesn't contain goroutine stacks")
+	}
+	// Check that the progress of the hanging service was reported
+	logsMu.Lock()
+	defer logsMu.Unlock()
+
+	var reported bool
+	for _, msg := range logs {
+		if strings.HasPrefix(msg, "Shutdown in progress") && strings.Contains(msg, "flushing trie cache: 42% complete") {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Errorf("shutdown progress not reported, logs: %v", logs)
+	}
+}
+
+// Tests that services stopping within the stop timeout don't trigger the
+// watchdog.
+func TestStopWatchdogInTime(t *testing.T) {
+	config := testNodeConfig()
+	config.StopTimeout = time.Second
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	stack.exit = func(code int) { t.Errorf("process terminated with code %d", code) }
+
+	var stopped bool
+	constructor := func(*ServiceContext) (Service, error) {
+		return &InstrumentedService{stopHook: func() { stopped = true }}, nil
+	}
+	if err := stack.Register(constructor); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if err := stack.Stop(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if !stopped {
+		t.Fatalf("service not stopped")
+	}
+}
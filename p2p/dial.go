@@ -69,6 +69,7 @@ func (t TCPDialer) Dial(dest *enode.Node) (net.Conn, error) {
 type dialstate struct {
 	maxDynDials int
 	netrestrict *netutil.Netlist
+	reputation  *reputationStore // refuses banned nodes, may be nil
 	self        enode.ID
 	bootnodes   []*enode.Node // default dials when there are no peers
 	log         log.Logger
@@ -109,6 +110,8 @@ func (s *dialstate) addStatic(n *enode.Node) {
 	// This overwrites the task instead of updating an existing
 	// entry, giving users the opportunity to force a resolve operation.
 	s.static[n.ID()] = &dialTask{flags: staticDialedConn, dest: n}
+	// Forget any recent dial, so the node is dialed on the next scheduling round.
+	s.hist.remove(string(n.ID().Bytes()))
 }
 
 func (s *dialstate) removeStatic(n *enode.Node) {
@@ -116,6 +119,10 @@ func (s *dialstate) removeStatic(n *enode.Node) {
 	delete(s.static, n.ID())
 }
 
+func (s *dialstate) setMaxDynDials(n int) {
+	s.maxDynDials = n
+}
+
 func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Time) []task {
 	var newtasks []task
 	addDial := func(flag connFlag, n *enode.Node) bool {
@@ -203,8 +210,13 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBannedNode       = errors.New("banned for misbehaviour")
 )
 
+func (s *dialstate) setNetRestrict(list *netutil.Netlist) {
+	s.netrestrict = list
+}
+
 func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 	_, dialing := s.dialing[n.ID()]
 	switch {
@@ -218,6 +230,8 @@ func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 		return errNotWhitelisted
 	case s.hist.contains(string(n.ID().Bytes())):
 		return errRecentlyDialed
+	case s.reputation.banned(n.ID()):
+		return errBannedNode
 	}
 	return nil
 }
@@ -354,6 +354,28 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that the network restriction can be changed.
+func TestDialStateSetNetRestrict(t *testing.T) {
+	var (
+		s       = newDialState(enode.ID{}, 10, &Config{})
+		inside  = newNode(uintID(1), net.ParseIP("127.0.2.1"))
+		outside = newNode(uintID(2), net.ParseIP("127.0.0.1"))
+	)
+	restrict := new(netutil.Netlist)
+	restrict.Add("127.0.2.0/24")
+	s.setNetRestrict(restrict)
+	if err := s.checkDial(outside, nil); err != errNotWhitelisted {
+		t.Errorf("wrong error for node outside of restriction: %v", err)
+	}
+	if err := s.checkDial(inside, nil); err != nil {
+		t.Errorf("wrong error for node inside of restriction: %v", err)
+	}
+	s.setNetRestrict(nil)
+	if err := s.checkDial(outside, nil); err != nil {
+		t.Errorf("wrong error after lifting restriction: %v", err)
+	}
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	config := &Config{
@@ -20,6 +20,7 @@ import (
 	"crypto/ecdsa"
 	"net"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -43,6 +44,8 @@ type Config struct {
 	Bootnodes   []*enode.Node     // list of bootstrap nodes
 	Unhandled   chan<- ReadPacket // unhandled packets are sent on this channel
 	Log         log.Logger        // if set, log messages go here
+	NeedNodes   func() bool       // if set, the table refresh rate adapts to the demand for peers
+	Clock       mclock.Clock      // if set, used to schedule table refreshes
 }
 
 // ListenUDP starts listening for discovery packets on the given UDP socket.
@@ -33,6 +33,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -53,7 +54,8 @@ const (
 	bucketIPLimit, bucketSubnet = 2, 24 // at most 2 addresses from the same /24
 	tableIPLimit, tableSubnet   = 10, 24
 
-	refreshInterval    = 30 * time.Minute
+	refreshInterval    = 30 * time.Minute // Refresh interval, upper bound when adapting to demand
+	refreshMinInterval = 1 * time.Minute  // Lower bound of the refresh interval when adapting to demand
 	revalidateInterval = 10 * time.Second
 	copyNodesInterval  = 30 * time.Second
 	seedMinTableTime   = 5 * time.Minute
@@ -71,6 +73,10 @@ type Table struct {
 	rand    *mrand.Rand       // source of randomness, periodically reseeded
 	ips     netutil.DistinctNetSet
 
+	clock       mclock.Clock
+	needNodes   func() bool   // reports whether the node looks for peers, nil if unknown
+	refreshIval time.Duration // current refresh interval, protected by mutex
+
 	log        log.Logger
 	db         *enode.DB // database of known nodes
 	net        transport
@@ -94,22 +100,25 @@ type transport interface {
 // bucket contains nodes, ordered by their last activity. the entry
 // that was most recently active is the first element in entries.
 type bucket struct {
-	entries      []*node // live entries, sorted by time of last contact
-	replacements []*node // recently seen nodes to be used if revalidation fails
-	ips          netutil.DistinctNetSet
+	entries       []*node // live entries, sorted by time of last contact
+	replacements  []*node // recently seen nodes to be used if revalidation fails
+	ips           netutil.DistinctNetSet
+	lastValidated time.Time // time of the last revalidation attempt
 }
 
 func newTable(t transport, db *enode.DB, bootnodes []*enode.Node, log log.Logger) (*Table, error) {
 	tab := &Table{
-		net:        t,
-		db:         db,
-		refreshReq: make(chan chan struct{}),
-		initDone:   make(chan struct{}),
-		closeReq:   make(chan struct{}),
-		closed:     make(chan struct{}),
-		rand:       mrand.New(mrand.NewSource(0)),
-		ips:        netutil.DistinctNetSet{Subnet: tableSubnet, Limit: tableIPLimit},
-		log:        log,
+		net:         t,
+		db:          db,
+		refreshReq:  make(chan chan struct{}),
+		initDone:    make(chan struct{}),
+		closeReq:    make(chan struct{}),
+		closed:      make(chan struct{}),
+		rand:        mrand.New(mrand.NewSource(0)),
+		ips:         netutil.DistinctNetSet{Subnet: tableSubnet, Limit: tableIPLimit},
+		clock:       mclock.System{},
+		refreshIval: refreshInterval,
+		log:         log,
 	}
 	if err := tab.setFallbackNodes(bootnodes); err != nil {
 		return nil, err
@@ -218,13 +227,12 @@ func (tab *Table) refresh() <-chan struct{} {
 func (tab *Table) loop() {
 	var (
 		revalidate     = time.NewTimer(tab.nextRevalidateTime())
-		refresh        = time.NewTicker(refreshInterval)
+		refresh        = tab.clock.After(tab.nextRefreshInterval())
 		copyNodes      = time.NewTicker(copyNodesInterval)
 		refreshDone    = make(chan struct{})           // where doRefresh reports completion
 		revalidateDone chan struct{}                   // where doRevalidate reports completion
 		waiting        = []chan struct{}{tab.initDone} // holds waiting callers while doRefresh runs
 	)
-	defer refresh.Stop()
 	defer revalidate.Stop()
 	defer copyNodes.Stop()
 
@@ -234,12 +242,13 @@ func (tab *Table) loop() {
 loop:
 	for {
 		select {
-		case <-refresh.C:
+		case <-refresh:
 			tab.seedRand()
 			if refreshDone == nil {
 				refreshDone = make(chan struct{})
 				go tab.doRefresh(refreshDone)
 			}
+			refresh = tab.clock.After(tab.nextRefreshInterval())
 		case req := <-tab.refreshReq:
 			waiting = append(waiting, req)
 			if refreshDone == nil {
@@ -300,6 +309,26 @@ func (tab *Table) doRefresh(done chan struct{}) {
 	}
 }
 
+// nextRefreshInterval adapts the refresh interval to the demand for new nodes.
+// While the node looks for peers, the table is refreshed as often as allowed by
+// refreshMinInterval. Otherwise the interval doubles up to refreshInterval.
+func (tab *Table) nextRefreshInterval() time.Duration {
+	if tab.needNodes == nil {
+		return refreshInterval
+	}
+	need := tab.needNodes()
+
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	if need {
+		tab.refreshIval = refreshMinInterval
+	} else if tab.refreshIval *= 2; tab.refreshIval > refreshInterval {
+		tab.refreshIval = refreshInterval
+	}
+	return tab.refreshIval
+}
+
 func (tab *Table) loadSeedNodes() {
 	seeds := wrapNodes(tab.db.QuerySeeds(seedCount, seedMaxAge))
 	seeds = append(seeds, tab.nursery...)
@@ -338,6 +367,7 @@ func (tab *Table) doRevalidate(done chan<- struct{}) {
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
 	b := tab.buckets[bi]
+	b.lastValidated = time.Now()
 	if err == nil {
 		// The node responded, move it to the front.
 		last.livenessChecks++
@@ -421,6 +451,66 @@ func (tab *Table) len() (n int) {
 	return n
 }
 
+// TableInfo is a snapshot of the node table, used to diagnose connectivity issues.
+type TableInfo struct {
+	Entries         int          `json:"entries"`         // Number of nodes in the table
+	Live            int          `json:"live"`            // Nodes which passed at least one liveness check
+	Stale           int          `json:"stale"`           // Nodes which were not validated yet
+	NeedNodes       bool         `json:"needNodes"`       // Whether the node is looking for peers
+	RefreshInterval string       `json:"refreshInterval"` // Current interval between table refreshes
+	Buckets         []BucketInfo `json:"buckets"`
+}
+
+// BucketInfo describes the content of a single table bucket.
+type BucketInfo struct {
+	Distance      int        `json:"distance"`      // Log distance from the local node, the first bucket also holds closer nodes
+	Entries       int        `json:"entries"`       // Number of nodes in the bucket
+	Replacements  int        `json:"replacements"`  // Number of replacement candidates
+	Live          int        `json:"live"`          // Nodes which passed at least one liveness check
+	Stale         int        `json:"stale"`         // Nodes which were not validated yet
+	LastValidated *time.Time `json:"lastValidated"` // Time of the last revalidation, nil if never
+}
+
+// info returns a snapshot of the table content.
+func (tab *Table) info() *TableInfo {
+	need := tab.needNodes != nil && tab.needNodes()
+
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	info := &TableInfo{
+		NeedNodes:       need,
+		RefreshInterval: tab.refreshIval.String(),
+		Buckets:         make([]BucketInfo, len(tab.buckets)),
+	}
+	for i, b := range &tab.buckets {
+		bi := BucketInfo{
+			Distance:     bucketMinDistance + i + 1,
+			Entries:      len(b.entries),
+			Replacements: len(b.replacements),
+		}
+		if i == 0 {
+			bi.Distance = bucketMinDistance
+		}
+		for _, n := range b.entries {
+			if n.livenessChecks > 0 {
+				bi.Live++
+			} else {
+				bi.Stale++
+			}
+		}
+		if !b.lastValidated.IsZero() {
+			t := b.lastValidated
+			bi.LastValidated = &t
+		}
+		info.Buckets[i] = bi
+		info.Entries += bi.Entries
+		info.Live += bi.Live
+		info.Stale += bi.Stale
+	}
+	return info
+}
+
 // bucket returns the bucket for the given node ID hash.
 func (tab *Table) bucket(id enode.ID) *bucket {
 	d := enode.LogDist(tab.self().ID(), id)
@@ -515,6 +605,30 @@ func (tab *Table) delete(node *node) {
 	tab.deleteInBucket(tab.bucket(node.ID()), node)
 }
 
+// filter removes all nodes for which keep returns false from the buckets and
+// replacement lists.
+func (tab *Table) filter(keep func(*node) bool) {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	for _, b := range &tab.buckets {
+		for _, n := range append([]*node(nil), b.entries...) {
+			if !keep(n) {
+				tab.deleteInBucket(b, n)
+			}
+		}
+		replacements := b.replacements[:0]
+		for _, n := range b.replacements {
+			if keep(n) {
+				replacements = append(replacements, n)
+			} else {
+				tab.removeIP(b, n.IP())
+			}
+		}
+		b.replacements = replacements
+	}
+}
+
 func (tab *Table) addIP(b *bucket, ip net.IP) bool {
 	if netutil.IsLAN(ip) {
 		return true
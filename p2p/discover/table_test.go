@@ -23,11 +23,14 @@ import (
 
 	"net"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"testing/quick"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -174,6 +177,34 @@ func TestTable_BucketIPLimit(t *testing.T) {
 	checkIPLimitInvariant(t, tab)
 }
 
+// This checks that filtering removes nodes and their IPs from the table.
+func TestTable_filter(t *testing.T) {
+	transport := newPingRecorder()
+	tab, db := newTestTable(transport)
+	defer db.Close()
+	defer tab.close()
+
+	for i := 0; i < 10; i++ {
+		tab.addSeenNode(nodeAtDistance(tab.self().ID(), 250+i%5, net.IP{172, 0, byte(i), 1}))
+		tab.addSeenNode(nodeAtDistance(tab.self().ID(), 250+i%5, net.IP{11, 0, byte(i), 1}))
+	}
+	keep := new(netutil.Netlist)
+	keep.Add("11.0.0.0/8")
+	tab.filter(func(n *node) bool { return keep.Contains(n.IP()) })
+
+	if tab.len() != 10 {
+		t.Errorf("wrong number of nodes after filtering: %d", tab.len())
+	}
+	for _, b := range &tab.buckets {
+		for _, n := range b.entries {
+			if !keep.Contains(n.IP()) {
+				t.Errorf("node %v not removed", n.IP())
+			}
+		}
+	}
+	checkIPLimitInvariant(t, tab)
+}
+
 // checkIPLimitInvariant checks that ip limit sets contain an entry for every
 // node in the table and no extra entries.
 func checkIPLimitInvariant(t *testing.T, tab *Table) {
@@ -396,6 +427,97 @@ func TestTable_revalidateSyncRecord(t *testing.T) {
 	}
 }
 
+// This test checks that the refresh interval adapts to the demand for nodes,
+// staying within its bounds.
+func TestTable_adaptiveRefresh(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		need  = int32(1)
+	)
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	tab, _ := newTable(newPingRecorder(), db, nil, log.Root())
+	tab.clock = clock
+	tab.needNodes = func() bool { return atomic.LoadInt32(&need) == 1 }
+	tab.refreshIval = refreshMinInterval
+	go tab.loop()
+	defer tab.close()
+	<-tab.initDone
+
+	interval := func() time.Duration {
+		tab.mutex.Lock()
+		defer tab.mutex.Unlock()
+		return tab.refreshIval
+	}
+	// runRefresh advances the clock to the next refresh and checks the
+	// interval scheduled afterwards.
+	runRefresh := func(wait, want time.Duration) {
+		t.Helper()
+		clock.WaitForTimers(1)
+		clock.Run(wait - time.Second)
+		if clock.ActiveTimers() != 1 {
+			t.Fatalf("refresh fired before %v", wait)
+		}
+		clock.Run(time.Second)
+		clock.WaitForTimers(1)
+		if ival := interval(); ival != want {
+			t.Fatalf("wrong refresh interval after %v: got %v, want %v", wait, ival, want)
+		}
+	}
+	// While nodes are needed, the table is refreshed at the fastest rate.
+	runRefresh(refreshMinInterval, refreshMinInterval)
+	runRefresh(refreshMinInterval, refreshMinInterval)
+
+	// Without demand, the interval backs off up to its maximum.
+	atomic.StoreInt32(&need, 0)
+	runRefresh(refreshMinInterval, 2*refreshMinInterval)
+	runRefresh(2*refreshMinInterval, 4*refreshMinInterval)
+	runRefresh(4*refreshMinInterval, 8*refreshMinInterval)
+	runRefresh(8*refreshMinInterval, 16*refreshMinInterval)
+	runRefresh(16*refreshMinInterval, refreshInterval)
+	runRefresh(refreshInterval, refreshInterval)
+
+	// Demand resets the interval to the minimum.
+	atomic.StoreInt32(&need, 1)
+	runRefresh(refreshInterval, refreshMinInterval)
+}
+
+func TestTable_info(t *testing.T) {
+	transport := newPingRecorder()
+	tab, db := newTestTable(transport)
+	<-tab.initDone
+	defer db.Close()
+	defer tab.close()
+
+	// Insert a live and a stale node into the same bucket.
+	live := nodeAtDistance(tab.self().ID(), 256, net.IP{127, 0, 0, 1})
+	stale := nodeAtDistance(tab.self().ID(), 256, net.IP{127, 0, 0, 2})
+	tab.addSeenNode(live)
+	tab.addSeenNode(stale)
+	tab.mutex.Lock()
+	live.livenessChecks = 1
+	tab.mutex.Unlock()
+
+	info := tab.info()
+	if info.Entries != 2 || info.Live != 1 || info.Stale != 1 {
+		t.Fatalf("wrong table counts: entries %d, live %d, stale %d", info.Entries, info.Live, info.Stale)
+	}
+	if len(info.Buckets) != nBuckets {
+		t.Fatalf("wrong number of buckets: %d", len(info.Buckets))
+	}
+	b := info.Buckets[nBuckets-1]
+	if b.Distance != 256 || b.Entries != 2 || b.Live != 1 || b.Stale != 1 {
+		t.Fatalf("wrong bucket info: %+v", b)
+	}
+	if b.LastValidated != nil {
+		t.Fatalf("bucket reported as validated before revalidation")
+	}
+	tab.doRevalidate(make(chan struct{}, 1))
+	if info := tab.info(); info.Buckets[nBuckets-1].LastValidated == nil {
+		t.Fatalf("bucket validation time not reported")
+	}
+}
+
 // gen wraps quick.Value so it's easier to use.
 // it generates a random value of the given value's type.
 func gen(typ interface{}, rand *rand.Rand) interface{} {
@@ -173,7 +173,7 @@ func (t *UDPv4) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*node, error) {
 	if err := netutil.CheckRelayIP(sender.IP, rn.IP); err != nil {
 		return nil, err
 	}
-	if t.netrestrict != nil && !t.netrestrict.Contains(rn.IP) {
+	if restrict := t.netRestrict(); restrict != nil && !restrict.Contains(rn.IP) {
 		return nil, errors.New("not contained in netrestrict whitelist")
 	}
 	key, err := decodePubkey(rn.ID)
@@ -198,6 +198,7 @@ func nodeToRPC(n *node) rpcNode {
 type UDPv4 struct {
 	conn        UDPConn
 	log         log.Logger
+	restrictMu  sync.Mutex
 	netrestrict *netutil.Netlist
 	priv        *ecdsa.PrivateKey
 	localNode   *enode.LocalNode
@@ -279,6 +280,13 @@ func ListenV4(c UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv4, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Clock != nil {
+		tab.clock = cfg.Clock
+	}
+	if cfg.NeedNodes != nil {
+		tab.needNodes = cfg.NeedNodes
+		tab.refreshIval = refreshMinInterval
+	}
 	t.tab = tab
 	go tab.loop()
 
@@ -293,6 +301,29 @@ func (t *UDPv4) Self() *enode.Node {
 	return t.localNode.Node()
 }
 
+// SetNetRestrict changes the IP networks nodes are accepted from. Nodes outside
+// of them are removed from the table. A nil list lifts the restriction.
+func (t *UDPv4) SetNetRestrict(list *netutil.Netlist) {
+	t.restrictMu.Lock()
+	t.netrestrict = list
+	t.restrictMu.Unlock()
+
+	if list != nil {
+		t.tab.filter(func(n *node) bool { return list.Contains(n.IP()) })
+	}
+}
+
+func (t *UDPv4) netRestrict() *netutil.Netlist {
+	t.restrictMu.Lock()
+	defer t.restrictMu.Unlock()
+	return t.netrestrict
+}
+
+// TableInfo returns a snapshot of the node table.
+func (t *UDPv4) TableInfo() *TableInfo {
+	return t.tab.info()
+}
+
 // Close shuts down the socket and aborts any running queries.
 func (t *UDPv4) Close() {
 	t.closeOnce.Do(func() {
@@ -495,6 +526,8 @@ func (t *UDPv4) RequestENR(n *enode.Node) (*enode.Node, error) {
 	if err := netutil.CheckRelayIP(addr.IP, respN.IP()); err != nil {
 		return nil, fmt.Errorf("invalid IP in response record: %v", err)
 	}
+	// Remember the most recent record of the node for later use.
+	t.db.UpdateNode(respN)
 	return respN, nil
 }
 
@@ -503,6 +503,48 @@ func TestUDPv4_EIP868(t *testing.T) {
 	})
 }
 
+// This test checks that records retrieved via EIP-868 requests are stored in
+// the node database.
+func TestUDPv4_requestENRStoresRecord(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.close()
+
+	// Pretend the remote node is bonded, so no endpoint proof is performed.
+	remote := enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 0, test.remoteaddr.Port)
+	test.db.UpdateLastPingReceived(remote.ID(), test.remoteaddr.IP, time.Now())
+
+	var record enr.Record
+	record.Set(enr.IP(test.remoteaddr.IP))
+	record.Set(enr.UDP(test.remoteaddr.Port))
+	record.Set(enr.WithEntry("foo", "bar"))
+	record.SetSeq(5)
+	if err := enode.SignV4(&record, test.remotekey); err != nil {
+		t.Fatalf("can't sign record: %v", err)
+	}
+	done := make(chan *enode.Node, 1)
+	go func() {
+		n, err := test.udp.RequestENR(remote)
+		if err != nil {
+			t.Errorf("ENR request failed: %v", err)
+		}
+		done <- n
+	}()
+	test.waitPacketOut(func(p *enrRequestV4, addr *net.UDPAddr, hash []byte) {
+		test.packetIn(nil, &enrResponseV4{ReplyTok: hash, Record: record})
+	})
+	if n := <-done; n == nil || n.Seq() != 5 {
+		t.Fatalf("wrong node returned: %v", n)
+	}
+	stored := test.db.Node(remote.ID())
+	if stored == nil {
+		t.Fatal("record not stored in node database")
+	}
+	var foo string
+	if err := stored.Load(enr.WithEntry("foo", &foo)); err != nil || foo != "bar" {
+		t.Errorf("stored record mismatch: seq %d, foo %q (%v)", stored.Seq(), foo, err)
+	}
+}
+
 // EIP-8 test vectors.
 var testPackets = []struct {
 	input      string
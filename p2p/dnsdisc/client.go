@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/mclock"
@@ -41,6 +43,27 @@ type Client struct {
 	trees     map[string]*clientTree
 
 	entries *lru.Cache
+
+	statsMu sync.Mutex
+	stats   map[string]*treeStats
+}
+
+// randomNodeRetryDelay is the time RandomNode waits after a failed sync step
+// before trying again, so that unreachable resolvers are not hammered.
+var randomNodeRetryDelay = 500 * time.Millisecond
+
+// TreeStats contains the sync status of a single tree.
+type TreeStats struct {
+	URL       string `json:"url"`                 // enrtree:// URL of the tree
+	Seq       uint   `json:"seq"`                 // sequence number of the current root
+	Nodes     int    `json:"nodes"`               // number of distinct nodes discovered
+	Errors    int    `json:"errors"`              // number of failed sync attempts
+	LastError string `json:"lastError,omitempty"` // most recent sync failure
+}
+
+type treeStats struct {
+	TreeStats
+	seen map[enode.ID]struct{}
 }
 
 // Config holds configuration options for the client.
@@ -91,6 +114,7 @@ func NewClient(cfg Config, urls ...string) (*Client, error) {
 		cfg:   cfg.withDefaults(),
 		clock: mclock.System{},
 		trees: make(map[string]*clientTree),
+		stats: make(map[string]*treeStats),
 	}
 	var err error
 	if c.entries, err = lru.New(c.cfg.CacheLimit); err != nil {
@@ -146,7 +170,9 @@ func (c *Client) ensureTree(le *linkEntry) (*clientTree, error) {
 	return ct, nil
 }
 
-// RandomNode retrieves the next random node.
+// RandomNode retrieves the next random node. Sync failures of individual trees
+// are logged and retried, the call only returns nil if the context is canceled
+// or no trees are known.
 func (c *Client) RandomNode(ctx context.Context) *enode.Node {
 	for {
 		ct := c.randomTree()
@@ -159,19 +185,86 @@ func (c *Client) RandomNode(ctx context.Context) *enode.Node {
 				return nil // context canceled.
 			}
 			c.cfg.Logger.Debug("Error in DNS random node sync", "tree", ct.loc.domain, "err", err)
+			c.recordError(ct, err)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(randomNodeRetryDelay):
+			}
 			continue
 		}
+		c.recordSync(ct, n)
 		if n != nil {
 			return n
 		}
 	}
 }
 
+// NewIterator creates an iterator which returns random nodes from all added
+// trees. The iterator can be used as a dial candidate source.
+func (c *Client) NewIterator() enode.Iterator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &randomIterator{c: c, ctx: ctx, cancel: cancel}
+}
+
+// Stats returns the sync status of all trees known to the client.
+func (c *Client) Stats() []TreeStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	stats := make([]TreeStats, 0, len(c.stats))
+	for _, st := range c.stats {
+		stats = append(stats, st.TreeStats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].URL < stats[j].URL })
+	return stats
+}
+
+// treeStats returns the stats entry of the given tree, creating it if needed.
+// The caller must hold statsMu.
+func (c *Client) treeStats(ct *clientTree) *treeStats {
+	st := c.stats[ct.loc.domain]
+	if st == nil {
+		st = &treeStats{TreeStats: TreeStats{URL: ct.loc.String()}, seen: make(map[enode.ID]struct{})}
+		c.stats[ct.loc.domain] = st
+	}
+	return st
+}
+
+// recordSync updates the stats of a tree after a successful sync step.
+func (c *Client) recordSync(ct *clientTree, n *enode.Node) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	st := c.treeStats(ct)
+	if ct.root != nil {
+		st.Seq = ct.root.seq
+	}
+	if n != nil {
+		st.seen[n.ID()] = struct{}{}
+		st.Nodes = len(st.seen)
+	}
+}
+
+// recordError updates the stats of a tree after a failed sync step.
+func (c *Client) recordError(ct *clientTree, err error) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	st := c.treeStats(ct)
+	st.Errors++
+	st.LastError = err.Error()
+}
+
 // randomTree returns a random tree.
 func (c *Client) randomTree() *clientTree {
 	if !c.linkCache.valid() {
 		c.gcTrees()
 	}
+	if len(c.trees) == 0 {
+		return nil
+	}
 	limit := rand.Intn(len(c.trees))
 	for _, ct := range c.trees {
 		if limit == 0 {
@@ -189,6 +282,43 @@ func (c *Client) gcTrees() {
 		trees[t.loc.domain] = t
 	}
 	c.trees = trees
+
+	c.statsMu.Lock()
+	for domain := range c.stats {
+		if _, ok := trees[domain]; !ok {
+			delete(c.stats, domain)
+		}
+	}
+	c.statsMu.Unlock()
+}
+
+// randomIterator yields random nodes from the trees of a client.
+type randomIterator struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	node   *enode.Node
+}
+
+// Next blocks until the next node is available. It returns false when the
+// iterator is closed or the client has no trees.
+func (it *randomIterator) Next() bool {
+	if it.ctx.Err() != nil {
+		it.node = nil
+		return false
+	}
+	it.node = it.c.RandomNode(it.ctx)
+	return it.node != nil
+}
+
+// Node returns the current node.
+func (it *randomIterator) Node() *enode.Node {
+	return it.node
+}
+
+// Close ends the iterator, interrupting any pending Next call.
+func (it *randomIterator) Close() {
+	it.cancel()
 }
 
 // resolveRoot retrieves a root entry via DNS.
@@ -211,6 +211,119 @@ func TestClientRandomNodeLinkUpdates(t *testing.T) {
 	}
 }
 
+// This test verifies that RandomNode ignores root updates which decrease the
+// sequence number of the tree.
+func TestClientRandomNodeStaleRoot(t *testing.T) {
+	var (
+		clock    = new(mclock.Simulated)
+		nodes    = testNodes(nodesSeed1, 20)
+		resolver = newMapResolver()
+		cfg      = Config{
+			Resolver:        resolver,
+			Logger:          testlog.Logger(t, log.LvlTrace),
+			RecheckInterval: 20 * time.Minute,
+		}
+		c, _ = NewClient(cfg)
+	)
+	c.clock = clock
+	defer func(delay time.Duration) { randomNodeRetryDelay = delay }(randomNodeRetryDelay)
+	randomNodeRetryDelay = 0
+
+	tree1, url := makeTestTreeSeq("n", 5, nodes[:10], nil)
+	resolver.add(tree1.ToTXT("n"))
+	c.AddTree(url)
+	checkRandomNode(t, c, nodes[:10])
+
+	// Publish a tree with a lower sequence number, it should be rejected.
+	tree2, _ := makeTestTreeSeq("n", 4, nodes[10:], nil)
+	resolver.clear()
+	resolver.add(tree1.ToTXT("n"))
+	resolver.add(tree2.ToTXT("n"))
+	clock.Run(cfg.RecheckInterval + 1*time.Second)
+
+	stale := make(map[enode.ID]bool)
+	for _, n := range tree2.Nodes() {
+		stale[n.ID()] = true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		n := c.RandomNode(ctx)
+		if n == nil {
+			t.Fatal("RandomNode returned nil")
+		}
+		if stale[n.ID()] {
+			t.Fatal("RandomNode returned node from stale tree")
+		}
+	}
+	stats := c.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("wrong number of tree stats: %d", len(stats))
+	}
+	if stats[0].Seq != 5 {
+		t.Errorf("wrong tree seq: got %d, want 5", stats[0].Seq)
+	}
+	if stats[0].Errors == 0 || stats[0].LastError != errStaleRoot.Error() {
+		t.Errorf("stale root not reported: %+v", stats[0])
+	}
+}
+
+// This test verifies that trees with an invalid root signature don't prevent
+// RandomNode from returning nodes of other trees.
+func TestClientRandomNodeBadSignature(t *testing.T) {
+	defer func(delay time.Duration) { randomNodeRetryDelay = delay }(randomNodeRetryDelay)
+	randomNodeRetryDelay = 0
+
+	nodes := testNodes(nodesSeed1, 10)
+	good, goodURL := makeTestTree("good", nodes, nil)
+	bad, _ := makeTestTree("bad", testNodes(nodesSeed2, 5), nil)
+	badURL := (&linkEntry{domain: "bad", pubkey: &testKey(nodesSeed2).PublicKey}).String()
+
+	c, _ := NewClient(Config{
+		Resolver: newMapResolver(good.ToTXT("good"), bad.ToTXT("bad")),
+		Logger:   testlog.Logger(t, log.LvlTrace),
+	}, goodURL, badURL)
+	checkRandomNode(t, c, nodes)
+
+	for _, st := range c.Stats() {
+		switch st.URL {
+		case goodURL:
+			if st.Nodes != len(nodes) || st.Errors != 0 {
+				t.Errorf("wrong stats for good tree: %+v", st)
+			}
+		case badURL:
+			if st.Nodes != 0 {
+				t.Errorf("bad tree yielded %d nodes", st.Nodes)
+			}
+		}
+	}
+}
+
+func TestClientIterator(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 15)
+	tree, url := makeTestTree("n", nodes, nil)
+	c, _ := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n")), Logger: testlog.Logger(t, log.LvlTrace)}, url)
+
+	it := c.NewIterator()
+	want := make(map[enode.ID]bool)
+	for _, n := range nodes {
+		want[n.ID()] = true
+	}
+	for i := 0; len(want) > 0 && i < 2*len(nodes); i++ {
+		if !it.Next() {
+			t.Fatal("iterator ended early")
+		}
+		delete(want, it.Node().ID())
+	}
+	if len(want) > 0 {
+		t.Errorf("iterator didn't return %d nodes", len(want))
+	}
+	it.Close()
+	if it.Next() {
+		t.Fatal("Next returned true after Close")
+	}
+}
+
 func checkRandomNode(t *testing.T, c *Client, wantNodes []*enode.Node) {
 	t.Helper()
 
@@ -237,7 +350,11 @@ func checkRandomNode(t *testing.T, c *Client, wantNodes []*enode.Node) {
 }
 
 func makeTestTree(domain string, nodes []*enode.Node, links []string) (*Tree, string) {
-	tree, err := MakeTree(1, nodes, links)
+	return makeTestTreeSeq(domain, 1, nodes, links)
+}
+
+func makeTestTreeSeq(domain string, seq uint, nodes []*enode.Node, links []string) (*Tree, string) {
+	tree, err := MakeTree(seq, nodes, links)
 	if err != nil {
 		panic(err)
 	}
@@ -253,9 +370,18 @@ func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
 	rand := rand.New(rand.NewSource(seed))
 	keys := make([]*ecdsa.PrivateKey, n)
 	for i := 0; i < n; i++ {
-		key, err := ecdsa.GenerateKey(crypto.S256(), rand)
-		if err != nil {
-			panic("can't generate key: " + err.Error())
+		// ecdsa.GenerateKey doesn't guarantee deterministic output for a
+		// given random source, derive the keys from raw bytes instead.
+		var (
+			key *ecdsa.PrivateKey
+			err error
+		)
+		for key == nil {
+			b := make([]byte, 32)
+			rand.Read(b)
+			if key, err = crypto.ToECDSA(b); err != nil {
+				key = nil
+			}
 		}
 		keys[i] = key
 	}
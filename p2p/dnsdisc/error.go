@@ -39,6 +39,7 @@ var (
 	errHashMismatch  = errors.New("hash mismatch")
 	errENRInLinkTree = errors.New("enr entry in link tree")
 	errLinkInENRTree = errors.New("link entry in ENR tree")
+	errStaleRoot     = errors.New("stale root (sequence number decreased)")
 )
 
 type nameError struct {
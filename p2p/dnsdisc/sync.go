@@ -145,6 +145,11 @@ func (ct *clientTree) updateRoot() error {
 	if err != nil {
 		return err
 	}
+	// Reject roots that roll back the tree. A lower sequence number is most
+	// likely a stale DNS cache somewhere along the way.
+	if ct.root != nil && root.seq < ct.root.seq {
+		return errStaleRoot
+	}
 	ct.root = &root
 
 	// Invalidate subtrees if changed.
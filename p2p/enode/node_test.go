@@ -64,6 +64,27 @@ func TestPythonInterop(t *testing.T) {
 	}
 }
 
+// TestEIP778TextVector checks that the textual form of the EIP-778 example record
+// decodes to the same record as its binary form, and encodes back identically.
+func TestEIP778TextVector(t *testing.T) {
+	const text = "enr:-IS4QHCYrYZbAKWCBRlAy5zzaDZXJBGkcnh4MHcBFZntXNFrdvJjX04jRzjzCBOonrkTfj499SZuOh8R33Ls8RRcy5wBgmlkgnY0gmlwhH8AAAGJc2VjcDI1NmsxoQPKY0yuDUmstAHYpMa2_oxVtw0RW_QAdpzBQA8yWM0xOIN1ZHCCdl8"
+
+	n, err := Parse(ValidSchemes, text)
+	if err != nil {
+		t.Fatalf("can't parse record: %v", err)
+	}
+	blob, err := rlp.EncodeToBytes(n.Record())
+	if err != nil {
+		t.Fatalf("can't encode record: %v", err)
+	}
+	if !bytes.Equal(blob, pyRecord) {
+		t.Errorf("wrong record encoding:\ngot  %x\nwant %x", blob, pyRecord)
+	}
+	if n.String() != text {
+		t.Errorf("wrong text encoding:\ngot  %s\nwant %s", n.String(), text)
+	}
+}
+
 func TestHexID(t *testing.T) {
 	ref := ID{0, 0, 0, 0, 0, 0, 0, 128, 106, 217, 182, 31, 165, 174, 1, 67, 7, 235, 220, 150, 66, 83, 173, 205, 159, 44, 10, 57, 42, 161, 26, 188}
 	id1 := HexID("0x00000000000000806ad9b61fa5ae014307ebdc964253adcd9f2c0a392aa11abc")
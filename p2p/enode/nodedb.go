@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"sync"
@@ -42,6 +43,14 @@ const (
 	dbLocalPrefix  = "local:"
 	dbDiscoverRoot = "v4"
 
+	// Persistent peer set members are keyed by ID, the full key is "static:<ID>".
+	dbStaticPrefix  = "static:"
+	dbTrustedPrefix = "trusted:"
+
+	// Reputation penalties are keyed by ID, the full key is "reputation:<ID>". They
+	// are kept separately from the node entries so they survive node expiration.
+	dbReputationPrefix = "reputation:"
+
 	// These fields are stored per ID and IP, the full key is "n:<ID>:v4:<IP>:findfail".
 	// Use nodeItemKey to create those keys.
 	dbNodeFindFails = "findfail"
@@ -49,6 +58,9 @@ const (
 	dbNodePong      = "lastpong"
 	dbNodeSeq       = "seq"
 
+	// The connection count is stored per ID only, it uses the unspecified IP.
+	dbNodeConnects = "connects"
+
 	// Local information is keyed by ID only, the full key is "local:<ID>:seq".
 	// Use localItemKey to create those keys.
 	dbLocalSeq = "seq"
@@ -378,6 +390,16 @@ func (db *DB) UpdateFindFails(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(nodeItemKey(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// Connects retrieves the number of times a connection to the node was established.
+func (db *DB) Connects(id ID) uint64 {
+	return db.fetchUint64(nodeItemKey(id, net.IPv6zero, dbNodeConnects))
+}
+
+// UpdateConnects stores the number of times a connection to the node was established.
+func (db *DB) UpdateConnects(id ID, connects uint64) error {
+	return db.storeUint64(nodeItemKey(id, net.IPv6zero, dbNodeConnects), connects)
+}
+
 // LocalSeq retrieves the local record sequence counter.
 func (db *DB) localSeq(id ID) uint64 {
 	return db.fetchUint64(localItemKey(id, dbLocalSeq))
@@ -440,6 +462,101 @@ func nextNode(it iterator.Iterator) *Node {
 	return nil
 }
 
+// StaticNodes returns all nodes of the persistent static peer set.
+func (db *DB) StaticNodes() []*Node {
+	return db.peerSet(dbStaticPrefix)
+}
+
+// AddStaticNode inserts - potentially overwriting - a node into the persistent
+// static peer set.
+func (db *DB) AddStaticNode(node *Node) error {
+	return db.addPeerSetNode(dbStaticPrefix, node)
+}
+
+// RemoveStaticNode deletes a node from the persistent static peer set.
+func (db *DB) RemoveStaticNode(id ID) error {
+	return db.lvl.Delete(append([]byte(dbStaticPrefix), id[:]...), nil)
+}
+
+// TrustedNodes returns all nodes of the persistent trusted peer set.
+func (db *DB) TrustedNodes() []*Node {
+	return db.peerSet(dbTrustedPrefix)
+}
+
+// AddTrustedNode inserts - potentially overwriting - a node into the persistent
+// trusted peer set.
+func (db *DB) AddTrustedNode(node *Node) error {
+	return db.addPeerSetNode(dbTrustedPrefix, node)
+}
+
+// RemoveTrustedNode deletes a node from the persistent trusted peer set.
+func (db *DB) RemoveTrustedNode(id ID) error {
+	return db.lvl.Delete(append([]byte(dbTrustedPrefix), id[:]...), nil)
+}
+
+// reputationEntry is the stored penalty score of a node.
+type reputationEntry struct {
+	Penalty uint64 // IEEE 754 bits of the score
+	Updated uint64 // Unix time in nanoseconds
+}
+
+// Reputation retrieves the penalty score of a node along with the time it was
+// last updated. A zero time is returned for unknown nodes.
+func (db *DB) Reputation(id ID) (penalty float64, updated time.Time) {
+	blob, err := db.lvl.Get(append([]byte(dbReputationPrefix), id[:]...), nil)
+	if err != nil {
+		return 0, time.Time{}
+	}
+	var entry reputationEntry
+	if err := rlp.DecodeBytes(blob, &entry); err != nil {
+		return 0, time.Time{}
+	}
+	return math.Float64frombits(entry.Penalty), time.Unix(0, int64(entry.Updated))
+}
+
+// UpdateReputation stores the penalty score of a node.
+func (db *DB) UpdateReputation(id ID, penalty float64, updated time.Time) error {
+	blob, err := rlp.EncodeToBytes(&reputationEntry{
+		Penalty: math.Float64bits(penalty),
+		Updated: uint64(updated.UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(append([]byte(dbReputationPrefix), id[:]...), blob, nil)
+}
+
+// DeleteReputation removes the penalty score of a node.
+func (db *DB) DeleteReputation(id ID) error {
+	return db.lvl.Delete(append([]byte(dbReputationPrefix), id[:]...), nil)
+}
+
+// peerSet retrieves all node records stored under the given peer set prefix.
+func (db *DB) peerSet(prefix string) []*Node {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer it.Release()
+
+	var nodes []*Node
+	for it.Next() {
+		id := it.Key()[len(prefix):]
+		if len(id) != len(ID{}) {
+			continue
+		}
+		nodes = append(nodes, mustDecodeNode(id, it.Value()))
+	}
+	return nodes
+}
+
+// addPeerSetNode stores a node record under the given peer set prefix.
+func (db *DB) addPeerSetNode(prefix string, node *Node) error {
+	blob, err := rlp.EncodeToBytes(&node.r)
+	if err != nil {
+		return err
+	}
+	id := node.ID()
+	return db.lvl.Put(append([]byte(prefix), id[:]...), blob, nil)
+}
+
 // close flushes and closes the database files.
 func (db *DB) Close() {
 	close(db.quit)
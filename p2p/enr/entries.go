@@ -55,6 +55,16 @@ func WithEntry(k string, v interface{}) Entry {
 	return &generic{key: k, value: v}
 }
 
+// IsReservedKey reports whether the given key is one of the pre-defined keys of
+// EIP-778, which describe the identity and the endpoints of a node.
+func IsReservedKey(key string) bool {
+	switch key {
+	case "id", "secp256k1", "ip", "ip6", "tcp", "tcp6", "udp", "udp6":
+		return true
+	}
+	return false
+}
+
 // TCP is the "tcp" key, which holds the TCP port of the node.
 type TCP uint16
 
@@ -0,0 +1,148 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	errInboundIPLimit     = errors.New("too many connections from IP")
+	errInboundSubnetLimit = errors.New("too many connections from subnet")
+	errReconnectCooldown  = errors.New("reconnecting too soon after disconnect")
+)
+
+// InboundPolicyInfo describes the inbound connection policy of the server along
+// with the number of inbound connections per subnet.
+type InboundPolicyInfo struct {
+	MaxPeersPerIP     int            `json:"maxPeersPerIP"`     // Inbound connection limit per IP, zero if unlimited
+	MaxPeersPerSubnet int            `json:"maxPeersPerSubnet"` // Inbound connection limit per /24 or /48 subnet, zero if unlimited
+	ReconnectCooldown string         `json:"reconnectCooldown"` // Time a node has to wait before reconnecting after a disconnect
+	Subnets           map[string]int `json:"subnets"`           // Current inbound connections per subnet
+}
+
+// subnetOf returns the /24 (IPv4) or /48 (IPv6) subnet of an IP address.
+func subnetOf(ip net.IP) string {
+	mask := net.CIDRMask(48, 128)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, mask = ip4, net.CIDRMask(24, 32)
+	}
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// inboundTracker counts inbound connections per IP address and subnet, from the
+// moment they are accepted until they are closed.
+type inboundTracker struct {
+	lock    sync.Mutex
+	ips     map[string]int
+	subnets map[string]int
+}
+
+func newInboundTracker() *inboundTracker {
+	return &inboundTracker{
+		ips:     make(map[string]int),
+		subnets: make(map[string]int),
+	}
+}
+
+// add accounts a new connection from the given IP, unless that would exceed the
+// per-IP or per-subnet limits. Zero limits are ignored, as are the limits for
+// exempt connections, which are still accounted.
+func (t *inboundTracker) add(ip net.IP, maxPerIP, maxPerSubnet int, exempt bool) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	addr, subnet := ip.String(), subnetOf(ip)
+	if !exempt {
+		if maxPerIP > 0 && t.ips[addr] >= maxPerIP {
+			return errInboundIPLimit
+		}
+		if maxPerSubnet > 0 && t.subnets[subnet] >= maxPerSubnet {
+			return errInboundSubnetLimit
+		}
+	}
+	t.ips[addr]++
+	t.subnets[subnet]++
+	return nil
+}
+
+// remove releases a connection from the given IP. Connections that were never
+// accounted are ignored.
+func (t *inboundTracker) remove(ip net.IP) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	addr, subnet := ip.String(), subnetOf(ip)
+	if t.ips[addr] == 0 {
+		return
+	}
+	if t.ips[addr]--; t.ips[addr] == 0 {
+		delete(t.ips, addr)
+	}
+	if t.subnets[subnet]--; t.subnets[subnet] <= 0 {
+		delete(t.subnets, subnet)
+	}
+}
+
+// subnetCounts returns the current number of inbound connections per subnet.
+func (t *inboundTracker) subnetCounts() map[string]int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	counts := make(map[string]int, len(t.subnets))
+	for subnet, n := range t.subnets {
+		counts[subnet] = n
+	}
+	return counts
+}
+
+// inboundPolicyInfo assembles the active inbound connection policy.
+func (srv *Server) inboundPolicyInfo() *InboundPolicyInfo {
+	info := &InboundPolicyInfo{
+		MaxPeersPerIP:     srv.MaxPeersPerIP,
+		MaxPeersPerSubnet: srv.MaxPeersPerSubnet,
+		ReconnectCooldown: srv.InboundCooldown.String(),
+		Subnets:           make(map[string]int),
+	}
+	if srv.inbound != nil {
+		info.Subnets = srv.inbound.subnetCounts()
+	}
+	return info
+}
+
+// recentDrops tracks recently disconnected nodes to enforce the reconnect
+// cooldown. It is only accessed by the run loop.
+type recentDrops struct {
+	cooldown time.Duration
+	hist     expHeap
+}
+
+// dropped records the disconnection of a node.
+func (d *recentDrops) dropped(id string, now time.Time) {
+	if d.cooldown > 0 {
+		d.hist.add(id, now.Add(d.cooldown))
+	}
+}
+
+// cooling reports whether the node disconnected within the cooldown period.
+func (d *recentDrops) cooling(id string, now time.Time) bool {
+	d.hist.expire(now)
+	return d.hist.contains(id)
+}
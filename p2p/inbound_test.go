@@ -0,0 +1,245 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestInboundTrackerLimits(t *testing.T) {
+	tracker := newInboundTracker()
+
+	// Fill up the per-IP and per-subnet allowances
+	steps := []struct {
+		ip     string
+		exempt bool
+		err    error
+	}{
+		{ip: "1.2.3.4", err: nil},
+		{ip: "1.2.3.4", err: nil},
+		{ip: "1.2.3.4", err: errInboundIPLimit},
+		{ip: "1.2.3.5", err: nil},
+		{ip: "1.2.3.6", err: errInboundSubnetLimit},
+		{ip: "1.2.4.6", err: nil},
+		{ip: "1.2.3.6", exempt: true, err: nil},
+		{ip: "2001:db8:1:1::1", err: nil},
+		{ip: "2001:db8:1:2::1", err: nil},
+		{ip: "2001:db8:1:3::1", err: nil},
+		{ip: "2001:db8:1:4::1", err: errInboundSubnetLimit},
+		{ip: "2001:db8:2::1", err: nil},
+	}
+	for i, step := range steps {
+		if err := tracker.add(net.ParseIP(step.ip), 2, 3, step.exempt); err != step.err {
+			t.Errorf("step %d (%s): error mismatch: have %v, want %v", i, step.ip, err, step.err)
+		}
+	}
+	want := map[string]int{"1.2.3.0/24": 4, "1.2.4.0/24": 1, "2001:db8:1::/48": 3, "2001:db8:2::/48": 1}
+	if counts := tracker.subnetCounts(); !equalCounts(counts, want) {
+		t.Errorf("subnet counts mismatch: have %v, want %v", counts, want)
+	}
+	// Release connections and check that the allowance is restored
+	tracker.remove(net.ParseIP("1.2.3.4"))
+	tracker.remove(net.ParseIP("1.2.3.6"))
+	if err := tracker.add(net.ParseIP("1.2.3.4"), 2, 3, false); err != nil {
+		t.Errorf("failed to add connection after release: %v", err)
+	}
+	// Releasing unknown connections must not corrupt the counts
+	tracker.remove(net.ParseIP("9.9.9.9"))
+	tracker.remove(net.ParseIP("1.2.4.7"))
+	want = map[string]int{"1.2.3.0/24": 3, "1.2.4.0/24": 1, "2001:db8:1::/48": 3, "2001:db8:2::/48": 1}
+	if counts := tracker.subnetCounts(); !equalCounts(counts, want) {
+		t.Errorf("subnet counts mismatch: have %v, want %v", counts, want)
+	}
+}
+
+func equalCounts(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Tests that the server rejects inbound connections from a crowded subnet before
+// running the handshake, and accepts them again once connections are closed.
+func TestServerInboundSubnetLimit(t *testing.T) {
+	const timeout = 5 * time.Second
+	newTransportCalled := make(chan struct{}, 10)
+	srv := &Server{
+		Config: Config{
+			PrivateKey:        newkey(),
+			ListenAddr:        "127.0.0.1:0",
+			MaxPeers:          10,
+			MaxPeersPerSubnet: 2,
+			NoDial:            true,
+			NoDiscovery:       true,
+			Protocols:         []Protocol{discard},
+			Logger:            testlog.Logger(t, log.LvlTrace),
+		},
+		newTransport: func(fd net.Conn) transport {
+			newTransportCalled <- struct{}{}
+			return newRLPX(fd)
+		},
+		listenFunc: func(network, laddr string) (net.Listener, error) {
+			l, err := net.Listen(network, laddr)
+			if err != nil {
+				return nil, err
+			}
+			return &subnetListener{Listener: l, subnet: net.IP{95, 33, 21, 0}}, nil
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal("can't start: ", err)
+	}
+	defer srv.Stop()
+
+	// Fill up the subnet allowance, each connection using a distinct IP
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.DialTimeout("tcp", srv.ListenAddr, timeout)
+		if err != nil {
+			t.Fatalf("could not dial: %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+
+		select {
+		case <-newTransportCalled:
+		case <-time.After(timeout):
+			t.Fatalf("connection %d: newTransport not called", i)
+		}
+	}
+	if n := srv.NodeInfo().Inbound.Subnets["95.33.21.0/24"]; n != 2 {
+		t.Fatalf("subnet count mismatch: have %d, want %d", n, 2)
+	}
+	// Any further connection from the same subnet should be closed immediately
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, timeout)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if n, err := conn.Read(make([]byte, 10)); err != io.EOF || n != 0 {
+		t.Errorf("expected io.EOF and n == 0, got error %q and n == %d", err, n)
+	}
+	select {
+	case <-newTransportCalled:
+		t.Error("newTransport called for connection over the subnet limit")
+	default:
+	}
+	// Drop a connection and wait for its slot to be released
+	conns[0].Close()
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		if srv.NodeInfo().Inbound.Subnets["95.33.21.0/24"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subnet slot not released")
+		}
+	}
+}
+
+// Tests that inbound peers are refused for a while after disconnecting, unless
+// they are trusted.
+func TestServerInboundCooldown(t *testing.T) {
+	remoteKey := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:      newkey(),
+			MaxPeers:        10,
+			NoDial:          true,
+			NoDiscovery:     true,
+			InboundCooldown: time.Minute,
+			Logger:          testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) (*conn, net.Conn) {
+		fd, remote := net.Pipe()
+		tx := newTestTransport(&remoteKey.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}, remote
+	}
+	// Connect a peer and disconnect it
+	id := randomID()
+	c, remote := newconn(id)
+	if err := srv.checkpoint(c, srv.checkpointPostHandshake); err != nil {
+		t.Fatalf("unexpected error for fresh conn @posthandshake: %v", err)
+	}
+	if err := srv.checkpoint(c, srv.checkpointAddPeer); err != nil {
+		t.Fatalf("unexpected error for fresh conn @addpeer: %v", err)
+	}
+	remote.Close()
+	for deadline := time.Now().Add(5 * time.Second); srv.PeerCount() > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("peer not dropped")
+		}
+	}
+	// Reconnecting should fail, unless the node is trusted
+	c, remote = newconn(id)
+	defer remote.Close()
+	if err := srv.checkpoint(c, srv.checkpointPostHandshake); err != errReconnectCooldown {
+		t.Errorf("reconnect error mismatch: have %v, want %v", err, errReconnectCooldown)
+	}
+	srv.AddTrustedPeer(enode.SignNull(new(enr.Record), id))
+	c, remote = newconn(id)
+	defer remote.Close()
+	if err := srv.checkpoint(c, srv.checkpointPostHandshake); err != nil {
+		t.Errorf("unexpected error for trusted reconnect: %v", err)
+	}
+}
+
+// subnetListener is a listener that assigns a distinct mocked remote address from
+// the same /24 subnet to every accepted connection.
+type subnetListener struct {
+	net.Listener
+	subnet net.IP
+
+	lock sync.Mutex
+	next byte
+}
+
+func (l *subnetListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.lock.Lock()
+	l.next++
+	ip := net.IP{l.subnet[0], l.subnet[1], l.subnet[2], l.next}
+	l.lock.Unlock()
+
+	return &fakeAddrConn{c, &net.TCPAddr{IP: ip, Port: 4444}}, nil
+}
@@ -45,6 +45,17 @@ var (
 	egressTrafficMeter  = metrics.NewRegisteredMeter(MetricsOutboundTraffic, nil)  // Meter metering the cumulative egress traffic
 	activePeerGauge     = metrics.NewRegisteredGauge("p2p/peers", nil)             // Gauge tracking the current peer count
 
+	ingressPayloadWireMeter    = metrics.NewRegisteredMeter(MetricsInboundTraffic+"/payload/wire", nil)     // Meter counting received message payloads as sent on the wire
+	ingressPayloadLogicalMeter = metrics.NewRegisteredMeter(MetricsInboundTraffic+"/payload/logical", nil)  // Meter counting received message payloads after decompression
+	egressPayloadWireMeter     = metrics.NewRegisteredMeter(MetricsOutboundTraffic+"/payload/wire", nil)    // Meter counting sent message payloads as sent on the wire
+	egressPayloadLogicalMeter  = metrics.NewRegisteredMeter(MetricsOutboundTraffic+"/payload/logical", nil) // Meter counting sent message payloads before compression
+
+	inboundRejectRestrictMeter = metrics.NewRegisteredMeter(MetricsInboundConnects+"/rejected/netrestrict", nil) // Meter counting inbound connections outside NetRestrict
+	inboundRejectThrottleMeter = metrics.NewRegisteredMeter(MetricsInboundConnects+"/rejected/throttle", nil)    // Meter counting inbound connections retried too often
+	inboundRejectIPMeter       = metrics.NewRegisteredMeter(MetricsInboundConnects+"/rejected/ip", nil)          // Meter counting inbound connections over the per-IP limit
+	inboundRejectSubnetMeter   = metrics.NewRegisteredMeter(MetricsInboundConnects+"/rejected/subnet", nil)      // Meter counting inbound connections over the per-subnet limit
+	inboundRejectCooldownMeter = metrics.NewRegisteredMeter(MetricsInboundConnects+"/rejected/cooldown", nil)    // Meter counting inbound peers reconnecting too soon
+
 	PeerIngressRegistry = metrics.NewPrefixedChildRegistry(metrics.EphemeralRegistry, MetricsInboundTraffic+"/")  // Registry containing the peer ingress
 	PeerEgressRegistry  = metrics.NewPrefixedChildRegistry(metrics.EphemeralRegistry, MetricsOutboundTraffic+"/") // Registry containing the peer egress
 
@@ -109,7 +120,7 @@ type meteredConn struct {
 // the original object.
 func newMeteredConn(conn net.Conn, ingress bool, addr *net.TCPAddr) net.Conn {
 	// Short circuit if metrics are disabled
-	if !metrics.Enabled {
+	if !metrics.Enabled() {
 		return conn
 	}
 	if addr == nil || addr.IP.IsUnspecified() {
@@ -0,0 +1,186 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	mapTimeout    = 20 * time.Minute // Lifetime requested for port mappings
+	mapRenewMin   = 30 * time.Second // Minimum time between two renewals of a granted mapping
+	mapRetryMin   = 10 * time.Second // Initial delay before retrying a failed mapping
+	mapRetryMax   = 5 * time.Minute  // Maximum delay between retries of a failed mapping
+	mapRenewRatio = 4                // Renewals happen after 3/4 of the lease passed
+)
+
+// leaseMapper is implemented by mechanisms which report the lifetime granted by
+// the gateway, which may be shorter than the requested one.
+type leaseMapper interface {
+	addMappingLease(protocol string, extport, intport int, name string, lifetime time.Duration) (time.Duration, error)
+}
+
+// addMapping creates a mapping on m, returning the lease granted by the gateway.
+// Mechanisms that don't report it are assumed to grant the requested lifetime.
+func addMapping(m Interface, protocol string, extport, intport int, name string, lifetime time.Duration) (time.Duration, error) {
+	if lm, ok := m.(leaseMapper); ok {
+		return lm.addMappingLease(protocol, extport, intport, name, lifetime)
+	}
+	if err := m.AddMapping(protocol, extport, intport, name, lifetime); err != nil {
+		return 0, err
+	}
+	return lifetime, nil
+}
+
+// MappingStatus is a snapshot of the state of a port mapping.
+type MappingStatus struct {
+	Protocol     string    `json:"protocol"`
+	ExternalPort int       `json:"externalPort"`
+	InternalPort int       `json:"internalPort"`
+	Mapped       bool      `json:"mapped"`              // Whether the gateway holds a valid lease
+	Expiry       time.Time `json:"expiry"`              // End of the current lease
+	LastRenewal  time.Time `json:"lastRenewal"`         // Time of the last renewal attempt
+	LastError    string    `json:"lastError,omitempty"` // Error of the last renewal attempt, if it failed
+}
+
+// Mapping is a port mapping which is renewed before the lease granted by the
+// gateway runs out. Failed attempts are retried with exponential backoff.
+type Mapping struct {
+	nat      Interface
+	protocol string
+	extport  int
+	intport  int
+	name     string
+	clock    mclock.Clock
+	log      log.Logger
+
+	lock        sync.Mutex
+	mapped      bool           // Whether a lease was granted at some point
+	expiry      mclock.AbsTime // End of the last granted lease
+	lastRenewal mclock.AbsTime // Time of the last renewal attempt
+	lastErr     error          // Result of the last renewal attempt
+	failures    int            // Number of consecutive failed attempts
+}
+
+// NewMapping creates a port mapping on m. The mapping isn't requested until Run
+// is called.
+func NewMapping(m Interface, protocol string, extport, intport int, name string) *Mapping {
+	return &Mapping{
+		nat:      m,
+		protocol: protocol,
+		extport:  extport,
+		intport:  intport,
+		name:     name,
+		clock:    mclock.System{},
+		log:      log.New("proto", protocol, "extport", extport, "intport", intport, "interface", m),
+	}
+}
+
+// Run adds the port mapping and keeps it alive until quit is closed, deleting
+// it afterwards. This function is typically invoked in its own goroutine.
+func (mp *Mapping) Run(quit <-chan struct{}) {
+	defer func() {
+		mp.log.Debug("Deleting port mapping")
+		mp.nat.DeleteMapping(mp.protocol, mp.extport, mp.intport)
+	}()
+	delay := mp.renew()
+	for {
+		select {
+		case _, ok := <-quit:
+			if !ok {
+				return
+			}
+		case <-mp.clock.After(delay):
+			delay = mp.renew()
+		}
+	}
+}
+
+// renew requests the mapping from the gateway and returns the time until the
+// next attempt should be made.
+func (mp *Mapping) renew() time.Duration {
+	mp.log.Trace("Refreshing port mapping")
+	lease, err := addMapping(mp.nat, mp.protocol, mp.extport, mp.intport, mp.name, mapTimeout)
+
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	now := mp.clock.Now()
+	mp.lastRenewal, mp.lastErr = now, err
+	if err != nil {
+		mp.failures++
+		retry := mapRetryMax
+		if mp.failures < 16 {
+			if backoff := mapRetryMin << uint(mp.failures-1); backoff < retry {
+				retry = backoff
+			}
+		}
+		// Failing to set up the mapping initially is common when there is no
+		// gateway, only warn when an established mapping is lost.
+		if mp.mapped {
+			mp.log.Warn("Couldn't renew port mapping", "failures", mp.failures, "retry", retry, "err", err)
+		} else {
+			mp.log.Debug("Couldn't add port mapping", "failures", mp.failures, "retry", retry, "err", err)
+		}
+		return retry
+	}
+	if !mp.mapped || mp.failures > 0 {
+		mp.log.Info("Mapped network port", "lease", lease)
+	}
+	mp.mapped, mp.failures = true, 0
+	if lease <= 0 {
+		lease = mapTimeout
+	}
+	mp.expiry = now.Add(lease)
+
+	delay := lease - lease/mapRenewRatio
+	if delay < mapRenewMin {
+		delay = mapRenewMin
+	}
+	return delay
+}
+
+// Status returns the current state of the mapping.
+func (mp *Mapping) Status() MappingStatus {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	var (
+		now    = mp.clock.Now()
+		wall   = time.Now()
+		status = MappingStatus{
+			Protocol:     mp.protocol,
+			ExternalPort: mp.extport,
+			InternalPort: mp.intport,
+			Mapped:       mp.mapped && mp.expiry > now,
+		}
+	)
+	if mp.mapped {
+		status.Expiry = wall.Add(time.Duration(mp.expiry - now))
+	}
+	if mp.mapped || mp.lastErr != nil {
+		status.LastRenewal = wall.Add(time.Duration(mp.lastRenewal - now))
+	}
+	if mp.lastErr != nil {
+		status.LastError = mp.lastErr.Error()
+	}
+	return status
+}
@@ -17,9 +17,14 @@
 package nat
 
 import (
+	"encoding/binary"
+	"errors"
 	"net"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
 )
 
 // This test checks that autodisc doesn't hang and returns
@@ -61,3 +66,192 @@ func TestAutoDiscRace(t *testing.T) {
 		}
 	}
 }
+
+// mockNAT is a port mapper which grants a fixed lease and can be made to fail.
+type mockNAT struct {
+	mu      sync.Mutex
+	lease   time.Duration
+	err     error
+	adds    int
+	deletes int
+}
+
+func (n *mockNAT) addMappingLease(protocol string, extport, intport int, name string, lifetime time.Duration) (time.Duration, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.adds++
+	if n.err != nil {
+		return 0, n.err
+	}
+	return n.lease, nil
+}
+
+func (n *mockNAT) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	_, err := n.addMappingLease(protocol, extport, intport, name, lifetime)
+	return err
+}
+
+func (n *mockNAT) DeleteMapping(protocol string, extport, intport int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.deletes++
+	return nil
+}
+
+func (n *mockNAT) setErr(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err = err
+}
+
+func (n *mockNAT) counts() (int, int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.adds, n.deletes
+}
+
+func (n *mockNAT) ExternalIP() (net.IP, error) { return net.IP{33, 44, 55, 66}, nil }
+func (n *mockNAT) String() string              { return "mock" }
+
+// startMapping runs a mapping on the mock NAT using a simulated clock, returning
+// a function which stops it and waits for it to exit.
+func startMapping(m Interface, clock *mclock.Simulated) (*Mapping, func()) {
+	mp := NewMapping(m, "tcp", 30303, 30303, "test")
+	mp.clock = clock
+
+	quit, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		mp.Run(quit)
+		close(done)
+	}()
+	return mp, func() {
+		close(quit)
+		<-done
+	}
+}
+
+// Tests that mappings are renewed based on the lease granted by the gateway
+// rather than the requested lifetime.
+func TestMappingRenewal(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		nat   = &mockNAT{lease: 8 * time.Minute}
+	)
+	mp, stop := startMapping(nat, clock)
+
+	clock.WaitForTimers(1)
+	if adds, _ := nat.counts(); adds != 1 {
+		t.Fatalf("wrong number of mapping requests after start: %d", adds)
+	}
+	if st := mp.Status(); !st.Mapped || st.LastError != "" {
+		t.Fatalf("mapping not reported as active: %+v", st)
+	}
+	// The mapping should be renewed after three quarters of the lease.
+	clock.Run(6*time.Minute - time.Second)
+	if adds, _ := nat.counts(); adds != 1 {
+		t.Fatalf("mapping renewed too early")
+	}
+	clock.Run(time.Second)
+	clock.WaitForTimers(1)
+	if adds, _ := nat.counts(); adds != 2 {
+		t.Fatalf("mapping not renewed after 6min, requests: %d", adds)
+	}
+	stop()
+	if _, deletes := nat.counts(); deletes != 1 {
+		t.Fatalf("mapping not deleted on shutdown")
+	}
+}
+
+// Tests that failed renewals are retried with exponential backoff and that the
+// failure is reported in the mapping status.
+func TestMappingRenewalFailure(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		nat   = &mockNAT{lease: mapTimeout}
+	)
+	mp, stop := startMapping(nat, clock)
+	defer stop()
+
+	clock.WaitForTimers(1)
+	nat.setErr(errors.New("gateway unreachable"))
+
+	// Renewal fails after 15 minutes, then retries back off.
+	delays := []time.Duration{15 * time.Minute, mapRetryMin, 2 * mapRetryMin, 4 * mapRetryMin, 8 * mapRetryMin, 16 * mapRetryMin}
+	for i, delay := range delays {
+		clock.Run(delay - time.Second)
+		if adds, _ := nat.counts(); adds != i+1 {
+			t.Fatalf("attempt %d: retried too early", i)
+		}
+		clock.Run(time.Second)
+		clock.WaitForTimers(1)
+		if adds, _ := nat.counts(); adds != i+2 {
+			t.Fatalf("attempt %d: no retry after %v", i, delay)
+		}
+		if st := mp.Status(); st.LastError != "gateway unreachable" {
+			t.Fatalf("attempt %d: wrong error in status: %q", i, st.LastError)
+		}
+	}
+	// The lease has run out by now.
+	if st := mp.Status(); st.Mapped {
+		t.Fatalf("expired mapping reported as active")
+	}
+	// Once the gateway is back, the regular renewal schedule resumes.
+	nat.setErr(nil)
+	clock.Run(mapRetryMax)
+	clock.WaitForTimers(1)
+	if st := mp.Status(); !st.Mapped || st.LastError != "" {
+		t.Fatalf("mapping not restored: %+v", st)
+	}
+	adds, _ := nat.counts()
+	clock.Run(15*time.Minute - time.Second)
+	if n, _ := nat.counts(); n != adds {
+		t.Fatalf("backoff not reset after successful renewal")
+	}
+}
+
+// Tests the PCP MAP request against a fake PCP server.
+func TestPCPMapping(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Serve MAP requests, granting half of the requested lifetime.
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			if n != pcpHeaderSize+pcpMapSize || req[0] != pcpVersion || req[1] != pcpOpMap {
+				continue
+			}
+			resp := make([]byte, pcpHeaderSize+pcpMapSize)
+			resp[0] = pcpVersion
+			resp[1] = pcpOpMap | pcpResponseFlag
+			binary.BigEndian.PutUint32(resp[4:], binary.BigEndian.Uint32(req[4:])/2)
+			copy(resp[pcpHeaderSize:], req[pcpHeaderSize:])
+			copy(resp[pcpHeaderSize+20:], net.IP{33, 44, 55, 66}.To16())
+			conn.WriteToUDP(resp, addr)
+		}
+	}()
+	n := newPCP(net.IP{127, 0, 0, 1})
+	n.port = conn.LocalAddr().(*net.UDPAddr).Port
+
+	lease, err := addMapping(n, "tcp", 30303, 30303, "test", 20*time.Minute)
+	if err != nil {
+		t.Fatalf("mapping failed: %v", err)
+	}
+	if lease != 10*time.Minute {
+		t.Errorf("wrong lease: got %v, want %v", lease, 10*time.Minute)
+	}
+	if ip, _ := n.ExternalIP(); !ip.Equal(net.IP{33, 44, 55, 66}) {
+		t.Errorf("wrong external IP: %v", ip)
+	}
+	if err := n.DeleteMapping("tcp", 30303, 30303); err != nil {
+		t.Errorf("deleting mapping failed: %v", err)
+	}
+}
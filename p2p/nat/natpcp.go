@@ -0,0 +1,282 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	natpmp "github.com/jackpal/go-nat-pmp"
+)
+
+// Port Control Protocol constants, see RFC 6887.
+const (
+	pcpPort         = 5351
+	pcpVersion      = 2
+	pcpOpAnnounce   = 0
+	pcpOpMap        = 1
+	pcpResponseFlag = 0x80
+	pcpHeaderSize   = 24
+	pcpMapSize      = 36
+	pcpNonceSize    = 12
+
+	pcpInitialTimeout = 250 * time.Millisecond
+	pcpMaxAttempts    = 4
+)
+
+var (
+	errPCPBadResponse = errors.New("invalid PCP response")
+	errPCPTimeout     = errors.New("PCP request timed out")
+)
+
+// pcpResultError is a non-success result code returned by a PCP server.
+type pcpResultError byte
+
+var pcpResultNames = map[pcpResultError]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external",
+	12: "address mismatch",
+	13: "excessive remote peers",
+}
+
+func (e pcpResultError) Error() string {
+	if name, ok := pcpResultNames[e]; ok {
+		return "PCP error: " + name
+	}
+	return fmt.Sprintf("PCP error: result code %d", byte(e))
+}
+
+// pcp implements the Port Control Protocol. Mappings are identified by the
+// gateway through a nonce, which is kept per mapping so it can be renewed and
+// deleted later.
+type pcp struct {
+	gw   net.IP
+	port int // gateway port, overridden in tests
+
+	mu     sync.Mutex
+	nonces map[string][pcpNonceSize]byte
+	extIP  net.IP // external address reported by the last mapping
+}
+
+func newPCP(gw net.IP) *pcp {
+	return &pcp{gw: gw, port: pcpPort, nonces: make(map[string][pcpNonceSize]byte)}
+}
+
+func (n *pcp) String() string {
+	return fmt.Sprintf("PCP(%v)", n.gw)
+}
+
+func (n *pcp) ExternalIP() (net.IP, error) {
+	n.mu.Lock()
+	ip := n.extIP
+	n.mu.Unlock()
+	if ip != nil {
+		return ip, nil
+	}
+	// PCP can only report the external address as part of a mapping. Servers
+	// are required to understand NAT-PMP though, so ask that way instead.
+	response, err := natpmp.NewClient(n.gw).GetExternalAddress()
+	if err != nil {
+		return nil, err
+	}
+	return response.ExternalIPAddress[:], nil
+}
+
+func (n *pcp) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	_, err := n.addMappingLease(protocol, extport, intport, name, lifetime)
+	return err
+}
+
+func (n *pcp) addMappingLease(protocol string, extport, intport int, name string, lifetime time.Duration) (time.Duration, error) {
+	if lifetime <= 0 {
+		return 0, fmt.Errorf("lifetime must not be <= 0")
+	}
+	lease, ip, err := n.mapPort(protocol, extport, intport, lifetime)
+	if err != nil {
+		return 0, err
+	}
+	n.mu.Lock()
+	n.extIP = ip
+	n.mu.Unlock()
+	return lease, nil
+}
+
+func (n *pcp) DeleteMapping(protocol string, extport, intport int) error {
+	// Mappings are deleted by requesting them with a lifetime of zero.
+	_, _, err := n.mapPort(protocol, extport, intport, 0)
+
+	n.mu.Lock()
+	delete(n.nonces, pcpMappingKey(protocol, intport))
+	n.mu.Unlock()
+	return err
+}
+
+func pcpMappingKey(protocol string, intport int) string {
+	return fmt.Sprintf("%s:%d", strings.ToLower(protocol), intport)
+}
+
+// mapPort sends a MAP request and returns the granted lifetime along with the
+// external address of the mapping.
+func (n *pcp) mapPort(protocol string, extport, intport int, lifetime time.Duration) (time.Duration, net.IP, error) {
+	var proto byte
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		proto = 6
+	case "udp":
+		proto = 17
+	default:
+		return 0, nil, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+	nonce, err := n.nonce(protocol, intport)
+	if err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, pcpMapSize)
+	copy(payload, nonce[:])
+	payload[12] = proto
+	binary.BigEndian.PutUint16(payload[16:], uint16(intport))
+	binary.BigEndian.PutUint16(payload[18:], uint16(extport))
+	copy(payload[20:], net.IPv4zero.To16()) // no preference for the external address
+
+	resp, err := n.request(pcpOpMap, uint32(lifetime/time.Second), payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(resp) < pcpHeaderSize+pcpMapSize {
+		return 0, nil, errPCPBadResponse
+	}
+	body := resp[pcpHeaderSize:]
+	if !bytes.Equal(body[:pcpNonceSize], nonce[:]) || body[12] != proto {
+		return 0, nil, errPCPBadResponse
+	}
+	lease := time.Duration(binary.BigEndian.Uint32(resp[4:])) * time.Second
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, body[20:36])
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return lease, ip, nil
+}
+
+// nonce returns the mapping nonce for the given internal port, creating a new
+// one if none exists yet.
+func (n *pcp) nonce(protocol string, intport int) ([pcpNonceSize]byte, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	key := pcpMappingKey(protocol, intport)
+	nonce, ok := n.nonces[key]
+	if !ok {
+		if _, err := rand.Read(nonce[:]); err != nil {
+			return nonce, err
+		}
+		n.nonces[key] = nonce
+	}
+	return nonce, nil
+}
+
+// request sends a PCP request to the gateway, retransmitting it with increasing
+// timeouts until a matching response arrives.
+func (n *pcp) request(op byte, lifetime uint32, payload []byte) ([]byte, error) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: n.gw, Port: n.port})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := make([]byte, pcpHeaderSize+len(payload))
+	req[0] = pcpVersion
+	req[1] = op
+	binary.BigEndian.PutUint32(req[4:], lifetime)
+	copy(req[8:], conn.LocalAddr().(*net.UDPAddr).IP.To16())
+	copy(req[pcpHeaderSize:], payload)
+
+	buf := make([]byte, 1100) // maximum PCP message size
+	timeout := pcpInitialTimeout
+	for i := 0; i < pcpMaxAttempts; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			nbytes, err := conn.Read(buf)
+			if err != nil {
+				if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			resp := buf[:nbytes]
+			if len(resp) < pcpHeaderSize || resp[0] != pcpVersion || resp[1] != op|pcpResponseFlag {
+				continue // not a response to our request
+			}
+			if resp[3] != 0 {
+				return nil, pcpResultError(resp[3])
+			}
+			return resp, nil
+		}
+		timeout *= 2
+	}
+	return nil, errPCPTimeout
+}
+
+func discoverPCP() Interface {
+	// send announce requests to all potential gateways
+	gws := potentialGateways()
+	found := make(chan *pcp, len(gws))
+	for i := range gws {
+		gw := gws[i]
+		go func() {
+			c := newPCP(gw)
+			if _, err := c.request(pcpOpAnnounce, 0, nil); err != nil {
+				found <- nil
+			} else {
+				found <- c
+			}
+		}()
+	}
+	// return the one that responds first.
+	timeout := time.NewTimer(1 * time.Second)
+	defer timeout.Stop()
+	for range gws {
+		select {
+		case c := <-found:
+			if c != nil {
+				return c
+			}
+		case <-timeout.C:
+			return nil
+		}
+	}
+	return nil
+}
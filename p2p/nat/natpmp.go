@@ -45,13 +45,21 @@ func (n *pmp) ExternalIP() (net.IP, error) {
 }
 
 func (n *pmp) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	_, err := n.addMappingLease(protocol, extport, intport, name, lifetime)
+	return err
+}
+
+func (n *pmp) addMappingLease(protocol string, extport, intport int, name string, lifetime time.Duration) (time.Duration, error) {
 	if lifetime <= 0 {
-		return fmt.Errorf("lifetime must not be <= 0")
+		return 0, fmt.Errorf("lifetime must not be <= 0")
 	}
 	// Note order of port arguments is switched between our
 	// AddMapping and the client's AddPortMapping.
-	_, err := n.c.AddPortMapping(strings.ToLower(protocol), intport, extport, int(lifetime/time.Second))
-	return err
+	res, err := n.c.AddPortMapping(strings.ToLower(protocol), intport, extport, int(lifetime/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(res.PortMappingLifetimeInSeconds) * time.Second, nil
 }
 
 func (n *pmp) DeleteMapping(protocol string, extport, intport int) (err error) {
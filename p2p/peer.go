@@ -87,19 +87,27 @@ const (
 	// PeerEventTypeMsgRecv is the type of event emitted when a
 	// message is received from a peer
 	PeerEventTypeMsgRecv PeerEventType = "msgrecv"
+
+	// PeerEventTypeHandshakeFail is the type of event emitted when the
+	// handshake with a peer fails, either during connection setup or in
+	// a subprotocol. In the latter case, a drop event follows.
+	PeerEventTypeHandshakeFail PeerEventType = "handshakefail"
 )
 
 // PeerEvent is an event emitted when peers are either added or dropped from
 // a p2p.Server or when a message is sent or received on a peer connection
 type PeerEvent struct {
-	Type          PeerEventType `json:"type"`
-	Peer          enode.ID      `json:"peer"`
-	Error         string        `json:"error,omitempty"`
-	Protocol      string        `json:"protocol,omitempty"`
-	MsgCode       *uint64       `json:"msg_code,omitempty"`
-	MsgSize       *uint32       `json:"msg_size,omitempty"`
-	LocalAddress  string        `json:"local,omitempty"`
-	RemoteAddress string        `json:"remote,omitempty"`
+	Type          PeerEventType    `json:"type"`
+	Peer          enode.ID         `json:"peer"`
+	Error         string           `json:"error,omitempty"`
+	Reason        string           `json:"reason,omitempty"`  // disconnect reason of drop events
+	Failure       HandshakeFailure `json:"failure,omitempty"` // category of handshake failures
+	Caps          []string         `json:"caps,omitempty"`    // negotiated protocols of add events
+	Protocol      string           `json:"protocol,omitempty"`
+	MsgCode       *uint64          `json:"msg_code,omitempty"`
+	MsgSize       *uint32          `json:"msg_size,omitempty"`
+	LocalAddress  string           `json:"local,omitempty"`
+	RemoteAddress string           `json:"remote,omitempty"`
 }
 
 // Peer represents a connected remote node.
@@ -108,6 +116,7 @@ type Peer struct {
 	running map[string]*protoRW
 	log     log.Logger
 	created mclock.AbsTime
+	stats   *peerStats
 
 	wg       sync.WaitGroup
 	protoErr chan error
@@ -116,6 +125,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// reputation records reported incidents if set
+	reputation *reputationStore
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -149,6 +161,20 @@ func (p *Peer) Caps() []Cap {
 	return p.rw.caps
 }
 
+// RunningCap returns true if the peer is actively connected using any of the
+// enumerated versions of a specific protocol, meaning that at least one of the
+// versions is supported by both this node and the peer p.
+func (p *Peer) RunningCap(protocol string, versions []uint) bool {
+	if proto, ok := p.running[protocol]; ok {
+		for _, ver := range versions {
+			if proto.Version == ver {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // RemoteAddr returns the remote address of the network connection.
 func (p *Peer) RemoteAddr() net.Addr {
 	return p.rw.fd.RemoteAddr()
@@ -159,6 +185,14 @@ func (p *Peer) LocalAddr() net.Addr {
 	return p.rw.fd.LocalAddr()
 }
 
+// ReportIncident records misbehaviour of the peer. Nodes with too many recent
+// incidents are refused for a while, even across restarts. The connection is
+// not closed, callers usually disconnect the peer afterwards.
+func (p *Peer) ReportIncident(incident Incident) {
+	rep := p.reputation.report(p.ID(), incident)
+	p.log.Debug("Recorded peer incident", "incident", incident, "penalty", rep.Penalty, "banned", rep.Banned)
+}
+
 // Disconnect terminates the peer connection with the given reason.
 // It returns immediately and does not wait until the connection is closed.
 func (p *Peer) Disconnect(reason DiscReason) {
@@ -185,6 +219,7 @@ func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 		rw:       conn,
 		running:  protomap,
 		created:  mclock.Now(),
+		stats:    newPeerStats(),
 		disc:     make(chan DiscReason),
 		protoErr: make(chan error, len(protomap)+1), // protocols + pingLoop
 		closed:   make(chan struct{}),
@@ -197,12 +232,11 @@ func (p *Peer) Log() log.Logger {
 	return p.log
 }
 
-func (p *Peer) run() (remoteRequested bool, err error) {
+func (p *Peer) run() (remoteRequested bool, reason DiscReason, err error) {
 	var (
 		writeStart = make(chan struct{}, 1)
 		writeErr   = make(chan error, 1)
 		readErr    = make(chan error, 1)
-		reason     DiscReason // sent to the peer
 	)
 	p.wg.Add(2)
 	go p.readLoop(readErr)
@@ -244,7 +278,7 @@ loop:
 	close(p.closed)
 	p.rw.close(reason)
 	p.wg.Wait()
-	return remoteRequested, err
+	return remoteRequested, reason, err
 }
 
 func (p *Peer) pingLoop() {
@@ -254,7 +288,7 @@ func (p *Peer) pingLoop() {
 	for {
 		select {
 		case <-ping.C:
-			if err := SendItems(p.rw, pingMsg); err != nil {
+			if err := p.sendPing(); err != nil {
 				p.protoErr <- err
 				return
 			}
@@ -265,6 +299,26 @@ func (p *Peer) pingLoop() {
 	}
 }
 
+// sendPing sends a ping message, recording the time for round-trip measurement.
+func (p *Peer) sendPing() error {
+	p.stats.pinged(mclock.Now())
+	return p.sendBaseMsg(pingMsg)
+}
+
+// sendBaseMsg sends a payload-less base protocol message and accounts it in
+// the peer statistics.
+func (p *Peer) sendBaseMsg(code uint64) error {
+	size, r, err := rlp.EncodeToReader([]interface{}{})
+	if err != nil {
+		return err
+	}
+	if err := p.rw.WriteMsg(Msg{Code: code, Size: uint32(size), Payload: r}); err != nil {
+		return err
+	}
+	p.stats.recordOut(baseProtocolName, uint32(size))
+	return nil
+}
+
 func (p *Peer) readLoop(errc chan<- error) {
 	defer p.wg.Done()
 	for {
@@ -282,10 +336,16 @@ func (p *Peer) readLoop(errc chan<- error) {
 }
 
 func (p *Peer) handle(msg Msg) error {
+	if msg.Code < baseProtocolLength {
+		p.stats.recordIn(baseProtocolName, msg.Size)
+	}
 	switch {
 	case msg.Code == pingMsg:
 		msg.Discard()
-		go SendItems(p.rw, pongMsg)
+		go p.sendBaseMsg(pongMsg)
+	case msg.Code == pongMsg:
+		p.stats.ponged(mclock.Now())
+		return msg.Discard()
 	case msg.Code == discMsg:
 		var reason [1]DiscReason
 		// This is the last message. We don't need to discard or
@@ -301,9 +361,10 @@ func (p *Peer) handle(msg Msg) error {
 		if err != nil {
 			return fmt.Errorf("msg code out of range: %v", msg.Code)
 		}
-		if metrics.Enabled {
+		if metrics.Enabled() {
 			metrics.GetOrRegisterMeter(fmt.Sprintf("%s/%s/%d/%#02x", MetricsInboundTraffic, proto.Name, proto.Version, msg.Code-proto.offset), nil).Mark(int64(msg.meterSize))
 		}
+		p.stats.recordIn(proto.Name, msg.Size)
 		select {
 		case proto.in <- msg:
 			return nil
@@ -358,6 +419,7 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.closed = p.closed
 		proto.wstart = writeStart
 		proto.werr = writeErr
+		proto.stats = p.stats
 		var rw MsgReadWriter = proto
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name, p.Info().Network.RemoteAddress, p.Info().Network.LocalAddress)
@@ -396,6 +458,7 @@ type protoRW struct {
 	werr   chan<- error    // for write results
 	offset uint64
 	w      MsgWriter
+	stats  *peerStats // accounts sent messages
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
@@ -410,6 +473,9 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	select {
 	case <-rw.wstart:
 		err = rw.w.WriteMsg(msg)
+		if err == nil && rw.stats != nil {
+			rw.stats.recordOut(rw.Name, msg.Size)
+		}
 		// Report write status back to Peer.run. It will initiate
 		// shutdown if the error is non-nil and unblock the next write
 		// otherwise. The calling protocol code should exit for errors
@@ -99,7 +99,48 @@ func (d DiscReason) Error() string {
 	return d.String()
 }
 
+// HandshakeFailure categorizes the reason of a failed handshake.
+type HandshakeFailure string
+
+const (
+	HandshakeTooManyPeers    HandshakeFailure = "too-many-peers"
+	HandshakeGenesisMismatch HandshakeFailure = "genesis-mismatch"
+	HandshakeForkIDRejected  HandshakeFailure = "forkid-rejected"
+	HandshakeIncompatible    HandshakeFailure = "incompatible-protocol"
+	HandshakeOtherFailure    HandshakeFailure = "other"
+)
+
+// HandshakeError is returned by protocols whose handshake with the remote peer
+// failed. The server reports such errors as PeerEventTypeHandshakeFail events.
+type HandshakeError struct {
+	Failure HandshakeFailure
+	Err     error
+}
+
+// NewHandshakeError wraps err as a handshake failure of the given category.
+func NewHandshakeError(failure HandshakeFailure, err error) *HandshakeError {
+	return &HandshakeError{Failure: failure, Err: err}
+}
+
+func (e *HandshakeError) Error() string {
+	return e.Err.Error()
+}
+
+// handshakeFailureForError categorizes errors of the devp2p handshake.
+func handshakeFailureForError(err error) HandshakeFailure {
+	switch err {
+	case DiscTooManyPeers:
+		return HandshakeTooManyPeers
+	case DiscUselessPeer, DiscIncompatibleVersion:
+		return HandshakeIncompatible
+	}
+	return HandshakeOtherFailure
+}
+
 func discReasonForError(err error) DiscReason {
+	if herr, ok := err.(*HandshakeError); ok {
+		err = herr.Err
+	}
 	if reason, ok := err.(DiscReason); ok {
 		return reason
 	}
@@ -0,0 +1,160 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// baseProtocolName is the name under which messages of the devp2p base protocol
+// (ping, pong, disconnect) are accounted in the peer statistics.
+const baseProtocolName = "p2p"
+
+// PeerStatsRegistry contains the per-peer connection quality gauges.
+var PeerStatsRegistry = metrics.NewPrefixedChildRegistry(metrics.EphemeralRegistry, "p2p/peerstats/")
+
+// PeerStats represents the connection quality statistics of a connected peer.
+type PeerStats struct {
+	ID       string                       `json:"id"`       // Unique node identifier
+	Name     string                       `json:"name"`     // Name of the node, including client type, version, OS, custom data
+	Age      float64                      `json:"age"`      // Connection age in seconds
+	BytesIn  uint64                       `json:"bytesIn"`  // Message payload bytes received
+	BytesOut uint64                       `json:"bytesOut"` // Message payload bytes sent
+	RTT      float64                      `json:"rtt"`      // Last ping round-trip time in milliseconds (zero if not measured yet)
+	Connects uint64                       `json:"connects"` // Number of times a connection to the node was established
+	Messages map[string]*ProtocolMsgStats `json:"messages"` // Message counts by protocol name ("p2p" for the base protocol)
+}
+
+// ProtocolMsgStats contains the number of messages exchanged over a protocol.
+type ProtocolMsgStats struct {
+	In  uint64 `json:"in"`
+	Out uint64 `json:"out"`
+}
+
+// peerStats tracks the traffic and latency of a single peer connection.
+type peerStats struct {
+	bytesIn  uint64 // Payload bytes received (atomic, keep 64-bit fields first for alignment)
+	bytesOut uint64 // Payload bytes sent (atomic)
+	rtt      int64  // Last measured ping round-trip time (atomic)
+	pingSent int64  // Time of the last unanswered ping, zero if none (atomic)
+
+	lock     sync.Mutex
+	messages map[string]*ProtocolMsgStats
+}
+
+func newPeerStats() *peerStats {
+	return &peerStats{messages: make(map[string]*ProtocolMsgStats)}
+}
+
+// counter returns the message counters of a protocol. The caller must hold the lock.
+func (s *peerStats) counter(proto string) *ProtocolMsgStats {
+	c := s.messages[proto]
+	if c == nil {
+		c = new(ProtocolMsgStats)
+		s.messages[proto] = c
+	}
+	return c
+}
+
+// recordIn accounts a received message.
+func (s *peerStats) recordIn(proto string, size uint32) {
+	atomic.AddUint64(&s.bytesIn, uint64(size))
+	s.lock.Lock()
+	s.counter(proto).In++
+	s.lock.Unlock()
+}
+
+// recordOut accounts a sent message.
+func (s *peerStats) recordOut(proto string, size uint32) {
+	atomic.AddUint64(&s.bytesOut, uint64(size))
+	s.lock.Lock()
+	s.counter(proto).Out++
+	s.lock.Unlock()
+}
+
+// pinged records the time a ping was sent.
+func (s *peerStats) pinged(now mclock.AbsTime) {
+	atomic.StoreInt64(&s.pingSent, int64(now))
+}
+
+// ponged measures the round-trip time if a ping is outstanding. Unsolicited
+// pongs are ignored.
+func (s *peerStats) ponged(now mclock.AbsTime) {
+	sent := atomic.SwapInt64(&s.pingSent, 0)
+	if sent == 0 {
+		return
+	}
+	atomic.StoreInt64(&s.rtt, int64(now)-sent)
+}
+
+// Stats returns the connection quality statistics of the peer. The historical
+// connection count is only known to the server and left zero.
+func (p *Peer) Stats() *PeerStats {
+	stats := &PeerStats{
+		ID:       p.ID().String(),
+		Name:     p.Name(),
+		Age:      time.Duration(mclock.Now() - p.created).Seconds(),
+		BytesIn:  atomic.LoadUint64(&p.stats.bytesIn),
+		BytesOut: atomic.LoadUint64(&p.stats.bytesOut),
+		RTT:      float64(atomic.LoadInt64(&p.stats.rtt)) / float64(time.Millisecond),
+		Messages: make(map[string]*ProtocolMsgStats),
+	}
+	p.stats.lock.Lock()
+	for proto, c := range p.stats.messages {
+		stats.Messages[proto] = &ProtocolMsgStats{In: c.In, Out: c.Out}
+	}
+	p.stats.lock.Unlock()
+	return stats
+}
+
+// statsGaugePrefix returns the metrics name prefix of the peer's gauges, which
+// is the truncated node ID.
+func (p *Peer) statsGaugePrefix() string {
+	id := p.ID()
+	return fmt.Sprintf("%x/", id[:8])
+}
+
+// registerStatsGauges exposes the peer statistics in the metrics registry.
+func (p *Peer) registerStatsGauges() {
+	prefix := p.statsGaugePrefix()
+	metrics.NewRegisteredFunctionalGauge(prefix+"age", PeerStatsRegistry, func() int64 {
+		return int64(time.Duration(mclock.Now()-p.created) / time.Second)
+	})
+	metrics.NewRegisteredFunctionalGauge(prefix+"ingress", PeerStatsRegistry, func() int64 {
+		return int64(atomic.LoadUint64(&p.stats.bytesIn))
+	})
+	metrics.NewRegisteredFunctionalGauge(prefix+"egress", PeerStatsRegistry, func() int64 {
+		return int64(atomic.LoadUint64(&p.stats.bytesOut))
+	})
+	metrics.NewRegisteredFunctionalGauge(prefix+"rtt", PeerStatsRegistry, func() int64 {
+		return int64(time.Duration(atomic.LoadInt64(&p.stats.rtt)) / time.Millisecond)
+	})
+}
+
+// unregisterStatsGauges removes the peer statistics from the metrics registry.
+func (p *Peer) unregisterStatsGauges() {
+	prefix := p.statsGaugePrefix()
+	for _, name := range []string{"age", "ingress", "egress", "rtt"} {
+		PeerStatsRegistry.Unregister(prefix + name)
+	}
+}
@@ -57,7 +57,7 @@ func testPeer(protos []Protocol) (func(), *conn, *Peer, <-chan error) {
 	peer := newPeer(log.Root(), c1, protos)
 	errc := make(chan error, 1)
 	go func() {
-		_, err := peer.run()
+		_, _, err := peer.run()
 		errc <- err
 	}()
 
@@ -133,6 +133,80 @@ func TestPeerPing(t *testing.T) {
 	}
 }
 
+func TestPeerStatsMessages(t *testing.T) {
+	done := make(chan struct{})
+	proto := Protocol{
+		Name:   "a",
+		Length: 5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			if err := SendItems(rw, 3, "foo"); err != nil {
+				t.Error(err)
+			}
+			<-done
+			return nil
+		},
+	}
+	closer, rw, peer, _ := testPeer([]Protocol{proto})
+	defer closer()
+	defer close(done)
+
+	if err := Send(rw, baseProtocolLength+2, []uint{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectMsg(rw, baseProtocolLength+3, []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	// The reply is accounted after the write completes, wait for it.
+	var stats *PeerStats
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stats = peer.Stats(); stats.Messages["a"] != nil && stats.Messages["a"].Out == 1 {
+			break
+		}
+	}
+	if c := stats.Messages["a"]; c == nil || c.In != 1 || c.Out != 1 {
+		t.Fatalf("wrong message counts for protocol a: %+v", c)
+	}
+	if stats.BytesIn == 0 || stats.BytesOut == 0 {
+		t.Fatalf("traffic not accounted: in %d, out %d", stats.BytesIn, stats.BytesOut)
+	}
+	if stats.Age <= 0 {
+		t.Fatalf("non-positive connection age %v", stats.Age)
+	}
+}
+
+func TestPeerStatsRTT(t *testing.T) {
+	closer, rw, peer, _ := testPeer(nil)
+	defer closer()
+
+	errc := make(chan error, 1)
+	go func() { errc <- peer.sendPing() }()
+	if err := ExpectMsg(rw, pingMsg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := SendItems(rw, pongMsg); err != nil {
+		t.Fatal(err)
+	}
+	var stats *PeerStats
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stats = peer.Stats(); stats.RTT > 0 {
+			break
+		}
+	}
+	if stats.RTT < 10 {
+		t.Fatalf("RTT too low: %vms", stats.RTT)
+	}
+	if c := stats.Messages[baseProtocolName]; c == nil || c.In != 1 || c.Out != 1 {
+		t.Fatalf("wrong base protocol message counts: %+v", c)
+	}
+}
+
 func TestPeerDisconnect(t *testing.T) {
 	closer, rw, _, disc := testPeer(nil)
 	defer closer()
@@ -0,0 +1,208 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Incident is a kind of misbehaviour of a remote node, lowering its reputation.
+type Incident int
+
+const (
+	// IncidentInvalidBlock is reported when a node sends a block which fails
+	// validation, e.g. because of an invalid proof-of-work.
+	IncidentInvalidBlock Incident = iota
+
+	// IncidentUselessData is reported when a node sends invalid or unusable
+	// chain data while syncing.
+	IncidentUselessData
+
+	// IncidentProtocolViolation is reported when a node sends a malformed or
+	// unexpected protocol message.
+	IncidentProtocolViolation
+
+	// IncidentHandshakeFailure is reported when a node fails the devp2p
+	// protocol handshake.
+	IncidentHandshakeFailure
+)
+
+var incidentNames = map[Incident]string{
+	IncidentInvalidBlock:      "invalid block",
+	IncidentUselessData:       "useless data",
+	IncidentProtocolViolation: "protocol violation",
+	IncidentHandshakeFailure:  "handshake failure",
+}
+
+func (i Incident) String() string {
+	if name, ok := incidentNames[i]; ok {
+		return name
+	}
+	return fmt.Sprintf("incident %d", int(i))
+}
+
+// incidentPenalties is the penalty a node receives for each kind of incident.
+// Nodes are refused while their penalty is above banThreshold, and penalties
+// decay by one per Config.PenaltyDuration. A single invalid block thus bans a
+// node for the penalty duration, while the other incidents only lead to a ban
+// if they are repeated in short succession.
+var incidentPenalties = map[Incident]float64{
+	IncidentInvalidBlock:      2,
+	IncidentUselessData:       1,
+	IncidentProtocolViolation: 1,
+	IncidentHandshakeFailure:  0.25,
+}
+
+const banThreshold = 1
+
+var errPenaltiesDisabled = errors.New("peer penalties are disabled")
+
+// Reputation describes the standing of a remote node.
+type Reputation struct {
+	Penalty     float64    `json:"penalty"`               // Current penalty score, decays over time
+	Banned      bool       `json:"banned"`                // Whether connections to the node are refused
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"` // When the ban ends if no further incidents happen
+	Updated     *time.Time `json:"updated,omitempty"`     // Time of the last incident
+}
+
+// reputationStore tracks the penalties of misbehaving nodes in the node
+// database, so they are remembered across restarts. A nil store is valid and
+// never bans any node.
+type reputationStore struct {
+	db       *enode.DB
+	duration time.Duration    // Time it takes for a penalty of one to decay
+	now      func() time.Time // Wall clock, overridden in tests
+	mu       sync.Mutex       // Serializes updates of the stored penalties
+}
+
+// newReputationStore creates a store using the given node database. It returns
+// nil if penalties are disabled.
+func newReputationStore(db *enode.DB, duration time.Duration) *reputationStore {
+	if duration <= 0 {
+		return nil
+	}
+	return &reputationStore{db: db, duration: duration, now: time.Now}
+}
+
+// decay returns the penalty remaining of a penalty assigned at updated.
+func (rs *reputationStore) decay(penalty float64, updated, now time.Time) float64 {
+	if elapsed := now.Sub(updated); elapsed > 0 {
+		penalty -= float64(elapsed) / float64(rs.duration)
+	}
+	if penalty < 0 {
+		penalty = 0
+	}
+	return penalty
+}
+
+// penalty returns the current penalty of a node, deleting the stored entry once
+// it has decayed entirely. The lock must be held.
+func (rs *reputationStore) penalty(id enode.ID, now time.Time) (float64, time.Time) {
+	stored, updated := rs.db.Reputation(id)
+	if updated.IsZero() {
+		return 0, updated
+	}
+	penalty := rs.decay(stored, updated, now)
+	if penalty == 0 {
+		rs.db.DeleteReputation(id)
+		return 0, time.Time{}
+	}
+	return penalty, updated
+}
+
+// report records an incident of a node.
+func (rs *reputationStore) report(id enode.ID, incident Incident) Reputation {
+	if rs == nil {
+		return Reputation{}
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := rs.now()
+	penalty, _ := rs.penalty(id, now)
+	penalty += incidentPenalties[incident]
+	rs.db.UpdateReputation(id, penalty, now)
+	return rs.makeReputation(penalty, now)
+}
+
+// ban raises the penalty of a node so that it's banned for at least the given
+// duration.
+func (rs *reputationStore) ban(id enode.ID, duration time.Duration) (Reputation, error) {
+	if rs == nil {
+		return Reputation{}, errPenaltiesDisabled
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := rs.now()
+	penalty, _ := rs.penalty(id, now)
+	if min := banThreshold + float64(duration)/float64(rs.duration); penalty < min {
+		penalty = min
+	}
+	if err := rs.db.UpdateReputation(id, penalty, now); err != nil {
+		return Reputation{}, err
+	}
+	return rs.makeReputation(penalty, now), nil
+}
+
+// reputation returns the current reputation of a node.
+func (rs *reputationStore) reputation(id enode.ID) Reputation {
+	if rs == nil {
+		return Reputation{}
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := rs.now()
+	penalty, updated := rs.penalty(id, now)
+	if updated.IsZero() {
+		return Reputation{}
+	}
+	rep := rs.makeReputation(penalty, now)
+	rep.Updated = &updated
+	return rep
+}
+
+func (rs *reputationStore) makeReputation(penalty float64, now time.Time) Reputation {
+	rep := Reputation{Penalty: penalty, Banned: penalty > banThreshold}
+	if rep.Banned {
+		until := now.Add(time.Duration((penalty - banThreshold) * float64(rs.duration)))
+		rep.BannedUntil = &until
+	}
+	return rep
+}
+
+// banned reports whether connections to the node should be refused.
+func (rs *reputationStore) banned(id enode.ID) bool {
+	return rs.reputation(id).Banned
+}
+
+// clear forgets all incidents of a node.
+func (rs *reputationStore) clear(id enode.ID) error {
+	if rs == nil {
+		return nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.db.DeleteReputation(id)
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestReputationDecay(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	var (
+		rs  = newReputationStore(db, time.Hour)
+		now = time.Unix(1000000, 0)
+		id  = randomID()
+	)
+	rs.now = func() time.Time { return now }
+
+	check := func(desc string, penalty float64, banned bool) {
+		t.Helper()
+		rep := rs.reputation(id)
+		if rep.Penalty != penalty || rep.Banned != banned {
+			t.Errorf("%s: have penalty %v banned %t, want penalty %v banned %t", desc, rep.Penalty, rep.Banned, penalty, banned)
+		}
+	}
+	// A single invalid block bans for the penalty duration.
+	rep := rs.report(id, IncidentInvalidBlock)
+	if !rep.Banned || !rep.BannedUntil.Equal(now.Add(time.Hour)) {
+		t.Fatalf("invalid block: wrong ban %+v", rep)
+	}
+	now = now.Add(30 * time.Minute)
+	check("half decayed", 1.5, true)
+	now = now.Add(30 * time.Minute)
+	check("ban expired", 1, false)
+	now = now.Add(time.Hour)
+	check("fully decayed", 0, false)
+	if _, updated := db.Reputation(id); !updated.IsZero() {
+		t.Error("decayed entry not deleted")
+	}
+	// Handshake failures only ban if repeated.
+	for i := 0; i < 4; i++ {
+		rs.report(id, IncidentHandshakeFailure)
+	}
+	check("four handshake failures", 1, false)
+	rs.report(id, IncidentHandshakeFailure)
+	check("five handshake failures", 1.25, true)
+
+	if err := rs.clear(id); err != nil {
+		t.Fatal(err)
+	}
+	check("cleared", 0, false)
+
+	// Explicit bans last for the requested duration, without lowering penalties.
+	rep, err := rs.ban(id, 90*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Banned || !rep.BannedUntil.Equal(now.Add(90*time.Minute)) {
+		t.Fatalf("explicit ban: wrong ban %+v", rep)
+	}
+	if rep, _ = rs.ban(id, time.Minute); !rep.BannedUntil.Equal(now.Add(90 * time.Minute)) {
+		t.Fatalf("shorter ban lowered penalty: %+v", rep)
+	}
+	now = now.Add(90 * time.Minute)
+	check("explicit ban expired", 1, false)
+
+	if _, err := (*reputationStore)(nil).ban(id, time.Minute); err != errPenaltiesDisabled {
+		t.Errorf("ban without penalties: have error %v, want %v", err, errPenaltiesDisabled)
+	}
+}
+
+// This test checks that penalties are kept across restarts and consulted for
+// inbound and outbound connections.
+func TestReputationPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-reputation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		bad       = newkey()
+		badID     = enode.PubkeyToIDV4(&bad.PublicKey)
+		trustedID = randomID()
+		config    = Config{
+			PrivateKey:      newkey(),
+			MaxPeers:        10,
+			NoDial:          true,
+			NoDiscovery:     true,
+			NodeDatabase:    filepath.Join(dir, "nodes"),
+			PenaltyDuration: time.Hour,
+			TrustedNodes:    []*enode.Node{newNode(trustedID, nil)},
+		}
+	)
+	srv := &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	srv.reputation.report(badID, IncidentInvalidBlock)
+	srv.reputation.report(trustedID, IncidentInvalidBlock)
+	srv.Stop()
+
+	// Restart on the same database, the bans must still be in place.
+	srv = &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not restart: %v", err)
+	}
+	defer srv.Stop()
+
+	if rep := srv.PeerReputation(badID); !rep.Banned {
+		t.Fatalf("ban lost across restart: %+v", rep)
+	}
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: newTestTransport(&bad.PublicKey, fd), flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	if err := srv.checkpoint(newconn(badID), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Errorf("banned inbound connection: have error %v, want %v", err, DiscUselessPeer)
+	}
+	if err := srv.checkpoint(newconn(trustedID), srv.checkpointPostHandshake); err != nil {
+		t.Errorf("banned trusted connection refused: %v", err)
+	}
+	dialer := newDialState(srv.localnode.ID(), 10, &srv.Config)
+	dialer.reputation = srv.reputation
+	if err := dialer.checkDial(newNode(badID, net.IP{127, 0, 0, 1}), nil); err != errBannedNode {
+		t.Errorf("banned dial candidate: have error %v, want %v", err, errBannedNode)
+	}
+	// Clearing the reputation lifts the ban.
+	if err := srv.ClearReputation(badID); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.checkpoint(newconn(badID), srv.checkpointPostHandshake); err != nil {
+		t.Errorf("cleared inbound connection refused: %v", err)
+	}
+	if err := dialer.checkDial(newNode(badID, net.IP{127, 0, 0, 1}), nil); err != nil {
+		t.Errorf("cleared dial candidate refused: %v", err)
+	}
+}
@@ -47,6 +47,13 @@ import (
 const (
 	maxUint24 = ^uint32(0) >> 8
 
+	// snappyCompressThreshold is the payload size below which messages sent to
+	// snappy capable peers are not compressed but stored as a single literal in
+	// the snappy block format. Small messages barely shrink, so this saves the
+	// compression work without breaking peers which expect snappy encoding.
+	// It must not exceed 256, the largest literal length held in one byte.
+	snappyCompressThreshold = 256
+
 	sskLen = 16                     // ecies.MaxSharedKeyLength(pubKey) / 2
 	sigLen = crypto.SignatureLength // elliptic S256
 	pubLen = 64                     // 512 bit pubkey in uncompressed representation without format byte
@@ -135,8 +142,8 @@ func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err
 	if err := <-werr; err != nil {
 		return nil, fmt.Errorf("write error: %v", err)
 	}
-	// If the protocol version supports Snappy encoding, upgrade immediately
-	t.rw.snappy = their.Version >= snappyProtocolVersion
+	// If both protocol versions support Snappy encoding, upgrade immediately
+	t.rw.snappy = our.Version >= snappyProtocolVersion && their.Version >= snappyProtocolVersion
 
 	return their, nil
 }
@@ -583,18 +590,21 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 	ptype, _ := rlp.EncodeToBytes(msg.Code)
 
 	// if snappy is enabled, compress message now
+	logicalSize := msg.Size
 	if rw.snappy {
 		if msg.Size > maxUint24 {
 			return errPlainMessageTooLarge
 		}
 		payload, _ := ioutil.ReadAll(msg.Payload)
-		payload = snappy.Encode(nil, payload)
+		payload = snappyEncode(payload)
 
 		msg.Payload = bytes.NewReader(payload)
 		msg.Size = uint32(len(payload))
 	}
 	msg.meterSize = msg.Size
-	if metrics.Enabled && msg.meterCap.Name != "" { // don't meter non-subprotocol messages
+	egressPayloadWireMeter.Mark(int64(msg.meterSize))
+	egressPayloadLogicalMeter.Mark(int64(logicalSize))
+	if metrics.Enabled() && msg.meterCap.Name != "" { // don't meter non-subprotocol messages
 		metrics.GetOrRegisterMeter(fmt.Sprintf("%s/%s/%d/%#02x", MetricsOutboundTraffic, msg.meterCap.Name, msg.meterCap.Version, msg.meterCode), nil).Mark(int64(msg.meterSize))
 	}
 	// write header
@@ -703,9 +713,36 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 		}
 		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
 	}
+	ingressPayloadWireMeter.Mark(int64(msg.meterSize))
+	ingressPayloadLogicalMeter.Mark(int64(msg.Size))
 	return msg, nil
 }
 
+// snappyEncode encodes a message payload in the snappy block format, compressing
+// it only if it is at least snappyCompressThreshold bytes long.
+func snappyEncode(payload []byte) []byte {
+	if len(payload) >= snappyCompressThreshold {
+		return snappy.Encode(nil, payload)
+	}
+	// Store the payload as one literal: the varint encoded length of the block,
+	// followed by the literal tag and the payload itself.
+	n := len(payload)
+	buf := make([]byte, binary.MaxVarintLen32+2+n)
+	i := binary.PutUvarint(buf, uint64(n))
+	switch {
+	case n == 0:
+		return buf[:i]
+	case n <= 60:
+		buf[i] = byte(n-1) << 2
+		i++
+	default:
+		buf[i], buf[i+1] = 60<<2, byte(n-1)
+		i += 2
+	}
+	i += copy(buf[i:], payload)
+	return buf[:i]
+}
+
 // updateMAC reseeds the given hash with encrypted seed.
 // it returns the first 16 bytes of the hash sum after seeding.
 func updateMAC(mac hash.Hash, block cipher.Block, seed []byte) []byte {
@@ -20,10 +20,12 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	mrand "math/rand"
 	"net"
 	"reflect"
 	"strings"
@@ -32,10 +34,12 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -318,7 +322,9 @@ func (fakeHash) BlockSize() int              { return 0 }
 func (h fakeHash) Size() int           { return len(h) }
 func (h fakeHash) Sum(b []byte) []byte { return append(b, h...) }
 
-func TestRLPXFrameRW(t *testing.T) {
+// newTestFrameRWPair creates two frame readers/writers with matching secrets,
+// operating on the same connection.
+func newTestFrameRWPair(conn io.ReadWriter) (*rlpxFrameRW, *rlpxFrameRW) {
 	var (
 		aesSecret      = make([]byte, 16)
 		macSecret      = make([]byte, 16)
@@ -328,8 +334,6 @@ func TestRLPXFrameRW(t *testing.T) {
 	for _, s := range [][]byte{aesSecret, macSecret, egressMACinit, ingressMACinit} {
 		rand.Read(s)
 	}
-	conn := new(bytes.Buffer)
-
 	s1 := secrets{
 		AES:        aesSecret,
 		MAC:        macSecret,
@@ -338,7 +342,6 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 	s1.EgressMAC.Write(egressMACinit)
 	s1.IngressMAC.Write(ingressMACinit)
-	rw1 := newRLPXFrameRW(conn, s1)
 
 	s2 := secrets{
 		AES:        aesSecret,
@@ -348,7 +351,13 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 	s2.EgressMAC.Write(ingressMACinit)
 	s2.IngressMAC.Write(egressMACinit)
-	rw2 := newRLPXFrameRW(conn, s2)
+
+	return newRLPXFrameRW(conn, s1), newRLPXFrameRW(conn, s2)
+}
+
+func TestRLPXFrameRW(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newTestFrameRWPair(conn)
 
 	// send some messages
 	for i := 0; i < 10; i++ {
@@ -375,6 +384,166 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 }
 
+func TestRLPXFrameRWSnappy(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newTestFrameRWPair(conn)
+	rw1.snappy, rw2.snappy = true, true
+
+	sizes := []int{0, 1, 60, 61, 200, snappyCompressThreshold - 1, snappyCompressThreshold, 4096, 1 << 20}
+	for _, size := range sizes {
+		payload := bytes.Repeat([]byte{0x42}, size)
+		if err := rw1.WriteMsg(Msg{Code: 8, Size: uint32(size), Payload: bytes.NewReader(payload)}); err != nil {
+			t.Fatalf("size %d: WriteMsg error: %v", size, err)
+		}
+		msg, err := rw2.ReadMsg()
+		if err != nil {
+			t.Fatalf("size %d: ReadMsg error: %v", size, err)
+		}
+		// Payloads below the threshold are stored, larger ones compressed.
+		switch {
+		case size < snappyCompressThreshold && msg.meterSize < uint32(size):
+			t.Errorf("size %d: payload compressed to %d bytes, want stored", size, msg.meterSize)
+		case size >= snappyCompressThreshold && msg.meterSize >= uint32(size):
+			t.Errorf("size %d: payload not compressed (%d bytes on wire)", size, msg.meterSize)
+		}
+		if msg.Code != 8 || msg.Size != uint32(size) {
+			t.Errorf("size %d: got code %d size %d", size, msg.Code, msg.Size)
+		}
+		have, _ := ioutil.ReadAll(msg.Payload)
+		if !bytes.Equal(have, payload) {
+			t.Errorf("size %d: payload mismatch", size)
+		}
+	}
+}
+
+func TestSnappyEncodeStored(t *testing.T) {
+	for size := 0; size < snappyCompressThreshold; size++ {
+		payload := make([]byte, size)
+		rand.Read(payload)
+		enc := snappyEncode(payload)
+		dec, err := snappy.Decode(nil, enc)
+		if err != nil {
+			t.Fatalf("size %d: decode error: %v", size, err)
+		}
+		if !bytes.Equal(dec, payload) {
+			t.Fatalf("size %d: payload mismatch", size)
+		}
+	}
+}
+
+// This test checks that malformed compressed frames from snappy peers are
+// rejected without crashing the reader.
+func TestRLPXFrameSnappyMalformed(t *testing.T) {
+	// Frames are written uncompressed, so the reader sees the raw payload as
+	// snappy data.
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newTestFrameRWPair(conn)
+	rw2.snappy = true
+
+	send := func(payload []byte) (Msg, error) {
+		if err := rw1.WriteMsg(Msg{Code: 1, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)}); err != nil {
+			t.Fatalf("WriteMsg error: %v", err)
+		}
+		return rw2.ReadMsg()
+	}
+	// A decoded length above the message size limit must be refused before
+	// anything is allocated.
+	bomb := make([]byte, binary.MaxVarintLen32)
+	bomb = bomb[:binary.PutUvarint(bomb, uint64(maxUint24)+1)]
+	if _, err := send(bomb); err != errPlainMessageTooLarge {
+		t.Errorf("oversized decoded length: got error %v, want %v", err, errPlainMessageTooLarge)
+	}
+	// Corrupt valid compressed payloads at random. Decoding may or may not
+	// fail, but must never panic or return more data than announced.
+	valid := snappy.Encode(nil, bytes.Repeat([]byte("corrupt me "), 100))
+	for i := 0; i < 1000; i++ {
+		payload := common.CopyBytes(valid)
+		for j := mrand.Intn(8); j >= 0; j-- {
+			payload[mrand.Intn(len(payload))] = byte(mrand.Intn(256))
+		}
+		if mrand.Intn(4) == 0 {
+			payload = payload[:mrand.Intn(len(payload))]
+		}
+		msg, err := send(payload)
+		if err != nil {
+			continue
+		}
+		if have, _ := ioutil.ReadAll(msg.Payload); uint32(len(have)) != msg.Size {
+			t.Fatalf("decoded %d bytes, message size is %d", len(have), msg.Size)
+		}
+	}
+}
+
+// This test checks that compression is enabled only when both sides of a
+// connection support it, and that messages pass in either case.
+func TestProtocolHandshakeSnappy(t *testing.T) {
+	tests := []struct {
+		version0, version1 uint64
+		snappy             bool
+	}{
+		{version0: 4, version1: 4, snappy: false},
+		{version0: 4, version1: snappyProtocolVersion, snappy: false},
+		{version0: snappyProtocolVersion, version1: 4, snappy: false},
+		{version0: snappyProtocolVersion, version1: snappyProtocolVersion, snappy: true},
+	}
+	payload := bytes.Repeat([]byte("block body "), 1000)
+
+	for i, test := range tests {
+		var (
+			prv0, _ = crypto.GenerateKey()
+			prv1, _ = crypto.GenerateKey()
+			hs0     = &protoHandshake{Version: test.version0, ID: crypto.FromECDSAPub(&prv0.PublicKey)[1:]}
+			hs1     = &protoHandshake{Version: test.version1, ID: crypto.FromECDSAPub(&prv1.PublicKey)[1:]}
+			wg      sync.WaitGroup
+		)
+		fd0, fd1, err := pipes.TCPPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := func(fd net.Conn, prv *ecdsa.PrivateKey, dest *ecdsa.PublicKey, hs *protoHandshake) {
+			defer wg.Done()
+			defer fd.Close()
+
+			c := newRLPX(fd).(*rlpx)
+			if _, err := c.doEncHandshake(prv, dest); err != nil {
+				t.Errorf("test %d: enc handshake failed: %v", i, err)
+				return
+			}
+			if _, err := c.doProtoHandshake(hs); err != nil {
+				t.Errorf("test %d: proto handshake failed: %v", i, err)
+				return
+			}
+			if c.rw.snappy != test.snappy {
+				t.Errorf("test %d: snappy mismatch: got %t, want %t", i, c.rw.snappy, test.snappy)
+			}
+			werr := make(chan error, 1)
+			go func() {
+				werr <- c.WriteMsg(Msg{Code: 16, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+			}()
+			defer func() {
+				if err := <-werr; err != nil {
+					t.Errorf("test %d: WriteMsg error: %v", i, err)
+				}
+			}()
+			msg, err := c.ReadMsg()
+			if err != nil {
+				t.Errorf("test %d: ReadMsg error: %v", i, err)
+				return
+			}
+			if have, _ := ioutil.ReadAll(msg.Payload); !bytes.Equal(have, payload) {
+				t.Errorf("test %d: payload mismatch", i)
+			}
+			if compressed := msg.meterSize < msg.Size; compressed != test.snappy {
+				t.Errorf("test %d: got %d bytes on wire for %d byte payload", i, msg.meterSize, msg.Size)
+			}
+		}
+		wg.Add(2)
+		go run(fd0, prv0, &prv1.PublicKey, hs0)
+		go run(fd1, prv1, nil, hs1)
+		wg.Wait()
+	}
+}
+
 type handshakeAuthTest struct {
 	input       string
 	isPlain     bool
@@ -33,9 +33,12 @@ import (
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/validate"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -75,17 +78,28 @@ type Config struct {
 
 	// MaxPeers is the maximum number of peers that can be
 	// connected. It must be greater than zero.
-	MaxPeers int
+	MaxPeers int `validate:"min=0" reload:"true"`
 
 	// MaxPendingPeers is the maximum number of peers that can be pending in the
 	// handshake phase, counted separately for inbound and outbound connections.
 	// Zero defaults to preset values.
-	MaxPendingPeers int `toml:",omitempty"`
+	MaxPendingPeers int `toml:",omitempty" validate:"min=0"`
 
 	// DialRatio controls the ratio of inbound to dialed connections.
 	// Example: a DialRatio of 2 allows 1/2 of connections to be dialed.
 	// Setting DialRatio to zero defaults it to 3.
-	DialRatio int `toml:",omitempty"`
+	DialRatio int `toml:",omitempty" validate:"min=0"`
+
+	// MaxPeersPerIP and MaxPeersPerSubnet limit the number of inbound connections
+	// accepted from a single IP address and from a single /24 (IPv4) or /48 (IPv6)
+	// subnet. Connections from LAN addresses are exempt. Zero means no limit.
+	MaxPeersPerIP     int `toml:",omitempty" validate:"min=0"`
+	MaxPeersPerSubnet int `toml:",omitempty" validate:"min=0"`
+
+	// InboundCooldown is the time a node has to wait after a disconnect before
+	// it is accepted as an inbound peer again. Trusted nodes are exempt. Zero
+	// disables the cooldown.
+	InboundCooldown time.Duration `toml:",omitempty" validate:"min=0"`
 
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
@@ -108,6 +122,15 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// DiscoveryURLs contains enrtree:// URLs of DNS-based node lists (EIP-1459).
+	// Nodes found in these lists are used as dial candidates alongside the
+	// ones found by the discovery protocol.
+	DiscoveryURLs []string `toml:",omitempty"`
+
+	// DNSResolver is used to resolve the TXT records of DNS node lists. If nil,
+	// the system resolver is used.
+	DNSResolver dnsdisc.Resolver `toml:"-"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*enode.Node
@@ -125,6 +148,12 @@ type Config struct {
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// PenaltyDuration is the time a node is refused after a severe incident, such
+	// as sending a block with invalid proof-of-work. Lesser incidents lead to a ban
+	// only if they are repeated. Penalties are stored in the node database, zero
+	// disables them.
+	PenaltyDuration time.Duration `toml:",omitempty" validate:"min=0"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -136,7 +165,7 @@ type Config struct {
 	// If the port is zero, the operating system will pick a port. The
 	// ListenAddr field will be updated with the actual address when
 	// the server is started.
-	ListenAddr string
+	ListenAddr string `validate:"omitempty,addr"`
 
 	// If set to a non-nil value, the given NAT port mapper
 	// is used to make the listening port available to the
@@ -158,6 +187,12 @@ type Config struct {
 	Logger log.Logger `toml:",omitempty"`
 }
 
+// Validate checks the configuration against the constraints declared in the
+// validate tags of its fields.
+func (cfg *Config) Validate() error {
+	return validate.Struct("Config", cfg)
+}
+
 // Server manages all peer connections.
 type Server struct {
 	// Config fields may not be modified while the server is running.
@@ -172,20 +207,32 @@ type Server struct {
 	lock    sync.Mutex // protects running
 	running bool
 
-	listener     net.Listener
+	listener net.Listener
+	loopWG   sync.WaitGroup // loop, listenLoop
+	peerFeed event.Feed
+	log      log.Logger
+
+	// Protocols currently offered to new connections. The slice and handshake are
+	// replaced, never modified, when protocols are added or removed at runtime.
+	protoLock    sync.RWMutex
+	protocols    []Protocol
 	ourHandshake *protoHandshake
-	loopWG       sync.WaitGroup // loop, listenLoop
-	peerFeed     event.Feed
-	log          log.Logger
+	dialSources  map[string]bool // protocol names with a discovery source in discmix
 
-	nodedb    *enode.DB
-	localnode *enode.LocalNode
-	ntab      *discover.UDPv4
-	DiscV5    *discv5.Network
-	discmix   *enode.FairMix
+	nodedb     *enode.DB
+	reputation *reputationStore
+	localnode  *enode.LocalNode
+	ntab       *discover.UDPv4
+	DiscV5     *discv5.Network
+	discmix    *enode.FairMix
+	dnsdisc    *dnsdisc.Client
 
 	staticNodeResolver nodeResolver
 
+	peerSetLock sync.Mutex               // protects staticSet, trustedSet
+	staticSet   map[enode.ID]*enode.Node // Static nodes, from config, database and runtime additions
+	trustedSet  map[enode.ID]*enode.Node // Trusted nodes, from config, database and runtime additions
+
 	// Channels into the run loop.
 	quit                    chan struct{}
 	addstatic               chan *enode.Node
@@ -194,12 +241,26 @@ type Server struct {
 	removetrusted           chan *enode.Node
 	peerOp                  chan peerOpFunc
 	peerOpDone              chan struct{}
+	setMaxPeers             chan int
+	setNetRestrict          chan netRestrictOp
 	delpeer                 chan peerDrop
 	checkpointPostHandshake chan *conn
 	checkpointAddPeer       chan *conn
 
 	// State of run loop and listenLoop.
+	maxPeers       int32 // peer limit in effect, changeable at runtime, accessed atomically
+	dialDemand     int32 // free dynamic dial slots, accessed atomically
 	inboundHistory expHeap
+	inbound        *inboundTracker
+
+	// Connection restrictions, changeable at runtime.
+	restrictLock sync.Mutex
+	restrict     NetRestriction
+
+	// Port mapping state.
+	natLock     sync.Mutex
+	natExtIP    net.IP
+	natMappings []*nat.Mapping
 }
 
 type peerOpFunc func(map[enode.ID]*Peer)
@@ -229,6 +290,8 @@ type conn struct {
 	cont  chan error // The run loop uses cont to signal errors to SetupConn.
 	caps  []Cap      // valid after the protocol handshake
 	name  string     // valid after the protocol handshake
+
+	protocols []Protocol // protocols offered in the protocol handshake
 }
 
 type transport interface {
@@ -317,6 +380,56 @@ func (srv *Server) Peers() []*Peer {
 	return ps
 }
 
+// NetRestriction holds the IP networks connections are restricted to. Inbound
+// applies to accepted connections, Outbound to dialed connections and to nodes
+// kept by discovery. A nil list doesn't restrict connections.
+type NetRestriction struct {
+	Inbound  *netutil.Netlist
+	Outbound *netutil.Netlist
+}
+
+type netRestrictOp struct {
+	restrict NetRestriction
+	drop     bool
+}
+
+// NetRestriction returns the IP networks connections are currently restricted
+// to. Initially, both directions are restricted to Config.NetRestrict.
+func (srv *Server) NetRestriction() NetRestriction {
+	srv.restrictLock.Lock()
+	defer srv.restrictLock.Unlock()
+	return srv.restrict
+}
+
+// SetNetRestriction changes the IP networks connections are restricted to while
+// the server is running. The restriction applies to new connections, existing
+// peers outside of the networks are only disconnected if drop is set.
+func (srv *Server) SetNetRestriction(r NetRestriction, drop bool) {
+	select {
+	case srv.setNetRestrict <- netRestrictOp{r, drop}:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+}
+
+// SetMaxPeers changes the maximum number of connected peers while the server is
+// running. Lowering the limit doesn't disconnect any peers, but no new peers are
+// accepted until the peer count drops below the new limit. The configured
+// MaxPeers is left untouched.
+func (srv *Server) SetMaxPeers(n int) {
+	select {
+	case srv.setMaxPeers <- n:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+}
+
+// PeerLimit returns the maximum number of peers currently in effect, which is
+// the configured MaxPeers unless changed by SetMaxPeers.
+func (srv *Server) PeerLimit() int {
+	return int(atomic.LoadInt32(&srv.maxPeers))
+}
+
 // PeerCount returns the number of connected peers.
 func (srv *Server) PeerCount() int {
 	var count int
@@ -330,7 +443,8 @@ func (srv *Server) PeerCount() int {
 
 // AddPeer connects to the given node and maintains the connection until the
 // server is shut down. If the connection fails for any reason, the server will
-// attempt to reconnect the peer.
+// attempt to reconnect the peer. The node is persisted in the node database and
+// is also dialed after a restart.
 func (srv *Server) AddPeer(node *enode.Node) {
 	select {
 	case srv.addstatic <- node:
@@ -338,7 +452,8 @@ func (srv *Server) AddPeer(node *enode.Node) {
 	}
 }
 
-// RemovePeer disconnects from the given node
+// RemovePeer disconnects from the given node and removes it from the static
+// peer set, including the persisted one.
 func (srv *Server) RemovePeer(node *enode.Node) {
 	select {
 	case srv.removestatic <- node:
@@ -347,7 +462,8 @@ func (srv *Server) RemovePeer(node *enode.Node) {
 }
 
 // AddTrustedPeer adds the given node to a reserved whitelist which allows the
-// node to always connect, even if the slot are full.
+// node to always connect, even if the slot are full. The node is persisted in
+// the node database and remains trusted after a restart.
 func (srv *Server) AddTrustedPeer(node *enode.Node) {
 	select {
 	case srv.addtrusted <- node:
@@ -355,7 +471,8 @@ func (srv *Server) AddTrustedPeer(node *enode.Node) {
 	}
 }
 
-// RemoveTrustedPeer removes the given node from the trusted peer set.
+// RemoveTrustedPeer removes the given node from the trusted peer set, including
+// the persisted one. An existing connection to the node is kept.
 func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
 	select {
 	case srv.removetrusted <- node:
@@ -459,15 +576,21 @@ func (srv *Server) Start() (err error) {
 	srv.checkpointPostHandshake = make(chan *conn)
 	srv.checkpointAddPeer = make(chan *conn)
 	srv.addstatic = make(chan *enode.Node)
+	srv.setMaxPeers = make(chan int)
+	atomic.StoreInt32(&srv.maxPeers, int32(srv.MaxPeers))
+	srv.setNetRestrict = make(chan netRestrictOp)
+	srv.restrict = NetRestriction{Inbound: srv.NetRestrict, Outbound: srv.NetRestrict}
 	srv.removestatic = make(chan *enode.Node)
 	srv.addtrusted = make(chan *enode.Node)
 	srv.removetrusted = make(chan *enode.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.inbound = newInboundTracker()
 
 	if err := srv.setupLocalNode(); err != nil {
 		return err
 	}
+	srv.reputation = newReputationStore(srv.nodedb, srv.PenaltyDuration)
 	if srv.ListenAddr != "" {
 		if err := srv.setupListening(); err != nil {
 			return err
@@ -477,21 +600,83 @@ func (srv *Server) Start() (err error) {
 		return err
 	}
 
+	srv.loadPeerSets()
+
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.localnode.ID(), dynPeers, &srv.Config)
+	dialer.reputation = srv.reputation
+	for _, n := range srv.nodedb.StaticNodes() {
+		dialer.addStatic(srv.staticSet[n.ID()])
+	}
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
 }
 
+// loadPeerSets assembles the static and trusted peer sets from the persisted
+// ones in the node database and the configured ones, the latter taking precedence.
+func (srv *Server) loadPeerSets() {
+	srv.peerSetLock.Lock()
+	defer srv.peerSetLock.Unlock()
+
+	srv.staticSet = make(map[enode.ID]*enode.Node)
+	for _, n := range srv.nodedb.StaticNodes() {
+		srv.staticSet[n.ID()] = n
+	}
+	for _, n := range srv.StaticNodes {
+		srv.staticSet[n.ID()] = n
+	}
+	srv.trustedSet = make(map[enode.ID]*enode.Node)
+	for _, n := range srv.nodedb.TrustedNodes() {
+		srv.trustedSet[n.ID()] = n
+	}
+	for _, n := range srv.TrustedNodes {
+		srv.trustedSet[n.ID()] = n
+	}
+}
+
+// updateStaticSet adds or removes a node from the static peer set and the
+// persisted one.
+func (srv *Server) updateStaticSet(n *enode.Node, add bool) {
+	srv.peerSetLock.Lock()
+	defer srv.peerSetLock.Unlock()
+
+	var err error
+	if add {
+		srv.staticSet[n.ID()] = n
+		err = srv.nodedb.AddStaticNode(n)
+	} else {
+		delete(srv.staticSet, n.ID())
+		err = srv.nodedb.RemoveStaticNode(n.ID())
+	}
+	if err != nil {
+		srv.log.Warn("Failed to persist static node", "id", n.ID(), "err", err)
+	}
+}
+
+// updateTrustedSet adds or removes a node from the trusted peer set and the
+// persisted one.
+func (srv *Server) updateTrustedSet(n *enode.Node, add bool) {
+	srv.peerSetLock.Lock()
+	defer srv.peerSetLock.Unlock()
+
+	var err error
+	if add {
+		srv.trustedSet[n.ID()] = n
+		err = srv.nodedb.AddTrustedNode(n)
+	} else {
+		delete(srv.trustedSet, n.ID())
+		err = srv.nodedb.RemoveTrustedNode(n.ID())
+	}
+	if err != nil {
+		srv.log.Warn("Failed to persist trusted node", "id", n.ID(), "err", err)
+	}
+}
+
 func (srv *Server) setupLocalNode() error {
 	// Create the devp2p handshake.
-	pubkey := crypto.FromECDSAPub(&srv.PrivateKey.PublicKey)
-	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: pubkey[1:]}
-	for _, p := range srv.Protocols {
-		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
-	}
-	sort.Sort(capsByNameAndVersion(srv.ourHandshake.Caps))
+	srv.protocols = append([]Protocol(nil), srv.Protocols...)
+	srv.ourHandshake = srv.makeHandshake(srv.protocols)
 
 	// Create the local node.
 	db, err := enode.OpenDB(srv.Config.NodeDatabase)
@@ -514,6 +699,7 @@ func (srv *Server) setupLocalNode() error {
 		// ExtIP doesn't block, set the IP right away.
 		ip, _ := srv.NAT.ExternalIP()
 		srv.localnode.SetStaticIP(ip)
+		srv.setNATExternalIP(ip)
 	default:
 		// Ask the router about the IP. This takes a while and blocks startup,
 		// do it in the background.
@@ -522,6 +708,7 @@ func (srv *Server) setupLocalNode() error {
 			defer srv.loopWG.Done()
 			if ip, err := srv.NAT.ExternalIP(); err == nil {
 				srv.localnode.SetStaticIP(ip)
+				srv.setNATExternalIP(ip)
 			}
 		}()
 	}
@@ -532,12 +719,20 @@ func (srv *Server) setupDiscovery() error {
 	srv.discmix = enode.NewFairMix(discmixTimeout)
 
 	// Add protocol-specific discovery sources.
-	added := make(map[string]bool)
+	srv.dialSources = make(map[string]bool)
 	for _, proto := range srv.Protocols {
-		if proto.DialCandidates != nil && !added[proto.Name] {
-			srv.discmix.AddSource(proto.DialCandidates)
-			added[proto.Name] = true
+		srv.addDialSource(proto)
+	}
+
+	// Add DNS node lists. Sync failures are handled by the client and the mix
+	// doesn't wait for slow sources, so this never holds up dialing.
+	if len(srv.DiscoveryURLs) > 0 {
+		client, err := dnsdisc.NewClient(dnsdisc.Config{Resolver: srv.DNSResolver, Logger: srv.log}, srv.DiscoveryURLs...)
+		if err != nil {
+			return err
 		}
+		srv.dnsdisc = client
+		srv.discmix.AddSource(client.NewIterator())
 	}
 
 	// Don't listen on UDP endpoint if DHT is disabled.
@@ -557,7 +752,7 @@ func (srv *Server) setupDiscovery() error {
 	srv.log.Debug("UDP listener up", "addr", realaddr)
 	if srv.NAT != nil {
 		if !realaddr.IP.IsLoopback() {
-			go nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
+			srv.mapPort("udp", realaddr.Port, "ethereum discovery")
 		}
 	}
 	srv.localnode.SetFallbackUDP(realaddr.Port)
@@ -576,6 +771,7 @@ func (srv *Server) setupDiscovery() error {
 			Bootnodes:   srv.BootstrapNodes,
 			Unhandled:   unhandled,
 			Log:         srv.log,
+			NeedNodes:   srv.needNodes,
 		}
 		ntab, err := discover.ListenUDP(conn, srv.localnode, cfg)
 		if err != nil {
@@ -619,11 +815,7 @@ func (srv *Server) setupListening() error {
 	if tcp, ok := listener.Addr().(*net.TCPAddr); ok {
 		srv.localnode.Set(enr.TCP(tcp.Port))
 		if !tcp.IP.IsLoopback() && srv.NAT != nil {
-			srv.loopWG.Add(1)
-			go func() {
-				nat.Map(srv.NAT, srv.quit, "tcp", tcp.Port, tcp.Port, "ethereum p2p")
-				srv.loopWG.Done()
-			}()
+			srv.mapPort("tcp", tcp.Port, "ethereum p2p")
 		}
 	}
 
@@ -632,11 +824,56 @@ func (srv *Server) setupListening() error {
 	return nil
 }
 
+// mapPort maps the given port on the NAT device and keeps the mapping alive
+// until the server is stopped.
+func (srv *Server) mapPort(protocol string, port int, name string) {
+	m := nat.NewMapping(srv.NAT, protocol, port, port, name)
+	srv.natLock.Lock()
+	srv.natMappings = append(srv.natMappings, m)
+	srv.natLock.Unlock()
+
+	srv.loopWG.Add(1)
+	go func() {
+		m.Run(srv.quit)
+		srv.loopWG.Done()
+	}()
+}
+
+func (srv *Server) setNATExternalIP(ip net.IP) {
+	srv.natLock.Lock()
+	srv.natExtIP = ip
+	srv.natLock.Unlock()
+}
+
+// natInfo assembles the state of the port mappings, or nil if NAT traversal is
+// disabled.
+func (srv *Server) natInfo() *NATInfo {
+	if srv.NAT == nil {
+		return nil
+	}
+	srv.natLock.Lock()
+	defer srv.natLock.Unlock()
+
+	info := &NATInfo{
+		Mechanism: srv.NAT.String(),
+		Mappings:  make([]nat.MappingStatus, 0, len(srv.natMappings)),
+	}
+	if srv.natExtIP != nil {
+		info.ExternalIP = srv.natExtIP.String()
+	}
+	for _, m := range srv.natMappings {
+		info.Mappings = append(info.Mappings, m.Status())
+	}
+	return info
+}
+
 type dialer interface {
 	newTasks(running int, peers map[enode.ID]*Peer, now time.Time) []task
 	taskDone(task, time.Time)
 	addStatic(*enode.Node)
 	removeStatic(*enode.Node)
+	setMaxDynDials(int)
+	setNetRestrict(*netutil.Netlist)
 }
 
 func (srv *Server) run(dialstate dialer) {
@@ -648,16 +885,19 @@ func (srv *Server) run(dialstate dialer) {
 	var (
 		peers        = make(map[enode.ID]*Peer)
 		inboundCount = 0
-		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
+		trusted      = make(map[enode.ID]bool)
 		taskdone     = make(chan task, maxActiveDialTasks)
 		runningTasks []task
 		queuedTasks  []task // tasks that can't run yet
+		drops        = recentDrops{cooldown: srv.InboundCooldown}
 	)
 	// Put trusted nodes into a map to speed up checks.
 	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
-	for _, n := range srv.TrustedNodes {
-		trusted[n.ID()] = true
+	srv.peerSetLock.Lock()
+	for id := range srv.trustedSet {
+		trusted[id] = true
 	}
+	srv.peerSetLock.Unlock()
 
 	// removes t from runningTasks
 	delTask := func(t task) {
@@ -692,6 +932,7 @@ func (srv *Server) run(dialstate dialer) {
 running:
 	for {
 		scheduleTasks()
+		srv.updateDialDemand(peers)
 
 		select {
 		case <-srv.quit:
@@ -704,6 +945,7 @@ running:
 			// it will keep the node connected.
 			srv.log.Trace("Adding static node", "node", n)
 			dialstate.addStatic(n)
+			srv.updateStaticSet(n, true)
 
 		case n := <-srv.removestatic:
 			// This channel is used by RemovePeer to send a
@@ -711,6 +953,16 @@ running:
 			// stop keeping the node connected.
 			srv.log.Trace("Removing static node", "node", n)
 			dialstate.removeStatic(n)
+			srv.updateStaticSet(n, false)
+
+			// Drop any dials to the node that didn't start yet
+			for i := 0; i < len(queuedTasks); i++ {
+				if t, ok := queuedTasks[i].(*dialTask); ok && t.dest.ID() == n.ID() {
+					dialstate.taskDone(t, time.Now())
+					queuedTasks = append(queuedTasks[:i], queuedTasks[i+1:]...)
+					i--
+				}
+			}
 			if p, ok := peers[n.ID()]; ok {
 				p.Disconnect(DiscRequested)
 			}
@@ -720,6 +972,7 @@ running:
 			// to the trusted node set.
 			srv.log.Trace("Adding trusted node", "node", n)
 			trusted[n.ID()] = true
+			srv.updateTrustedSet(n, true)
 			// Mark any already-connected peer as trusted
 			if p, ok := peers[n.ID()]; ok {
 				p.rw.set(trustedConn, true)
@@ -730,6 +983,7 @@ running:
 			// from the trusted node set.
 			srv.log.Trace("Removing trusted node", "node", n)
 			delete(trusted, n.ID())
+			srv.updateTrustedSet(n, false)
 
 			// Unmark any already-connected peer as trusted
 			if p, ok := peers[n.ID()]; ok {
@@ -741,6 +995,18 @@ running:
 			op(peers)
 			srv.peerOpDone <- struct{}{}
 
+		case n := <-srv.setMaxPeers:
+			// This channel is used by SetMaxPeers.
+			srv.log.Info("Changing peer limit", "old", srv.PeerLimit(), "new", n)
+			atomic.StoreInt32(&srv.maxPeers, int32(n))
+			dialstate.setMaxDynDials(srv.maxDialedConns())
+			srv.peerOpDone <- struct{}{}
+
+		case op := <-srv.setNetRestrict:
+			// This channel is used by SetNetRestriction.
+			srv.applyNetRestriction(op, dialstate, peers)
+			srv.peerOpDone <- struct{}{}
+
 		case t := <-taskdone:
 			// A task got done. Tell dialstate about it so it
 			// can update its state and remove it from the active
@@ -757,7 +1023,12 @@ running:
 				c.flags |= trustedConn
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
-			c.cont <- srv.postHandshakeChecks(peers, inboundCount, c)
+			err := srv.postHandshakeChecks(peers, inboundCount, c)
+			if err == nil && c.is(inboundConn) && !c.is(trustedConn) && drops.cooling(c.node.ID().String(), time.Now()) {
+				inboundRejectCooldownMeter.Mark(1)
+				err = errReconnectCooldown
+			}
+			c.cont <- err
 
 		case c := <-srv.checkpointAddPeer:
 			// At this point the connection is past the protocol handshake.
@@ -765,7 +1036,8 @@ running:
 			err := srv.addPeerChecks(peers, inboundCount, c)
 			if err == nil {
 				// The handshakes are done and it passed all checks.
-				p := newPeer(srv.log, c, srv.Protocols)
+				p := newPeer(srv.log, c, c.protocols)
+				p.reputation = srv.reputation
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -781,6 +1053,13 @@ running:
 				if conn, ok := c.fd.(*meteredConn); ok {
 					conn.handshakeDone(p)
 				}
+				if srv.nodedb != nil {
+					id := c.node.ID()
+					srv.nodedb.UpdateConnects(id, srv.nodedb.Connects(id)+1)
+				}
+				if metrics.Enabled() {
+					p.registerStatsGauges()
+				}
 			}
 			// The dialer logic relies on the assumption that
 			// dial tasks complete after the peer has been added or
@@ -792,8 +1071,15 @@ running:
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "addr", pd.RemoteAddr(), "peers", len(peers)-1, "duration", d, "req", pd.requested, "err", pd.err)
 			delete(peers, pd.ID())
+			drops.dropped(pd.ID().String(), time.Now())
 			if pd.Inbound() {
 				inboundCount--
+				if ip := netutil.AddrIP(pd.RemoteAddr()); ip != nil {
+					srv.inbound.remove(ip)
+				}
+			}
+			if metrics.Enabled() {
+				pd.unregisterStatsGauges()
 			}
 		}
 	}
@@ -818,12 +1104,15 @@ running:
 		p := <-srv.delpeer
 		p.log.Trace("<-delpeer (spindown)", "remainingTasks", len(runningTasks))
 		delete(peers, p.ID())
+		if metrics.Enabled() {
+			p.unregisterStatsGauges()
+		}
 	}
 }
 
 func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	switch {
-	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.MaxPeers:
+	case !c.is(trustedConn|staticDialedConn) && len(peers) >= srv.PeerLimit():
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
 		return DiscTooManyPeers
@@ -831,6 +1120,8 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn) && srv.reputation.banned(c.node.ID()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
@@ -838,7 +1129,7 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 
 func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	// Drop connections with no matching protocols.
-	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
+	if len(c.protocols) > 0 && countMatchingProtocols(c.protocols, c.caps) == 0 {
 		return DiscUselessPeer
 	}
 	// Repeat the post-handshake checks because the
@@ -847,7 +1138,83 @@ func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *
 }
 
 func (srv *Server) maxInboundConns() int {
-	return srv.MaxPeers - srv.maxDialedConns()
+	return srv.PeerLimit() - srv.maxDialedConns()
+}
+
+// updateDialDemand records the number of free slots for dynamically dialed peers.
+func (srv *Server) updateDialDemand(peers map[enode.ID]*Peer) {
+	free := srv.maxDialedConns()
+	for _, p := range peers {
+		if p.rw.is(dynDialedConn) {
+			free--
+		}
+	}
+	atomic.StoreInt32(&srv.dialDemand, int32(free))
+}
+
+// needNodes reports whether the dialer is looking for more peers. The discovery
+// table uses this to adapt its refresh rate.
+func (srv *Server) needNodes() bool {
+	return atomic.LoadInt32(&srv.dialDemand) > 0
+}
+
+// applyNetRestriction installs new connection restrictions. It runs on the run
+// loop.
+func (srv *Server) applyNetRestriction(op netRestrictOp, dialstate dialer, peers map[enode.ID]*Peer) {
+	srv.restrictLock.Lock()
+	srv.restrict = op.restrict
+	srv.restrictLock.Unlock()
+
+	srv.log.Info("Changing network restrictions", "inbound", op.restrict.Inbound, "outbound", op.restrict.Outbound)
+	dialstate.setNetRestrict(op.restrict.Outbound)
+	if srv.ntab != nil {
+		srv.ntab.SetNetRestrict(op.restrict.Outbound)
+	}
+	if !op.drop {
+		return
+	}
+	for _, p := range peers {
+		restrict := op.restrict.Outbound
+		if p.Inbound() {
+			restrict = op.restrict.Inbound
+		}
+		if restrict != nil && !restrict.Contains(p.Node().IP()) {
+			p.log.Debug("Dropping peer outside of network restriction")
+			p.Disconnect(DiscRequested)
+		}
+	}
+}
+
+// PeerReputation returns the reputation of a node, derived from the incidents
+// reported by protocols through Peer.ReportIncident.
+func (srv *Server) PeerReputation(id enode.ID) Reputation {
+	return srv.reputation.reputation(id)
+}
+
+// ClearReputation forgets all incidents of a node, lifting any ban.
+func (srv *Server) ClearReputation(id enode.ID) error {
+	return srv.reputation.clear(id)
+}
+
+// BanPeer refuses connections to a node for at least the given duration. The
+// node is disconnected and removed from the static peers. Trusted nodes are
+// exempt from bans.
+func (srv *Server) BanPeer(node *enode.Node, duration time.Duration) (Reputation, error) {
+	rep, err := srv.reputation.ban(node.ID(), duration)
+	if err != nil {
+		return rep, err
+	}
+	srv.RemovePeer(node)
+	return rep, nil
+}
+
+// DiscoveryTable returns a snapshot of the discovery v4 node table, or nil if
+// discovery is disabled.
+func (srv *Server) DiscoveryTable() *discover.TableInfo {
+	if srv.ntab == nil {
+		return nil
+	}
+	return srv.ntab.TableInfo()
 }
 
 func (srv *Server) maxDialedConns() int {
@@ -858,7 +1225,7 @@ func (srv *Server) maxDialedConns() int {
 	if r == 0 {
 		r = defaultDialRatio
 	}
-	return srv.MaxPeers / r
+	return srv.PeerLimit() / r
 }
 
 // listenLoop runs in its own goroutine and accepts
@@ -912,7 +1279,11 @@ func (srv *Server) listenLoop() {
 			srv.log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 		}
 		go func() {
-			srv.SetupConn(fd, inboundConn, nil)
+			// Release the connection from the inbound limits unless it became a
+			// peer, which is released by the run loop upon disconnection.
+			if err := srv.SetupConn(fd, inboundConn, nil); err != nil && remoteIP != nil {
+				srv.inbound.remove(remoteIP)
+			}
 			slots <- struct{}{}
 		}()
 	}
@@ -921,15 +1292,28 @@ func (srv *Server) listenLoop() {
 func (srv *Server) checkInboundConn(fd net.Conn, remoteIP net.IP) error {
 	if remoteIP != nil {
 		// Reject connections that do not match NetRestrict.
-		if srv.NetRestrict != nil && !srv.NetRestrict.Contains(remoteIP) {
+		if restrict := srv.NetRestriction().Inbound; restrict != nil && !restrict.Contains(remoteIP) {
+			inboundRejectRestrictMeter.Mark(1)
 			return fmt.Errorf("not whitelisted in NetRestrict")
 		}
 		// Reject Internet peers that try too often.
+		lan := netutil.IsLAN(remoteIP)
 		srv.inboundHistory.expire(time.Now())
-		if !netutil.IsLAN(remoteIP) && srv.inboundHistory.contains(remoteIP.String()) {
+		if !lan && srv.inboundHistory.contains(remoteIP.String()) {
+			inboundRejectThrottleMeter.Mark(1)
 			return fmt.Errorf("too many attempts")
 		}
 		srv.inboundHistory.add(remoteIP.String(), time.Now().Add(inboundThrottleTime))
+
+		// Reject Internet peers from crowded addresses and subnets.
+		switch err := srv.inbound.add(remoteIP, srv.MaxPeersPerIP, srv.MaxPeersPerSubnet, lan); err {
+		case errInboundIPLimit:
+			inboundRejectIPMeter.Mark(1)
+			return err
+		case errInboundSubnetLimit:
+			inboundRejectSubnetMeter.Mark(1)
+			return err
+		}
 	}
 	return nil
 }
@@ -943,6 +1327,17 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *enode.Node)
 	if err != nil {
 		c.close(err)
 		srv.log.Trace("Setting up connection failed", "addr", fd.RemoteAddr(), "err", err)
+		// Report failures once the remote identity is known.
+		if c.node != nil && err != errServerStopped {
+			srv.peerFeed.Send(&PeerEvent{
+				Type:          PeerEventTypeHandshakeFail,
+				Peer:          c.node.ID(),
+				Error:         err.Error(),
+				Failure:       handshakeFailureForError(err),
+				RemoteAddress: fd.RemoteAddr().String(),
+				LocalAddress:  fd.LocalAddr().String(),
+			})
+		}
 	}
 	return err
 }
@@ -987,10 +1382,17 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		return err
 	}
 
-	// Run the capability negotiation handshake.
-	phs, err := c.doProtoHandshake(srv.ourHandshake)
+	// Run the capability negotiation handshake. The protocols offered here are
+	// the ones the peer will run, even if protocols are added or removed later.
+	var ourHandshake *protoHandshake
+	c.protocols, ourHandshake = srv.runningProtocols()
+	phs, err := c.doProtoHandshake(ourHandshake)
 	if err != nil {
 		clog.Trace("Failed proto handshake", "err", err)
+		// Penalize inbound nodes failing the handshake, unless they gave a reason.
+		if _, ok := err.(DiscReason); !ok && c.is(inboundConn) {
+			srv.reputation.report(c.node.ID(), IncidentHandshakeFailure)
+		}
 		return err
 	}
 	if id := c.node.ID(); !bytes.Equal(crypto.Keccak256(phs.ID), id[:]) {
@@ -1047,21 +1449,38 @@ func (srv *Server) runPeer(p *Peer) {
 	}
 
 	// broadcast peer add
+	var caps []string
+	for _, proto := range p.running {
+		caps = append(caps, Cap{proto.Name, proto.Version}.String())
+	}
+	sort.Strings(caps)
 	srv.peerFeed.Send(&PeerEvent{
 		Type:          PeerEventTypeAdd,
 		Peer:          p.ID(),
+		Caps:          caps,
 		RemoteAddress: p.RemoteAddr().String(),
 		LocalAddress:  p.LocalAddr().String(),
 	})
 
 	// run the protocol
-	remoteRequested, err := p.run()
-
-	// broadcast peer drop
+	remoteRequested, reason, err := p.run()
+
+	// broadcast handshake failures of subprotocols, then the peer drop
+	if herr, ok := err.(*HandshakeError); ok {
+		srv.peerFeed.Send(&PeerEvent{
+			Type:          PeerEventTypeHandshakeFail,
+			Peer:          p.ID(),
+			Error:         err.Error(),
+			Failure:       herr.Failure,
+			RemoteAddress: p.RemoteAddr().String(),
+			LocalAddress:  p.LocalAddr().String(),
+		})
+	}
 	srv.peerFeed.Send(&PeerEvent{
 		Type:          PeerEventTypeDrop,
 		Peer:          p.ID(),
 		Error:         err.Error(),
+		Reason:        reason.String(),
 		RemoteAddress: p.RemoteAddr().String(),
 		LocalAddress:  p.LocalAddr().String(),
 	})
@@ -1082,8 +1501,18 @@ type NodeInfo struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
 		Listener  int `json:"listener"`  // TCP listening port for RLPx
 	} `json:"ports"`
-	ListenAddr string                 `json:"listenAddr"`
-	Protocols  map[string]interface{} `json:"protocols"`
+	ListenAddr   string                 `json:"listenAddr"`
+	Protocols    map[string]interface{} `json:"protocols"`
+	DNSDiscovery []dnsdisc.TreeStats    `json:"dnsDiscovery,omitempty"` // Sync status of DNS node lists
+	Inbound      *InboundPolicyInfo     `json:"inboundPolicy"`          // Inbound connection policy and usage
+	NAT          *NATInfo               `json:"nat,omitempty"`          // Port mapping status, if NAT traversal is enabled
+}
+
+// NATInfo represents the state of NAT traversal.
+type NATInfo struct {
+	Mechanism  string              `json:"mechanism"`  // Port mapping mechanism in use
+	ExternalIP string              `json:"externalIP"` // External address reported by the gateway
+	Mappings   []nat.MappingStatus `json:"mappings"`   // Status of the mapped ports
 }
 
 // NodeInfo gathers and returns a collection of metadata known about the host.
@@ -1097,13 +1526,19 @@ func (srv *Server) NodeInfo() *NodeInfo {
 		IP:         node.IP().String(),
 		ListenAddr: srv.ListenAddr,
 		Protocols:  make(map[string]interface{}),
+		Inbound:    srv.inboundPolicyInfo(),
+		NAT:        srv.natInfo(),
 	}
 	info.Ports.Discovery = node.UDP()
 	info.Ports.Listener = node.TCP()
 	info.ENR = node.String()
+	if srv.dnsdisc != nil {
+		info.DNSDiscovery = srv.dnsdisc.Stats()
+	}
 
 	// Gather all the running protocol infos (only once per protocol type)
-	for _, proto := range srv.Protocols {
+	protocols, _ := srv.runningProtocols()
+	for _, proto := range protocols {
 		if _, ok := info.Protocols[proto.Name]; !ok {
 			nodeInfo := interface{}("unknown")
 			if query := proto.NodeInfo; query != nil {
@@ -1115,13 +1550,76 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// PeerStats returns the connection quality statistics of all connected peers,
+// sorted by node identifier.
+func (srv *Server) PeerStats() []*PeerStats {
+	stats := make([]*PeerStats, 0, srv.PeerCount())
+	for _, peer := range srv.Peers() {
+		s := peer.Stats()
+		if srv.nodedb != nil {
+			s.Connects = srv.nodedb.Connects(peer.ID())
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
+// PeerSetInfo describes a member of the static or trusted peer set.
+type PeerSetInfo struct {
+	Enode     string `json:"enode"`     // Node URL for connecting to the node
+	ID        string `json:"id"`        // Unique node identifier
+	Connected bool   `json:"connected"` // Whether the node is currently connected
+}
+
+// StaticPeers returns the nodes the server maintains connections to, sorted by
+// node identifier.
+func (srv *Server) StaticPeers() []*PeerSetInfo {
+	return srv.peerSetInfos(func() map[enode.ID]*enode.Node { return srv.staticSet })
+}
+
+// TrustedPeers returns the nodes allowed to connect even above the peer limit,
+// sorted by node identifier.
+func (srv *Server) TrustedPeers() []*PeerSetInfo {
+	return srv.peerSetInfos(func() map[enode.ID]*enode.Node { return srv.trustedSet })
+}
+
+// peerSetInfos describes the members of a peer set along with their connection
+// state. The set is retrieved under the peer set lock.
+func (srv *Server) peerSetInfos(set func() map[enode.ID]*enode.Node) []*PeerSetInfo {
+	connected := make(map[enode.ID]bool)
+	for _, p := range srv.Peers() {
+		connected[p.ID()] = true
+	}
+	srv.peerSetLock.Lock()
+	defer srv.peerSetLock.Unlock()
+
+	infos := make([]*PeerSetInfo, 0, len(set()))
+	for id, n := range set() {
+		infos = append(infos, &PeerSetInfo{
+			Enode:     n.URLv4(),
+			ID:        id.String(),
+			Connected: connected[id],
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
 	infos := make([]*PeerInfo, 0, srv.PeerCount())
 	for _, peer := range srv.Peers() {
 		if peer != nil {
-			infos = append(infos, peer.Info())
+			info := peer.Info()
+			if info.ENR == "" && srv.nodedb != nil {
+				// Fall back to the record learned from discovery, if any
+				if n := srv.nodedb.Node(peer.ID()); n != nil && n.Seq() > 0 {
+					info.ENR = n.String()
+				}
+			}
+			infos = append(infos, info)
 		}
 	}
 	// Sort the result array alphabetically by node identifier
@@ -0,0 +1,157 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var errProtocolNotRegistered = errors.New("protocol not registered")
+
+// AddProtocol starts offering an additional protocol on the running server.
+//
+// Capabilities are negotiated once per connection, so the protocol is only run
+// with peers connecting after this call. Peers which are already connected keep
+// running the protocols negotiated during their handshake. ProtocolPeers can be
+// used to find the peers running the new protocol.
+func (srv *Server) AddProtocol(p Protocol) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if !srv.running {
+		return errServerStopped
+	}
+
+	srv.protoLock.Lock()
+	defer srv.protoLock.Unlock()
+
+	for _, have := range srv.protocols {
+		if have.Name == p.Name && have.Version == p.Version {
+			return fmt.Errorf("protocol %s/%d already registered", p.Name, p.Version)
+		}
+	}
+	protocols := make([]Protocol, len(srv.protocols), len(srv.protocols)+1)
+	copy(protocols, srv.protocols)
+	srv.protocols = append(protocols, p)
+	srv.ourHandshake = srv.makeHandshake(srv.protocols)
+
+	for _, e := range p.Attributes {
+		srv.localnode.Set(e)
+	}
+	srv.addDialSource(p)
+	srv.log.Info("Added p2p protocol", "name", p.Name, "version", p.Version)
+	return nil
+}
+
+// RemoveProtocol stops offering a protocol on the running server. New connections
+// no longer negotiate it, but peers which are already running the protocol keep
+// doing so until they disconnect. The discovery source of the protocol, if any,
+// stays active until the server is stopped.
+func (srv *Server) RemoveProtocol(name string, version uint) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if !srv.running {
+		return errServerStopped
+	}
+
+	srv.protoLock.Lock()
+	defer srv.protoLock.Unlock()
+
+	var (
+		protocols = make([]Protocol, 0, len(srv.protocols))
+		removed   *Protocol
+	)
+	for i := range srv.protocols {
+		if p := srv.protocols[i]; p.Name == name && p.Version == version {
+			removed = &srv.protocols[i]
+			continue
+		}
+		protocols = append(protocols, srv.protocols[i])
+	}
+	if removed == nil {
+		return errProtocolNotRegistered
+	}
+	srv.protocols = protocols
+	srv.ourHandshake = srv.makeHandshake(srv.protocols)
+
+	// Drop the ENR entries of the protocol unless another protocol still sets them.
+	for _, e := range removed.Attributes {
+		if !protocolsSetAttribute(protocols, e.ENRKey()) {
+			srv.localnode.Delete(e)
+		}
+	}
+	srv.log.Info("Removed p2p protocol", "name", name, "version", version)
+	return nil
+}
+
+// ProtocolPeers returns the connected peers running the given protocol.
+func (srv *Server) ProtocolPeers(name string, version uint) []*Peer {
+	var peers []*Peer
+	for _, p := range srv.Peers() {
+		if p.RunningCap(name, []uint{version}) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// runningProtocols returns the protocols currently offered to new connections
+// along with the matching protocol handshake. The returned values must not be
+// modified.
+func (srv *Server) runningProtocols() ([]Protocol, *protoHandshake) {
+	srv.protoLock.RLock()
+	defer srv.protoLock.RUnlock()
+
+	if srv.ourHandshake == nil {
+		return srv.Protocols, nil // not started yet
+	}
+	return srv.protocols, srv.ourHandshake
+}
+
+// makeHandshake creates the devp2p handshake announcing the given protocols.
+func (srv *Server) makeHandshake(protocols []Protocol) *protoHandshake {
+	pubkey := crypto.FromECDSAPub(&srv.PrivateKey.PublicKey)
+	hs := &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: pubkey[1:]}
+	for _, p := range protocols {
+		hs.Caps = append(hs.Caps, p.cap())
+	}
+	sort.Sort(capsByNameAndVersion(hs.Caps))
+	return hs
+}
+
+// addDialSource adds the discovery source of a protocol to the dial candidates,
+// unless a protocol of the same name already provided one.
+func (srv *Server) addDialSource(p Protocol) {
+	if p.DialCandidates != nil && !srv.dialSources[p.Name] {
+		srv.discmix.AddSource(p.DialCandidates)
+		srv.dialSources[p.Name] = true
+	}
+}
+
+func protocolsSetAttribute(protocols []Protocol, key string) bool {
+	for _, p := range protocols {
+		for _, e := range p.Attributes {
+			if e.ENRKey() == key {
+				return true
+			}
+		}
+	}
+	return false
+}
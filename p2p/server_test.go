@@ -20,9 +20,13 @@ import (
 	"crypto/ecdsa"
 	"errors"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -31,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -341,6 +346,10 @@ func (tg taskgen) addStatic(*enode.Node) {
 }
 func (tg taskgen) removeStatic(*enode.Node) {
 }
+func (tg taskgen) setMaxDynDials(int) {
+}
+func (tg taskgen) setNetRestrict(*netutil.Netlist) {
+}
 
 type testTask struct {
 	index  int
@@ -418,6 +427,267 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+// Tests that static and trusted peers added at runtime are persisted in the node
+// database and restored after a restart.
+func TestServerPeerSetPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-peerset-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		key        = newkey()
+		configured = newNode(randomID(), nil)
+		static     = newNode(randomID(), nil)
+		trusted    = newNode(randomID(), nil)
+	)
+	start := func() *Server {
+		srv := &Server{
+			Config: Config{
+				PrivateKey:   key,
+				MaxPeers:     10,
+				NoDiscovery:  true,
+				NodeDatabase: filepath.Join(dir, "nodes"),
+				StaticNodes:  []*enode.Node{configured},
+				Logger:       testlog.Logger(t, log.LvlTrace),
+			},
+		}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start: %v", err)
+		}
+		return srv
+	}
+	check := func(srv *Server, want map[string][]*enode.Node) {
+		t.Helper()
+		for name, infos := range map[string][]*PeerSetInfo{"static": srv.StaticPeers(), "trusted": srv.TrustedPeers()} {
+			var ids []string
+			for _, info := range infos {
+				ids = append(ids, info.ID)
+				if info.Connected {
+					t.Errorf("%s peer %s reported connected", name, info.ID)
+				}
+			}
+			var wantIDs []string
+			for _, n := range want[name] {
+				wantIDs = append(wantIDs, n.ID().String())
+			}
+			sort.Strings(wantIDs)
+			if !reflect.DeepEqual(ids, wantIDs) {
+				t.Errorf("%s peer set mismatch: have %v, want %v", name, ids, wantIDs)
+			}
+		}
+	}
+	// Add the peers at runtime and check that they survive a restart
+	srv := start()
+	srv.AddPeer(static)
+	srv.AddTrustedPeer(trusted)
+	check(srv, map[string][]*enode.Node{"static": {configured, static}, "trusted": {trusted}})
+	srv.Stop()
+
+	srv = start()
+	check(srv, map[string][]*enode.Node{"static": {configured, static}, "trusted": {trusted}})
+
+	// Remove the peers and check that they are also gone after a restart
+	srv.RemovePeer(static)
+	srv.RemoveTrustedPeer(trusted)
+	check(srv, map[string][]*enode.Node{"static": {configured}})
+	srv.Stop()
+
+	srv = start()
+	defer srv.Stop()
+	check(srv, map[string][]*enode.Node{"static": {configured}})
+}
+
+// Tests that the peer limit can be changed while the server is running.
+func TestServerSetMaxPeers(t *testing.T) {
+	remoteKey := newkey()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    2,
+			NoDial:      true,
+			NoDiscovery: true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func() *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&remoteKey.PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), randomID())
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	for i := 0; i < 2; i++ {
+		if err := srv.checkpoint(newconn(), srv.checkpointAddPeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	if err := srv.checkpoint(newconn(), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatalf("wrong error for conn over limit: %v", err)
+	}
+	// Raising the limit admits more peers.
+	srv.SetMaxPeers(3)
+	if err := srv.checkpoint(newconn(), srv.checkpointAddPeer); err != nil {
+		t.Fatalf("could not add conn after raising limit: %v", err)
+	}
+	// Lowering it keeps the existing peers, but rejects new ones.
+	srv.SetMaxPeers(1)
+	if err := srv.checkpoint(newconn(), srv.checkpointPostHandshake); err != DiscTooManyPeers {
+		t.Fatalf("wrong error for conn after lowering limit: %v", err)
+	}
+	if n := srv.PeerCount(); n != 3 {
+		t.Fatalf("wrong peer count after lowering limit: %d", n)
+	}
+	if srv.MaxPeers != 2 {
+		t.Fatalf("configured peer limit changed: %d", srv.MaxPeers)
+	}
+}
+
+// Tests that peer events report negotiated protocols on add, and that failed
+// handshakes are reported with their category before the drop.
+func TestServerPeerEvents(t *testing.T) {
+	errGenesis := NewHandshakeError(HandshakeGenesisMismatch, errors.New("genesis mismatch"))
+	newServer := func(proto Protocol) *Server {
+		srv := &Server{Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			Protocols:   []Protocol{proto},
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start server: %v", err)
+		}
+		return srv
+	}
+	// The first server rejects everyone in its subprotocol handshake.
+	srv := newServer(Protocol{Name: "test", Version: 1, Length: 1, Run: func(p *Peer, rw MsgReadWriter) error {
+		return errGenesis
+	}})
+	defer srv.Stop()
+	events := make(chan *PeerEvent, 10)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	next := func() *PeerEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for peer event")
+			return nil
+		}
+	}
+	// Connect a peer speaking the same protocol.
+	peer := newServer(Protocol{Name: "test", Version: 1, Length: 1, Run: func(p *Peer, rw MsgReadWriter) error {
+		_, err := rw.ReadMsg()
+		return err
+	}})
+	defer peer.Stop()
+	peer.AddPeer(srv.Self())
+
+	if ev := next(); ev.Type != PeerEventTypeAdd || ev.Peer != peer.Self().ID() || !reflect.DeepEqual(ev.Caps, []string{"test/1"}) {
+		t.Fatalf("wrong add event: %+v", ev)
+	}
+	if ev := next(); ev.Type != PeerEventTypeHandshakeFail || ev.Failure != HandshakeGenesisMismatch || ev.Error != errGenesis.Error() {
+		t.Fatalf("wrong handshake failure event: %+v", ev)
+	}
+	if ev := next(); ev.Type != PeerEventTypeDrop || ev.Reason != DiscReason(DiscSubprotocolError).String() {
+		t.Fatalf("wrong drop event: %+v", ev)
+	}
+	// Connect a peer without any matching protocol.
+	other := newServer(Protocol{Name: "other", Version: 1, Length: 1, Run: func(p *Peer, rw MsgReadWriter) error {
+		_, err := rw.ReadMsg()
+		return err
+	}})
+	defer other.Stop()
+	other.AddPeer(srv.Self())
+
+	if ev := next(); ev.Type != PeerEventTypeHandshakeFail || ev.Peer != other.Self().ID() || ev.Failure != HandshakeIncompatible {
+		t.Fatalf("wrong handshake failure event: %+v", ev)
+	}
+}
+
+// Tests that network restrictions can be changed at runtime and apply to each
+// direction separately.
+func TestServerNetRestriction(t *testing.T) {
+	newServer := func() *Server {
+		srv := &Server{Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    10,
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start server: %v", err)
+		}
+		return srv
+	}
+	srv := newServer()
+	defer srv.Stop()
+	events := make(chan *PeerEvent, 10)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	waitEvent := func(typ PeerEventType) {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == typ {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s event", typ)
+			}
+		}
+	}
+	var (
+		lan       = new(netutil.Netlist)
+		localhost = new(netutil.Netlist)
+	)
+	lan.Add("10.0.0.0/8")
+	localhost.Add("127.0.0.0/8")
+	localhost.Add("::1/128")
+
+	// An outbound-only restriction must still accept inbound peers from
+	// outside of the list.
+	srv.SetNetRestriction(NetRestriction{Outbound: lan}, false)
+	if r := srv.NetRestriction(); r.Inbound != nil || r.Outbound != lan {
+		t.Fatalf("wrong restriction: %+v", r)
+	}
+	peer := newServer()
+	defer peer.Stop()
+	peer.AddPeer(srv.Self())
+	waitEvent(PeerEventTypeAdd)
+
+	// Restricting inbound connections keeps the peer unless asked to drop it.
+	srv.SetNetRestriction(NetRestriction{Inbound: lan}, false)
+	if srv.PeerCount() != 1 {
+		t.Fatal("peer dropped without drop flag")
+	}
+	if err := srv.checkInboundConn(nil, net.ParseIP("127.0.0.2")); err == nil {
+		t.Fatal("inbound connection outside of restriction accepted")
+	}
+	srv.SetNetRestriction(NetRestriction{Inbound: lan}, true)
+	waitEvent(PeerEventTypeDrop)
+
+	// Peers within the restriction are kept.
+	srv.SetNetRestriction(NetRestriction{}, false)
+	peer2 := newServer()
+	defer peer2.Stop()
+	peer2.AddPeer(srv.Self())
+	waitEvent(PeerEventTypeAdd)
+	srv.SetNetRestriction(NetRestriction{Inbound: localhost, Outbound: lan}, true)
+	if srv.PeerCount() != 1 {
+		t.Fatal("peer within restriction dropped")
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
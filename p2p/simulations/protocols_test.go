@@ -0,0 +1,148 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// newEchoProtocol creates a protocol which sends a request to every peer and
+// answers requests of the peer. The IDs of peers answering are sent to replies.
+func newEchoProtocol(replies chan<- enode.ID) p2p.Protocol {
+	return p2p.Protocol{
+		Name:    "echo",
+		Version: 1,
+		Length:  2,
+		Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+			if err := p2p.Send(rw, 0, peer.ID()); err != nil {
+				return err
+			}
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				var id enode.ID
+				if err := msg.Decode(&id); err != nil {
+					return err
+				}
+				switch msg.Code {
+				case 0:
+					err = p2p.Send(rw, 1, id)
+				case 1:
+					replies <- peer.ID()
+				}
+				if err != nil {
+					return err
+				}
+			}
+		},
+	}
+}
+
+// Tests that protocols added to running servers are negotiated with peers connecting
+// afterwards, while peers which were already connected are unaffected.
+func TestAddProtocolRunning(t *testing.T) {
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"noopwoop": func(ctx *adapters.ServiceContext) (node.Service, error) {
+			return NewNoopService(nil), nil
+		},
+	})
+	network := NewNetwork(adapter, &NetworkConfig{DefaultService: "noopwoop"})
+	defer network.Shutdown()
+
+	nodes, err := createTestNodes(3, network)
+	if err != nil {
+		t.Fatalf("can't create nodes: %v", err)
+	}
+	servers := make([]*p2p.Server, len(nodes))
+	for i, n := range nodes {
+		servers[i] = n.Node.(*adapters.SimNode).Server()
+	}
+	waitPeers := func(srv *p2p.Server, count int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for srv.PeerCount() < count {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %d peers, have %d", count, srv.PeerCount())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Connect the first two nodes before the protocol is added.
+	if err := network.Connect(nodes[0].ID(), nodes[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(servers[0], 1)
+
+	replies := make(chan enode.ID, 10)
+	for _, srv := range servers {
+		if err := srv.AddProtocol(newEchoProtocol(replies)); err != nil {
+			t.Fatalf("can't add protocol: %v", err)
+		}
+	}
+	if err := servers[0].AddProtocol(newEchoProtocol(replies)); err == nil {
+		t.Fatal("duplicate protocol added")
+	}
+
+	// The third node connects after the protocol was added and must negotiate it.
+	if err := network.Connect(nodes[0].ID(), nodes[2].ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(servers[0], 2)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-replies:
+			if id != nodes[0].ID() && id != nodes[2].ID() {
+				t.Fatalf("echo reply from unexpected peer %v", id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for echo reply")
+		}
+	}
+	peers := servers[0].ProtocolPeers("echo", 1)
+	if len(peers) != 1 || peers[0].ID() != nodes[2].ID() {
+		t.Fatalf("wrong echo peers: %v", peers)
+	}
+	for _, p := range servers[0].Peers() {
+		if !p.RunningCap("noop", []uint{666}) {
+			t.Errorf("peer %v doesn't run the initial protocol", p.ID())
+		}
+	}
+
+	// Removing the protocol doesn't affect peers already running it.
+	if err := servers[0].RemoveProtocol("echo", 1); err != nil {
+		t.Fatalf("can't remove protocol: %v", err)
+	}
+	if err := servers[0].RemoveProtocol("echo", 1); err == nil {
+		t.Fatal("removed protocol twice")
+	}
+	if _, ok := servers[0].NodeInfo().Protocols["echo"]; ok {
+		t.Error("removed protocol still in node info")
+	}
+	if peers := servers[0].ProtocolPeers("echo", 1); len(peers) != 1 {
+		t.Fatalf("wrong echo peers after removal: %v", peers)
+	}
+}
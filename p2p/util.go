@@ -50,6 +50,16 @@ func (h expHeap) contains(item string) bool {
 	return false
 }
 
+// remove deletes an item regardless of its expiry time.
+func (h *expHeap) remove(item string) {
+	for i, v := range *h {
+		if v.item == item {
+			heap.Remove(h, i)
+			return
+		}
+	}
+}
+
 // expire removes items with expiry time before 'now'.
 func (h *expHeap) expire(now time.Time) {
 	for h.Len() > 0 && h.nextExpiry().Before(now) {
@@ -110,6 +110,9 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
+	MaxUncles     = 2 // Maximum number of uncles allowed in a single block
+	MaxUncleDepth = 6 // Maximum number of generations between an uncle and the block including it
+
 	// Precompiled contract gas prices
 
 	EcrecoverGas        uint64 = 3000 // Elliptic curve sender recovery gas price
@@ -18,12 +18,30 @@ package rpc
 
 import (
 	"net"
+	"net/http"
+	"strconv"
 
 	"github.com/ethereum/go-ethereum/log"
 )
 
-// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules
-func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts) (net.Listener, *Server, error) {
+// apiAllowed checks whether an API is enabled by a module whitelist. Versioned
+// APIs ("eth@2") are enabled by their plain namespace ("eth") as well as by their
+// versioned one ("eth2").
+func apiAllowed(whitelist map[string]bool, namespace string) bool {
+	if whitelist[namespace] {
+		return true
+	}
+	name, version, err := parseNamespace(namespace)
+	if err != nil || version == 0 {
+		return false
+	}
+	return whitelist[name] || whitelist[name+strconv.Itoa(version)]
+}
+
+// StartHTTPEndpoint starts the HTTP RPC endpoint, configured with cors/vhosts/modules.
+// The optional middlewares wrap the entire handler chain, the first one being the
+// outermost.
+func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []string, vhosts []string, timeouts HTTPTimeouts, middlewares ...func(http.Handler) http.Handler) (net.Listener, *Server, error) {
 	// Generate the whitelist based on the allowed modules
 	whitelist := make(map[string]bool)
 	for _, module := range modules {
@@ -32,7 +50,7 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 	// Register all the APIs exposed by the services
 	handler := NewServer()
 	for _, api := range apis {
-		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+		if apiAllowed(whitelist, api.Namespace) || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 				return nil, nil, err
 			}
@@ -47,7 +65,11 @@ func StartHTTPEndpoint(endpoint string, apis []API, modules []string, cors []str
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return nil, nil, err
 	}
-	go NewHTTPServer(cors, vhosts, timeouts, handler).Serve(listener)
+	server := NewHTTPServer(cors, vhosts, timeouts, handler)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		server.Handler = middlewares[i](server.Handler)
+	}
+	go server.Serve(listener)
 	return listener, handler, err
 }
 
@@ -62,7 +84,7 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 	// Register all the APIs exposed by the services
 	handler := NewServer()
 	for _, api := range apis {
-		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+		if exposeAll || apiAllowed(whitelist, api.Namespace) || (len(whitelist) == 0 && api.Public) {
 			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 				return nil, nil, err
 			}
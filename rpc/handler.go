@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -288,6 +289,9 @@ func (h *handler) handleResponse(msg *jsonrpcMessage) {
 
 // handleCallMsg executes a call message and returns the answer.
 func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	atomic.AddInt64(&h.reg.active, 1)
+	defer atomic.AddInt64(&h.reg.active, -1)
+
 	start := time.Now()
 	switch {
 	case msg.isNotification():
@@ -24,12 +24,19 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/log"
 )
 
 // ipcListen will create a Unix socket on the given endpoint.
 func ipcListen(endpoint string) (net.Listener, error) {
+	// On Linux, endpoints starting with @ are abstract sockets which the net
+	// package creates in the abstract namespace. There is no file to manage.
+	if runtime.GOOS == "linux" && strings.HasPrefix(endpoint, "@") {
+		return net.Listen("unix", endpoint)
+	}
 	if len(endpoint) > int(max_path_size) {
 		log.Warn(fmt.Sprintf("The ipc endpoint is longer than %d characters. ", max_path_size),
 			"endpoint", endpoint)
@@ -128,6 +128,12 @@ func (s *Server) Stop() {
 	}
 }
 
+// ActiveRequests returns the number of method calls currently being executed by
+// the server, across all of its connections.
+func (s *Server) ActiveRequests() int64 {
+	return atomic.LoadInt64(&s.services.active)
+}
+
 // RPCService gives meta information about the server.
 // e.g. gives information about the loaded modules.
 type RPCService struct {
@@ -145,3 +151,10 @@ func (s *RPCService) Modules() map[string]string {
 	}
 	return modules
 }
+
+// SupportedVersions returns the versions available of each RPC namespace. Plain
+// namespaces count as version 1, while services registered as "namespace@N" add
+// version N, callable through the "namespaceN_" method prefix.
+func (s *RPCService) SupportedVersions() map[string][]int {
+	return s.server.services.supportedVersions()
+}
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"net"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -150,3 +151,123 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+func TestServerActiveRequests(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Call(nil, "test_sleep", 200*time.Millisecond)
+	}()
+	// Wait for the call to reach the server, then for it to finish.
+	deadline := time.Now().Add(time.Second)
+	for server.ActiveRequests() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("call never became active")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := server.ActiveRequests(); n != 1 {
+		t.Fatalf("wrong number of active requests: have %d, want 1", n)
+	}
+	if err := <-done; err != nil {
+		t.Fatal("call failed:", err)
+	}
+	if n := server.ActiveRequests(); n != 0 {
+		t.Fatalf("wrong number of active requests after call: have %d, want 0", n)
+	}
+}
+
+// testServiceV2 is a second version of testService, changing the signature of
+// Echo and adding a new method.
+type testServiceV2 struct{}
+
+func (s *testServiceV2) Echo(str string) string { return "v2:" + str }
+func (s *testServiceV2) Upgraded() string       { return "upgraded" }
+
+// Tests that services registered under a versioned namespace are callable under
+// both the versioned and the plain method prefix.
+func TestServerVersionedNamespace(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	for _, name := range []string{"test@", "test@0", "test@x", "@2"} {
+		if err := server.RegisterName(name, new(testServiceV2)); err == nil {
+			t.Errorf("invalid name %q accepted", name)
+		}
+	}
+	if err := server.RegisterName("test", new(testService)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("test@2", new(testServiceV2)); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	// The plain namespace retains its methods, the versioned one overrides them
+	var v1 echoResult
+	if err := client.Call(&v1, "test_echo", "x", 1, &echoArgs{"y"}); err != nil {
+		t.Fatalf("test_echo failed: %v", err)
+	}
+	if want := (echoResult{"x", 1, &echoArgs{"y"}}); !reflect.DeepEqual(v1, want) {
+		t.Errorf("test_echo result mismatch: have %v, want %v", v1, want)
+	}
+	var v2 string
+	if err := client.Call(&v2, "test2_echo", "x"); err != nil {
+		t.Fatalf("test2_echo failed: %v", err)
+	}
+	if v2 != "v2:x" {
+		t.Errorf("test2_echo result mismatch: have %q, want %q", v2, "v2:x")
+	}
+	// New methods of the versioned namespace are available under both prefixes
+	for _, method := range []string{"test_upgraded", "test2_upgraded"} {
+		var res string
+		if err := client.Call(&res, method); err != nil {
+			t.Fatalf("%s failed: %v", method, err)
+		}
+		if res != "upgraded" {
+			t.Errorf("%s result mismatch: have %q, want %q", method, res, "upgraded")
+		}
+	}
+	var versions map[string][]int
+	if err := client.Call(&versions, "rpc_supportedVersions"); err != nil {
+		t.Fatalf("rpc_supportedVersions failed: %v", err)
+	}
+	if want := map[string][]int{"rpc": {1}, "test": {1, 2}}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("supported versions mismatch: have %v, want %v", versions, want)
+	}
+}
+
+// Tests that versioned APIs are enabled by the module whitelist of their plain
+// namespace on the HTTP endpoint.
+func TestHTTPEndpointVersionedNamespace(t *testing.T) {
+	apis := []API{
+		{Namespace: "test", Service: new(testService)},
+		{Namespace: "test@2", Service: new(testServiceV2)},
+	}
+	listener, server, err := StartHTTPEndpoint("127.0.0.1:0", apis, []string{"test"}, nil, []string{"*"}, DefaultHTTPTimeouts)
+	if err != nil {
+		t.Fatalf("failed to start HTTP endpoint: %v", err)
+	}
+	defer server.Stop()
+	defer listener.Close()
+
+	client, err := DialHTTP("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial HTTP endpoint: %v", err)
+	}
+	defer client.Close()
+
+	var res string
+	if err := client.Call(&res, "test2_echo", "x"); err != nil {
+		t.Fatalf("test2_echo failed: %v", err)
+	}
+	if res != "v2:x" {
+		t.Errorf("test2_echo result mismatch: have %q, want %q", res, "v2:x")
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"unicode"
@@ -37,8 +39,10 @@ var (
 )
 
 type serviceRegistry struct {
+	active   int64 // number of calls currently executing, accessed atomically (keep first for alignment)
 	mu       sync.Mutex
 	services map[string]service
+	versions map[string]map[int]bool // supported versions of each namespace
 }
 
 // service represents a registered object.
@@ -58,11 +62,35 @@ type callback struct {
 	isSubscribe bool           // true if this is a subscription callback
 }
 
+// parseNamespace splits a "namespace@version" service name into its parts. Names
+// without a version suffix are returned with version zero.
+func parseNamespace(name string) (string, int, error) {
+	pos := strings.LastIndex(name, "@")
+	if pos < 0 {
+		return name, 0, nil
+	}
+	version, err := strconv.Atoi(name[pos+1:])
+	if err != nil || version < 1 || pos == 0 {
+		return "", 0, fmt.Errorf("invalid versioned service name %q", name)
+	}
+	return name[:pos], version, nil
+}
+
+// registerName registers the methods of a receiver under the given service name.
+//
+// A name in the form of "namespace@version" registers the methods both under the
+// versioned namespace (e.g. "eth2_call" for "eth@2") and, for backward compatibility,
+// under the plain one ("eth_call"). Methods already available in the plain namespace
+// are not overridden by versioned registrations.
 func (r *serviceRegistry) registerName(name string, rcvr interface{}) error {
 	rcvrVal := reflect.ValueOf(rcvr)
 	if name == "" {
 		return fmt.Errorf("no service name for type %s", rcvrVal.Type().String())
 	}
+	namespace, version, err := parseNamespace(name)
+	if err != nil {
+		return err
+	}
 	callbacks := suitableCallbacks(rcvrVal)
 	if len(callbacks) == 0 {
 		return fmt.Errorf("service %T doesn't have any suitable methods/subscriptions to expose", rcvr)
@@ -73,6 +101,27 @@ func (r *serviceRegistry) registerName(name string, rcvr interface{}) error {
 	if r.services == nil {
 		r.services = make(map[string]service)
 	}
+	if r.versions == nil {
+		r.versions = make(map[string]map[int]bool)
+	}
+	if r.versions[namespace] == nil {
+		r.versions[namespace] = make(map[int]bool)
+	}
+	if version == 0 {
+		r.addCallbacks(namespace, callbacks, true)
+		r.versions[namespace][1] = true
+	} else {
+		r.addCallbacks(namespace+strconv.Itoa(version), callbacks, true)
+		r.addCallbacks(namespace, callbacks, false)
+		r.versions[namespace][version] = true
+	}
+	return nil
+}
+
+// addCallbacks adds methods and subscriptions to the named service, creating it
+// if needed. Existing ones are only replaced if override is set. The caller must
+// hold the lock.
+func (r *serviceRegistry) addCallbacks(name string, callbacks map[string]*callback, override bool) {
 	svc, ok := r.services[name]
 	if !ok {
 		svc = service{
@@ -83,13 +132,30 @@ func (r *serviceRegistry) registerName(name string, rcvr interface{}) error {
 		r.services[name] = svc
 	}
 	for name, cb := range callbacks {
+		set := svc.callbacks
 		if cb.isSubscribe {
-			svc.subscriptions[name] = cb
-		} else {
-			svc.callbacks[name] = cb
+			set = svc.subscriptions
+		}
+		if _, exists := set[name]; !exists || override {
+			set[name] = cb
 		}
 	}
-	return nil
+}
+
+// supportedVersions returns the sorted list of registered versions of each
+// namespace. Namespaces registered without a version count as version 1.
+func (r *serviceRegistry) supportedVersions() map[string][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions := make(map[string][]int, len(r.versions))
+	for namespace, set := range r.versions {
+		for version := range set {
+			versions[namespace] = append(versions[namespace], version)
+		}
+		sort.Ints(versions[namespace])
+	}
+	return versions
 }
 
 // callback returns the callback corresponding to the given RPC method name.
@@ -17,10 +17,12 @@
 package trie
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -54,6 +56,12 @@ var (
 	memcacheCommitTimeTimer  = metrics.NewRegisteredResettingTimer("trie/memcache/commit/time", nil)
 	memcacheCommitNodesMeter = metrics.NewRegisteredMeter("trie/memcache/commit/nodes", nil)
 	memcacheCommitSizeMeter  = metrics.NewRegisteredMeter("trie/memcache/commit/size", nil)
+	memcacheCommitDedupMeter = metrics.NewRegisteredMeter("trie/memcache/commit/dedup", nil)
+
+	memcacheDirtyNodesGauge    = metrics.NewRegisteredGauge("trie/memcache/dirty/nodes", nil)
+	memcacheDirtySizeGauge     = metrics.NewRegisteredGauge("trie/memcache/dirty/size", nil)
+	memcachePreimagesSizeGauge = metrics.NewRegisteredGauge("trie/memcache/preimages/size", nil)
+	memcacheCapFlushMeter      = metrics.NewRegisteredMeter("trie/memcache/cap/flush", nil)
 )
 
 // secureKeyPrefix is the database key prefix used to store trie node preimages.
@@ -89,6 +97,13 @@ type Database struct {
 	flushnodes uint64             // Nodes flushed since last commit
 	flushsize  common.StorageSize // Data storage flushed since last commit
 
+	commitnodes uint64        // Nodes written by the last commit
+	commitdedup uint64        // Nodes skipped by the last commit as already persisted
+	committime  time.Duration // Time spent on the last commit
+
+	dirtyCap   common.StorageSize // Hard memory cap forcing synchronous flushes (0 = disabled)
+	capflushes uint64             // Number of flushes forced by the hard memory cap
+
 	dirtiesSize   common.StorageSize // Storage size of the dirty node cache (exc. metadata)
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
@@ -348,6 +363,7 @@ func (db *Database) insert(hash common.Hash, blob []byte, node node) {
 		db.dirties[db.newest].flushNext, db.newest = hash, hash
 	}
 	db.dirtiesSize += common.StorageSize(common.HashLength + entry.size)
+	db.updateSizeGauges()
 }
 
 // insertPreimage writes a new trie node pre-image to the memory database if it's
@@ -360,6 +376,7 @@ func (db *Database) insertPreimage(hash common.Hash, preimage []byte) {
 	}
 	db.preimages[hash] = common.CopyBytes(preimage)
 	db.preimagesSize += common.StorageSize(common.HashLength + len(preimage))
+	memcachePreimagesSizeGauge.Update(int64(db.preimagesSize))
 }
 
 // node retrieves a cached trie node from memory, or returns nil if none can be
@@ -478,11 +495,18 @@ func (db *Database) Nodes() []common.Hash {
 }
 
 // Reference adds a new reference from a parent node to a child node.
+//
+// If a hard memory cap is configured and a new root reference (i.e. parent being
+// the meta root) pushes the dirty cache above it, old nodes are flushed to disk
+// synchronously before returning.
 func (db *Database) Reference(child common.Hash, parent common.Hash) {
 	db.lock.Lock()
-	defer db.lock.Unlock()
-
 	db.reference(child, parent)
+	db.lock.Unlock()
+
+	if parent == (common.Hash{}) {
+		db.enforceDirtyCap()
+	}
 }
 
 // reference is the private locked version of Reference.
@@ -522,6 +546,7 @@ func (db *Database) Dereference(root common.Hash) {
 	db.gcnodes += uint64(nodes - len(db.dirties))
 	db.gcsize += storage - db.dirtiesSize
 	db.gctime += time.Since(start)
+	db.updateSizeGauges()
 
 	memcacheGCTimeTimer.Update(time.Since(start))
 	memcacheGCSizeMeter.Mark(int64(storage - db.dirtiesSize))
@@ -597,8 +622,7 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	// db.dirtiesSize only contains the useful data in the cache, but when reporting
 	// the total memory consumption, the maintenance metadata is also needed to be
 	// counted.
-	size := db.dirtiesSize + common.StorageSize((len(db.dirties)-1)*cachedNodeSize)
-	size += db.childrenSize - common.StorageSize(len(db.dirties[common.Hash{}].children)*(common.HashLength+2))
+	size := db.dirtySize()
 
 	// If the preimage cache got large enough, push to disk. If it's still small
 	// leave for later to deduplicate writes.
@@ -671,6 +695,7 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	db.flushnodes += uint64(nodes - len(db.dirties))
 	db.flushsize += storage - db.dirtiesSize
 	db.flushtime += time.Since(start)
+	db.updateSizeGauges()
 
 	memcacheFlushTimeTimer.Update(time.Since(start))
 	memcacheFlushSizeMeter.Mark(int64(storage - db.dirtiesSize))
@@ -689,6 +714,13 @@ func (db *Database) Cap(limit common.StorageSize) error {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Commit(node common.Hash, report bool) error {
+	return db.CommitWithProgress(node, report, nil)
+}
+
+// CommitWithProgress is like Commit, but it reports the size of the dirty cache
+// to progress each time a batch of nodes was written to disk, allowing callers
+// to track long running commits.
+func (db *Database) CommitWithProgress(node common.Hash, report bool, progress func(dirty common.StorageSize)) error {
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -720,8 +752,9 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.dirties), db.dirtiesSize
 
-	uncacher := &cleaner{db}
-	if err := db.commit(node, batch, uncacher); err != nil {
+	var dedup uint64
+	uncacher := &cleaner{db: db, progress: progress}
+	if err := db.commit(node, batch, uncacher, &dedup); err != nil {
 		log.Error("Failed to commit trie from trie database", "err", err)
 		return err
 	}
@@ -741,9 +774,13 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	db.preimages = make(map[common.Hash][]byte)
 	db.preimagesSize = 0
 
+	db.commitnodes, db.commitdedup, db.committime = uint64(nodes-len(db.dirties)), dedup, time.Since(start)
+	db.updateSizeGauges()
+
 	memcacheCommitTimeTimer.Update(time.Since(start))
 	memcacheCommitSizeMeter.Mark(int64(storage - db.dirtiesSize))
 	memcacheCommitNodesMeter.Mark(int64(nodes - len(db.dirties)))
+	memcacheCommitDedupMeter.Mark(int64(dedup))
 
 	logger := log.Info
 	if !report {
@@ -759,15 +796,17 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	return nil
 }
 
-// commit is the private locked version of Commit.
-func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleaner) error {
+// commit is the private locked version of Commit. The number of nodes skipped as
+// already persisted is accumulated into dedup.
+func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleaner, dedup *uint64) error {
 	// If the node does not exist, it's a previously committed node
 	node, ok := db.dirties[hash]
 	if !ok {
+		*dedup++
 		return nil
 	}
 	for _, child := range node.childs() {
-		if err := db.commit(child, batch, uncacher); err != nil {
+		if err := db.commit(child, batch, uncacher, dedup); err != nil {
 			return err
 		}
 	}
@@ -782,7 +821,12 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 		db.lock.Lock()
 		batch.Replay(uncacher)
 		batch.Reset()
+		dirty := db.dirtySize()
 		db.lock.Unlock()
+
+		if uncacher.progress != nil {
+			uncacher.progress(dirty)
+		}
 	}
 	return nil
 }
@@ -790,7 +834,8 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 // cleaner is a database batch replayer that takes a batch of write operations
 // and cleans up the trie database from anything written to disk.
 type cleaner struct {
-	db *Database
+	db       *Database
+	progress func(dirty common.StorageSize) // Notified of the dirty cache size after each flush, if set
 }
 
 // Put reacts to database writes and implements dirty data uncaching. This is the
@@ -836,16 +881,175 @@ func (c *cleaner) Delete(key []byte) error {
 	panic("not implemented")
 }
 
+// ResetCleanCache drops all the clean trie nodes cached in memory, so they are
+// loaded from the persistent database again. It must be called if trie nodes
+// were deleted from disk, otherwise they'd remain accessible from the cache.
+func (db *Database) ResetCleanCache() {
+	if db.cleans != nil {
+		db.cleans.Reset()
+	}
+}
+
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
-	// db.dirtiesSize only contains the useful data in the cache, but when reporting
-	// the total memory consumption, the maintenance metadata is also needed to be
-	// counted.
+	return db.dirtySize(), db.preimagesSize
+}
+
+// dirtySize returns the total memory usage of the dirty node cache. The useful
+// data in the cache is tracked by db.dirtiesSize, but when reporting the total
+// memory consumption, the maintenance metadata is also needed to be counted.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) dirtySize() common.StorageSize {
 	var metadataSize = common.StorageSize((len(db.dirties) - 1) * cachedNodeSize)
 	var metarootRefs = common.StorageSize(len(db.dirties[common.Hash{}].children) * (common.HashLength + 2))
-	return db.dirtiesSize + db.childrenSize + metadataSize - metarootRefs, db.preimagesSize
+	return db.dirtiesSize + db.childrenSize + metadataSize - metarootRefs
+}
+
+// updateSizeGauges reports the current size of the memory caches to the metrics
+// system.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) updateSizeGauges() {
+	memcacheDirtyNodesGauge.Update(int64(len(db.dirties) - 1))
+	memcacheDirtySizeGauge.Update(int64(db.dirtySize()))
+	memcachePreimagesSizeGauge.Update(int64(db.preimagesSize))
+}
+
+// SetDirtyCap sets a hard memory cap for the dirty node cache. Whenever a newly
+// referenced root pushes the cache above the cap, old nodes are flushed to disk
+// synchronously, instead of letting the cache grow unbounded if blocks arrive
+// faster than they are flushed. Zero disables the cap.
+func (db *Database) SetDirtyCap(limit common.StorageSize) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.dirtyCap = limit
+}
+
+// enforceDirtyCap flushes old nodes from the dirty cache if its size exceeds the
+// hard memory cap.
+//
+// Note, this method is a non-synchronized mutator. It is unsafe to call this
+// concurrently with other mutators.
+func (db *Database) enforceDirtyCap() {
+	db.lock.RLock()
+	limit, size := db.dirtyCap, db.dirtySize()
+	db.lock.RUnlock()
+
+	if limit == 0 || size <= limit {
+		return
+	}
+	log.Warn("Trie cache exceeded memory cap, flushing", "size", size, "cap", limit)
+
+	// Flush a bit more than necessary to avoid flushing on every new root
+	target := limit - ethdb.IdealBatchSize
+	if target < limit/2 {
+		target = limit / 2
+	}
+	if err := db.Cap(target); err != nil {
+		log.Error("Failed to flush trie cache", "err", err)
+		return
+	}
+	db.lock.Lock()
+	db.capflushes++
+	db.lock.Unlock()
+
+	memcacheCapFlushMeter.Mark(1)
+}
+
+// DatabaseStats is a summary of the memory usage and the flush activity of the
+// trie database.
+type DatabaseStats struct {
+	DirtyNodes    int                `json:"dirtyNodes"`    // Number of nodes in the dirty cache
+	DirtySize     common.StorageSize `json:"dirtySize"`     // Memory usage of the dirty cache, including metadata
+	DirtyCap      common.StorageSize `json:"dirtyCap"`      // Hard memory cap of the dirty cache (0 = disabled)
+	PreimagesSize common.StorageSize `json:"preimagesSize"` // Memory usage of the preimage cache
+
+	GCNodes    uint64             `json:"gcNodes"`    // Nodes garbage collected since the last commit
+	GCSize     common.StorageSize `json:"gcSize"`     // Data garbage collected since the last commit
+	GCTime     time.Duration      `json:"gcTime"`     // Time spent on garbage collection since the last commit
+	FlushNodes uint64             `json:"flushNodes"` // Nodes flushed since the last commit
+	FlushSize  common.StorageSize `json:"flushSize"`  // Data flushed since the last commit
+	FlushTime  time.Duration      `json:"flushTime"`  // Time spent on flushing since the last commit
+	CapFlushes uint64             `json:"capFlushes"` // Number of flushes forced by the memory cap
+
+	CommitNodes uint64        `json:"commitNodes"` // Nodes written by the last commit
+	CommitDedup uint64        `json:"commitDedup"` // Nodes skipped by the last commit as already persisted
+	CommitTime  time.Duration `json:"commitTime"`  // Time spent on the last commit
+
+	Roots []RootStats `json:"roots"` // Roots referenced in memory, largest first
+}
+
+// RootStats is the memory retained by a root referenced in the trie database.
+type RootStats struct {
+	Root  common.Hash        `json:"root"`
+	Refs  uint16             `json:"refs"`  // Number of references held on the root
+	Nodes int                `json:"nodes"` // Number of dirty nodes reachable from the root
+	Size  common.StorageSize `json:"size"`  // Size of the dirty nodes reachable from the root
+}
+
+// Stats returns a summary of the memory usage and the flush activity of the trie
+// database. Nodes shared between referenced roots are accounted to each of them.
+//
+// Note, this method traverses all the tries retained in memory, so it's expensive
+// and meant for diagnostics only.
+func (db *Database) Stats() *DatabaseStats {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	stats := &DatabaseStats{
+		DirtyNodes:    len(db.dirties) - 1,
+		DirtySize:     db.dirtySize(),
+		DirtyCap:      db.dirtyCap,
+		PreimagesSize: db.preimagesSize,
+		GCNodes:       db.gcnodes,
+		GCSize:        db.gcsize,
+		GCTime:        db.gctime,
+		FlushNodes:    db.flushnodes,
+		FlushSize:     db.flushsize,
+		FlushTime:     db.flushtime,
+		CapFlushes:    db.capflushes,
+		CommitNodes:   db.commitnodes,
+		CommitDedup:   db.commitdedup,
+		CommitTime:    db.committime,
+		Roots:         []RootStats{},
+	}
+	for root, refs := range db.dirties[common.Hash{}].children {
+		rs := RootStats{Root: root, Refs: refs}
+		db.retained(root, make(map[common.Hash]struct{}), &rs)
+		stats.Roots = append(stats.Roots, rs)
+	}
+	sort.Slice(stats.Roots, func(i, j int) bool {
+		if stats.Roots[i].Size != stats.Roots[j].Size {
+			return stats.Roots[i].Size > stats.Roots[j].Size
+		}
+		return bytes.Compare(stats.Roots[i].Root[:], stats.Roots[j].Root[:]) < 0
+	})
+	return stats
+}
+
+// retained accumulates the number and size of the dirty nodes reachable from
+// the given node into the root stats.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) retained(hash common.Hash, seen map[common.Hash]struct{}, stats *RootStats) {
+	if _, ok := seen[hash]; ok {
+		return
+	}
+	node, ok := db.dirties[hash]
+	if !ok {
+		return
+	}
+	seen[hash] = struct{}{}
+
+	stats.Nodes++
+	stats.Size += common.StorageSize(common.HashLength + int(node.size))
+	for _, child := range node.childs() {
+		db.retained(child, seen, stats)
+	}
 }
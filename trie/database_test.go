@@ -17,9 +17,11 @@
 package trie
 
 import (
+	"encoding/binary"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 )
 
@@ -31,3 +33,135 @@ func TestDatabaseMetarootFetch(t *testing.T) {
 		t.Fatalf("metaroot retrieval succeeded")
 	}
 }
+
+// fillDatabase simulates block processing, creating a new state root with the
+// given number of updated keys per block and referencing it in the database.
+func fillDatabase(t *testing.T, db *Database, blocks, updates int, check func(root common.Hash)) common.Hash {
+	var root common.Hash
+	for i := 0; i < blocks; i++ {
+		tr, err := New(root, db)
+		if err != nil {
+			t.Fatalf("block %d: failed to open trie: %v", i, err)
+		}
+		for j := 0; j < updates; j++ {
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(i*updates+j))
+			tr.Update(crypto.Keccak256(key), crypto.Keccak256(key, []byte{byte(i)}))
+		}
+		if root, err = tr.Commit(nil); err != nil {
+			t.Fatalf("block %d: failed to commit trie: %v", i, err)
+		}
+		db.Reference(root, common.Hash{})
+		if check != nil {
+			check(root)
+		}
+	}
+	return root
+}
+
+// Tests that the hard memory cap forces flushes of the dirty cache, keeping it
+// bounded, while all the referenced state remains accessible.
+func TestDatabaseDirtyCap(t *testing.T) {
+	const limit = 256 * 1024
+
+	// Without a cap, the dirty cache grows unbounded
+	db := NewDatabase(memorydb.New())
+	fillDatabase(t, db, 100, 50, nil)
+	if size, _ := db.Size(); size <= limit {
+		t.Fatalf("uncapped cache too small for the test: %v", size)
+	}
+	if stats := db.Stats(); stats.CapFlushes != 0 {
+		t.Fatalf("flushes forced without cap: %d", stats.CapFlushes)
+	}
+	// With a cap, the dirty cache is flushed whenever a new root exceeds it
+	db = NewDatabase(memorydb.New())
+	db.SetDirtyCap(limit)
+
+	root := fillDatabase(t, db, 100, 50, func(root common.Hash) {
+		if size, _ := db.Size(); size > limit {
+			t.Fatalf("dirty cache above cap: have %v, cap %v", size, common.StorageSize(limit))
+		}
+	})
+	stats := db.Stats()
+	if stats.CapFlushes == 0 {
+		t.Fatal("no flushes forced by the cap")
+	}
+	if stats.FlushNodes == 0 {
+		t.Fatal("no nodes flushed")
+	}
+	if len(stats.Roots) != 100 {
+		t.Fatalf("referenced root count mismatch: have %d, want 100", len(stats.Roots))
+	}
+	tr, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open latest trie: %v", err)
+	}
+	for i := 0; i < 100*50; i++ {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(i))
+		if _, err := tr.TryGet(crypto.Keccak256(key)); err != nil {
+			t.Fatalf("key %d inaccessible: %v", i, err)
+		}
+	}
+}
+
+// Tests that the database stats track the referenced roots and the commits.
+func TestDatabaseStats(t *testing.T) {
+	db := NewDatabase(memorydb.New())
+	root := fillDatabase(t, db, 3, 20, nil)
+
+	stats := db.Stats()
+	if stats.DirtyNodes == 0 || stats.DirtySize == 0 {
+		t.Fatalf("dirty cache not tracked: %d nodes, %v", stats.DirtyNodes, stats.DirtySize)
+	}
+	if len(stats.Roots) != 3 {
+		t.Fatalf("referenced root count mismatch: have %d, want 3", len(stats.Roots))
+	}
+	for i, rs := range stats.Roots {
+		if rs.Refs != 1 || rs.Nodes == 0 {
+			t.Errorf("root %d: invalid stats %+v", i, rs)
+		}
+		if i > 0 && rs.Size > stats.Roots[i-1].Size {
+			t.Errorf("root %d: not sorted by size", i)
+		}
+	}
+	// The latest root retains the most data, as it references all the keys
+	if stats.Roots[0].Root != root {
+		t.Errorf("largest root mismatch: have %x, want %x", stats.Roots[0].Root, root)
+	}
+	// Commit an older root, then the latest one sharing nodes with it
+	oldest := stats.Roots[2]
+	if err := db.Commit(oldest.Root, false); err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.Stats(); stats.CommitNodes != uint64(oldest.Nodes) {
+		t.Errorf("committed node count mismatch: have %d, want %d", stats.CommitNodes, oldest.Nodes)
+	}
+	if err := db.Commit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.Stats(); stats.CommitNodes == 0 || stats.CommitDedup == 0 {
+		t.Errorf("commit not tracked: %d nodes written, %d deduplicated", stats.CommitNodes, stats.CommitDedup)
+	}
+}
+
+// Tests that long commits report the shrinking dirty cache while flushing.
+func TestDatabaseCommitProgress(t *testing.T) {
+	db := NewDatabase(memorydb.New())
+	root := fillDatabase(t, db, 1, 10000, nil)
+
+	var reports []common.StorageSize
+	if err := db.CommitWithProgress(root, false, func(dirty common.StorageSize) {
+		reports = append(reports, dirty)
+	}); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if len(reports) == 0 {
+		t.Fatalf("no progress reported")
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] >= reports[i-1] {
+			t.Errorf("report %d: dirty cache not shrinking: have %v, previous %v", i, reports[i], reports[i-1])
+		}
+	}
+}
@@ -102,8 +102,26 @@ type NodeIterator interface {
 	// iterator is not positioned at a leaf. Callers must not retain references
 	// to the value after calling Next.
 	LeafProof() [][]byte
+
+	// Position returns a compact encoding of the iteration state, which can be
+	// persisted and used to resume iterating after the current node, as long as
+	// the trie is still available. Nil is returned for iterators which have not
+	// been started, or which can't be resumed.
+	Position() []byte
+
+	// Seek moves the iterator to just before the first node whose path is not
+	// less than the hex-encoded key prefix, as if the iterator was created with
+	// it as the start key. The next call to Next returns that node.
+	Seek(prefix []byte)
 }
 
+// positionEnd is the position of iterators which have reached the end.
+var positionEnd = []byte{0xff}
+
+// errInvalidPosition is returned when resuming an iterator at a position which
+// is malformed or doesn't exist in the trie.
+var errInvalidPosition = errors.New("invalid iterator position")
+
 // nodeIteratorState represents the iteration state at one particular node of the
 // trie, which can be resumed at a later invocation.
 type nodeIteratorState struct {
@@ -206,6 +224,24 @@ func (it *nodeIterator) Path() []byte {
 	return it.path
 }
 
+func (it *nodeIterator) Position() []byte {
+	if it.err == errIteratorEnd {
+		return common.CopyBytes(positionEnd)
+	}
+	if len(it.stack) == 0 {
+		return nil
+	}
+	return hexToCompact(it.path)
+}
+
+func (it *nodeIterator) Seek(prefix []byte) {
+	if it.trie == nil {
+		return // empty trie
+	}
+	it.stack, it.path = nil, nil
+	it.err = it.seek(prefix)
+}
+
 func (it *nodeIterator) Error() error {
 	if it.err == errIteratorEnd {
 		return nil
@@ -257,6 +293,24 @@ func (it *nodeIterator) seek(prefix []byte) error {
 	}
 }
 
+// resume moves the iterator to the node at the given hex path, rebuilding the
+// iteration state as if the iterator was advanced up to the node.
+func (it *nodeIterator) resume(path []byte) error {
+	for len(it.stack) == 0 || !bytes.Equal(it.path, path) {
+		state, parentIndex, next, err := it.peek(bytes.HasPrefix(path, it.path))
+		if err == errIteratorEnd {
+			return errInvalidPosition
+		} else if err != nil {
+			return err
+		}
+		if bytes.Compare(next, path) > 0 {
+			return errInvalidPosition // the node doesn't exist
+		}
+		it.push(state, parentIndex, next)
+	}
+	return nil
+}
+
 // peek creates the next state of the iterator.
 func (it *nodeIterator) peek(descend bool) (*nodeIteratorState, *int, []byte, error) {
 	if len(it.stack) == 0 {
@@ -422,6 +476,23 @@ func (it *differenceIterator) Path() []byte {
 	return it.b.Path()
 }
 
+// Position encodes the positions of both iterators. It returns nil if any of
+// them can't be resumed.
+func (it *differenceIterator) Position() []byte {
+	a, b := it.a.Position(), it.b.Position()
+	if a == nil {
+		return nil
+	}
+	enc, _ := rlp.EncodeToBytes([][]byte{a, b})
+	return enc
+}
+
+func (it *differenceIterator) Seek(prefix []byte) {
+	it.b.Seek(prefix)
+	it.a.Seek(prefix)
+	it.eof = !it.a.Next(true)
+}
+
 func (it *differenceIterator) Next(bool) bool {
 	// Invariants:
 	// - We always advance at least one element in b.
@@ -485,6 +556,7 @@ func (h *nodeIteratorHeap) Pop() interface{} {
 }
 
 type unionIterator struct {
+	iters []NodeIterator    // Iterators of all tries, including the exhausted ones
 	items *nodeIteratorHeap // Nodes returned are the union of the ones in these iterators
 	count int               // Number of nodes scanned across all tries
 }
@@ -497,7 +569,7 @@ func NewUnionIterator(iters []NodeIterator) (NodeIterator, *int) {
 	copy(h, iters)
 	heap.Init(&h)
 
-	ui := &unionIterator{items: &h}
+	ui := &unionIterator{iters: iters, items: &h}
 	return ui, &ui.count
 }
 
@@ -569,6 +641,21 @@ func (it *unionIterator) Next(descend bool) bool {
 	return len(*it.items) > 0
 }
 
+// Position returns nil, union iterators can't be resumed.
+func (it *unionIterator) Position() []byte {
+	return nil
+}
+
+func (it *unionIterator) Seek(prefix []byte) {
+	h := make(nodeIteratorHeap, 0, len(it.iters))
+	for _, iter := range it.iters {
+		iter.Seek(prefix)
+		h = append(h, iter)
+	}
+	heap.Init(&h)
+	it.items = &h
+}
+
 func (it *unionIterator) Error() error {
 	for i := 0; i < len(*it.items); i++ {
 		if err := (*it.items)[i].Error(); err != nil {
@@ -577,3 +664,61 @@ func (it *unionIterator) Error() error {
 	}
 	return nil
 }
+
+// NodeIteratorAt returns an iterator positioned at the node identified by the
+// given position, which was obtained from an iterator of the same trie. The
+// next call to Next continues after that node.
+func (t *Trie) NodeIteratorAt(position []byte) (NodeIterator, error) {
+	if t.Hash() == emptyState {
+		return new(nodeIterator), nil
+	}
+	it := &nodeIterator{trie: t}
+	switch {
+	case len(position) == 0:
+		return it, nil
+	case bytes.Equal(position, positionEnd):
+		it.err = errIteratorEnd
+		return it, nil
+	}
+	path := compactToHex(position)
+	if !bytes.Equal(hexToCompact(path), position) {
+		return nil, errInvalidPosition
+	}
+	if err := it.resume(path); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// NewIteratorAt opens the trie with the given root and returns an iterator
+// resuming at the position obtained from an earlier iterator of the trie.
+func NewIteratorAt(root common.Hash, db *Database, position []byte) (NodeIterator, error) {
+	tr, err := New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	return tr.NodeIteratorAt(position)
+}
+
+// NewDifferenceIteratorAt resumes a difference iterator over the nodes of b not
+// in a at a position obtained from an earlier one of the same tries.
+func NewDifferenceIteratorAt(a, b *Trie, position []byte) (NodeIterator, *int, error) {
+	var positions [][]byte
+	if err := rlp.DecodeBytes(position, &positions); err != nil || len(positions) != 2 {
+		return nil, nil, errInvalidPosition
+	}
+	ait, err := a.NodeIteratorAt(positions[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	bit, err := b.NodeIteratorAt(positions[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	it := &differenceIterator{
+		a:   ait,
+		b:   bit,
+		eof: bytes.Equal(positions[0], positionEnd),
+	}
+	return it, &it.count, nil
+}
@@ -19,7 +19,9 @@ package trie
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -440,3 +442,152 @@ func checkIteratorNoDups(t *testing.T, it NodeIterator, seen map[string]bool) in
 	}
 	return len(seen)
 }
+
+// iteratedNode is the record of a node visited by an iterator.
+type iteratedNode struct {
+	path string
+	hash common.Hash
+}
+
+// makeLargeTestTrie creates a random trie of the given number of leaves, stored
+// in a fresh database.
+func makeLargeTestTrie(n int, diskdb *memorydb.Database) common.Hash {
+	tr, _ := New(common.Hash{}, NewDatabase(diskdb))
+	for i := 0; i < n; i++ {
+		tr.Update(randBytes(32), randBytes(1+rand.Intn(40)))
+	}
+	root, _ := tr.Commit(nil)
+	tr.db.Commit(root, false)
+	return root
+}
+
+// iterateInChunks visits all nodes of an iterator, reopening it every chunk
+// nodes at the persisted position.
+func iterateInChunks(t *testing.T, chunk int, open func(position []byte) NodeIterator) []iteratedNode {
+	var (
+		nodes    []iteratedNode
+		position []byte
+	)
+	for {
+		it := open(position)
+		for i := 0; i < chunk; i++ {
+			if !it.Next(true) {
+				if err := it.Error(); err != nil {
+					t.Fatalf("iteration failed: %v", err)
+				}
+				return nodes
+			}
+			nodes = append(nodes, iteratedNode{string(it.Path()), it.Hash()})
+		}
+		position = it.Position()
+	}
+}
+
+func TestIteratorResume(t *testing.T) {
+	diskdb := memorydb.New()
+	root := makeLargeTestTrie(2000, diskdb)
+
+	tr, _ := New(root, NewDatabase(diskdb))
+	want := iterateInChunks(t, math.MaxInt32, func([]byte) NodeIterator { return tr.NodeIterator(nil) })
+
+	for _, chunk := range []int{1, 7, 100} {
+		have := iterateInChunks(t, chunk, func(position []byte) NodeIterator {
+			// Open the trie from scratch to simulate a restart
+			it, err := NewIteratorAt(root, NewDatabase(diskdb), position)
+			if err != nil {
+				t.Fatalf("chunk %d: failed to resume iterator at %x: %v", chunk, position, err)
+			}
+			return it
+		})
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("chunk %d: resumed iteration mismatch: have %d nodes, want %d", chunk, len(have), len(want))
+		}
+	}
+	// Resuming at the end must not yield anything
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+	}
+	if it, _ := tr.NodeIteratorAt(it.Position()); it.Next(true) {
+		t.Fatalf("iterator resumed at the end returned node %x", it.Path())
+	}
+	// Malformed and non-existent positions must be rejected
+	for _, position := range [][]byte{{0x41}, {0x20, 0x12, 0x34, 0x56, 0x78, 0x9a}, hexToCompact([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})} {
+		if _, err := tr.NodeIteratorAt(position); err == nil {
+			t.Errorf("position %x accepted", position)
+		}
+	}
+}
+
+func TestDifferenceIteratorResume(t *testing.T) {
+	diskdb := memorydb.New()
+	triedb := NewDatabase(diskdb)
+
+	// Create two tries sharing most of their nodes
+	a, _ := New(common.Hash{}, triedb)
+	for i := 0; i < 1000; i++ {
+		a.Update(randBytes(32), randBytes(20))
+	}
+	rootA, _ := a.Commit(nil)
+	b, _ := New(rootA, triedb)
+	for i := 0; i < 100; i++ {
+		b.Update(randBytes(32), randBytes(20))
+	}
+	rootB, _ := b.Commit(nil)
+	triedb.Commit(rootA, false)
+	triedb.Commit(rootB, false)
+
+	open := func(a, b *Trie) NodeIterator {
+		it, _ := NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+		return it
+	}
+	want := iterateInChunks(t, math.MaxInt32, func([]byte) NodeIterator { return open(a, b) })
+
+	for _, chunk := range []int{1, 10} {
+		have := iterateInChunks(t, chunk, func(position []byte) NodeIterator {
+			triedb := NewDatabase(diskdb)
+			a, _ := New(rootA, triedb)
+			b, _ := New(rootB, triedb)
+			if position == nil {
+				return open(a, b)
+			}
+			it, _, err := NewDifferenceIteratorAt(a, b, position)
+			if err != nil {
+				t.Fatalf("chunk %d: failed to resume iterator: %v", chunk, err)
+			}
+			return it
+		})
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("chunk %d: resumed iteration mismatch: have %d nodes, want %d", chunk, len(have), len(want))
+		}
+	}
+}
+
+func TestIteratorSeekMethod(t *testing.T) {
+	trie := newEmpty()
+	for _, val := range testdata1 {
+		trie.Update([]byte(val.k), []byte(val.v))
+	}
+	// Seek forward and backward on an already advanced iterator
+	nodeIt := trie.NodeIterator(nil)
+	for i := 0; i < 5; i++ {
+		nodeIt.Next(true)
+	}
+	nodeIt.Seek([]byte("fab"))
+	if err := checkIteratorOrder(testdata1[4:], NewIterator(nodeIt)); err != nil {
+		t.Fatal(err)
+	}
+	nodeIt.Seek([]byte("barc"))
+	if err := checkIteratorOrder(testdata1[1:], NewIterator(nodeIt)); err != nil {
+		t.Fatal(err)
+	}
+	// Seek the difference iterator
+	trieb := newEmpty()
+	for _, val := range testdata2 {
+		trieb.Update([]byte(val.k), []byte(val.v))
+	}
+	di, _ := NewDifferenceIterator(trie.NodeIterator(nil), trieb.NodeIterator(nil))
+	di.Seek([]byte("bars"))
+	if err := checkIteratorOrder([]kvs{{"bars", "be"}, {"jars", "d"}}, NewIterator(di)); err != nil {
+		t.Fatal(err)
+	}
+}
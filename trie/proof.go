@@ -18,10 +18,12 @@ package trie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -115,7 +117,7 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb ethdb.KeyValueReader)
 		if err != nil {
 			return nil, i, fmt.Errorf("bad proof node %d: %v", i, err)
 		}
-		keyrest, cld := get(n, key)
+		keyrest, cld := get(n, key, true)
 		switch cld := cld.(type) {
 		case nil:
 			// The trie doesn't contain the key.
@@ -129,7 +131,10 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb ethdb.KeyValueReader)
 	}
 }
 
-func get(tn node, key []byte) ([]byte, node) {
+// get returns the child of tn on the path of key along with the remaining key.
+// If skipResolved is set, resolved nodes are traversed until a hash or value
+// node is reached, otherwise the direct child is returned.
+func get(tn node, key []byte, skipResolved bool) ([]byte, node) {
 	for {
 		switch n := tn.(type) {
 		case *shortNode:
@@ -138,9 +143,15 @@ func get(tn node, key []byte) ([]byte, node) {
 			}
 			tn = n.Val
 			key = key[len(n.Key):]
+			if !skipResolved {
+				return key, tn
+			}
 		case *fullNode:
 			tn = n.Children[key[0]]
 			key = key[1:]
+			if !skipResolved {
+				return key, tn
+			}
 		case hashNode:
 			return key, n
 		case nil:
@@ -152,3 +163,412 @@ func get(tn node, key []byte) ([]byte, node) {
 		}
 	}
 }
+
+// ProveRange constructs a range proof for all leaves with keys between firstKey
+// and lastKey (both inclusive). It returns the keys and values of the leaves in
+// ascending key order and writes the merkle proofs of the two edge keys into
+// proofDb. The edge keys don't need to exist in the trie.
+//
+// The keys, values and proof can be checked with VerifyRangeProof.
+func (t *Trie) ProveRange(firstKey, lastKey []byte, proofDb ethdb.KeyValueWriter) (keys, values [][]byte, err error) {
+	if bytes.Compare(firstKey, lastKey) > 0 {
+		return nil, nil, errors.New("invalid edge keys")
+	}
+	it := NewIterator(t.NodeIterator(firstKey))
+	for it.Next() {
+		if bytes.Compare(it.Key, lastKey) > 0 {
+			break
+		}
+		keys = append(keys, common.CopyBytes(it.Key))
+		values = append(values, common.CopyBytes(it.Value))
+	}
+	if it.Err != nil {
+		return nil, nil, it.Err
+	}
+	if err := t.Prove(firstKey, 0, proofDb); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(firstKey, lastKey) {
+		if err := t.Prove(lastKey, 0, proofDb); err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, values, nil
+}
+
+// VerifyRangeProof checks that keys and values are exactly the leaves with keys
+// between firstKey and lastKey (both inclusive) in the trie with the given root
+// hash, with the proof containing the merkle proofs of the two edge keys. The
+// edge keys don't need to exist in the trie. Missing, additional or modified
+// leaves within the range are rejected.
+//
+// The following special cases are supported:
+//   - If proof is nil, keys and values must be all leaves of the trie and the
+//     edge keys are ignored.
+//   - If keys is empty, the proof shows that there are no leaves in the range.
+//   - If firstKey equals lastKey, the proof is a single merkle proof of the
+//     element, or of its absence.
+//
+// All keys must have the same length, as in the state trie. The returned flag
+// reports whether the trie contains leaves beyond lastKey.
+func VerifyRangeProof(rootHash common.Hash, firstKey, lastKey []byte, keys, values [][]byte, proof ethdb.KeyValueReader) (bool, error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	for i, key := range keys {
+		if i > 0 && bytes.Compare(keys[i-1], key) >= 0 {
+			return false, errors.New("range is not monotonically increasing")
+		}
+		if len(values[i]) == 0 {
+			return false, fmt.Errorf("empty value for key %x", key)
+		}
+	}
+	// Without edge proofs, the leaves must make up the entire trie
+	if proof == nil {
+		tr := &Trie{db: NewDatabase(memorydb.New())}
+		for i, key := range keys {
+			if err := tr.TryUpdate(key, values[i]); err != nil {
+				return false, err
+			}
+		}
+		if have := tr.Hash(); have != rootHash {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+		}
+		return false, nil
+	}
+	if bytes.Compare(firstKey, lastKey) > 0 {
+		return false, errors.New("invalid edge keys")
+	}
+	if len(firstKey) != len(lastKey) {
+		return false, errors.New("inconsistent edge key lengths")
+	}
+	for _, key := range keys {
+		if len(key) != len(firstKey) {
+			return false, fmt.Errorf("inconsistent key length %d, edge keys have %d", len(key), len(firstKey))
+		}
+	}
+	if len(keys) > 0 && (bytes.Compare(keys[0], firstKey) < 0 || bytes.Compare(keys[len(keys)-1], lastKey) > 0) {
+		return false, errors.New("keys outside of range")
+	}
+	// Both edge keys are the same, so there's a single path. The range contains
+	// at most one leaf, which is proven directly.
+	if bytes.Equal(firstKey, lastKey) {
+		root, val, err := proofToPath(rootHash, nil, firstKey, proof, true)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case len(keys) == 0 && val != nil:
+			return false, errors.New("missing leaf in range")
+		case len(keys) == 1 && !bytes.Equal(val, values[0]):
+			return false, errors.New("correct proof but invalid data")
+		}
+		return hasRightElement(root, lastKey)
+	}
+	// Resolve the paths of both edge keys into a partial trie
+	root, _, err := proofToPath(rootHash, nil, firstKey, proof, true)
+	if err != nil {
+		return false, err
+	}
+	if root, _, err = proofToPath(rootHash, root, lastKey, proof, true); err != nil {
+		return false, err
+	}
+	more, err := hasRightElement(root, lastKey)
+	if err != nil {
+		return false, err
+	}
+
+	// Remove everything within the range from the partial trie and fill it in
+	// again from the given leaves, the result must match the original root.
+	empty, err := unsetInternal(root, firstKey, lastKey)
+	if err == errEmptyRange {
+		// The trie has no leaves in the range, but nothing to unset either
+		if len(keys) > 0 {
+			return false, errors.New("leaves outside of trie range")
+		}
+		return more, nil
+	} else if err != nil {
+		return false, err
+	}
+	tr := &Trie{root: root, db: NewDatabase(memorydb.New())}
+	if empty {
+		tr.root = nil
+	}
+	for i, key := range keys {
+		if err := tr.TryUpdate(key, values[i]); err != nil {
+			return false, err
+		}
+	}
+	if have := tr.Hash(); have != rootHash {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+	}
+	return more, nil
+}
+
+// errEmptyRange is returned by unsetInternal if both edge keys diverge from the
+// trie on the same side of a short node, so the trie has no leaves in the range.
+var errEmptyRange = errors.New("empty range")
+
+// proofToPath resolves the nodes on the path of key from the merkle proof and
+// links them into the partial trie rooted at root. Nodes off the path are left
+// as hash nodes. If root is nil, the root node is resolved from the proof too.
+//
+// If allowNonExistent is set, the proof may show the absence of key, in which
+// case the returned value is nil.
+func proofToPath(rootHash common.Hash, root node, key []byte, proofDb ethdb.KeyValueReader, allowNonExistent bool) (node, []byte, error) {
+	resolveNode := func(hash common.Hash) (node, error) {
+		buf, _ := proofDb.Get(hash[:])
+		if buf == nil {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash[:], buf)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %v", err)
+		}
+		return n, nil
+	}
+	if root == nil {
+		n, err := resolveNode(rootHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		root = n
+	}
+	var (
+		err           error
+		child, parent node
+		keyrest       []byte
+		valnode       []byte
+	)
+	key, parent = keybytesToHex(key), root
+	for {
+		keyrest, child = get(parent, key, false)
+		switch cld := child.(type) {
+		case nil:
+			// The trie doesn't contain the key, the resolved nodes prove its
+			// absence.
+			if allowNonExistent {
+				return root, nil, nil
+			}
+			return nil, nil, errors.New("the node is not contained in trie")
+		case *shortNode, *fullNode:
+			// Already resolved by an earlier path
+			key, parent = keyrest, child
+			continue
+		case hashNode:
+			child, err = resolveNode(common.BytesToHash(cld))
+			if err != nil {
+				return nil, nil, err
+			}
+		case valueNode:
+			valnode = cld
+		}
+		// Link the resolved child into its parent
+		switch pnode := parent.(type) {
+		case *shortNode:
+			pnode.Val = child
+		case *fullNode:
+			pnode.Children[key[0]] = child
+		default:
+			return nil, nil, fmt.Errorf("invalid proof: unexpected %T on path", pnode)
+		}
+		if len(valnode) > 0 {
+			return root, valnode, nil
+		}
+		key, parent = keyrest, child
+	}
+}
+
+// unsetInternal removes all nodes between the paths of the left and right keys
+// from the partial trie, along with the leaves at the edge keys themselves. The
+// remaining nodes are those outside of the range. The modified nodes are marked
+// dirty, so their hashes are recomputed.
+//
+// The returned flag is set if everything in the trie is within the range.
+func unsetInternal(n node, left []byte, right []byte) (bool, error) {
+	left, right = keybytesToHex(left), keybytesToHex(right)
+
+	// Step down to the fork point of the two paths, which is either a short
+	// node not matching one of the keys, or a full node where the paths take
+	// different (or missing) children.
+	var (
+		pos    = 0
+		parent node
+
+		// Position of the keys relative to the short node at the fork point:
+		// 0 if the key matches, -1 if it's smaller, 1 if it's larger
+		shortForkLeft, shortForkRight int
+	)
+findFork:
+	for {
+		switch rn := (n).(type) {
+		case *shortNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if len(left)-pos < len(rn.Key) {
+				shortForkLeft = bytes.Compare(left[pos:], rn.Key)
+			} else {
+				shortForkLeft = bytes.Compare(left[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if len(right)-pos < len(rn.Key) {
+				shortForkRight = bytes.Compare(right[pos:], rn.Key)
+			} else {
+				shortForkRight = bytes.Compare(right[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if shortForkLeft != 0 || shortForkRight != 0 {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Val, pos+len(rn.Key)
+		case *fullNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			leftnode, rightnode := rn.Children[left[pos]], rn.Children[right[pos]]
+			if leftnode == nil || rightnode == nil || left[pos] != right[pos] {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Children[left[pos]], pos+1
+		default:
+			return false, fmt.Errorf("invalid proof: unexpected %T on path", n)
+		}
+	}
+	switch rn := n.(type) {
+	case *shortNode:
+		// Both keys on the same side of the short node, nothing is in range
+		if shortForkLeft == shortForkRight {
+			return false, errEmptyRange
+		}
+		// The short node is entirely within the range, remove it
+		if shortForkLeft != 0 && shortForkRight != 0 {
+			if parent == nil {
+				return true, nil
+			}
+			return false, unlinkChild(parent, left[pos-1])
+		}
+		// Only one of the keys diverges from the short node, remove what's
+		// within the range on the path of the other.
+		if shortForkRight != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				return false, unlinkChild(parent, left[pos-1])
+			}
+			return false, unset(rn, rn.Val, left[pos:], len(rn.Key), false)
+		}
+		if _, ok := rn.Val.(valueNode); ok {
+			if parent == nil {
+				return true, nil
+			}
+			return false, unlinkChild(parent, right[pos-1])
+		}
+		return false, unset(rn, rn.Val, right[pos:], len(rn.Key), true)
+
+	case *fullNode:
+		// Remove all children between the paths, then everything within the
+		// range along both paths.
+		for i := left[pos] + 1; i < right[pos]; i++ {
+			rn.Children[i] = nil
+		}
+		if err := unset(rn, rn.Children[left[pos]], left[pos:], 1, false); err != nil {
+			return false, err
+		}
+		if err := unset(rn, rn.Children[right[pos]], right[pos:], 1, true); err != nil {
+			return false, err
+		}
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("invalid proof: unexpected %T at fork point", n)
+	}
+}
+
+// unset removes the nodes on one side of the path of key below child, which is
+// the child of parent at position pos of the key. If removeLeft is set, the
+// nodes left of the path are removed (right edge of the range), otherwise the
+// ones right of it (left edge of the range). The leaf at key is removed as well.
+func unset(parent node, child node, key []byte, pos int, removeLeft bool) error {
+	switch cld := child.(type) {
+	case *fullNode:
+		if removeLeft {
+			for i := 0; i < int(key[pos]); i++ {
+				cld.Children[i] = nil
+			}
+		} else {
+			for i := key[pos] + 1; i < 16; i++ {
+				cld.Children[i] = nil
+			}
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Children[key[pos]], key, pos+1, removeLeft)
+
+	case *shortNode:
+		if len(key[pos:]) < len(cld.Key) || !bytes.Equal(cld.Key, key[pos:pos+len(cld.Key)]) {
+			// The path diverges here (the key doesn't exist). The short node is
+			// removed if it's within the range, otherwise it's kept with its
+			// cached hash. The parent must be a full node.
+			if removeLeft {
+				if bytes.Compare(cld.Key, key[pos:]) < 0 {
+					return unlinkChild(parent, key[pos-1])
+				}
+			} else {
+				if bytes.Compare(cld.Key, key[pos:]) > 0 {
+					return unlinkChild(parent, key[pos-1])
+				}
+			}
+			return nil
+		}
+		if _, ok := cld.Val.(valueNode); ok {
+			return unlinkChild(parent, key[pos-1])
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Val, key, pos+len(cld.Key), removeLeft)
+
+	case nil:
+		// A missing child of the fork point, the key doesn't exist
+		return nil
+
+	default:
+		return fmt.Errorf("invalid proof: unexpected %T on path", child)
+	}
+}
+
+// unlinkChild removes the child at index from parent. Short nodes are always
+// children of full nodes in a valid trie, so any other parent means the proof
+// is malformed.
+func unlinkChild(parent node, index byte) error {
+	fn, ok := parent.(*fullNode)
+	if !ok {
+		return errors.New("invalid proof: short node under short node")
+	}
+	fn.Children[index] = nil
+	return nil
+}
+
+// hasRightElement reports whether the resolved partial trie contains anything
+// right of the path of key.
+func hasRightElement(node node, key []byte) (bool, error) {
+	pos, key := 0, keybytesToHex(key)
+	for node != nil {
+		switch rn := node.(type) {
+		case *fullNode:
+			for i := key[pos] + 1; i < 16; i++ {
+				if rn.Children[i] != nil {
+					return true, nil
+				}
+			}
+			node, pos = rn.Children[key[pos]], pos+1
+		case *shortNode:
+			if len(key)-pos < len(rn.Key) || !bytes.Equal(rn.Key, key[pos:pos+len(rn.Key)]) {
+				return bytes.Compare(rn.Key, key[pos:]) > 0, nil
+			}
+			node, pos = rn.Val, pos+len(rn.Key)
+		case valueNode:
+			return false, nil
+		default:
+			return false, fmt.Errorf("invalid proof: unexpected %T on path", node)
+		}
+	}
+	return false, nil
+}
@@ -20,12 +20,14 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func init() {
@@ -148,6 +150,248 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+// sortedEntries returns the entries of a random trie ordered by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	entries := make([]*kv, 0, len(vals))
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return entries
+}
+
+// increaseKey returns a copy of key incremented by one.
+func increaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x00 {
+			break
+		}
+	}
+	return key
+}
+
+// decreaseKey returns a copy of key decremented by one.
+func decreaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
+// Tests that range proofs of random contiguous spans verify, with the edge keys
+// being either existing leaves or keys between them.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + mrand.Intn(len(entries)-start)
+
+		firstKey, lastKey := entries[start].k, entries[end].k
+		if i%2 == 1 {
+			// Use non-existent edge keys, skipping the leaves in front of
+			// and behind them.
+			if start > 0 {
+				firstKey = increaseKey(entries[start-1].k)
+			}
+			if end < len(entries)-1 {
+				lastKey = decreaseKey(entries[end+1].k)
+			}
+		}
+		proof := memorydb.New()
+		keys, values, err := trie.ProveRange(firstKey, lastKey, proof)
+		if err != nil {
+			t.Fatalf("case %d: failed to prove range: %v", i, err)
+		}
+		if len(keys) != end-start+1 {
+			t.Fatalf("case %d: leaf count mismatch: have %d, want %d", i, len(keys), end-start+1)
+		}
+		more, err := VerifyRangeProof(trie.Hash(), firstKey, lastKey, keys, values, proof)
+		if err != nil {
+			t.Fatalf("case %d (%d-%d): failed to verify range proof: %v", i, start, end, err)
+		}
+		if want := end < len(entries)-1; more != want {
+			t.Fatalf("case %d: more flag mismatch: have %v, want %v", i, more, want)
+		}
+	}
+}
+
+// Tests the special cases of range proofs: empty ranges, single elements and
+// proofs of the entire trie.
+func TestRangeProofSpecialCases(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	// Empty ranges between two leaves, and behind the last one
+	for _, i := range []int{150, 1000, len(entries) - 2} {
+		firstKey, lastKey := increaseKey(entries[i].k), decreaseKey(entries[i+1].k)
+		proof := memorydb.New()
+		keys, values, err := trie.ProveRange(firstKey, lastKey, proof)
+		if err != nil || len(keys) != 0 {
+			t.Fatalf("leaf %d: failed to prove empty range: %d leaves, %v", i, len(keys), err)
+		}
+		if more, err := VerifyRangeProof(root, firstKey, lastKey, keys, values, proof); err != nil || !more {
+			t.Fatalf("leaf %d: failed to verify empty range: more %v, %v", i, more, err)
+		}
+	}
+	last := entries[len(entries)-1].k
+	lastKey := bytes.Repeat([]byte{0xff}, 32)
+	proof := memorydb.New()
+	keys, values, _ := trie.ProveRange(increaseKey(last), lastKey, proof)
+	if more, err := VerifyRangeProof(root, increaseKey(last), lastKey, keys, values, proof); err != nil || more {
+		t.Fatalf("failed to verify empty range behind last leaf: more %v, %v", more, err)
+	}
+	// Single elements, existing and not existing
+	for _, key := range [][]byte{entries[0].k, entries[1000].k, last, increaseKey(entries[1000].k)} {
+		proof := memorydb.New()
+		keys, values, err := trie.ProveRange(key, key, proof)
+		if err != nil {
+			t.Fatalf("key %x: failed to prove single element: %v", key, err)
+		}
+		more, err := VerifyRangeProof(root, key, key, keys, values, proof)
+		if err != nil {
+			t.Fatalf("key %x: failed to verify single element: %v", key, err)
+		}
+		if want := !bytes.Equal(key, last); more != want {
+			t.Fatalf("key %x: more flag mismatch: have %v, want %v", key, more, want)
+		}
+	}
+	// All entries without edge proofs
+	keys, values = nil, nil
+	for _, entry := range entries {
+		keys = append(keys, entry.k)
+		values = append(values, entry.v)
+	}
+	if _, err := VerifyRangeProof(root, nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("failed to verify all entries: %v", err)
+	}
+	if _, err := VerifyRangeProof(root, nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("incomplete entries accepted without edge proofs")
+	}
+	// All entries with edge proofs spanning the entire key space
+	proof = memorydb.New()
+	keys, values, _ = trie.ProveRange(make([]byte, 32), lastKey, proof)
+	if more, err := VerifyRangeProof(root, make([]byte, 32), lastKey, keys, values, proof); err != nil || more {
+		t.Fatalf("failed to verify entire key space: more %v, %v", more, err)
+	}
+}
+
+// Tests that tampered range proofs are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries) - 3)
+		end := start + 2 + mrand.Intn(len(entries)-start-2)
+		firstKey, lastKey := entries[start].k, entries[end].k
+		if i%2 == 1 && start > 0 {
+			firstKey = increaseKey(entries[start-1].k)
+		}
+		proof := memorydb.New()
+		keys, values, err := trie.ProveRange(firstKey, lastKey, proof)
+		if err != nil {
+			t.Fatalf("case %d: failed to prove range: %v", i, err)
+		}
+		var (
+			index    = 1 + mrand.Intn(len(keys)-2)
+			testcase = mrand.Intn(6)
+			name     string
+		)
+		switch testcase {
+		case 0:
+			name = "dropped middle leaf"
+			keys = append(keys[:index:index], keys[index+1:]...)
+			values = append(values[:index:index], values[index+1:]...)
+		case 1:
+			name = "tampered value"
+			values[index] = append(common.CopyBytes(values[index]), 0x01)
+		case 2:
+			name = "dropped first leaf"
+			keys, values = keys[1:], values[1:]
+		case 3:
+			name = "dropped last leaf"
+			keys, values = keys[:len(keys)-1], values[:len(values)-1]
+		case 4:
+			name = "extra leaf"
+			extra := increaseKey(keys[index])
+			if bytes.Equal(extra, keys[index+1]) {
+				continue
+			}
+			keys = append(keys[:index+1:index+1], append([][]byte{extra}, keys[index+1:]...)...)
+			values = append(values[:index+1:index+1], append([][]byte{{0x01}}, values[index+1:]...)...)
+		case 5:
+			name = "wrong boundary"
+			lastKey = decreaseKey(lastKey)
+		}
+		if _, err := VerifyRangeProof(root, firstKey, lastKey, keys, values, proof); err == nil {
+			t.Fatalf("case %d: %s (%d-%d, index %d) accepted", i, name, start, end, index)
+		}
+	}
+}
+
+// Tests that non-empty ranges aren't accepted as empty and leaves can't be
+// hidden behind a single element proof.
+func TestBadRangeProofSpecialCases(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	firstKey, lastKey := increaseKey(entries[199].k), decreaseKey(entries[202].k)
+	proof := memorydb.New()
+	keys, values, _ := trie.ProveRange(firstKey, lastKey, proof)
+	if len(keys) != 2 {
+		t.Fatalf("leaf count mismatch: have %d, want 2", len(keys))
+	}
+	if _, err := VerifyRangeProof(root, firstKey, lastKey, nil, nil, proof); err == nil {
+		t.Fatalf("non-empty range accepted as empty")
+	}
+	key := entries[200].k
+	proof = memorydb.New()
+	keys, values, _ = trie.ProveRange(key, key, proof)
+	if _, err := VerifyRangeProof(root, key, key, nil, nil, proof); err == nil {
+		t.Fatalf("existing single element accepted as missing")
+	}
+	if _, err := VerifyRangeProof(root, key, key, keys, [][]byte{{0x01}}, proof); err == nil {
+		t.Fatalf("tampered single element accepted")
+	}
+	// Unordered leaves
+	proof = memorydb.New()
+	keys, values, _ = trie.ProveRange(entries[10].k, entries[20].k, proof)
+	keys[3], keys[4] = keys[4], keys[3]
+	values[3], values[4] = values[4], values[3]
+	if _, err := VerifyRangeProof(root, entries[10].k, entries[20].k, keys, values, proof); err == nil {
+		t.Fatalf("unordered leaves accepted")
+	}
+}
+
+// Tests that range proofs made of nodes that can't occur in a valid trie are
+// rejected with an error instead of crashing the verifier.
+func TestBadRangeProofMalformedNodes(t *testing.T) {
+	// A short node whose value is the hash of another short node
+	leaf, _ := rlp.EncodeToBytes([]interface{}{hexToCompact([]byte{5, 16}), []byte("v")})
+	leafHash := crypto.Keccak256(leaf)
+	root, _ := rlp.EncodeToBytes([]interface{}{hexToCompact([]byte{1}), leafHash})
+	rootHash := crypto.Keccak256Hash(root)
+
+	proof := memorydb.New()
+	proof.Put(leafHash, leaf)
+	proof.Put(rootHash[:], root)
+
+	if _, err := VerifyRangeProof(rootHash, []byte{0x10}, []byte{0x1f}, nil, nil, proof); err == nil {
+		t.Fatalf("short node under short node accepted")
+	}
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {